@@ -0,0 +1,49 @@
+package gofakes3
+
+import (
+	"net/http"
+	"strings"
+)
+
+const bucketAccelerateConfig = "accelerate"
+
+// getBucketAccelerateConfiguration returns the bucket's transfer acceleration
+// state. Unlike most bucket configurations, S3 does not report an error for a
+// bucket that has never had one set: it returns an AccelerateConfiguration
+// with no Status, which clients tell apart from an explicit 'Suspended'.
+func (g *GoFakeS3) getBucketAccelerateConfiguration(bucket string, w http.ResponseWriter, r *http.Request) error {
+	g.log.Print(LogInfo, "GET BUCKET ACCELERATE:", bucket)
+
+	if err := g.ensureBucketExists(bucket); err != nil {
+		return err
+	}
+
+	config, ok := g.configs.get(resourceConfigKey{bucket: bucket, kind: bucketAccelerateConfig})
+	if !ok {
+		config = &AccelerateConfiguration{Xmlns: "http://s3.amazonaws.com/doc/2006-03-01/"}
+	}
+	return g.xmlEncoder(w).Encode(config)
+}
+
+func (g *GoFakeS3) putBucketAccelerateConfiguration(bucket string, w http.ResponseWriter, r *http.Request) error {
+	g.log.Print(LogInfo, "PUT BUCKET ACCELERATE:", bucket)
+
+	if err := g.ensureBucketExists(bucket); err != nil {
+		return err
+	}
+
+	var in AccelerateConfiguration
+	if err := g.xmlDecodeBody(r.Body, &in); err != nil {
+		return err
+	}
+	if !in.Status.Valid() {
+		return ErrMalformedXML
+	}
+	if strings.Contains(bucket, ".") {
+		return ErrorMessage(ErrInvalidRequest, "S3 Transfer Acceleration is not supported for buckets with periods (.) in their names")
+	}
+	in.Xmlns = "http://s3.amazonaws.com/doc/2006-03-01/"
+
+	g.configs.put(resourceConfigKey{bucket: bucket, kind: bucketAccelerateConfig}, &in)
+	return nil
+}
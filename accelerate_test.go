@@ -0,0 +1,44 @@
+package gofakes3_test
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/johannesboyne/gofakes3"
+)
+
+func TestBucketAccelerateConfiguration(t *testing.T) {
+	ts := newTestServer(t)
+	defer ts.Close()
+	svc := ts.s3Client()
+
+	// A bucket that has never been configured has no Status, rather than an
+	// error or 'Suspended':
+	rs, body := ts.sendRaw("GET", defaultBucket+"?accelerate", nil, nil)
+	if rs.StatusCode != http.StatusOK {
+		t.Fatal("unexpected status", rs.StatusCode, string(body))
+	}
+	if !strings.Contains(string(body), "<AccelerateConfiguration") || strings.Contains(string(body), "<Status") {
+		t.Fatal("unexpected body", string(body))
+	}
+
+	for _, status := range []string{s3.BucketAccelerateStatusEnabled, s3.BucketAccelerateStatusSuspended} {
+		ts.OKAll(svc.PutBucketAccelerateConfiguration(&s3.PutBucketAccelerateConfigurationInput{
+			Bucket:                  aws.String(defaultBucket),
+			AccelerateConfiguration: &s3.AccelerateConfiguration{Status: aws.String(status)},
+		}))
+		out, err := svc.GetBucketAccelerateConfiguration(&s3.GetBucketAccelerateConfigurationInput{
+			Bucket: aws.String(defaultBucket),
+		})
+		ts.OK(err)
+		if aws.StringValue(out.Status) != status {
+			t.Fatal("expected status", status, "found", aws.StringValue(out.Status))
+		}
+	}
+
+	rs, body = ts.sendRaw("PUT", defaultBucket+"?accelerate", []byte(`<AccelerateConfiguration><Status>Nope</Status></AccelerateConfiguration>`), nil)
+	ts.assertRawErrorCode(rs, body, gofakes3.ErrMalformedXML)
+}
@@ -0,0 +1,372 @@
+package gofakes3
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const (
+	objectACLConfig = "acl"
+	bucketACLConfig = "acl"
+)
+
+// defaultOwner owns every bucket, and every object unless WithDefaultOwner or
+// WithOwners is used.
+var defaultOwner = UserInfo{
+	ID:          "fe7272ea58be830e56fe1663b10fafef",
+	DisplayName: "GoFakeS3",
+}
+
+// Grantee URIs for the predefined groups that make an ACL public:
+const (
+	allUsersGroupURI           = "http://acs.amazonaws.com/groups/global/AllUsers"
+	authenticatedUsersGroupURI = "http://acs.amazonaws.com/groups/global/AuthenticatedUsers"
+)
+
+// requestSetsACL reports whether a request asks for an ACL to be applied.
+// When ACLs are disabled, S3 still accepts the 'bucket-owner-full-control'
+// canned ACL, as that is equivalent to the enforced behaviour.
+func requestSetsACL(header http.Header) bool {
+	if acl := header.Get("X-Amz-Acl"); acl != "" && acl != "bucket-owner-full-control" {
+		return true
+	}
+	for key := range header {
+		if strings.HasPrefix(key, "X-Amz-Grant-") {
+			return true
+		}
+	}
+	return false
+}
+
+// requestSetsPublicACL reports whether a request asks for an ACL that grants
+// access to everyone, or to every authenticated AWS user, either with a
+// canned ACL or with an explicit 'x-amz-grant-*' header.
+func requestSetsPublicACL(header http.Header) bool {
+	switch header.Get("X-Amz-Acl") {
+	case "public-read", "public-read-write", "authenticated-read":
+		return true
+	}
+	for key, values := range header {
+		if !strings.HasPrefix(key, "X-Amz-Grant-") {
+			continue
+		}
+		for _, v := range values {
+			if strings.Contains(v, allUsersGroupURI) || strings.Contains(v, authenticatedUsersGroupURI) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// ensureACLsAllowed returns an error if the request sets an ACL that is
+// malformed, or that the bucket does not permit: any ACL if the bucket's
+// ObjectOwnership is BucketOwnerEnforced, or a public ACL if its
+// PublicAccessBlockConfiguration blocks them.
+func (g *GoFakeS3) ensureACLsAllowed(bucket string, header http.Header) error {
+	if !requestSetsACL(header) {
+		return nil
+	}
+	if _, err := grantHeadersPolicy(header.Get, g.defaultOwner); err != nil {
+		return err
+	}
+	if g.bucketObjectOwnership(bucket) == ObjectOwnershipBucketOwnerEnforced {
+		return ErrAccessControlListNotSupported
+	}
+	if requestSetsPublicACL(header) && g.bucketPublicAccessBlock(bucket).BlockPublicAcls {
+		return ErrAccessDenied
+	}
+	return nil
+}
+
+// cannedACLPolicy expands a canned ACL, as sent in the 'x-amz-acl' header,
+// into the AccessControlPolicy S3 would report for it. The bucket owner
+// grants in 'bucket-owner-*' ACLs are subsumed by the owner's FULL_CONTROL
+// grant if the object and the bucket have the same owner.
+//
+// https://docs.aws.amazon.com/AmazonS3/latest/userguide/acl-overview.html#canned-acl
+func cannedACLPolicy(acl string, owner, bucketOwner UserInfo) (*AccessControlPolicy, error) {
+	group := func(uri string, perm Permission) Grant {
+		return Grant{Grantee: &Grantee{Type: GranteeGroup, URI: uri}, Permission: perm}
+	}
+	user := func(user UserInfo, perm Permission) Grant {
+		return Grant{Grantee: &Grantee{Type: GranteeCanonicalUser, ID: user.ID, DisplayName: user.DisplayName}, Permission: perm}
+	}
+
+	grants := []Grant{user(owner, PermissionFullControl)}
+
+	switch acl {
+	case "", "private", "aws-exec-read":
+	case "bucket-owner-read":
+		if bucketOwner != owner {
+			grants = append(grants, user(bucketOwner, PermissionRead))
+		}
+	case "bucket-owner-full-control":
+		if bucketOwner != owner {
+			grants = append(grants, user(bucketOwner, PermissionFullControl))
+		}
+	case "public-read":
+		grants = append(grants, group(allUsersGroupURI, PermissionRead))
+	case "public-read-write":
+		grants = append(grants, group(allUsersGroupURI, PermissionRead), group(allUsersGroupURI, PermissionWrite))
+	case "authenticated-read":
+		grants = append(grants, group(authenticatedUsersGroupURI, PermissionRead))
+	default:
+		return nil, ErrorInvalidArgument("x-amz-acl", acl, "Invalid canned ACL")
+	}
+
+	return &AccessControlPolicy{
+		Xmlns:             "http://s3.amazonaws.com/doc/2006-03-01/",
+		Owner:             &owner,
+		AccessControlList: AccessControlList{Grants: grants},
+	}, nil
+}
+
+// grantHeaders maps the explicit 'x-amz-grant-*' headers to the permission
+// each grants.
+var grantHeaders = []struct {
+	header     string
+	permission Permission
+}{
+	{"X-Amz-Grant-Full-Control", PermissionFullControl},
+	{"X-Amz-Grant-Read", PermissionRead},
+	{"X-Amz-Grant-Read-Acp", PermissionReadACP},
+	{"X-Amz-Grant-Write", PermissionWrite},
+	{"X-Amz-Grant-Write-Acp", PermissionWriteACP},
+}
+
+// grantHeadersPolicy builds the AccessControlPolicy for the explicit
+// 'x-amz-grant-*' headers returned by get, which hold comma separated
+// grantees such as 'id="...", emailAddress="...", uri="..."'. Like S3, only
+// the grants in the headers are included; the owner is not given
+// FULL_CONTROL unless a header grants it.
+//
+// It returns nil if there are no grant headers, and an error if they are
+// malformed or sent along with a canned ACL.
+//
+// https://docs.aws.amazon.com/AmazonS3/latest/API/API_PutObject.html#API_PutObject_RequestSyntax
+func grantHeadersPolicy(get func(key string) string, owner UserInfo) (*AccessControlPolicy, error) {
+	var grants []Grant
+	for _, gh := range grantHeaders {
+		value := get(gh.header)
+		if value == "" {
+			continue
+		}
+		for _, item := range strings.Split(value, ",") {
+			grantee, err := parseGrantee(strings.TrimSpace(item))
+			if err != nil {
+				return nil, ErrorInvalidArgument(strings.ToLower(gh.header), value, "Argument format not recognized")
+			}
+			if grantee.Type == GranteeCanonicalUser && grantee.ID == owner.ID {
+				grantee.DisplayName = owner.DisplayName
+			}
+			grants = append(grants, Grant{Grantee: grantee, Permission: gh.permission})
+		}
+	}
+	if grants == nil {
+		return nil, nil
+	}
+	if get("X-Amz-Acl") != "" {
+		return nil, ErrorMessage(ErrInvalidRequest, "Specifying both Canned ACLs and Header Grants is not allowed")
+	}
+
+	return &AccessControlPolicy{
+		Xmlns:             "http://s3.amazonaws.com/doc/2006-03-01/",
+		Owner:             &owner,
+		AccessControlList: AccessControlList{Grants: grants},
+	}, nil
+}
+
+// parseGrantee parses one grantee of a grant header, such as 'id="abc"'.
+// The quotes are optional.
+func parseGrantee(item string) (*Grantee, error) {
+	eq := strings.IndexByte(item, '=')
+	if eq < 0 {
+		return nil, ErrMalformedACLError
+	}
+	kind, value := item[:eq], strings.TrimSpace(item[eq+1:])
+	if len(value) >= 2 && value[0] == '"' && value[len(value)-1] == '"' {
+		value = value[1 : len(value)-1]
+	}
+	if value == "" {
+		return nil, ErrMalformedACLError
+	}
+
+	switch strings.ToLower(strings.TrimSpace(kind)) {
+	case "id":
+		return &Grantee{Type: GranteeCanonicalUser, ID: value}, nil
+	case "emailaddress":
+		return &Grantee{Type: GranteeAmazonCustomerByEmail, EmailAddress: value}, nil
+	case "uri":
+		return &Grantee{Type: GranteeGroup, URI: value}, nil
+	}
+	return nil, ErrMalformedACLError
+}
+
+// isPublic reports whether the policy grants any access to everyone, or to
+// every authenticated AWS user.
+func (p *AccessControlPolicy) isPublic() bool {
+	for _, grant := range p.AccessControlList.Grants {
+		if grant.Grantee != nil && (grant.Grantee.URI == allUsersGroupURI || grant.Grantee.URI == authenticatedUsersGroupURI) {
+			return true
+		}
+	}
+	return false
+}
+
+// objectACL returns the AccessControlPolicy of an object version, which
+// headObjectVersion has already fetched.
+func (g *GoFakeS3) objectACL(bucket, object string, obj *Object) (*AccessControlPolicy, error) {
+	key := resourceConfigKey{bucket: bucket, object: object, version: obj.VersionID, kind: objectACLConfig}
+	if config, ok := g.configs.get(key); ok {
+		return config.(*AccessControlPolicy), nil
+	}
+
+	// Without an explicit ACL, the object has the canned ACL or grant
+	// headers it was created with, which metadataHeaders stores along with
+	// the other 'x-amz-' headers:
+	owner := g.objectOwner(bucket, object, obj.VersionID)
+	policy, err := grantHeadersPolicy(func(key string) string { return obj.Metadata[key] }, owner)
+	if policy != nil || err != nil {
+		return policy, err
+	}
+	return cannedACLPolicy(obj.Metadata["X-Amz-Acl"], owner, g.defaultOwner)
+}
+
+// allowsAnonymousRead reports whether the policy grants READ to everyone.
+func (p *AccessControlPolicy) allowsAnonymousRead() bool {
+	for _, grant := range p.AccessControlList.Grants {
+		if grant.Grantee != nil && grant.Grantee.URI == allUsersGroupURI &&
+			(grant.Permission == PermissionRead || grant.Permission == PermissionFullControl) {
+			return true
+		}
+	}
+	return false
+}
+
+// authorizeAnonymous implements WithAnonymousAccessControl. Requests that
+// carry credentials are let through, as signatures are not verified; an
+// anonymous request may only GET or HEAD an object whose ACL grants READ to
+// everyone, and only if the bucket's PublicAccessBlockConfiguration does not
+// ignore public ACLs.
+func (g *GoFakeS3) authorizeAnonymous(bucket, object string, query url.Values, r *http.Request) error {
+	if !g.anonymousAccessControl || requestAccessKey(r) != "" {
+		return nil
+	}
+	if bucket == "" || object == "" || (r.Method != http.MethodGet && r.Method != http.MethodHead) {
+		return ErrAccessDenied
+	}
+	for key := range query {
+		if key != "versionId" && !strings.HasPrefix(key, "response-") {
+			return ErrAccessDenied
+		}
+	}
+
+	// Like S3, anonymous requests for objects that don't exist are denied
+	// rather than revealing whether the key exists:
+	obj, err := g.headObjectVersion(bucket, object, VersionID(versionFromQuery(query["versionId"])))
+	if HasErrorCode(err, ErrNoSuchKey) || HasErrorCode(err, ErrNoSuchBucket) || HasErrorCode(err, ErrNoSuchVersion) {
+		return ErrAccessDenied
+	} else if err != nil {
+		return err
+	}
+
+	policy, err := g.objectACL(bucket, object, obj)
+	if err != nil {
+		return err
+	}
+	if !policy.allowsAnonymousRead() || g.bucketPublicAccessBlock(bucket).IgnorePublicAcls {
+		return ErrAccessDenied
+	}
+	return nil
+}
+
+func (g *GoFakeS3) getObjectACL(bucket, object string, versionID VersionID, w http.ResponseWriter, r *http.Request) error {
+	g.log.Print(LogInfo, "GET OBJECT ACL:", bucket, object, versionID)
+
+	obj, err := g.headObjectVersion(bucket, object, versionID)
+	if err != nil {
+		return err
+	}
+
+	policy, err := g.objectACL(bucket, object, obj)
+	if err != nil {
+		return err
+	}
+	return g.xmlEncoder(w).Encode(policy)
+}
+
+func (g *GoFakeS3) putObjectACL(bucket, object string, versionID VersionID, w http.ResponseWriter, r *http.Request) error {
+	g.log.Print(LogInfo, "PUT OBJECT ACL:", bucket, object, versionID)
+
+	key, err := g.objectConfigKey(bucket, object, versionID, objectACLConfig)
+	if err != nil {
+		return err
+	}
+
+	owner := g.objectOwner(bucket, object, key.version)
+
+	var policy *AccessControlPolicy
+	if acl := r.Header.Get("X-Amz-Acl"); acl != "" {
+		if policy, err = cannedACLPolicy(acl, owner, g.defaultOwner); err != nil {
+			return err
+		}
+		if err := g.ensureACLsAllowed(bucket, r.Header); err != nil {
+			return err
+		}
+
+	} else if policy, err = grantHeadersPolicy(r.Header.Get, owner); err != nil {
+		return err
+
+	} else if policy != nil {
+		if err := g.ensureACLsAllowed(bucket, r.Header); err != nil {
+			return err
+		}
+
+	} else {
+		var in AccessControlPolicy
+		if err := g.xmlDecodeBody(r.Body, &in); err != nil {
+			return err
+		}
+		for _, grant := range in.AccessControlList.Grants {
+			if grant.Grantee == nil || !grant.Permission.Valid() {
+				return ErrMalformedACLError
+			}
+		}
+		if in.Owner == nil {
+			in.Owner = &owner
+		}
+		in.Xmlns = "http://s3.amazonaws.com/doc/2006-03-01/"
+
+		if g.bucketObjectOwnership(bucket) == ObjectOwnershipBucketOwnerEnforced {
+			return ErrAccessControlListNotSupported
+		}
+		if in.isPublic() && g.bucketPublicAccessBlock(bucket).BlockPublicAcls {
+			return ErrAccessDenied
+		}
+		policy = &in
+	}
+
+	g.configs.put(key, policy)
+	return nil
+}
+
+// bucketACL returns the AccessControlPolicy of a bucket: the canned ACL it
+// was created with, or 'private' if there was none.
+func (g *GoFakeS3) bucketACL(bucket string) *AccessControlPolicy {
+	if config, ok := g.configs.get(resourceConfigKey{bucket: bucket, kind: bucketACLConfig}); ok {
+		return config.(*AccessControlPolicy)
+	}
+	policy, _ := cannedACLPolicy("private", g.defaultOwner, g.defaultOwner)
+	return policy
+}
+
+func (g *GoFakeS3) getBucketACL(bucket string, w http.ResponseWriter, r *http.Request) error {
+	g.log.Print(LogInfo, "GET BUCKET ACL:", bucket)
+
+	if err := g.ensureBucketExists(bucket); err != nil {
+		return err
+	}
+	return g.xmlEncoder(w).Encode(g.bucketACL(bucket))
+}
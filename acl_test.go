@@ -0,0 +1,297 @@
+package gofakes3_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/johannesboyne/gofakes3"
+)
+
+func TestObjectACL(t *testing.T) {
+	ts := newTestServer(t)
+	defer ts.Close()
+	svc := ts.s3Client()
+
+	getGrants := func(key string) []*s3.Grant {
+		t.Helper()
+		rs, err := svc.GetObjectAcl(&s3.GetObjectAclInput{
+			Bucket: aws.String(defaultBucket),
+			Key:    aws.String(key),
+		})
+		ts.OK(err)
+		if rs.Owner == nil || aws.StringValue(rs.Owner.DisplayName) == "" {
+			t.Fatal("missing owner")
+		}
+		return rs.Grants
+	}
+
+	assertOwnerOnly := func(grants []*s3.Grant) {
+		t.Helper()
+		if len(grants) != 1 ||
+			aws.StringValue(grants[0].Grantee.Type) != s3.TypeCanonicalUser ||
+			aws.StringValue(grants[0].Permission) != s3.PermissionFullControl {
+			t.Fatal("unexpected grants", grants)
+		}
+	}
+
+	t.Run("default-private", func(t *testing.T) {
+		ts.backendPutString(defaultBucket, "private", nil, "hello")
+		assertOwnerOnly(getGrants("private"))
+	})
+
+	t.Run("canned-at-create", func(t *testing.T) {
+		ts.OKAll(svc.PutObject(&s3.PutObjectInput{
+			Bucket: aws.String(defaultBucket),
+			Key:    aws.String("public"),
+			Body:   bytes.NewReader([]byte("hello")),
+			ACL:    aws.String(s3.ObjectCannedACLPublicRead),
+		}))
+
+		grants := getGrants("public")
+		if len(grants) != 2 {
+			t.Fatal("unexpected grants", grants)
+		}
+		if aws.StringValue(grants[1].Grantee.Type) != s3.TypeGroup ||
+			aws.StringValue(grants[1].Grantee.URI) != "http://acs.amazonaws.com/groups/global/AllUsers" ||
+			aws.StringValue(grants[1].Permission) != s3.PermissionRead {
+			t.Fatal("unexpected public grant", grants[1])
+		}
+	})
+
+	t.Run("grants-at-create", func(t *testing.T) {
+		ts.OKAll(svc.PutObject(&s3.PutObjectInput{
+			Bucket:           aws.String(defaultBucket),
+			Key:              aws.String("granted"),
+			Body:             bytes.NewReader([]byte("hello")),
+			GrantFullControl: aws.String(`id="someone", emailAddress="someone@example.com"`),
+			GrantRead:        aws.String(`uri="http://acs.amazonaws.com/groups/global/AllUsers"`),
+		}))
+
+		grants := getGrants("granted")
+		if len(grants) != 3 ||
+			aws.StringValue(grants[0].Grantee.Type) != s3.TypeCanonicalUser ||
+			aws.StringValue(grants[0].Grantee.ID) != "someone" ||
+			aws.StringValue(grants[0].Permission) != s3.PermissionFullControl ||
+			aws.StringValue(grants[1].Grantee.Type) != s3.TypeAmazonCustomerByEmail ||
+			aws.StringValue(grants[1].Grantee.EmailAddress) != "someone@example.com" ||
+			aws.StringValue(grants[1].Permission) != s3.PermissionFullControl ||
+			aws.StringValue(grants[2].Grantee.Type) != s3.TypeGroup ||
+			aws.StringValue(grants[2].Grantee.URI) != "http://acs.amazonaws.com/groups/global/AllUsers" ||
+			aws.StringValue(grants[2].Permission) != s3.PermissionRead {
+			t.Fatal("unexpected grants", grants)
+		}
+
+		for _, input := range []*s3.PutObjectInput{
+			{GrantRead: aws.String(`name="someone"`)},
+			{GrantRead: aws.String(`id=""`)},
+			{GrantRead: aws.String(`id="someone"`), ACL: aws.String(s3.ObjectCannedACLPrivate)},
+		} {
+			input.Bucket, input.Key = aws.String(defaultBucket), aws.String("invalid-grant")
+			input.Body = bytes.NewReader([]byte("hello"))
+			_, err := svc.PutObject(input)
+			if !hasErrorCode(err, gofakes3.ErrInvalidArgument) && !hasErrorCode(err, gofakes3.ErrInvalidRequest) {
+				t.Fatal("expected an error, found", err)
+			}
+		}
+		if ts.backendObjectExists(defaultBucket, "invalid-grant") {
+			t.Fatal("object was created")
+		}
+	})
+
+	t.Run("put-grants", func(t *testing.T) {
+		ts.backendPutString(defaultBucket, "put-grants", nil, "hello")
+		ts.OKAll(svc.PutObjectAcl(&s3.PutObjectAclInput{
+			Bucket:    aws.String(defaultBucket),
+			Key:       aws.String("put-grants"),
+			GrantRead: aws.String(`id="someone"`),
+		}))
+		grants := getGrants("put-grants")
+		if len(grants) != 1 || aws.StringValue(grants[0].Grantee.ID) != "someone" {
+			t.Fatal("unexpected grants", grants)
+		}
+	})
+
+	t.Run("put-canned", func(t *testing.T) {
+		ts.backendPutString(defaultBucket, "canned", nil, "hello")
+		ts.OKAll(svc.PutObjectAcl(&s3.PutObjectAclInput{
+			Bucket: aws.String(defaultBucket),
+			Key:    aws.String("canned"),
+			ACL:    aws.String(s3.ObjectCannedACLAuthenticatedRead),
+		}))
+
+		grants := getGrants("canned")
+		if len(grants) != 2 || aws.StringValue(grants[1].Grantee.URI) != "http://acs.amazonaws.com/groups/global/AuthenticatedUsers" {
+			t.Fatal("unexpected grants", grants)
+		}
+	})
+
+	t.Run("put-policy", func(t *testing.T) {
+		ts.backendPutString(defaultBucket, "policy", nil, "hello")
+		ts.OKAll(svc.PutObjectAcl(&s3.PutObjectAclInput{
+			Bucket: aws.String(defaultBucket),
+			Key:    aws.String("policy"),
+			AccessControlPolicy: &s3.AccessControlPolicy{
+				Owner: &s3.Owner{ID: aws.String("owner"), DisplayName: aws.String("Owner")},
+				Grants: []*s3.Grant{{
+					Grantee: &s3.Grantee{
+						Type:        aws.String(s3.TypeCanonicalUser),
+						ID:          aws.String("someone"),
+						DisplayName: aws.String("Someone"),
+					},
+					Permission: aws.String(s3.PermissionReadAcp),
+				}},
+			},
+		}))
+
+		grants := getGrants("policy")
+		if len(grants) != 1 ||
+			aws.StringValue(grants[0].Grantee.Type) != s3.TypeCanonicalUser ||
+			aws.StringValue(grants[0].Grantee.ID) != "someone" ||
+			aws.StringValue(grants[0].Permission) != s3.PermissionReadAcp {
+			t.Fatal("unexpected grants", grants)
+		}
+
+		// Overwriting the object resets its ACL:
+		ts.OKAll(svc.PutObject(&s3.PutObjectInput{
+			Bucket: aws.String(defaultBucket),
+			Key:    aws.String("policy"),
+			Body:   bytes.NewReader([]byte("hello")),
+		}))
+		assertOwnerOnly(getGrants("policy"))
+	})
+
+	t.Run("invalid-canned", func(t *testing.T) {
+		ts.backendPutString(defaultBucket, "invalid", nil, "hello")
+		_, err := svc.PutObjectAcl(&s3.PutObjectAclInput{
+			Bucket: aws.String(defaultBucket),
+			Key:    aws.String("invalid"),
+			ACL:    aws.String("nope"),
+		})
+		if !hasErrorCode(err, gofakes3.ErrInvalidArgument) {
+			t.Fatal("expected ErrInvalidArgument, found", err)
+		}
+	})
+
+	t.Run("missing-object", func(t *testing.T) {
+		_, err := svc.GetObjectAcl(&s3.GetObjectAclInput{
+			Bucket: aws.String(defaultBucket),
+			Key:    aws.String("nope"),
+		})
+		if !hasErrorCode(err, gofakes3.ErrNoSuchKey) {
+			t.Fatal("expected ErrNoSuchKey, found", err)
+		}
+	})
+}
+
+func TestBucketACL(t *testing.T) {
+	ts := newTestServer(t)
+	defer ts.Close()
+	svc := ts.s3Client()
+
+	getGrants := func(bucket string) []*s3.Grant {
+		t.Helper()
+		rs, err := svc.GetBucketAcl(&s3.GetBucketAclInput{Bucket: aws.String(bucket)})
+		ts.OK(err)
+		if rs.Owner == nil || aws.StringValue(rs.Owner.DisplayName) == "" {
+			t.Fatal("missing owner")
+		}
+		return rs.Grants
+	}
+
+	if grants := getGrants(defaultBucket); len(grants) != 1 ||
+		aws.StringValue(grants[0].Grantee.Type) != s3.TypeCanonicalUser ||
+		aws.StringValue(grants[0].Permission) != s3.PermissionFullControl {
+		t.Fatal("unexpected grants", grants)
+	}
+
+	ts.OKAll(svc.CreateBucket(&s3.CreateBucketInput{
+		Bucket: aws.String("public"),
+		ACL:    aws.String(s3.BucketCannedACLPublicRead),
+	}))
+	grants := getGrants("public")
+	if len(grants) != 2 ||
+		aws.StringValue(grants[0].Permission) != s3.PermissionFullControl ||
+		aws.StringValue(grants[1].Grantee.Type) != s3.TypeGroup ||
+		aws.StringValue(grants[1].Grantee.URI) != "http://acs.amazonaws.com/groups/global/AllUsers" ||
+		aws.StringValue(grants[1].Permission) != s3.PermissionRead {
+		t.Fatal("unexpected grants", grants)
+	}
+
+	ts.OKAll(svc.CreateBucket(&s3.CreateBucketInput{
+		Bucket:           aws.String("granted"),
+		GrantFullControl: aws.String(`id="someone"`),
+	}))
+	if grants := getGrants("granted"); len(grants) != 1 ||
+		aws.StringValue(grants[0].Grantee.ID) != "someone" ||
+		aws.StringValue(grants[0].Permission) != s3.PermissionFullControl {
+		t.Fatal("unexpected grants", grants)
+	}
+
+	// An invalid canned ACL fails before the bucket is created:
+	_, err := svc.CreateBucket(&s3.CreateBucketInput{
+		Bucket: aws.String("invalid"),
+		ACL:    aws.String("nope"),
+	})
+	if !hasErrorCode(err, gofakes3.ErrInvalidArgument) {
+		t.Fatal("expected InvalidArgument, found", err)
+	}
+	_, err = svc.HeadBucket(&s3.HeadBucketInput{Bucket: aws.String("invalid")})
+	if err == nil {
+		t.Fatal("bucket was created")
+	}
+
+	_, err = svc.GetBucketAcl(&s3.GetBucketAclInput{Bucket: aws.String("missing")})
+	if !hasErrorCode(err, gofakes3.ErrNoSuchBucket) {
+		t.Fatal("expected NoSuchBucket, found", err)
+	}
+}
+
+func TestAnonymousAccessControl(t *testing.T) {
+	ts := newTestServer(t, withFakerOptions(gofakes3.WithAnonymousAccessControl()))
+	defer ts.Close()
+	svc := ts.s3Client()
+
+	for key, acl := range map[string]string{"public": "public-read", "private": "private"} {
+		ts.OKAll(svc.PutObject(&s3.PutObjectInput{
+			Bucket: aws.String(defaultBucket),
+			Key:    aws.String(key),
+			ACL:    aws.String(acl),
+			Body:   bytes.NewReader([]byte("hello")),
+		}))
+	}
+
+	rs, body := ts.sendRaw("GET", defaultBucket+"/public", nil, nil)
+	if rs.StatusCode != 200 || string(body) != "hello" {
+		t.Fatal("unexpected response", rs.StatusCode, string(body))
+	}
+	rs, _ = ts.sendRaw("HEAD", defaultBucket+"/public", nil, nil)
+	if rs.StatusCode != 200 {
+		t.Fatal("unexpected status", rs.StatusCode)
+	}
+
+	for _, path := range []string{
+		defaultBucket + "/private",
+		defaultBucket + "/missing",
+		defaultBucket + "/public?acl",
+		defaultBucket,
+	} {
+		rs, body := ts.sendRaw("GET", path, nil, nil)
+		ts.assertRawErrorCode(rs, body, gofakes3.ErrAccessDenied)
+	}
+	rs, body = ts.sendRaw("PUT", defaultBucket+"/public", []byte("changed"), nil)
+	ts.assertRawErrorCode(rs, body, gofakes3.ErrAccessDenied)
+
+	// Requests with credentials are still allowed everything:
+	ts.assertObject(defaultBucket, "private", nil, "hello")
+
+	ts.OKAll(svc.PutPublicAccessBlock(&s3.PutPublicAccessBlockInput{
+		Bucket: aws.String(defaultBucket),
+		PublicAccessBlockConfiguration: &s3.PublicAccessBlockConfiguration{
+			IgnorePublicAcls: aws.Bool(true),
+		},
+	}))
+	rs, body = ts.sendRaw("GET", defaultBucket+"/public", nil, nil)
+	ts.assertRawErrorCode(rs, body, gofakes3.ErrAccessDenied)
+}
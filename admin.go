@@ -0,0 +1,162 @@
+package gofakes3
+
+import (
+	"html/template"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+)
+
+// AdminHandler returns an http.Handler serving a small HTML interface for
+// inspecting the Backend: it lists the buckets, the objects in each bucket
+// with their sizes and ETags, and lets objects be downloaded or deleted.
+//
+// It is a debugging aid, not part of the S3 API: it talks to the Backend
+// directly, bypassing every check GoFakeS3 makes on S3 requests, and has no
+// authentication. The handler expects to be served from the root of its own
+// server; see WithAdminUI to serve it alongside the S3 API instead.
+func (g *GoFakeS3) AdminHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", g.adminBuckets)
+	mux.HandleFunc("/bucket", g.adminBucket)
+	mux.HandleFunc("/object", g.adminObject)
+	return mux
+}
+
+// adminMiddleware implements WithAdminUI. Requests for the admin UI are not
+// S3 requests, so they are served before any other middleware can reject
+// them, for example for being unsigned.
+func (g *GoFakeS3) adminMiddleware(handler http.Handler) http.Handler {
+	admin := http.StripPrefix(g.adminPrefix, g.AdminHandler())
+	return http.HandlerFunc(func(w http.ResponseWriter, rq *http.Request) {
+		switch {
+		case rq.URL.Path == g.adminPrefix:
+			// The admin UI's links are relative to its root:
+			http.Redirect(w, rq, g.adminPrefix+"/", http.StatusMovedPermanently)
+		case strings.HasPrefix(rq.URL.Path, g.adminPrefix+"/"):
+			admin.ServeHTTP(w, rq)
+		default:
+			handler.ServeHTTP(w, rq)
+		}
+	})
+}
+
+var adminTemplates = template.Must(template.New("admin").Parse(`
+{{define "buckets"}}<!DOCTYPE html>
+<html><head><title>gofakes3</title></head><body>
+<h1>Buckets</h1>
+<table>
+<tr><th>Name</th><th>Created</th></tr>
+{{range .}}<tr><td><a href="bucket?bucket={{.Name}}">{{.Name}}</a></td><td>{{.CreationDate.Format "2006-01-02T15:04:05Z07:00"}}</td></tr>
+{{end}}</table>
+</body></html>
+{{end}}
+
+{{define "bucket"}}<!DOCTYPE html>
+<html><head><title>gofakes3: {{.Bucket}}</title></head><body>
+<p><a href="./">Buckets</a></p>
+<h1>{{.Bucket}}</h1>
+<table>
+<tr><th>Key</th><th>Size</th><th>ETag</th><th>Last modified</th><th></th></tr>
+{{range .Objects}}<tr>
+<td><a href="object?bucket={{$.Bucket}}&amp;key={{.Key}}">{{.Key}}</a></td>
+<td>{{.Size}}</td>
+<td>{{.ETag}}</td>
+<td>{{.LastModified.Format "2006-01-02T15:04:05Z07:00"}}</td>
+<td><form method="POST" action="object?bucket={{$.Bucket}}&amp;key={{.Key}}"><button>Delete</button></form></td>
+</tr>
+{{end}}</table>
+</body></html>
+{{end}}
+`))
+
+func (g *GoFakeS3) adminError(w http.ResponseWriter, err error) {
+	code := ensureErrorResponse(err, "", "").ErrorCode()
+	http.Error(w, err.Error(), code.Status())
+}
+
+func (g *GoFakeS3) adminBuckets(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+
+	buckets, err := g.storage.ListBuckets()
+	if err != nil {
+		g.adminError(w, err)
+		return
+	}
+	sort.Slice(buckets, func(i, j int) bool { return buckets[i].Name < buckets[j].Name })
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := adminTemplates.ExecuteTemplate(w, "buckets", buckets); err != nil {
+		g.log.Print(LogErr, "admin template failed:", err)
+	}
+}
+
+func (g *GoFakeS3) adminBucket(w http.ResponseWriter, r *http.Request) {
+	bucket := r.URL.Query().Get("bucket")
+
+	// A Backend must return everything when passed an empty ListBucketPage:
+	objects, err := g.storage.ListBucket(bucket, &Prefix{}, ListBucketPage{})
+	if err != nil {
+		g.adminError(w, err)
+		return
+	}
+	for _, item := range objects.Contents {
+		item.ETag = strings.Trim(item.ETag, `"`)
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := adminTemplates.ExecuteTemplate(w, "bucket", struct {
+		Bucket  string
+		Objects []*Content
+	}{bucket, objects.Contents}); err != nil {
+		g.log.Print(LogErr, "admin template failed:", err)
+	}
+}
+
+// adminObject downloads an object with GET, or deletes it with POST, as HTML
+// forms cannot send DELETE.
+func (g *GoFakeS3) adminObject(w http.ResponseWriter, r *http.Request) {
+	bucket, key := r.URL.Query().Get("bucket"), r.URL.Query().Get("key")
+
+	switch r.Method {
+	case "GET":
+		obj, err := g.storage.GetObject(bucket, key, nil)
+		if err != nil {
+			g.adminError(w, err)
+			return
+		}
+		defer obj.Contents.Close()
+
+		contentType := obj.Metadata["Content-Type"]
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Set("Content-Disposition", `attachment; filename="`+strings.ReplaceAll(path.Base(key), `"`, "")+`"`)
+		if _, err := io.Copy(w, obj.Contents); err != nil {
+			g.log.Print(LogErr, "admin download failed:", err)
+		}
+
+	case "POST":
+		g.log.Print(LogInfo, "ADMIN DELETE OBJECT:", bucket, key)
+		if _, err := g.storage.DeleteObject(bucket, key); err != nil {
+			g.adminError(w, err)
+			return
+		}
+		// http.Redirect would resolve the location against the path the
+		// request has been stripped to by WithAdminUI, so the client is left
+		// to resolve it instead:
+		w.Header().Set("Location", "bucket?bucket="+url.QueryEscape(bucket))
+		w.WriteHeader(http.StatusSeeOther)
+
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
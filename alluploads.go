@@ -0,0 +1,92 @@
+package gofakes3
+
+import (
+	"sort"
+	"time"
+)
+
+// MultipartUploadInfo describes an in-progress multipart upload, as returned
+// by GoFakeS3.ListAllMultipartUploads.
+type MultipartUploadInfo struct {
+	Bucket    string
+	Key       string
+	UploadID  UploadID
+	Initiated time.Time
+}
+
+// ListAllMultipartUploads returns every multipart upload that has been
+// created but neither completed nor aborted, across all buckets, ordered by
+// bucket, then key, then initiation time.
+//
+// This is not part of the S3 API, which only lists uploads a bucket at a
+// time. It is intended for tests, to check in their cleanup that no upload
+// was left behind.
+func (g *GoFakeS3) ListAllMultipartUploads() ([]MultipartUploadInfo, error) {
+	var uploads []MultipartUploadInfo
+
+	if mb, ok := g.multipart.(*multipartBackend); ok {
+		// The default MultipartBackend can also report uploads in buckets
+		// that have since been deleted:
+		uploads = mb.uploader.all()
+
+	} else {
+		buckets, err := g.storage.ListBuckets()
+		if err != nil {
+			return nil, err
+		}
+		for _, bucket := range buckets {
+			var marker *UploadListMarker
+			for {
+				list, err := g.multipart.ListMultipartUploads(bucket.Name, marker, Prefix{}, DefaultMaxUploads)
+				if HasErrorCode(err, ErrNoSuchUpload) {
+					break
+				} else if err != nil {
+					return nil, err
+				}
+				for _, upload := range list.Uploads {
+					uploads = append(uploads, MultipartUploadInfo{
+						Bucket:    bucket.Name,
+						Key:       upload.Key,
+						UploadID:  upload.UploadID,
+						Initiated: upload.Initiated.Time,
+					})
+				}
+				if !list.IsTruncated {
+					break
+				}
+				marker = &UploadListMarker{Object: list.NextKeyMarker, UploadID: list.NextUploadIDMarker}
+			}
+		}
+	}
+
+	sort.SliceStable(uploads, func(i, j int) bool {
+		a, b := uploads[i], uploads[j]
+		if a.Bucket != b.Bucket {
+			return a.Bucket < b.Bucket
+		} else if a.Key != b.Key {
+			return a.Key < b.Key
+		} else if !a.Initiated.Equal(b.Initiated) {
+			return a.Initiated.Before(b.Initiated)
+		}
+		return a.UploadID < b.UploadID
+	})
+	return uploads, nil
+}
+
+func (u *uploader) all() []MultipartUploadInfo {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	var uploads []MultipartUploadInfo
+	for _, bucketUploads := range u.buckets {
+		for _, mpu := range bucketUploads.uploads {
+			uploads = append(uploads, MultipartUploadInfo{
+				Bucket:    mpu.Bucket,
+				Key:       mpu.Object,
+				UploadID:  mpu.ID,
+				Initiated: mpu.Initiated,
+			})
+		}
+	}
+	return uploads
+}
@@ -0,0 +1,62 @@
+package gofakes3
+
+import "net/http"
+
+const bucketAnalyticsConfig = "analytics"
+
+// MaxAnalyticsConfigurations is the number of analytics configurations a
+// bucket may have, documented here:
+// https://docs.aws.amazon.com/AmazonS3/latest/API/API_PutBucketAnalyticsConfiguration.html
+const MaxAnalyticsConfigurations = 1000
+
+func (g *GoFakeS3) getBucketAnalyticsConfiguration(bucket string, w http.ResponseWriter, r *http.Request) error {
+	g.log.Print(LogInfo, "GET BUCKET ANALYTICS CONFIGURATION:", bucket, r.URL.Query().Get("id"))
+
+	config, err := g.getIDConfig(bucket, bucketAnalyticsConfig, r)
+	if err != nil {
+		return err
+	}
+	out := *config.(*AnalyticsConfiguration)
+	out.Xmlns = "http://s3.amazonaws.com/doc/2006-03-01/"
+	return g.xmlEncoder(w).Encode(&out)
+}
+
+func (g *GoFakeS3) listBucketAnalyticsConfigurations(bucket string, w http.ResponseWriter, r *http.Request) error {
+	g.log.Print(LogInfo, "LIST BUCKET ANALYTICS CONFIGURATIONS:", bucket)
+
+	configs, token, next, err := g.listIDConfigs(bucket, bucketAnalyticsConfig, r)
+	if err != nil {
+		return err
+	}
+
+	out := ListBucketAnalyticsConfigurationsResult{
+		Xmlns:                 "http://s3.amazonaws.com/doc/2006-03-01/",
+		IsTruncated:           next != "",
+		ContinuationToken:     token,
+		NextContinuationToken: next,
+	}
+	for _, config := range configs {
+		out.AnalyticsConfigurations = append(out.AnalyticsConfigurations, *config.(*AnalyticsConfiguration))
+	}
+	return g.xmlEncoder(w).Encode(&out)
+}
+
+func (g *GoFakeS3) putBucketAnalyticsConfiguration(bucket string, w http.ResponseWriter, r *http.Request) error {
+	g.log.Print(LogInfo, "PUT BUCKET ANALYTICS CONFIGURATION:", bucket, r.URL.Query().Get("id"))
+
+	var in AnalyticsConfiguration
+	return g.putIDConfig(bucket, bucketAnalyticsConfig, MaxAnalyticsConfigurations, r, &in,
+		func() string { return in.ID },
+		func() error {
+			if in.StorageClassAnalysis == nil {
+				return ErrMalformedXML
+			}
+			in.Xmlns = ""
+			return validateConfigurationFilter(in.Filter)
+		})
+}
+
+func (g *GoFakeS3) deleteBucketAnalyticsConfiguration(bucket string, w http.ResponseWriter, r *http.Request) error {
+	g.log.Print(LogInfo, "DELETE BUCKET ANALYTICS CONFIGURATION:", bucket, r.URL.Query().Get("id"))
+	return g.deleteIDConfig(bucket, bucketAnalyticsConfig, w, r)
+}
@@ -0,0 +1,120 @@
+package gofakes3_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/johannesboyne/gofakes3"
+)
+
+func TestBucketAnalyticsConfiguration(t *testing.T) {
+	ts := newTestServer(t)
+	defer ts.Close()
+	svc := ts.s3Client()
+
+	config := func(id string) *s3.AnalyticsConfiguration {
+		return &s3.AnalyticsConfiguration{
+			Id: aws.String(id),
+			Filter: &s3.AnalyticsFilter{
+				And: &s3.AnalyticsAndOperator{
+					Prefix: aws.String("logs/"),
+					Tags:   []*s3.Tag{{Key: aws.String("a"), Value: aws.String("1")}},
+				},
+			},
+			StorageClassAnalysis: &s3.StorageClassAnalysis{
+				DataExport: &s3.StorageClassAnalysisDataExport{
+					OutputSchemaVersion: aws.String(s3.StorageClassAnalysisSchemaVersionV1),
+					Destination: &s3.AnalyticsExportDestination{
+						S3BucketDestination: &s3.AnalyticsS3BucketDestination{
+							Bucket: aws.String("arn:aws:s3:::destination"),
+							Format: aws.String(s3.AnalyticsS3ExportFileFormatCsv),
+							Prefix: aws.String("out/"),
+						},
+					},
+				},
+			},
+		}
+	}
+
+	_, err := svc.GetBucketAnalyticsConfiguration(&s3.GetBucketAnalyticsConfigurationInput{
+		Bucket: aws.String(defaultBucket),
+		Id:     aws.String("missing"),
+	})
+	if !hasErrorCode(err, gofakes3.ErrNoSuchConfiguration) {
+		t.Fatal("expected ErrNoSuchConfiguration, found", err)
+	}
+
+	ts.OKAll(svc.PutBucketAnalyticsConfiguration(&s3.PutBucketAnalyticsConfigurationInput{
+		Bucket:                 aws.String(defaultBucket),
+		Id:                     aws.String("report"),
+		AnalyticsConfiguration: config("report"),
+	}))
+
+	rs, err := svc.GetBucketAnalyticsConfiguration(&s3.GetBucketAnalyticsConfigurationInput{
+		Bucket: aws.String(defaultBucket),
+		Id:     aws.String("report"),
+	})
+	ts.OK(err)
+	if rs.AnalyticsConfiguration.String() != config("report").String() {
+		t.Fatal("configuration mismatch:", rs.AnalyticsConfiguration)
+	}
+
+	t.Run("mismatched-id", func(t *testing.T) {
+		_, err := svc.PutBucketAnalyticsConfiguration(&s3.PutBucketAnalyticsConfigurationInput{
+			Bucket:                 aws.String(defaultBucket),
+			Id:                     aws.String("one"),
+			AnalyticsConfiguration: config("two"),
+		})
+		if !hasErrorCode(err, gofakes3.ErrInvalidArgument) {
+			t.Fatal("expected ErrInvalidArgument, found", err)
+		}
+	})
+
+	t.Run("list-pages", func(t *testing.T) {
+		for i := 0; i < 150; i++ {
+			id := fmt.Sprintf("page-%03d", i)
+			ts.OKAll(svc.PutBucketAnalyticsConfiguration(&s3.PutBucketAnalyticsConfigurationInput{
+				Bucket:                 aws.String(defaultBucket),
+				Id:                     aws.String(id),
+				AnalyticsConfiguration: config(id),
+			}))
+		}
+
+		var ids []string
+		var token *string
+		for pages := 0; ; pages++ {
+			rs, err := svc.ListBucketAnalyticsConfigurations(&s3.ListBucketAnalyticsConfigurationsInput{
+				Bucket:            aws.String(defaultBucket),
+				ContinuationToken: token,
+			})
+			ts.OK(err)
+			for _, config := range rs.AnalyticsConfigurationList {
+				ids = append(ids, aws.StringValue(config.Id))
+			}
+			if !aws.BoolValue(rs.IsTruncated) {
+				if pages != 1 {
+					t.Fatal("expected 2 pages, found", pages+1)
+				}
+				break
+			}
+			token = rs.NextContinuationToken
+		}
+		if len(ids) != 151 || ids[0] != "page-000" || ids[150] != "report" {
+			t.Fatal("unexpected ids", len(ids), ids[0], ids[len(ids)-1])
+		}
+	})
+
+	ts.OKAll(svc.DeleteBucketAnalyticsConfiguration(&s3.DeleteBucketAnalyticsConfigurationInput{
+		Bucket: aws.String(defaultBucket),
+		Id:     aws.String("report"),
+	}))
+	_, err = svc.DeleteBucketAnalyticsConfiguration(&s3.DeleteBucketAnalyticsConfigurationInput{
+		Bucket: aws.String(defaultBucket),
+		Id:     aws.String("report"),
+	})
+	if !hasErrorCode(err, gofakes3.ErrNoSuchConfiguration) {
+		t.Fatal("expected ErrNoSuchConfiguration, found", err)
+	}
+}
@@ -0,0 +1,90 @@
+package gofakes3
+
+import (
+	"encoding/hex"
+	"net/http"
+	"strings"
+)
+
+// ObjectAttributesHeader lists the attributes GetObjectAttributes returns,
+// separated by commas.
+const ObjectAttributesHeader = "X-Amz-Object-Attributes"
+
+// Attributes that can be requested with the ObjectAttributesHeader.
+// 'ObjectParts' is only returned for objects created by completing a
+// multipart upload.
+const (
+	ObjectAttributeETag         = "ETag"
+	ObjectAttributeChecksum     = "Checksum"
+	ObjectAttributeObjectParts  = "ObjectParts"
+	ObjectAttributeStorageClass = "StorageClass"
+	ObjectAttributeObjectSize   = "ObjectSize"
+)
+
+func (g *GoFakeS3) getObjectAttributes(bucket, object string, versionID VersionID, w http.ResponseWriter, r *http.Request) error {
+	g.log.Print(LogInfo, "GET OBJECT ATTRIBUTES:", bucket, object, versionID)
+
+	attributes := map[string]bool{}
+	for _, hdr := range r.Header.Values(ObjectAttributesHeader) {
+		for _, attr := range strings.Split(hdr, ",") {
+			attr = strings.TrimSpace(attr)
+			switch attr {
+			case ObjectAttributeETag, ObjectAttributeChecksum, ObjectAttributeObjectParts, ObjectAttributeStorageClass, ObjectAttributeObjectSize:
+				attributes[attr] = true
+			case "":
+			default:
+				return ErrorInvalidArgument(ObjectAttributesHeader, attr, "Invalid attribute name specified.")
+			}
+		}
+	}
+	if len(attributes) == 0 {
+		return ErrorInvalidArgument(ObjectAttributesHeader, "", "The x-amz-object-attributes header specifying the attributes to be retrieved is either missing or empty")
+	}
+
+	obj, err := g.headObjectVersion(bucket, object, versionID)
+	if err != nil {
+		return err
+	}
+	ssec, err := parseSSECustomerKey(r.Header, "")
+	if err != nil {
+		return err
+	}
+	if err := checkSSECustomerKey(obj.Metadata, ssec); err != nil {
+		return err
+	}
+
+	out := GetObjectAttributesResult{Xmlns: "http://s3.amazonaws.com/doc/2006-03-01/"}
+	if attributes[ObjectAttributeETag] {
+		out.ETag = hex.EncodeToString(obj.Hash)
+	}
+	if attributes[ObjectAttributeChecksum] {
+		if sum, ok := g.objectChecksum(bucket, object, obj); ok {
+			out.Checksum = sum.result()
+		}
+	}
+	if attributes[ObjectAttributeObjectParts] {
+		if parts, ok := g.objectParts(bucket, object, obj); ok {
+			if out.ObjectParts, err = getObjectAttributesParts(parts, r.Header); err != nil {
+				return err
+			}
+		}
+	}
+	if attributes[ObjectAttributeStorageClass] {
+		out.StorageClass = StorageStandard
+		if class := obj.Metadata["X-Amz-Storage-Class"]; class != "" {
+			out.StorageClass = StorageClass(class)
+		}
+	}
+	if attributes[ObjectAttributeObjectSize] {
+		size := obj.Size
+		out.ObjectSize = &size
+	}
+
+	if lastModified := obj.Metadata["Last-Modified"]; lastModified != "" {
+		w.Header().Set("Last-Modified", lastModified)
+	}
+	if obj.VersionID != "" {
+		w.Header().Set("x-amz-version-id", string(obj.VersionID))
+	}
+	return g.xmlEncoder(w).Encode(&out)
+}
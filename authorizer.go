@@ -0,0 +1,51 @@
+package gofakes3
+
+import (
+	"net/http"
+	"net/url"
+)
+
+// AnonymousPrincipal is passed to an Authorizer for requests that carry no
+// credentials. It can't be confused with an access key ID, which is made of
+// upper-case letters and digits.
+const AnonymousPrincipal = "anonymous"
+
+// Authorizer decides whether a request may go ahead, so that tests can
+// simulate access policies of their own, see WithAuthorizer.
+type Authorizer interface {
+	// Authorize is called before the operation is carried out. principal is
+	// the access key ID the request was signed with, or AnonymousPrincipal.
+	// action is the S3 API action name of the operation, such as 'GetObject'
+	// or 'PutBucketVersioning', or empty if the request does not match an
+	// operation. key is empty for requests that don't name an object, and
+	// bucket is empty for 'ListBuckets'.
+	//
+	// If an error is returned, the request fails with ErrAccessDenied.
+	// Authorize may be called concurrently.
+	Authorize(principal, action, bucket, key string) error
+}
+
+// AuthorizerFunc allows a function to be used as an Authorizer.
+type AuthorizerFunc func(principal, action, bucket, key string) error
+
+func (f AuthorizerFunc) Authorize(principal, action, bucket, key string) error {
+	return f(principal, action, bucket, key)
+}
+
+// authorize implements WithAuthorizer.
+func (g *GoFakeS3) authorize(bucket, object string, query url.Values, r *http.Request) error {
+	if g.authorizer == nil {
+		return nil
+	}
+
+	principal := requestAccessKey(r)
+	if principal == "" {
+		principal = AnonymousPrincipal
+	}
+	action := requestOperation(bucket, object, query, r)
+	if err := g.authorizer.Authorize(principal, action, bucket, object); err != nil {
+		g.log.Print(LogInfo, "ACCESS DENIED:", principal, action, bucket, object, err)
+		return ErrAccessDenied
+	}
+	return nil
+}
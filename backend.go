@@ -252,6 +252,112 @@ type MultipartBackend interface {
 	UploadPart(bucketName, key string, id UploadID, partNumber int, input io.Reader, size int64, added time.Time) (etag string, err error)
 }
 
+// FlushingBackend may be optionally implemented by a Backend that buffers
+// writes, so that GoFakeS3.Shutdown can ensure they have been persisted once
+// the last request has been handled.
+type FlushingBackend interface {
+	Flush() error
+}
+
+// IteratingBackend may be optionally implemented by a Backend that can list
+// the objects in a bucket lazily, such as by walking a sorted index, rather
+// than collecting the whole listing in memory.
+//
+// If a Backend's ListBucket returns ErrInternalPageNotImplemented, GoFakeS3
+// uses ListObjectsIter, if it is implemented, to page the listing itself,
+// reading no further than it needs to fill the page.
+type IteratingBackend interface {
+	// ListObjectsIter returns an iterator over the objects in the bucket
+	// whose keys start with prefix and sort after the key 'after', in
+	// lexical order of key. Either may be empty. Delete markers must not be
+	// yielded.
+	//
+	// ListObjectsIter must return a gofakes3.ErrNoSuchBucket error if the
+	// bucket does not exist. See gofakes3.BucketNotFound() for a convenient
+	// way to create one.
+	//
+	// The caller MUST call ObjectIterator.Close(), otherwise the Backend may
+	// leak resources.
+	ListObjectsIter(bucketName, prefix, after string) (ObjectIterator, error)
+}
+
+// ObjectIterator is returned by IteratingBackend.ListObjectsIter.
+type ObjectIterator interface {
+	// Next returns the next object, or io.EOF once there are no more.
+	Next() (*Content, error)
+	Close() error
+}
+
+// PagingBucketsBackend may be optionally implemented by a Backend that can
+// list its buckets a page at a time, such as one that queries a database,
+// rather than loading every bucket for each ListBuckets request.
+//
+// Without it, GoFakeS3 pages the result of Backend.ListBuckets itself.
+type PagingBucketsBackend interface {
+	// ListBucketsPage returns at most max buckets whose names start with
+	// prefix, which may be empty, in lexical order of name. If
+	// continuationToken is not empty, it is a nextToken returned by an
+	// earlier call, and the page continues from where that one ended.
+	//
+	// nextToken must be empty if there are no more buckets. It is opaque to
+	// GoFakeS3 and its clients; ListBucketsPage must return an
+	// ErrInvalidArgument error for a continuationToken it did not return.
+	ListBucketsPage(prefix, continuationToken string, max int64) (buckets []BucketInfo, nextToken string, err error)
+}
+
+// ConditionalPutBackend may be optionally implemented by a Backend that can
+// check the current object at a key and replace it atomically, for example
+// with a native compare-and-swap. GoFakeS3 uses it for PutObject requests
+// sent with 'If-Match' or 'If-None-Match: *'.
+//
+// Without it, conditional writes are still supported, but they are only
+// atomic with respect to other requests handled by the same GoFakeS3.
+type ConditionalPutBackend interface {
+	// PutObjectIfMatch must write the object exactly as PutObject would, but
+	// only if the current version of the object has the unquoted ETag
+	// expectedETag. If expectedETag is empty, the object must only be written
+	// if there is no current version, or it is a delete marker.
+	//
+	// If the condition does not hold, PutObjectIfMatch must return
+	// gofakes3.ErrPreconditionFailed, or a gofakes3.ErrNoSuchKey error if an
+	// ETag was expected but there is no object. See gofakes3.KeyNotFound()
+	// for a convenient way to create one.
+	PutObjectIfMatch(bucketName, key, expectedETag string, meta map[string]string, input io.Reader, size int64) (PutObjectResult, error)
+}
+
+// RangeReportingBackend may be optionally implemented by a Backend that
+// cannot always serve part of an object, such as one that can only stream
+// objects from the start.
+//
+// If SupportsRanges returns false, GoFakeS3 never passes a range to
+// GetObject or GetObjectVersion: the Range header is ignored and the whole
+// object is returned with 200 OK, and 'Accept-Ranges: bytes' is left out of
+// GET and HEAD responses, so that clients fall back to downloading whole
+// objects.
+type RangeReportingBackend interface {
+	SupportsRanges() bool
+}
+
+// MetadataUpdatingBackend may be optionally implemented by a Backend that
+// can replace an object's metadata without rewriting its contents. S3 only
+// allows metadata to be changed by copying an object onto itself, and
+// GoFakeS3 uses UpdateObjectMetadata for such copies instead of PutObject.
+type MetadataUpdatingBackend interface {
+	// UpdateObjectMetadata must replace the object's metadata with meta, and
+	// update its last modified time, exactly as PutObject would if it was
+	// passed the object's existing contents.
+	//
+	// If that would create a new version of the object, as it does once
+	// versioning has been enabled on the bucket, UpdateObjectMetadata must
+	// return gofakes3.ErrNotImplemented, and the contents are copied with
+	// PutObject instead.
+	//
+	// UpdateObjectMetadata must return a gofakes3.ErrNoSuchKey error if the
+	// object does not exist. See gofakes3.KeyNotFound() for a convenient way
+	// to create one.
+	UpdateObjectMetadata(bucketName, objectName string, meta map[string]string) error
+}
+
 // VersionedBackend may be optionally implemented by a Backend in order to support
 // operations on S3 object versions.
 //
@@ -24,7 +24,11 @@ type Backend struct {
 	metaBucketName []byte
 }
 
-var _ gofakes3.Backend = &Backend{}
+var (
+	_ gofakes3.Backend          = &Backend{}
+	_ gofakes3.FlushingBackend  = &Backend{}
+	_ gofakes3.IteratingBackend = &Backend{}
+)
 
 type Option func(b *Backend)
 
@@ -158,16 +162,9 @@ func (db *Backend) ListBucket(name string, prefix *gofakes3.Prefix, page gofakes
 				objects.AddPrefix(match.MatchedPart)
 
 			} else {
-				var b boltObject
-				err := bson.Unmarshal(v, &b)
+				item, err := boltContent(k, v)
 				if err != nil {
-					return fmt.Errorf("gofakes3: could not unmarshal object %q: %v", string(k[:]), err)
-				}
-				item := &gofakes3.Content{
-					Key:          string(k[:]),
-					ETag:         `"` + hex.EncodeToString(b.Hash[:]) + `"`,
-					Size:         b.Size,
-					LastModified: gofakes3.NewContentTime(b.LastModified.UTC()),
+					return err
 				}
 				objects.Add(item)
 			}
@@ -179,8 +176,77 @@ func (db *Backend) ListBucket(name string, prefix *gofakes3.Prefix, page gofakes
 	return objects, err
 }
 
+// ListObjectsIter walks the bucket with a cursor in a read-only transaction,
+// which is held open until the iterator is closed.
+func (db *Backend) ListObjectsIter(name, prefix, after string) (gofakes3.ObjectIterator, error) {
+	tx, err := db.bolt.Begin(false)
+	if err != nil {
+		return nil, err
+	}
+	b := tx.Bucket([]byte(name))
+	if b == nil {
+		tx.Rollback()
+		return nil, gofakes3.BucketNotFound(name)
+	}
+
+	iter := &objectIterator{tx: tx, cursor: b.Cursor(), prefix: []byte(prefix)}
+
+	seek := prefix
+	if after > seek {
+		seek = after
+	}
+	iter.k, iter.v = iter.cursor.Seek([]byte(seek))
+	if iter.k != nil && string(iter.k) == after {
+		iter.k, iter.v = iter.cursor.Next()
+	}
+	return iter, nil
+}
+
+type objectIterator struct {
+	tx     *bolt.Tx
+	cursor *bolt.Cursor
+	prefix []byte
+	k, v   []byte
+}
+
+func (iter *objectIterator) Next() (*gofakes3.Content, error) {
+	if iter.k == nil || !bytes.HasPrefix(iter.k, iter.prefix) {
+		return nil, io.EOF
+	}
+	item, err := boltContent(iter.k, iter.v)
+	if err != nil {
+		return nil, err
+	}
+	iter.k, iter.v = iter.cursor.Next()
+	return item, nil
+}
+
+func (iter *objectIterator) Close() error {
+	return iter.tx.Rollback()
+}
+
+func boltContent(k, v []byte) (*gofakes3.Content, error) {
+	var b boltObject
+	if err := bson.Unmarshal(v, &b); err != nil {
+		return nil, fmt.Errorf("gofakes3: could not unmarshal object %q: %v", string(k[:]), err)
+	}
+	return &gofakes3.Content{
+		Key:          string(k[:]),
+		ETag:         `"` + hex.EncodeToString(b.Hash[:]) + `"`,
+		Size:         b.Size,
+		LastModified: gofakes3.NewContentTime(b.LastModified.UTC()),
+	}, nil
+}
+
 func (db *Backend) CreateBucket(name string) error {
 	return db.bolt.Update(func(tx *bolt.Tx) error {
+		// The existing bucket's metadata must be left alone, so this is
+		// checked first:
+		nameBts := []byte(name)
+		if tx.Bucket(nameBts) != nil {
+			return gofakes3.ResourceError(gofakes3.ErrBucketAlreadyExists, name)
+		}
+
 		{ // create bucket metadata
 			metaBucket, err := db.metaBucket(tx)
 			if err != nil {
@@ -192,10 +258,6 @@ func (db *Backend) CreateBucket(name string) error {
 		}
 
 		{ // create bucket
-			nameBts := []byte(name)
-			if tx.Bucket(nameBts) != nil {
-				return gofakes3.ResourceError(gofakes3.ErrBucketAlreadyExists, name)
-			}
 			if _, err := tx.CreateBucket(nameBts); err != nil {
 				return err
 			}
@@ -252,19 +314,42 @@ func (db *Backend) BucketExists(name string) (exists bool, err error) {
 	return exists, err
 }
 
+// Flush syncs the database file to disk. It is called by GoFakeS3.Shutdown
+// and is only needed if the database was opened with NoSync.
+func (db *Backend) Flush() error {
+	return db.bolt.Sync()
+}
+
 func (db *Backend) HeadObject(bucketName, objectName string) (*gofakes3.Object, error) {
-	obj, err := db.GetObject(bucketName, objectName, nil)
-	if err != nil {
+	// Decoding into boltObjectHead skips the Contents, so HEAD doesn't copy
+	// the body out of the database:
+	var t boltObjectHead
+	if err := db.loadObject(bucketName, objectName, &t); err != nil {
 		return nil, err
 	}
-	obj.Contents = s3io.NoOpReadCloser{}
-	return obj, nil
+	return &gofakes3.Object{
+		Name:     objectName,
+		Metadata: t.Metadata,
+		Size:     t.Size,
+		Contents: s3io.NoOpReadCloser{},
+		Hash:     t.Hash,
+	}, nil
 }
 
 func (db *Backend) GetObject(bucketName, objectName string, rangeRequest *gofakes3.ObjectRangeRequest) (*gofakes3.Object, error) {
 	var t boltObject
+	if err := db.loadObject(bucketName, objectName, &t); err != nil {
+		return nil, err
+	}
 
-	err := db.bolt.View(func(tx *bolt.Tx) error {
+	// FIXME: objectName here is a bit of a hack; this can be cleaned up when we have a
+	// database migration script.
+	return t.Object(objectName, rangeRequest)
+}
+
+// loadObject decodes the stored object into a boltObject or boltObjectHead.
+func (db *Backend) loadObject(bucketName, objectName string, into interface{}) error {
+	return db.bolt.View(func(tx *bolt.Tx) error {
 		b := tx.Bucket([]byte(bucketName))
 		if b == nil {
 			return gofakes3.BucketNotFound(bucketName)
@@ -275,20 +360,12 @@ func (db *Backend) GetObject(bucketName, objectName string, rangeRequest *gofake
 			return gofakes3.KeyNotFound(objectName)
 		}
 
-		if err := bson.Unmarshal(v, &t); err != nil {
+		if err := bson.Unmarshal(v, into); err != nil {
 			return fmt.Errorf("gofakes3: could not unmarshal object at %q/%q: %v", bucketName, objectName, err)
 		}
 
 		return nil
 	})
-
-	if err != nil {
-		return nil, err
-	}
-
-	// FIXME: objectName here is a bit of a hack; this can be cleaned up when we have a
-	// database migration script.
-	return t.Object(objectName, rangeRequest)
 }
 
 func (db *Backend) PutObject(
@@ -29,6 +29,15 @@ type boltObject struct {
 	Hash         []byte
 }
 
+// boltObjectHead decodes the same document as boltObject, less the Contents.
+type boltObjectHead struct {
+	Name         string
+	Metadata     map[string]string
+	LastModified time.Time
+	Size         int64
+	Hash         []byte
+}
+
 func (b *boltObject) Object(objectName string, rangeRequest *gofakes3.ObjectRangeRequest) (*gofakes3.Object, error) {
 	data := b.Contents
 
@@ -45,7 +54,7 @@ func (b *boltObject) Object(objectName string, rangeRequest *gofakes3.ObjectRang
 		Name:     objectName,
 		Metadata: b.Metadata,
 		Size:     b.Size,
-		Contents: s3io.ReaderWithDummyCloser{bytes.NewReader(data)},
+		Contents: s3io.ReaderWithDummyCloser{Reader: bytes.NewReader(data)},
 		Range:    rnge,
 		Hash:     b.Hash,
 	}, nil
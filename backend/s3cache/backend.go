@@ -0,0 +1,428 @@
+// Package s3cache provides a gofakes3.Backend that keeps recently used objects
+// in memory and spills the rest to another Backend, usually one that stores
+// them on disk, like s3afero or s3bolt.
+package s3cache
+
+import (
+	"bytes"
+	"container/list"
+	"io"
+	"io/ioutil"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/johannesboyne/gofakes3"
+	"github.com/johannesboyne/gofakes3/backend/s3mem"
+)
+
+// Backend is a gofakes3.Backend that keeps the most recently used objects in
+// an s3mem.Backend, up to a limit on their total size in bytes. When a write
+// takes the size over the limit, the least recently used objects are evicted
+// to the cold Backend. Reading an evicted object with GetObject promotes it
+// back to memory; HeadObject and listings do not.
+//
+// The cold Backend is the source of truth for which buckets exist. Each
+// object is stored in exactly one of the two backends at a time.
+//
+// Only the Backend interface is implemented; versioning is not supported.
+// Operations are serialised by a single lock, which keeps moving objects
+// between the two backends simple at the expense of concurrency.
+type Backend struct {
+	hot      *s3mem.Backend
+	cold     gofakes3.Backend
+	capacity int64
+
+	// lru holds a *cacheEntry for every object in hot, the most recently
+	// used at the front. entries indexes the elements of lru.
+	lru     *list.List
+	entries map[cacheKey]*list.Element
+	size    int64
+
+	// modified holds the original modification time of each object that has
+	// been moved between the backends, as writing it to the other backend
+	// resets it there.
+	modified map[cacheKey]time.Time
+
+	mu sync.Mutex
+}
+
+var _ gofakes3.Backend = &Backend{}
+
+type cacheKey struct {
+	bucket, object string
+}
+
+type cacheEntry struct {
+	key  cacheKey
+	size int64
+}
+
+type Option func(b *Backend)
+
+// WithTimeSource sets the TimeSource used by the in-memory backend.
+func WithTimeSource(timeSource gofakes3.TimeSource) Option {
+	return func(b *Backend) { b.hot = s3mem.New(s3mem.WithTimeSource(timeSource)) }
+}
+
+// New creates a Backend that keeps up to capacity bytes of objects in memory
+// and evicts the rest to cold. Objects larger than capacity are never held
+// in memory.
+func New(cold gofakes3.Backend, capacity int64, opts ...Option) *Backend {
+	b := &Backend{
+		cold:     cold,
+		capacity: capacity,
+		lru:      list.New(),
+		entries:  make(map[cacheKey]*list.Element),
+		modified: make(map[cacheKey]time.Time),
+	}
+	for _, opt := range opts {
+		opt(b)
+	}
+	if b.hot == nil {
+		b.hot = s3mem.New()
+	}
+	return b
+}
+
+// Size returns the total size in bytes of the objects held in memory.
+func (b *Backend) Size() int64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.size
+}
+
+func (b *Backend) ListBuckets() ([]gofakes3.BucketInfo, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.cold.ListBuckets()
+}
+
+// ListBucket merges the listings of both backends. Merging pages is not
+// supported, so GoFakeS3 falls back to listing everything and paging the
+// result itself.
+func (b *Backend) ListBucket(name string, prefix *gofakes3.Prefix, page gofakes3.ListBucketPage) (*gofakes3.ObjectList, error) {
+	if !page.IsEmpty() {
+		return nil, gofakes3.ErrInternalPageNotImplemented
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	cold, err := b.cold.ListBucket(name, prefix, page)
+	if err != nil {
+		return nil, err
+	}
+	if ok, err := b.hot.BucketExists(name); err != nil || !ok {
+		return cold, err
+	}
+	hot, err := b.hot.ListBucket(name, prefix, page)
+	if err != nil {
+		return nil, err
+	}
+
+	result := gofakes3.NewObjectList()
+	result.Contents = append(cold.Contents, hot.Contents...)
+	for _, item := range result.Contents {
+		if at, ok := b.modified[cacheKey{name, item.Key}]; ok {
+			item.LastModified = gofakes3.NewContentTime(at)
+		}
+	}
+	sort.Slice(result.Contents, func(i, j int) bool {
+		return result.Contents[i].Key < result.Contents[j].Key
+	})
+
+	var prefixes []string
+	for _, list := range []*gofakes3.ObjectList{cold, hot} {
+		for _, cp := range list.CommonPrefixes {
+			prefixes = append(prefixes, cp.Prefix)
+		}
+	}
+	sort.Strings(prefixes)
+	for _, p := range prefixes {
+		result.AddPrefix(p)
+	}
+
+	return result, nil
+}
+
+func (b *Backend) CreateBucket(name string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if err := b.cold.CreateBucket(name); err != nil {
+		return err
+	}
+	return b.ensureHotBucket(name)
+}
+
+func (b *Backend) BucketExists(name string) (exists bool, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.cold.BucketExists(name)
+}
+
+func (b *Backend) DeleteBucket(name string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	hotExists, err := b.hot.BucketExists(name)
+	if err != nil {
+		return err
+	}
+	if hotExists {
+		hot, err := b.hot.ListBucket(name, nil, gofakes3.ListBucketPage{})
+		if err != nil {
+			return err
+		}
+		if len(hot.Contents) > 0 {
+			return gofakes3.ResourceError(gofakes3.ErrBucketNotEmpty, name)
+		}
+	}
+
+	if err := b.cold.DeleteBucket(name); err != nil {
+		return err
+	}
+	if hotExists {
+		return b.hot.DeleteBucket(name)
+	}
+	return nil
+}
+
+// GetObject returns the object from memory if it is there. Otherwise the
+// object is moved from the cold Backend into memory first, unless it is too
+// large to fit.
+func (b *Backend) GetObject(bucketName, objectName string, rangeRequest *gofakes3.ObjectRangeRequest) (*gofakes3.Object, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	key := cacheKey{bucketName, objectName}
+	if el, ok := b.entries[key]; ok {
+		b.lru.MoveToFront(el)
+		return b.hot.GetObject(bucketName, objectName, rangeRequest)
+	}
+
+	head, err := b.cold.HeadObject(bucketName, objectName)
+	if err != nil {
+		return nil, err
+	}
+	if head.Size > b.capacity {
+		return b.cold.GetObject(bucketName, objectName, rangeRequest)
+	}
+
+	if err := b.promote(bucketName, objectName); err != nil {
+		return nil, err
+	}
+	return b.hot.GetObject(bucketName, objectName, rangeRequest)
+}
+
+func (b *Backend) HeadObject(bucketName, objectName string) (*gofakes3.Object, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if _, ok := b.entries[cacheKey{bucketName, objectName}]; ok {
+		return b.hot.HeadObject(bucketName, objectName)
+	}
+	return b.cold.HeadObject(bucketName, objectName)
+}
+
+func (b *Backend) DeleteObject(bucketName, objectName string) (result gofakes3.ObjectDeleteResult, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	key := cacheKey{bucketName, objectName}
+	delete(b.modified, key)
+	if _, ok := b.entries[key]; ok {
+		b.forget(key)
+		return b.hot.DeleteObject(bucketName, objectName)
+	}
+	return b.cold.DeleteObject(bucketName, objectName)
+}
+
+// PutObject writes to memory, evicting other objects to the cold Backend if
+// that takes the total size over capacity. Objects larger than capacity are
+// written straight to the cold Backend instead.
+func (b *Backend) PutObject(bucketName, objectName string, meta map[string]string, input io.Reader, size int64) (result gofakes3.PutObjectResult, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if exists, err := b.cold.BucketExists(bucketName); err != nil {
+		return result, err
+	} else if !exists {
+		return result, gofakes3.BucketNotFound(bucketName)
+	}
+
+	key := cacheKey{bucketName, objectName}
+	delete(b.modified, key)
+	if size > b.capacity {
+		if _, ok := b.entries[key]; ok {
+			b.forget(key)
+			if _, err := b.hot.DeleteObject(bucketName, objectName); err != nil {
+				return result, err
+			}
+		}
+		return b.cold.PutObject(bucketName, objectName, meta, input, size)
+	}
+
+	if err := b.ensureHotBucket(bucketName); err != nil {
+		return result, err
+	}
+	result, err = b.hot.PutObject(bucketName, objectName, meta, input, size)
+	if err != nil {
+		return result, err
+	}
+
+	// The previous version of the object, if there was one, may be in the
+	// cold Backend, where it must not be found again by a later GetObject:
+	if _, ok := b.entries[key]; ok {
+		b.forget(key)
+	} else if err := b.deleteCold(bucketName, objectName); err != nil {
+		return result, err
+	}
+
+	b.remember(key, size)
+	return result, b.evict()
+}
+
+func (b *Backend) DeleteMulti(bucketName string, objects ...string) (result gofakes3.MultiDeleteResult, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var hot, cold []string
+	for _, object := range objects {
+		key := cacheKey{bucketName, object}
+		delete(b.modified, key)
+		if b.entries[key] != nil {
+			b.forget(key)
+			hot = append(hot, object)
+		} else {
+			cold = append(cold, object)
+		}
+	}
+
+	for _, batch := range []struct {
+		backend gofakes3.Backend
+		objects []string
+	}{
+		{b.hot, hot},
+		{b.cold, cold},
+	} {
+		if len(batch.objects) == 0 {
+			continue
+		}
+		deleted, err := batch.backend.DeleteMulti(bucketName, batch.objects...)
+		if err != nil {
+			return result, err
+		}
+		result.Deleted = append(result.Deleted, deleted.Deleted...)
+		result.Error = append(result.Error, deleted.Error...)
+	}
+	return result, nil
+}
+
+func (b *Backend) ensureHotBucket(name string) error {
+	if exists, err := b.hot.BucketExists(name); err != nil || exists {
+		return err
+	}
+	return b.hot.CreateBucket(name)
+}
+
+func (b *Backend) remember(key cacheKey, size int64) {
+	b.entries[key] = b.lru.PushFront(&cacheEntry{key: key, size: size})
+	b.size += size
+}
+
+func (b *Backend) forget(key cacheKey) {
+	el := b.entries[key]
+	b.size -= el.Value.(*cacheEntry).size
+	b.lru.Remove(el)
+	delete(b.entries, key)
+}
+
+// deleteCold removes an object from the cold Backend, if it is there.
+func (b *Backend) deleteCold(bucketName, objectName string) error {
+	if _, err := b.cold.HeadObject(bucketName, objectName); gofakes3.HasErrorCode(err, gofakes3.ErrNoSuchKey) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	_, err := b.cold.DeleteObject(bucketName, objectName)
+	return err
+}
+
+// move copies an object from one backend to the other, then deletes it from
+// the first, or the copy if that fails. The time the object was last modified is kept in b.modified the
+// first time it is moved, so that listings still report it afterwards.
+func (b *Backend) move(from, to gofakes3.Backend, bucketName, objectName string) (size int64, err error) {
+	key := cacheKey{bucketName, objectName}
+	if _, ok := b.modified[key]; !ok {
+		at, err := lastModified(from, bucketName, objectName)
+		if err != nil {
+			return 0, err
+		}
+		b.modified[key] = at
+	}
+
+	obj, err := from.GetObject(bucketName, objectName, nil)
+	if err != nil {
+		return 0, err
+	}
+	body, err := ioutil.ReadAll(obj.Contents)
+	obj.Contents.Close()
+	if err != nil {
+		return 0, err
+	}
+
+	if _, err := to.PutObject(bucketName, objectName, obj.Metadata, bytes.NewReader(body), int64(len(body))); err != nil {
+		return 0, err
+	}
+	if _, err := from.DeleteObject(bucketName, objectName); err != nil {
+		// Leave the object where it was, rather than in both backends:
+		to.DeleteObject(bucketName, objectName)
+		return 0, err
+	}
+	return int64(len(body)), nil
+}
+
+// lastModified finds the time an object was last modified in the listing of
+// a backend, as the Backend interface has no other way to get it.
+func lastModified(backend gofakes3.Backend, bucketName, objectName string) (time.Time, error) {
+	prefix := gofakes3.NewPrefix(&objectName, nil)
+	list, err := backend.ListBucket(bucketName, &prefix, gofakes3.ListBucketPage{})
+	if err != nil {
+		return time.Time{}, err
+	}
+	for _, item := range list.Contents {
+		if item.Key == objectName {
+			return item.LastModified.Time, nil
+		}
+	}
+	return time.Time{}, gofakes3.KeyNotFound(objectName)
+}
+
+// promote moves an object from the cold Backend into memory, evicting others
+// to make room for it.
+func (b *Backend) promote(bucketName, objectName string) error {
+	if err := b.ensureHotBucket(bucketName); err != nil {
+		return err
+	}
+	size, err := b.move(b.cold, b.hot, bucketName, objectName)
+	if err != nil {
+		return err
+	}
+	b.remember(cacheKey{bucketName, objectName}, size)
+	return b.evict()
+}
+
+// evict moves the least recently used objects to the cold Backend until the
+// objects in memory fit within capacity.
+func (b *Backend) evict() error {
+	for b.size > b.capacity {
+		entry := b.lru.Back().Value.(*cacheEntry)
+		if _, err := b.move(b.hot, b.cold, entry.key.bucket, entry.key.object); err != nil {
+			return err
+		}
+		b.forget(entry.key)
+	}
+	return nil
+}
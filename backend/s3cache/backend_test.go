@@ -0,0 +1,208 @@
+package s3cache
+
+import (
+	"io/ioutil"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/johannesboyne/gofakes3"
+	"github.com/johannesboyne/gofakes3/backend/s3mem"
+)
+
+func TestEviction(t *testing.T) {
+	cold := s3mem.New()
+	db := New(cold, 10)
+	if err := db.CreateBucket("bucket"); err != nil {
+		t.Fatal(err)
+	}
+
+	put := func(key, body string) {
+		t.Helper()
+		if _, err := db.PutObject("bucket", key, map[string]string{}, strings.NewReader(body), int64(len(body))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	get := func(key string) string {
+		t.Helper()
+		obj, err := db.GetObject("bucket", key, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer obj.Contents.Close()
+		body, err := ioutil.ReadAll(obj.Contents)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(body)
+	}
+	assertCold := func(key string, expected bool) {
+		t.Helper()
+		_, err := cold.HeadObject("bucket", key)
+		if found := err == nil; found != expected {
+			t.Fatalf("expected %q in the cold backend: %v, found %v (%v)", key, expected, found, err)
+		}
+	}
+
+	put("a", "aaaaaa")
+	put("b", "bbbbbb")
+	if db.Size() != 6 {
+		t.Fatal("unexpected size", db.Size())
+	}
+	assertCold("a", true)
+	assertCold("b", false)
+
+	// Reading the evicted object promotes it and evicts the other:
+	if body := get("a"); body != "aaaaaa" {
+		t.Fatal("unexpected body", body)
+	}
+	assertCold("a", false)
+	assertCold("b", true)
+
+	// Objects that do not fit skip memory entirely:
+	put("big", "0123456789abcdef")
+	assertCold("big", true)
+	if body := get("big"); body != "0123456789abcdef" {
+		t.Fatal("unexpected body", body)
+	}
+	assertCold("big", true)
+
+	list, err := db.ListBucket("bucket", nil, gofakes3.ListBucketPage{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var keys []string
+	for _, item := range list.Contents {
+		keys = append(keys, item.Key)
+	}
+	if !reflect.DeepEqual(keys, []string{"a", "b", "big"}) {
+		t.Fatal("unexpected keys", keys)
+	}
+
+	// Overwriting an evicted object must not leave the old copy behind:
+	put("b", "new")
+	assertCold("b", false)
+	if body := get("b"); body != "new" {
+		t.Fatal("unexpected body", body)
+	}
+
+	result, err := db.DeleteMulti("bucket", "a", "b", "big")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Deleted) != 3 || db.Size() != 0 {
+		t.Fatal("unexpected delete result", result, db.Size())
+	}
+	if err := db.DeleteBucket("bucket"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMoveKeepsLastModified(t *testing.T) {
+	clock := gofakes3.FixedTimeSource(time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC))
+	cold := s3mem.New(s3mem.WithTimeSource(clock))
+	db := New(cold, 10, WithTimeSource(clock))
+	if err := db.CreateBucket("bucket"); err != nil {
+		t.Fatal(err)
+	}
+
+	put := func(key, body string) {
+		t.Helper()
+		if _, err := db.PutObject("bucket", key, map[string]string{}, strings.NewReader(body), int64(len(body))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	assertListed := func(key string, modified time.Time, etag string) {
+		t.Helper()
+		list, err := db.ListBucket("bucket", nil, gofakes3.ListBucketPage{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, item := range list.Contents {
+			if item.Key != key {
+				continue
+			}
+			if !item.LastModified.Equal(modified) {
+				t.Fatalf("expected %q to be modified at %s, found %s", key, modified, item.LastModified.Time)
+			}
+			if item.ETag != etag {
+				t.Fatalf("expected %q to have ETag %s, found %s", key, etag, item.ETag)
+			}
+			return
+		}
+		t.Fatalf("%q not listed", key)
+	}
+
+	put("a", "aaaaaa")
+	created := clock.Now()
+	list, err := db.ListBucket("bucket", nil, gofakes3.ListBucketPage{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	etag := list.Contents[0].ETag
+
+	// Evicting "a":
+	clock.Advance(time.Hour)
+	put("c", "cccccc")
+	if _, err := cold.HeadObject("bucket", "a"); err != nil {
+		t.Fatal("expected a to be evicted:", err)
+	}
+	assertListed("a", created, etag)
+
+	// Promoting it again:
+	clock.Advance(time.Hour)
+	obj, err := db.GetObject("bucket", "a", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	obj.Contents.Close()
+	if _, err := cold.HeadObject("bucket", "a"); !gofakes3.HasErrorCode(err, gofakes3.ErrNoSuchKey) {
+		t.Fatal("expected a to be promoted:", err)
+	}
+	assertListed("a", created, etag)
+
+	// Writing it again resets the time:
+	clock.Advance(time.Hour)
+	put("a", "aaaaaa")
+	assertListed("a", clock.Now(), etag)
+}
+
+type failingDeleteBackend struct {
+	gofakes3.Backend
+}
+
+func (b failingDeleteBackend) DeleteObject(bucketName, objectName string) (gofakes3.ObjectDeleteResult, error) {
+	return gofakes3.ObjectDeleteResult{}, gofakes3.ErrInternal
+}
+
+func TestMoveFailureKeepsOneCopy(t *testing.T) {
+	cold := s3mem.New()
+	db := New(failingDeleteBackend{cold}, 10)
+	if err := db.CreateBucket("bucket"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cold.PutObject("bucket", "a", map[string]string{}, strings.NewReader("aaaaaa"), 6); err != nil {
+		t.Fatal(err)
+	}
+
+	// Promoting "a" cannot delete it from the cold backend:
+	if _, err := db.GetObject("bucket", "a", nil); err == nil {
+		t.Fatal("expected promotion to fail")
+	}
+	if _, err := db.hot.HeadObject("bucket", "a"); !gofakes3.HasErrorCode(err, gofakes3.ErrNoSuchKey) {
+		t.Fatal("expected a to be removed from memory:", err)
+	}
+
+	list, err := db.ListBucket("bucket", nil, gofakes3.ListBucketPage{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var keys []string
+	for _, item := range list.Contents {
+		keys = append(keys, item.Key)
+	}
+	if !reflect.DeepEqual(keys, []string{"a"}) {
+		t.Fatal("unexpected keys", keys)
+	}
+}
@@ -2,8 +2,11 @@ package s3mem
 
 import (
 	"crypto/md5"
+	"encoding/base64"
 	"encoding/hex"
 	"io"
+	"sort"
+	"strings"
 	"sync"
 
 	"github.com/johannesboyne/gofakes3"
@@ -15,6 +18,14 @@ var (
 	emptyVersionsPage = &gofakes3.ListBucketVersionsPage{}
 )
 
+// Backend is an in-memory gofakes3.Backend.
+//
+// Locking is split in two levels: lock guards the buckets map itself, and
+// each bucket carries its own lock guarding its contents. Operations on a
+// bucket hold the backend's read lock for their whole duration; only
+// CreateBucket and DeleteBucket take the write lock. This allows operations
+// on different buckets to proceed in parallel without a bucket disappearing
+// from underneath an in-flight request.
 type Backend struct {
 	buckets          map[string]*bucket
 	timeSource       gofakes3.TimeSource
@@ -22,11 +33,13 @@ type Backend struct {
 	versionSeed      int64
 	versionSeedSet   bool
 	versionScratch   []byte
+	versionLock      sync.Mutex
 	lock             sync.RWMutex
 }
 
 var _ gofakes3.Backend = &Backend{}
 var _ gofakes3.VersionedBackend = &Backend{}
+var _ gofakes3.PagingBucketsBackend = &Backend{}
 
 type Option func(b *Backend)
 
@@ -73,6 +86,42 @@ func (db *Backend) ListBuckets() ([]gofakes3.BucketInfo, error) {
 	return buckets, nil
 }
 
+// ListBucketsPage implements gofakes3.PagingBucketsBackend. The token is the
+// name of the last bucket in the page, base64 encoded.
+func (db *Backend) ListBucketsPage(prefix, continuationToken string, max int64) (buckets []gofakes3.BucketInfo, nextToken string, err error) {
+	after, err := base64.URLEncoding.DecodeString(continuationToken)
+	if err != nil {
+		return nil, "", gofakes3.ErrorInvalidArgument("continuation-token", continuationToken, "The continuation token provided is incorrect")
+	}
+
+	db.lock.RLock()
+	defer db.lock.RUnlock()
+
+	names := make([]string, 0, len(db.buckets))
+	for name := range db.buckets {
+		if name > string(after) && strings.HasPrefix(name, prefix) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	if int64(len(names)) > max {
+		names = names[:max]
+		nextToken = base64.URLEncoding.EncodeToString([]byte(names[max-1]))
+	}
+	for _, name := range names {
+		buckets = append(buckets, gofakes3.BucketInfo{
+			Name:         name,
+			CreationDate: db.buckets[name].creationDate,
+		})
+	}
+	return buckets, nextToken, nil
+}
+
+// ListBucket reads each page under the bucket's lock, and resumes from the
+// first key after the page's Marker rather than from a position in the
+// previous page, so that paging through a bucket while it is written to never
+// lists a key twice, or skips one that existed throughout.
 func (db *Backend) ListBucket(name string, prefix *gofakes3.Prefix, page gofakes3.ListBucketPage) (*gofakes3.ObjectList, error) {
 	if prefix == nil {
 		prefix = emptyPrefix
@@ -86,30 +135,90 @@ func (db *Backend) ListBucket(name string, prefix *gofakes3.Prefix, page gofakes
 		return nil, gofakes3.BucketNotFound(name)
 	}
 
+	storedBucket.mu.RLock()
+	defer storedBucket.mu.RUnlock()
+
 	var response = gofakes3.NewObjectList()
 	var iter = goskipiter.New(storedBucket.objects.Iterator())
 	var match gofakes3.PrefixMatch
 
-	if page.Marker != "" {
-		iter.Seek(page.Marker)
-		iter.Next() // Move to the next item after the Marker
+	// Objects are kept sorted by key, so rather than scanning the whole
+	// bucket, listing seeks straight to the first key that could match the
+	// prefix, and stops at the first key past it:
+	var scanPrefix string
+	if prefix.HasPrefix {
+		scanPrefix = prefix.Prefix
 	}
 
-	var cnt int64 = 0
+	seek := scanPrefix
+	if page.Marker > seek {
+		seek = page.Marker
+	}
+	if seek != "" {
+		// Seek lands on the first key >= seek, but listing starts strictly
+		// after the Marker, so only skip the item if it is the Marker itself:
+		if iter.Seek(seek) && iter.Key().(string) == page.Marker {
+			iter.Next()
+		}
+	}
 
+	// next advances iter to the next object that belongs in the listing,
+	// skipping over the rest of the keys in lastMatchedPart if it is a
+	// common prefix.
 	var lastMatchedPart string
 
-	for iter.Next() {
-		item := iter.Value().(*bucketObject)
+	// If the previous page ended on a common prefix, the Marker is that
+	// prefix, and the keys it rolled up must not be listed again. Prefix.Match
+	// treats the Marker as a key in its own right, so it is a common prefix if
+	// it ends with the delimiter and matches up to its end:
+	if prefix.HasDelimiter && prefix.Delimiter != "" && page.Marker != prefix.Prefix && strings.HasSuffix(page.Marker, prefix.Delimiter) &&
+		prefix.Match(page.Marker, &match) && match.MatchedPart == page.Marker {
+		lastMatchedPart = page.Marker
+	}
+	next := func() (item *bucketObject, ok bool) {
+		for iter.Next() {
+			key := iter.Key().(string)
+			if !strings.HasPrefix(key, scanPrefix) {
+				return nil, false
+			}
 
-		if !prefix.Match(item.data.name, &match) {
-			continue
-		} else if item.data.deleteMarker {
-			continue
-		} else if match.CommonPrefix {
-			if match.MatchedPart == lastMatchedPart {
+			if lastMatchedPart != "" && strings.HasPrefix(key, lastMatchedPart) {
+				// Every key in a common prefix after the first is adjacent to
+				// it, so they can all be skipped with a single seek. Seeking
+				// an existing skiplist iterator forward walks from its
+				// current node, so a fresh iterator, which seeks from the
+				// head of the list, is faster:
+				end, ok := prefixEnd(lastMatchedPart)
+				if !ok {
+					return nil, false
+				}
+				iter = goskipiter.New(storedBucket.objects.Iterator())
+				if !iter.Seek(end) {
+					return nil, false
+				}
+				continue
+			}
+
+			item := iter.Value().(*bucketObject)
+			if !prefix.Match(item.data.name, &match) || item.data.deleteMarker {
+				continue
+			} else if match.CommonPrefix && match.MatchedPart == lastMatchedPart {
 				continue // Should not count towards keys
 			}
+			return item, true
+		}
+		return nil, false
+	}
+
+	var cnt int64 = 0
+
+	for {
+		item, ok := next()
+		if !ok {
+			break
+		}
+
+		if match.CommonPrefix {
 			response.AddPrefix(match.MatchedPart)
 			lastMatchedPart = match.MatchedPart
 
@@ -124,8 +233,15 @@ func (db *Backend) ListBucket(name string, prefix *gofakes3.Prefix, page gofakes
 
 		cnt++
 		if page.MaxKeys > 0 && cnt >= page.MaxKeys {
-			response.NextMarker = item.data.name
-			response.IsTruncated = iter.Next()
+			// The next page resumes after the last entry in this one, which
+			// is the common prefix itself if the page ended on one. This
+			// must be read before calling next(), which overwrites match:
+			if match.CommonPrefix {
+				response.NextMarker = match.MatchedPart
+			} else {
+				response.NextMarker = item.data.name
+			}
+			_, response.IsTruncated = next()
 			break
 		}
 	}
@@ -133,6 +249,20 @@ func (db *Backend) ListBucket(name string, prefix *gofakes3.Prefix, page gofakes
 	return response, nil
 }
 
+// prefixEnd returns the smallest key that sorts after every key starting
+// with prefix. ok is false if there is no such key, i.e. if prefix consists
+// only of 0xff bytes.
+func prefixEnd(prefix string) (end string, ok bool) {
+	b := []byte(prefix)
+	for i := len(b) - 1; i >= 0; i-- {
+		if b[i] < 0xff {
+			b[i]++
+			return string(b[:i+1]), true
+		}
+	}
+	return "", false
+}
+
 func (db *Backend) CreateBucket(name string) error {
 	db.lock.Lock()
 	defer db.lock.Unlock()
@@ -149,11 +279,14 @@ func (db *Backend) DeleteBucket(name string) error {
 	db.lock.Lock()
 	defer db.lock.Unlock()
 
-	if db.buckets[name] == nil {
+	bucket := db.buckets[name]
+	if bucket == nil {
 		return gofakes3.ErrNoSuchBucket
 	}
 
-	if db.buckets[name].objects.Len() > 0 {
+	// The backend's write lock excludes every other bucket operation, so
+	// the bucket's own lock is not required here.
+	if bucket.objects.Len() > 0 {
 		return gofakes3.ResourceError(gofakes3.ErrBucketNotEmpty, name)
 	}
 
@@ -177,12 +310,24 @@ func (db *Backend) HeadObject(bucketName, objectName string) (*gofakes3.Object,
 		return nil, gofakes3.BucketNotFound(bucketName)
 	}
 
+	bucket.mu.RLock()
+	defer bucket.mu.RUnlock()
+
 	obj := bucket.object(objectName)
 	if obj == nil || obj.data.deleteMarker {
 		return nil, gofakes3.KeyNotFound(objectName)
 	}
 
-	return obj.data.toObject(nil, false)
+	result, err := obj.data.toObject(nil, false)
+	if err != nil {
+		return nil, err
+	}
+
+	if bucket.versioning != gofakes3.VersioningEnabled {
+		result.VersionID = ""
+	}
+
+	return result, nil
 }
 
 func (db *Backend) GetObject(bucketName, objectName string, rangeRequest *gofakes3.ObjectRangeRequest) (*gofakes3.Object, error) {
@@ -194,14 +339,14 @@ func (db *Backend) GetObject(bucketName, objectName string, rangeRequest *gofake
 		return nil, gofakes3.BucketNotFound(bucketName)
 	}
 
+	bucket.mu.RLock()
+	defer bucket.mu.RUnlock()
+
 	obj := bucket.object(objectName)
 	if obj == nil || obj.data.deleteMarker {
-		// FIXME: If the current version of the object is a delete marker,
-		// Amazon S3 behaves as if the object was deleted and includes
-		// x-amz-delete-marker: true in the response.
-		//
-		// The solution may be to return an object but no error if the object is
-		// a delete marker, and let the main GoFakeS3 class decide what to do.
+		// If the current version of the object is a delete marker, GoFakeS3
+		// finds it in the version listing and adds the
+		// x-amz-delete-marker header itself.
 		return nil, gofakes3.KeyNotFound(objectName)
 	}
 
@@ -218,6 +363,32 @@ func (db *Backend) GetObject(bucketName, objectName string, rangeRequest *gofake
 }
 
 func (db *Backend) PutObject(bucketName, objectName string, meta map[string]string, input io.Reader, size int64) (result gofakes3.PutObjectResult, err error) {
+	return db.put(bucketName, objectName, meta, input, size, nil)
+}
+
+// PutObjectIfMatch implements gofakes3.ConditionalPutBackend. The condition
+// is checked under the same lock as the write.
+func (db *Backend) PutObjectIfMatch(bucketName, objectName, expectedETag string, meta map[string]string, input io.Reader, size int64) (result gofakes3.PutObjectResult, err error) {
+	return db.put(bucketName, objectName, meta, input, size, func(object *bucketObject) error {
+		exists := object != nil && object.data != nil && !object.data.deleteMarker
+		switch {
+		case expectedETag == "" && exists:
+			return gofakes3.ErrPreconditionFailed
+		case expectedETag == "":
+			return nil
+		case !exists:
+			return gofakes3.KeyNotFound(objectName)
+		case hex.EncodeToString(object.data.hash) != expectedETag:
+			return gofakes3.ErrPreconditionFailed
+		}
+		return nil
+	})
+}
+
+// put implements PutObject and PutObjectIfMatch. If check is not nil, it is
+// passed the object currently at the key, which may be nil, and the write
+// only goes ahead if it returns nil.
+func (db *Backend) put(bucketName, objectName string, meta map[string]string, input io.Reader, size int64, check func(object *bucketObject) error) (result gofakes3.PutObjectResult, err error) {
 	// No need to lock the backend while we read the data into memory; it holds
 	// the write lock open unnecessarily, and could be blocked for an unreasonably
 	// long time by a connection timing out:
@@ -231,14 +402,23 @@ func (db *Backend) PutObject(bucketName, objectName string, meta map[string]stri
 		return result, err
 	}
 
-	db.lock.Lock()
-	defer db.lock.Unlock()
+	db.lock.RLock()
+	defer db.lock.RUnlock()
 
 	bucket := db.buckets[bucketName]
 	if bucket == nil {
 		return result, gofakes3.BucketNotFound(bucketName)
 	}
 
+	bucket.mu.Lock()
+	defer bucket.mu.Unlock()
+
+	if check != nil {
+		if err := check(bucket.object(objectName)); err != nil {
+			return result, err
+		}
+	}
+
 	hash := md5.Sum(bts)
 
 	item := &bucketData{
@@ -260,27 +440,65 @@ func (db *Backend) PutObject(bucketName, objectName string, meta map[string]stri
 	return result, nil
 }
 
+// UpdateObjectMetadata implements gofakes3.MetadataUpdatingBackend. Only
+// buckets that have never had versioning enabled are updated in place.
+func (db *Backend) UpdateObjectMetadata(bucketName, objectName string, meta map[string]string) error {
+	db.lock.RLock()
+	defer db.lock.RUnlock()
+
+	bucket := db.buckets[bucketName]
+	if bucket == nil {
+		return gofakes3.BucketNotFound(bucketName)
+	}
+
+	bucket.mu.Lock()
+	defer bucket.mu.Unlock()
+
+	if bucket.versioning != "" {
+		return gofakes3.ErrNotImplemented
+	}
+	object := bucket.object(objectName)
+	if object == nil || object.data == nil || object.data.deleteMarker {
+		return gofakes3.KeyNotFound(objectName)
+	}
+
+	// The item may still be in use by readers of the old metadata, so it is
+	// replaced rather than modified. The body is never modified, so it can
+	// be shared:
+	item := *object.data
+	item.metadata = meta
+	item.lastModified = db.timeSource.Now()
+	object.data = &item
+	return nil
+}
+
 func (db *Backend) DeleteObject(bucketName, objectName string) (result gofakes3.ObjectDeleteResult, rerr error) {
-	db.lock.Lock()
-	defer db.lock.Unlock()
+	db.lock.RLock()
+	defer db.lock.RUnlock()
 
 	bucket := db.buckets[bucketName]
 	if bucket == nil {
 		return result, gofakes3.BucketNotFound(bucketName)
 	}
 
+	bucket.mu.Lock()
+	defer bucket.mu.Unlock()
+
 	return bucket.rm(objectName, db.timeSource.Now())
 }
 
 func (db *Backend) DeleteMulti(bucketName string, objects ...string) (result gofakes3.MultiDeleteResult, err error) {
-	db.lock.Lock()
-	defer db.lock.Unlock()
+	db.lock.RLock()
+	defer db.lock.RUnlock()
 
 	bucket := db.buckets[bucketName]
 	if bucket == nil {
 		return result, gofakes3.BucketNotFound(bucketName)
 	}
 
+	bucket.mu.Lock()
+	defer bucket.mu.Unlock()
+
 	now := db.timeSource.Now()
 
 	for _, object := range objects {
@@ -306,14 +524,17 @@ func (db *Backend) DeleteMulti(bucketName string, objects ...string) (result gof
 }
 
 func (db *Backend) DeleteMultiVersions(bucketName string, objects ...gofakes3.ObjectID) (result gofakes3.MultiDeleteResult, err error) {
-	db.lock.Lock()
-	defer db.lock.Unlock()
+	db.lock.RLock()
+	defer db.lock.RUnlock()
 
 	bucket := db.buckets[bucketName]
 	if bucket == nil {
 		return result, gofakes3.BucketNotFound(bucketName)
 	}
 
+	bucket.mu.Lock()
+	defer bucket.mu.Unlock()
+
 	now := db.timeSource.Now()
 
 	for _, object := range objects {
@@ -351,6 +572,9 @@ func (db *Backend) VersioningConfiguration(bucketName string) (versioning gofake
 		return versioning, gofakes3.BucketNotFound(bucketName)
 	}
 
+	bucket.mu.RLock()
+	defer bucket.mu.RUnlock()
+
 	versioning.Status = bucket.versioning
 
 	return versioning, nil
@@ -361,14 +585,17 @@ func (db *Backend) SetVersioningConfiguration(bucketName string, v gofakes3.Vers
 		return gofakes3.ErrNotImplemented
 	}
 
-	db.lock.Lock()
-	defer db.lock.Unlock()
+	db.lock.RLock()
+	defer db.lock.RUnlock()
 
 	bucket := db.buckets[bucketName]
 	if bucket == nil {
 		return gofakes3.BucketNotFound(bucketName)
 	}
 
+	bucket.mu.Lock()
+	defer bucket.mu.Unlock()
+
 	bucket.setVersioning(v.Enabled())
 
 	return nil
@@ -390,6 +617,9 @@ func (db *Backend) GetObjectVersion(
 		return nil, gofakes3.BucketNotFound(bucketName)
 	}
 
+	bucket.mu.RLock()
+	defer bucket.mu.RUnlock()
+
 	ver, err := bucket.objectVersion(objectName, versionID)
 	if err != nil {
 		return nil, err
@@ -411,6 +641,9 @@ func (db *Backend) HeadObjectVersion(bucketName, objectName string, versionID go
 		return nil, gofakes3.BucketNotFound(bucketName)
 	}
 
+	bucket.mu.RLock()
+	defer bucket.mu.RUnlock()
+
 	ver, err := bucket.objectVersion(objectName, versionID)
 	if err != nil {
 		return nil, err
@@ -420,14 +653,17 @@ func (db *Backend) HeadObjectVersion(bucketName, objectName string, versionID go
 }
 
 func (db *Backend) DeleteObjectVersion(bucketName, objectName string, versionID gofakes3.VersionID) (result gofakes3.ObjectDeleteResult, rerr error) {
-	db.lock.Lock()
-	defer db.lock.Unlock()
+	db.lock.RLock()
+	defer db.lock.RUnlock()
 
 	bucket := db.buckets[bucketName]
 	if bucket == nil {
 		return result, gofakes3.BucketNotFound(bucketName)
 	}
 
+	bucket.mu.Lock()
+	defer bucket.mu.Unlock()
+
 	return bucket.rmVersion(objectName, versionID, db.timeSource.Now())
 }
 
@@ -453,31 +689,24 @@ func (db *Backend) ListBucketVersions(
 		return result, gofakes3.BucketNotFound(bucketName)
 	}
 
+	bucket.mu.RLock()
+	defer bucket.mu.RUnlock()
+
 	var iter = goskipiter.New(bucket.objects.Iterator())
 	var match gofakes3.PrefixMatch
 
+	// Like S3, each key's versions are listed from newest to oldest. A key
+	// marker on its own resumes after every version of that key; with a
+	// version ID marker, it resumes after that version. Version IDs sort in
+	// the order they were generated, so the versions left to list are the
+	// ones with smaller IDs.
 	if page.KeyMarker != "" {
-		if !prefix.Match(page.KeyMarker, &match) {
-			// FIXME: NO idea what S3 would do here.
-			return result, gofakes3.ErrInternal
-		}
 		iter.Seek(page.KeyMarker)
 	}
 
-	var truncated = false
-	var first = true
-	var cnt int64 = 0
-
-	// FIXME: The S3 docs have this to say on the topic of result ordering:
-	//   "The following request returns objects in the order they were stored,
-	//   returning the most recently stored object first starting with the value
-	//   for key-marker."
-	//
-	// OK so this method....
-	// - Returns objects in the order they were stored
-	// - Returning the most recently stored object first
-	//
-	// This makes no sense at all!
+	var cnt int64
+	var lastPrefix string
+	full := func() bool { return page.MaxKeys > 0 && cnt >= page.MaxKeys }
 
 	for iter.Next() {
 		object := iter.Value().(*bucketObject)
@@ -487,65 +716,80 @@ func (db *Backend) ListBucketVersions(
 		}
 
 		if match.CommonPrefix {
+			if match.MatchedPart == lastPrefix || match.MatchedPart == page.KeyMarker {
+				continue
+			}
+			if full() {
+				result.IsTruncated = true
+				break
+			}
 			result.AddPrefix(match.MatchedPart)
+			lastPrefix = match.MatchedPart
+			result.NextKeyMarker, result.NextVersionIDMarker = match.MatchedPart, ""
+			cnt++
 			continue
 		}
 
-		versions := iter.Value().(*bucketObject).Iterator()
-		if first {
-			if page.VersionIDMarker != "" {
-				if !versions.Seek(page.VersionIDMarker) {
-					// FIXME: log
-					return result, gofakes3.ErrInternal
+		for _, version := range object.newestFirst() {
+			if object.name == page.KeyMarker {
+				// S300005: the only version of a key in an unversioned bucket
+				// is 'null', so there is nothing after it.
+				if page.VersionIDMarker == "" || page.VersionIDMarker == "null" || version.versionID >= page.VersionIDMarker {
+					continue
 				}
 			}
-			first = false
-		}
+			if full() {
+				result.IsTruncated = true
+				goto done
+			}
 
-		for versions.Next() {
-			version := versions.Value()
+			var versionID gofakes3.VersionID
+			if bucket.versioning != gofakes3.VersioningNone { // S300005
+				versionID = version.versionID
+			}
 
 			if version.deleteMarker {
-				marker := &gofakes3.DeleteMarker{
+				result.Versions = append(result.Versions, &gofakes3.DeleteMarker{
 					Key:          version.name,
+					VersionID:    versionID,
 					IsLatest:     version == object.data,
 					LastModified: gofakes3.NewContentTime(version.lastModified),
-				}
-				if bucket.versioning != gofakes3.VersioningNone { // S300005
-					marker.VersionID = version.versionID
-				}
-				result.Versions = append(result.Versions, marker)
+				})
 
 			} else {
-				resultVer := &gofakes3.Version{
+				result.Versions = append(result.Versions, &gofakes3.Version{
 					Key:          version.name,
+					VersionID:    versionID,
 					IsLatest:     version == object.data,
 					LastModified: gofakes3.NewContentTime(version.lastModified),
 					Size:         int64(len(version.body)),
 					ETag:         version.etag,
-				}
-				if bucket.versioning != gofakes3.VersioningNone { // S300005
-					resultVer.VersionID = version.versionID
-				}
-				result.Versions = append(result.Versions, resultVer)
+				})
 			}
 
 			cnt++
-			if page.MaxKeys > 0 && cnt >= page.MaxKeys {
-				truncated = versions.Next()
-				goto done
+			result.NextKeyMarker, result.NextVersionIDMarker = version.name, versionID
+			if versionID == "" {
+				result.NextVersionIDMarker = "null" // S300005
 			}
 		}
 	}
 
 done:
-	result.IsTruncated = truncated || iter.Next()
+	// The markers are only sent with a truncated response:
+	if !result.IsTruncated {
+		result.NextKeyMarker, result.NextVersionIDMarker = "", ""
+	}
 
 	return result, nil
 }
 
-// nextVersion assumes the backend's lock is acquired
+// nextVersion may be called concurrently by writers to different buckets, so
+// the shared scratch buffer has its own lock.
 func (db *Backend) nextVersion() gofakes3.VersionID {
+	db.versionLock.Lock()
+	defer db.versionLock.Unlock()
+
 	v, scr := db.versionGenerator.Next(db.versionScratch)
 	db.versionScratch = scr
 	return v
@@ -0,0 +1,390 @@
+package s3mem
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/johannesboyne/gofakes3"
+)
+
+func TestConcurrentBucketOperations(t *testing.T) {
+	const buckets = 8
+	const objects = 200
+
+	db := New()
+	for i := 0; i < buckets; i++ {
+		if err := db.CreateBucket(fmt.Sprintf("bucket%d", i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < buckets; i++ {
+		wg.Add(2)
+		bucket := fmt.Sprintf("bucket%d", i)
+
+		go func() {
+			defer wg.Done()
+			for j := 0; j < objects; j++ {
+				body := "body"
+				key := fmt.Sprintf("key%04d", j)
+				if _, err := db.PutObject(bucket, key, map[string]string{}, strings.NewReader(body), int64(len(body))); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}()
+
+		go func() {
+			defer wg.Done()
+			for j := 0; j < objects; j++ {
+				list, err := db.ListBucket(bucket, nil, gofakes3.ListBucketPage{})
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				seen := map[string]bool{}
+				for _, c := range list.Contents {
+					if seen[c.Key] {
+						t.Error("duplicate key", c.Key)
+						return
+					}
+					seen[c.Key] = true
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	for i := 0; i < buckets; i++ {
+		list, err := db.ListBucket(fmt.Sprintf("bucket%d", i), nil, gofakes3.ListBucketPage{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(list.Contents) != objects {
+			t.Fatal("unexpected object count", len(list.Contents), "!=", objects)
+		}
+	}
+}
+
+func TestListBucketPrefixScan(t *testing.T) {
+	keys := []string{"a", "a/1", "a/2", "ab", "b/c/d", "b/c/e", "b/x", "bb", "c", "c\xff"}
+
+	db := New()
+	if err := db.CreateBucket("bucket"); err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range keys {
+		if _, err := db.PutObject("bucket", key, map[string]string{}, strings.NewReader("body"), 4); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// list is a reference implementation that scans every key:
+	list := func(prefix gofakes3.Prefix, page gofakes3.ListBucketPage) (found []string, truncated bool) {
+		var last string
+		for _, key := range keys {
+			var match gofakes3.PrefixMatch
+			if key <= page.Marker || !prefix.Match(key, &match) {
+				continue
+			}
+			entry := key
+			if match.CommonPrefix {
+				if match.MatchedPart == last {
+					continue
+				}
+				entry, last = match.MatchedPart, match.MatchedPart
+			}
+			if page.MaxKeys > 0 && int64(len(found)) >= page.MaxKeys {
+				return found, true
+			}
+			found = append(found, entry)
+		}
+		return found, false
+	}
+
+	for _, pfx := range []string{"", "a", "a/", "b", "b/", "b/c", "c", "z"} {
+		for _, delim := range []string{"", "/"} {
+			for _, marker := range []string{"", "a", "a/1", "b/c/d", "bb"} {
+				for _, maxKeys := range []int64{0, 1, 2} {
+					prefix := gofakes3.Prefix{HasPrefix: pfx != "", Prefix: pfx, HasDelimiter: delim != "", Delimiter: delim}
+					page := gofakes3.ListBucketPage{Marker: marker, HasMarker: marker != "", MaxKeys: maxKeys}
+
+					rs, err := db.ListBucket("bucket", &prefix, page)
+					if err != nil {
+						t.Fatal(err)
+					}
+					var found []string
+					for _, p := range rs.CommonPrefixes {
+						found = append(found, p.Prefix)
+					}
+					for _, c := range rs.Contents {
+						found = append(found, c.Key)
+					}
+					sort.Strings(found)
+
+					expected, truncated := list(prefix, page)
+					sort.Strings(expected)
+					if !reflect.DeepEqual(found, expected) || rs.IsTruncated != truncated {
+						t.Fatalf("%s, %+v: expected %q (truncated: %v), found %q (truncated: %v)",
+							prefix, page, expected, truncated, found, rs.IsTruncated)
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestListBucketsPage(t *testing.T) {
+	db := New()
+	for _, name := range []string{"b1", "a2", "a1", "a3"} {
+		if err := db.CreateBucket(name); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var found []string
+	var token string
+	for {
+		page, next, err := db.ListBucketsPage("a", token, 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, bucket := range page {
+			found = append(found, bucket.Name)
+		}
+		if next == "" {
+			break
+		}
+		token = next
+	}
+	if !reflect.DeepEqual(found, []string{"a1", "a2", "a3"}) {
+		t.Fatal("unexpected buckets", found)
+	}
+
+	if _, _, err := db.ListBucketsPage("", "!", 2); !gofakes3.HasErrorCode(err, gofakes3.ErrInvalidArgument) {
+		t.Fatal("expected InvalidArgument, found", err)
+	}
+}
+
+func TestListBucketPagingConcurrentWrites(t *testing.T) {
+	const stable = 200
+
+	db := New()
+	if err := db.CreateBucket("bucket"); err != nil {
+		t.Fatal(err)
+	}
+	put := func(key string) {
+		if _, err := db.PutObject("bucket", key, map[string]string{}, strings.NewReader("body"), 4); err != nil {
+			t.Error(err)
+		}
+	}
+
+	// Keys with even numbers exist throughout; those with odd numbers, and
+	// the 'churn/' directories, come and go while the listings are paged:
+	for i := 0; i < stable; i++ {
+		put(fmt.Sprintf("dir%d/key%04d", i%5, i*2))
+	}
+
+	stop := make(chan struct{})
+	var writers sync.WaitGroup
+	for w := 0; w < 4; w++ {
+		writers.Add(1)
+		go func(w int) {
+			defer writers.Done()
+			for i := 0; ; i++ {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				keys := []string{
+					fmt.Sprintf("dir%d/key%04d", i%5, (i%stable)*2+1),
+					fmt.Sprintf("churn%d/key%d", w, i%3),
+				}
+				for _, key := range keys {
+					put(key)
+				}
+				for _, key := range keys {
+					if _, err := db.DeleteObject("bucket", key); err != nil {
+						t.Error(err)
+					}
+				}
+			}
+		}(w)
+	}
+
+	for _, delim := range []string{"", "/"} {
+		for _, maxKeys := range []int64{1, 3, 17} {
+			prefix := gofakes3.Prefix{HasDelimiter: delim != "", Delimiter: delim}
+			expected := map[string]bool{}
+			for i := 0; i < stable; i++ {
+				key := fmt.Sprintf("dir%d/key%04d", i%5, i*2)
+				var match gofakes3.PrefixMatch
+				prefix.Match(key, &match)
+				expected[match.MatchedPart] = true
+			}
+
+			for round := 0; round < 5; round++ {
+				seen := map[string]bool{}
+				var last string
+				page := gofakes3.ListBucketPage{MaxKeys: maxKeys}
+				for {
+					rs, err := db.ListBucket("bucket", &prefix, page)
+					if err != nil {
+						t.Fatal(err)
+					}
+					var found []string
+					for _, p := range rs.CommonPrefixes {
+						found = append(found, p.Prefix)
+					}
+					for _, c := range rs.Contents {
+						found = append(found, c.Key)
+					}
+					sort.Strings(found)
+					for _, entry := range found {
+						if seen[entry] {
+							t.Fatalf("%s, max-keys %d: %q listed twice", prefix, maxKeys, entry)
+						} else if entry <= last {
+							t.Fatalf("%s, max-keys %d: %q listed after %q", prefix, maxKeys, entry, last)
+						}
+						seen[entry] = true
+					}
+					if len(found) > 0 {
+						last = found[len(found)-1]
+					}
+					if !rs.IsTruncated {
+						break
+					}
+					page.Marker, page.HasMarker = rs.NextMarker, true
+				}
+
+				for entry := range expected {
+					if !seen[entry] {
+						t.Fatalf("%s, max-keys %d: %q was skipped", prefix, maxKeys, entry)
+					}
+				}
+			}
+		}
+	}
+
+	close(stop)
+	writers.Wait()
+}
+
+func BenchmarkListBucketPage(b *testing.B) {
+	const objects = 100000
+
+	db := New()
+	if err := db.CreateBucket("bucket"); err != nil {
+		b.Fatal(err)
+	}
+	for i := 0; i < objects; i++ {
+		key := fmt.Sprintf("dir%d/key%06d", i%10, i)
+		if _, err := db.PutObject("bucket", key, map[string]string{}, strings.NewReader("body"), 4); err != nil {
+			b.Fatal(err)
+		}
+	}
+
+	for _, bm := range []struct {
+		name   string
+		prefix gofakes3.Prefix
+	}{
+		{"all", gofakes3.Prefix{}},
+		{"prefix", gofakes3.Prefix{HasPrefix: true, Prefix: "dir9/"}},
+		{"delimiter", gofakes3.Prefix{HasDelimiter: true, Delimiter: "/"}},
+	} {
+		b.Run(bm.name, func(b *testing.B) {
+			page := gofakes3.ListBucketPage{MaxKeys: 1000}
+			for i := 0; i < b.N; i++ {
+				if _, err := db.ListBucket("bucket", &bm.prefix, page); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkConcurrentPutManyBuckets(b *testing.B) {
+	const buckets = 64
+
+	db := New()
+	for i := 0; i < buckets; i++ {
+		if err := db.CreateBucket(fmt.Sprintf("bucket%d", i)); err != nil {
+			b.Fatal(err)
+		}
+	}
+
+	body := strings.Repeat("x", 1024)
+	var next uint64
+	var mu sync.Mutex
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		mu.Lock()
+		bucket := fmt.Sprintf("bucket%d", next%buckets)
+		next++
+		mu.Unlock()
+
+		i := 0
+		for pb.Next() {
+			key := fmt.Sprintf("key%d", i%100)
+			if _, err := db.PutObject(bucket, key, map[string]string{}, strings.NewReader(body), int64(len(body))); err != nil {
+				b.Fatal(err)
+			}
+			if _, err := db.ListBucket(bucket, nil, gofakes3.ListBucketPage{}); err != nil {
+				b.Fatal(err)
+			}
+			i++
+		}
+	})
+}
+
+func TestPutObjectIfMatch(t *testing.T) {
+	db := New()
+	if err := db.CreateBucket("bucket"); err != nil {
+		t.Fatal(err)
+	}
+	put := func(expectedETag, body string) error {
+		_, err := db.PutObjectIfMatch("bucket", "key", expectedETag, map[string]string{}, strings.NewReader(body), int64(len(body)))
+		return err
+	}
+	const helloETag = "5d41402abc4b2a76b9719d911017c592"
+
+	if err := put(helloETag, "hello"); !gofakes3.HasErrorCode(err, gofakes3.ErrNoSuchKey) {
+		t.Fatal("expected ErrNoSuchKey, found", err)
+	}
+	if err := put("", "hello"); err != nil {
+		t.Fatal(err)
+	}
+	if err := put("", "again"); !gofakes3.HasErrorCode(err, gofakes3.ErrPreconditionFailed) {
+		t.Fatal("expected ErrPreconditionFailed, found", err)
+	}
+	if err := put("nope", "again"); !gofakes3.HasErrorCode(err, gofakes3.ErrPreconditionFailed) {
+		t.Fatal("expected ErrPreconditionFailed, found", err)
+	}
+	if err := put(helloETag, "world"); err != nil {
+		t.Fatal(err)
+	}
+
+	obj, err := db.GetObject("bucket", "key", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer obj.Contents.Close()
+	if body, _ := gofakes3.ReadAll(obj.Contents, obj.Size); string(body) != "world" {
+		t.Fatal("unexpected body", string(body))
+	}
+
+	// The key is free again once the object is deleted:
+	if _, err := db.DeleteObject("bucket", "key"); err != nil {
+		t.Fatal(err)
+	}
+	if err := put("", "hello"); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -3,6 +3,7 @@ package s3mem
 import (
 	"bytes"
 	"io"
+	"sync"
 	"time"
 
 	"github.com/johannesboyne/gofakes3"
@@ -20,6 +21,9 @@ type bucket struct {
 	versionGen   versionGenFunc
 	creationDate gofakes3.ContentTime
 
+	// mu guards versioning and objects. It must only be acquired while
+	// holding the Backend's lock.
+	mu      sync.RWMutex
 	objects *skiplist.SkipList
 }
 
@@ -38,77 +42,23 @@ type bucketObject struct {
 	versions *skiplist.SkipList
 }
 
-func (b *bucketObject) Iterator() *bucketObjectIterator {
-	var iter skiplist.Iterator
-	if b.versions != nil {
-		iter = b.versions.Iterator()
-	}
-
-	return &bucketObjectIterator{
-		data: b.data,
-		iter: iter,
-	}
-}
-
-type bucketObjectIterator struct {
-	data     *bucketData
-	iter     skiplist.Iterator
-	cur      *bucketData
-	seenData bool
-	done     bool
-}
-
-func (b *bucketObjectIterator) Seek(key gofakes3.VersionID) bool {
-	if b.iter.Seek(key) {
-		return true
-	}
-
-	b.iter = nil
-	if b.data != nil && b.data.versionID == key {
-		return true
-	}
-
-	b.data = nil
-	b.done = true
-
-	return false
-}
-
-func (b *bucketObjectIterator) Next() bool {
-	if b.done {
-		return false
-	}
-
-	if b.iter != nil {
-		iterAlive := b.iter.Next()
-		if iterAlive {
-			b.cur = b.iter.Value().(*bucketData)
-			return true
-		}
-
-		b.iter.Close()
-		b.iter = nil
-	}
-
+// newestFirst returns every version of the object, including delete markers,
+// from the most recently stored to the least, as S3 lists them. Version IDs
+// sort in the order they were generated, so the current version always comes
+// before the noncurrent ones.
+func (b *bucketObject) newestFirst() []*bucketData {
+	var versions []*bucketData
 	if b.data != nil {
-		b.cur = b.data
-		b.data = nil
-		return true
+		versions = append(versions, b.data)
 	}
-
-	b.done = true
-	return false
-}
-
-func (b *bucketObjectIterator) Close() {
-	if b.iter != nil {
-		b.iter.Close()
+	if b.versions == nil {
+		return versions
 	}
-	b.done = true
-}
-
-func (b *bucketObjectIterator) Value() *bucketData {
-	return b.cur
+	iter := b.versions.SeekToLast()
+	for ok := iter != nil; ok; ok = iter.Previous() {
+		versions = append(versions, iter.Value().(*bucketData))
+	}
+	return versions
 }
 
 type bucketData struct {
@@ -142,7 +92,7 @@ func (bi *bucketData) toObject(rangeRequest *gofakes3.ObjectRangeRequest, withBo
 
 		// The data slice should be completely replaced if the bucket item is edited, so
 		// it should be safe to return the data slice directly.
-		contents = s3io.ReaderWithDummyCloser{bytes.NewReader(data)}
+		contents = s3io.ReaderWithDummyCloser{Reader: bytes.NewReader(data)}
 
 	} else {
 		contents = s3io.NoOpReadCloser{}
@@ -0,0 +1,363 @@
+// Package s3proxy provides a gofakes3.Backend that passes every call through
+// to another S3 implementation, like MinIO, using an aws-sdk-go client.
+//
+// It is intended as a base for backends that fake a handful of operations
+// while leaving the rest to a real server: embed *Backend in a struct and
+// override the methods that should be faked.
+package s3proxy
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+	"github.com/johannesboyne/gofakes3"
+	"github.com/johannesboyne/gofakes3/internal/s3io"
+)
+
+// Backend is a gofakes3.Backend that sends every call to an S3 client.
+//
+// Only the basic Backend interface is implemented. GoFakeS3 handles
+// multipart uploads itself, storing the completed object with PutObject, and
+// versioned operations are not supported.
+type Backend struct {
+	client s3iface.S3API
+}
+
+var _ gofakes3.Backend = &Backend{}
+
+// New creates a Backend that sends every call to client, which is usually an
+// *s3.S3 configured to talk to the real server.
+func New(client s3iface.S3API) *Backend {
+	return &Backend{client: client}
+}
+
+func (db *Backend) ListBuckets() ([]gofakes3.BucketInfo, error) {
+	out, err := db.client.ListBuckets(&s3.ListBucketsInput{})
+	if err != nil {
+		return nil, mapError(err, "", "")
+	}
+
+	buckets := make([]gofakes3.BucketInfo, 0, len(out.Buckets))
+	for _, bucket := range out.Buckets {
+		buckets = append(buckets, gofakes3.BucketInfo{
+			Name:         aws.StringValue(bucket.Name),
+			CreationDate: gofakes3.NewContentTime(aws.TimeValue(bucket.CreationDate)),
+		})
+	}
+	return buckets, nil
+}
+
+func (db *Backend) ListBucket(name string, prefix *gofakes3.Prefix, page gofakes3.ListBucketPage) (*gofakes3.ObjectList, error) {
+	in := &s3.ListObjectsInput{Bucket: aws.String(name)}
+	if prefix != nil && prefix.HasPrefix {
+		in.Prefix = aws.String(prefix.Prefix)
+	}
+	if prefix != nil && prefix.HasDelimiter {
+		in.Delimiter = aws.String(prefix.Delimiter)
+	}
+	if page.HasMarker {
+		in.Marker = aws.String(page.Marker)
+	}
+	if page.MaxKeys > 0 {
+		in.MaxKeys = aws.Int64(page.MaxKeys)
+	}
+
+	out, err := db.client.ListObjects(in)
+	if err != nil {
+		return nil, mapError(err, name, "")
+	}
+
+	response := gofakes3.NewObjectList()
+	for _, item := range out.Contents {
+		response.Add(&gofakes3.Content{
+			Key:          aws.StringValue(item.Key),
+			LastModified: gofakes3.NewContentTime(aws.TimeValue(item.LastModified)),
+			ETag:         aws.StringValue(item.ETag),
+			Size:         aws.Int64Value(item.Size),
+			StorageClass: gofakes3.StorageClass(aws.StringValue(item.StorageClass)),
+		})
+	}
+	for _, common := range out.CommonPrefixes {
+		response.AddPrefix(aws.StringValue(common.Prefix))
+	}
+
+	response.IsTruncated = aws.BoolValue(out.IsTruncated)
+	if response.IsTruncated {
+		// S3 only returns NextMarker when a delimiter is used; otherwise, the
+		// last key is the marker for the next page:
+		response.NextMarker = aws.StringValue(out.NextMarker)
+		if response.NextMarker == "" && len(out.Contents) > 0 {
+			response.NextMarker = aws.StringValue(out.Contents[len(out.Contents)-1].Key)
+		}
+	}
+	return response, nil
+}
+
+func (db *Backend) CreateBucket(name string) error {
+	_, err := db.client.CreateBucket(&s3.CreateBucketInput{Bucket: aws.String(name)})
+	return mapError(err, name, "")
+}
+
+func (db *Backend) BucketExists(name string) (exists bool, err error) {
+	_, err = db.client.HeadBucket(&s3.HeadBucketInput{Bucket: aws.String(name)})
+	if err == nil {
+		return true, nil
+	}
+	if reqErr, ok := err.(awserr.RequestFailure); ok && reqErr.StatusCode() == http.StatusNotFound {
+		return false, nil
+	}
+	return false, mapError(err, name, "")
+}
+
+func (db *Backend) DeleteBucket(name string) error {
+	_, err := db.client.DeleteBucket(&s3.DeleteBucketInput{Bucket: aws.String(name)})
+	return mapError(err, name, "")
+}
+
+func (db *Backend) HeadObject(bucketName, objectName string) (*gofakes3.Object, error) {
+	rq, out := db.client.HeadObjectRequest(&s3.HeadObjectInput{
+		Bucket: aws.String(bucketName),
+		Key:    aws.String(objectName),
+	})
+	if err := rq.Send(); err != nil {
+		return nil, mapError(err, bucketName, objectName)
+	}
+
+	return &gofakes3.Object{
+		Name:      objectName,
+		Metadata:  responseMetadata(rq),
+		Size:      aws.Int64Value(out.ContentLength),
+		Contents:  s3io.NoOpReadCloser{},
+		Hash:      etagHash(aws.StringValue(out.ETag)),
+		VersionID: gofakes3.VersionID(aws.StringValue(out.VersionId)),
+	}, nil
+}
+
+func (db *Backend) GetObject(bucketName, objectName string, rangeRequest *gofakes3.ObjectRangeRequest) (*gofakes3.Object, error) {
+	in := &s3.GetObjectInput{
+		Bucket: aws.String(bucketName),
+		Key:    aws.String(objectName),
+	}
+	if rangeRequest != nil {
+		in.Range = aws.String(rangeHeader(rangeRequest))
+	}
+
+	rq, out := db.client.GetObjectRequest(in)
+	if err := rq.Send(); err != nil {
+		return nil, mapError(err, bucketName, objectName)
+	}
+
+	obj := &gofakes3.Object{
+		Name:      objectName,
+		Metadata:  responseMetadata(rq),
+		Size:      aws.Int64Value(out.ContentLength),
+		Contents:  out.Body,
+		Hash:      etagHash(aws.StringValue(out.ETag)),
+		VersionID: gofakes3.VersionID(aws.StringValue(out.VersionId)),
+	}
+
+	// The server may ignore the Range and send the whole object, in which
+	// case there is no Content-Range:
+	if contentRange := aws.StringValue(out.ContentRange); contentRange != "" {
+		rnge, size, err := parseContentRange(contentRange)
+		if err != nil {
+			out.Body.Close()
+			return nil, err
+		}
+		obj.Range, obj.Size = rnge, size
+	}
+	return obj, nil
+}
+
+func (db *Backend) DeleteObject(bucketName, objectName string) (result gofakes3.ObjectDeleteResult, err error) {
+	out, err := db.client.DeleteObject(&s3.DeleteObjectInput{
+		Bucket: aws.String(bucketName),
+		Key:    aws.String(objectName),
+	})
+	if err != nil {
+		return result, mapError(err, bucketName, objectName)
+	}
+	result.IsDeleteMarker = aws.BoolValue(out.DeleteMarker)
+	result.VersionID = gofakes3.VersionID(aws.StringValue(out.VersionId))
+	return result, nil
+}
+
+func (db *Backend) PutObject(bucketName, objectName string, meta map[string]string, input io.Reader, size int64) (result gofakes3.PutObjectResult, err error) {
+	// The SDK needs to seek the body to sign it, so it is read into memory:
+	bts, err := gofakes3.ReadAll(input, size)
+	if err != nil {
+		return result, err
+	}
+
+	rq, out := db.client.PutObjectRequest(&s3.PutObjectInput{
+		Bucket: aws.String(bucketName),
+		Key:    aws.String(objectName),
+		Body:   bytes.NewReader(bts),
+	})
+	for k, v := range meta {
+		if forwardedHeader(k) {
+			rq.HTTPRequest.Header.Set(k, v)
+		}
+	}
+	if err := rq.Send(); err != nil {
+		return result, mapError(err, bucketName, objectName)
+	}
+
+	result.VersionID = gofakes3.VersionID(aws.StringValue(out.VersionId))
+	return result, nil
+}
+
+func (db *Backend) DeleteMulti(bucketName string, objects ...string) (result gofakes3.MultiDeleteResult, err error) {
+	in := &s3.DeleteObjectsInput{
+		Bucket: aws.String(bucketName),
+		Delete: &s3.Delete{},
+	}
+	for _, object := range objects {
+		in.Delete.Objects = append(in.Delete.Objects, &s3.ObjectIdentifier{Key: aws.String(object)})
+	}
+
+	out, err := db.client.DeleteObjects(in)
+	if err != nil {
+		return result, mapError(err, bucketName, "")
+	}
+
+	for _, deleted := range out.Deleted {
+		result.Deleted = append(result.Deleted, gofakes3.ObjectID{
+			Key:       aws.StringValue(deleted.Key),
+			VersionID: aws.StringValue(deleted.VersionId),
+		})
+	}
+	for _, failed := range out.Errors {
+		result.Error = append(result.Error, gofakes3.ErrorResult{
+			Key:     aws.StringValue(failed.Key),
+			Code:    gofakes3.ErrorCode(aws.StringValue(failed.Code)),
+			Message: aws.StringValue(failed.Message),
+		})
+	}
+	return result, nil
+}
+
+// mapError converts an error returned by the client into the error the
+// Backend interface expects, so that GoFakeS3 responds with the same code as
+// the server did. HEAD requests have no response body, so their 404s only
+// say 'NotFound'; these become ErrNoSuchKey, or ErrNoSuchBucket if no object
+// was involved.
+func mapError(err error, bucket, object string) error {
+	if err == nil {
+		return nil
+	}
+
+	reqErr, ok := err.(awserr.RequestFailure)
+	if !ok {
+		return err
+	}
+
+	switch code := gofakes3.ErrorCode(reqErr.Code()); {
+	case code == gofakes3.ErrNoSuchBucket:
+		return gofakes3.BucketNotFound(bucket)
+	case code == gofakes3.ErrNoSuchKey:
+		return gofakes3.KeyNotFound(object)
+	case reqErr.StatusCode() == http.StatusNotFound && code == "NotFound":
+		if object == "" {
+			return gofakes3.BucketNotFound(bucket)
+		}
+		return gofakes3.KeyNotFound(object)
+	default:
+		return gofakes3.ErrorMessage(code, reqErr.Message())
+	}
+}
+
+// forwardedHeader reports whether a metadata key, as stored by GoFakeS3,
+// should be sent to the server with PutObject. GoFakeS3 stores every
+// 'x-amz-' header of the request, but those that describe the request
+// itself, like 'x-amz-date', would break the client's signature.
+func forwardedHeader(key string) bool {
+	switch key {
+	case "Content-Type", "Cache-Control", "Content-Disposition", "Content-Encoding", "Content-Language", "Expires",
+		"X-Amz-Acl", "X-Amz-Tagging", "X-Amz-Storage-Class", "X-Amz-Website-Redirect-Location":
+		return true
+	}
+	return isMetadataKey(key) ||
+		strings.HasPrefix(key, "X-Amz-Grant-") ||
+		strings.HasPrefix(key, "X-Amz-Object-Lock-") ||
+		strings.HasPrefix(key, "X-Amz-Server-Side-Encryption")
+}
+
+// responseMetadata collects the headers of a GET or HEAD object response
+// that GoFakeS3 would have stored as metadata for the object.
+func responseMetadata(rq *request.Request) map[string]string {
+	meta := map[string]string{}
+	for k, vs := range rq.HTTPResponse.Header {
+		switch k {
+		case "X-Amz-Id-2", "X-Amz-Request-Id", "X-Amz-Version-Id", "X-Amz-Delete-Marker":
+			continue
+		case "Content-Type", "Cache-Control", "Content-Disposition", "Content-Encoding", "Content-Language", "Expires", "Last-Modified":
+		default:
+			if !strings.HasPrefix(k, "X-Amz-") {
+				continue
+			}
+		}
+		if isMetadataKey(k) {
+			k = strings.ToLower(k)
+		}
+		meta[k] = vs[0]
+	}
+	return meta
+}
+
+// isMetadataKey reports whether a header holds user-defined metadata. GoFakeS3
+// stores these in lower case, but the client canonicalizes response headers.
+func isMetadataKey(key string) bool {
+	return strings.HasPrefix(strings.ToLower(key), "x-amz-meta-")
+}
+
+// etagHash recovers the hash GoFakeS3 formats as the object's ETag. The
+// ETags of multipart uploads, like '"<hash>-<parts>"', lose the part count.
+func etagHash(etag string) []byte {
+	etag = strings.Trim(etag, `"`)
+	if idx := strings.IndexByte(etag, '-'); idx >= 0 {
+		etag = etag[:idx]
+	}
+	hash, _ := hex.DecodeString(etag)
+	return hash
+}
+
+func rangeHeader(rnge *gofakes3.ObjectRangeRequest) string {
+	if rnge.FromEnd {
+		return fmt.Sprintf("bytes=-%d", rnge.End)
+	} else if rnge.End == gofakes3.RangeNoEnd {
+		return fmt.Sprintf("bytes=%d-", rnge.Start)
+	}
+	return fmt.Sprintf("bytes=%d-%d", rnge.Start, rnge.End)
+}
+
+// parseContentRange parses a Content-Range header like 'bytes 0-99/1024'.
+func parseContentRange(hdr string) (rnge *gofakes3.ObjectRange, size int64, err error) {
+	var start, end int64
+	spec := strings.TrimPrefix(hdr, "bytes ")
+	slash := strings.IndexByte(spec, '/')
+	dash := strings.IndexByte(spec, '-')
+	if slash < 0 || dash < 0 || dash > slash {
+		return nil, 0, fmt.Errorf("s3proxy: invalid Content-Range %q", hdr)
+	}
+	if start, err = strconv.ParseInt(spec[:dash], 10, 64); err != nil {
+		return nil, 0, fmt.Errorf("s3proxy: invalid Content-Range %q", hdr)
+	}
+	if end, err = strconv.ParseInt(spec[dash+1:slash], 10, 64); err != nil {
+		return nil, 0, fmt.Errorf("s3proxy: invalid Content-Range %q", hdr)
+	}
+	if size, err = strconv.ParseInt(spec[slash+1:], 10, 64); err != nil {
+		return nil, 0, fmt.Errorf("s3proxy: invalid Content-Range %q", hdr)
+	}
+	return &gofakes3.ObjectRange{Start: start, Length: end - start + 1}, size, nil
+}
@@ -0,0 +1,177 @@
+package s3proxy
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/johannesboyne/gofakes3"
+	"github.com/johannesboyne/gofakes3/backend/s3mem"
+)
+
+func newClient(url string) *s3.S3 {
+	config := aws.NewConfig()
+	config.WithEndpoint(url)
+	config.WithRegion("region")
+	config.WithCredentials(credentials.NewStaticCredentials("dummy-access", "dummy-secret", ""))
+	config.WithS3ForcePathStyle(true)
+	return s3.New(session.New(), config)
+}
+
+// newUpstream starts a GoFakeS3 server to stand in for the real server that
+// Backend proxies to.
+func newUpstream(t *testing.T) (upstream *s3mem.Backend, proxy *Backend, close func()) {
+	upstream = s3mem.New()
+	if err := upstream.CreateBucket("bucket"); err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(gofakes3.New(upstream).Server())
+	return upstream, New(newClient(server.URL)), server.Close
+}
+
+func TestProxyObjects(t *testing.T) {
+	upstream, db, close := newUpstream(t)
+	defer close()
+
+	body := "hello proxy"
+	if _, err := db.PutObject("bucket", "dir/object", map[string]string{
+		"Content-Type":    "text/plain",
+		"x-amz-meta-test": "value",
+		"X-Amz-Date":      "20180101T120000Z",
+	}, strings.NewReader(body), int64(len(body))); err != nil {
+		t.Fatal(err)
+	}
+
+	stored, err := upstream.HeadObject("bucket", "dir/object")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Metadata["Content-Type"] != "text/plain" || stored.Metadata["x-amz-meta-test"] != "value" {
+		t.Fatal("metadata not forwarded", stored.Metadata)
+	}
+
+	obj, err := db.GetObject("bucket", "dir/object", &gofakes3.ObjectRangeRequest{Start: 6, End: gofakes3.RangeNoEnd})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer obj.Contents.Close()
+	out, err := ioutil.ReadAll(obj.Contents)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(out) != "proxy" || obj.Size != int64(len(body)) || obj.Range == nil || obj.Range.Start != 6 || obj.Range.Length != 5 {
+		t.Fatal("unexpected range", string(out), obj.Size, obj.Range)
+	}
+	if obj.Metadata["x-amz-meta-test"] != "value" || !bytes.Equal(obj.Hash, stored.Hash) {
+		t.Fatal("unexpected object", obj.Metadata, obj.Hash)
+	}
+
+	list, err := db.ListBucket("bucket", &gofakes3.Prefix{HasPrefix: true, Prefix: "d", HasDelimiter: true, Delimiter: "/"}, gofakes3.ListBucketPage{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list.Contents) != 0 || len(list.CommonPrefixes) != 1 || list.CommonPrefixes[0].Prefix != "dir/" {
+		t.Fatal("unexpected listing", list.Contents, list.CommonPrefixes)
+	}
+
+	result, err := db.DeleteMulti("bucket", "dir/object")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Deleted) != 1 || result.Deleted[0].Key != "dir/object" {
+		t.Fatal("unexpected result", result)
+	}
+	if _, err := db.HeadObject("bucket", "dir/object"); !gofakes3.HasErrorCode(err, gofakes3.ErrNoSuchKey) {
+		t.Fatal("expected ErrNoSuchKey, found", err)
+	}
+}
+
+func TestProxyBuckets(t *testing.T) {
+	_, db, close := newUpstream(t)
+	defer close()
+
+	if exists, err := db.BucketExists("missing"); err != nil || exists {
+		t.Fatal("unexpected result", exists, err)
+	}
+	if _, err := db.GetObject("missing", "object", nil); !gofakes3.HasErrorCode(err, gofakes3.ErrNoSuchBucket) {
+		t.Fatal("expected ErrNoSuchBucket, found", err)
+	}
+
+	if err := db.CreateBucket("other"); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.CreateBucket("other"); !gofakes3.HasErrorCode(err, gofakes3.ErrBucketAlreadyExists) {
+		t.Fatal("expected ErrBucketAlreadyExists, found", err)
+	}
+	if exists, err := db.BucketExists("other"); err != nil || !exists {
+		t.Fatal("unexpected result", exists, err)
+	}
+
+	buckets, err := db.ListBuckets()
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := map[string]bool{}
+	for _, bucket := range buckets {
+		names[bucket.Name] = true
+	}
+	if len(buckets) != 2 || !names["bucket"] || !names["other"] {
+		t.Fatal("unexpected buckets", buckets)
+	}
+
+	if err := db.DeleteBucket("other"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// fakeHead answers HeadObject itself and proxies everything else.
+type fakeHead struct {
+	*Backend
+}
+
+func (f fakeHead) HeadObject(bucketName, objectName string) (*gofakes3.Object, error) {
+	return nil, gofakes3.ErrAccessDenied
+}
+
+func TestProxyOverride(t *testing.T) {
+	_, db, close := newUpstream(t)
+	defer close()
+
+	server := httptest.NewServer(gofakes3.New(fakeHead{db}).Server())
+	defer server.Close()
+	svc := newClient(server.URL)
+
+	if _, err := svc.PutObject(&s3.PutObjectInput{
+		Bucket: aws.String("bucket"),
+		Key:    aws.String("object"),
+		Body:   strings.NewReader("hello"),
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	rs, err := svc.GetObject(&s3.GetObjectInput{
+		Bucket: aws.String("bucket"),
+		Key:    aws.String("object"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rs.Body.Close()
+	if out, _ := ioutil.ReadAll(rs.Body); string(out) != "hello" {
+		t.Fatal("unexpected body", string(out))
+	}
+
+	_, err = svc.HeadObject(&s3.HeadObjectInput{
+		Bucket: aws.String("bucket"),
+		Key:    aws.String("object"),
+	})
+	if reqErr, ok := err.(interface{ StatusCode() int }); !ok || reqErr.StatusCode() != 403 {
+		t.Fatal("expected the faked HeadObject to be used, found", err)
+	}
+}
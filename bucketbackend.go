@@ -0,0 +1,281 @@
+package gofakes3
+
+import (
+	"io"
+	"sort"
+	"time"
+)
+
+// bucketRouter implements WithBucketBackend. It is a Backend that passes each
+// call on to the Backend the bucket is mapped to, or to the default Backend
+// for the rest.
+//
+// It implements every optional Backend interface GoFakeS3 checks for. Where
+// the Backend a call is routed to does not implement one, the call fails with
+// ErrNotImplemented, which GoFakeS3 takes as a cue to fall back, or does what
+// GoFakeS3 would do without the interface.
+type bucketRouter struct {
+	fallback          Backend
+	fallbackMultipart MultipartBackend
+	buckets           map[string]Backend
+	multipart         map[string]MultipartBackend
+}
+
+var (
+	_ Backend                 = &bucketRouter{}
+	_ VersionedBackend        = &bucketRouter{}
+	_ MultipartBackend        = &bucketRouter{}
+	_ FlushingBackend         = &bucketRouter{}
+	_ ConditionalPutBackend   = &bucketRouter{}
+	_ MetadataUpdatingBackend = &bucketRouter{}
+)
+
+func newBucketRouter(fallback Backend, fallbackMultipart MultipartBackend, buckets map[string]Backend) *bucketRouter {
+	router := &bucketRouter{
+		fallback:          fallback,
+		fallbackMultipart: fallbackMultipart,
+		buckets:           buckets,
+		multipart:         make(map[string]MultipartBackend, len(buckets)),
+	}
+	for name, backend := range buckets {
+		multipart, ok := backend.(MultipartBackend)
+		if !ok {
+			multipart = &multipartBackend{storage: backend, uploader: newUploader()}
+		}
+		router.multipart[name] = multipart
+	}
+	return router
+}
+
+// backend returns the Backend that stores the bucket.
+func (r *bucketRouter) backend(bucket string) Backend {
+	if backend, ok := r.buckets[bucket]; ok {
+		return backend
+	}
+	return r.fallback
+}
+
+func (r *bucketRouter) multipartBackend(bucket string) MultipartBackend {
+	if multipart, ok := r.multipart[bucket]; ok {
+		return multipart
+	}
+	return r.fallbackMultipart
+}
+
+// ListBuckets lists the default Backend's buckets, other than those that have
+// been mapped elsewhere, along with each mapped bucket that exists in its own
+// Backend.
+func (r *bucketRouter) ListBuckets() ([]BucketInfo, error) {
+	all, err := r.fallback.ListBuckets()
+	if err != nil {
+		return nil, err
+	}
+	buckets := make([]BucketInfo, 0, len(all))
+	for _, bucket := range all {
+		if _, ok := r.buckets[bucket.Name]; !ok {
+			buckets = append(buckets, bucket)
+		}
+	}
+
+	for name, backend := range r.buckets {
+		all, err := backend.ListBuckets()
+		if err != nil {
+			return nil, err
+		}
+		for _, bucket := range all {
+			if bucket.Name == name {
+				buckets = append(buckets, bucket)
+			}
+		}
+	}
+
+	sort.Slice(buckets, func(i, j int) bool { return buckets[i].Name < buckets[j].Name })
+	return buckets, nil
+}
+
+// ListBucket pages the listing itself if the Backend is an IteratingBackend
+// that can't, as GoFakeS3 would.
+func (r *bucketRouter) ListBucket(name string, prefix *Prefix, page ListBucketPage) (*ObjectList, error) {
+	backend := r.backend(name)
+	objects, err := backend.ListBucket(name, prefix, page)
+	if ib, ok := backend.(IteratingBackend); ok && err == ErrInternalPageNotImplemented {
+		return listBucketIter(ib, name, prefix, page)
+	}
+	return objects, err
+}
+
+func (r *bucketRouter) CreateBucket(name string) error {
+	return r.backend(name).CreateBucket(name)
+}
+
+func (r *bucketRouter) BucketExists(name string) (exists bool, err error) {
+	return r.backend(name).BucketExists(name)
+}
+
+func (r *bucketRouter) DeleteBucket(name string) error {
+	return r.backend(name).DeleteBucket(name)
+}
+
+func (r *bucketRouter) GetObject(bucketName, objectName string, rangeRequest *ObjectRangeRequest) (*Object, error) {
+	return r.backend(bucketName).GetObject(bucketName, objectName, rangeRequest)
+}
+
+func (r *bucketRouter) HeadObject(bucketName, objectName string) (*Object, error) {
+	return r.backend(bucketName).HeadObject(bucketName, objectName)
+}
+
+func (r *bucketRouter) DeleteObject(bucketName, objectName string) (ObjectDeleteResult, error) {
+	return r.backend(bucketName).DeleteObject(bucketName, objectName)
+}
+
+func (r *bucketRouter) PutObject(bucketName, key string, meta map[string]string, input io.Reader, size int64) (PutObjectResult, error) {
+	return r.backend(bucketName).PutObject(bucketName, key, meta, input, size)
+}
+
+func (r *bucketRouter) DeleteMulti(bucketName string, objects ...string) (MultiDeleteResult, error) {
+	return r.backend(bucketName).DeleteMulti(bucketName, objects...)
+}
+
+// VersioningConfiguration reports that versioning has never been enabled on
+// a bucket whose Backend does not support it.
+func (r *bucketRouter) VersioningConfiguration(bucket string) (VersioningConfiguration, error) {
+	versioned, ok := r.backend(bucket).(VersionedBackend)
+	if !ok {
+		if err := r.ensureBucketExists(bucket); err != nil {
+			return VersioningConfiguration{}, err
+		}
+		return VersioningConfiguration{}, nil
+	}
+	return versioned.VersioningConfiguration(bucket)
+}
+
+func (r *bucketRouter) SetVersioningConfiguration(bucket string, v VersioningConfiguration) error {
+	versioned, ok := r.backend(bucket).(VersionedBackend)
+	if !ok {
+		return ErrNotImplemented
+	}
+	return versioned.SetVersioningConfiguration(bucket, v)
+}
+
+func (r *bucketRouter) GetObjectVersion(bucketName, objectName string, versionID VersionID, rangeRequest *ObjectRangeRequest) (*Object, error) {
+	versioned, ok := r.backend(bucketName).(VersionedBackend)
+	if !ok {
+		return nil, ErrNotImplemented
+	}
+	return versioned.GetObjectVersion(bucketName, objectName, versionID, rangeRequest)
+}
+
+func (r *bucketRouter) HeadObjectVersion(bucketName, objectName string, versionID VersionID) (*Object, error) {
+	versioned, ok := r.backend(bucketName).(VersionedBackend)
+	if !ok {
+		return nil, ErrNotImplemented
+	}
+	return versioned.HeadObjectVersion(bucketName, objectName, versionID)
+}
+
+func (r *bucketRouter) DeleteObjectVersion(bucketName, objectName string, versionID VersionID) (ObjectDeleteResult, error) {
+	versioned, ok := r.backend(bucketName).(VersionedBackend)
+	if !ok {
+		return ObjectDeleteResult{}, ErrNotImplemented
+	}
+	return versioned.DeleteObjectVersion(bucketName, objectName, versionID)
+}
+
+// DeleteMultiVersions falls back to DeleteMulti for a Backend that does not
+// support versioning, as long as no versions were named.
+func (r *bucketRouter) DeleteMultiVersions(bucketName string, objects ...ObjectID) (MultiDeleteResult, error) {
+	backend := r.backend(bucketName)
+	if versioned, ok := backend.(VersionedBackend); ok {
+		return versioned.DeleteMultiVersions(bucketName, objects...)
+	}
+
+	keys := make([]string, len(objects))
+	for i, o := range objects {
+		if o.VersionID != "" {
+			return MultiDeleteResult{}, ErrNotImplemented
+		}
+		keys[i] = o.Key
+	}
+	return backend.DeleteMulti(bucketName, keys...)
+}
+
+func (r *bucketRouter) ListBucketVersions(bucketName string, prefix *Prefix, page *ListBucketVersionsPage) (*ListBucketVersionsResult, error) {
+	versioned, ok := r.backend(bucketName).(VersionedBackend)
+	if !ok {
+		return nil, ErrNotImplemented
+	}
+	return versioned.ListBucketVersions(bucketName, prefix, page)
+}
+
+func (r *bucketRouter) AbortMultipartUpload(bucketName, key string, id UploadID) error {
+	return r.multipartBackend(bucketName).AbortMultipartUpload(bucketName, key, id)
+}
+
+func (r *bucketRouter) CompleteMultipartUpload(bucketName, key string, id UploadID, req *CompleteMultipartUploadRequest) (*PutObjectResult, string, error) {
+	return r.multipartBackend(bucketName).CompleteMultipartUpload(bucketName, key, id, req)
+}
+
+func (r *bucketRouter) CreateMultipartUpload(bucketName, key string, meta map[string]string, initiated time.Time) (UploadID, error) {
+	return r.multipartBackend(bucketName).CreateMultipartUpload(bucketName, key, meta, initiated)
+}
+
+func (r *bucketRouter) ListParts(bucketName, key string, uploadID UploadID, marker int, limit int64) (*ListMultipartUploadPartsResult, error) {
+	return r.multipartBackend(bucketName).ListParts(bucketName, key, uploadID, marker, limit)
+}
+
+func (r *bucketRouter) ListMultipartUploads(bucketName string, marker *UploadListMarker, prefix Prefix, limit int64) (*ListMultipartUploadsResult, error) {
+	return r.multipartBackend(bucketName).ListMultipartUploads(bucketName, marker, prefix, limit)
+}
+
+func (r *bucketRouter) UploadPart(bucketName, key string, id UploadID, partNumber int, input io.Reader, size int64, added time.Time) (etag string, err error) {
+	return r.multipartBackend(bucketName).UploadPart(bucketName, key, id, partNumber, input, size, added)
+}
+
+// Flush flushes every Backend that implements FlushingBackend, and returns
+// the first error.
+func (r *bucketRouter) Flush() error {
+	var first error
+	flush := func(backend Backend) {
+		if flusher, ok := backend.(FlushingBackend); ok {
+			if err := flusher.Flush(); err != nil && first == nil {
+				first = err
+			}
+		}
+	}
+
+	flush(r.fallback)
+	for _, backend := range r.buckets {
+		flush(backend)
+	}
+	return first
+}
+
+// PutObjectIfMatch uses PutObject if the Backend is not a
+// ConditionalPutBackend; GoFakeS3 has already checked the condition.
+func (r *bucketRouter) PutObjectIfMatch(bucketName, key, expectedETag string, meta map[string]string, input io.Reader, size int64) (PutObjectResult, error) {
+	backend := r.backend(bucketName)
+	cas, ok := backend.(ConditionalPutBackend)
+	if !ok {
+		return backend.PutObject(bucketName, key, meta, input, size)
+	}
+	return cas.PutObjectIfMatch(bucketName, key, expectedETag, meta, input, size)
+}
+
+func (r *bucketRouter) UpdateObjectMetadata(bucketName, objectName string, meta map[string]string) error {
+	updater, ok := r.backend(bucketName).(MetadataUpdatingBackend)
+	if !ok {
+		return ErrNotImplemented
+	}
+	return updater.UpdateObjectMetadata(bucketName, objectName, meta)
+}
+
+func (r *bucketRouter) ensureBucketExists(bucket string) error {
+	exists, err := r.BucketExists(bucket)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return BucketNotFound(bucket)
+	}
+	return nil
+}
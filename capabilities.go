@@ -0,0 +1,157 @@
+package gofakes3
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync/atomic"
+)
+
+// CapabilitiesQuery is the query string key that triggers the non-standard
+// capabilities endpoint, i.e. 'GET /?gofakes3-capabilities'. It is only
+// recognised at the root of the service, where S3 lists buckets, so it can't
+// collide with a bucket or object path.
+const CapabilitiesQuery = "gofakes3-capabilities"
+
+// Capabilities is returned as JSON by the capabilities endpoint. It is not
+// part of the S3 protocol; it exists so that test harnesses can adapt to the
+// feature set of the server they are talking to.
+type Capabilities struct {
+	Operations []string            `json:"operations"`
+	Options    CapabilitiesOptions `json:"options"`
+}
+
+// CapabilitiesOptions reflects the Options GoFakeS3 was configured with.
+type CapabilitiesOptions struct {
+	Versioning bool `json:"versioning"`
+
+	// NativeMultipart is true if the Backend implements MultipartBackend
+	// itself, rather than relying on GoFakeS3's in-memory fallback.
+	NativeMultipart bool `json:"nativeMultipart"`
+
+	IntegrityCheck         bool  `json:"integrityCheck"`
+	HostBucket             bool  `json:"hostBucket"`
+	PreserveTrailingSlash  bool  `json:"preserveTrailingSlash"`
+	AutoBucket             bool  `json:"autoBucket"`
+	RequireContentLength   bool  `json:"requireContentLength"`
+	ContentTypeSniffing    bool  `json:"contentTypeSniffing"`
+	UnimplementedPageError bool  `json:"unimplementedPageError"`
+	MetadataSizeLimit      int   `json:"metadataSizeLimit"`
+	MetadataCountLimit     int   `json:"metadataCountLimit"`
+	MaxConcurrentRequests  int64 `json:"maxConcurrentRequests"`
+	UploadBandwidthLimit   int   `json:"uploadBandwidthLimit"`
+	DownloadBandwidthLimit int   `json:"downloadBandwidthLimit"`
+	TimeSkewLimitMillis    int64 `json:"timeSkewLimitMillis"`
+}
+
+var (
+	coreOperations = []string{
+		"AbortMultipartUpload",
+		"CompleteMultipartUpload",
+		"CopyObject",
+		"CreateBucket",
+		"CreateMultipartUpload",
+		"DeleteBucket",
+		"DeleteBucketAnalyticsConfiguration",
+		"DeleteBucketCors",
+		"DeleteBucketIntelligentTieringConfiguration",
+		"DeleteBucketLifecycle",
+		"DeleteBucketMetricsConfiguration",
+		"DeleteBucketOwnershipControls",
+		"DeleteObject",
+		"DeleteObjectTagging",
+		"DeleteObjects",
+		"DeletePublicAccessBlock",
+		"GetBucketAccelerateConfiguration",
+		"GetBucketAcl",
+		"GetBucketAnalyticsConfiguration",
+		"GetBucketCors",
+		"GetBucketIntelligentTieringConfiguration",
+		"GetBucketLifecycleConfiguration",
+		"GetBucketLocation",
+		"GetBucketMetricsConfiguration",
+		"GetBucketOwnershipControls",
+		"GetBucketVersioning",
+		"GetObject",
+		"GetObjectAcl",
+		"GetObjectLegalHold",
+		"GetObjectLockConfiguration",
+		"GetObjectRetention",
+		"GetObjectTagging",
+		"GetPublicAccessBlock",
+		"HeadBucket",
+		"HeadObject",
+		"ListBucketAnalyticsConfigurations",
+		"ListBucketIntelligentTieringConfigurations",
+		"ListBucketMetricsConfigurations",
+		"ListBuckets",
+		"ListMultipartUploads",
+		"ListObjects",
+		"ListObjectsV2",
+		"ListParts",
+		"PostObject",
+		"PutBucketAccelerateConfiguration",
+		"PutBucketAnalyticsConfiguration",
+		"PutBucketCors",
+		"PutBucketIntelligentTieringConfiguration",
+		"PutBucketLifecycleConfiguration",
+		"PutBucketMetricsConfiguration",
+		"PutBucketOwnershipControls",
+		"PutBucketVersioning",
+		"PutObject",
+		"PutObjectAcl",
+		"PutObjectLegalHold",
+		"PutObjectLockConfiguration",
+		"PutObjectRetention",
+		"PutObjectTagging",
+		"PutPublicAccessBlock",
+		"UploadPart",
+		"UploadPartCopy",
+	}
+
+	versionedOperations = []string{
+		"DeleteObjectVersion",
+		"GetObjectVersion",
+		"HeadObjectVersion",
+		"ListObjectVersions",
+	}
+)
+
+// Capabilities reports the operations and options supported by this
+// GoFakeS3 instance. Operations are listed by their S3 API action name.
+func (g *GoFakeS3) Capabilities() Capabilities {
+	ops := make([]string, 0, len(coreOperations)+len(versionedOperations))
+	ops = append(ops, coreOperations...)
+	if g.versioned != nil {
+		ops = append(ops, versionedOperations...)
+	}
+
+	_, fallbackMultipart := g.multipart.(*multipartBackend)
+
+	return Capabilities{
+		Operations: ops,
+		Options: CapabilitiesOptions{
+			Versioning:             g.versioned != nil,
+			NativeMultipart:        !fallbackMultipart,
+			IntegrityCheck:         g.integrityCheck,
+			HostBucket:             g.hostBucket,
+			PreserveTrailingSlash:  g.preserveTrailingSlash,
+			AutoBucket:             g.autoBucket,
+			RequireContentLength:   g.requireContentLength,
+			ContentTypeSniffing:    g.contentTypeSniffing,
+			UnimplementedPageError: g.failOnUnimplementedPage,
+			MetadataSizeLimit:      g.metadataSizeLimit,
+			MetadataCountLimit:     g.metadataCountLimit,
+			MaxConcurrentRequests:  atomic.LoadInt64(&g.maxConcurrentRequests),
+			UploadBandwidthLimit:   g.uploadBandwidth,
+			DownloadBandwidthLimit: g.downloadBandwidth,
+			TimeSkewLimitMillis:    g.timeSkew.Milliseconds(),
+		},
+	}
+}
+
+func (g *GoFakeS3) getCapabilities(w http.ResponseWriter, r *http.Request) error {
+	g.log.Print(LogInfo, "GET CAPABILITIES")
+
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(g.Capabilities())
+}
@@ -0,0 +1,57 @@
+package gofakes3_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/johannesboyne/gofakes3"
+)
+
+func TestCapabilities(t *testing.T) {
+	assertCapabilities := func(ts *testServer) gofakes3.Capabilities {
+		t.Helper()
+		rs, err := httpClient().Get(ts.url("/?" + gofakes3.CapabilitiesQuery))
+		ts.OK(err)
+		defer rs.Body.Close()
+
+		if rs.StatusCode != http.StatusOK {
+			t.Fatal("unexpected status", rs.StatusCode)
+		}
+		var caps gofakes3.Capabilities
+		ts.OK(json.NewDecoder(rs.Body).Decode(&caps))
+		return caps
+	}
+
+	hasOperation := func(caps gofakes3.Capabilities, op string) bool {
+		for _, v := range caps.Operations {
+			if v == op {
+				return true
+			}
+		}
+		return false
+	}
+
+	t.Run("default", func(t *testing.T) {
+		ts := newTestServer(t)
+		defer ts.Close()
+
+		caps := assertCapabilities(ts)
+		if !caps.Options.Versioning || !hasOperation(caps, "ListObjectVersions") {
+			t.Fatal("expected versioning capability")
+		}
+		if !hasOperation(caps, "PutObject") {
+			t.Fatal("expected PutObject capability")
+		}
+	})
+
+	t.Run("without-versioning", func(t *testing.T) {
+		ts := newTestServer(t, withFakerOptions(gofakes3.WithoutVersioning()))
+		defer ts.Close()
+
+		caps := assertCapabilities(ts)
+		if caps.Options.Versioning || hasOperation(caps, "ListObjectVersions") {
+			t.Fatal("unexpected versioning capability")
+		}
+	})
+}
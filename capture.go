@@ -0,0 +1,126 @@
+package gofakes3
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"hash"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+)
+
+// CapturedRequest describes a request as GoFakeS3 saw it, after any
+// rewriting done by options like WithHostBucket. It is passed to the function
+// given to WithRequestCapture.
+type CapturedRequest struct {
+	Method string
+
+	// Path is the unescaped request path, and RawQuery the query string as
+	// sent. Query is RawQuery decoded.
+	Path     string
+	RawQuery string
+	Query    url.Values
+
+	// Bucket and Object are empty if the request did not address one.
+	// Subresource is the name of the subresource requested in the query
+	// string, like 'tagging', or empty.
+	Bucket      string
+	Object      string
+	Subresource string
+
+	Header http.Header
+
+	// BodySize and BodySHA256 describe the request body exactly as it was
+	// received, before any 'aws-chunked' framing is removed. BodySHA256 is
+	// hex encoded, like the 'x-amz-content-sha256' header.
+	BodySize   int64
+	BodySHA256 string
+
+	// StatusCode is the status of the response GoFakeS3 sent.
+	StatusCode int
+}
+
+// captureBody hashes a request body as the handler reads it. Closing it is
+// left to captureRequest, which needs to read what the handler did not.
+type captureBody struct {
+	io.ReadCloser
+	hash hash.Hash
+	size int64
+}
+
+func (b *captureBody) Read(p []byte) (n int, err error) {
+	n, err = b.ReadCloser.Read(p)
+	b.hash.Write(p[:n])
+	b.size += int64(n)
+	return n, err
+}
+
+func (b *captureBody) Close() error { return nil }
+
+// captureResponseWriter records the status of the response.
+type captureResponseWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *captureResponseWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *captureResponseWriter) Write(p []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w.ResponseWriter.Write(p)
+}
+
+func (w *captureResponseWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// captureRequest wraps the request and response so that, once the request
+// has been handled, finish can pass a CapturedRequest to the function given
+// to WithRequestCapture.
+func (g *GoFakeS3) captureRequest(w http.ResponseWriter, r *http.Request) (cw http.ResponseWriter, finish func(bucket, object string)) {
+	if r.Body == nil {
+		r.Body = http.NoBody
+	}
+	body := &captureBody{ReadCloser: r.Body, hash: sha256.New()}
+	r.Body = body
+	crw := &captureResponseWriter{ResponseWriter: w}
+	header := r.Header.Clone()
+
+	return crw, func(bucket, object string) {
+		// Whatever the handler did not read still belongs to the request:
+		if _, err := io.Copy(ioutil.Discard, body); err != nil {
+			g.log.Print(LogWarn, "request capture failed to read body:", err)
+		}
+		body.ReadCloser.Close()
+
+		status := crw.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		subresource, _ := findSubresource(r.URL.Query())
+
+		g.requestCapture(CapturedRequest{
+			Method:      r.Method,
+			Path:        r.URL.Path,
+			RawQuery:    r.URL.RawQuery,
+			Query:       r.URL.Query(),
+			Bucket:      bucket,
+			Object:      object,
+			Subresource: subresource,
+			Header:      header,
+			BodySize:    body.size,
+			BodySHA256:  hex.EncodeToString(body.hash.Sum(nil)),
+			StatusCode:  status,
+		})
+	}
+}
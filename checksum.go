@@ -0,0 +1,164 @@
+package gofakes3
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"hash"
+	"hash/crc32"
+	"io"
+	"net/http"
+	"net/textproto"
+	"strings"
+)
+
+// Checksum headers supported by GoFakeS3. S3 also supports CRC64NVME, but the
+// standard library has no implementation of that polynomial.
+//
+// https://docs.aws.amazon.com/AmazonS3/latest/userguide/checking-object-integrity.html
+const (
+	ChecksumCRC32Header  = "X-Amz-Checksum-Crc32"
+	ChecksumCRC32CHeader = "X-Amz-Checksum-Crc32c"
+	ChecksumSHA1Header   = "X-Amz-Checksum-Sha1"
+	ChecksumSHA256Header = "X-Amz-Checksum-Sha256"
+)
+
+// ChecksumAlgorithmHeader asks for an object's checksum to be computed with
+// the named algorithm, e.g. 'CRC32', when the client does not send the
+// checksum itself.
+const ChecksumAlgorithmHeader = "X-Amz-Sdk-Checksum-Algorithm"
+
+// checksumHeaders lists the supported checksum headers, keyed by the name of
+// their algorithm.
+var checksumHeaders = map[string]string{
+	"CRC32":  ChecksumCRC32Header,
+	"CRC32C": ChecksumCRC32CHeader,
+	"SHA1":   ChecksumSHA1Header,
+	"SHA256": ChecksumSHA256Header,
+}
+
+const objectChecksumConfig = "checksum"
+
+var crc32cTable = crc32.MakeTable(crc32.Castagnoli)
+
+// newChecksumHash returns the hash.Hash for the checksum header passed in
+// 'header', which is matched case-insensitively. If the header is not a
+// supported checksum header, ok will be false.
+func newChecksumHash(header string) (h hash.Hash, ok bool) {
+	switch strings.ToLower(header) {
+	case "x-amz-checksum-crc32":
+		return crc32.NewIEEE(), true
+	case "x-amz-checksum-crc32c":
+		return crc32.New(crc32cTable), true
+	case "x-amz-checksum-sha1":
+		return sha1.New(), true
+	case "x-amz-checksum-sha256":
+		return sha256.New(), true
+	default:
+		return nil, false
+	}
+}
+
+// objectChecksum is the checksum computed for an object when it was created,
+// which GoFakeS3 stores itself as the Backend interface has no place for it.
+type objectChecksum struct {
+	header string
+	value  string
+}
+
+func (c *objectChecksum) result() *Checksum {
+	var out Checksum
+	switch c.header {
+	case ChecksumCRC32Header:
+		out.ChecksumCRC32 = c.value
+	case ChecksumCRC32CHeader:
+		out.ChecksumCRC32C = c.value
+	case ChecksumSHA1Header:
+		out.ChecksumSHA1 = c.value
+	case ChecksumSHA256Header:
+		out.ChecksumSHA256 = c.value
+	}
+	return &out
+}
+
+// requestChecksumHeader returns the checksum header a request to create an
+// object wants the object's checksum computed for: the one the request
+// carries the checksum in, either as a header or as a trailer, or the one
+// requested with the ChecksumAlgorithmHeader. It returns an empty string if
+// no checksum was asked for.
+//
+// A checksum sent as a header must be the base64 encoding of a digest of the
+// right size for its algorithm. It is checked independently of any
+// Content-MD5, which must also match if it is sent.
+func requestChecksumHeader(h http.Header) (header string, err error) {
+	for _, header := range []string{ChecksumCRC32Header, ChecksumCRC32CHeader, ChecksumSHA1Header, ChecksumSHA256Header} {
+		if value := h.Get(header); value != "" {
+			hash, _ := newChecksumHash(header)
+			if sum, err := base64.StdEncoding.DecodeString(value); err != nil || len(sum) != hash.Size() {
+				return "", ErrorMessagef(ErrInvalidRequest, "Value for %s header is invalid.", strings.ToLower(header))
+			}
+			return header, nil
+		}
+	}
+	if trailer := h.Get("X-Amz-Trailer"); trailer != "" {
+		if _, ok := newChecksumHash(trailer); ok {
+			return textproto.CanonicalMIMEHeaderKey(trailer), nil
+		}
+	}
+	if algorithm := h.Get(ChecksumAlgorithmHeader); algorithm != "" {
+		header, ok := checksumHeaders[strings.ToUpper(algorithm)]
+		if !ok {
+			return "", ErrorInvalidArgument(ChecksumAlgorithmHeader, algorithm, "Value for x-amz-sdk-checksum-algorithm header is invalid.")
+		}
+		return header, nil
+	}
+	return "", nil
+}
+
+// stripChecksumHeaders removes the checksum headers of a request from the
+// metadata stored with an object; the computed checksum is stored instead.
+func stripChecksumHeaders(meta map[string]string) {
+	delete(meta, ChecksumAlgorithmHeader)
+	for _, header := range checksumHeaders {
+		delete(meta, header)
+	}
+}
+
+// checksumReader computes the checksum named by header over everything read
+// from inner. If expected is not empty, it is checked once inner returns
+// io.EOF.
+type checksumReader struct {
+	inner    io.Reader
+	header   string
+	hash     hash.Hash
+	expected string
+}
+
+func newChecksumReader(inner io.Reader, header, expected string) *checksumReader {
+	h, _ := newChecksumHash(header)
+	return &checksumReader{inner: inner, header: header, hash: h, expected: expected}
+}
+
+func (r *checksumReader) Read(p []byte) (n int, err error) {
+	n, err = r.inner.Read(p)
+	r.hash.Write(p[:n]) // Hash.Write never returns an error.
+	if err == io.EOF && r.expected != "" && r.checksum().value != r.expected {
+		return n, ErrorMessagef(ErrBadDigest, "The %s you specified did not match the calculated checksum.", strings.ToLower(r.header))
+	}
+	return n, err
+}
+
+// checksum returns the checksum of what has been read so far.
+func (r *checksumReader) checksum() *objectChecksum {
+	return &objectChecksum{header: r.header, value: base64.StdEncoding.EncodeToString(r.hash.Sum(nil))}
+}
+
+// objectChecksum returns the checksum computed when an object version was
+// created, if one was requested.
+func (g *GoFakeS3) objectChecksum(bucket, object string, obj *Object) (*objectChecksum, bool) {
+	config, ok := g.configs.get(resourceConfigKey{bucket: bucket, object: object, version: obj.VersionID, kind: objectChecksumConfig})
+	if !ok {
+		return nil, false
+	}
+	return config.(*objectChecksum), true
+}
@@ -0,0 +1,169 @@
+package gofakes3_test
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/xml"
+	"hash"
+	"hash/crc32"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/johannesboyne/gofakes3"
+)
+
+func TestPutObjectChecksum(t *testing.T) {
+	ts := newTestServer(t)
+	defer ts.Close()
+
+	const contents = "hello world"
+	checksum := func(h hash.Hash) string {
+		h.Write([]byte(contents))
+		return base64.StdEncoding.EncodeToString(h.Sum(nil))
+	}
+
+	for _, tc := range []struct {
+		algorithm string
+		header    string
+		sum       string
+	}{
+		{"CRC32", gofakes3.ChecksumCRC32Header, checksum(crc32.NewIEEE())},
+		{"crc32c", gofakes3.ChecksumCRC32CHeader, checksum(crc32.New(crc32.MakeTable(crc32.Castagnoli)))},
+		{"SHA1", gofakes3.ChecksumSHA1Header, checksum(sha1.New())},
+		{"SHA256", gofakes3.ChecksumSHA256Header, checksum(sha256.New())},
+	} {
+		t.Run(tc.algorithm, func(t *testing.T) {
+			rs, body := ts.sendRaw("PUT", defaultBucket+"/"+tc.algorithm, []byte(contents), http.Header{
+				gofakes3.ChecksumAlgorithmHeader: {tc.algorithm},
+			})
+			if rs.StatusCode != http.StatusOK || rs.Header.Get(tc.header) != tc.sum {
+				t.Fatal("unexpected response", rs.StatusCode, rs.Header, string(body))
+			}
+
+			rs, _ = ts.sendRaw("GET", defaultBucket+"/"+tc.algorithm, nil, nil)
+			if rs.Header.Get(tc.header) != "" {
+				t.Fatal("checksum returned without checksum mode")
+			}
+			for _, method := range []string{"GET", "HEAD"} {
+				rs, _ = ts.sendRaw(method, defaultBucket+"/"+tc.algorithm, nil, http.Header{"X-Amz-Checksum-Mode": {"ENABLED"}})
+				if rs.Header.Get(tc.header) != tc.sum {
+					t.Fatal("unexpected checksum", method, rs.Header.Get(tc.header))
+				}
+			}
+			rs, _ = ts.sendRaw("GET", defaultBucket+"/"+tc.algorithm, nil, http.Header{"X-Amz-Checksum-Mode": {"DISABLED"}})
+			if rs.Header.Get(tc.header) != "" {
+				t.Fatal("checksum returned with checksum mode disabled")
+			}
+
+			// A checksum sent by the client is verified:
+			rs, body = ts.sendRaw("PUT", defaultBucket+"/"+tc.algorithm, []byte(contents), http.Header{tc.header: {tc.sum}})
+			if rs.StatusCode != http.StatusOK || rs.Header.Get(tc.header) != tc.sum {
+				t.Fatal("unexpected response", rs.StatusCode, string(body))
+			}
+			rs, body = ts.sendRaw("PUT", defaultBucket+"/"+tc.algorithm, []byte("changed"), http.Header{tc.header: {tc.sum}})
+			ts.assertRawErrorCode(rs, body, gofakes3.ErrBadDigest)
+		})
+	}
+
+	rs, body := ts.sendRaw("PUT", defaultBucket+"/bad", []byte(contents), http.Header{
+		gofakes3.ChecksumAlgorithmHeader: {"MD5"},
+	})
+	ts.assertRawErrorCode(rs, body, gofakes3.ErrInvalidArgument)
+
+	// Nothing is returned for an object uploaded without a checksum:
+	ts.backendPutString(defaultBucket, "plain", nil, contents)
+	rs, _ = ts.sendRaw("GET", defaultBucket+"/plain", nil, http.Header{"X-Amz-Checksum-Mode": {"ENABLED"}})
+	for k := range rs.Header {
+		if strings.HasPrefix(k, "X-Amz-Checksum-") {
+			t.Fatal("unexpected checksum header", k)
+		}
+	}
+}
+
+// An upload carrying both a Content-MD5 and a checksum must match both, and
+// keeps both once stored.
+func TestPutObjectChecksumAndContentMD5(t *testing.T) {
+	ts := newTestServer(t)
+	defer ts.Close()
+
+	const contents = "hello world"
+	crc := crc32.NewIEEE()
+	crc.Write([]byte(contents))
+	sum := base64.StdEncoding.EncodeToString(crc.Sum(nil))
+	md5 := hashMD5Bytes([]byte(contents))
+
+	put := func(key, md5, crc string) (*http.Response, []byte) {
+		t.Helper()
+		return ts.sendRaw("PUT", defaultBucket+"/"+key, []byte(contents), http.Header{
+			"Content-Md5":                {md5},
+			gofakes3.ChecksumCRC32Header: {crc},
+		})
+	}
+
+	rs, body := put("both", md5.Base64(), sum)
+	if rs.StatusCode != http.StatusOK || rs.Header.Get("ETag") != `"`+md5.Hex()+`"` || rs.Header.Get(gofakes3.ChecksumCRC32Header) != sum {
+		t.Fatal("unexpected response", rs.StatusCode, rs.Header, string(body))
+	}
+	rs, _ = ts.sendRaw("HEAD", defaultBucket+"/both", nil, http.Header{"X-Amz-Checksum-Mode": {"ENABLED"}})
+	if rs.Header.Get("ETag") != `"`+md5.Hex()+`"` || rs.Header.Get(gofakes3.ChecksumCRC32Header) != sum {
+		t.Fatal("unexpected HEAD response", rs.Header)
+	}
+
+	// A correct Content-MD5 does not excuse a wrong checksum, or vice versa:
+	rs, body = put("badcrc", md5.Base64(), "AAAAAA==")
+	ts.assertRawErrorCode(rs, body, gofakes3.ErrBadDigest)
+	rs, body = put("badmd5", hashMD5Bytes([]byte("changed")).Base64(), sum)
+	ts.assertRawErrorCode(rs, body, gofakes3.ErrBadDigest)
+	for _, key := range []string{"badcrc", "badmd5"} {
+		if ts.backendObjectExists(defaultBucket, key) {
+			t.Fatal("object stored despite bad digest", key)
+		}
+	}
+
+	// A checksum that can't be the algorithm's digest is rejected up front:
+	rs, body = put("malformed", md5.Base64(), "not-a-crc")
+	ts.assertRawErrorCode(rs, body, gofakes3.ErrInvalidRequest)
+}
+
+func TestGetObjectAttributes(t *testing.T) {
+	ts := newTestServer(t)
+	defer ts.Close()
+
+	rs, body := ts.sendRaw("PUT", defaultBucket+"/obj", []byte("hello"), http.Header{
+		gofakes3.ChecksumAlgorithmHeader: {"CRC32"},
+		"X-Amz-Storage-Class":            {"STANDARD_IA"},
+	})
+	if rs.StatusCode != http.StatusOK {
+		t.Fatal("unexpected status", rs.StatusCode, string(body))
+	}
+	sum := rs.Header.Get(gofakes3.ChecksumCRC32Header)
+
+	getAttributes := func(attributes string) *gofakes3.GetObjectAttributesResult {
+		t.Helper()
+		rs, body := ts.sendRaw("GET", defaultBucket+"/obj?attributes", nil, http.Header{gofakes3.ObjectAttributesHeader: {attributes}})
+		if rs.StatusCode != http.StatusOK {
+			t.Fatal("unexpected status", rs.StatusCode, string(body))
+		}
+		var out gofakes3.GetObjectAttributesResult
+		ts.OK(xml.Unmarshal(body, &out))
+		return &out
+	}
+
+	out := getAttributes("ETag, Checksum,ObjectSize,StorageClass")
+	if out.ETag != "5d41402abc4b2a76b9719d911017c592" || out.Checksum == nil || out.Checksum.ChecksumCRC32 != sum ||
+		out.ObjectSize == nil || *out.ObjectSize != 5 || out.StorageClass != "STANDARD_IA" {
+		t.Fatalf("unexpected attributes %+v", out)
+	}
+
+	out = getAttributes("ObjectSize")
+	if out.ETag != "" || out.Checksum != nil || out.ObjectSize == nil {
+		t.Fatalf("unexpected attributes %+v", out)
+	}
+
+	for _, attributes := range []string{"", "Nope"} {
+		rs, body := ts.sendRaw("GET", defaultBucket+"/obj?attributes", nil, http.Header{gofakes3.ObjectAttributesHeader: {attributes}})
+		ts.assertRawErrorCode(rs, body, gofakes3.ErrInvalidArgument)
+	}
+}
@@ -1,11 +1,92 @@
 package gofakes3
 
 import (
+	"bufio"
+	"encoding/base64"
 	"fmt"
+	"hash"
 	"io"
 	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
 )
 
+// decodeStreamingBody removes the 'aws-chunked' framing from the body of a
+// streaming upload, identified by its 'x-amz-content-sha256' header, and
+// returns the decoded payload along with its size from
+// 'x-amz-decoded-content-length'. streaming is false, and body is returned
+// unchanged, for any other upload.
+func decodeStreamingBody(header http.Header, body io.Reader) (reader io.Reader, size int64, streaming bool, err error) {
+	switch header.Get("X-Amz-Content-Sha256") {
+	case "STREAMING-AWS4-HMAC-SHA256-PAYLOAD":
+		reader = newChunkedReader(body)
+
+	case "STREAMING-UNSIGNED-PAYLOAD-TRAILER", "STREAMING-AWS4-HMAC-SHA256-PAYLOAD-TRAILER":
+		if reader, err = newTrailerChunkedReader(body, header.Get("X-Amz-Trailer")); err != nil {
+			return nil, 0, true, err
+		}
+
+	default:
+		return body, 0, false, nil
+	}
+
+	decoded := header.Get("X-Amz-Decoded-Content-Length")
+	size, err = strconv.ParseInt(decoded, 10, 64)
+	if err != nil || size < 0 {
+		return nil, 0, true, ErrorMessage(ErrInvalidRequest, "Invalid x-amz-decoded-content-length: "+decoded)
+	}
+	return reader, size, true, nil
+}
+
+// checkDecodedLength wraps the decoded payload of a streaming upload so that
+// it fails with ErrIncompleteBody if it does not match its
+// 'x-amz-decoded-content-length', if WithDecodedContentLengthCheck is used.
+func (g *GoFakeS3) checkDecodedLength(reader io.Reader, size int64) io.Reader {
+	if !g.decodedLengthCheck {
+		return reader
+	}
+	return &decodedLengthReader{inner: reader, remain: size}
+}
+
+// decodedLengthReader returns ErrIncompleteBody if inner ends before remain
+// bytes have been read, or has more to give after them. It looks for extra
+// bytes as soon as the expected number have been read, so a mismatch is
+// caught even if the Backend never reads to EOF.
+type decodedLengthReader struct {
+	inner  io.Reader
+	remain int64
+}
+
+func (r *decodedLengthReader) Read(p []byte) (n int, err error) {
+	if r.remain <= 0 {
+		return 0, r.checkEOF()
+	}
+	if int64(len(p)) > r.remain {
+		p = p[:r.remain]
+	}
+	n, err = r.inner.Read(p)
+	r.remain -= int64(n)
+
+	if err == io.EOF && r.remain > 0 {
+		return n, ErrIncompleteBody
+	} else if err != nil {
+		return n, err
+	} else if r.remain == 0 {
+		return n, r.checkEOF()
+	}
+	return n, nil
+}
+
+func (r *decodedLengthReader) checkEOF() error {
+	var extra [1]byte
+	n, err := io.ReadFull(r.inner, extra[:])
+	if n > 0 {
+		return ErrIncompleteBody
+	}
+	return err
+}
+
 type chunkedReader struct {
 	inner         io.Reader
 	chunkRemain   int
@@ -69,3 +150,171 @@ func (r *chunkedReader) Read(p []byte) (n int, err error) {
 	}
 	return n, nil
 }
+
+// trailerChunkedReader decodes an 'aws-chunked' body that ends with trailing
+// headers, as sent by the SDKs when 'x-amz-content-sha256' is
+// 'STREAMING-UNSIGNED-PAYLOAD-TRAILER' or
+// 'STREAMING-AWS4-HMAC-SHA256-PAYLOAD-TRAILER':
+//
+//	<hex-size>[;chunk-signature=<sig>]\r\n
+//	<data>\r\n
+//	...
+//	0[;chunk-signature=<sig>]\r\n
+//	x-amz-checksum-crc32:<base64>\r\n
+//	[x-amz-trailer-signature:<sig>\r\n]
+//	\r\n
+//
+// If a trailer is expected, the checksum it names is computed over the decoded
+// data and validated once the final chunk has been read. Signatures are not
+// verified.
+//
+// https://docs.aws.amazon.com/AmazonS3/latest/API/sigv4-streaming-trailers.html
+type trailerChunkedReader struct {
+	inner       *bufio.Reader
+	chunkRemain int64
+	done        bool
+
+	trailer  string
+	hash     hash.Hash
+	trailers map[string]string
+}
+
+func newTrailerChunkedReader(inner io.Reader, trailer string) (*trailerChunkedReader, error) {
+	r := &trailerChunkedReader{
+		inner:    bufio.NewReader(inner),
+		trailers: map[string]string{},
+	}
+	if trailer != "" {
+		h, ok := newChecksumHash(trailer)
+		if !ok {
+			return nil, ErrorInvalidArgument("x-amz-trailer", trailer, "The value specified in the x-amz-trailer header is not supported")
+		}
+		r.trailer = strings.ToLower(trailer)
+		r.hash = h
+	}
+	return r, nil
+}
+
+// Trailers returns the trailing headers, keyed by their lowercased name. It
+// is only populated once the reader has returned io.EOF.
+func (r *trailerChunkedReader) Trailers() map[string]string {
+	return r.trailers
+}
+
+func (r *trailerChunkedReader) Read(p []byte) (n int, err error) {
+	for n < len(p) {
+		if r.done {
+			return n, io.EOF
+		}
+
+		if r.chunkRemain == 0 {
+			if err := r.nextChunk(); err != nil {
+				return n, err
+			}
+			continue
+		}
+
+		want := int64(len(p) - n)
+		if want > r.chunkRemain {
+			want = r.chunkRemain
+		}
+
+		innerN, err := r.inner.Read(p[n : n+int(want)])
+		if r.hash != nil {
+			r.hash.Write(p[n : n+innerN])
+		}
+		n += innerN
+		r.chunkRemain -= int64(innerN)
+
+		if r.chunkRemain == 0 {
+			if err := r.expectCRLF(); err != nil {
+				return n, err
+			}
+		}
+		if err != nil {
+			if err == io.EOF {
+				err = ErrIncompleteBody
+			}
+			return n, err
+		}
+	}
+	return n, nil
+}
+
+func (r *trailerChunkedReader) nextChunk() error {
+	line, err := r.readLine()
+	if err != nil {
+		return err
+	}
+	if idx := strings.IndexByte(line, ';'); idx >= 0 {
+		line = line[:idx]
+	}
+	size, err := strconv.ParseInt(strings.TrimSpace(line), 16, 64)
+	if err != nil || size < 0 {
+		return ErrIncompleteBody
+	}
+	if size > 0 {
+		r.chunkRemain = size
+		return nil
+	}
+
+	r.done = true
+	return r.readTrailers()
+}
+
+func (r *trailerChunkedReader) readTrailers() error {
+	for {
+		line, err := r.readLine()
+		if err == io.EOF && line == "" {
+			break
+		} else if err != nil {
+			return err
+		}
+		if line == "" {
+			break
+		}
+		idx := strings.IndexByte(line, ':')
+		if idx < 0 {
+			return ErrIncompleteBody
+		}
+		r.trailers[strings.ToLower(strings.TrimSpace(line[:idx]))] = strings.TrimSpace(line[idx+1:])
+	}
+
+	if r.hash == nil {
+		return nil
+	}
+
+	expected, ok := r.trailers[r.trailer]
+	if !ok {
+		return ErrIncompleteBody
+	}
+	if base64.StdEncoding.EncodeToString(r.hash.Sum(nil)) != expected {
+		return ErrorMessagef(ErrBadDigest, "The %s you specified did not match the calculated checksum.", r.trailer)
+	}
+	return nil
+}
+
+func (r *trailerChunkedReader) expectCRLF() error {
+	line, err := r.readLine()
+	if err != nil {
+		return err
+	}
+	if line != "" {
+		return ErrIncompleteBody
+	}
+	return nil
+}
+
+func (r *trailerChunkedReader) readLine() (string, error) {
+	line, err := r.inner.ReadString('\n')
+	if err != nil {
+		if err == io.EOF && line == "" {
+			return "", io.EOF
+		}
+		if err == io.EOF {
+			return "", ErrIncompleteBody
+		}
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
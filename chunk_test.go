@@ -1,8 +1,10 @@
 package gofakes3
 
 import (
+	"encoding/base64"
 	"errors"
 	"github.com/stretchr/testify/assert"
+	"hash/crc32"
 	"io"
 	"io/ioutil"
 	"strings"
@@ -114,3 +116,45 @@ func TestChunkedUploadFail(t *testing.T) {
 	assert.Equal(t, 0, n)
 
 }
+
+func TestTrailerChunkedUploadSuccess(t *testing.T) {
+	body := strings.Repeat("a", 65536) + strings.Repeat("b", 1024)
+
+	crc := crc32.NewIEEE()
+	crc.Write([]byte(body))
+	sum := base64.StdEncoding.EncodeToString(crc.Sum(nil))
+
+	payload := "10000\r\n" + body[:65536] + "\r\n"
+	payload += "400\r\n" + body[65536:] + "\r\n"
+	payload += "0\r\n"
+	payload += "x-amz-checksum-crc32:" + sum + "\r\n"
+	payload += "\r\n"
+
+	rdr, err := newTrailerChunkedReader(strings.NewReader(payload), "x-amz-checksum-crc32")
+	assert.Equal(t, nil, err)
+	buf, err := ioutil.ReadAll(rdr)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, body, string(buf))
+	assert.Equal(t, sum, rdr.Trailers()["x-amz-checksum-crc32"])
+}
+
+func TestTrailerChunkedUploadBadChecksum(t *testing.T) {
+	payload := "5\r\nhello\r\n0\r\nx-amz-checksum-crc32:AAAAAA==\r\n\r\n"
+
+	rdr, err := newTrailerChunkedReader(strings.NewReader(payload), "x-amz-checksum-crc32")
+	assert.Equal(t, nil, err)
+	_, err = ioutil.ReadAll(rdr)
+	assert.True(t, HasErrorCode(err, ErrBadDigest))
+}
+
+func TestTrailerChunkedUploadTruncated(t *testing.T) {
+	rdr, err := newTrailerChunkedReader(strings.NewReader("10\r\nhello"), "")
+	assert.Equal(t, nil, err)
+	_, err = ioutil.ReadAll(rdr)
+	assert.Equal(t, ErrIncompleteBody, err)
+}
+
+func TestTrailerChunkedUnsupportedTrailer(t *testing.T) {
+	_, err := newTrailerChunkedReader(strings.NewReader(""), "x-amz-checksum-nope")
+	assert.True(t, HasErrorCode(err, ErrInvalidArgument))
+}
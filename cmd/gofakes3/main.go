@@ -32,6 +32,7 @@ type fakeS3Flags struct {
 	host          string
 	backendKind   string
 	initialBucket string
+	initialDir    string
 	fixedTimeStr  string
 	noIntegrity   bool
 	hostBucket    bool
@@ -47,12 +48,14 @@ type fakeS3Flags struct {
 
 	debugCPU  string
 	debugHost string
+	adminHost string
 }
 
 func (f *fakeS3Flags) attach(flagSet *flag.FlagSet) {
 	flagSet.StringVar(&f.host, "host", ":9000", "Host to run the service")
 	flagSet.StringVar(&f.fixedTimeStr, "time", "", "RFC3339 format. If passed, the server's clock will always see this time; does not affect existing stored dates.")
 	flagSet.StringVar(&f.initialBucket, "initialbucket", "", "If passed, this bucket will be created on startup if it does not already exist.")
+	flagSet.StringVar(&f.initialDir, "initialdir", "", "If passed, every file under this directory is loaded into -initialbucket on startup, keyed by its relative path.")
 	flagSet.BoolVar(&f.noIntegrity, "no-integrity", false, "Pass this flag to disable Content-MD5 validation when uploading.")
 	flagSet.BoolVar(&f.hostBucket, "hostbucket", false, "If passed, the bucket name will be extracted from the first segment of the hostname, rather than the first part of the URL path.")
 	flagSet.BoolVar(&f.autoBucket, "autobucket", false, "If passed, nonexistent buckets will be created on first use instead of raising an error")
@@ -72,6 +75,7 @@ func (f *fakeS3Flags) attach(flagSet *flag.FlagSet) {
 	// Debugging:
 	flagSet.StringVar(&f.debugHost, "debug.host", "", "Run the debug server on this host")
 	flagSet.StringVar(&f.debugCPU, "debug.cpu", "", "Create CPU profile in this file")
+	flagSet.StringVar(&f.adminHost, "admin.host", "", "Run an HTML interface for browsing the backend on this host")
 
 	// Deprecated:
 	flagSet.StringVar(&f.boltDb, "db", "locals3.db", "Deprecated; use -bolt.db")
@@ -224,6 +228,16 @@ func run() error {
 		log.Println("created -initialbucket", values.initialBucket)
 	}
 
+	if values.initialDir != "" {
+		if values.initialBucket == "" {
+			return fmt.Errorf("gofakes3: -initialdir requires -initialbucket")
+		}
+		if err := gofakes3.LoadDir(backend, values.initialBucket, values.initialDir); err != nil {
+			return fmt.Errorf("gofakes3: could not load -initialdir %q: %v", values.initialDir, err)
+		}
+		log.Println("loaded -initialdir", values.initialDir, "into", values.initialBucket)
+	}
+
 	logger := gofakes3.GlobalLog()
 	if values.quiet {
 		logger = gofakes3.DiscardLog()
@@ -238,6 +252,15 @@ func run() error {
 		gofakes3.WithAutoBucket(values.autoBucket),
 	)
 
+	if values.adminHost != "" {
+		log.Println("starting admin server at", fmt.Sprintf("http://%s/", values.adminHost))
+		go func() {
+			if err := http.ListenAndServe(values.adminHost, faker.AdminHandler()); err != nil {
+				log.Fatal(err)
+			}
+		}()
+	}
+
 	return listenAndServe(values.host, faker.Server())
 }
 
@@ -0,0 +1,110 @@
+package gofakes3
+
+import (
+	"net/http"
+	"strings"
+	"time"
+)
+
+// conditionalRequest holds the conditional headers of a request, which
+// check evaluates against the current object at its key.
+type conditionalRequest struct {
+	ifMatch, ifNoneMatch string
+
+	// ifModifiedSince and ifUnmodifiedSince are zero if the header was not
+	// sent, or is not a valid HTTP date, in which case it is ignored. Like S3,
+	// an If-Modified-Since later than now is also ignored, as RFC 7232
+	// requires, rather than treating every object as not modified.
+	ifModifiedSince, ifUnmodifiedSince time.Time
+}
+
+func parseConditionalRequest(h http.Header, now time.Time) conditionalRequest {
+	c := conditionalRequest{
+		ifMatch:     h.Get("If-Match"),
+		ifNoneMatch: h.Get("If-None-Match"),
+	}
+	c.ifModifiedSince, _ = http.ParseTime(h.Get("If-Modified-Since"))
+	if c.ifModifiedSince.After(now) {
+		c.ifModifiedSince = time.Time{}
+	}
+	c.ifUnmodifiedSince, _ = http.ParseTime(h.Get("If-Unmodified-Since"))
+	return c
+}
+
+// check returns the error S3 answers a request with the given method with if
+// its conditions do not hold for the current object, which has the given
+// ETag and modification time if it exists. The conditions are evaluated in
+// the order S3 documents:
+//
+// For GET and HEAD, If-Match is checked first, and if it is sent,
+// If-Unmodified-Since is ignored; either fails with '412 Precondition
+// Failed'. Then If-None-Match, which if sent means that If-Modified-Since is
+// ignored; either yields '304 Not Modified'. If-Match compares ETags
+// strongly, and If-None-Match weakly, as RFC 7232 requires.
+//
+// For PUT and POST, only If-Match and 'If-None-Match: *' are supported, as
+// parseWriteCondition ensures. An If-Match write fails with NoSuchKey if
+// there is no object, and a 'If-None-Match: *' one with '412 Precondition
+// Failed' if there is.
+//
+// For DELETE, only If-Match is supported. As deletes are idempotent, one
+// for a key with no object succeeds.
+//
+// https://docs.aws.amazon.com/AmazonS3/latest/userguide/conditional-requests.html
+// https://www.rfc-editor.org/rfc/rfc7232#section-3.3
+// https://www.rfc-editor.org/rfc/rfc7232#section-6
+func (c conditionalRequest) check(method string, exists bool, etag string, modified time.Time) error {
+	switch method {
+	case http.MethodGet, http.MethodHead:
+		if c.ifMatch != "" {
+			if !etagListMatch(c.ifMatch, etag, false) {
+				return ErrPreconditionFailed
+			}
+		} else if !c.ifUnmodifiedSince.IsZero() && !modified.IsZero() && modified.Truncate(time.Second).After(c.ifUnmodifiedSince) {
+			return ErrPreconditionFailed
+		}
+
+		if c.ifNoneMatch != "" {
+			if etagListMatch(c.ifNoneMatch, etag, true) {
+				return ErrNotModified
+			}
+		} else if !c.ifModifiedSince.IsZero() && !modified.IsZero() && !modified.Truncate(time.Second).After(c.ifModifiedSince) {
+			return ErrNotModified
+		}
+
+	case http.MethodPut, http.MethodPost:
+		switch {
+		case c.ifNoneMatch == "*" && exists:
+			return ErrPreconditionFailed
+		case c.ifMatch != "" && !exists:
+			return ErrNoSuchKey
+		case c.ifMatch != "" && !etagListMatch(c.ifMatch, etag, false):
+			return ErrPreconditionFailed
+		}
+
+	case http.MethodDelete:
+		if c.ifMatch != "" && exists && !etagListMatch(c.ifMatch, etag, false) {
+			return ErrPreconditionFailed
+		}
+	}
+	return nil
+}
+
+// etagListMatch reports whether etag matches any of the comma separated
+// ETags in an If-Match or If-None-Match header, or if the header is '*'.
+// Unless weak is set, weak ETags never match; see weakETagMatch. Like S3,
+// the ETags in the header need not be quoted.
+func etagListMatch(list, etag string, weak bool) bool {
+	for _, candidate := range strings.Split(list, ",") {
+		candidate = strings.TrimSpace(candidate)
+		switch {
+		case candidate == "*":
+			return true
+		case weak && weakETagMatch(candidate, etag):
+			return true
+		case !weak && !isWeakETag(candidate) && !isWeakETag(etag) && candidate != "" && quoteETag(candidate) == quoteETag(etag):
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,132 @@
+package gofakes3_test
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+)
+
+const helloETagValue = `"5d41402abc4b2a76b9719d911017c592"`
+
+func TestConditionalRead(t *testing.T) {
+	ts := newTestServer(t)
+	defer ts.Close()
+
+	// Objects put straight into the Backend have no Last-Modified:
+	rs, body := ts.sendRaw("PUT", defaultBucket+"/object", []byte("hello"), nil)
+	if rs.StatusCode != http.StatusOK {
+		t.Fatal("unexpected status", rs.StatusCode, string(body))
+	}
+
+	modified := defaultDate.Format(http.TimeFormat)
+	before := defaultDate.Add(-time.Hour).Format(http.TimeFormat)
+	after := defaultDate.Add(time.Hour).Format(http.TimeFormat)
+	future := defaultDate.Add(3 * time.Hour).Format(http.TimeFormat)
+	ts.Advance(2 * time.Hour)
+
+	for idx, tc := range []struct {
+		header http.Header
+		status int
+	}{
+		{http.Header{}, http.StatusOK},
+
+		// If-Match, strongly compared, takes precedence over
+		// If-Unmodified-Since:
+		{http.Header{"If-Match": {helloETagValue}}, http.StatusOK},
+		{http.Header{"If-Match": {"5d41402abc4b2a76b9719d911017c592"}}, http.StatusOK},
+		{http.Header{"If-Match": {`"nope"`}}, http.StatusPreconditionFailed},
+		{http.Header{"If-Match": {`"nope", ` + helloETagValue}}, http.StatusOK},
+		{http.Header{"If-Match": {"*"}}, http.StatusOK},
+		{http.Header{"If-Match": {"W/" + helloETagValue}}, http.StatusPreconditionFailed},
+		{http.Header{"If-Unmodified-Since": {after}}, http.StatusOK},
+		{http.Header{"If-Unmodified-Since": {modified}}, http.StatusOK},
+		{http.Header{"If-Unmodified-Since": {before}}, http.StatusPreconditionFailed},
+		{http.Header{"If-Match": {helloETagValue}, "If-Unmodified-Since": {before}}, http.StatusOK},
+		{http.Header{"If-Match": {`"nope"`}, "If-Unmodified-Since": {after}}, http.StatusPreconditionFailed},
+
+		// If-None-Match, weakly compared, takes precedence over
+		// If-Modified-Since:
+		{http.Header{"If-None-Match": {helloETagValue}}, http.StatusNotModified},
+		{http.Header{"If-None-Match": {"W/" + helloETagValue}}, http.StatusNotModified},
+		{http.Header{"If-None-Match": {`"nope", ` + helloETagValue}}, http.StatusNotModified},
+		{http.Header{"If-None-Match": {"*"}}, http.StatusNotModified},
+		{http.Header{"If-None-Match": {`"nope"`}}, http.StatusOK},
+		{http.Header{"If-Modified-Since": {before}}, http.StatusOK},
+		{http.Header{"If-Modified-Since": {modified}}, http.StatusNotModified},
+		{http.Header{"If-Modified-Since": {after}}, http.StatusNotModified},
+		{http.Header{"If-Modified-Since": {"not a date"}}, http.StatusOK},
+		// A date later than the server's time is ignored, as RFC 7232 requires:
+		{http.Header{"If-Modified-Since": {future}}, http.StatusOK},
+		{http.Header{"If-None-Match": {helloETagValue}, "If-Modified-Since": {future}}, http.StatusNotModified},
+		{http.Header{"If-None-Match": {`"nope"`}, "If-Modified-Since": {after}}, http.StatusOK},
+		{http.Header{"If-None-Match": {helloETagValue}, "If-Modified-Since": {before}}, http.StatusNotModified},
+
+		// A failed precondition is reported before a match:
+		{http.Header{"If-Match": {`"nope"`}, "If-None-Match": {helloETagValue}}, http.StatusPreconditionFailed},
+		{http.Header{"If-Unmodified-Since": {before}, "If-Modified-Since": {after}}, http.StatusPreconditionFailed},
+		{http.Header{"If-Match": {helloETagValue}, "If-None-Match": {helloETagValue}}, http.StatusNotModified},
+		{http.Header{"If-Match": {helloETagValue}, "If-Modified-Since": {after}}, http.StatusNotModified},
+		{http.Header{"If-Unmodified-Since": {after}, "If-None-Match": {`"nope"`}}, http.StatusOK},
+	} {
+		for _, method := range []string{"GET", "HEAD"} {
+			t.Run(fmt.Sprintf("%d/%s", idx, method), func(t *testing.T) {
+				rs, body := ts.sendRaw(method, defaultBucket+"/object", nil, tc.header)
+				if rs.StatusCode != tc.status {
+					t.Fatal(tc.header, "expected status", tc.status, "found", rs.StatusCode, string(body))
+				}
+			})
+		}
+	}
+}
+
+func TestConditionalWrite(t *testing.T) {
+	ts := newTestServer(t)
+	defer ts.Close()
+	before := defaultDate.Add(-time.Hour).Format(http.TimeFormat)
+
+	for idx, tc := range []struct {
+		method string
+		exists bool
+		header http.Header
+		status int
+	}{
+		{"PUT", true, http.Header{"If-None-Match": {"*"}}, http.StatusPreconditionFailed},
+		{"PUT", false, http.Header{"If-None-Match": {"*"}}, http.StatusOK},
+		{"PUT", true, http.Header{"If-Match": {helloETagValue}}, http.StatusOK},
+		{"PUT", true, http.Header{"If-Match": {`"nope"`}}, http.StatusPreconditionFailed},
+		{"PUT", false, http.Header{"If-Match": {helloETagValue}}, http.StatusNotFound},
+		{"PUT", true, http.Header{"If-Match": {helloETagValue}, "If-None-Match": {"*"}}, http.StatusNotImplemented},
+
+		// Writes ignore the date conditions, as S3 does:
+		{"PUT", true, http.Header{"If-Unmodified-Since": {before}}, http.StatusOK},
+		{"PUT", true, http.Header{"If-Match": {helloETagValue}, "If-Unmodified-Since": {before}}, http.StatusOK},
+
+		{"DELETE", true, http.Header{"If-Match": {helloETagValue}}, http.StatusNoContent},
+		{"DELETE", true, http.Header{"If-Match": {"*"}}, http.StatusNoContent},
+		{"DELETE", true, http.Header{"If-Match": {`"nope"`}}, http.StatusPreconditionFailed},
+		{"DELETE", false, http.Header{"If-Match": {helloETagValue}}, http.StatusNoContent},
+		{"DELETE", true, http.Header{"If-None-Match": {helloETagValue}}, http.StatusNoContent},
+	} {
+		t.Run(fmt.Sprintf("%d/%s", idx, tc.method), func(t *testing.T) {
+			key := fmt.Sprintf("object-%d", idx)
+			if tc.exists {
+				ts.backendPutString(defaultBucket, key, nil, "hello")
+			}
+
+			var body []byte
+			if tc.method == "PUT" {
+				body = []byte("world")
+			}
+			rs, rsBody := ts.sendRaw(tc.method, defaultBucket+"/"+key, body, tc.header)
+			if rs.StatusCode != tc.status {
+				t.Fatal(tc.header, "expected status", tc.status, "found", rs.StatusCode, string(rsBody))
+			}
+
+			// A failed condition leaves the object as it was:
+			if rs.StatusCode >= 400 && tc.exists {
+				ts.assertObject(defaultBucket, key, nil, "hello")
+			}
+		})
+	}
+}
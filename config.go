@@ -0,0 +1,121 @@
+package gofakes3
+
+import (
+	"encoding/base64"
+	"sort"
+	"sync"
+)
+
+// resourceConfigKey identifies a configuration document attached to a bucket
+// or an object via a subresource, like '?retention'. Bucket configuration has
+// an empty object name. Subresources that hold several configurations, like
+// '?analytics', tell them apart by id.
+type resourceConfigKey struct {
+	bucket  string
+	object  string
+	version VersionID
+	kind    string
+	id      string
+}
+
+// resourceConfigs stores configuration documents for subresources that the
+// Backend interface knows nothing about. They are held in memory by GoFakeS3
+// itself, so they work with any Backend, but they do not outlive the
+// GoFakeS3 instance.
+type resourceConfigs struct {
+	mu      sync.Mutex
+	configs map[resourceConfigKey]interface{}
+}
+
+func newResourceConfigs() *resourceConfigs {
+	return &resourceConfigs{configs: map[resourceConfigKey]interface{}{}}
+}
+
+func (rc *resourceConfigs) get(key resourceConfigKey) (v interface{}, ok bool) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	v, ok = rc.configs[key]
+	return v, ok
+}
+
+func (rc *resourceConfigs) put(key resourceConfigKey, v interface{}) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	rc.configs[key] = v
+}
+
+func (rc *resourceConfigs) delete(key resourceConfigKey) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	delete(rc.configs, key)
+}
+
+// listIDs returns the ids of every configuration of the given kind attached
+// to a bucket, in sorted order.
+func (rc *resourceConfigs) listIDs(bucket, kind string) []string {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	var ids []string
+	for key := range rc.configs {
+		if key.bucket == bucket && key.object == "" && key.kind == kind {
+			ids = append(ids, key.id)
+		}
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// hasKind reports whether any configuration of the given kind is attached to
+// the bucket or to any of the objects it contains.
+func (rc *resourceConfigs) hasKind(bucket, kind string) bool {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	for key := range rc.configs {
+		if key.bucket == bucket && key.kind == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// deleteObject removes every configuration document attached to a single
+// version of an object.
+func (rc *resourceConfigs) deleteObject(bucket, object string, version VersionID) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	for key := range rc.configs {
+		if key.bucket == bucket && key.object == object && key.version == version {
+			delete(rc.configs, key)
+		}
+	}
+}
+
+// deleteBucket removes every configuration document attached to a bucket or
+// to any of the objects it contains.
+func (rc *resourceConfigs) deleteBucket(bucket string) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	for key := range rc.configs {
+		if key.bucket == bucket {
+			delete(rc.configs, key)
+		}
+	}
+}
+
+// pageIDs returns the page of ids that starts at the continuation token, as
+// returned by a previous call in next. next is empty if this is the last
+// page. Like the list bucket continuation tokens, these are just the id that
+// begins the next page in disguise.
+func pageIDs(ids []string, token string, limit int) (page []string, next string, err error) {
+	if token != "" {
+		start, err := base64.URLEncoding.DecodeString(token)
+		if err != nil {
+			return nil, "", ErrInvalidToken
+		}
+		ids = ids[sort.SearchStrings(ids, string(start)):]
+	}
+	if len(ids) > limit {
+		return ids[:limit], base64.URLEncoding.EncodeToString([]byte(ids[limit])), nil
+	}
+	return ids, "", nil
+}
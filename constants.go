@@ -8,6 +8,14 @@ const (
 	//	encoding is at most 1024 bytes long."
 	KeySizeLimit = 1024
 
+	// From https://docs.aws.amazon.com/AmazonS3/latest/API/API_CopyObject.html:
+	//	"You create a copy of your object up to 5 GB in size in a single atomic
+	//	action using this API. However, to copy an object greater than 5 GB, you
+	//	must use the multipart upload Upload Part - Copy (UploadPartCopy) API."
+	//
+	// Unlike the metadata limit, S3's error message confirms this is GiB.
+	CopySourceSizeLimit = 5 * 1024 * 1024 * 1024
+
 	// From https://docs.aws.amazon.com/AmazonS3/latest/dev/UsingMetadata.html:
 	//	Within the PUT request header, the user-defined metadata is limited to 2
 	// 	KB in size. The size of user-defined metadata is measured by taking the
@@ -36,6 +44,10 @@ const (
 	MaxBucketKeys        = 1000
 	DefaultMaxBucketKeys = 1000
 
+	// ListBuckets pages hold at most MaxBuckets buckets, which is also the
+	// default page size.
+	MaxBuckets = 10000
+
 	MaxBucketVersionKeys        = 1000
 	DefaultMaxBucketVersionKeys = 1000
 
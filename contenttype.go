@@ -0,0 +1,41 @@
+package gofakes3
+
+import (
+	"bufio"
+	"io"
+	"mime"
+	"net/http"
+	"path"
+)
+
+// DefaultContentType is stored for objects created without a Content-Type,
+// as S3 does.
+const DefaultContentType = "binary/octet-stream"
+
+// sniffLen is the most that http.DetectContentType looks at.
+const sniffLen = 512
+
+// contentType chooses the Content-Type of an object created without one.
+// Unless WithContentTypeSniffing is used, this is always DefaultContentType.
+// Otherwise, the key's file extension is tried first, as it tells apart text
+// formats like CSS that look alike, then the contents of body, which is
+// returned wrapped so that nothing is lost. body may be nil if the contents
+// are not known yet, as for multipart uploads.
+func (g *GoFakeS3) contentType(object string, body io.Reader) (string, io.Reader) {
+	if !g.contentTypeSniffing {
+		return DefaultContentType, body
+	}
+	if byExt := mime.TypeByExtension(path.Ext(object)); byExt != "" {
+		return byExt, body
+	}
+	if body == nil {
+		return DefaultContentType, body
+	}
+
+	br := bufio.NewReaderSize(body, sniffLen)
+	head, _ := br.Peek(sniffLen) // Errors are left for the real read to find
+	if len(head) == 0 {
+		return DefaultContentType, br
+	}
+	return http.DetectContentType(head), br
+}
@@ -5,6 +5,14 @@ import (
 	"strings"
 )
 
+const bucketCORSConfig = "cors"
+
+// MaxCORSRules is the maximum number of rules in a CORSConfiguration.
+const MaxCORSRules = 100
+
+// corsMethods are the methods a CORSRule may allow.
+var corsMethods = map[string]bool{"GET": true, "PUT": true, "HEAD": true, "POST": true, "DELETE": true}
+
 var (
 	corsHeaders = []string{
 		"Accept",
@@ -12,6 +20,7 @@ var (
 		"Authorization",
 		"Content-Disposition",
 		"Content-Encoding",
+		"Content-Language",
 		"Content-Length",
 		"Content-Type",
 		"X-Amz-Date",
@@ -45,3 +54,79 @@ func (s *withCORS) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 	s.r.ServeHTTP(w, r)
 }
+
+func validateCORS(config *CORSConfiguration) error {
+	if len(config.CORSRules) == 0 {
+		return ErrMalformedXML
+	}
+	if len(config.CORSRules) > MaxCORSRules {
+		return ErrorMessage(ErrInvalidRequest, "The number of CORS rules should not exceed allowed limit of 100 rules.")
+	}
+	for _, rule := range config.CORSRules {
+		if len(rule.AllowedMethods) == 0 || len(rule.AllowedOrigins) == 0 {
+			return ErrMalformedXML
+		}
+		for _, method := range rule.AllowedMethods {
+			if !corsMethods[method] {
+				return ErrorMessagef(ErrInvalidRequest, "Found unsupported HTTP method in CORS config. Unsupported method is %s", method)
+			}
+		}
+		for _, origin := range rule.AllowedOrigins {
+			if strings.Count(origin, "*") > 1 {
+				return ErrorMessagef(ErrInvalidRequest, "AllowedOrigin \"%s\" can not have more than one wildcard.", origin)
+			}
+		}
+		for _, header := range rule.AllowedHeaders {
+			if strings.Count(header, "*") > 1 {
+				return ErrorMessagef(ErrInvalidRequest, "AllowedHeader \"%s\" can not have more than one wildcard.", header)
+			}
+		}
+	}
+	return nil
+}
+
+func (g *GoFakeS3) getBucketCORS(bucket string, w http.ResponseWriter, r *http.Request) error {
+	g.log.Print(LogInfo, "GET BUCKET CORS:", bucket)
+
+	if err := g.ensureBucketExists(bucket); err != nil {
+		return err
+	}
+
+	config, ok := g.configs.get(resourceConfigKey{bucket: bucket, kind: bucketCORSConfig})
+	if !ok {
+		return ResourceError(ErrNoSuchCORSConfiguration, bucket)
+	}
+	return g.xmlEncoder(w).Encode(config)
+}
+
+func (g *GoFakeS3) putBucketCORS(bucket string, w http.ResponseWriter, r *http.Request) error {
+	g.log.Print(LogInfo, "PUT BUCKET CORS:", bucket)
+
+	if err := g.ensureBucketExists(bucket); err != nil {
+		return err
+	}
+
+	var in CORSConfiguration
+	if err := g.xmlDecodeBody(r.Body, &in); err != nil {
+		return err
+	}
+	if err := validateCORS(&in); err != nil {
+		return err
+	}
+	in.Xmlns = "http://s3.amazonaws.com/doc/2006-03-01/"
+
+	g.configs.put(resourceConfigKey{bucket: bucket, kind: bucketCORSConfig}, &in)
+	return nil
+}
+
+func (g *GoFakeS3) deleteBucketCORS(bucket string, w http.ResponseWriter, r *http.Request) error {
+	g.log.Print(LogInfo, "DELETE BUCKET CORS:", bucket)
+
+	if err := g.ensureBucketExists(bucket); err != nil {
+		return err
+	}
+
+	g.configs.delete(resourceConfigKey{bucket: bucket, kind: bucketCORSConfig})
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
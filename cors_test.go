@@ -0,0 +1,63 @@
+package gofakes3_test
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/johannesboyne/gofakes3"
+)
+
+func TestBucketCORS(t *testing.T) {
+	ts := newTestServer(t)
+	defer ts.Close()
+	svc := ts.s3Client()
+
+	rs, body := ts.sendRaw("GET", defaultBucket+"?cors", nil, nil)
+	ts.assertRawErrorCode(rs, body, gofakes3.ErrNoSuchCORSConfiguration)
+	if rs.StatusCode != 404 {
+		t.Fatal("unexpected status", rs.StatusCode)
+	}
+
+	ts.OKAll(svc.PutBucketCors(&s3.PutBucketCorsInput{
+		Bucket: aws.String(defaultBucket),
+		CORSConfiguration: &s3.CORSConfiguration{
+			CORSRules: []*s3.CORSRule{{
+				AllowedMethods: aws.StringSlice([]string{"GET", "PUT"}),
+				AllowedOrigins: aws.StringSlice([]string{"https://*.example.com"}),
+				AllowedHeaders: aws.StringSlice([]string{"*"}),
+				ExposeHeaders:  aws.StringSlice([]string{"ETag"}),
+				MaxAgeSeconds:  aws.Int64(3000),
+			}},
+		},
+	}))
+
+	out, err := svc.GetBucketCors(&s3.GetBucketCorsInput{Bucket: aws.String(defaultBucket)})
+	ts.OK(err)
+	if len(out.CORSRules) != 1 {
+		t.Fatal("unexpected rules", out.CORSRules)
+	}
+	rule := out.CORSRules[0]
+	if len(rule.AllowedMethods) != 2 || aws.StringValue(rule.AllowedOrigins[0]) != "https://*.example.com" || aws.Int64Value(rule.MaxAgeSeconds) != 3000 {
+		t.Fatal("unexpected rule", rule)
+	}
+
+	_, err = svc.PutBucketCors(&s3.PutBucketCorsInput{
+		Bucket: aws.String(defaultBucket),
+		CORSConfiguration: &s3.CORSConfiguration{
+			CORSRules: []*s3.CORSRule{{
+				AllowedMethods: aws.StringSlice([]string{"PATCH"}),
+				AllowedOrigins: aws.StringSlice([]string{"*"}),
+			}},
+		},
+	})
+	if !hasErrorCode(err, gofakes3.ErrInvalidRequest) {
+		t.Fatal("expected ErrInvalidRequest, found", err)
+	}
+
+	ts.OKAll(svc.DeleteBucketCors(&s3.DeleteBucketCorsInput{Bucket: aws.String(defaultBucket)}))
+	_, err = svc.GetBucketCors(&s3.GetBucketCorsInput{Bucket: aws.String(defaultBucket)})
+	if !hasErrorCode(err, gofakes3.ErrNoSuchCORSConfiguration) {
+		t.Fatal("expected ErrNoSuchCORSConfiguration, found", err)
+	}
+}
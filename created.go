@@ -0,0 +1,45 @@
+package gofakes3
+
+import (
+	"net/http"
+	"time"
+)
+
+// CreatedHeader is returned by GET and HEAD object requests if
+// WithCreationTimes is passed, and gives the time the object's key was first
+// written, in the same format as 'Last-Modified'. It is not part of S3.
+const CreatedHeader = "X-Gofakes3-Created"
+
+const objectCreatedConfig = "created"
+
+// objectCreation looks up the creation time of an object that is about to be
+// written, and returns a func that records it for the version the write
+// creates. A key that does not exist yet, or whose current version is a
+// delete marker, is created by the write.
+//
+// Objects that were written to the Backend without going through GoFakeS3
+// have no creation time, and neither do versions written over them.
+func (g *GoFakeS3) objectCreation(bucket, object string) func(version VersionID) {
+	if !g.creationTimes {
+		return func(VersionID) {}
+	}
+
+	created := g.timeSource.Now()
+	if obj, err := g.storage.HeadObject(bucket, object); err == nil && !obj.IsDeleteMarker {
+		config, ok := g.configs.get(resourceConfigKey{bucket: bucket, object: object, version: obj.VersionID, kind: objectCreatedConfig})
+		if !ok {
+			return func(VersionID) {}
+		}
+		created = config.(time.Time)
+	}
+
+	return func(version VersionID) {
+		g.configs.put(resourceConfigKey{bucket: bucket, object: object, version: version, kind: objectCreatedConfig}, created)
+	}
+}
+
+func (g *GoFakeS3) writeCreatedHeader(bucket string, obj *Object, w http.ResponseWriter) {
+	if config, ok := g.configs.get(resourceConfigKey{bucket: bucket, object: obj.Name, version: obj.VersionID, kind: objectCreatedConfig}); ok {
+		w.Header().Set(CreatedHeader, formatHeaderTime(config.(time.Time)))
+	}
+}
@@ -0,0 +1,11 @@
+package gofakes3
+
+// applyBucketDefaultMetadata adds the metadata registered for the bucket with
+// WithBucketDefaultMetadata to meta, except for keys meta already has.
+func (g *GoFakeS3) applyBucketDefaultMetadata(bucket string, meta map[string]string) {
+	for k, v := range g.bucketDefaultMetadata[bucket] {
+		if _, ok := meta[k]; !ok {
+			meta[k] = v
+		}
+	}
+}
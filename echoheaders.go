@@ -0,0 +1,27 @@
+package gofakes3
+
+import (
+	"net/http"
+)
+
+// EchoHeaderPrefix is prepended to the names of the request headers
+// WithEchoHeaders copies onto responses. No S3 header starts with it.
+const EchoHeaderPrefix = "X-Gofakes3-Echo-"
+
+// echoHeadersMiddleware implements WithEchoHeaders. The headers are set
+// before the request is handled, so they are sent with errors too.
+func (g *GoFakeS3) echoHeadersMiddleware(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, rq *http.Request) {
+		hdr := w.Header()
+		for name, values := range rq.Header {
+			for _, v := range values {
+				hdr.Add(EchoHeaderPrefix+name, v)
+			}
+		}
+		if rq.Host != "" {
+			// net/http moves the 'Host' header out of rq.Header:
+			hdr.Set(EchoHeaderPrefix+"Host", rq.Host)
+		}
+		handler.ServeHTTP(w, rq)
+	})
+}
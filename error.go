@@ -15,6 +15,18 @@ import (
 const (
 	ErrNone ErrorCode = ""
 
+	// The bucket's ObjectOwnership setting is BucketOwnerEnforced, which
+	// disables ACLs.
+	ErrAccessControlListNotSupported ErrorCode = "AccessControlListNotSupported"
+
+	ErrAccessDenied ErrorCode = "AccessDenied"
+
+	// The request was signed for a different region than the bucket's; see
+	// WithBucketRegion. Presigned URLs get
+	// ErrAuthorizationQueryParametersError instead.
+	ErrAuthorizationHeaderMalformed      ErrorCode = "AuthorizationHeaderMalformed"
+	ErrAuthorizationQueryParametersError ErrorCode = "AuthorizationQueryParametersError"
+
 	// The Content-MD5 you specified did not match what we received.
 	ErrBadDigest ErrorCode = "BadDigest"
 
@@ -30,6 +42,11 @@ const (
 	// HTTP header:
 	ErrIncompleteBody ErrorCode = "IncompleteBody"
 
+	// The upload is smaller or larger than a browser-based upload's policy
+	// allows with its 'content-length-range' condition.
+	ErrEntityTooSmall ErrorCode = "EntityTooSmall"
+	ErrEntityTooLarge ErrorCode = "EntityTooLarge"
+
 	// POST requires exactly one file upload per request.
 	ErrIncorrectNumberOfFilesInPostRequest ErrorCode = "IncorrectNumberOfFilesInPostRequest"
 
@@ -42,12 +59,30 @@ const (
 
 	ErrInvalidArgument ErrorCode = "InvalidArgument"
 
+	// The policy of a browser-based upload could not be decoded.
+	ErrInvalidPolicyDocument ErrorCode = "InvalidPolicyDocument"
+
+	// A bucket ACL was requested when creating a bucket with the
+	// BucketOwnerEnforced ObjectOwnership setting.
+	ErrInvalidBucketAclWithObjectOwnership ErrorCode = "InvalidBucketAclWithObjectOwnership"
+
 	// https://docs.aws.amazon.com/AmazonS3/latest/dev/BucketRestrictions.html#bucketnamingrules
 	ErrInvalidBucketName ErrorCode = "InvalidBucketName"
 
 	// The Content-MD5 you specified is not valid.
 	ErrInvalidDigest ErrorCode = "InvalidDigest"
 
+	// The LocationConstraint of a new bucket is not one of the regions passed
+	// to WithAllowedRegions.
+	ErrInvalidLocationConstraint ErrorCode = "InvalidLocationConstraint"
+
+	// The access key ID a request was signed with is not one of those passed
+	// to WithSignatureVerification.
+	ErrInvalidAccessKeyId ErrorCode = "InvalidAccessKeyId"
+
+	// The tag provided was not a valid tag, or the tag set is too large.
+	ErrInvalidTag ErrorCode = "InvalidTag"
+
 	ErrInvalidRange         ErrorCode = "InvalidRange"
 	ErrInvalidToken         ErrorCode = "InvalidToken"
 	ErrKeyTooLong           ErrorCode = "KeyTooLongError" // This is not a typo: Error is part of the string, but redundant in the constant name
@@ -62,8 +97,15 @@ const (
 	// specified in order by part number.
 	ErrInvalidPartOrder ErrorCode = "InvalidPartOrder"
 
+	// The part requested with the partNumber parameter does not exist.
+	ErrInvalidPartNumber ErrorCode = "InvalidPartNumber"
+
 	ErrInvalidURI ErrorCode = "InvalidURI"
 
+	// The ACL you provided was not well-formed or did not validate against
+	// our published schema.
+	ErrMalformedACLError ErrorCode = "MalformedACLError"
+
 	ErrMetadataTooLarge ErrorCode = "MetadataTooLarge"
 	ErrMethodNotAllowed ErrorCode = "MethodNotAllowed"
 	ErrMalformedXML     ErrorCode = "MalformedXML"
@@ -83,9 +125,59 @@ const (
 
 	ErrNoSuchVersion ErrorCode = "NoSuchVersion"
 
+	// The specified object does not have an Object Lock retention or legal
+	// hold configuration.
+	ErrNoSuchObjectLockConfiguration ErrorCode = "NoSuchObjectLockConfiguration"
+
+	// The bucket does not have a PublicAccessBlockConfiguration.
+	ErrNoSuchPublicAccessBlockConfiguration ErrorCode = "NoSuchPublicAccessBlockConfiguration"
+
+	// The request is not valid, for a reason given in the message.
+	ErrInvalidRequest ErrorCode = "InvalidRequest"
+
+	// The bucket does not have a CORSConfiguration.
+	ErrNoSuchCORSConfiguration ErrorCode = "NoSuchCORSConfiguration"
+
+	// The bucket does not have a LifecycleConfiguration.
+	ErrNoSuchLifecycleConfiguration ErrorCode = "NoSuchLifecycleConfiguration"
+
+	// The bucket does not have OwnershipControls.
+	ErrOwnershipControlsNotFound ErrorCode = "OwnershipControlsNotFoundError"
+
+	// The bucket does not have an ObjectLockConfiguration.
+	ErrObjectLockConfigurationNotFound ErrorCode = "ObjectLockConfigurationNotFoundError"
+
+	// The request is not valid for the current state of the bucket.
+	ErrInvalidBucketState ErrorCode = "InvalidBucketState"
+
 	// No need to retransmit the object
 	ErrNotModified ErrorCode = "NotModified"
 
+	// At least one of the preconditions you specified did not hold.
+	ErrPreconditionFailed ErrorCode = "PreconditionFailed"
+
+	// The bucket must be addressed through the endpoint of its own region;
+	// see WithBucketRegion.
+	ErrPermanentRedirect ErrorCode = "PermanentRedirect"
+
+	// The bucket has no configuration with the requested id, for
+	// subresources like '?analytics'.
+	ErrNoSuchConfiguration ErrorCode = "NoSuchConfiguration"
+
+	// The bucket already has the maximum number of configurations for a
+	// subresource like '?analytics'.
+	ErrTooManyConfigurations ErrorCode = "TooManyConfigurations"
+
+	// The signature of a request did not match the one calculated with the
+	// secret key passed to WithSignatureVerification.
+	ErrSignatureDoesNotMatch ErrorCode = "SignatureDoesNotMatch"
+
+	// Reduce your request rate.
+	ErrSlowDown ErrorCode = "SlowDown"
+
+	// Returned for requests that arrive after GoFakeS3.Shutdown was called.
+	ErrServiceUnavailable ErrorCode = "ServiceUnavailable"
+
 	ErrRequestTimeTooSkewed ErrorCode = "RequestTimeTooSkewed"
 	ErrTooManyBuckets       ErrorCode = "TooManyBuckets"
 	ErrNotImplemented       ErrorCode = "NotImplemented"
@@ -103,19 +195,20 @@ const (
 // ensureErrorResponse.
 type errorResponse interface {
 	Error
-	enrich(requestID string)
+	enrich(requestID, hostID string)
 }
 
-func ensureErrorResponse(err error, requestID string) Error {
+func ensureErrorResponse(err error, requestID, hostID string) Error {
 	switch err := err.(type) {
 	case errorResponse:
-		err.enrich(requestID)
+		err.enrich(requestID, hostID)
 		return err
 
 	case ErrorCode:
 		return &ErrorResponse{
 			Code:      err,
 			RequestID: requestID,
+			HostID:    hostID,
 			Message:   string(err),
 		}
 
@@ -124,6 +217,7 @@ func ensureErrorResponse(err error, requestID string) Error {
 			Code:      ErrInternal,
 			Message:   "Internal Error",
 			RequestID: requestID,
+			HostID:    hostID,
 		}
 	}
 }
@@ -172,8 +266,9 @@ func (e *ErrorResponse) Error() string {
 	return fmt.Sprintf("%s: %s", e.Code, e.Message)
 }
 
-func (r *ErrorResponse) enrich(requestID string) {
+func (r *ErrorResponse) enrich(requestID, hostID string) {
 	r.RequestID = requestID
+	r.HostID = hostID
 }
 
 func ErrorMessage(code ErrorCode, message string) error {
@@ -225,6 +320,50 @@ func (e ErrorCode) Message() string {
 		return "The difference between the request time and the current time is too large"
 	case ErrMalformedXML:
 		return "The XML you provided was not well-formed or did not validate against our published schema"
+	case ErrNoSuchObjectLockConfiguration:
+		return "The specified object does not have a ObjectLock configuration"
+	case ErrAccessControlListNotSupported:
+		return "The bucket does not allow ACLs"
+	case ErrOwnershipControlsNotFound:
+		return "The bucket ownership controls were not found"
+	case ErrObjectLockConfigurationNotFound:
+		return "Object Lock configuration does not exist for this bucket"
+	case ErrNoSuchLifecycleConfiguration:
+		return "The lifecycle configuration does not exist"
+	case ErrNoSuchCORSConfiguration:
+		return "The CORS configuration does not exist"
+	case ErrNoSuchPublicAccessBlockConfiguration:
+		return "The public access block configuration was not found"
+	case ErrAccessDenied:
+		return "Access Denied"
+	case ErrNoSuchConfiguration:
+		return "The specified configuration does not exist."
+	case ErrTooManyConfigurations:
+		return "You are attempting to create a new configuration but have already reached the limit."
+	case ErrSlowDown:
+		return "Please reduce your request rate."
+	case ErrServiceUnavailable:
+		return "Service is unable to handle request."
+	case ErrPreconditionFailed:
+		return "At least one of the pre-conditions you specified did not hold"
+	case ErrInvalidPartNumber:
+		return "The requested partnumber is not satisfiable"
+	case ErrEntityTooSmall:
+		return "Your proposed upload is smaller than the minimum allowed size"
+	case ErrEntityTooLarge:
+		return "Your proposed upload exceeds the maximum allowed size"
+	case ErrInvalidRange:
+		return "The requested range is not satisfiable"
+	case ErrMethodNotAllowed:
+		return "The specified method is not allowed against this resource."
+	case ErrInvalidLocationConstraint:
+		return "The specified location-constraint is not valid"
+	case ErrInvalidAccessKeyId:
+		return "The AWS Access Key Id you provided does not exist in our records."
+	case ErrSignatureDoesNotMatch:
+		return "The request signature we calculated does not match the signature you provided. Check your key and signing method."
+	case ErrPermanentRedirect:
+		return "The bucket you are attempting to access must be addressed using the specified endpoint. Please send all future requests to this endpoint."
 	default:
 		return ""
 	}
@@ -233,50 +372,85 @@ func (e ErrorCode) Message() string {
 func (e ErrorCode) Status() int {
 	switch e {
 	case ErrBucketAlreadyExists,
-		ErrBucketNotEmpty:
+		ErrBucketNotEmpty,
+		ErrInvalidBucketState:
 		return http.StatusConflict
 
-	case ErrBadDigest,
+	case ErrAccessControlListNotSupported,
+		ErrAuthorizationHeaderMalformed,
+		ErrAuthorizationQueryParametersError,
+		ErrBadDigest,
 		ErrIllegalVersioningConfiguration,
 		ErrIncompleteBody,
+		ErrEntityTooLarge,
+		ErrEntityTooSmall,
 		ErrIncorrectNumberOfFilesInPostRequest,
 		ErrInlineDataTooLarge,
 		ErrInvalidArgument,
+		ErrInvalidPolicyDocument,
+		ErrInvalidBucketAclWithObjectOwnership,
 		ErrInvalidBucketName,
 		ErrInvalidDigest,
+		ErrInvalidLocationConstraint,
 		ErrInvalidPart,
 		ErrInvalidPartOrder,
+		ErrInvalidRequest,
+		ErrInvalidTag,
 		ErrInvalidToken,
 		ErrInvalidURI,
 		ErrKeyTooLong,
+		ErrMalformedACLError,
 		ErrMetadataTooLarge,
-		ErrMethodNotAllowed,
 		ErrMalformedPOSTRequest,
 		ErrMalformedXML,
-		ErrTooManyBuckets:
+		ErrTooManyBuckets,
+		ErrTooManyConfigurations:
 		return http.StatusBadRequest
 
-	case ErrRequestTimeTooSkewed:
+	case ErrAccessDenied,
+		ErrInvalidAccessKeyId,
+		ErrRequestTimeTooSkewed,
+		ErrSignatureDoesNotMatch:
 		return http.StatusForbidden
 
-	case ErrInvalidRange:
+	case ErrInvalidRange,
+		ErrInvalidPartNumber:
 		return http.StatusRequestedRangeNotSatisfiable
 
 	case ErrNoSuchBucket,
 		ErrNoSuchKey,
 		ErrNoSuchUpload,
-		ErrNoSuchVersion:
+		ErrNoSuchVersion,
+		ErrNoSuchConfiguration,
+		ErrNoSuchCORSConfiguration,
+		ErrNoSuchLifecycleConfiguration,
+		ErrNoSuchObjectLockConfiguration,
+		ErrNoSuchPublicAccessBlockConfiguration,
+		ErrObjectLockConfigurationNotFound,
+		ErrOwnershipControlsNotFound:
 		return http.StatusNotFound
 
+	case ErrMethodNotAllowed:
+		return http.StatusMethodNotAllowed
+
 	case ErrNotImplemented:
 		return http.StatusNotImplemented
 
 	case ErrNotModified:
 		return http.StatusNotModified
 
+	case ErrPermanentRedirect:
+		return http.StatusMovedPermanently
+
+	case ErrPreconditionFailed:
+		return http.StatusPreconditionFailed
+
 	case ErrMissingContentLength:
 		return http.StatusLengthRequired
 
+	case ErrSlowDown, ErrServiceUnavailable:
+		return http.StatusServiceUnavailable
+
 	case ErrInternal:
 		return http.StatusInternalServerError
 	}
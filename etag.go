@@ -0,0 +1,45 @@
+package gofakes3
+
+import (
+	"encoding/hex"
+	"strings"
+)
+
+// hashETag formats an object's MD5 hash as an ETag.
+func hashETag(hash []byte) string {
+	return `"` + hex.EncodeToString(hash) + `"`
+}
+
+// quoteETag ensures an ETag is wrapped in double quotes, as S3 always returns
+// them. Backends are not required to quote the ETags they return, so this is
+// applied to every ETag GoFakeS3 writes to a response.
+func quoteETag(etag string) string {
+	if etag == "" {
+		return ""
+	}
+	return `"` + strings.Trim(etag, `"`) + `"`
+}
+
+// isWeakETag reports whether an ETag is weak, as returned for objects over
+// the size given to WithWeakETags.
+func isWeakETag(etag string) bool {
+	return strings.HasPrefix(etag, "W/")
+}
+
+// weakETagMatch compares two ETags, either of which may be weak, by their
+// opaque tags alone:
+// https://www.rfc-editor.org/rfc/rfc7232#section-2.3.2
+func weakETagMatch(a, b string) bool {
+	a, b = strings.TrimPrefix(a, "W/"), strings.TrimPrefix(b, "W/")
+	return a != "" && quoteETag(a) == quoteETag(b)
+}
+
+// objectETag returns the ETag GET and HEAD object requests return for obj,
+// which is weak if obj is over the size given to WithWeakETags.
+func (g *GoFakeS3) objectETag(obj *Object) string {
+	etag := hashETag(obj.Hash)
+	if g.weakETagSize >= 0 && obj.Size > g.weakETagSize {
+		etag = "W/" + etag
+	}
+	return etag
+}
@@ -0,0 +1,24 @@
+package gofakes3
+
+import (
+	"path"
+	"time"
+)
+
+// firstByteDelayRule is added by WithFirstByteDelay.
+type firstByteDelayRule struct {
+	pattern string
+	delay   time.Duration
+}
+
+// firstByteDelay returns how long to wait between sending the headers of a
+// GET object response and the first byte of its body, if the object's
+// 'bucket/key' path matches a firstByteDelayRule.
+func (g *GoFakeS3) firstByteDelay(bucket, object string) time.Duration {
+	for _, rule := range g.firstByteDelays {
+		if matched, _ := path.Match(rule.pattern, bucket+"/"+object); matched {
+			return rule.delay
+		}
+	}
+	return 0
+}
@@ -1,18 +1,22 @@
 package gofakes3
 
 import (
+	"bufio"
+	"bytes"
 	"encoding/base64"
-	"encoding/hex"
 	"encoding/xml"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"math"
+	"math/rand"
 	"net/http"
 	"net/textproto"
 	"net/url"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"sync/atomic"
 	"time"
 )
@@ -24,6 +28,13 @@ import (
 type GoFakeS3 struct {
 	requestID uint64
 
+	// Accessed atomically; see WithMaxConcurrentRequests:
+	maxConcurrentRequests int64
+	inflightRequests      int64
+
+	// Accessed atomically; see WithWriteByteLimit:
+	bytesWritten int64
+
 	storage   Backend
 	multipart MultipartBackend
 	versioned VersionedBackend
@@ -31,11 +42,73 @@ type GoFakeS3 struct {
 	timeSource              TimeSource
 	timeSkew                time.Duration
 	metadataSizeLimit       int
+	metadataCountLimit      int
 	integrityCheck          bool
 	failOnUnimplementedPage bool
 	hostBucket              bool
+	signatureSecrets        map[string]string
+	signatureDebugging      bool
+	allowedRegions          map[string]bool
+	bucketThroughput        map[string]*bucketThroughput
+	preserveTrailingSlash   bool
 	autoBucket              bool
+	requireContentLength    bool
+	decodedLengthCheck      bool
+	contentTypeSniffing     bool
+	uploadBandwidth         int
+	downloadBandwidth       int
+	redirects               []redirectRule
+	truncateRules           []truncateRule
+	uploadIDGenerator       UploadIDGenerator
+	notFoundDelays          []notFoundDelayRule
+	firstByteDelays         []firstByteDelayRule
+	bucketBackends          map[string]Backend
+	bucketRegions           map[string]string
+	creationTimes           bool
+	strictQueryParameters   bool
+	storeTransform          TransformFunc
+	loadTransform           TransformFunc
+	listingLag              time.Duration
+	adminPrefix             string
+	healthCheckPath         string
+	requestCapture          func(CapturedRequest)
+	subresourceHandlers     []subresourceHandler
+	defaultOwner            UserInfo
+	owners                  map[string]UserInfo
+	anonymousAccessControl  bool
+	authorizer              Authorizer
+	syntheticTrees          []syntheticTree
+	bucketDefaultMetadata   map[string]map[string]string
+	writeByteLimit          int64
+	weakETagSize            int64
+	minPartSize             int64
+	maxUploadParts          int
+	latencies               latencies
+	gzipErrors              bool
+	echoHeaders             bool
+	ttlSweepInterval        time.Duration
+	lifecycleSweepInterval  time.Duration
 	log                     Logger
+
+	// configs holds subresource configuration that is not stored by the
+	// Backend, such as object retention.
+	configs *resourceConfigs
+
+	// objectLocks serialises writes to each key; see createObject.
+	objectLocks *keyLocks
+
+	// uploadLocks keeps part writes out of a completing upload; see
+	// completeMultipartUpload.
+	uploadLocks *keyLocks
+
+	stop     chan struct{}
+	stopOnce sync.Once
+
+	// See Shutdown. shutdownMu ensures no handler is added to the handlers
+	// WaitGroup once shuttingDown is set.
+	shutdownMu   sync.RWMutex
+	shuttingDown bool
+	handlers     sync.WaitGroup
 }
 
 // New creates a new GoFakeS3 using the supplied Backend. Backends are pluggable.
@@ -56,7 +129,16 @@ func New(backend Backend, options ...Option) *GoFakeS3 {
 		timeSkew:          DefaultSkewLimit,
 		metadataSizeLimit: DefaultMetadataSizeLimit,
 		integrityCheck:    true,
+		writeByteLimit:    -1,
+		weakETagSize:      -1,
+		minPartSize:       DefaultUploadPartSize,
+		maxUploadParts:    MaxUploadPartNumber,
 		requestID:         0,
+		defaultOwner:      defaultOwner,
+		configs:           newResourceConfigs(),
+		objectLocks:       newKeyLocks(),
+		uploadLocks:       newKeyLocks(),
+		stop:              make(chan struct{}),
 	}
 
 	// versioned MUST be set before options as one of the options disables it:
@@ -65,12 +147,53 @@ func New(backend Backend, options ...Option) *GoFakeS3 {
 	for _, opt := range options {
 		opt(s3)
 	}
+	if len(s3.bucketBackends) > 0 {
+		router := newBucketRouter(backend, s3.multipart, s3.bucketBackends)
+		s3.storage, s3.multipart = router, router
+		if s3.versioned != nil {
+			s3.versioned = router
+		}
+	}
+	if s3.storeTransform != nil || s3.loadTransform != nil {
+		transforming := newTransformingBackend(s3.storage, s3.storeTransform, s3.loadTransform)
+		s3.storage = transforming
+		if s3.versioned != nil {
+			s3.versioned = transforming
+		}
+		// Uploads assembled by GoFakeS3 are transformed like any other write:
+		if mb, ok := s3.multipart.(*multipartBackend); ok {
+			mb.storage = transforming
+		} else if router, ok := s3.multipart.(*bucketRouter); ok {
+			if mb, ok := router.fallbackMultipart.(*multipartBackend); ok {
+				mb.storage = transforming
+			}
+		}
+	}
+	if s3.uploadIDGenerator != nil {
+		for _, u := range uploaders(s3.multipart) {
+			u.generateID = s3.uploadIDGenerator
+		}
+	}
 	if s3.log == nil {
 		s3.log = DiscardLog()
 	}
 	if s3.timeSource == nil {
 		s3.timeSource = DefaultTimeSource()
 	}
+	if s3.latencies.rnd == nil {
+		s3.latencies.rnd = rand.New(rand.NewSource(time.Now().UnixNano()))
+	}
+	for _, tree := range s3.syntheticTrees {
+		if err := s3.populateSyntheticTree(tree); err != nil {
+			panic(fmt.Errorf("gofakes3: could not populate synthetic tree in bucket %q: %w", tree.bucket, err))
+		}
+	}
+	if s3.ttlSweepInterval > 0 {
+		go s3.runSweeper(s3.ttlSweepInterval, "TTL", s3.SweepExpiredObjects)
+	}
+	if s3.lifecycleSweepInterval > 0 {
+		go s3.runSweeper(s3.lifecycleSweepInterval, "lifecycle", s3.SweepLifecycleExpirations)
+	}
 
 	return s3
 }
@@ -79,27 +202,112 @@ func (g *GoFakeS3) nextRequestID() uint64 {
 	return atomic.AddUint64(&g.requestID, 1)
 }
 
+// SetMaxConcurrentRequests adjusts the limit on in-flight requests set by
+// WithMaxConcurrentRequests while the server is running. Requests that are
+// already in flight are not affected. Set to '0' to remove the limit.
+func (g *GoFakeS3) SetMaxConcurrentRequests(n int) {
+	atomic.StoreInt64(&g.maxConcurrentRequests, int64(n))
+}
+
+// acquireRequestSlot claims a slot for an in-flight request. Unlike a
+// queueing semaphore, it fails immediately with ErrSlowDown if the limit has
+// been reached, as S3 does when throttling. If err is nil, release must be
+// called once the request has been handled.
+func (g *GoFakeS3) acquireRequestSlot() (release func(), err error) {
+	g.shutdownMu.RLock()
+	defer g.shutdownMu.RUnlock()
+	if g.shuttingDown {
+		return nil, ErrServiceUnavailable
+	}
+
+	inflight := atomic.AddInt64(&g.inflightRequests, 1)
+	if limit := atomic.LoadInt64(&g.maxConcurrentRequests); limit > 0 && inflight > limit {
+		atomic.AddInt64(&g.inflightRequests, -1)
+		return nil, ErrSlowDown
+	}
+
+	g.handlers.Add(1)
+	return func() {
+		atomic.AddInt64(&g.inflightRequests, -1)
+		g.handlers.Done()
+	}, nil
+}
+
 // Create the AWS S3 API
 func (g *GoFakeS3) Server() http.Handler {
 	var handler http.Handler = &withCORS{r: http.HandlerFunc(g.routeBase), log: g.log}
 
+	if g.uploadBandwidth > 0 || g.downloadBandwidth > 0 {
+		handler = g.bandwidthMiddleware(handler)
+	}
+
 	if g.timeSkew != 0 {
 		handler = g.timeSkewMiddleware(handler)
 	}
 
+	if len(g.redirects) > 0 {
+		handler = g.redirectMiddleware(handler)
+	}
+
 	if g.hostBucket {
 		handler = g.hostBucketMiddleware(handler)
 	}
 
+	if g.signatureSecrets != nil {
+		handler = g.signatureMiddleware(handler)
+	}
+
+	if g.adminPrefix != "" {
+		handler = g.adminMiddleware(handler)
+	}
+
+	if g.healthCheckPath != "" {
+		handler = g.healthCheckMiddleware(handler)
+	}
+
+	if g.echoHeaders {
+		handler = g.echoHeadersMiddleware(handler)
+	}
+
+	// This must wrap every other middleware, as any of them may respond:
+	handler = g.requestIDMiddleware(handler)
+
 	return handler
 }
 
+// requestIDMiddleware sets the headers S3 uses to identify a request on
+// every response. httpError also copies them into the body of errors.
+//
+// The 'Date' header is set from the TimeSource rather than left to net/http,
+// as the SDKs use it to correct for clock skew after a RequestTimeTooSkewed
+// error.
+func (g *GoFakeS3) requestIDMiddleware(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, rq *http.Request) {
+		hdr := w.Header()
+		id := fmt.Sprintf("%016X", g.nextRequestID())
+		hdr.Set("x-amz-id-2", base64.StdEncoding.EncodeToString([]byte(id+id+id+id))) // x-amz-id-2 is 48 bytes of random stuff
+		hdr.Set("x-amz-request-id", id)
+		hdr.Set("Server", "AmazonS3")
+		hdr.Set("Date", g.timeSource.Now().UTC().Format(http.TimeFormat))
+
+		handler.ServeHTTP(w, rq)
+	})
+}
+
+// timeSkewMiddleware rejects requests sent at a time too far from the
+// TimeSource's. Like S3, the time is taken from the 'x-amz-date' header, or
+// from the 'Date' header if there is none.
 func (g *GoFakeS3) timeSkewMiddleware(handler http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, rq *http.Request) {
+		var rqTime time.Time
 		timeHdr := rq.Header.Get("x-amz-date")
+		if timeHdr != "" {
+			rqTime, _ = time.Parse("20060102T150405Z", timeHdr)
+		} else if timeHdr = rq.Header.Get("Date"); timeHdr != "" {
+			rqTime, _ = http.ParseTime(timeHdr)
+		}
 
 		if timeHdr != "" {
-			rqTime, _ := time.Parse("20060102T150405Z", timeHdr)
 			at := g.timeSource.Now()
 			skew := at.Sub(rqTime)
 
@@ -120,11 +328,16 @@ func (g *GoFakeS3) hostBucketMiddleware(handler http.Handler) http.Handler {
 		parts := strings.SplitN(rq.Host, ".", 2)
 		bucket := parts[0]
 
-		p := rq.URL.Path
+		p, rawp := rq.URL.Path, rq.URL.RawPath
 		rq.URL.Path = "/" + bucket
 		if p != "/" {
 			rq.URL.Path += p
 		}
+		// RawPath is only set if the path has encodings that Path loses,
+		// like '%2F'; see unescapePath:
+		if rawp != "" {
+			rq.URL.RawPath = "/" + url.PathEscape(bucket) + rawp
+		}
 		g.log.Print(LogInfo, p, "=>", rq.URL)
 
 		handler.ServeHTTP(w, rq)
@@ -132,10 +345,16 @@ func (g *GoFakeS3) hostBucketMiddleware(handler http.Handler) http.Handler {
 }
 
 func (g *GoFakeS3) httpError(w http.ResponseWriter, r *http.Request, err error) {
-	resp := ensureErrorResponse(err, "") // FIXME: request id
+	resp := ensureErrorResponse(err, w.Header().Get("x-amz-request-id"), w.Header().Get("x-amz-id-2"))
 	if resp.ErrorCode() == ErrInternal {
 		g.log.Print(LogErr, err)
 	}
+	discardRequestBody(w, r)
+
+	if g.gzipErrors && r.Method != http.MethodHead && acceptsGzip(r) {
+		g.writeGzipError(w, resp)
+		return
+	}
 
 	w.WriteHeader(resp.ErrorCode().Status())
 
@@ -147,24 +366,115 @@ func (g *GoFakeS3) httpError(w http.ResponseWriter, r *http.Request, err error)
 	}
 }
 
-func (g *GoFakeS3) listBuckets(w http.ResponseWriter, r *http.Request) error {
-	buckets, err := g.storage.ListBuckets()
-	if err != nil {
-		return err
+// errorBodyDiscardLimit is how much of the body of a failed request
+// discardRequestBody will read. It is well above the limit net/http applies
+// itself, so that uploads of a typical part size can fail without the client
+// losing its connection.
+const errorBodyDiscardLimit = 16 << 20
+
+// discardRequestBody reads what is left of the body of a request that failed
+// before it was read, so that the connection can be reused once the error has
+// been sent. If too much is left, 'Connection: close' is sent instead.
+func discardRequestBody(w http.ResponseWriter, r *http.Request) {
+	if r.Body == nil || r.Body == http.NoBody {
+		return
+	}
+	if strings.EqualFold(r.Header.Get("Expect"), "100-continue") {
+		// Reading would ask the client to send a body that is going to be
+		// thrown away; net/http closes the connection instead if it has not
+		// been read.
+		return
+	}
+
+	n, err := io.CopyN(ioutil.Discard, r.Body, errorBodyDiscardLimit+1)
+	if err == http.ErrBodyReadAfterClose {
+		// The handler closed the body, which lets net/http decide whether
+		// the connection can be reused:
+		return
 	}
+	if n > errorBodyDiscardLimit || (err != nil && err != io.EOF) {
+		w.Header().Set("Connection", "close")
+	}
+}
+
+func (g *GoFakeS3) listBuckets(w http.ResponseWriter, r *http.Request) error {
+	q := r.URL.Query()
+	_, hasMax := q["max-buckets"]
+	_, hasToken := q["continuation-token"]
+	prefix := q.Get("prefix")
 
 	s := &Storage{
-		Xmlns:   "http://s3.amazonaws.com/doc/2006-03-01/",
-		Buckets: buckets,
+		Xmlns:  "http://s3.amazonaws.com/doc/2006-03-01/",
+		Prefix: prefix,
 		Owner: &UserInfo{
-			ID:          "fe7272ea58be830e56fe1663b10fafef",
-			DisplayName: "GoFakeS3",
+			ID:          g.defaultOwner.ID,
+			DisplayName: g.defaultOwner.DisplayName,
 		},
 	}
 
+	pager, ok := g.storage.(PagingBucketsBackend)
+	if !ok && !hasMax && !hasToken && prefix == "" {
+		buckets, err := g.storage.ListBuckets()
+		if err != nil {
+			return err
+		}
+		s.Buckets = buckets
+		return g.xmlEncoder(w).Encode(s)
+	}
+
+	max := int64(MaxBuckets)
+	if hasMax {
+		v, err := strconv.ParseInt(q.Get("max-buckets"), 10, 64)
+		if err != nil || v < 1 || v > MaxBuckets {
+			return ErrorInvalidArgument("max-buckets", q.Get("max-buckets"), fmt.Sprintf("Argument max-buckets must be an integer between 1 and %d", MaxBuckets))
+		}
+		max = v
+	}
+
+	var err error
+	if ok {
+		s.Buckets, s.ContinuationToken, err = pager.ListBucketsPage(prefix, q.Get("continuation-token"), max)
+	} else {
+		s.Buckets, s.ContinuationToken, err = g.listBucketsPage(prefix, q.Get("continuation-token"), max)
+	}
+	if err != nil {
+		return err
+	}
 	return g.xmlEncoder(w).Encode(s)
 }
 
+// listBucketsPage pages the buckets returned by a Backend that is not a
+// PagingBucketsBackend. Like the continuation-token of ListObjectsV2, the
+// token is the name of the last bucket listed, base64 encoded.
+func (g *GoFakeS3) listBucketsPage(prefix, continuationToken string, max int64) (page []BucketInfo, nextToken string, err error) {
+	var after string
+	if continuationToken != "" {
+		decoded, err := base64.URLEncoding.DecodeString(continuationToken)
+		if err != nil {
+			return nil, "", ErrorInvalidArgument("continuation-token", continuationToken, "The continuation token provided is incorrect")
+		}
+		after = string(decoded)
+	}
+
+	buckets, err := g.storage.ListBuckets()
+	if err != nil {
+		return nil, "", err
+	}
+	sort.Slice(buckets, func(i, j int) bool { return buckets[i].Name < buckets[j].Name })
+
+	for _, bucket := range buckets {
+		if bucket.Name <= after || !strings.HasPrefix(bucket.Name, prefix) {
+			continue
+		}
+		if int64(len(page)) == max {
+			last := page[len(page)-1].Name
+			return page, base64.URLEncoding.EncodeToString([]byte(last)), nil
+		}
+		page = append(page, bucket)
+	}
+	return page, "", nil
+}
+
 // S3 has two versions of this API, both of which are close to identical. We manage that
 // jank in here so the Backend doesn't have to with the following tricks:
 //
@@ -190,19 +500,31 @@ func (g *GoFakeS3) listBucket(bucketName string, w http.ResponseWriter, r *http.
 		return err
 	}
 
+	encodingType, encode, err := listEncodingFromQuery(q)
+	if err != nil {
+		return err
+	}
+
 	isVersion2 := q.Get("list-type") == "2"
 
 	g.log.Print(LogInfo, "bucketname:", bucketName, "prefix:", prefix, "page:", fmt.Sprintf("%+v", page))
 
 	objects, err := g.storage.ListBucket(bucketName, &prefix, page)
 	if err != nil {
-		if err == ErrInternalPageNotImplemented && !g.failOnUnimplementedPage {
+		if ib, ok := g.storage.(IteratingBackend); ok && err == ErrInternalPageNotImplemented {
+			objects, err = listBucketIter(ib, bucketName, &prefix, page)
+			if err != nil {
+				return err
+			}
+
+		} else if err == ErrInternalPageNotImplemented && !g.failOnUnimplementedPage {
 			// We have observed (though not yet confirmed) that simple clients
 			// tend to work fine if you simply ignore pagination, so the
 			// default if this is not implemented is to retry without it. If
 			// you care about this performance impact for some weird reason,
-			// you'll need to handle it yourself.
-			objects, err = g.storage.ListBucket(bucketName, &prefix, ListBucketPage{})
+			// you'll need to handle it yourself. The marker is still
+			// honoured, so that resumed listings don't repeat keys:
+			objects, err = listBucketAfterMarker(g.storage, bucketName, &prefix, page.Marker)
 			if err != nil {
 				return err
 			}
@@ -213,28 +535,56 @@ func (g *GoFakeS3) listBucket(bucketName string, w http.ResponseWriter, r *http.
 			return err
 		}
 	}
+	objects.Contents = g.omitRecentWrites(objects.Contents)
+
+	// On the topic of "fetch-owner", the AWS docs say, in typically vague style:
+	// "If you want the owner information in the response, you can specify
+	// this parameter with the value set to true."
+	//
+	// What does the bare word 'true' mean when we're talking about a query
+	// string parameter, which can only be a string? The SDKs send the
+	// strings 'true' and 'false', so that's what we parse, but a bare
+	// '?fetch-owner' is also treated as true. V1 calls always include the
+	// Owner.
+	if !isVersion2 || fetchOwner(q) {
+		if err := g.fillListOwners(bucketName, objects.Contents); err != nil {
+			return err
+		}
+	} else {
+		for _, item := range objects.Contents {
+			item.Owner = nil
+		}
+	}
+
+	for _, item := range objects.Contents {
+		item.ETag = quoteETag(item.ETag)
+		item.Key = encode(item.Key)
+	}
 
 	base := ListBucketResultBase{
 		Xmlns:          "http://s3.amazonaws.com/doc/2006-03-01/",
 		Name:           bucketName,
-		CommonPrefixes: objects.CommonPrefixes,
+		CommonPrefixes: encodeCommonPrefixes(objects.CommonPrefixes, encode),
 		Contents:       objects.Contents,
 		IsTruncated:    objects.IsTruncated,
-		Delimiter:      prefix.Delimiter,
-		Prefix:         prefix.Prefix,
+		Delimiter:      encode(prefix.Delimiter),
+		Prefix:         encode(prefix.Prefix),
 		MaxKeys:        page.MaxKeys,
+		EncodingType:   encodingType,
 	}
 
 	if !isVersion2 {
 		var result = &ListBucketResult{
 			ListBucketResultBase: base,
-			Marker:               page.Marker,
+			Marker:               encode(page.Marker),
 		}
-		if base.Delimiter != "" {
-			// From the S3 docs: "This element is returned only if you specify
-			// a delimiter request parameter." Dunno why. This hack has been moved
-			// into GoFakeS3 to spare backend implementers the trouble.
-			result.NextMarker = objects.NextMarker
+		if base.Delimiter != "" && objects.IsTruncated {
+			// From the S3 docs: "This element is returned only if you have
+			// the delimiter request parameter specified." Dunno why. Without
+			// it, clients use the last key as the next marker. This hack has
+			// been moved into GoFakeS3 to spare backend implementers the
+			// trouble.
+			result.NextMarker = encode(objects.NextMarker)
 		}
 		return g.xmlEncoder(w).Encode(result)
 
@@ -242,38 +592,34 @@ func (g *GoFakeS3) listBucket(bucketName string, w http.ResponseWriter, r *http.
 		var result = &ListBucketResultV2{
 			ListBucketResultBase: base,
 			KeyCount:             int64(len(objects.CommonPrefixes) + len(objects.Contents)),
-			StartAfter:           q.Get("start-after"),
+			StartAfter:           encode(q.Get("start-after")),
 			ContinuationToken:    q.Get("continuation-token"),
 		}
-		if objects.NextMarker != "" {
+		if objects.IsTruncated && objects.NextMarker != "" {
 			// We are just cheating with these continuation tokens; they're just the NextMarker
 			// from v1 in disguise! That may change at any time and should not be relied upon
 			// though.
 			result.NextContinuationToken = base64.URLEncoding.EncodeToString([]byte(objects.NextMarker))
 		}
 
-		// On the topic of "fetch-owner", the AWS docs say, in typically vague style:
-		// "If you want the owner information in the response, you can specify
-		// this parameter with the value set to true."
-		//
-		// What does the bare word 'true' mean when we're talking about a query
-		// string parameter, which can only be a string? Does it mean the word
-		// 'true'? Does it mean 'any truthy string'? Does it mean only the key
-		// needs to be present (i.e. '?fetch-owner'), which we are assuming
-		// for now? This is why you need proper technical writers.
-		//
-		// Probably need to hit up the s3assumer at some point, but until then, here's
-		// another FIXME!
-		if _, ok := q["fetch-owner"]; !ok {
-			for _, v := range result.Contents {
-				v.Owner = nil
-			}
-		}
-
 		return g.xmlEncoder(w).Encode(result)
 	}
 }
 
+// fetchOwner reports whether a ListObjectsV2 request asked for the Owner of
+// each object with the 'fetch-owner' query parameter.
+func fetchOwner(q url.Values) bool {
+	vs, ok := q["fetch-owner"]
+	if !ok {
+		return false
+	}
+	if len(vs) == 0 || vs[0] == "" {
+		return true
+	}
+	v, err := strconv.ParseBool(vs[0])
+	return err == nil && v
+}
+
 func (g *GoFakeS3) getBucketLocation(bucketName string, w http.ResponseWriter, r *http.Request) error {
 	g.log.Print(LogInfo, "GET BUCKET LOCATION")
 
@@ -282,8 +628,11 @@ func (g *GoFakeS3) getBucketLocation(bucketName string, w http.ResponseWriter, r
 	}
 
 	result := GetBucketLocation{
-		Xmlns:              "http://s3.amazonaws.com/doc/2006-03-01/",
-		LocationConstraint: "",
+		Xmlns: "http://s3.amazonaws.com/doc/2006-03-01/",
+	}
+	// Like S3, buckets in us-east-1 have no location constraint:
+	if region := g.bucketRegions[bucketName]; region != "us-east-1" {
+		result.LocationConstraint = region
 	}
 
 	return g.xmlEncoder(w).Encode(result)
@@ -304,6 +653,10 @@ func (g *GoFakeS3) listBucketVersions(bucketName string, w http.ResponseWriter,
 	if err != nil {
 		return err
 	}
+	encodingType, encode, err := listEncodingFromQuery(q)
+	if err != nil {
+		return err
+	}
 
 	// S300004:
 	if page.HasVersionIDMarker {
@@ -333,6 +686,22 @@ func (g *GoFakeS3) listBucketVersions(bucketName string, w http.ResponseWriter,
 		if ver.GetVersionID() == "" {
 			ver.setVersionID("null")
 		}
+		switch v := ver.(type) {
+		case *Version:
+			v.ETag = quoteETag(v.ETag)
+			v.Key = encode(v.Key)
+		case *DeleteMarker:
+			v.Key = encode(v.Key)
+		}
+	}
+
+	if encodingType != "" {
+		bucket.EncodingType = encodingType
+		bucket.Delimiter = encode(bucket.Delimiter)
+		bucket.Prefix = encode(bucket.Prefix)
+		bucket.KeyMarker = encode(bucket.KeyMarker)
+		bucket.NextKeyMarker = encode(bucket.NextKeyMarker)
+		bucket.CommonPrefixes = encodeCommonPrefixes(bucket.CommonPrefixes, encode)
 	}
 
 	return g.xmlEncoder(w).Encode(bucket)
@@ -345,15 +714,80 @@ func (g *GoFakeS3) createBucket(bucket string, w http.ResponseWriter, r *http.Re
 	if err := ValidateBucketName(bucket); err != nil {
 		return err
 	}
+
+	ownership := ObjectOwnership(r.Header.Get(ObjectOwnershipHeader))
+	if ownership != "" && !ownership.Valid() {
+		return ErrorInvalidArgument(ObjectOwnershipHeader, string(ownership), "Invalid x-amz-object-ownership header")
+	}
+	if ownership == ObjectOwnershipBucketOwnerEnforced && requestSetsACL(r.Header) {
+		return ErrInvalidBucketAclWithObjectOwnership
+	}
+
+	acl, err := grantHeadersPolicy(r.Header.Get, g.defaultOwner)
+	if err != nil {
+		return err
+	}
+	if canned := r.Header.Get("X-Amz-Acl"); canned != "" {
+		if acl, err = cannedACLPolicy(canned, g.defaultOwner, g.defaultOwner); err != nil {
+			return err
+		}
+	}
+
+	if err := g.checkLocationConstraint(r); err != nil {
+		return err
+	}
+
 	if err := g.storage.CreateBucket(bucket); err != nil {
 		return err
 	}
+	if acl != nil {
+		g.configs.put(resourceConfigKey{bucket: bucket, kind: bucketACLConfig}, acl)
+	}
+	if ownership != "" {
+		g.configs.put(resourceConfigKey{bucket: bucket, kind: bucketOwnershipControlsConfig}, &OwnershipControls{
+			Rules: []OwnershipControlsRule{{ObjectOwnership: ownership}},
+		})
+	}
+	if strings.EqualFold(r.Header.Get(ObjectLockEnabledHeader), "true") {
+		if err := g.enableObjectLock(bucket); err != nil {
+			return err
+		}
+	}
 
 	w.Header().Set("Location", "/"+bucket)
 	w.Write([]byte{})
 	return nil
 }
 
+// checkLocationConstraint implements WithAllowedRegions: it rejects a
+// CreateBucket request whose CreateBucketConfiguration names a region that is
+// not allowed. A request without one creates the bucket in us-east-1.
+func (g *GoFakeS3) checkLocationConstraint(r *http.Request) error {
+	if g.allowedRegions == nil {
+		return nil
+	}
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return err
+	}
+
+	region := "us-east-1"
+	if len(bytes.TrimSpace(body)) > 0 {
+		var config CreateBucketConfiguration
+		if err := g.xmlDecodeBody(ioutil.NopCloser(bytes.NewReader(body)), &config); err != nil {
+			return err
+		}
+		if config.LocationConstraint != "" {
+			region = config.LocationConstraint
+		}
+	}
+
+	if !g.allowedRegions[region] {
+		return ErrorMessage(ErrInvalidLocationConstraint, ErrInvalidLocationConstraint.Message())
+	}
+	return nil
+}
+
 // DeleteBucket deletes the bucket in the underlying backend, if and only if it
 // contains no items.
 func (g *GoFakeS3) deleteBucket(bucket string, w http.ResponseWriter, r *http.Request) error {
@@ -365,6 +799,7 @@ func (g *GoFakeS3) deleteBucket(bucket string, w http.ResponseWriter, r *http.Re
 	if err := g.storage.DeleteBucket(bucket); err != nil {
 		return err
 	}
+	g.configs.deleteBucket(bucket)
 
 	w.WriteHeader(http.StatusNoContent)
 	return nil
@@ -383,6 +818,10 @@ func (g *GoFakeS3) headBucket(bucket string, w http.ResponseWriter, r *http.Requ
 	return nil
 }
 
+// getObjectPeekSize is how much of an object's body getObject reads from the
+// Backend before it commits to a successful response.
+const getObjectPeekSize = 32 * 1024
+
 // GetObject retrievs a bucket object.
 func (g *GoFakeS3) getObject(
 	bucket, object string,
@@ -397,9 +836,36 @@ func (g *GoFakeS3) getObject(
 		return err
 	}
 
-	rnge, err := parseRangeHeader(r.Header.Get("Range"))
-	if err != nil {
-		return err
+	var rnge *ObjectRangeRequest
+	var err error
+	if g.supportsRanges(bucket) {
+		if rnge, err = parseRangeHeader(r.Header.Get("Range")); err != nil {
+			return err
+		}
+	}
+	// partNumber is turned into the range the part covers:
+	var part *objectPart
+	var partsCount int
+	if _, ok := r.URL.Query()["partNumber"]; ok {
+		current, err := g.headObjectVersion(bucket, object, versionID)
+		if err != nil {
+			return err
+		}
+		if part, partsCount, err = g.requestedPart(bucket, object, current, r); err != nil {
+			return err
+		}
+		if g.supportsRanges(bucket) {
+			rnge = part.rangeRequest()
+		}
+	}
+	if ifRange := r.Header.Get("If-Range"); rnge != nil && ifRange != "" {
+		current, err := g.headObjectVersion(bucket, object, versionID)
+		if err != nil {
+			return err
+		}
+		if !ifRangeMatches(ifRange, current, g.objectETag(current)) {
+			rnge = nil
+		}
 	}
 
 	var obj *Object
@@ -407,18 +873,24 @@ func (g *GoFakeS3) getObject(
 	{ // get object from backend
 		if versionID == "" {
 			obj, err = g.storage.GetObject(bucket, object, rnge)
-			if err != nil {
-				return err
-			}
 		} else {
 			if g.versioned == nil {
 				return ErrNotImplemented
 			}
 			obj, err = g.versioned.GetObjectVersion(bucket, object, versionID, rnge)
-			if err != nil {
-				return err
+		}
+		if versionID == "" {
+			err = g.currentDeleteMarkerHeaders(bucket, object, err, w)
+		}
+		if HasErrorCode(err, ErrInvalidRange) && rnge != nil {
+			// The Backend does not say how big the object is:
+			if current, herr := g.headObjectVersion(bucket, object, versionID); herr == nil {
+				err = invalidRange(r.Header.Get("Range"), current.Size)
 			}
 		}
+		if err != nil {
+			return err
+		}
 	}
 
 	if obj == nil {
@@ -427,52 +899,193 @@ func (g *GoFakeS3) getObject(
 	}
 	defer obj.Contents.Close()
 
-	if err := g.writeGetOrHeadObjectResponse(obj, w, r); err != nil {
+	if g.objectExpired(obj) {
+		return KeyNotFound(object)
+	}
+
+	if err := g.writeGetOrHeadObjectResponse(bucket, obj, w, r); err != nil {
 		return err
 	}
 
+	if part != nil {
+		part.writeHeaders(partsCount, w, r)
+	}
+	writeResponseHeaderOverrides(r.URL.Query(), w)
+
+	var contents io.Reader = obj.Contents
+	length := obj.Size
+	if obj.Range != nil {
+		length = obj.Range.Length
+	}
+	expected := length
+	truncated, stall, ok := g.truncatedLength(object, length)
+	if ok {
+		g.log.Print(LogInfo, "TRUNCATING BODY:", bucket, object, truncated, "of", length, "stalling", stall)
+		contents = io.LimitReader(contents, truncated)
+		expected = truncated
+	}
+
+	// Once the headers are sent there is no way to report an error, so the
+	// start of the body is read first. A Backend that fails straight away,
+	// or holds fewer bytes than it claims, gets a proper error response:
+	body := bufio.NewReaderSize(contents, getObjectPeekSize)
+	peek := expected
+	if peek > getObjectPeekSize {
+		peek = getObjectPeekSize
+	}
+	if _, err := body.Peek(int(peek)); err != nil {
+		g.log.Print(LogErr, "GET OBJECT read failed:", bucket, object, err)
+		return ErrInternal
+	}
+
 	// Writes Content-Length, and Content-Range if applicable:
 	obj.Range.writeHeader(obj.Size, w)
+	if obj.Range != nil {
+		w.WriteHeader(http.StatusPartialContent)
+	}
 
-	if _, err := io.Copy(w, obj.Contents); err != nil {
-		return err
+	if delay := g.firstByteDelay(bucket, object); delay > 0 {
+		g.log.Print(LogInfo, "DELAYING FIRST BYTE:", bucket, object, delay)
+
+		// The headers must reach the client before it waits for the body:
+		if f, ok := w.(http.Flusher); ok {
+			f.Flush()
+		}
+		if err := g.sleep(r.Context(), delay); err != nil {
+			// The client has gone away, so there is nobody to send the body to:
+			return nil
+		}
+	}
+
+	// Like S3, the stored bytes are returned verbatim: an object stored with
+	// a Content-Encoding is neither decoded nor re-encoded, regardless of
+	// the request's Accept-Encoding.
+	if _, err := io.CopyN(w, body, expected); err != nil {
+		// Returning the error would append an error document to a body that
+		// is already under way. Aborting drops the connection instead, so the
+		// client cannot mistake what it received for the whole object:
+		g.log.Print(LogErr, "GET OBJECT failed after sending headers:", bucket, object, err)
+		panic(http.ErrAbortHandler)
 	}
 
+	if stall > 0 {
+		// The bytes sent so far must reach the client before it stalls:
+		if f, ok := w.(http.Flusher); ok {
+			f.Flush()
+		}
+		if err := g.sleep(r.Context(), stall); err != nil {
+			g.log.Print(LogInfo, "STALL ABANDONED:", bucket, object, err)
+		}
+	}
 	return nil
 }
 
 // writeGetOrHeadObjectResponse contains shared logic for constructing headers for
 // a HEAD and a GET request for a /bucket/object URL.
-func (g *GoFakeS3) writeGetOrHeadObjectResponse(obj *Object, w http.ResponseWriter, r *http.Request) error {
+func (g *GoFakeS3) writeGetOrHeadObjectResponse(bucket string, obj *Object, w http.ResponseWriter, r *http.Request) error {
 	// "If the current version of the object is a delete marker, Amazon S3
 	// behaves as if the object was deleted and includes x-amz-delete-marker:
-	// true in the response."
+	// true in the response." A delete marker requested by its version ID
+	// can't be read at all, only deleted:
 	if obj.IsDeleteMarker {
 		w.Header().Set("x-amz-version-id", string(obj.VersionID))
 		w.Header().Set("x-amz-delete-marker", "true")
+		if r.URL.Query().Get("versionId") != "" {
+			return methodNotAllowed(w, "DELETE")
+		}
 		return KeyNotFound(obj.Name)
 	}
 
+	ssec, err := parseSSECustomerKey(r.Header, "")
+	if err != nil {
+		return err
+	}
+	if err := checkSSECustomerKey(obj.Metadata, ssec); err != nil {
+		return err
+	}
+
+	missing := 0
 	for mk, mv := range obj.Metadata {
-		w.Header().Set(mk, mv)
+		if isMetadataKey(mk) {
+			if !isHeaderSafe(mv) {
+				missing++
+				continue
+			}
+			// Set would undo canonicalMetadataKey:
+			w.Header()[canonicalMetadataKey(mk)] = []string{mv}
+		} else if mk != TaggingHeader {
+			w.Header().Set(mk, mv)
+		}
+	}
+	w.Header().Set("x-amz-missing-meta", strconv.Itoa(missing))
+
+	tagging, err := g.objectTagging(bucket, obj.Name, obj)
+	if err != nil {
+		return err
+	}
+	if len(tagging.TagSet) > 0 {
+		w.Header().Set(TaggingCountHeader, strconv.Itoa(len(tagging.TagSet)))
+	}
+
+	g.writeRetentionHeaders(bucket, obj.Name, obj.VersionID, w)
+	g.writeCreatedHeader(bucket, obj, w)
+
+	// Like S3, the checksum is only returned if asked for, and only for the
+	// whole object:
+	if strings.EqualFold(r.Header.Get("X-Amz-Checksum-Mode"), "ENABLED") && obj.Range == nil {
+		if sum, ok := g.objectChecksum(bucket, obj.Name, obj); ok {
+			w.Header().Set(sum.header, sum.value)
+		}
 	}
 
 	if obj.VersionID != "" {
 		w.Header().Set("x-amz-version-id", string(obj.VersionID))
 	}
 
-	etag := `"` + hex.EncodeToString(obj.Hash) + `"`
+	if err := g.writeExpirationHeader(bucket, obj, w); err != nil {
+		return err
+	}
+
+	etag := g.objectETag(obj)
 	w.Header().Set("ETag", etag)
 
-	if r.Header.Get("If-None-Match") == etag {
-		return ErrNotModified
+	// The dates are compared to the second, the precision of Last-Modified:
+	modified, _ := http.ParseTime(obj.Metadata["Last-Modified"])
+	if err := parseConditionalRequest(r.Header, g.timeSource.Now()).check(r.Method, true, etag, modified); err != nil {
+		return err
 	}
 
-	w.Header().Set("Accept-Ranges", "bytes")
+	if g.supportsRanges(bucket) {
+		w.Header().Set("Accept-Ranges", "bytes")
+	}
 
 	return nil
 }
 
+// responseHeaderOverrides maps the query string parameters that GET object
+// accepts to the response headers they replace. These are commonly found in
+// presigned URLs:
+// https://docs.aws.amazon.com/AmazonS3/latest/API/API_GetObject.html
+var responseHeaderOverrides = [][2]string{
+	{"response-cache-control", "Cache-Control"},
+	{"response-content-disposition", "Content-Disposition"},
+	{"response-content-encoding", "Content-Encoding"},
+	{"response-content-language", "Content-Language"},
+	{"response-content-type", "Content-Type"},
+	{"response-expires", "Expires"},
+}
+
+// writeResponseHeaderOverrides applies any 'response-*' query parameters to
+// the response headers. These take precedence over the stored metadata, so
+// this must be called after the metadata headers are written.
+func writeResponseHeaderOverrides(query url.Values, w http.ResponseWriter) {
+	for _, override := range responseHeaderOverrides {
+		if v := query.Get(override[0]); v != "" {
+			w.Header().Set(override[1], v)
+		}
+	}
+}
+
 // headObject retrieves only meta information of an object and not the whole.
 func (g *GoFakeS3) headObject(
 	bucket, object string,
@@ -481,31 +1094,105 @@ func (g *GoFakeS3) headObject(
 	r *http.Request,
 ) error {
 
-	g.log.Print(LogInfo, "HEAD OBJECT", bucket, object)
+	g.log.Print(LogInfo, "HEAD OBJECT", bucket, object, versionID)
 
-	if err := g.ensureBucketExists(bucket); err != nil {
+	obj, err := g.headObjectVersion(bucket, object, versionID)
+	if versionID == "" {
+		err = g.currentDeleteMarkerHeaders(bucket, object, err, w)
+	}
+	if err != nil {
 		return err
 	}
 
-	obj, err := g.storage.HeadObject(bucket, object)
+	part, partsCount, err := g.requestedPart(bucket, object, obj, r)
 	if err != nil {
 		return err
 	}
-	if obj == nil {
-		g.log.Print(LogErr, "unexpected nil object for key", bucket, object)
-		return ErrInternal
-	}
-	defer obj.Contents.Close()
 
-	if err := g.writeGetOrHeadObjectResponse(obj, w, r); err != nil {
+	if err := g.writeGetOrHeadObjectResponse(bucket, obj, w, r); err != nil {
 		return err
 	}
 
+	if part != nil {
+		part.writeHeaders(partsCount, w, r)
+		if rnge, _ := part.rangeRequest().Range(obj.Size); rnge != nil && g.supportsRanges(bucket) {
+			rnge.writeHeader(obj.Size, w)
+			w.WriteHeader(http.StatusPartialContent)
+			return nil
+		}
+	}
 	w.Header().Set("Content-Length", fmt.Sprintf("%d", obj.Size))
 
 	return nil
 }
 
+// supportsRanges reports whether the Backend can return part of an object;
+// see RangeReportingBackend.
+func (g *GoFakeS3) supportsRanges(bucket string) bool {
+	backend := g.storage
+	if router, ok := backend.(*bucketRouter); ok {
+		backend = router.backend(bucket)
+	}
+	rb, ok := backend.(RangeReportingBackend)
+	return !ok || rb.SupportsRanges()
+}
+
+// currentDeleteMarkerHeaders adds the headers S3 sends with the 'NoSuchKey'
+// error for a GET or HEAD without a version ID when the current version of
+// the object is a delete marker. Backends only report that the key is
+// missing, so the versions are listed to tell the two apart. err is returned
+// unchanged, unless listing the versions fails.
+func (g *GoFakeS3) currentDeleteMarkerHeaders(bucket, object string, err error, w http.ResponseWriter) error {
+	if g.versioned == nil || !HasErrorCode(err, ErrNoSuchKey) {
+		return err
+	}
+
+	prefix := NewPrefix(&object, nil)
+	versions, lerr := g.versioned.ListBucketVersions(bucket, &prefix, &ListBucketVersionsPage{})
+	if lerr != nil {
+		return lerr
+	}
+	for _, ver := range versions.Versions {
+		if marker, ok := ver.(*DeleteMarker); ok && marker.IsLatest && marker.Key == object {
+			w.Header().Set("x-amz-version-id", string(marker.VersionID))
+			w.Header().Set("x-amz-delete-marker", "true")
+			break
+		}
+	}
+	return err
+}
+
+// headObjectVersion fetches an object version's metadata without its
+// Contents, which have already been closed. If versionID is empty, the current
+// version is used.
+func (g *GoFakeS3) headObjectVersion(bucket, object string, versionID VersionID) (obj *Object, err error) {
+	if err := g.ensureBucketExists(bucket); err != nil {
+		return nil, err
+	}
+
+	if versionID != "" {
+		if g.versioned == nil {
+			return nil, ErrNotImplemented
+		}
+		obj, err = g.versioned.HeadObjectVersion(bucket, object, versionID)
+	} else {
+		obj, err = g.storage.HeadObject(bucket, object)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if obj == nil {
+		g.log.Print(LogErr, "unexpected nil object for key", bucket, object)
+		return nil, ErrInternal
+	}
+	obj.Contents.Close()
+
+	if g.objectExpired(obj) {
+		return nil, KeyNotFound(object)
+	}
+	return obj, nil
+}
+
 // createObjectBrowserUpload allows objects to be created from a multipart upload initiated
 // by a browser form.
 func (g *GoFakeS3) createObjectBrowserUpload(bucket string, w http.ResponseWriter, r *http.Request) error {
@@ -535,65 +1222,129 @@ func (g *GoFakeS3) createObjectBrowserUpload(bucket string, w http.ResponseWrite
 	}
 	fileHeader := fileValues[0]
 
+	if err := checkPostPolicy(r.MultipartForm.Value, fileHeader.Size); err != nil {
+		return err
+	}
+
 	infile, err := fileHeader.Open()
 	if err != nil {
 		return err
 	}
 	defer infile.Close()
 
-	meta, err := metadataHeaders(r.MultipartForm.Value, g.timeSource.Now(), g.metadataSizeLimit)
+	meta, err := metadataHeaders(r.MultipartForm.Value, g.timeSource.Now(), g.metadataSizeLimit, g.metadataCountLimit)
 	if err != nil {
 		return err
 	}
+	g.applyBucketDefaultMetadata(bucket, meta)
+	if err := g.applyObjectTTL(meta); err != nil {
+		return err
+	}
+	if err := g.ensureACLsAllowed(bucket, http.Header{"X-Amz-Acl": r.MultipartForm.Value["acl"]}); err != nil {
+		return err
+	}
 
 	if len(key) > KeySizeLimit {
 		return ResourceError(ErrKeyTooLong, key)
 	}
-
-	// FIXME: how does Content-MD5 get sent when using the browser? does it?
-	rdr, err := newHashingReader(infile, "")
+
+	// FIXME: how does Content-MD5 get sent when using the browser? does it?
+	rdr, err := newHashingReader(infile, "")
+	if err != nil {
+		return err
+	}
+
+	setCreated := g.objectCreation(bucket, key)
+	result, err := g.storage.PutObject(bucket, key, meta, g.limitWrite(rdr), fileHeader.Size)
+	if err != nil {
+		return err
+	}
+	g.configs.deleteObject(bucket, key, result.VersionID)
+	g.setObjectOwner(bucket, key, result.VersionID, r)
+	setCreated(result.VersionID)
+	g.setObjectRetention(bucket, key, result.VersionID, nil)
+	if result.VersionID != "" {
+		w.Header().Set("x-amz-version-id", string(result.VersionID))
+	}
+
+	w.Header().Set("ETag", hashETag(rdr.Sum(nil)))
+	return nil
+}
+
+// CreateObject creates a new S3 object.
+func (g *GoFakeS3) createObject(bucket, object string, w http.ResponseWriter, r *http.Request) (err error) {
+	g.log.Print(LogInfo, "CREATE OBJECT:", bucket, object)
+
+	if err := g.ensureBucketExists(bucket); err != nil {
+		return err
+	}
+
+	meta, err := metadataHeaders(r.Header, g.timeSource.Now(), g.metadataSizeLimit, g.metadataCountLimit)
+	if err != nil {
+		return err
+	}
+	if err := g.applyObjectTTL(meta); err != nil {
+		return err
+	}
+	ssec, err := sseCustomerMeta(meta, r.Header)
+	if err != nil {
+		return err
+	}
+	if err := serverSideEncryptionMeta(meta); err != nil {
+		return err
+	}
+	if err := g.ensureACLsAllowed(bucket, r.Header); err != nil {
+		return err
+	}
+	if hdr, ok := meta[TaggingHeader]; ok {
+		if _, err := parseTaggingHeader(hdr); err != nil {
+			return err
+		}
+	}
+	retention, err := g.requestRetention(bucket, meta)
 	if err != nil {
 		return err
 	}
-
-	result, err := g.storage.PutObject(bucket, key, meta, rdr, fileHeader.Size)
+	cond, err := parseWriteCondition(r.Header)
 	if err != nil {
 		return err
 	}
-	if result.VersionID != "" {
-		w.Header().Set("x-amz-version-id", string(result.VersionID))
-	}
-
-	w.Header().Set("ETag", `"`+hex.EncodeToString(rdr.Sum(nil))+`"`)
-	return nil
-}
-
-// CreateObject creates a new S3 object.
-func (g *GoFakeS3) createObject(bucket, object string, w http.ResponseWriter, r *http.Request) (err error) {
-	g.log.Print(LogInfo, "CREATE OBJECT:", bucket, object)
 
-	if err := g.ensureBucketExists(bucket); err != nil {
-		return err
-	}
+	// The conditional create check and the write must not be interleaved
+	// with another write to the same key:
+	unlock := g.objectLocks.lock(bucket, object)
+	defer unlock()
 
-	meta, err := metadataHeaders(r.Header, g.timeSource.Now(), g.metadataSizeLimit)
-	if err != nil {
+	if err := g.checkWriteCondition(bucket, object, cond); err != nil {
 		return err
 	}
 
 	if _, ok := meta["X-Amz-Copy-Source"]; ok {
-		return g.copyObject(bucket, object, meta, w, r)
+		return g.copyObject(bucket, object, meta, ssec, retention, w, r)
 	}
+	g.applyBucketDefaultMetadata(bucket, meta)
 
-	contentLength := r.Header.Get("Content-Length")
-	if contentLength == "" {
-		return ErrMissingContentLength
+	checksumHeader, err := requestChecksumHeader(r.Header)
+	if err != nil {
+		return err
 	}
+	stripChecksumHeaders(meta)
 
-	size, err := strconv.ParseInt(contentLength, 10, 64)
-	if err != nil || size < 0 {
-		w.WriteHeader(http.StatusBadRequest) // XXX: no code for this, according to s3tests
-		return nil
+	// aws-chunked bodies carry their size in X-Amz-Decoded-Content-Length, so
+	// they don't need a Content-Length:
+	var body io.Reader = r.Body
+	var size int64
+	if contentLength := r.Header.Get("Content-Length"); contentLength != "" {
+		size, err = strconv.ParseInt(contentLength, 10, 64)
+		if err != nil || size < 0 {
+			w.WriteHeader(http.StatusBadRequest) // XXX: no code for this, according to s3tests
+			return nil
+		}
+	} else if !strings.HasPrefix(meta["X-Amz-Content-Sha256"], "STREAMING-") {
+		body, size, err = g.readUnsizedBody(r)
+		if err != nil {
+			return err
+		}
 	}
 
 	if len(object) > KeySizeLimit {
@@ -609,17 +1360,26 @@ func (g *GoFakeS3) createObject(bucket, object string, w http.ResponseWriter, r
 		}
 	}
 
-	var reader io.Reader
+	reader, decodedSize, streaming, err := decodeStreamingBody(r.Header, body)
+	if err != nil {
+		return err
+	}
+	if streaming {
+		reader, size = g.checkDecodedLength(reader, decodedSize), decodedSize
+		delete(meta, "X-Amz-Trailer")
+		stripAWSChunkedEncoding(meta)
+	}
 
-	if sha, ok := meta["X-Amz-Content-Sha256"]; ok && sha == "STREAMING-AWS4-HMAC-SHA256-PAYLOAD" {
-		reader = newChunkedReader(r.Body)
-		size, err = strconv.ParseInt(meta["X-Amz-Decoded-Content-Length"], 10, 64)
-		if err != nil {
-			w.WriteHeader(http.StatusBadRequest) // XXX: no code for this, according to s3tests
-			return nil
-		}
-	} else {
-		reader = r.Body
+	if meta["Content-Type"] == "" {
+		meta["Content-Type"], reader = g.contentType(object, reader)
+	}
+
+	// A checksum sent as a trailer has already been checked by the
+	// trailerChunkedReader:
+	var checksum *checksumReader
+	if checksumHeader != "" {
+		checksum = newChecksumReader(reader, checksumHeader, r.Header.Get(checksumHeader))
+		reader = checksum
 	}
 
 	// hashingReader is still needed to get the ETag even if integrityCheck
@@ -630,22 +1390,166 @@ func (g *GoFakeS3) createObject(bucket, object string, w http.ResponseWriter, r
 		return err
 	}
 
-	result, err := g.storage.PutObject(bucket, object, meta, rdr, size)
+	setCreated := g.objectCreation(bucket, object)
+	result, err := g.putObject(bucket, object, cond, meta, g.limitWrite(rdr), size)
 	if err != nil {
 		return err
 	}
+	g.configs.deleteObject(bucket, object, result.VersionID)
+	g.setObjectOwner(bucket, object, result.VersionID, r)
+	setCreated(result.VersionID)
+	g.setObjectRetention(bucket, object, result.VersionID, retention)
 
 	if result.VersionID != "" {
 		g.log.Print(LogInfo, "CREATED VERSION:", bucket, object, result.VersionID)
 		w.Header().Set("x-amz-version-id", string(result.VersionID))
 	}
-	w.Header().Set("ETag", `"`+hex.EncodeToString(rdr.Sum(nil))+`"`)
+	w.Header().Set("ETag", hashETag(rdr.Sum(nil)))
+	ssec.writeHeaders(w)
+	writeServerSideEncryptionHeaders(meta, w)
+	if checksum != nil {
+		sum := checksum.checksum()
+		g.configs.put(resourceConfigKey{bucket: bucket, object: object, version: result.VersionID, kind: objectChecksumConfig}, sum)
+		w.Header().Set(sum.header, sum.value)
+	}
+
+	return nil
+}
+
+// writeCondition is the condition a conditional write places on the current
+// object at its key.
+type writeCondition struct {
+	conditionalRequest
+
+	// etag is the unquoted ETag the current object must have, or empty if
+	// there must be no current object.
+	etag string
+}
+
+// parseWriteCondition returns the condition set by a write's 'If-Match' or
+// 'If-None-Match: *' header, or nil if it has neither. Like S3, no other
+// value of 'If-None-Match' is supported, and the two cannot be combined.
+func parseWriteCondition(h http.Header) (*writeCondition, error) {
+	// Writes ignore the date conditions, so there is no need for the time:
+	c := parseConditionalRequest(h, time.Time{})
+	ifMatch, ifNoneMatch := c.ifMatch, c.ifNoneMatch
+	switch {
+	case ifMatch == "" && ifNoneMatch == "":
+		return nil, nil
+	case ifNoneMatch == "*" && ifMatch == "":
+		return &writeCondition{conditionalRequest: c}, nil
+	case ifNoneMatch == "" && ifMatch != "*" && !strings.Contains(ifMatch, ","):
+		return &writeCondition{conditionalRequest: c, etag: strings.Trim(ifMatch, `"`)}, nil
+	default:
+		return nil, ErrorMessage(ErrNotImplemented, "A header you provided implies functionality that is not implemented")
+	}
+}
+
+// checkWriteCondition implements conditional writes, returning
+// ErrPreconditionFailed if the condition does not hold, or ErrNoSuchKey if
+// an ETag was expected but there is no object. The caller must hold the
+// key's lock in objectLocks.
+func (g *GoFakeS3) checkWriteCondition(bucket, object string, cond *writeCondition) error {
+	if cond == nil {
+		return nil
+	}
 
+	exists, etag, err := g.currentETag(bucket, object)
+	if err != nil {
+		return err
+	}
+	if err := cond.check(http.MethodPut, exists, etag, time.Time{}); err == ErrNoSuchKey {
+		return KeyNotFound(object)
+	} else if err != nil {
+		return err
+	}
 	return nil
 }
 
-// CopyObject copies an existing S3 object
-func (g *GoFakeS3) copyObject(bucket, object string, meta map[string]string, w http.ResponseWriter, r *http.Request) (err error) {
+// currentETag returns the ETag of the current object at a key, which
+// conditional writes and deletes are checked against, if there is one.
+func (g *GoFakeS3) currentETag(bucket, object string) (exists bool, etag string, err error) {
+	obj, err := g.storage.HeadObject(bucket, object)
+	if HasErrorCode(err, ErrNoSuchKey) {
+		return false, "", nil
+	} else if err != nil {
+		return false, "", err
+	}
+	if obj.IsDeleteMarker {
+		return false, "", nil
+	}
+	return true, hashETag(obj.Hash), nil
+}
+
+// putObject stores the object of a PutObject request. A conditional write
+// is checked again by the Backend, if it is a ConditionalPutBackend, so that
+// it is also atomic with respect to writes that bypass this GoFakeS3.
+func (g *GoFakeS3) putObject(bucket, object string, cond *writeCondition, meta map[string]string, input io.Reader, size int64) (PutObjectResult, error) {
+	if cas, ok := g.storage.(ConditionalPutBackend); ok && cond != nil {
+		return cas.PutObjectIfMatch(bucket, object, cond.etag, meta, input, size)
+	}
+	return g.storage.PutObject(bucket, object, meta, input, size)
+}
+
+// readUnsizedBody buffers the body of a request that was sent without a
+// Content-Length, so that its size is known before it is passed to the
+// Backend. If WithRequireContentLength is used, ErrMissingContentLength is
+// returned instead.
+func (g *GoFakeS3) readUnsizedBody(r *http.Request) (body io.Reader, size int64, err error) {
+	if g.requireContentLength {
+		return nil, 0, ErrMissingContentLength
+	}
+	b, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return nil, 0, err
+	}
+	return bytes.NewReader(b), int64(len(b)), nil
+}
+
+// stripAWSChunkedEncoding removes the 'aws-chunked' transfer framing from the
+// stored Content-Encoding; it describes the request body, not the object.
+func stripAWSChunkedEncoding(meta map[string]string) {
+	enc, ok := meta["Content-Encoding"]
+	if !ok {
+		return
+	}
+	var keep []string
+	for _, v := range strings.Split(enc, ",") {
+		if v = strings.TrimSpace(v); v != "" && v != "aws-chunked" {
+			keep = append(keep, v)
+		}
+	}
+	if len(keep) == 0 {
+		delete(meta, "Content-Encoding")
+	} else {
+		meta["Content-Encoding"] = strings.Join(keep, ",")
+	}
+}
+
+// MetadataDirectiveHeader may be sent with CopyObject to choose whether the
+// destination keeps the source's metadata ('COPY', the default) or takes the
+// metadata sent with the request instead ('REPLACE').
+const MetadataDirectiveHeader = "X-Amz-Metadata-Directive"
+
+const (
+	MetadataDirectiveCopy    = "COPY"
+	MetadataDirectiveReplace = "REPLACE"
+)
+
+// selfCopyAttributes are the headers that, like S3, allow an object to be
+// copied onto itself with the COPY metadata directive, as long as one of them
+// is changed by the copy.
+var selfCopyAttributes = []string{
+	"X-Amz-Server-Side-Encryption",
+	SSECustomerKeyMD5Header,
+	"X-Amz-Storage-Class",
+	"X-Amz-Website-Redirect-Location",
+}
+
+// CopyObject copies an existing S3 object, named by the X-Amz-Copy-Source
+// header. ssec is the SSE-C key the copy is stored with, if any; the source's
+// key is taken from the copy source SSE-C headers.
+func (g *GoFakeS3) copyObject(bucket, object string, meta map[string]string, ssec *sseCustomerKey, retention *ObjectRetention, w http.ResponseWriter, r *http.Request) (err error) {
 	if err := g.ensureBucketExists(bucket); err != nil {
 		return err
 	}
@@ -657,12 +1561,7 @@ func (g *GoFakeS3) copyObject(bucket, object string, meta map[string]string, w h
 		return ResourceError(ErrKeyTooLong, object)
 	}
 
-	// XXX No support for versionId subresource
-	parts := strings.SplitN(strings.TrimPrefix(source, "/"), "/", 2)
-	srcBucket := parts[0]
-	srcKey := strings.SplitN(parts[1], "?", 2)[0]
-
-	srcKey, err = url.QueryUnescape(srcKey)
+	srcBucket, srcKey, err := parseCopySource(source)
 	if err != nil {
 		return err
 	}
@@ -681,17 +1580,104 @@ func (g *GoFakeS3) copyObject(bucket, object string, meta map[string]string, w h
 	// "If the current version of the object is a delete marker, Amazon S3
 	// behaves as if the object was deleted."
 
-	// merge metadata, ACL is not preserved
-	for k, v := range srcObj.Metadata {
-		if _, found := meta[k]; !found && k != "X-Amz-Acl" {
-			meta[k] = v
+	if srcObj.Size > CopySourceSizeLimit {
+		return ErrorMessagef(ErrInvalidRequest, "The specified copy source is larger than the maximum allowable size for a copy source: %d", CopySourceSizeLimit)
+	}
+
+	srcSSEC, err := parseSSECustomerKey(r.Header, CopySourceSSECustomerPrefix)
+	if err != nil {
+		return err
+	}
+	if err := checkSSECustomerKey(srcObj.Metadata, srcSSEC); err != nil {
+		return err
+	}
+	for _, hdr := range []string{SSECustomerAlgorithmHeader, SSECustomerKeyHeader, SSECustomerKeyMD5Header} {
+		delete(meta, ssecHeader(CopySourceSSECustomerPrefix, hdr))
+	}
+
+	metadataDirective := meta[MetadataDirectiveHeader]
+	delete(meta, MetadataDirectiveHeader)
+	switch metadataDirective {
+	case "", MetadataDirectiveCopy, MetadataDirectiveReplace:
+	default:
+		return ErrorInvalidArgument(MetadataDirectiveHeader, metadataDirective, "Unknown metadata directive.")
+	}
+
+	if srcBucket == bucket && srcKey == object && metadataDirective != MetadataDirectiveReplace {
+		changed := false
+		for _, attr := range selfCopyAttributes {
+			if v, ok := meta[attr]; ok && v != srcObj.Metadata[attr] {
+				changed = true
+			}
+		}
+		if !changed {
+			return ErrorMessage(ErrInvalidRequest, "This copy request is illegal because it is trying to copy an object to itself without changing the object's metadata, storage class, website redirect location or encryption attributes.")
+		}
+	}
+
+	directive := meta[TaggingDirectiveHeader]
+	delete(meta, TaggingDirectiveHeader)
+	tagging, hasTags := meta[TaggingHeader]
+
+	switch directive {
+	case "", TaggingDirectiveCopy:
+		// The source's tags may have been set with PutObjectTagging rather
+		// than the TaggingHeader, so they are copied explicitly:
+		tags, err := g.objectTagging(srcBucket, srcKey, srcObj)
+		if err != nil {
+			return err
 		}
+		tagging, hasTags = formatTaggingHeader(tags), len(tags.TagSet) > 0
+	case TaggingDirectiveReplace:
+	default:
+		return ErrorInvalidArgument(TaggingDirectiveHeader, directive, "Unknown tagging directive.")
+	}
+
+	if metadataDirective == MetadataDirectiveReplace {
+		if meta["Content-Type"] == "" {
+			meta["Content-Type"], _ = g.contentType(object, nil)
+		}
+	} else {
+		// merge metadata, ACL is not preserved. Nor is the storage class,
+		// which like S3 is taken from the request whatever the directive, and
+		// is STANDARD if the request does not give one:
+		for k, v := range srcObj.Metadata {
+			// The source may predate canonicalMetadataKey:
+			k = canonicalMetadataKey(k)
+			if _, found := meta[k]; !found && k != "X-Amz-Acl" && !strings.HasPrefix(k, "X-Amz-Grant-") && k != "X-Amz-Storage-Class" {
+				meta[k] = v
+			}
+		}
+	}
+	if hasTags {
+		meta[TaggingHeader] = tagging
+	} else {
+		delete(meta, TaggingHeader)
 	}
 
-	result, err := g.storage.PutObject(bucket, object, meta, srcObj.Contents, srcObj.Size)
+	// The copy is only encrypted with a key if the request supplied one;
+	// the source's key is not merged in with its metadata:
+	if ssec == nil {
+		delete(meta, SSECustomerAlgorithmHeader)
+		delete(meta, SSECustomerKeyMD5Header)
+	}
+
+	// The request's metadata was checked by metadataHeaders, but what was
+	// merged in from the source may take it over the limits:
+	if err := checkMetadataLimits(meta, g.metadataSizeLimit, g.metadataCountLimit); err != nil {
+		return err
+	}
+	g.applyBucketDefaultMetadata(bucket, meta)
+
+	setCreated := g.objectCreation(bucket, object)
+	result, err := g.putCopy(srcBucket, srcKey, srcObj, bucket, object, meta)
 	if err != nil {
 		return err
 	}
+	g.configs.deleteObject(bucket, object, result.VersionID)
+	g.setObjectOwner(bucket, object, result.VersionID, r)
+	setCreated(result.VersionID)
+	g.setObjectRetention(bucket, object, result.VersionID, retention)
 
 	if srcObj.VersionID != "" {
 		w.Header().Set("x-amz-copy-source-version-id", string(srcObj.VersionID))
@@ -700,28 +1686,82 @@ func (g *GoFakeS3) copyObject(bucket, object string, meta map[string]string, w h
 		g.log.Print(LogInfo, "CREATED VERSION:", bucket, object, result.VersionID)
 		w.Header().Set("x-amz-version-id", string(result.VersionID))
 	}
+	ssec.writeHeaders(w)
 
 	return g.xmlEncoder(w).Encode(CopyObjectResult{
-		ETag:         `"` + hex.EncodeToString(srcObj.Hash) + `"`,
+		ETag:         hashETag(srcObj.Hash),
 		LastModified: NewContentTime(g.timeSource.Now()),
 	})
 }
 
+// parseCopySource returns the bucket and key named by an X-Amz-Copy-Source
+// header.
+//
+// XXX No support for versionId subresource
+func parseCopySource(source string) (bucket, key string, err error) {
+	parts := strings.SplitN(strings.TrimPrefix(source, "/"), "/", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", ErrorInvalidArgument("x-amz-copy-source", source, "Invalid copy source object key")
+	}
+	key, err = url.QueryUnescape(strings.SplitN(parts[1], "?", 2)[0])
+	if err != nil {
+		return "", "", err
+	}
+	return parts[0], key, nil
+}
+
+// putCopy stores the destination of a CopyObject request. When an object is
+// copied onto itself, the Backend may be able to change its metadata without
+// rewriting the contents; see MetadataUpdatingBackend.
+func (g *GoFakeS3) putCopy(srcBucket, srcKey string, srcObj *Object, bucket, object string, meta map[string]string) (PutObjectResult, error) {
+	if updater, ok := g.storage.(MetadataUpdatingBackend); ok && srcBucket == bucket && srcKey == object {
+		err := updater.UpdateObjectMetadata(bucket, object, meta)
+		if !HasErrorCode(err, ErrNotImplemented) {
+			return PutObjectResult{}, err
+		}
+	}
+	return g.storage.PutObject(bucket, object, meta, g.limitWrite(srcObj.Contents), srcObj.Size)
+}
+
 func (g *GoFakeS3) deleteObject(bucket, object string, w http.ResponseWriter, r *http.Request) error {
 	g.log.Print(LogInfo, "DELETE:", bucket, object)
 	if err := g.ensureBucketExists(bucket); err != nil {
 		return err
 	}
 
+	// Like a conditional write, the check and the delete must not be
+	// interleaved with a write to the same key:
+	if cond := parseConditionalRequest(r.Header, g.timeSource.Now()); cond.ifMatch != "" {
+		unlock := g.objectLocks.lock(bucket, object)
+		defer unlock()
+
+		exists, etag, err := g.currentETag(bucket, object)
+		if err != nil {
+			return err
+		}
+		if err := cond.check(http.MethodDelete, exists, etag, time.Time{}); err != nil {
+			return err
+		}
+	}
+
 	result, err := g.storage.DeleteObject(bucket, object)
+	if HasErrorCode(err, ErrNoSuchKey) {
+		// Backends should not report a missing key, but like S3, deletes
+		// succeed whether or not there was anything to delete:
+		result, err = ObjectDeleteResult{}, nil
+	}
 	if err != nil {
 		return err
 	}
+	if result.VersionID == "" {
+		// Without versioning, the object is gone rather than hidden behind a
+		// delete marker:
+		g.configs.deleteObject(bucket, object, "")
+	}
 
+	// Like S3, the header is left out unless it is true:
 	if result.IsDeleteMarker {
 		w.Header().Set("x-amz-delete-marker", "true")
-	} else {
-		w.Header().Set("x-amz-delete-marker", "false")
 	}
 
 	if result.VersionID != "" {
@@ -746,12 +1786,12 @@ func (g *GoFakeS3) deleteObjectVersion(bucket, object string, version VersionID,
 	if err != nil {
 		return err
 	}
+	g.configs.deleteObject(bucket, object, version)
 	g.log.Print(LogInfo, "DELETED VERSION:", bucket, object, version)
 
+	// Like S3, the header is left out unless it is true:
 	if result.IsDeleteMarker {
 		w.Header().Set("x-amz-delete-marker", "true")
-	} else {
-		w.Header().Set("x-amz-delete-marker", "false")
 	}
 
 	if result.VersionID != "" {
@@ -772,29 +1812,56 @@ func (g *GoFakeS3) deleteMulti(bucket string, w http.ResponseWriter, r *http.Req
 	}
 
 	var in DeleteRequest
-
-	defer r.Body.Close()
-	dc := xml.NewDecoder(r.Body)
-	if err := dc.Decode(&in); err != nil {
-		return ErrorMessage(ErrMalformedXML, err.Error())
+	if err := g.xmlDecodeBody(r.Body, &in); err != nil {
+		return err
+	}
+	if len(in.Objects) == 0 {
+		return ErrMalformedXML
+	}
+
+	// Objects protected by Object Lock are reported as errors, without being
+	// passed to the Backend:
+	bypass := strings.EqualFold(r.Header.Get(BypassGovernanceRetentionHeader), "true")
+	var objects []ObjectID
+	var locked []ErrorResult
+	for _, o := range in.Objects {
+		if err := g.checkDeleteLock(bucket, o, bypass); HasErrorCode(err, ErrAccessDenied) {
+			locked = append(locked, ErrorResult{
+				Key:       o.Key,
+				VersionID: o.VersionID,
+				Code:      ErrAccessDenied,
+				Message:   "Access Denied because object protected by object lock.",
+			})
+		} else if err != nil {
+			return err
+		} else {
+			objects = append(objects, o)
+		}
 	}
 
 	var err error
 	var out MultiDeleteResult
-	if g.versioned == nil {
-		keys := make([]string, len(in.Objects))
-		for i, o := range in.Objects {
+	switch {
+	case len(objects) == 0:
+	case g.versioned == nil:
+		keys := make([]string, len(objects))
+		for i, o := range objects {
 			keys[i] = o.Key
 		}
 
 		out, err = g.storage.DeleteMulti(bucket, keys...)
-	} else {
-		out, err = g.versioned.DeleteMultiVersions(bucket, in.Objects...)
+	default:
+		out, err = g.versioned.DeleteMultiVersions(bucket, objects...)
 	}
 
 	if err != nil {
 		return err
 	}
+	out.Error = append(out.Error, locked...)
+
+	for _, deleted := range out.Deleted {
+		g.configs.deleteObject(bucket, deleted.Key, VersionID(deleted.VersionID))
+	}
 
 	if in.Quiet {
 		out.Deleted = nil
@@ -806,13 +1873,35 @@ func (g *GoFakeS3) deleteMulti(bucket string, w http.ResponseWriter, r *http.Req
 func (g *GoFakeS3) initiateMultipartUpload(bucket, object string, w http.ResponseWriter, r *http.Request) error {
 	g.log.Print(LogInfo, "initiate multipart upload", bucket, object)
 
-	meta, err := metadataHeaders(r.Header, g.timeSource.Now(), g.metadataSizeLimit)
+	meta, err := metadataHeaders(r.Header, g.timeSource.Now(), g.metadataSizeLimit, g.metadataCountLimit)
 	if err != nil {
 		return err
 	}
+	if err := g.applyObjectTTL(meta); err != nil {
+		return err
+	}
+	if _, err := sseCustomerMeta(meta, r.Header); err != nil {
+		return err
+	}
+	if err := serverSideEncryptionMeta(meta); err != nil {
+		return err
+	}
 	if err := g.ensureBucketExists(bucket); err != nil {
 		return err
 	}
+	g.applyBucketDefaultMetadata(bucket, meta)
+	if err := g.ensureACLsAllowed(bucket, r.Header); err != nil {
+		return err
+	}
+	if hdr, ok := meta[TaggingHeader]; ok {
+		if _, err := parseTaggingHeader(hdr); err != nil {
+			return err
+		}
+	}
+
+	if meta["Content-Type"] == "" {
+		meta["Content-Type"], _ = g.contentType(object, nil)
+	}
 
 	id, err := g.multipart.CreateMultipartUpload(bucket, object, meta, g.timeSource.Now())
 	if err != nil {
@@ -823,6 +1912,7 @@ func (g *GoFakeS3) initiateMultipartUpload(bucket, object string, w http.Respons
 		Bucket:   bucket,
 		Key:      object,
 	}
+	writeServerSideEncryptionHeaders(meta, w)
 	return g.xmlEncoder(w).Encode(out)
 }
 
@@ -837,17 +1927,34 @@ func (g *GoFakeS3) putMultipartUploadPart(bucket, object string, uploadID Upload
 	g.log.Print(LogInfo, "put multipart upload", bucket, object, uploadID)
 
 	partNumber, err := strconv.ParseInt(r.URL.Query().Get("partNumber"), 10, 0)
-	if err != nil || partNumber <= 0 || partNumber > MaxUploadPartNumber {
-		return ErrInvalidPart
+	if err != nil || partNumber <= 0 || partNumber > int64(g.maxUploadParts) {
+		return ErrorInvalidArgument("partNumber", r.URL.Query().Get("partNumber"), fmt.Sprintf("Part number must be an integer between 1 and %d, inclusive", g.maxUploadParts))
 	}
 
-	size, err := strconv.ParseInt(r.Header.Get("Content-Length"), 10, 64)
-	if err != nil || size <= 0 {
-		return ErrMissingContentLength
+	defer r.Body.Close()
+	unlock := g.uploadLocks.lockUpload(bucket, object, uploadID, false)
+	defer unlock()
+
+	if source := r.Header.Get("X-Amz-Copy-Source"); source != "" {
+		return g.copyMultipartUploadPart(bucket, object, uploadID, int(partNumber), source, w, r)
 	}
 
-	defer r.Body.Close()
-	var rdr io.Reader = r.Body
+	// Like objects, streaming parts are sized by X-Amz-Decoded-Content-Length
+	// rather than the framed Content-Length:
+	rdr, size, streaming, err := decodeStreamingBody(r.Header, r.Body)
+	if err != nil {
+		return err
+	}
+	if streaming {
+		rdr = g.checkDecodedLength(rdr, size)
+	} else if contentLength := r.Header.Get("Content-Length"); contentLength != "" {
+		size, err = strconv.ParseInt(contentLength, 10, 64)
+		if err != nil || size < 0 {
+			return ErrMissingContentLength
+		}
+	} else if rdr, size, err = g.readUnsizedBody(r); err != nil {
+		return err
+	}
 
 	if g.integrityCheck {
 		md5Base64 := r.Header.Get("Content-MD5")
@@ -864,15 +1971,72 @@ func (g *GoFakeS3) putMultipartUploadPart(bucket, object string, uploadID Upload
 		}
 	}
 
-	etag, err := g.multipart.UploadPart(bucket, object, uploadID, int(partNumber), rdr, r.ContentLength, g.timeSource.Now())
+	etag, err := g.multipart.UploadPart(bucket, object, uploadID, int(partNumber), g.limitWrite(rdr), size, g.timeSource.Now())
 	if err != nil {
 		return err
 	}
 
-	w.Header().Add("ETag", etag)
+	w.Header().Add("ETag", quoteETag(etag))
 	return nil
 }
 
+// copyMultipartUploadPart handles UploadPartCopy, which uploads a part from
+// the object named by the X-Amz-Copy-Source header, or the range of it given
+// by X-Amz-Copy-Source-Range, rather than from the request body.
+func (g *GoFakeS3) copyMultipartUploadPart(bucket, object string, uploadID UploadID, partNumber int, source string, w http.ResponseWriter, r *http.Request) error {
+	g.log.Print(LogInfo, "copy multipart upload part", source, "TO", bucket, object, uploadID)
+
+	srcBucket, srcKey, err := parseCopySource(source)
+	if err != nil {
+		return err
+	}
+	// Unlike a GET, a copy may not ask for several ranges, which
+	// parseRangeHeader would ignore:
+	copyRange := r.Header.Get("X-Amz-Copy-Source-Range")
+	if strings.Contains(copyRange, ",") {
+		return ErrorInvalidArgument("x-amz-copy-source-range", copyRange, "The x-amz-copy-source-range value must be of the form bytes=first-last where first and last are the zero-based offsets of the first and last bytes to copy")
+	}
+	rnge, err := parseRangeHeader(copyRange)
+	if err != nil {
+		return err
+	}
+	srcObj, err := g.storage.GetObject(srcBucket, srcKey, rnge)
+	if err != nil {
+		return err
+	}
+	if srcObj == nil {
+		g.log.Print(LogErr, "unexpected nil object for key", srcBucket, srcKey)
+		return ErrInternal
+	}
+	defer srcObj.Contents.Close()
+
+	srcSSEC, err := parseSSECustomerKey(r.Header, CopySourceSSECustomerPrefix)
+	if err != nil {
+		return err
+	}
+	if err := checkSSECustomerKey(srcObj.Metadata, srcSSEC); err != nil {
+		return err
+	}
+
+	size := srcObj.Size
+	if srcObj.Range != nil {
+		size = srcObj.Range.Length
+	}
+	now := g.timeSource.Now()
+	etag, err := g.multipart.UploadPart(bucket, object, uploadID, partNumber, g.limitWrite(srcObj.Contents), size, now)
+	if err != nil {
+		return err
+	}
+
+	if srcObj.VersionID != "" {
+		w.Header().Set("x-amz-copy-source-version-id", string(srcObj.VersionID))
+	}
+	return g.xmlEncoder(w).Encode(CopyPartResult{
+		ETag:         quoteETag(etag),
+		LastModified: NewContentTime(now),
+	})
+}
+
 func (g *GoFakeS3) abortMultipartUpload(bucket, object string, uploadID UploadID, w http.ResponseWriter, r *http.Request) error {
 	g.log.Print(LogInfo, "abort multipart upload", bucket, object, uploadID)
 	if err := g.multipart.AbortMultipartUpload(bucket, object, uploadID); err != nil {
@@ -889,18 +2053,69 @@ func (g *GoFakeS3) completeMultipartUpload(bucket, object string, uploadID Uploa
 	if err := g.xmlDecodeBody(r.Body, &in); err != nil {
 		return err
 	}
+	if len(in.Parts) == 0 {
+		return ErrMalformedXML
+	}
+	if len(in.Parts) > g.maxUploadParts {
+		return ErrorInvalidArgument("Part", strconv.Itoa(len(in.Parts)), fmt.Sprintf("Part count must be no more than %d", g.maxUploadParts))
+	}
+
+	// As with createObject, a failed condition leaves the upload in place,
+	// so the client can still decide whether to abort it:
+	cond, err := parseWriteCondition(r.Header)
+	if err != nil {
+		return err
+	}
+	unlock := g.objectLocks.lock(bucket, object)
+	defer unlock()
 
-	result, etag, err := g.multipart.CompleteMultipartUpload(bucket, object, uploadID, &in)
+	// Parts written while the upload is being completed could otherwise be
+	// missed by the size checks, or land after the parts have been
+	// assembled, and be lost:
+	unlockUpload := g.uploadLocks.lockUpload(bucket, object, uploadID, true)
+	defer unlockUpload()
+
+	if err := g.checkWriteCondition(bucket, object, cond); err != nil {
+		return err
+	}
+
+	parts, err := g.uploadedParts(bucket, object, uploadID, &in)
 	if err != nil {
 		return err
 	}
+	// Every part but the last must be at least the minimum size. If a part
+	// is missing, parts is nil, and the Backend reports it:
+	for i, part := range parts {
+		if i < len(parts)-1 && part.size < g.minPartSize {
+			return ErrEntityTooSmall
+		}
+	}
+
+	setCreated := g.objectCreation(bucket, object)
+	result, etag, err := g.multipart.CompleteMultipartUpload(bucket, object, uploadID, &in)
+	if err != nil {
+		// S3 sends '200 OK' once it has accepted the request, and keeps the
+		// connection alive while the parts are assembled, so a failure after
+		// that can only be reported in the body:
+		resp := ensureErrorResponse(err, w.Header().Get("x-amz-request-id"), w.Header().Get("x-amz-id-2"))
+		if status := resp.ErrorCode().Status(); status < 500 || status == http.StatusNotImplemented {
+			return err
+		}
+		g.log.Print(LogErr, "complete multipart upload failed:", err)
+		return g.xmlEncoder(w).Encode(resp)
+	}
+	g.configs.deleteObject(bucket, object, result.VersionID)
+	g.configs.put(resourceConfigKey{bucket: bucket, object: object, version: result.VersionID, kind: objectPartsConfig}, parts)
+	g.setObjectOwner(bucket, object, result.VersionID, r)
+	setCreated(result.VersionID)
+	g.setObjectRetention(bucket, object, result.VersionID, nil)
 
 	if result.VersionID != "" {
 		w.Header().Set("x-amz-version-id", string(result.VersionID))
 	}
 
 	return g.xmlEncoder(w).Encode(&CompleteMultipartUploadResult{
-		ETag:   etag,
+		ETag:   quoteETag(etag),
 		Bucket: bucket,
 		Key:    object,
 	})
@@ -915,9 +2130,9 @@ func (g *GoFakeS3) listMultipartUploads(bucket string, w http.ResponseWriter, r
 	prefix := prefixFromQuery(query)
 	marker := uploadListMarkerFromQuery(query)
 
-	maxUploads, err := parseClampedInt(query.Get("max-uploads"), DefaultMaxUploads, 0, MaxUploadsLimit)
+	maxUploads, err := parseListParam("max-uploads", "max-uploads", query.Get("max-uploads"), DefaultMaxUploads, MaxUploadsLimit)
 	if err != nil {
-		return ErrInvalidURI
+		return err
 	}
 	if maxUploads == 0 {
 		maxUploads = DefaultMaxUploads
@@ -938,20 +2153,23 @@ func (g *GoFakeS3) listMultipartUploadParts(bucket, object string, uploadID Uplo
 
 	query := r.URL.Query()
 
-	marker, err := parseClampedInt(query.Get("part-number-marker"), 0, 0, math.MaxInt64)
+	marker, err := parseListParam("part-number-marker", "part-number-marker", query.Get("part-number-marker"), 0, math.MaxInt64)
 	if err != nil {
-		return ErrInvalidURI
+		return err
 	}
 
-	maxParts, err := parseClampedInt(query.Get("max-parts"), DefaultMaxUploadParts, 0, MaxUploadPartsLimit)
+	maxParts, err := parseListParam("max-parts", "max-parts", query.Get("max-parts"), DefaultMaxUploadParts, MaxUploadPartsLimit)
 	if err != nil {
-		return ErrInvalidURI
+		return err
 	}
 
 	out, err := g.multipart.ListParts(bucket, object, uploadID, int(marker), maxParts)
 	if err != nil {
 		return err
 	}
+	for i := range out.Parts {
+		out.Parts[i].ETag = quoteETag(out.Parts[i].ETag)
+	}
 
 	return g.xmlEncoder(w).Encode(out)
 }
@@ -983,6 +2201,9 @@ func (g *GoFakeS3) putBucketVersioning(bucket string, w http.ResponseWriter, r *
 	if err := g.xmlDecodeBody(r.Body, &in); err != nil {
 		return err
 	}
+	if _, ok := g.bucketObjectLock(bucket); ok && in.Status != VersioningEnabled {
+		return ErrorMessage(ErrInvalidBucketState, "An Object Lock configuration is present on this bucket, so the versioning state cannot be changed.")
+	}
 
 	if g.versioned == nil {
 		if in.MFADelete == MFADeleteEnabled || in.Status == VersioningEnabled {
@@ -1028,12 +2249,17 @@ func (g *GoFakeS3) xmlEncoder(w http.ResponseWriter) *xml.Encoder {
 func (g *GoFakeS3) xmlDecodeBody(rdr io.ReadCloser, into interface{}) error {
 	body, err := ioutil.ReadAll(rdr)
 	defer rdr.Close()
-	if err != nil {
+	if err == io.ErrUnexpectedEOF {
+		return ErrIncompleteBody
+	} else if err != nil {
 		return err
 	}
 
+	// S3 doesn't say what was wrong with the XML, so neither do we, but
+	// the reason is logged to help debug clients:
 	if err := xml.Unmarshal(body, into); err != nil {
-		return ErrorMessage(ErrMalformedXML, err.Error())
+		g.log.Print(LogWarn, "malformed XML body:", err)
+		return ErrMalformedXML
 	}
 
 	return nil
@@ -1048,35 +2274,117 @@ func formatHeaderTime(t time.Time) string {
 	return tc.Format("Mon, 02 Jan 2006 15:04:05") + " GMT"
 }
 
+// metadataKeyPrefix starts the key of every user-defined metadata entry.
+const metadataKeyPrefix = "x-amz-meta-"
+
+// isMetadataKey reports whether a metadata key holds user-defined metadata,
+// sent as an 'x-amz-meta-*' header.
+func isMetadataKey(key string) bool {
+	return len(key) >= len(metadataKeyPrefix) && strings.EqualFold(key[:len(metadataKeyPrefix)], metadataKeyPrefix)
+}
+
+// canonicalMetadataKey returns the key a metadata entry is stored under.
+// Like S3, which treats user-defined metadata keys case-insensitively and
+// always returns them in lower case, 'x-amz-meta-*' keys are lowercased, so a
+// PUT with 'X-Amz-Meta-Foo' is returned as 'x-amz-meta-foo'. Other keys keep
+// the canonical form of their header, like 'Content-Type'.
+func canonicalMetadataKey(key string) string {
+	if isMetadataKey(key) {
+		return strings.ToLower(key)
+	}
+	return key
+}
+
+// isHeaderSafe reports whether a user-defined metadata value can be returned
+// in a header as it is, i.e. it is printable ASCII. Like S3, values that
+// can't be are left out of responses and counted in 'x-amz-missing-meta'.
+func isHeaderSafe(v string) bool {
+	for i := 0; i < len(v); i++ {
+		if c := v[i]; (c < ' ' && c != '\t') || c > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+// metadataSize returns the size of the user-defined metadata, which is the
+// only part of the metadata S3 includes when checking the limit:
+// https://docs.aws.amazon.com/AmazonS3/latest/dev/UsingMetadata.html
 func metadataSize(meta map[string]string) int {
 	total := 0
 	for k, v := range meta {
-		total += len(k) + len(v)
+		if isMetadataKey(k) {
+			total += len(k) + len(v)
+		}
+	}
+	return total
+}
+
+// metadataCount returns the number of user-defined metadata entries.
+func metadataCount(meta map[string]string) int {
+	total := 0
+	for k := range meta {
+		if isMetadataKey(k) {
+			total++
+		}
 	}
 	return total
 }
 
-func metadataHeaders(headers map[string][]string, at time.Time, sizeLimit int) (map[string]string, error) {
+func metadataHeaders(headers map[string][]string, at time.Time, sizeLimit int, countLimit int) (map[string]string, error) {
 	meta := make(map[string]string)
 	for hk, hv := range headers {
-		if strings.HasPrefix(hk, "X-Amz-") ||
+		if isMetadataKey(hk) {
+			meta[canonicalMetadataKey(hk)] = hv[0]
+		} else if strings.HasPrefix(hk, "X-Amz-") ||
 			hk == "Content-Type" ||
+			hk == "Cache-Control" ||
 			hk == "Content-Disposition" ||
-			hk == "Content-Encoding" {
+			hk == "Content-Encoding" ||
+			hk == "Content-Language" ||
+			hk == "Expires" {
 			meta[hk] = hv[0]
 		}
 	}
 	meta["Last-Modified"] = formatHeaderTime(at)
 
+	return meta, checkMetadataLimits(meta, sizeLimit, countLimit)
+}
+
+// checkMetadataLimits returns ErrMetadataTooLarge if the user-defined
+// metadata exceeds either limit. A limit of 0 is not enforced.
+func checkMetadataLimits(meta map[string]string, sizeLimit int, countLimit int) error {
 	if sizeLimit > 0 && metadataSize(meta) > sizeLimit {
-		return meta, ErrMetadataTooLarge
+		return ErrMetadataTooLarge
+	}
+	if countLimit > 0 && metadataCount(meta) > countLimit {
+		return ErrorMessagef(ErrMetadataTooLarge, "too many metadata entries; limit is %d", countLimit)
 	}
+	return nil
+}
+
+// parseMaxKeys parses the 'max-keys' parameter of a listing. Like S3, values
+// above max are clamped to it, but negative values are rejected.
+func parseMaxKeys(in string, defaultValue, max int64) (int64, error) {
+	return parseListParam("max-keys", "maxKeys", in, defaultValue, max)
+}
 
-	return meta, nil
+// parseListParam parses an integer query parameter of a listing, such as
+// 'max-parts' or 'part-number-marker'. Values above max are clamped to it,
+// but negative or malformed values are rejected with an InvalidArgument that
+// refers to the parameter as argument.
+func parseListParam(name, argument, in string, defaultValue, max int64) (int64, error) {
+	// Anything below -1 is clamped to -1, which is enough to tell that it was
+	// negative:
+	v, err := parseClampedInt(in, defaultValue, -1, max)
+	if err != nil || v < 0 {
+		return 0, ErrorInvalidArgument(name, in, fmt.Sprintf("Argument %s must be an integer between 0 and 2147483647", argument))
+	}
+	return v, nil
 }
 
 func listBucketPageFromQuery(query url.Values) (page ListBucketPage, rerr error) {
-	maxKeys, err := parseClampedInt(query.Get("max-keys"), DefaultMaxBucketKeys, 0, MaxBucketKeys)
+	maxKeys, err := parseMaxKeys(query.Get("max-keys"), DefaultMaxBucketKeys, MaxBucketKeys)
 	if err != nil {
 		return page, err
 	}
@@ -1109,7 +2417,7 @@ func listBucketPageFromQuery(query url.Values) (page ListBucketPage, rerr error)
 }
 
 func listBucketVersionsPageFromQuery(query url.Values) (page ListBucketVersionsPage, rerr error) {
-	maxKeys, err := parseClampedInt(query.Get("max-keys"), DefaultMaxBucketVersionKeys, 0, MaxBucketVersionKeys)
+	maxKeys, err := parseMaxKeys(query.Get("max-keys"), DefaultMaxBucketVersionKeys, MaxBucketVersionKeys)
 	if err != nil {
 		return page, err
 	}
@@ -8,6 +8,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 )
 
 func TestHttpError(t *testing.T) {
@@ -89,3 +90,33 @@ type failingResponseWriter struct {
 func (w *failingResponseWriter) Write(buf []byte) (n int, err error) {
 	return 0, fmt.Errorf("nope")
 }
+
+func TestUploaderAbortReleasesParts(t *testing.T) {
+	u := newUploader()
+	up, err := u.Begin("bucket", "object", nil, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := up.AddPart(1, time.Now(), []byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := u.Abort("bucket", "object", up.ID); err != nil {
+		t.Fatal(err)
+	}
+	if up.parts != nil {
+		t.Fatal("parts not released:", len(up.parts))
+	}
+
+	// A request that found the upload before it was aborted must not be able
+	// to use it afterwards:
+	if _, err := up.AddPart(2, time.Now(), []byte("late")); !HasErrorCode(err, ErrNoSuchUpload) {
+		t.Fatal("expected ErrNoSuchUpload, found", err)
+	}
+	if up.parts != nil {
+		t.Fatal("part added after abort")
+	}
+	if err := u.Abort("bucket", "object", up.ID); !HasErrorCode(err, ErrNoSuchUpload) {
+		t.Fatal("expected ErrNoSuchUpload, found", err)
+	}
+}
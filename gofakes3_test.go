@@ -3,24 +3,33 @@ package gofakes3_test
 import (
 	"bufio"
 	"bytes"
+	"encoding/base64"
 	"encoding/xml"
 	"fmt"
+	"hash/crc32"
 	"io"
 	"io/ioutil"
 	"mime/multipart"
 	"net/http"
+	"net/http/httptest"
+	"net/http/httptrace"
 	"net/http/httputil"
 	"net/url"
+	"path/filepath"
 	"reflect"
 	"sort"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/service/s3"
 	"github.com/johannesboyne/gofakes3"
+	"github.com/johannesboyne/gofakes3/backend/s3bolt"
 	"github.com/johannesboyne/gofakes3/backend/s3mem"
+	bolt "go.etcd.io/bbolt"
 )
 
 func TestCreateBucket(t *testing.T) {
@@ -51,6 +60,51 @@ func TestCreateBucket(t *testing.T) {
 	}))
 }
 
+func TestCreateBucketConcurrent(t *testing.T) {
+	ts := newTestServer(t, withoutInitialBuckets())
+	defer ts.Close()
+	svc := ts.s3Client()
+
+	const creators = 50
+	errs := make(chan error, creators)
+	var wg sync.WaitGroup
+	for i := 0; i < creators; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := svc.CreateBucket(&s3.CreateBucketInput{Bucket: aws.String("race")})
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	var created int
+	for err := range errs {
+		if err == nil {
+			created++
+			continue
+		}
+		if reqErr, ok := err.(awserr.RequestFailure); !ok || reqErr.StatusCode() != http.StatusConflict || !hasErrorCode(err, gofakes3.ErrBucketAlreadyExists) {
+			t.Fatal("unexpected error", err)
+		}
+	}
+	if created != 1 {
+		t.Fatal("expected exactly one bucket to be created, found", created)
+	}
+
+	// A later attempt must not replace the bucket's creation date:
+	ts.Advance(time.Hour)
+	if _, err := svc.CreateBucket(&s3.CreateBucketInput{Bucket: aws.String("race")}); !hasErrorCode(err, gofakes3.ErrBucketAlreadyExists) {
+		t.Fatal("expected BucketAlreadyExists, found", err)
+	}
+	rs, err := svc.ListBuckets(&s3.ListBucketsInput{})
+	ts.OK(err)
+	if len(rs.Buckets) != 1 || !aws.TimeValue(rs.Buckets[0].CreationDate).Equal(defaultDate) {
+		t.Fatal("unexpected buckets", rs.Buckets)
+	}
+}
+
 func TestListBuckets(t *testing.T) {
 	ts := newTestServer(t, withoutInitialBuckets())
 	defer ts.Close()
@@ -109,6 +163,75 @@ func TestListBuckets(t *testing.T) {
 	assertBucketTime("test3", defaultDate.Add(1*time.Minute))
 }
 
+// backendWithoutBucketPaging hides s3mem's PagingBucketsBackend, so that
+// GoFakeS3 pages the buckets itself.
+type backendWithoutBucketPaging struct {
+	gofakes3.Backend
+}
+
+func TestListBucketsPaged(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		opts []testServerOption
+	}{
+		{"backend", nil},
+		{"fallback", []testServerOption{withBackend(&backendWithoutBucketPaging{Backend: s3mem.New()})}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			opts := append([]testServerOption{withInitialBuckets("b1", "a2", "a1", "a3", "c1")}, tc.opts...)
+			ts := newTestServer(t, opts...)
+			defer ts.Close()
+
+			list := func(query string) (names []string, token, prefix string) {
+				t.Helper()
+				rs, body := ts.sendRaw("GET", "/?"+query, nil, nil)
+				if rs.StatusCode != http.StatusOK {
+					t.Fatal("unexpected status", rs.StatusCode, string(body))
+				}
+				var result struct {
+					Buckets           []string `xml:"Buckets>Bucket>Name"`
+					ContinuationToken string
+					Prefix            string
+				}
+				ts.OK(xml.Unmarshal(body, &result))
+				return result.Buckets, result.ContinuationToken, result.Prefix
+			}
+
+			var found []string
+			var token string
+			for i := 0; ; i++ {
+				query := "max-buckets=2&prefix=a"
+				if token != "" {
+					query += "&continuation-token=" + url.QueryEscape(token)
+				}
+				var page []string
+				var prefix string
+				page, token, prefix = list(query)
+				if len(page) > 2 || prefix != "a" {
+					t.Fatal("unexpected page", page, prefix)
+				}
+				found = append(found, page...)
+				if token == "" {
+					break
+				} else if i > 2 {
+					t.Fatal("too many pages")
+				}
+			}
+			if !reflect.DeepEqual(found, []string{"a1", "a2", "a3"}) {
+				t.Fatal("unexpected buckets", found)
+			}
+
+			if names, token, _ := list("max-buckets=10"); len(names) != 5 || token != "" {
+				t.Fatal("unexpected buckets", names, token)
+			}
+			for _, query := range []string{"max-buckets=0", "max-buckets=10001", "max-buckets=x", "continuation-token=%21"} {
+				rs, body := ts.sendRaw("GET", "/?"+query, nil, nil)
+				ts.assertRawErrorCode(rs, body, gofakes3.ErrInvalidArgument)
+			}
+		})
+	}
+}
+
 func TestListBucketObjectSize(t *testing.T) {
 	ts := newTestServer(t)
 	defer ts.Close()
@@ -133,6 +256,46 @@ func TestListBucketObjectSize(t *testing.T) {
 	}
 }
 
+func TestListBucketNonexistent(t *testing.T) {
+	ts := newTestServer(t, withVersioning())
+	defer ts.Close()
+	svc := ts.s3Client()
+
+	const bucket = "nonexistent"
+
+	for _, tc := range []struct {
+		name string
+		list func() error
+	}{
+		{"v1", func() error {
+			_, err := svc.ListObjects(&s3.ListObjectsInput{Bucket: aws.String(bucket)})
+			return err
+		}},
+		{"v2", func() error {
+			_, err := svc.ListObjectsV2(&s3.ListObjectsV2Input{Bucket: aws.String(bucket)})
+			return err
+		}},
+		{"versions", func() error {
+			_, err := svc.ListObjectVersions(&s3.ListObjectVersionsInput{Bucket: aws.String(bucket)})
+			return err
+		}},
+		{"uploads", func() error {
+			_, err := svc.ListMultipartUploads(&s3.ListMultipartUploadsInput{Bucket: aws.String(bucket)})
+			return err
+		}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.list()
+			if !hasErrorCode(err, gofakes3.ErrNoSuchBucket) {
+				t.Fatal("expected ErrNoSuchBucket, found", err)
+			}
+			if reqErr, ok := err.(interface{ StatusCode() int }); !ok || reqErr.StatusCode() != http.StatusNotFound {
+				t.Fatal("expected 404, found", err)
+			}
+		})
+	}
+}
+
 func TestCreateObject(t *testing.T) {
 	ts := newTestServer(t)
 	defer ts.Close()
@@ -173,6 +336,31 @@ func TestCreateObjectMetadataSizeLimit(t *testing.T) {
 	}
 }
 
+func TestCreateObjectMetadataCountLimit(t *testing.T) {
+	ts := newTestServer(t, withFakerOptions(
+		gofakes3.WithMetadataCountLimit(2),
+	))
+	defer ts.Close()
+	svc := ts.s3Client()
+
+	put := func(meta map[string]*string) error {
+		_, err := svc.PutObject(&s3.PutObjectInput{
+			Bucket:   aws.String(defaultBucket),
+			Key:      aws.String("object"),
+			Metadata: meta,
+			Body:     bytes.NewReader([]byte("hello")),
+		})
+		return err
+	}
+
+	ts.OK(put(map[string]*string{"one": aws.String("1"), "two": aws.String("2")}))
+
+	err := put(map[string]*string{"one": aws.String("1"), "two": aws.String("2"), "three": aws.String("3")})
+	if !hasErrorCode(err, gofakes3.ErrMetadataTooLarge) {
+		t.Fatal(err)
+	}
+}
+
 func TestCreateObjectMD5(t *testing.T) {
 	ts := newTestServer(t)
 	defer ts.Close()
@@ -208,7 +396,7 @@ func TestCreateObjectMD5(t *testing.T) {
 }
 
 func TestCreateObjectWithMissingContentLength(t *testing.T) {
-	ts := newTestServer(t)
+	ts := newTestServer(t, withFakerOptions(gofakes3.WithRequireContentLength()))
 	defer ts.Close()
 	client := ts.rawClient()
 	body := []byte{}
@@ -223,6 +411,24 @@ func TestCreateObjectWithMissingContentLength(t *testing.T) {
 	}
 }
 
+func TestCreateObjectWithMissingContentLengthTolerated(t *testing.T) {
+	ts := newTestServer(t)
+	defer ts.Close()
+	client := ts.rawClient()
+	body := []byte("hello")
+	rq, err := http.NewRequest("PUT", client.URL(fmt.Sprintf("/%s/yep", defaultBucket)).String(), maskReader(bytes.NewReader(body)))
+	if err != nil {
+		panic(err)
+	}
+	client.SetHeaders(rq, body)
+	rs, err := client.Do(rq)
+	ts.OK(err)
+	if rs.StatusCode != http.StatusOK {
+		t.Fatal("unexpected status", rs.StatusCode)
+	}
+	ts.assertObject(defaultBucket, "yep", nil, "hello")
+}
+
 func TestCreateObjectWithInvalidContentLength(t *testing.T) {
 	ts := newTestServer(t)
 	defer ts.Close()
@@ -277,19 +483,74 @@ func TestCreateObjectWithContentDisposition(t *testing.T) {
 	}
 }
 
+func TestCreateObjectWithContentLanguageAndWebsiteRedirect(t *testing.T) {
+	ts := newTestServer(t)
+	defer ts.Close()
+	svc := ts.s3Client()
+
+	_, err := svc.PutObject(&s3.PutObjectInput{
+		Bucket:                  aws.String(defaultBucket),
+		Key:                     aws.String("object"),
+		Body:                    bytes.NewReader([]byte("hello")),
+		ContentLanguage:         aws.String("de-CH"),
+		WebsiteRedirectLocation: aws.String("/elsewhere.html"),
+	})
+	ts.OK(err)
+
+	assertHeaders := func(key string) {
+		t.Helper()
+
+		head, err := svc.HeadObject(&s3.HeadObjectInput{
+			Bucket: aws.String(defaultBucket),
+			Key:    aws.String(key),
+		})
+		ts.OK(err)
+		if v := aws.StringValue(head.ContentLanguage); v != "de-CH" {
+			t.Fatal("HEAD: unexpected Content-Language", v)
+		}
+		if v := aws.StringValue(head.WebsiteRedirectLocation); v != "/elsewhere.html" {
+			t.Fatal("HEAD: unexpected x-amz-website-redirect-location", v)
+		}
+
+		obj, err := svc.GetObject(&s3.GetObjectInput{
+			Bucket: aws.String(defaultBucket),
+			Key:    aws.String(key),
+		})
+		ts.OK(err)
+		obj.Body.Close()
+		if v := aws.StringValue(obj.ContentLanguage); v != "de-CH" {
+			t.Fatal("GET: unexpected Content-Language", v)
+		}
+		if v := aws.StringValue(obj.WebsiteRedirectLocation); v != "/elsewhere.html" {
+			t.Fatal("GET: unexpected x-amz-website-redirect-location", v)
+		}
+	}
+	assertHeaders("object")
+
+	ts.OKAll(svc.CopyObject(&s3.CopyObjectInput{
+		Bucket:            aws.String(defaultBucket),
+		Key:               aws.String("copied"),
+		CopySource:        aws.String(defaultBucket + "/object"),
+		MetadataDirective: aws.String(s3.MetadataDirectiveCopy),
+	}))
+	assertHeaders("copied")
+}
+
 func TestCreateObjectWithContentEncoding(t *testing.T) {
 	ts := newTestServer(t)
 	defer ts.Close()
 	svc := ts.s3Client()
 
+	gzipped := []byte{ // "hello", gzipped
+		0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x03, 0xcb, 0x48,
+		0xcd, 0xc9, 0xc9, 0x07, 0x00, 0x86, 0xa6, 0x10, 0x36, 0x05, 0x00, 0x00,
+		0x00,
+	}
+
 	_, err := svc.PutObject(&s3.PutObjectInput{
-		Bucket: aws.String(defaultBucket),
-		Key:    aws.String("object"),
-		Body: bytes.NewReader([]byte{ // "hello", gzipped
-			0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x03, 0xcb, 0x48,
-			0xcd, 0xc9, 0xc9, 0x07, 0x00, 0x86, 0xa6, 0x10, 0x36, 0x05, 0x00, 0x00,
-			0x00,
-		}),
+		Bucket:          aws.String(defaultBucket),
+		Key:             aws.String("object"),
+		Body:            bytes.NewReader(gzipped),
 		ContentType:     aws.String("text/plain"),
 		ContentEncoding: aws.String("gzip"),
 	})
@@ -306,6 +567,75 @@ func TestCreateObjectWithContentEncoding(t *testing.T) {
 	if !bytes.Equal(content, []byte("hello")) {
 		t.Fatal("incorrect body with Content-Encoding: gzip")
 	}
+
+	t.Run("verbatim", func(t *testing.T) {
+		// The stored bytes must be returned as-is, without being decoded or
+		// compressed a second time, even if the client accepts gzip. The
+		// transport's own decompression is disabled so we see the raw body:
+		client := &http.Client{Transport: &http.Transport{DisableCompression: true}}
+		rq, err := http.NewRequest("GET", ts.url(defaultBucket+"/object"), nil)
+		ts.OK(err)
+		rq.Header.Set("Accept-Encoding", "gzip")
+
+		rs, err := client.Do(rq)
+		ts.OK(err)
+		defer rs.Body.Close()
+
+		if enc := rs.Header.Values("Content-Encoding"); len(enc) != 1 || enc[0] != "gzip" {
+			t.Fatal("unexpected Content-Encoding", enc)
+		}
+		if rs.ContentLength != int64(len(gzipped)) {
+			t.Fatal("unexpected Content-Length", rs.ContentLength)
+		}
+		body, err := io.ReadAll(rs.Body)
+		ts.OK(err)
+		if !bytes.Equal(body, gzipped) {
+			t.Fatal("body was not returned verbatim")
+		}
+	})
+}
+
+func TestCreateObjectStreamingUnsignedPayloadTrailer(t *testing.T) {
+	ts := newTestServer(t)
+	defer ts.Close()
+
+	const contents = "hello world"
+	crc := crc32.NewIEEE()
+	crc.Write([]byte(contents))
+	sum := base64.StdEncoding.EncodeToString(crc.Sum(nil))
+
+	send := func(sum string) *http.Response {
+		t.Helper()
+		body := fmt.Sprintf("%x\r\n%s\r\n0\r\nx-amz-checksum-crc32:%s\r\n\r\n", len(contents), contents, sum)
+
+		client := ts.rawClient()
+		rq := client.Request("PUT", fmt.Sprintf("/%s/trailer", defaultBucket), []byte(body))
+		rq.Header.Del("Content-Md5")
+		rq.Header.Set("Content-Encoding", "aws-chunked")
+		rq.Header.Set("X-Amz-Content-Sha256", "STREAMING-UNSIGNED-PAYLOAD-TRAILER")
+		rq.Header.Set("X-Amz-Decoded-Content-Length", fmt.Sprintf("%d", len(contents)))
+		rq.Header.Set("X-Amz-Trailer", "x-amz-checksum-crc32")
+
+		rs, err := client.Do(rq)
+		ts.OK(err)
+		rs.Body.Close()
+		return rs
+	}
+
+	if rs := send(sum); rs.StatusCode != http.StatusOK {
+		t.Fatal("unexpected status", rs.StatusCode)
+	}
+	ts.assertObject(defaultBucket, "trailer", nil, contents)
+
+	obj, err := ts.backend.HeadObject(defaultBucket, "trailer")
+	ts.OK(err)
+	if _, ok := obj.Metadata["Content-Encoding"]; ok {
+		t.Fatal("aws-chunked content encoding should not be stored")
+	}
+
+	if rs := send("AAAAAA=="); rs.StatusCode != http.StatusBadRequest {
+		t.Fatal("unexpected status", rs.StatusCode)
+	}
 }
 
 func TestCreateObjectMetadataAndObjectTagging(t *testing.T) {
@@ -377,6 +707,193 @@ func TestCreateObjectMetadataAndObjectTagging(t *testing.T) {
 	}
 }
 
+func TestMetadataKeyCanonicalization(t *testing.T) {
+	ts := newTestServer(t)
+	defer ts.Close()
+	svc := ts.s3Client()
+
+	ts.OKAll(svc.PutObject(&s3.PutObjectInput{
+		Bucket: aws.String(defaultBucket),
+		Key:    aws.String("object"),
+		Body:   bytes.NewReader([]byte("hello")),
+		Metadata: map[string]*string{
+			"Foo": aws.String("bar"),
+		},
+	}))
+
+	obj, err := ts.backend.HeadObject(defaultBucket, "object")
+	ts.OK(err)
+	if v := obj.Metadata["x-amz-meta-foo"]; v != "bar" {
+		t.Fatalf("metadata not stored in lower case: %+v", obj.Metadata)
+	}
+
+	// The client canonicalizes the headers it receives, so the response is
+	// recorded to check what is sent on the wire:
+	for _, method := range []string{"GET", "HEAD"} {
+		t.Run(method, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			ts.Server().ServeHTTP(rec, httptest.NewRequest(method, "/"+defaultBucket+"/object", nil))
+			if vs := rec.Header()["x-amz-meta-foo"]; len(vs) != 1 || vs[0] != "bar" {
+				t.Fatalf("metadata not returned in lower case: %+v", rec.Header())
+			}
+			if _, ok := rec.Header()["X-Amz-Meta-Foo"]; ok {
+				t.Fatal("metadata returned twice")
+			}
+		})
+	}
+}
+
+func TestMissingMetaHeader(t *testing.T) {
+	ts := newTestServer(t)
+	defer ts.Close()
+	svc := ts.s3Client()
+
+	ts.backendPutString(defaultBucket, "plain", map[string]string{"x-amz-meta-ok": "fine"}, "hello")
+	ts.backendPutString(defaultBucket, "unicode", map[string]string{
+		"x-amz-meta-ok":  "fine",
+		"x-amz-meta-bad": "h\u00e9llo",
+	}, "hello")
+
+	for _, tc := range []struct {
+		key     string
+		missing int64
+	}{
+		{"plain", 0},
+		{"unicode", 1},
+	} {
+		t.Run(tc.key, func(t *testing.T) {
+			head, err := svc.HeadObject(&s3.HeadObjectInput{
+				Bucket: aws.String(defaultBucket),
+				Key:    aws.String(tc.key),
+			})
+			ts.OK(err)
+			if head.MissingMeta == nil || *head.MissingMeta != tc.missing {
+				t.Fatal("unexpected HEAD missing meta", head.MissingMeta)
+			}
+			if _, ok := head.Metadata["Bad"]; ok || aws.StringValue(head.Metadata["Ok"]) != "fine" {
+				t.Fatal("unexpected metadata", head.Metadata)
+			}
+
+			get, err := svc.GetObject(&s3.GetObjectInput{
+				Bucket: aws.String(defaultBucket),
+				Key:    aws.String(tc.key),
+			})
+			ts.OK(err)
+			get.Body.Close()
+			if get.MissingMeta == nil || *get.MissingMeta != tc.missing {
+				t.Fatal("unexpected GET missing meta", get.MissingMeta)
+			}
+		})
+	}
+}
+
+func TestCreateObjectIfNoneMatch(t *testing.T) {
+	ts := newTestServer(t)
+	defer ts.Close()
+	svc := ts.s3Client()
+
+	// The SDK in use predates conditional writes, so the header is added to
+	// the request by hand:
+	create := func(key, body string) error {
+		rq, _ := svc.PutObjectRequest(&s3.PutObjectInput{
+			Bucket: aws.String(defaultBucket),
+			Key:    aws.String(key),
+			Body:   strings.NewReader(body),
+		})
+		rq.HTTPRequest.Header.Set("If-None-Match", "*")
+		return rq.Send()
+	}
+
+	ts.OK(create("object", "first"))
+	if err := create("object", "second"); !hasErrorCode(err, gofakes3.ErrPreconditionFailed) {
+		t.Fatal("expected ErrPreconditionFailed, found", err)
+	}
+	ts.assertObject(defaultBucket, "object", nil, "first")
+
+	t.Run("race", func(t *testing.T) {
+		for i := 0; i < 20; i++ {
+			key := fmt.Sprintf("race-%d", i)
+
+			var wg sync.WaitGroup
+			errs := make([]error, 2)
+			for j := range errs {
+				wg.Add(1)
+				go func(j int) {
+					defer wg.Done()
+					errs[j] = create(key, fmt.Sprint(j))
+				}(j)
+			}
+			wg.Wait()
+
+			var created int
+			for _, err := range errs {
+				if err == nil {
+					created++
+				} else if !hasErrorCode(err, gofakes3.ErrPreconditionFailed) {
+					t.Fatal("expected ErrPreconditionFailed, found", err)
+				}
+			}
+			if created != 1 {
+				t.Fatal("expected exactly one create to succeed for", key, "found", created)
+			}
+		}
+	})
+
+	t.Run("unsupported-value", func(t *testing.T) {
+		rq, _ := svc.PutObjectRequest(&s3.PutObjectInput{
+			Bucket: aws.String(defaultBucket),
+			Key:    aws.String("other"),
+			Body:   strings.NewReader("hello"),
+		})
+		rq.HTTPRequest.Header.Set("If-None-Match", `"5d41402abc4b2a76b9719d911017c592"`)
+		if err := rq.Send(); !hasErrorCode(err, gofakes3.ErrNotImplemented) {
+			t.Fatal("expected ErrNotImplemented, found", err)
+		}
+	})
+}
+
+func TestCreateObjectIfMatch(t *testing.T) {
+	ts := newTestServer(t)
+	defer ts.Close()
+	svc := ts.s3Client()
+
+	put := func(key, body string, header http.Header) error {
+		rq, _ := svc.PutObjectRequest(&s3.PutObjectInput{
+			Bucket: aws.String(defaultBucket),
+			Key:    aws.String(key),
+			Body:   strings.NewReader(body),
+		})
+		for k, v := range header {
+			rq.HTTPRequest.Header[k] = v
+		}
+		return rq.Send()
+	}
+	const helloETag = `"5d41402abc4b2a76b9719d911017c592"`
+
+	if err := put("object", "hello", http.Header{"If-Match": {helloETag}}); !hasErrorCode(err, gofakes3.ErrNoSuchKey) {
+		t.Fatal("expected ErrNoSuchKey, found", err)
+	}
+	ts.backendPutString(defaultBucket, "object", nil, "hello")
+
+	if err := put("object", "second", http.Header{"If-Match": {`"nope"`}}); !hasErrorCode(err, gofakes3.ErrPreconditionFailed) {
+		t.Fatal("expected ErrPreconditionFailed, found", err)
+	}
+	ts.assertObject(defaultBucket, "object", nil, "hello")
+
+	ts.OK(put("object", "second", http.Header{"If-Match": {helloETag}}))
+	ts.assertObject(defaultBucket, "object", nil, "second")
+
+	// The ETag has changed, so the same condition fails the second time:
+	if err := put("object", "third", http.Header{"If-Match": {helloETag}}); !hasErrorCode(err, gofakes3.ErrPreconditionFailed) {
+		t.Fatal("expected ErrPreconditionFailed, found", err)
+	}
+
+	err := put("object", "third", http.Header{"If-Match": {helloETag}, "If-None-Match": {"*"}})
+	if !hasErrorCode(err, gofakes3.ErrNotImplemented) {
+		t.Fatal("expected ErrNotImplemented, found", err)
+	}
+}
+
 func TestCopyObject(t *testing.T) {
 	ts := newTestServer(t)
 	defer ts.Close()
@@ -415,15 +932,15 @@ func TestCopyObject(t *testing.T) {
 		t.Fatal("object copying failed")
 	}
 
-	if v := obj.Metadata["X-Amz-Meta-One"]; v != "src" {
+	if v := obj.Metadata["x-amz-meta-one"]; v != "src" {
 		t.Fatalf("bad Content-Type: %q", v)
 	}
 
-	if v := obj.Metadata["X-Amz-Meta-Two"]; v != "dst" {
+	if v := obj.Metadata["x-amz-meta-two"]; v != "dst" {
 		t.Fatalf("bad Content-Encoding: %q", v)
 	}
 
-	if v := obj.Metadata["X-Amz-Meta-Three"]; v != "dst" {
+	if v := obj.Metadata["x-amz-meta-three"]; v != "dst" {
 		t.Fatalf("bad Content-Encoding: %q", v)
 	}
 }
@@ -483,35 +1000,346 @@ func TestCopyObjectWithSpecialCharsEscapedInvalied(t *testing.T) {
 	}
 }
 
-func TestDeleteBucket(t *testing.T) {
-	t.Run("delete-empty", func(t *testing.T) {
-		ts := newTestServer(t, withoutInitialBuckets())
-		defer ts.Close()
-		svc := ts.s3Client()
-
-		ts.backendCreateBucket("test")
-		ts.OKAll(svc.DeleteBucket(&s3.DeleteBucketInput{
-			Bucket: aws.String("test"),
-		}))
-	})
+func TestCopyObjectToItself(t *testing.T) {
+	ts := newTestServer(t)
+	defer ts.Close()
+	svc := ts.s3Client()
 
-	t.Run("delete-fails-if-not-empty", func(t *testing.T) {
-		ts := newTestServer(t, withoutInitialBuckets())
-		defer ts.Close()
-		svc := ts.s3Client()
+	ts.backendPutString(defaultBucket, "key", map[string]string{
+		"Content-Type":   "text/plain",
+		"X-Amz-Meta-Old": "yes",
+	}, "content")
+
+	copyToItself := func(in *s3.CopyObjectInput) error {
+		in.Bucket = aws.String(defaultBucket)
+		in.Key = aws.String("key")
+		in.CopySource = aws.String("/" + defaultBucket + "/key")
+		_, err := svc.CopyObject(in)
+		return err
+	}
 
-		ts.backendCreateBucket("test")
-		ts.backendPutString("test", "test", nil, "test")
-		_, err := svc.DeleteBucket(&s3.DeleteBucketInput{
-			Bucket: aws.String("test"),
-		})
-		if !hasErrorCode(err, gofakes3.ErrBucketNotEmpty) {
-			t.Fatal("expected ErrBucketNotEmpty, found", err)
+	for _, directive := range []*string{nil, aws.String("COPY")} {
+		err := copyToItself(&s3.CopyObjectInput{MetadataDirective: directive})
+		if !hasErrorCode(err, gofakes3.ErrInvalidRequest) {
+			t.Fatal("expected ErrInvalidRequest, found", err)
 		}
+	}
+
+	// Changing the storage class is allowed without replacing the metadata:
+	ts.OK(copyToItself(&s3.CopyObjectInput{StorageClass: aws.String("STANDARD_IA")}))
+
+	ts.OK(copyToItself(&s3.CopyObjectInput{
+		MetadataDirective: aws.String("REPLACE"),
+		Metadata:          map[string]*string{"New": aws.String("yes")},
+	}))
+	head, err := svc.HeadObject(&s3.HeadObjectInput{
+		Bucket: aws.String(defaultBucket),
+		Key:    aws.String("key"),
 	})
-}
+	ts.OK(err)
+	if v := aws.StringValue(head.Metadata["New"]); v != "yes" {
+		t.Fatal("unexpected metadata", head.Metadata)
+	}
 
-func TestDeleteMulti(t *testing.T) {
+	// REPLACE does not merge in the source's metadata:
+	ts.OKAll(svc.CopyObject(&s3.CopyObjectInput{
+		Bucket:            aws.String(defaultBucket),
+		Key:               aws.String("other"),
+		CopySource:        aws.String("/" + defaultBucket + "/key"),
+		MetadataDirective: aws.String("REPLACE"),
+	}))
+	head, err = svc.HeadObject(&s3.HeadObjectInput{
+		Bucket: aws.String(defaultBucket),
+		Key:    aws.String("other"),
+	})
+	ts.OK(err)
+	if len(head.Metadata) != 0 {
+		t.Fatal("REPLACE kept the source's metadata", head.Metadata)
+	}
+
+	err = copyToItself(&s3.CopyObjectInput{MetadataDirective: aws.String("MERGE")})
+	if !hasErrorCode(err, gofakes3.ErrInvalidArgument) {
+		t.Fatal("expected ErrInvalidArgument, found", err)
+	}
+}
+
+func TestCopyObjectStorageClass(t *testing.T) {
+	ts := newTestServer(t)
+	defer ts.Close()
+	svc := ts.s3Client()
+
+	ts.OKAll(svc.PutObject(&s3.PutObjectInput{
+		Bucket: aws.String(defaultBucket),
+		Key:    aws.String("key"),
+		Body:   strings.NewReader("content"),
+	}))
+
+	assertStorageClass := func(key, expected string) {
+		t.Helper()
+		rs, err := svc.GetObject(&s3.GetObjectInput{Bucket: aws.String(defaultBucket), Key: aws.String(key)})
+		ts.OK(err)
+		rs.Body.Close()
+		if aws.StringValue(rs.StorageClass) != expected {
+			t.Fatal("unexpected storage class", key, aws.StringValue(rs.StorageClass))
+		}
+	}
+
+	ts.OKAll(svc.CopyObject(&s3.CopyObjectInput{
+		Bucket:            aws.String(defaultBucket),
+		Key:               aws.String("key"),
+		CopySource:        aws.String("/" + defaultBucket + "/key"),
+		MetadataDirective: aws.String("REPLACE"),
+		StorageClass:      aws.String("STANDARD_IA"),
+	}))
+	assertStorageClass("key", "STANDARD_IA")
+
+	// The storage class is not copied from the source, even with the COPY
+	// directive:
+	ts.OKAll(svc.CopyObject(&s3.CopyObjectInput{
+		Bucket:            aws.String(defaultBucket),
+		Key:               aws.String("other"),
+		CopySource:        aws.String("/" + defaultBucket + "/key"),
+		MetadataDirective: aws.String("COPY"),
+	}))
+	assertStorageClass("other", "")
+}
+
+// backendCountingPuts counts the calls to PutObject, while keeping the
+// optional interfaces of the s3mem.Backend it embeds.
+type backendCountingPuts struct {
+	*s3mem.Backend
+	puts int
+}
+
+func (b *backendCountingPuts) PutObject(bucketName, key string, meta map[string]string, input io.Reader, size int64) (gofakes3.PutObjectResult, error) {
+	b.puts++
+	return b.Backend.PutObject(bucketName, key, meta, input, size)
+}
+
+func TestCopyObjectToItselfUpdatesMetadata(t *testing.T) {
+	backend := &backendCountingPuts{Backend: s3mem.New()}
+	ts := newTestServer(t, withBackend(backend))
+	defer ts.Close()
+	svc := ts.s3Client()
+
+	copyToItself := func(bucket, contentType string) *s3.CopyObjectOutput {
+		t.Helper()
+		out, err := svc.CopyObject(&s3.CopyObjectInput{
+			Bucket:            aws.String(bucket),
+			Key:               aws.String("key"),
+			CopySource:        aws.String("/" + bucket + "/key"),
+			MetadataDirective: aws.String("REPLACE"),
+			ContentType:       aws.String(contentType),
+		})
+		ts.OK(err)
+		return out
+	}
+	assertObject := func(bucket, contentType string) {
+		t.Helper()
+		head, err := svc.HeadObject(&s3.HeadObjectInput{Bucket: aws.String(bucket), Key: aws.String("key")})
+		ts.OK(err)
+		if aws.StringValue(head.ContentType) != contentType {
+			t.Fatal("unexpected content type", aws.StringValue(head.ContentType))
+		}
+		if body := ts.backendGetString(bucket, "key", nil); body != "content" {
+			t.Fatal("unexpected contents", body)
+		}
+	}
+
+	ts.backendPutString(defaultBucket, "key", map[string]string{"Content-Type": "text/plain"}, "content")
+	backend.puts = 0
+
+	// The metadata is changed without the contents being written again:
+	copyToItself(defaultBucket, "text/html")
+	if backend.puts != 0 {
+		t.Fatal("contents copied", backend.puts)
+	}
+	assertObject(defaultBucket, "text/html")
+
+	// A copy to another key still writes the contents:
+	ts.OKAll(svc.CopyObject(&s3.CopyObjectInput{
+		Bucket:     aws.String(defaultBucket),
+		Key:        aws.String("other"),
+		CopySource: aws.String("/" + defaultBucket + "/key"),
+	}))
+	if backend.puts != 1 {
+		t.Fatal("unexpected puts", backend.puts)
+	}
+
+	// With versioning enabled, the copy creates a new version, so the
+	// contents must be written:
+	ts.backendCreateBucket("versioned")
+	ts.OK(backend.SetVersioningConfiguration("versioned", gofakes3.VersioningConfiguration{Status: gofakes3.VersioningEnabled}))
+	ts.backendPutString("versioned", "key", nil, "content")
+	backend.puts = 0
+	if out := copyToItself("versioned", "text/html"); aws.StringValue(out.VersionId) == "" {
+		t.Fatal("expected a new version")
+	}
+	if backend.puts != 1 {
+		t.Fatal("unexpected puts", backend.puts)
+	}
+	assertObject("versioned", "text/html")
+}
+
+func TestCopyObjectLimits(t *testing.T) {
+	t.Run("size", func(t *testing.T) {
+		ts := newTestServer(t, withBackend(&backendWithObjectSize{
+			Backend: s3mem.New(),
+			size:    gofakes3.CopySourceSizeLimit + 1,
+		}))
+		defer ts.Close()
+		svc := ts.s3Client()
+
+		ts.backendPutString(defaultBucket, "src-key", nil, "content")
+		_, err := svc.CopyObject(&s3.CopyObjectInput{
+			Bucket:     aws.String(defaultBucket),
+			Key:        aws.String("dst-key"),
+			CopySource: aws.String("/" + defaultBucket + "/src-key"),
+		})
+		if !hasErrorCode(err, gofakes3.ErrInvalidRequest) {
+			t.Fatal("expected ErrInvalidRequest, found", err)
+		}
+	})
+
+	t.Run("metadata", func(t *testing.T) {
+		ts := newTestServer(t, withFakerOptions(gofakes3.WithMetadataCountLimit(2)))
+		defer ts.Close()
+		svc := ts.s3Client()
+
+		ts.backendPutString(defaultBucket, "src-key", map[string]string{
+			"X-Amz-Meta-One": "src",
+			"X-Amz-Meta-Two": "src",
+		}, "content")
+
+		// The request's metadata is within the limit until it is merged with
+		// the source's:
+		_, err := svc.CopyObject(&s3.CopyObjectInput{
+			Bucket:     aws.String(defaultBucket),
+			Key:        aws.String("dst-key"),
+			CopySource: aws.String("/" + defaultBucket + "/src-key"),
+			Metadata:   map[string]*string{"Three": aws.String("dst")},
+		})
+		if !hasErrorCode(err, gofakes3.ErrMetadataTooLarge) {
+			t.Fatal("expected ErrMetadataTooLarge, found", err)
+		}
+	})
+}
+
+func TestDeleteBucket(t *testing.T) {
+	t.Run("delete-empty", func(t *testing.T) {
+		ts := newTestServer(t, withoutInitialBuckets())
+		defer ts.Close()
+		svc := ts.s3Client()
+
+		ts.backendCreateBucket("test")
+		ts.OKAll(svc.DeleteBucket(&s3.DeleteBucketInput{
+			Bucket: aws.String("test"),
+		}))
+	})
+
+	t.Run("delete-fails-if-not-empty", func(t *testing.T) {
+		ts := newTestServer(t, withoutInitialBuckets())
+		defer ts.Close()
+		svc := ts.s3Client()
+
+		ts.backendCreateBucket("test")
+		ts.backendPutString("test", "test", nil, "test")
+		_, err := svc.DeleteBucket(&s3.DeleteBucketInput{
+			Bucket: aws.String("test"),
+		})
+		if !hasErrorCode(err, gofakes3.ErrBucketNotEmpty) {
+			t.Fatal("expected ErrBucketNotEmpty, found", err)
+		}
+	})
+}
+
+func TestDeleteObjectResponseHeaders(t *testing.T) {
+	assertDelete := func(ts *testServer, url string, marker bool) (versionID string) {
+		ts.Helper()
+		rs, body := ts.sendRaw("DELETE", url, nil, nil)
+		if rs.StatusCode != http.StatusNoContent {
+			ts.Fatal("expected status", http.StatusNoContent, "found", rs.StatusCode)
+		}
+		if len(body) != 0 {
+			ts.Fatal("unexpected body", string(body))
+		}
+		expected := ""
+		if marker {
+			expected = "true"
+		}
+		if hdr := rs.Header.Get("x-amz-delete-marker"); hdr != expected {
+			ts.Fatal("expected x-amz-delete-marker", expected, "found", hdr)
+		}
+		return rs.Header.Get("x-amz-version-id")
+	}
+
+	t.Run("unversioned", func(t *testing.T) {
+		ts := newTestServer(t)
+		defer ts.Close()
+		ts.backendPutString(defaultBucket, "object", nil, "hello")
+
+		if versionID := assertDelete(ts, defaultBucket+"/object", false); versionID != "" {
+			t.Fatal("unexpected version", versionID)
+		}
+		// Deleting a missing object succeeds just the same:
+		assertDelete(ts, defaultBucket+"/object", false)
+	})
+
+	t.Run("versioned", func(t *testing.T) {
+		ts := newTestServer(t, withVersioning())
+		defer ts.Close()
+		svc := ts.s3Client()
+
+		put, err := svc.PutObject(&s3.PutObjectInput{
+			Bucket: aws.String(defaultBucket),
+			Key:    aws.String("object"),
+			Body:   strings.NewReader("hello"),
+		})
+		ts.OK(err)
+
+		markerID := assertDelete(ts, defaultBucket+"/object", true)
+		if markerID == "" || markerID == aws.StringValue(put.VersionId) {
+			t.Fatal("unexpected delete marker version", markerID)
+		}
+		if versionID := assertDelete(ts, defaultBucket+"/object?versionId="+markerID, true); versionID != markerID {
+			t.Fatal("expected version", markerID, "found", versionID)
+		}
+		objectID := aws.StringValue(put.VersionId)
+		if versionID := assertDelete(ts, defaultBucket+"/object?versionId="+objectID, false); versionID != objectID {
+			t.Fatal("expected version", objectID, "found", versionID)
+		}
+	})
+}
+
+// backendReportingMissingKeys fails to delete keys that don't exist, which
+// Backends are not meant to do.
+type backendReportingMissingKeys struct {
+	gofakes3.Backend
+}
+
+func (b *backendReportingMissingKeys) DeleteObject(bucketName, objectName string) (gofakes3.ObjectDeleteResult, error) {
+	if _, err := b.Backend.HeadObject(bucketName, objectName); err != nil {
+		return gofakes3.ObjectDeleteResult{}, err
+	}
+	return b.Backend.DeleteObject(bucketName, objectName)
+}
+
+func TestDeleteMissingObject(t *testing.T) {
+	ts := newTestServer(t, withBackend(&backendReportingMissingKeys{Backend: s3mem.New()}))
+	defer ts.Close()
+
+	for i := 0; i < 2; i++ {
+		rs, body := ts.sendRaw("DELETE", defaultBucket+"/missing", nil, nil)
+		if rs.StatusCode != http.StatusNoContent || len(body) != 0 {
+			t.Fatal("unexpected response", rs.StatusCode, string(body))
+		}
+	}
+
+	rs, body := ts.sendRaw("DELETE", "missing-bucket/missing", nil, nil)
+	ts.assertRawErrorCode(rs, body, gofakes3.ErrNoSuchBucket)
+}
+
+func TestDeleteMulti(t *testing.T) {
 	deletedKeys := func(rs *s3.DeleteObjectsOutput) []string {
 		deleted := make([]string, len(rs.Deleted))
 		for idx, del := range rs.Deleted {
@@ -573,6 +1401,24 @@ func TestDeleteMulti(t *testing.T) {
 		assertDeletedKeys(t, rs, "bar", "foo")
 		ts.assertLs(defaultBucket, "", nil, []string{"baz"})
 	})
+
+	t.Run("malformed-xml", func(t *testing.T) {
+		ts := newTestServer(t)
+		defer ts.Close()
+
+		ts.backendPutString(defaultBucket, "foo", nil, "one")
+
+		for _, body := range []string{
+			"",
+			"garbage",
+			"<Delete><Object><Key>foo</Key></Object>",
+			"<Delete></Delete>",
+		} {
+			rs, out := ts.sendRaw("POST", defaultBucket+"?delete", []byte(body), nil)
+			ts.assertRawErrorCode(rs, out, gofakes3.ErrMalformedXML)
+		}
+		ts.assertLs(defaultBucket, "", nil, []string{"foo"})
+	})
 }
 
 func TestGetBucketLocation(t *testing.T) {
@@ -594,6 +1440,127 @@ func TestGetBucketLocation(t *testing.T) {
 	}
 }
 
+func TestRequestIDHeaders(t *testing.T) {
+	ts := newTestServer(t)
+	defer ts.Close()
+
+	assertIDs := func(t *testing.T, rs *http.Response) (requestID, hostID string) {
+		t.Helper()
+		requestID, hostID = rs.Header.Get("x-amz-request-id"), rs.Header.Get("x-amz-id-2")
+		if requestID == "" || hostID == "" {
+			t.Fatal("missing request ID headers", rs.Header)
+		}
+		return requestID, hostID
+	}
+
+	assertErrorIDs := func(t *testing.T, rs *http.Response, body []byte) {
+		t.Helper()
+		requestID, hostID := assertIDs(t, rs)
+		var errResp gofakes3.ErrorResponse
+		ts.OK(xml.Unmarshal(body, &errResp))
+		if errResp.RequestID != requestID || errResp.HostID != hostID {
+			t.Fatal("error body IDs", errResp.RequestID, errResp.HostID, "do not match headers", requestID, hostID)
+		}
+	}
+
+	t.Run("success", func(t *testing.T) {
+		rs, _ := ts.sendRaw("GET", defaultBucket, nil, nil)
+		first, _ := assertIDs(t, rs)
+		rs, _ = ts.sendRaw("GET", defaultBucket, nil, nil)
+		if second, _ := assertIDs(t, rs); second == first {
+			t.Fatal("request ID reused", first)
+		}
+	})
+
+	t.Run("error", func(t *testing.T) {
+		rs, body := ts.sendRaw("GET", defaultBucket+"/missing", nil, nil)
+		ts.assertRawErrorCode(rs, body, gofakes3.ErrNoSuchKey)
+		assertErrorIDs(t, rs, body)
+	})
+
+	t.Run("middleware-error", func(t *testing.T) {
+		ts := newTestServer(t, withFakerOptions(gofakes3.WithTimeSkewLimit(time.Minute)))
+		defer ts.Close()
+
+		rs, body := ts.sendRaw("GET", defaultBucket, nil, http.Header{
+			"X-Amz-Date": []string{defaultDate.Add(-time.Hour).Format("20060102T150405Z")},
+		})
+		ts.assertRawErrorCode(rs, body, gofakes3.ErrRequestTimeTooSkewed)
+		assertErrorIDs(t, rs, body)
+	})
+}
+
+func TestErrorKeepsConnection(t *testing.T) {
+	ts := newTestServer(t)
+	defer ts.Close()
+
+	client := &http.Client{Transport: &http.Transport{}, Timeout: 5 * time.Second}
+	defer client.CloseIdleConnections()
+
+	send := func(method, path string, body []byte) (rs *http.Response, reused bool) {
+		t.Helper()
+		rq, err := http.NewRequest(method, ts.url(path), bytes.NewReader(body))
+		ts.OK(err)
+		rq = rq.WithContext(httptrace.WithClientTrace(rq.Context(), &httptrace.ClientTrace{
+			GotConn: func(info httptrace.GotConnInfo) { reused = info.Reused },
+		}))
+		rs, err = client.Do(rq)
+		ts.OK(err)
+		ioutil.ReadAll(rs.Body)
+		rs.Body.Close()
+		return rs, reused
+	}
+
+	// The request fails before its body is read, and the body is larger
+	// than net/http would discard on its own:
+	rs, _ := send("PUT", "nope/object", randomFileBody(1<<20))
+	if rs.StatusCode != http.StatusNotFound || rs.Close {
+		t.Fatal("unexpected response", rs.StatusCode, rs.Close)
+	}
+	rs, reused := send("GET", defaultBucket, nil)
+	if rs.StatusCode != http.StatusOK || !reused {
+		t.Fatal("connection not reused", rs.StatusCode, reused)
+	}
+}
+
+func TestTimeSkew(t *testing.T) {
+	ts := newTestServer(t, withFakerOptions(gofakes3.WithTimeSkewLimit(15*time.Minute)))
+	defer ts.Close()
+
+	for _, tc := range []struct {
+		name   string
+		header http.Header
+		skewed bool
+	}{
+		{"none", nil, false},
+		{"amz-date", http.Header{"X-Amz-Date": {defaultDate.Add(10 * time.Minute).Format("20060102T150405Z")}}, false},
+		{"amz-date-skewed", http.Header{"X-Amz-Date": {defaultDate.Add(-time.Hour).Format("20060102T150405Z")}}, true},
+		{"date", http.Header{"Date": {defaultDate.Add(-10 * time.Minute).Format(http.TimeFormat)}}, false},
+		{"date-skewed", http.Header{"Date": {defaultDate.Add(24 * time.Hour).Format(http.TimeFormat)}}, true},
+
+		// x-amz-date takes precedence over Date:
+		{"both", http.Header{
+			"X-Amz-Date": {defaultDate.Format("20060102T150405Z")},
+			"Date":       {defaultDate.Add(time.Hour).Format(http.TimeFormat)},
+		}, false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			rs, body := ts.sendRaw("GET", defaultBucket, nil, tc.header)
+			if tc.skewed {
+				ts.assertRawErrorCode(rs, body, gofakes3.ErrRequestTimeTooSkewed)
+			} else if rs.StatusCode != http.StatusOK {
+				t.Fatal("unexpected status", rs.StatusCode, string(body))
+			}
+
+			// The Date returned is the server's, so that clients can
+			// correct for the skew:
+			if date := rs.Header.Get("Date"); date != defaultDate.Format(http.TimeFormat) {
+				t.Fatal("unexpected date", date)
+			}
+		})
+	}
+}
+
 func TestGetObjectRange(t *testing.T) {
 	assertRange := func(ts *testServer, key string, hdr string, expected []byte, fail bool) {
 		ts.Helper()
@@ -653,6 +1620,135 @@ func TestGetObjectRange(t *testing.T) {
 	}
 }
 
+func TestGetObjectRangeWholeObject(t *testing.T) {
+	ts := newTestServer(t)
+	defer ts.Close()
+	ts.backendPutString(defaultBucket, "obj", nil, "hello")
+
+	// A range is answered with 206 and a Content-Range even if it covers the
+	// whole object:
+	for _, rnge := range []string{"bytes=0-", "bytes=0-4", "bytes=0-1000", "bytes=-5"} {
+		rs, body := ts.sendRaw("GET", defaultBucket+"/obj", nil, http.Header{"Range": {rnge}})
+		if rs.StatusCode != http.StatusPartialContent {
+			t.Fatal("unexpected status for", rnge, rs.StatusCode)
+		}
+		if cr := rs.Header.Get("Content-Range"); cr != "bytes 0-4/5" {
+			t.Fatal("unexpected content range for", rnge, cr)
+		}
+		if rs.Header.Get("Content-Length") != "5" || string(body) != "hello" {
+			t.Fatal("unexpected body for", rnge, rs.Header.Get("Content-Length"), string(body))
+		}
+	}
+
+	rs, _ := ts.sendRaw("GET", defaultBucket+"/obj", nil, nil)
+	if rs.StatusCode != http.StatusOK || rs.Header.Get("Content-Range") != "" {
+		t.Fatal("unexpected response without range", rs.StatusCode, rs.Header.Get("Content-Range"))
+	}
+}
+
+func TestGetObjectMultipleRanges(t *testing.T) {
+	ts := newTestServer(t)
+	defer ts.Close()
+	ts.backendPutString(defaultBucket, "obj", nil, "hello")
+
+	// Like S3, a request for several ranges gets the whole object:
+	for _, rnge := range []string{"bytes=0-1,3-4", "bytes=0-0, -1", "bytes=1-2,100-200"} {
+		rs, body := ts.sendRaw("GET", defaultBucket+"/obj", nil, http.Header{"Range": {rnge}})
+		if rs.StatusCode != http.StatusOK || rs.Header.Get("Content-Range") != "" {
+			t.Fatal("unexpected response for", rnge, rs.StatusCode, rs.Header.Get("Content-Range"))
+		}
+		if rs.Header.Get("Content-Length") != "5" || string(body) != "hello" {
+			t.Fatal("unexpected body for", rnge, rs.Header.Get("Content-Length"), string(body))
+		}
+	}
+}
+
+func TestLastModifiedPrecision(t *testing.T) {
+	ts := newTestServer(t)
+	defer ts.Close()
+
+	ts.Advance(1234 * time.Millisecond)
+	rs, body := ts.sendRaw("PUT", defaultBucket+"/object", []byte("hello"), nil)
+	if rs.StatusCode != http.StatusOK {
+		t.Fatal("unexpected status", rs.StatusCode, string(body))
+	}
+
+	// The header is truncated to the second, while listings keep the
+	// milliseconds:
+	rs, _ = ts.sendRaw("HEAD", defaultBucket+"/object", nil, nil)
+	if modified := rs.Header.Get("Last-Modified"); modified != "Mon, 01 Jan 2018 12:00:01 GMT" {
+		t.Fatal("unexpected Last-Modified", modified)
+	}
+	for _, path := range []string{defaultBucket, defaultBucket + "?list-type=2"} {
+		rs, body = ts.sendRaw("GET", path, nil, nil)
+		if !strings.Contains(string(body), "<LastModified>2018-01-01T12:00:01.234Z</LastModified>") {
+			t.Fatal("unexpected listing", path, string(body))
+		}
+	}
+
+	// Conditions compare whole seconds, so the object was not modified since
+	// the second it was modified in:
+	for _, tc := range []struct {
+		header string
+		at     string
+		status int
+	}{
+		{"If-Modified-Since", "Mon, 01 Jan 2018 12:00:01 GMT", http.StatusNotModified},
+		{"If-Modified-Since", "Mon, 01 Jan 2018 12:00:00 GMT", http.StatusOK},
+		{"If-Unmodified-Since", "Mon, 01 Jan 2018 12:00:01 GMT", http.StatusOK},
+		{"If-Unmodified-Since", "Mon, 01 Jan 2018 12:00:00 GMT", http.StatusPreconditionFailed},
+	} {
+		rs, body = ts.sendRaw("GET", defaultBucket+"/object", nil, http.Header{tc.header: {tc.at}})
+		if rs.StatusCode != tc.status {
+			t.Fatal(tc.header, tc.at, "expected status", tc.status, "found", rs.StatusCode, string(body))
+		}
+	}
+
+	// If-None-Match takes precedence over If-Modified-Since:
+	rs, body = ts.sendRaw("GET", defaultBucket+"/object", nil, http.Header{
+		"If-None-Match":     {`"not-the-etag"`},
+		"If-Modified-Since": {"Mon, 01 Jan 2018 12:00:01 GMT"},
+	})
+	if rs.StatusCode != http.StatusOK {
+		t.Fatal("unexpected status", rs.StatusCode, string(body))
+	}
+}
+
+func TestGetObjectRangeOneByteProbe(t *testing.T) {
+	ts := newTestServer(t)
+	defer ts.Close()
+	ts.backendPutString(defaultBucket, "one", nil, "a")
+	ts.backendPutString(defaultBucket, "empty", nil, "")
+
+	rs, body := ts.sendRaw("GET", defaultBucket+"/one", nil, http.Header{"Range": {"bytes=0-0"}})
+	if rs.StatusCode != http.StatusPartialContent || string(body) != "a" {
+		t.Fatal("unexpected response", rs.StatusCode, string(body))
+	}
+	if cr := rs.Header.Get("Content-Range"); cr != "bytes 0-0/1" || rs.Header.Get("Content-Length") != "1" {
+		t.Fatal("unexpected headers", cr, rs.Header.Get("Content-Length"))
+	}
+
+	// No range of an empty object can be satisfied; S3 gives the size in the
+	// error instead:
+	rs, body = ts.sendRaw("GET", defaultBucket+"/empty", nil, http.Header{"Range": {"bytes=0-0"}})
+	ts.assertRawErrorCode(rs, body, gofakes3.ErrInvalidRange)
+	var errResp struct {
+		RangeRequested   string
+		ActualObjectSize int64
+	}
+	ts.OK(xml.Unmarshal(body, &errResp))
+	if errResp.RangeRequested != "bytes=0-0" || errResp.ActualObjectSize != 0 {
+		t.Fatalf("unexpected error %+v", errResp)
+	}
+
+	rs, body = ts.sendRaw("GET", defaultBucket+"/one", nil, http.Header{"Range": {"bytes=1-1"}})
+	ts.assertRawErrorCode(rs, body, gofakes3.ErrInvalidRange)
+	ts.OK(xml.Unmarshal(body, &errResp))
+	if errResp.RangeRequested != "bytes=1-1" || errResp.ActualObjectSize != 1 {
+		t.Fatalf("unexpected error %+v", errResp)
+	}
+}
+
 func TestGetObjectRangeInvalid(t *testing.T) {
 	assertRangeInvalid := func(ts *testServer, key string, hdr string) {
 		svc := ts.s3Client()
@@ -684,15 +1780,172 @@ func TestGetObjectRangeInvalid(t *testing.T) {
 	}
 }
 
-func TestGetObjectIfNoneMatch(t *testing.T) {
-	objectKey := "foo"
-	assertModified := func(ts *testServer, ifNoneMatch string, shouldModify bool) {
-		svc := ts.s3Client()
-		input := s3.GetObjectInput{
-			Bucket: aws.String(defaultBucket),
-			Key:    aws.String(objectKey),
-		}
-		if ifNoneMatch != "" {
+// backendWithFailingRead returns objects whose contents fail with an error
+// after the given number of bytes, as a Backend reading from a failing disk
+// might.
+type backendWithFailingRead struct {
+	gofakes3.Backend
+	after int64
+}
+
+type failingReader struct {
+	io.Reader
+}
+
+func (r failingReader) Read(p []byte) (int, error) {
+	n, err := r.Reader.Read(p)
+	if err == io.EOF {
+		err = fmt.Errorf("read failed")
+	}
+	return n, err
+}
+
+func (b *backendWithFailingRead) GetObject(bucketName, objectName string, rangeRequest *gofakes3.ObjectRangeRequest) (*gofakes3.Object, error) {
+	obj, err := b.Backend.GetObject(bucketName, objectName, rangeRequest)
+	if err != nil {
+		return obj, err
+	}
+	obj.Contents = struct {
+		io.Reader
+		io.Closer
+	}{failingReader{io.LimitReader(obj.Contents, b.after)}, obj.Contents}
+	return obj, nil
+}
+
+func TestGetObjectBackendReadError(t *testing.T) {
+	in := randomFileBody(100000)
+
+	t.Run("before-headers", func(t *testing.T) {
+		ts := newTestServer(t, withBackend(&backendWithFailingRead{Backend: s3mem.New(), after: 10}))
+		defer ts.Close()
+		ts.backendPutBytes(defaultBucket, "foo", nil, in)
+
+		rs, body := ts.sendRaw("GET", defaultBucket+"/foo", nil, nil)
+		ts.assertRawErrorCode(rs, body, gofakes3.ErrInternal)
+
+		rs, body = ts.sendRaw("GET", defaultBucket+"/foo", nil, http.Header{"Range": {"bytes=5-99"}})
+		ts.assertRawErrorCode(rs, body, gofakes3.ErrInternal)
+	})
+
+	t.Run("after-headers", func(t *testing.T) {
+		ts := newTestServer(t, withBackend(&backendWithFailingRead{Backend: s3mem.New(), after: 50000}))
+		defer ts.Close()
+		ts.backendPutBytes(defaultBucket, "foo", nil, in)
+
+		rs, err := httpClient().Get(ts.url(defaultBucket + "/foo"))
+		ts.OK(err)
+		defer rs.Body.Close()
+		if rs.StatusCode != http.StatusOK || rs.ContentLength != int64(len(in)) {
+			t.Fatal("unexpected response", rs.StatusCode, rs.ContentLength)
+		}
+		body, err := ioutil.ReadAll(rs.Body)
+		if err == nil {
+			t.Fatal("expected the body to fail, read", len(body), "bytes")
+		}
+		if !bytes.Equal(body, in[:len(body)]) {
+			t.Fatal("unexpected bytes in body")
+		}
+	})
+}
+
+func TestGetObjectIfRange(t *testing.T) {
+	ts := newTestServer(t)
+	defer ts.Close()
+	svc := ts.s3Client()
+
+	in := randomFileBody(1024)
+	ts.OKAll(svc.PutObject(&s3.PutObjectInput{
+		Bucket: aws.String(defaultBucket),
+		Key:    aws.String("foo"),
+		Body:   bytes.NewReader(in),
+	}))
+	head, err := svc.HeadObject(&s3.HeadObjectInput{
+		Bucket: aws.String(defaultBucket),
+		Key:    aws.String("foo"),
+	})
+	ts.OK(err)
+	etag := aws.StringValue(head.ETag)
+	modified := aws.TimeValue(head.LastModified).UTC().Format(http.TimeFormat)
+
+	for _, tc := range []struct {
+		name     string
+		ifRange  string
+		status   int
+		expected []byte
+	}{
+		{"etag", etag, http.StatusPartialContent, in[100:200]},
+		{"stale-etag", `"00000000000000000000000000000000"`, http.StatusOK, in},
+		{"weak-etag", "W/" + etag, http.StatusOK, in},
+		{"date", modified, http.StatusPartialContent, in[100:200]},
+		{"stale-date", defaultDate.Add(-time.Hour).Format(http.TimeFormat), http.StatusOK, in},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			rs, body := ts.sendRaw("GET", defaultBucket+"/foo", nil, http.Header{
+				"Range":    []string{"bytes=100-199"},
+				"If-Range": []string{tc.ifRange},
+			})
+			if rs.StatusCode != tc.status {
+				t.Fatal("expected status", tc.status, "found", rs.StatusCode)
+			}
+			if !bytes.Equal(body, tc.expected) {
+				t.Fatal("unexpected body of length", len(body))
+			}
+			if hasRange := rs.Header.Get("Content-Range") != ""; hasRange != (tc.status == http.StatusPartialContent) {
+				t.Fatal("unexpected Content-Range", rs.Header.Get("Content-Range"))
+			}
+		})
+	}
+}
+
+// backendWithoutRanges can only stream objects from the start.
+type backendWithoutRanges struct {
+	gofakes3.Backend
+}
+
+func (b *backendWithoutRanges) SupportsRanges() bool { return false }
+
+func (b *backendWithoutRanges) GetObject(bucketName, objectName string, rangeRequest *gofakes3.ObjectRangeRequest) (*gofakes3.Object, error) {
+	if rangeRequest != nil {
+		return nil, fmt.Errorf("unexpected range %+v", rangeRequest)
+	}
+	return b.Backend.GetObject(bucketName, objectName, nil)
+}
+
+func TestGetObjectRangeUnsupported(t *testing.T) {
+	ts := newTestServer(t, withBackend(&backendWithoutRanges{Backend: s3mem.New()}))
+	defer ts.Close()
+	ts.backendPutString(defaultBucket, "foo", nil, "hello world")
+
+	for _, hdr := range []http.Header{
+		nil,
+		{"Range": {"bytes=0-4"}},
+		{"Range": {"bytes=100-"}},
+		{"Range": {"bytes=0-4"}, "If-Range": {`"5eb63bbbe01eeed093cb22bb8f5acdc3"`}},
+	} {
+		rs, body := ts.sendRaw("GET", defaultBucket+"/foo", nil, hdr)
+		if rs.StatusCode != http.StatusOK || string(body) != "hello world" {
+			t.Fatal("unexpected response", hdr, rs.StatusCode, string(body))
+		}
+		if rs.Header.Get("Accept-Ranges") != "" || rs.Header.Get("Content-Range") != "" {
+			t.Fatal("unexpected range headers", hdr, rs.Header)
+		}
+	}
+
+	rs, _ := ts.sendRaw("HEAD", defaultBucket+"/foo", nil, nil)
+	if rs.StatusCode != http.StatusOK || rs.Header.Get("Accept-Ranges") != "" {
+		t.Fatal("unexpected response", rs.StatusCode, rs.Header)
+	}
+}
+
+func TestGetObjectIfNoneMatch(t *testing.T) {
+	objectKey := "foo"
+	assertModified := func(ts *testServer, ifNoneMatch string, shouldModify bool) {
+		svc := ts.s3Client()
+		input := s3.GetObjectInput{
+			Bucket: aws.String(defaultBucket),
+			Key:    aws.String(objectKey),
+		}
+		if ifNoneMatch != "" {
 			input.IfNoneMatch = aws.String(ifNoneMatch)
 		}
 
@@ -730,6 +1983,43 @@ func TestGetObjectIfNoneMatch(t *testing.T) {
 	}
 }
 
+func TestGetObjectResponseHeaderOverrides(t *testing.T) {
+	ts := newTestServer(t)
+	defer ts.Close()
+	svc := ts.s3Client()
+
+	ts.backendPutString(defaultBucket, "foo", map[string]string{
+		"Cache-Control":       "max-age=60",
+		"Content-Type":        "text/plain",
+		"Content-Disposition": "inline",
+		"Expires":             "Mon, 01 Jan 2018 12:00:00 GMT",
+	}, "hello")
+
+	out, err := svc.GetObject(&s3.GetObjectInput{
+		Bucket:                     aws.String(defaultBucket),
+		Key:                        aws.String("foo"),
+		ResponseCacheControl:       aws.String("no-cache"),
+		ResponseContentDisposition: aws.String(`attachment; filename="foo.txt"`),
+		ResponseContentType:        aws.String("application/octet-stream"),
+		ResponseExpires:            aws.Time(defaultDate.AddDate(0, 0, 1)),
+	})
+	ts.OK(err)
+	defer out.Body.Close()
+
+	if v := aws.StringValue(out.CacheControl); v != "no-cache" {
+		t.Fatal("unexpected Cache-Control", v)
+	}
+	if v := aws.StringValue(out.Expires); v != "2018-01-02T12:00:00Z" {
+		t.Fatal("unexpected Expires", v)
+	}
+	if v := aws.StringValue(out.ContentDisposition); v != `attachment; filename="foo.txt"` {
+		t.Fatal("unexpected Content-Disposition", v)
+	}
+	if v := aws.StringValue(out.ContentType); v != "application/octet-stream" {
+		t.Fatal("unexpected Content-Type", v)
+	}
+}
+
 func TestCreateObjectBrowserUpload(t *testing.T) {
 	addFile := func(tt gofakes3.TT, w *multipart.Writer, object string, b []byte) {
 		tt.Helper()
@@ -807,6 +2097,45 @@ func TestCreateObjectBrowserUpload(t *testing.T) {
 		addFile(ts.TT, w, strings.Repeat("a", gofakes3.KeySizeLimit+1), []byte("yep"))
 		assertUploadFails(ts, defaultBucket, w, &b, gofakes3.ErrKeyTooLong)
 	})
+
+	t.Run("content-length-range", func(t *testing.T) {
+		ts := newTestServer(t)
+		defer ts.Close()
+
+		policy := base64.StdEncoding.EncodeToString([]byte(`{
+			"expiration": "2030-01-01T00:00:00Z",
+			"conditions": [{"bucket": "` + defaultBucket + `"}, ["content-length-range", 3, "5"]]
+		}`))
+		for _, tc := range []struct {
+			body string
+			code gofakes3.ErrorCode
+		}{
+			{"ab", gofakes3.ErrEntityTooSmall},
+			{"abc", ""},
+			{"abcde", ""},
+			{"abcdef", gofakes3.ErrEntityTooLarge},
+		} {
+			var b bytes.Buffer
+			w := multipart.NewWriter(&b)
+			ts.OK(w.WriteField("Policy", policy))
+			addFile(ts.TT, w, tc.body, []byte(tc.body))
+			if tc.code != "" {
+				assertUploadFails(ts, defaultBucket, w, &b, tc.code)
+				if ts.backendObjectExists(defaultBucket, tc.body) {
+					t.Fatal("object stored for rejected upload", tc.body)
+				}
+			} else {
+				assertUpload(ts, defaultBucket, w, &b, "")
+				ts.assertObject(defaultBucket, tc.body, nil, tc.body)
+			}
+		}
+
+		var b bytes.Buffer
+		w := multipart.NewWriter(&b)
+		ts.OK(w.WriteField("policy", "not base64!"))
+		addFile(ts.TT, w, "invalid", []byte("stuff"))
+		assertUploadFails(ts, defaultBucket, w, &b, gofakes3.ErrInvalidPolicyDocument)
+	})
 }
 
 func TestVersioning(t *testing.T) {
@@ -891,6 +2220,116 @@ func TestVersioning(t *testing.T) {
 	})
 }
 
+func TestCreateObjectVersionIDHeader(t *testing.T) {
+	put := func(ts *testServer, body string) string {
+		ts.Helper()
+		rs, out := ts.sendRaw("PUT", defaultBucket+"/object", []byte(body), nil)
+		if rs.StatusCode != http.StatusOK {
+			t.Fatal("unexpected response", rs.StatusCode, string(out))
+		}
+		if vs, ok := rs.Header[http.CanonicalHeaderKey("x-amz-version-id")]; ok && (len(vs) != 1 || vs[0] == "") {
+			t.Fatal("unexpected x-amz-version-id", vs)
+		}
+		return rs.Header.Get("x-amz-version-id")
+	}
+
+	t.Run("unversioned", func(t *testing.T) {
+		ts := newTestServer(t)
+		defer ts.Close()
+
+		if version := put(ts, "hello"); version != "" {
+			t.Fatal("unexpected version", version)
+		}
+	})
+
+	t.Run("versioned", func(t *testing.T) {
+		ts := newTestServer(t, withVersioning())
+		defer ts.Close()
+		svc := ts.s3Client()
+
+		first, second := put(ts, "one"), put(ts, "two")
+		if first == "" || second == "" || first == second {
+			t.Fatal("unexpected versions", first, second)
+		}
+
+		out, err := svc.ListObjectVersions(&s3.ListObjectVersionsInput{Bucket: aws.String(defaultBucket)})
+		ts.OK(err)
+		listed := map[string]bool{}
+		for _, ver := range out.Versions {
+			listed[aws.StringValue(ver.VersionId)] = aws.BoolValue(ver.IsLatest)
+		}
+		if len(listed) != 2 || !listed[second] {
+			t.Fatal("second version is not the latest", listed)
+		}
+		if latest, ok := listed[first]; !ok || latest {
+			t.Fatal("first version is not listed as noncurrent", listed)
+		}
+
+		ts.OKAll(svc.PutBucketVersioning(&s3.PutBucketVersioningInput{
+			Bucket: aws.String(defaultBucket),
+			VersioningConfiguration: &s3.VersioningConfiguration{
+				Status: aws.String(string(gofakes3.VersioningSuspended)),
+			},
+		}))
+		if version := put(ts, "three"); version != "" {
+			t.Fatal("unexpected version once suspended", version)
+		}
+	})
+}
+
+func TestHeadObjectVersion(t *testing.T) {
+	ts := newTestServer(t, withVersioning())
+	defer ts.Close()
+	svc := ts.s3Client()
+
+	var versions []string
+	for _, body := range []string{"hello", "hello world"} {
+		put, err := svc.PutObject(&s3.PutObjectInput{
+			Bucket: aws.String(defaultBucket),
+			Key:    aws.String("object"),
+			Body:   strings.NewReader(body),
+		})
+		ts.OK(err)
+		versions = append(versions, aws.StringValue(put.VersionId))
+	}
+	del, err := svc.DeleteObject(&s3.DeleteObjectInput{Bucket: aws.String(defaultBucket), Key: aws.String("object")})
+	ts.OK(err)
+	marker := aws.StringValue(del.VersionId)
+
+	for i, size := range []int64{5, 11} {
+		head, err := svc.HeadObject(&s3.HeadObjectInput{
+			Bucket:    aws.String(defaultBucket),
+			Key:       aws.String("object"),
+			VersionId: aws.String(versions[i]),
+		})
+		ts.OK(err)
+		if aws.Int64Value(head.ContentLength) != size || aws.StringValue(head.VersionId) != versions[i] || head.LastModified == nil {
+			t.Fatal("unexpected head output for version", i, head)
+		}
+	}
+
+	rs, body := ts.sendRaw("HEAD", defaultBucket+"/object", nil, nil)
+	if rs.StatusCode != http.StatusNotFound ||
+		rs.Header.Get("x-amz-delete-marker") != "true" ||
+		rs.Header.Get("x-amz-version-id") != marker {
+		t.Fatal("unexpected response", rs.StatusCode, rs.Header)
+	}
+
+	// A delete marker named by its version ID can only be deleted:
+	for _, method := range []string{"HEAD", "GET"} {
+		rs, body = ts.sendRaw(method, defaultBucket+"/object?versionId="+marker, nil, nil)
+		if rs.StatusCode != http.StatusMethodNotAllowed ||
+			rs.Header.Get("x-amz-delete-marker") != "true" ||
+			rs.Header.Get("x-amz-version-id") != marker ||
+			rs.Header.Get("Allow") != "DELETE" {
+			t.Fatal("unexpected response", method, rs.StatusCode, rs.Header, string(body))
+		}
+		if method == "GET" {
+			ts.assertRawErrorCode(rs, body, gofakes3.ErrMethodNotAllowed)
+		}
+	}
+}
+
 func TestObjectVersions(t *testing.T) {
 	create := func(ts *testServer, bucket, key string, contents []byte, version string) {
 		ts.Helper()
@@ -1036,6 +2475,37 @@ func TestObjectVersions(t *testing.T) {
 		}
 	})
 
+	t.Run("head-version", func(t *testing.T) {
+		ts := newTestServer(t, withVersioning())
+		defer ts.Close()
+		svc := ts.s3Client()
+
+		create(ts, defaultBucket, "object", []byte("body 1"), v1)
+		create(ts, defaultBucket, "object", []byte("longer body 2"), v2)
+
+		for _, tc := range []struct {
+			version string
+			size    int64
+		}{
+			{v1, 6},
+			{v2, 13},
+			{"", 13},
+		} {
+			input := &s3.HeadObjectInput{
+				Bucket: aws.String(defaultBucket),
+				Key:    aws.String("object"),
+			}
+			if tc.version != "" {
+				input.VersionId = aws.String(tc.version)
+			}
+			out, err := svc.HeadObject(input)
+			ts.OK(err)
+			if aws.Int64Value(out.ContentLength) != tc.size {
+				t.Fatal("size mismatch for version", tc.version, "found:", aws.Int64Value(out.ContentLength), "expected:", tc.size)
+			}
+		}
+	})
+
 	t.Run("list-never-versioned", func(t *testing.T) {
 		ts := newTestServer(t, withVersioning())
 		defer ts.Close()
@@ -1152,6 +2622,217 @@ func TestListBucketPages(t *testing.T) {
 	}
 }
 
+func TestListBucketPagesCommonPrefixBoundary(t *testing.T) {
+	// Keys and common prefixes are interleaved, so every page size puts a
+	// prefix at the truncation boundary at least once:
+	keys := []string{"a", "b/1", "b/2", "c", "d/1", "d/2", "d/3", "e"}
+	expectedKeys := []string{"a", "c", "e"}
+	expectedPrefixes := []string{"b/", "d/"}
+
+	assertResult := func(t *testing.T, rs *listBucketResult) {
+		t.Helper()
+		found := make([]string, len(rs.Contents))
+		for i, item := range rs.Contents {
+			found[i] = aws.StringValue(item.Key)
+		}
+		if !reflect.DeepEqual(found, expectedKeys) {
+			t.Fatal("key mismatch:", expectedKeys, "!=", found)
+		}
+		found = make([]string, len(rs.CommonPrefixes))
+		for i, item := range rs.CommonPrefixes {
+			found[i] = aws.StringValue(item.Prefix)
+		}
+		if !reflect.DeepEqual(found, expectedPrefixes) {
+			t.Fatal("prefix mismatch:", expectedPrefixes, "!=", found)
+		}
+	}
+
+	for pageKeys := int64(1); pageKeys <= 6; pageKeys++ {
+		t.Run(fmt.Sprintf("%d", pageKeys), func(t *testing.T) {
+			ts := newTestServer(t)
+			defer ts.Close()
+			for _, key := range keys {
+				ts.backendPutString(defaultBucket, key, nil, "body")
+			}
+
+			prefix := gofakes3.NewFolderPrefix("")
+			assertResult(t, ts.mustListBucketV1Pages(&prefix, pageKeys, ""))
+			assertResult(t, ts.mustListBucketV2Pages(&prefix, pageKeys, ""))
+		})
+	}
+
+	t.Run("next-marker", func(t *testing.T) {
+		ts := newTestServer(t)
+		defer ts.Close()
+		svc := ts.s3Client()
+		for _, key := range keys {
+			ts.backendPutString(defaultBucket, key, nil, "body")
+		}
+
+		out, err := svc.ListObjects(&s3.ListObjectsInput{
+			Bucket:    aws.String(defaultBucket),
+			Delimiter: aws.String("/"),
+			MaxKeys:   aws.Int64(2),
+		})
+		ts.OK(err)
+		if !aws.BoolValue(out.IsTruncated) || aws.StringValue(out.NextMarker) != "b/" {
+			t.Fatal("unexpected next marker", aws.StringValue(out.NextMarker))
+		}
+
+		out, err = svc.ListObjects(&s3.ListObjectsInput{
+			Bucket:    aws.String(defaultBucket),
+			Delimiter: aws.String("/"),
+			Marker:    out.NextMarker,
+			MaxKeys:   aws.Int64(2),
+		})
+		ts.OK(err)
+		if len(out.Contents) != 1 || aws.StringValue(out.Contents[0].Key) != "c" ||
+			len(out.CommonPrefixes) != 1 || aws.StringValue(out.CommonPrefixes[0].Prefix) != "d/" {
+			t.Fatal("unexpected page after common prefix", out)
+		}
+		if aws.StringValue(out.NextMarker) != "d/" {
+			t.Fatal("unexpected next marker", aws.StringValue(out.NextMarker))
+		}
+	})
+}
+
+func TestListBucketV1NextMarker(t *testing.T) {
+	ts := newTestServer(t)
+	defer ts.Close()
+	svc := ts.s3Client()
+	for _, key := range []string{"a", "b/1", "c"} {
+		ts.backendPutString(defaultBucket, key, nil, "body")
+	}
+
+	for _, tc := range []struct {
+		delimiter  string
+		maxKeys    int64
+		truncated  bool
+		nextMarker string
+	}{
+		{"/", 2, true, "b/"},
+		{"/", 3, false, ""},
+		{"", 2, true, ""},
+		{"", 3, false, ""},
+	} {
+		t.Run(fmt.Sprintf("%q/%d", tc.delimiter, tc.maxKeys), func(t *testing.T) {
+			in := &s3.ListObjectsInput{
+				Bucket:  aws.String(defaultBucket),
+				MaxKeys: aws.Int64(tc.maxKeys),
+			}
+			if tc.delimiter != "" {
+				in.Delimiter = aws.String(tc.delimiter)
+			}
+			out, err := svc.ListObjects(in)
+			ts.OK(err)
+			if aws.BoolValue(out.IsTruncated) != tc.truncated {
+				t.Fatal("unexpected truncation", aws.BoolValue(out.IsTruncated))
+			}
+			if tc.nextMarker == "" && out.NextMarker != nil {
+				t.Fatal("unexpected next marker", aws.StringValue(out.NextMarker))
+			} else if aws.StringValue(out.NextMarker) != tc.nextMarker {
+				t.Fatal("unexpected next marker", aws.StringValue(out.NextMarker))
+			}
+		})
+	}
+}
+
+func TestUnicodeKeysAreNotNormalized(t *testing.T) {
+	ts := newTestServer(t)
+	defer ts.Close()
+	svc := ts.s3Client()
+
+	// Canonically equivalent, but different bytes; like S3, the keys are
+	// kept as they are sent and sorted byte by byte:
+	const (
+		nfc = "caf\u00e9"  // 'é' as a single code point
+		nfd = "cafe\u0301" // 'e' followed by a combining acute accent
+	)
+	for _, key := range []string{nfc, nfd} {
+		ts.OKAll(svc.PutObject(&s3.PutObjectInput{
+			Bucket: aws.String(defaultBucket),
+			Key:    aws.String(key),
+			Body:   strings.NewReader(key),
+		}))
+	}
+
+	for _, key := range []string{nfc, nfd} {
+		ts.assertObject(defaultBucket, key, nil, key)
+	}
+
+	rs, err := svc.ListObjectsV2(&s3.ListObjectsV2Input{Bucket: aws.String(defaultBucket)})
+	ts.OK(err)
+	var found []string
+	for _, item := range rs.Contents {
+		found = append(found, aws.StringValue(item.Key))
+	}
+	if !reflect.DeepEqual(found, []string{nfd, nfc}) {
+		t.Fatalf("unexpected keys %q", found)
+	}
+}
+
+func TestListBucketMaxKeys(t *testing.T) {
+	ts := newTestServer(t)
+	defer ts.Close()
+	svc := ts.s3Client()
+	ts.backendPutString(defaultBucket, "obj", nil, "hello")
+
+	v1, err := svc.ListObjects(&s3.ListObjectsInput{Bucket: aws.String(defaultBucket), MaxKeys: aws.Int64(2000)})
+	ts.OK(err)
+	if aws.Int64Value(v1.MaxKeys) != gofakes3.MaxBucketKeys {
+		t.Fatal("unexpected max keys", aws.Int64Value(v1.MaxKeys))
+	}
+	v2, err := svc.ListObjectsV2(&s3.ListObjectsV2Input{Bucket: aws.String(defaultBucket), MaxKeys: aws.Int64(2)})
+	ts.OK(err)
+	if aws.Int64Value(v2.MaxKeys) != 2 {
+		t.Fatal("unexpected max keys", aws.Int64Value(v2.MaxKeys))
+	}
+
+	for _, query := range []string{"?max-keys=-1", "?list-type=2&max-keys=-1", "?versions&max-keys=-1"} {
+		rs, body := ts.sendRaw("GET", defaultBucket+query, nil, nil)
+		ts.assertRawErrorCode(rs, body, gofakes3.ErrInvalidArgument)
+	}
+}
+
+func TestListBucketPagesIterator(t *testing.T) {
+	// s3bolt does not implement paging in ListBucket, but implements
+	// IteratingBackend, so GoFakeS3 can page the listing itself. Were it to
+	// fall back to ListBucket, WithUnimplementedPageError would fail the
+	// request:
+	keys := []string{"a", "b/1", "b/2", "c", "d/1", "d/2", "d/3", "e"}
+
+	for pageKeys := int64(1); pageKeys <= 6; pageKeys++ {
+		t.Run(fmt.Sprintf("%d", pageKeys), func(t *testing.T) {
+			db, err := bolt.Open(filepath.Join(t.TempDir(), "bolt.db"), 0600, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer db.Close()
+
+			ts := newTestServer(t, withBackend(s3bolt.New(db)), withFakerOptions(gofakes3.WithUnimplementedPageError()))
+			defer ts.Close()
+			for _, key := range keys {
+				ts.backendPutString(defaultBucket, key, nil, "body")
+			}
+
+			prefix := gofakes3.NewFolderPrefix("")
+			for _, rs := range []*listBucketResult{
+				ts.mustListBucketV1Pages(&prefix, pageKeys, ""),
+				ts.mustListBucketV2Pages(&prefix, pageKeys, ""),
+			} {
+				if len(rs.Contents) != 3 || len(rs.CommonPrefixes) != 2 {
+					t.Fatal("unexpected listing", rs.Contents, rs.CommonPrefixes)
+				}
+			}
+
+			all := ts.mustListBucketV2Pages(nil, pageKeys, "b/1")
+			if len(all.Contents) != len(keys)-2 || aws.StringValue(all.Contents[0].Key) != "b/2" {
+				t.Fatal("unexpected listing", all.Contents)
+			}
+		})
+	}
+}
+
 // Ensure that a backend that does not support pagination can use the fallback if enabled:
 func TestListBucketPagesFallback(t *testing.T) {
 	createData := func(ts *testServer, prefix string, n int64) []string {
@@ -1190,7 +2871,574 @@ func TestListBucketPagesFallback(t *testing.T) {
 	})
 }
 
+// Markers are exclusive; listing starts with the first key strictly after the
+// marker, whether or not the marker is itself an existing key.
+func TestListBucketMarker(t *testing.T) {
+	ts := newTestServer(t)
+	defer ts.Close()
+	svc := ts.s3Client()
+
+	for _, key := range []string{"a", "b", "c", "d"} {
+		ts.backendPutString(defaultBucket, key, nil, "body")
+	}
+
+	for idx, tc := range []struct {
+		marker string
+		keys   []string
+	}{
+		{"", []string{"a", "b", "c", "d"}},
+		{"0", []string{"a", "b", "c", "d"}},
+		{"a", []string{"b", "c", "d"}},
+		{"b", []string{"c", "d"}},
+		{"bb", []string{"c", "d"}},
+		{"d", nil},
+		{"z", nil},
+	} {
+		t.Run(fmt.Sprintf("%d/%s", idx, tc.marker), func(t *testing.T) {
+			v1, err := svc.ListObjects(&s3.ListObjectsInput{
+				Bucket: aws.String(defaultBucket),
+				Marker: aws.String(tc.marker),
+			})
+			ts.OK(err)
+
+			var found []string
+			for _, item := range v1.Contents {
+				found = append(found, aws.StringValue(item.Key))
+			}
+			if !reflect.DeepEqual(found, tc.keys) {
+				t.Fatal("v1 key mismatch:", tc.keys, "!=", found)
+			}
+
+			v2, err := svc.ListObjectsV2(&s3.ListObjectsV2Input{
+				Bucket:     aws.String(defaultBucket),
+				StartAfter: aws.String(tc.marker),
+			})
+			ts.OK(err)
+
+			found = nil
+			for _, item := range v2.Contents {
+				found = append(found, aws.StringValue(item.Key))
+			}
+			if !reflect.DeepEqual(found, tc.keys) {
+				t.Fatal("v2 key mismatch:", tc.keys, "!=", found)
+			}
+		})
+	}
+}
+
+// The marker applies within the prefix: a listing with both returns only the
+// keys under the prefix that sort after the marker, whichever way the
+// Backend pages.
+func TestListBucketPrefixAndMarker(t *testing.T) {
+	keys := []string{"a", "foo/a", "foo/bar", "foo/bar/1", "foo/baz", "foo/bb/1", "foo/bb/2", "fooz", "zz"}
+
+	backends := map[string]func(t *testing.T) gofakes3.Backend{
+		"s3mem": func(t *testing.T) gofakes3.Backend { return s3mem.New() },
+		"iterator": func(t *testing.T) gofakes3.Backend {
+			db, err := bolt.Open(filepath.Join(t.TempDir(), "bolt.db"), 0600, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(func() { db.Close() })
+			return s3bolt.New(db)
+		},
+		"fallback": func(t *testing.T) gofakes3.Backend { return &backendWithUnimplementedPaging{s3mem.New()} },
+	}
+
+	for name, backend := range backends {
+		t.Run(name, func(t *testing.T) {
+			ts := newTestServer(t, withBackend(backend(t)))
+			defer ts.Close()
+			svc := ts.s3Client()
+			for _, key := range keys {
+				ts.backendPutString(defaultBucket, key, nil, "body")
+			}
+
+			for idx, tc := range []struct {
+				marker, delimiter string
+				keys, prefixes    []string
+			}{
+				{"foo/bar", "", []string{"foo/bar/1", "foo/baz", "foo/bb/1", "foo/bb/2"}, nil},
+				{"foo/bar", "/", []string{"foo/baz"}, []string{"foo/bar/", "foo/bb/"}},
+				{"foo/bar/1", "/", []string{"foo/baz"}, []string{"foo/bb/"}},
+				{"foo/bb/1", "/", nil, []string{"foo/bb/"}},
+				{"a", "", []string{"foo/a", "foo/bar", "foo/bar/1", "foo/baz", "foo/bb/1", "foo/bb/2"}, nil},
+				{"foo0", "", nil, nil},
+			} {
+				t.Run(fmt.Sprintf("%d/%s", idx, tc.marker), func(t *testing.T) {
+					input := &s3.ListObjectsInput{
+						Bucket: aws.String(defaultBucket),
+						Prefix: aws.String("foo/"),
+						Marker: aws.String(tc.marker),
+					}
+					if tc.delimiter != "" {
+						input.Delimiter = aws.String(tc.delimiter)
+					}
+					rs, err := svc.ListObjects(input)
+					ts.OK(err)
+
+					var found, prefixes []string
+					for _, item := range rs.Contents {
+						found = append(found, aws.StringValue(item.Key))
+					}
+					for _, prefix := range rs.CommonPrefixes {
+						prefixes = append(prefixes, aws.StringValue(prefix.Prefix))
+					}
+					if !reflect.DeepEqual(found, tc.keys) || !reflect.DeepEqual(prefixes, tc.prefixes) {
+						t.Fatal("unexpected listing", found, prefixes)
+					}
+				})
+			}
+		})
+	}
+}
+
+func TestListBucketNoMatches(t *testing.T) {
+	ts := newTestServer(t)
+	defer ts.Close()
+	ts.backendPutString(defaultBucket, "dir/1", nil, "body")
+	ts.backendPutString(defaultBucket, "dir/2", nil, "body")
+
+	type listing struct {
+		Prefix                *string
+		Delimiter             *string
+		IsTruncated           *string
+		KeyCount              *string
+		NextMarker            *string
+		NextContinuationToken *string
+		Contents              []struct{ Key string }
+		CommonPrefixes        []struct{ Prefix string }
+	}
+	list := func(query string) (l listing) {
+		t.Helper()
+		rs, body := ts.sendRaw("GET", defaultBucket+"?"+query, nil, nil)
+		if rs.StatusCode != http.StatusOK {
+			t.Fatal("unexpected status", rs.StatusCode, string(body))
+		}
+		ts.OK(xml.Unmarshal(body, &l))
+		return l
+	}
+
+	for _, query := range []string{"prefix=missing/&delimiter=/", "list-type=2&prefix=missing/&delimiter=/"} {
+		l := list(query)
+		if aws.StringValue(l.Prefix) != "missing/" || aws.StringValue(l.Delimiter) != "/" {
+			t.Fatal("prefix and delimiter not echoed", query, l)
+		}
+		if aws.StringValue(l.IsTruncated) != "false" || len(l.Contents) != 0 || len(l.CommonPrefixes) != 0 {
+			t.Fatal("unexpected listing", query, l)
+		}
+	}
+	if l := list("list-type=2&prefix=missing/"); aws.StringValue(l.KeyCount) != "0" {
+		t.Fatal("unexpected key count", l.KeyCount)
+	}
+
+	// A page that is filled exactly by the last keys is not truncated:
+	for _, query := range []string{"prefix=dir/&delimiter=/&max-keys=2", "list-type=2&prefix=dir/&max-keys=2"} {
+		l := list(query)
+		if aws.StringValue(l.IsTruncated) != "false" || l.NextMarker != nil || l.NextContinuationToken != nil || len(l.Contents) != 2 {
+			t.Fatal("unexpected listing", query, l)
+		}
+	}
+}
+
+func TestListBucketV2ContinuationTokenOverridesStartAfter(t *testing.T) {
+	ts := newTestServer(t)
+	defer ts.Close()
+	svc := ts.s3Client()
+
+	for _, key := range []string{"a", "b", "c", "d"} {
+		ts.backendPutString(defaultBucket, key, nil, "body")
+	}
+
+	first, err := svc.ListObjectsV2(&s3.ListObjectsV2Input{
+		Bucket:  aws.String(defaultBucket),
+		MaxKeys: aws.Int64(1),
+	})
+	ts.OK(err)
+	if first.NextContinuationToken == nil {
+		t.Fatal("expected a continuation token")
+	}
+
+	// start-after 'c' would skip 'b' and 'c', but the continuation token
+	// resumes after 'a', and start-after is ignored:
+	rs, err := svc.ListObjectsV2(&s3.ListObjectsV2Input{
+		Bucket:            aws.String(defaultBucket),
+		ContinuationToken: first.NextContinuationToken,
+		StartAfter:        aws.String("c"),
+	})
+	ts.OK(err)
+
+	var found []string
+	for _, item := range rs.Contents {
+		found = append(found, aws.StringValue(item.Key))
+	}
+	if !reflect.DeepEqual(found, []string{"b", "c", "d"}) {
+		t.Fatal("unexpected keys", found)
+	}
+	if aws.StringValue(rs.StartAfter) != "c" || aws.StringValue(rs.ContinuationToken) != aws.StringValue(first.NextContinuationToken) {
+		t.Fatal("unexpected echoed parameters", rs.StartAfter, rs.ContinuationToken)
+	}
+}
+
+func TestListBucketV2FetchOwner(t *testing.T) {
+	ts := newTestServer(t)
+	defer ts.Close()
+	svc := ts.s3Client()
+
+	ts.backendPutString(defaultBucket, "object", nil, "body")
+
+	for _, tc := range []struct {
+		fetchOwner *bool
+		owner      bool
+	}{
+		{nil, false},
+		{aws.Bool(false), false},
+		{aws.Bool(true), true},
+	} {
+		t.Run(fmt.Sprint(aws.BoolValue(tc.fetchOwner)), func(t *testing.T) {
+			rs, err := svc.ListObjectsV2(&s3.ListObjectsV2Input{
+				Bucket:     aws.String(defaultBucket),
+				FetchOwner: tc.fetchOwner,
+			})
+			ts.OK(err)
+			if len(rs.Contents) != 1 {
+				t.Fatal("unexpected contents", rs.Contents)
+			}
+
+			owner := rs.Contents[0].Owner
+			if !tc.owner {
+				if owner != nil {
+					t.Fatal("unexpected owner", owner)
+				}
+				return
+			}
+			if owner == nil || aws.StringValue(owner.ID) == "" || aws.StringValue(owner.DisplayName) != "GoFakeS3" {
+				t.Fatal("unexpected owner", owner)
+			}
+		})
+	}
+}
+
+func TestListBucketEncodingType(t *testing.T) {
+	ts := newTestServer(t)
+	defer ts.Close()
+
+	for _, key := range []string{"a/b c/d", "a/x+y", "top key&1"} {
+		ts.backendPutString(defaultBucket, key, nil, "body")
+	}
+
+	list := func(query string, result interface{}) {
+		t.Helper()
+		rs, body := ts.sendRaw("GET", defaultBucket+"?"+query, nil, nil)
+		if rs.StatusCode != http.StatusOK {
+			t.Fatal("list failed", rs.StatusCode, string(body))
+		}
+		ts.OK(xml.Unmarshal(body, result))
+	}
+	prefixes := func(base gofakes3.ListBucketResultBase) (prefixes, keys []string) {
+		for _, prefix := range base.CommonPrefixes {
+			prefixes = append(prefixes, prefix.Prefix)
+		}
+		for _, item := range base.Contents {
+			keys = append(keys, item.Key)
+		}
+		return prefixes, keys
+	}
+
+	t.Run("v1", func(t *testing.T) {
+		var result gofakes3.ListBucketResult
+		list("delimiter=/&encoding-type=url", &result)
+		commonPrefixes, keys := prefixes(result.ListBucketResultBase)
+		if result.EncodingType != "url" ||
+			!reflect.DeepEqual(commonPrefixes, []string{"a/"}) ||
+			!reflect.DeepEqual(keys, []string{"top+key%261"}) {
+			t.Fatal("unexpected listing", result.EncodingType, commonPrefixes, keys)
+		}
+	})
+
+	t.Run("v2-prefix", func(t *testing.T) {
+		var result gofakes3.ListBucketResultV2
+		list("list-type=2&prefix=a/&delimiter=/&encoding-type=url", &result)
+		commonPrefixes, keys := prefixes(result.ListBucketResultBase)
+		if result.EncodingType != "url" || result.Prefix != "a/" ||
+			!reflect.DeepEqual(commonPrefixes, []string{"a/b+c/"}) ||
+			!reflect.DeepEqual(keys, []string{"a/x%2By"}) {
+			t.Fatal("unexpected listing", result.EncodingType, result.Prefix, commonPrefixes, keys)
+		}
+	})
+
+	t.Run("not-encoded", func(t *testing.T) {
+		var result gofakes3.ListBucketResultV2
+		list("list-type=2&prefix=a/&delimiter=/", &result)
+		commonPrefixes, keys := prefixes(result.ListBucketResultBase)
+		if result.EncodingType != "" ||
+			!reflect.DeepEqual(commonPrefixes, []string{"a/b c/"}) ||
+			!reflect.DeepEqual(keys, []string{"a/x+y"}) {
+			t.Fatal("unexpected listing", result.EncodingType, commonPrefixes, keys)
+		}
+	})
+
+	t.Run("versions", func(t *testing.T) {
+		rs, body := ts.sendRaw("GET", defaultBucket+"?versions&prefix=a/&delimiter=/&encoding-type=url", nil, nil)
+		if rs.StatusCode != http.StatusOK {
+			t.Fatal("list failed", rs.StatusCode, string(body))
+		}
+		for _, expected := range []string{
+			"<EncodingType>url</EncodingType>",
+			"<Prefix>a/b+c/</Prefix>",
+			"<Key>a/x%2By</Key>",
+		} {
+			if !strings.Contains(string(body), expected) {
+				t.Fatal("expected", expected, "in", string(body))
+			}
+		}
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		rs, body := ts.sendRaw("GET", defaultBucket+"?encoding-type=base64", nil, nil)
+		ts.assertRawErrorCode(rs, body, gofakes3.ErrInvalidArgument)
+	})
+}
+
 func tryDumpResponse(rs *http.Response, body bool) string {
 	b, _ := httputil.DumpResponse(rs, body)
 	return string(b)
 }
+
+func TestETagQuoting(t *testing.T) {
+	backend := &backendWithBareETags{Backend: s3mem.New(s3mem.WithTimeSource(gofakes3.FixedTimeSource(defaultDate)))}
+	ts := newTestServer(t, withBackend(backend))
+	defer ts.Close()
+	svc := ts.s3Client()
+
+	const expected = `"9a0364b9e99bb480dd25e1f0284c8555"` // md5("content")
+	assertETag := func(op string, etag *string) {
+		t.Helper()
+		if aws.StringValue(etag) != expected {
+			t.Fatalf("%s: expected ETag %s, found %s", op, expected, aws.StringValue(etag))
+		}
+	}
+
+	put, err := svc.PutObject(&s3.PutObjectInput{
+		Bucket: aws.String(defaultBucket),
+		Key:    aws.String("object"),
+		Body:   bytes.NewReader([]byte("content")),
+	})
+	ts.OK(err)
+	assertETag("put", put.ETag)
+
+	head, err := svc.HeadObject(&s3.HeadObjectInput{Bucket: aws.String(defaultBucket), Key: aws.String("object")})
+	ts.OK(err)
+	assertETag("head", head.ETag)
+
+	get, err := svc.GetObject(&s3.GetObjectInput{Bucket: aws.String(defaultBucket), Key: aws.String("object")})
+	ts.OK(err)
+	get.Body.Close()
+	assertETag("get", get.ETag)
+
+	copied, err := svc.CopyObject(&s3.CopyObjectInput{
+		Bucket:     aws.String(defaultBucket),
+		Key:        aws.String("copied"),
+		CopySource: aws.String(defaultBucket + "/object"),
+	})
+	ts.OK(err)
+	assertETag("copy", copied.CopyObjectResult.ETag)
+
+	list, err := svc.ListObjects(&s3.ListObjectsInput{Bucket: aws.String(defaultBucket)})
+	ts.OK(err)
+	for _, item := range list.Contents {
+		assertETag("list", item.ETag)
+	}
+
+	listV2, err := svc.ListObjectsV2(&s3.ListObjectsV2Input{Bucket: aws.String(defaultBucket)})
+	ts.OK(err)
+	for _, item := range listV2.Contents {
+		assertETag("list-v2", item.ETag)
+	}
+
+	uploadID := ts.createMultipartUpload(defaultBucket, "multi", nil)
+	part := ts.uploadPart(defaultBucket, "multi", uploadID, 1, []byte("content"))
+	assertETag("upload-part", part.ETag)
+
+	parts, err := svc.ListParts(&s3.ListPartsInput{
+		Bucket:   aws.String(defaultBucket),
+		Key:      aws.String("multi"),
+		UploadId: aws.String(uploadID),
+	})
+	ts.OK(err)
+	for _, item := range parts.Parts {
+		assertETag("list-parts", item.ETag)
+	}
+
+	complete, err := svc.CompleteMultipartUpload(&s3.CompleteMultipartUploadInput{
+		Bucket:          aws.String(defaultBucket),
+		Key:             aws.String("multi"),
+		UploadId:        aws.String(uploadID),
+		MultipartUpload: &s3.CompletedMultipartUpload{Parts: []*s3.CompletedPart{part}},
+	})
+	ts.OK(err)
+	if etag := aws.StringValue(complete.ETag); !strings.HasPrefix(etag, `"`) || !strings.HasSuffix(etag, `"`) {
+		t.Fatal("complete: expected quoted ETag, found", etag)
+	}
+}
+
+func TestGetObjectDeleteMarker(t *testing.T) {
+	ts := newTestServer(t, withVersioning())
+	defer ts.Close()
+	svc := ts.s3Client()
+
+	put, err := svc.PutObject(&s3.PutObjectInput{
+		Bucket: aws.String(defaultBucket),
+		Key:    aws.String("object"),
+		Body:   strings.NewReader("hello"),
+	})
+	ts.OK(err)
+	del, err := svc.DeleteObject(&s3.DeleteObjectInput{Bucket: aws.String(defaultBucket), Key: aws.String("object")})
+	ts.OK(err)
+	marker := aws.StringValue(del.VersionId)
+
+	t.Run("latest", func(t *testing.T) {
+		rs, body := ts.sendRaw("GET", defaultBucket+"/object", nil, nil)
+		ts.assertRawErrorCode(rs, body, gofakes3.ErrNoSuchKey)
+		if rs.StatusCode != http.StatusNotFound ||
+			rs.Header.Get("x-amz-delete-marker") != "true" ||
+			rs.Header.Get("x-amz-version-id") != marker {
+			t.Fatal("unexpected response", rs.StatusCode, rs.Header)
+		}
+	})
+
+	t.Run("marker-version", func(t *testing.T) {
+		rs, body := ts.sendRaw("GET", defaultBucket+"/object?versionId="+marker, nil, nil)
+		ts.assertRawErrorCode(rs, body, gofakes3.ErrMethodNotAllowed)
+		if rs.StatusCode != http.StatusMethodNotAllowed ||
+			rs.Header.Get("x-amz-delete-marker") != "true" ||
+			rs.Header.Get("x-amz-version-id") != marker {
+			t.Fatal("unexpected response", rs.StatusCode, rs.Header)
+		}
+	})
+
+	// A key that was never written is simply missing:
+	t.Run("missing", func(t *testing.T) {
+		rs, body := ts.sendRaw("GET", defaultBucket+"/objectx", nil, nil)
+		ts.assertRawErrorCode(rs, body, gofakes3.ErrNoSuchKey)
+		if rs.Header.Get("x-amz-delete-marker") != "" || rs.Header.Get("x-amz-version-id") != "" {
+			t.Fatal("unexpected headers", rs.Header)
+		}
+	})
+
+	// The version hidden behind the marker can still be read by its ID:
+	t.Run("hidden-version", func(t *testing.T) {
+		rs, body := ts.sendRaw("GET", defaultBucket+"/object?versionId="+aws.StringValue(put.VersionId), nil, nil)
+		if rs.StatusCode != http.StatusOK || string(body) != "hello" || rs.Header.Get("x-amz-delete-marker") != "" {
+			t.Fatal("unexpected response", rs.StatusCode, rs.Header, string(body))
+		}
+	})
+}
+
+func TestListObjectVersionsPaging(t *testing.T) {
+	ts := newTestServer(t, withVersioning())
+	defer ts.Close()
+	svc := ts.s3Client()
+
+	// Each key's versions are expected newest first:
+	var expected []string
+	put := func(key string, n int) {
+		t.Helper()
+		var versions []string
+		for i := 0; i < n; i++ {
+			out, err := svc.PutObject(&s3.PutObjectInput{
+				Bucket: aws.String(defaultBucket),
+				Key:    aws.String(key),
+				Body:   strings.NewReader(fmt.Sprint(i)),
+			})
+			ts.OK(err)
+			versions = append([]string{key + " " + aws.StringValue(out.VersionId)}, versions...)
+		}
+		expected = append(expected, versions...)
+	}
+	put("a", 1)
+	put("many", 7)
+	del, err := svc.DeleteObject(&s3.DeleteObjectInput{Bucket: aws.String(defaultBucket), Key: aws.String("many")})
+	ts.OK(err)
+	expected = append(expected[:1], append([]string{"many " + aws.StringValue(del.VersionId)}, expected[1:]...)...)
+	put("z", 2)
+
+	type listing struct {
+		IsTruncated         bool
+		NextKeyMarker       string
+		NextVersionIdMarker string
+		Items               []struct {
+			XMLName   xml.Name
+			Key       string
+			VersionId string
+			IsLatest  bool
+		} `xml:",any"`
+	}
+
+	for _, maxKeys := range []int{1, 3, 4, 1000} {
+		t.Run(fmt.Sprint(maxKeys), func(t *testing.T) {
+			var found []string
+			var keyMarker, versionIDMarker string
+			for page := 0; ; page++ {
+				if page > len(expected) {
+					t.Fatal("too many pages")
+				}
+				query := url.Values{"max-keys": {fmt.Sprint(maxKeys)}}
+				if keyMarker != "" {
+					query.Set("key-marker", keyMarker)
+					query.Set("version-id-marker", versionIDMarker)
+				}
+				rs, body := ts.sendRaw("GET", defaultBucket+"?versions&"+query.Encode(), nil, nil)
+				if rs.StatusCode != http.StatusOK {
+					t.Fatal("unexpected status", rs.StatusCode, string(body))
+				}
+				var out listing
+				ts.OK(xml.Unmarshal(body, &out))
+
+				var items int
+				for _, item := range out.Items {
+					if item.XMLName.Local != "Version" && item.XMLName.Local != "DeleteMarker" {
+						continue
+					}
+					// Only the first listed version of a key is its latest:
+					latest := len(found) == 0 || !strings.HasPrefix(found[len(found)-1], item.Key+" ")
+					if item.IsLatest != latest {
+						t.Fatal("unexpected IsLatest for", item.Key, item.VersionId)
+					}
+					found = append(found, item.Key+" "+item.VersionId)
+					items++
+				}
+				if items > maxKeys {
+					t.Fatal("page has", items, "items, more than", maxKeys)
+				}
+				if !out.IsTruncated {
+					if out.NextKeyMarker != "" || out.NextVersionIdMarker != "" {
+						t.Fatal("unexpected markers on last page", out.NextKeyMarker, out.NextVersionIdMarker)
+					}
+					break
+				}
+				if last := found[len(found)-1]; last != out.NextKeyMarker+" "+out.NextVersionIdMarker {
+					t.Fatal("markers", out.NextKeyMarker, out.NextVersionIdMarker, "are not the last version returned", last)
+				}
+				keyMarker, versionIDMarker = out.NextKeyMarker, out.NextVersionIdMarker
+			}
+
+			if !reflect.DeepEqual(found, expected) {
+				t.Fatal("versions mismatch\nfound:   ", found, "\nexpected:", expected)
+			}
+		})
+	}
+
+	// A key marker on its own skips every version of that key:
+	rs, body := ts.sendRaw("GET", defaultBucket+"?versions&key-marker=many", nil, nil)
+	var out listing
+	ts.OK(xml.Unmarshal(body, &out))
+	if rs.StatusCode != http.StatusOK || len(out.Items) == 0 || out.Items[len(out.Items)-1].Key != "z" {
+		t.Fatal("unexpected response", rs.StatusCode, string(body))
+	}
+	for _, item := range out.Items {
+		if item.Key == "a" || item.Key == "many" {
+			t.Fatal("unexpected version after key marker", item.Key, item.VersionId)
+		}
+	}
+}
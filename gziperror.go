@@ -0,0 +1,57 @@
+package gofakes3
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/xml"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// acceptsGzip reports whether the request's Accept-Encoding header allows a
+// gzip-encoded response.
+func acceptsGzip(r *http.Request) bool {
+	for _, hdr := range r.Header.Values("Accept-Encoding") {
+		for _, coding := range strings.Split(hdr, ",") {
+			params := strings.Split(coding, ";")
+			if name := strings.TrimSpace(params[0]); name != "gzip" && name != "*" {
+				continue
+			}
+			accepted := true
+			for _, param := range params[1:] {
+				if q := strings.TrimSpace(param); strings.HasPrefix(q, "q=") {
+					weight, err := strconv.ParseFloat(q[2:], 64)
+					accepted = err == nil && weight > 0
+				}
+			}
+			return accepted
+		}
+	}
+	return false
+}
+
+// writeGzipError writes an error response with the body gzip-encoded, for
+// WithGzipErrors. The body is encoded before anything is sent, so that the
+// Content-Length can describe it.
+func (g *GoFakeS3) writeGzipError(w http.ResponseWriter, resp Error) {
+	var body bytes.Buffer
+	gz := gzip.NewWriter(&body)
+	gz.Write([]byte(xml.Header))
+	xe := xml.NewEncoder(gz)
+	xe.Indent("", "  ")
+	if err := xe.Encode(resp); err != nil {
+		g.log.Print(LogErr, err)
+		w.WriteHeader(resp.ErrorCode().Status())
+		return
+	}
+	gz.Close()
+
+	hdr := w.Header()
+	hdr.Set("Content-Type", "application/xml")
+	hdr.Set("Content-Encoding", "gzip")
+	hdr.Set("Content-Length", strconv.Itoa(body.Len()))
+	hdr.Add("Vary", "Accept-Encoding")
+	w.WriteHeader(resp.ErrorCode().Status())
+	w.Write(body.Bytes())
+}
@@ -0,0 +1,45 @@
+package gofakes3
+
+import (
+	"io"
+	"net/http"
+)
+
+// DefaultHealthCheckPath is the path WithHealthCheck serves health checks
+// from if it is given an empty one. The underscore keeps it from being
+// mistaken for a bucket, as it is not allowed in bucket names.
+const DefaultHealthCheckPath = "/_gofakes3/health"
+
+// healthCheckMiddleware implements WithHealthCheck. Like the admin UI, health
+// checks are not S3 requests, so they are answered before any other
+// middleware can reject them.
+func (g *GoFakeS3) healthCheckMiddleware(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, rq *http.Request) {
+		if rq.URL.Path != g.healthCheckPath {
+			handler.ServeHTTP(w, rq)
+			return
+		}
+
+		if rq.Method != http.MethodGet && rq.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+
+		// Load balancers should stop sending requests once they would be
+		// refused:
+		g.shutdownMu.RLock()
+		shuttingDown := g.shuttingDown
+		g.shutdownMu.RUnlock()
+		if shuttingDown {
+			http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		if rq.Method == http.MethodGet {
+			io.WriteString(w, "OK\n")
+		}
+	})
+}
@@ -0,0 +1,115 @@
+package gofakes3
+
+import "net/http"
+
+// Helpers for bucket subresources that hold several configurations, told
+// apart by the 'id' query parameter, like '?analytics' and '?metrics'.
+
+// idConfigsPageSize is the number of configurations S3 returns per page when
+// listing any of these subresources.
+const idConfigsPageSize = 100
+
+func (g *GoFakeS3) getIDConfig(bucket, kind string, r *http.Request) (interface{}, error) {
+	if err := g.ensureBucketExists(bucket); err != nil {
+		return nil, err
+	}
+
+	id := r.URL.Query().Get("id")
+	config, ok := g.configs.get(resourceConfigKey{bucket: bucket, kind: kind, id: id})
+	if !ok {
+		return nil, ResourceError(ErrNoSuchConfiguration, id)
+	}
+	return config, nil
+}
+
+// listIDConfigs returns the page of configurations requested by the
+// 'continuation-token' query parameter.
+func (g *GoFakeS3) listIDConfigs(bucket, kind string, r *http.Request) (configs []interface{}, token, next string, err error) {
+	if err := g.ensureBucketExists(bucket); err != nil {
+		return nil, "", "", err
+	}
+
+	token = r.URL.Query().Get("continuation-token")
+	ids, next, err := pageIDs(g.configs.listIDs(bucket, kind), token, idConfigsPageSize)
+	if err != nil {
+		return nil, "", "", err
+	}
+	for _, id := range ids {
+		if config, ok := g.configs.get(resourceConfigKey{bucket: bucket, kind: kind, id: id}); ok {
+			configs = append(configs, config)
+		}
+	}
+	return configs, token, next, nil
+}
+
+// putIDConfig decodes the request body into config and stores it, provided
+// its id, which is returned by bodyID, matches the 'id' query parameter.
+// validate may be nil.
+func (g *GoFakeS3) putIDConfig(bucket, kind string, limit int, r *http.Request, config interface{}, bodyID func() string, validate func() error) error {
+	if err := g.ensureBucketExists(bucket); err != nil {
+		return err
+	}
+
+	id := r.URL.Query().Get("id")
+	if id == "" {
+		return ErrorInvalidArgument("id", id, "The id parameter is required")
+	}
+	if err := g.xmlDecodeBody(r.Body, config); err != nil {
+		return err
+	}
+	if bodyID() != id {
+		return ErrorInvalidArgument("id", id, "The id in the request body does not match the id parameter")
+	}
+	if validate != nil {
+		if err := validate(); err != nil {
+			return err
+		}
+	}
+
+	key := resourceConfigKey{bucket: bucket, kind: kind, id: id}
+	if _, ok := g.configs.get(key); !ok && len(g.configs.listIDs(bucket, kind)) >= limit {
+		return ErrTooManyConfigurations
+	}
+	g.configs.put(key, config)
+	return nil
+}
+
+func (g *GoFakeS3) deleteIDConfig(bucket, kind string, w http.ResponseWriter, r *http.Request) error {
+	if err := g.ensureBucketExists(bucket); err != nil {
+		return err
+	}
+
+	id := r.URL.Query().Get("id")
+	key := resourceConfigKey{bucket: bucket, kind: kind, id: id}
+	if _, ok := g.configs.get(key); !ok {
+		return ResourceError(ErrNoSuchConfiguration, id)
+	}
+	g.configs.delete(key)
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// validateConfigurationFilter checks that a filter uses at most one of its
+// elements, as S3 requires; several conditions must be combined with And.
+func validateConfigurationFilter(filter *ConfigurationFilter) error {
+	if filter == nil {
+		return nil
+	}
+	var set int
+	if filter.Prefix != "" {
+		set++
+	}
+	if filter.Tag != nil {
+		set++
+	}
+	if filter.AccessPointArn != "" {
+		set++
+	}
+	if filter.And != nil {
+		set++
+	}
+	if set > 1 {
+		return ErrMalformedXML
+	}
+	return nil
+}
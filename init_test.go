@@ -10,6 +10,7 @@ import (
 	"crypto/sha256"
 	"encoding/base64"
 	"encoding/hex"
+	"encoding/xml"
 	"flag"
 	"fmt"
 	"io"
@@ -307,6 +308,42 @@ func (ts *testServer) rawClient() *rawClient {
 	return newRawClient(httpClient(), ts.server.URL)
 }
 
+// sendRaw sends a request to the test server without going through the SDK,
+// for operations that the SDK version we use does not support. The url may
+// include a query string. The response body is read and closed.
+func (ts *testServer) sendRaw(method, url string, body []byte, header http.Header) (*http.Response, []byte) {
+	ts.Helper()
+
+	rq, err := http.NewRequest(method, ts.url(url), bytes.NewReader(body))
+	ts.OK(err)
+	for k, v := range header {
+		rq.Header[k] = v
+	}
+
+	rs, err := httpClient().Do(rq)
+	ts.OK(err)
+	defer rs.Body.Close()
+
+	out, err := ioutil.ReadAll(rs.Body)
+	ts.OK(err)
+	return rs, out
+}
+
+// assertRawErrorCode asserts that a response returned by sendRaw is an S3
+// error response with the given code.
+func (ts *testServer) assertRawErrorCode(rs *http.Response, body []byte, code gofakes3.ErrorCode) {
+	ts.Helper()
+
+	if rs.StatusCode != code.Status() {
+		ts.Fatal("expected status", code.Status(), "found", rs.StatusCode)
+	}
+	var errResp gofakes3.ErrorResponse
+	ts.OK(xml.Unmarshal(body, &errResp))
+	if errResp.Code != code {
+		ts.Fatal("expected code", code, "found", errResp.Code)
+	}
+}
+
 type multipartUploadOptions struct {
 	partSize int64
 }
@@ -347,8 +384,9 @@ func (ts *testServer) createMultipartUpload(bucket, object string, meta map[stri
 
 	svc := ts.s3Client()
 	mpu, err := svc.CreateMultipartUpload(&s3.CreateMultipartUploadInput{
-		Bucket: aws.String(bucket),
-		Key:    aws.String(object),
+		Bucket:   aws.String(bucket),
+		Key:      aws.String(object),
+		Metadata: aws.StringMap(meta),
 	})
 	ts.OK(err)
 	return *mpu.UploadId
@@ -836,6 +874,22 @@ func (b *backendWithUnimplementedPaging) ListBucket(name string, prefix *gofakes
 	return b.Backend.ListBucket(name, prefix, page)
 }
 
+// backendWithObjectSize reports every object as having the given size, so
+// that size limits can be tested without storing that much data.
+type backendWithObjectSize struct {
+	gofakes3.Backend
+	size int64
+}
+
+func (b *backendWithObjectSize) GetObject(bucketName, objectName string, rangeRequest *gofakes3.ObjectRangeRequest) (*gofakes3.Object, error) {
+	obj, err := b.Backend.GetObject(bucketName, objectName, rangeRequest)
+	if err != nil {
+		return nil, err
+	}
+	obj.Size = b.size
+	return obj, nil
+}
+
 type rawClient struct {
 	client *http.Client
 	base   *url.URL
@@ -934,3 +988,20 @@ type maskedReader struct {
 func (r *maskedReader) Read(b []byte) (n int, err error) {
 	return r.inner.Read(b)
 }
+
+// backendWithBareETags strips the quotes from the ETags returned by
+// ListBucket, which GoFakeS3 should restore.
+type backendWithBareETags struct {
+	gofakes3.Backend
+}
+
+func (b *backendWithBareETags) ListBucket(name string, prefix *gofakes3.Prefix, page gofakes3.ListBucketPage) (*gofakes3.ObjectList, error) {
+	list, err := b.Backend.ListBucket(name, prefix, page)
+	if err != nil {
+		return nil, err
+	}
+	for _, item := range list.Contents {
+		item.ETag = strings.Trim(item.ETag, `"`)
+	}
+	return list, nil
+}
@@ -0,0 +1,101 @@
+package gofakes3
+
+import (
+	"net/http"
+	"strconv"
+)
+
+const bucketIntelligentTieringConfig = "intelligent-tiering"
+
+// MaxIntelligentTieringConfigurations is the number of S3 Intelligent-Tiering
+// configurations a bucket may have, documented here:
+// https://docs.aws.amazon.com/AmazonS3/latest/API/API_PutBucketIntelligentTieringConfiguration.html
+const MaxIntelligentTieringConfigurations = 1000
+
+// The range of days after which each access tier may be used, documented here:
+// https://docs.aws.amazon.com/AmazonS3/latest/API/API_Tiering.html
+const (
+	MinArchiveAccessDays     = 90
+	MinDeepArchiveAccessDays = 180
+	MaxTieringDays           = 730
+)
+
+// validateIntelligentTiering checks the configuration, but GoFakeS3 never
+// moves any objects between tiers.
+func validateIntelligentTiering(config *IntelligentTieringConfiguration) error {
+	if !config.Status.Valid() || len(config.Tierings) == 0 {
+		return ErrMalformedXML
+	}
+
+	seen := make(map[IntelligentTieringAccessTier]bool, len(config.Tierings))
+	for _, tiering := range config.Tierings {
+		var min int
+		switch tiering.AccessTier {
+		case IntelligentTieringArchiveAccess:
+			min = MinArchiveAccessDays
+		case IntelligentTieringDeepArchiveAccess:
+			min = MinDeepArchiveAccessDays
+		default:
+			return ErrMalformedXML
+		}
+		if seen[tiering.AccessTier] {
+			return ErrorInvalidArgument("AccessTier", string(tiering.AccessTier), "Each access tier may only be configured once")
+		}
+		seen[tiering.AccessTier] = true
+
+		if tiering.Days < min || tiering.Days > MaxTieringDays {
+			return ErrorInvalidArgument("Days", strconv.Itoa(tiering.Days), "The number of days is out of range for the access tier")
+		}
+	}
+
+	return validateConfigurationFilter(config.Filter)
+}
+
+func (g *GoFakeS3) getBucketIntelligentTieringConfiguration(bucket string, w http.ResponseWriter, r *http.Request) error {
+	g.log.Print(LogInfo, "GET BUCKET INTELLIGENT TIERING CONFIGURATION:", bucket, r.URL.Query().Get("id"))
+
+	config, err := g.getIDConfig(bucket, bucketIntelligentTieringConfig, r)
+	if err != nil {
+		return err
+	}
+	out := *config.(*IntelligentTieringConfiguration)
+	out.Xmlns = "http://s3.amazonaws.com/doc/2006-03-01/"
+	return g.xmlEncoder(w).Encode(&out)
+}
+
+func (g *GoFakeS3) listBucketIntelligentTieringConfigurations(bucket string, w http.ResponseWriter, r *http.Request) error {
+	g.log.Print(LogInfo, "LIST BUCKET INTELLIGENT TIERING CONFIGURATIONS:", bucket)
+
+	configs, token, next, err := g.listIDConfigs(bucket, bucketIntelligentTieringConfig, r)
+	if err != nil {
+		return err
+	}
+
+	out := ListBucketIntelligentTieringConfigurationsResult{
+		Xmlns:                 "http://s3.amazonaws.com/doc/2006-03-01/",
+		IsTruncated:           next != "",
+		ContinuationToken:     token,
+		NextContinuationToken: next,
+	}
+	for _, config := range configs {
+		out.IntelligentTieringConfigurations = append(out.IntelligentTieringConfigurations, *config.(*IntelligentTieringConfiguration))
+	}
+	return g.xmlEncoder(w).Encode(&out)
+}
+
+func (g *GoFakeS3) putBucketIntelligentTieringConfiguration(bucket string, w http.ResponseWriter, r *http.Request) error {
+	g.log.Print(LogInfo, "PUT BUCKET INTELLIGENT TIERING CONFIGURATION:", bucket, r.URL.Query().Get("id"))
+
+	var in IntelligentTieringConfiguration
+	return g.putIDConfig(bucket, bucketIntelligentTieringConfig, MaxIntelligentTieringConfigurations, r, &in,
+		func() string { return in.ID },
+		func() error {
+			in.Xmlns = ""
+			return validateIntelligentTiering(&in)
+		})
+}
+
+func (g *GoFakeS3) deleteBucketIntelligentTieringConfiguration(bucket string, w http.ResponseWriter, r *http.Request) error {
+	g.log.Print(LogInfo, "DELETE BUCKET INTELLIGENT TIERING CONFIGURATION:", bucket, r.URL.Query().Get("id"))
+	return g.deleteIDConfig(bucket, bucketIntelligentTieringConfig, w, r)
+}
@@ -0,0 +1,93 @@
+package gofakes3_test
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/johannesboyne/gofakes3"
+)
+
+func TestBucketIntelligentTieringConfiguration(t *testing.T) {
+	ts := newTestServer(t)
+	defer ts.Close()
+
+	// The SDK in use predates Intelligent-Tiering configuration, so these
+	// requests are sent raw.
+	configURL := func(id string) string {
+		return fmt.Sprintf("%s?intelligent-tiering&id=%s", defaultBucket, url.QueryEscape(id))
+	}
+	config := func(id string, days int) []byte {
+		return []byte(fmt.Sprintf(`<IntelligentTieringConfiguration xmlns="http://s3.amazonaws.com/doc/2006-03-01/">`+
+			`<Id>%s</Id><Filter><Prefix>cold/</Prefix></Filter><Status>Enabled</Status>`+
+			`<Tiering><AccessTier>ARCHIVE_ACCESS</AccessTier><Days>%d</Days></Tiering>`+
+			`</IntelligentTieringConfiguration>`, id, days))
+	}
+
+	rs, body := ts.sendRaw("GET", configURL("missing"), nil, nil)
+	ts.assertRawErrorCode(rs, body, gofakes3.ErrNoSuchConfiguration)
+
+	for i := 0; i < 101; i++ {
+		id := fmt.Sprintf("tiering-%03d", i)
+		rs, body := ts.sendRaw("PUT", configURL(id), config(id, 90+i), nil)
+		if rs.StatusCode != http.StatusOK {
+			t.Fatal("put failed", rs.StatusCode, string(body))
+		}
+	}
+
+	rs, body = ts.sendRaw("GET", configURL("tiering-010"), nil, nil)
+	if rs.StatusCode != http.StatusOK {
+		t.Fatal("get failed", rs.StatusCode, string(body))
+	}
+	var got gofakes3.IntelligentTieringConfiguration
+	ts.OK(xml.Unmarshal(body, &got))
+	if got.ID != "tiering-010" || got.Status != gofakes3.IntelligentTieringEnabled ||
+		got.Filter == nil || got.Filter.Prefix != "cold/" ||
+		len(got.Tierings) != 1 || got.Tierings[0].AccessTier != gofakes3.IntelligentTieringArchiveAccess || got.Tierings[0].Days != 100 {
+		t.Fatal("configuration mismatch:", string(body))
+	}
+
+	t.Run("invalid-days", func(t *testing.T) {
+		rs, body := ts.sendRaw("PUT", configURL("short"), config("short", 30), nil)
+		ts.assertRawErrorCode(rs, body, gofakes3.ErrInvalidArgument)
+	})
+
+	t.Run("list-pages", func(t *testing.T) {
+		var ids []string
+		token := ""
+		for pages := 0; ; pages++ {
+			u := defaultBucket + "?intelligent-tiering"
+			if token != "" {
+				u += "&continuation-token=" + url.QueryEscape(token)
+			}
+			rs, body := ts.sendRaw("GET", u, nil, nil)
+			if rs.StatusCode != http.StatusOK {
+				t.Fatal("list failed", rs.StatusCode, string(body))
+			}
+			var page gofakes3.ListBucketIntelligentTieringConfigurationsResult
+			ts.OK(xml.Unmarshal(body, &page))
+			for _, config := range page.IntelligentTieringConfigurations {
+				ids = append(ids, config.ID)
+			}
+			if !page.IsTruncated {
+				if pages != 1 {
+					t.Fatal("expected 2 pages, found", pages+1)
+				}
+				break
+			}
+			token = page.NextContinuationToken
+		}
+		if len(ids) != 101 || ids[0] != "tiering-000" || ids[100] != "tiering-100" {
+			t.Fatal("unexpected ids", len(ids), ids[0], ids[len(ids)-1])
+		}
+	})
+
+	rs, body = ts.sendRaw("DELETE", configURL("tiering-010"), nil, nil)
+	if rs.StatusCode != http.StatusNoContent {
+		t.Fatal("delete failed", rs.StatusCode, string(body))
+	}
+	rs, body = ts.sendRaw("GET", configURL("tiering-010"), nil, nil)
+	ts.assertRawErrorCode(rs, body, gofakes3.ErrNoSuchConfiguration)
+}
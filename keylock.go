@@ -0,0 +1,87 @@
+package gofakes3
+
+import "sync"
+
+// keyLocks serialises writes to the same object key, so that a check of the
+// current object and the write that depends on it, like a conditional create,
+// happen as if they were atomic. It only covers writes made through GoFakeS3,
+// not those made to the Backend directly.
+//
+// The same keyLocks can also hold locks on multipart uploads; see lockUpload.
+type keyLocks struct {
+	mu    sync.Mutex
+	locks map[keyLockID]*keyLock
+}
+
+type keyLockID struct {
+	bucket string
+	object string
+	upload UploadID
+}
+
+type keyLock struct {
+	sync.RWMutex
+	refs int
+}
+
+func newKeyLocks() *keyLocks {
+	return &keyLocks{locks: map[keyLockID]*keyLock{}}
+}
+
+// lock blocks until no other write to the key is in progress, and returns the
+// function that ends this one.
+func (kl *keyLocks) lock(bucket, object string) (unlock func()) {
+	l, release := kl.acquire(keyLockID{bucket: bucket, object: object})
+	l.Lock()
+	return func() {
+		l.Unlock()
+		release()
+	}
+}
+
+// lockUpload lets the parts of a multipart upload be written concurrently,
+// but not while the upload is being completed, so that
+// CompleteMultipartUpload checks and assembles the parts as they were when it
+// began, and a part cannot be written to an upload once it has been
+// completed.
+//
+// lockUpload blocks until the upload is not being completed, or if complete
+// is set, until no part of it is being written either, and returns the
+// function that releases the lock.
+func (kl *keyLocks) lockUpload(bucket, object string, id UploadID, complete bool) (unlock func()) {
+	l, release := kl.acquire(keyLockID{bucket: bucket, object: object, upload: id})
+	if complete {
+		l.Lock()
+	} else {
+		l.RLock()
+	}
+	return func() {
+		if complete {
+			l.Unlock()
+		} else {
+			l.RUnlock()
+		}
+		release()
+	}
+}
+
+// acquire returns the lock for id, creating it if nobody else holds it, and
+// the function that drops the reference to it once it has been unlocked.
+func (kl *keyLocks) acquire(id keyLockID) (l *keyLock, release func()) {
+	kl.mu.Lock()
+	l = kl.locks[id]
+	if l == nil {
+		l = &keyLock{}
+		kl.locks[id] = l
+	}
+	l.refs++
+	kl.mu.Unlock()
+
+	return l, func() {
+		kl.mu.Lock()
+		defer kl.mu.Unlock()
+		if l.refs--; l.refs == 0 {
+			delete(kl.locks, id)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package gofakes3
+
+import (
+	"context"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// AnyOperation may be passed to WithOperationLatency in place of an operation
+// name, to delay requests for operations that have no latency of their own.
+const AnyOperation = "*"
+
+// LatencyFunc returns how long to delay a request by. rnd may be used to vary
+// the delay; its sequence is reproducible if WithLatencySeed is passed. rnd
+// must not be used outside of the call.
+type LatencyFunc func(rnd *rand.Rand) time.Duration
+
+// UniformLatency returns a LatencyFunc that picks a delay between min and max
+// inclusive, with every value equally likely.
+func UniformLatency(min, max time.Duration) LatencyFunc {
+	if max < min {
+		min, max = max, min
+	}
+	return func(rnd *rand.Rand) time.Duration {
+		return min + time.Duration(rnd.Int63n(int64(max-min)+1))
+	}
+}
+
+// TimeSourceSleeper is implemented by a TimeSource that can also wait. If the
+// configured TimeSource implements it, it is used for every simulated delay,
+// such as those added with WithOperationLatency, WithNotFoundDelay,
+// WithStalledBodies and WithFirstByteDelay; otherwise the real clock is used.
+// Either way, a delayed request whose client goes away is dropped.
+//
+// The TimeSource returned by FixedTimeSource implements it by advancing its
+// time without waiting, so that tests using simulated delays complete
+// immediately.
+type TimeSourceSleeper interface {
+	TimeSource
+
+	// Sleep waits for d to pass, or returns ctx.Err() if ctx is done first.
+	Sleep(ctx context.Context, d time.Duration) error
+}
+
+// sleep waits for d to pass, using the TimeSource if it is a
+// TimeSourceSleeper, or returns ctx.Err() if ctx is done first.
+func (g *GoFakeS3) sleep(ctx context.Context, d time.Duration) error {
+	if sleeper, ok := g.timeSource.(TimeSourceSleeper); ok {
+		return sleeper.Sleep(ctx, d)
+	}
+	return sleepContext(ctx, d)
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// latencies holds the LatencyFuncs added with WithOperationLatency, and the
+// random source they share.
+type latencies struct {
+	funcs map[string]LatencyFunc
+
+	mu  sync.Mutex
+	rnd *rand.Rand
+}
+
+func (l *latencies) delay(operation string) time.Duration {
+	latency, ok := l.funcs[operation]
+	if !ok {
+		if latency, ok = l.funcs[AnyOperation]; !ok {
+			return 0
+		}
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return latency(l.rnd)
+}
+
+// simulateLatency delays a request by the latency configured for its
+// operation. It returns an error if the client goes away first, in which
+// case there is nobody left to respond to.
+func (g *GoFakeS3) simulateLatency(bucket, object string, query url.Values, r *http.Request) error {
+	if len(g.latencies.funcs) == 0 {
+		return nil
+	}
+	operation := requestOperation(bucket, object, query, r)
+	d := g.latencies.delay(operation)
+	if d <= 0 {
+		return nil
+	}
+
+	g.log.Print(LogInfo, "DELAYING:", operation, d)
+	return g.sleep(r.Context(), d)
+}
@@ -0,0 +1,331 @@
+package gofakes3
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const bucketLifecycleConfig = "lifecycle"
+
+// Limits on lifecycle configuration, documented here:
+// https://docs.aws.amazon.com/AmazonS3/latest/userguide/intro-lifecycle-rules.html
+const (
+	MaxLifecycleRules        = 1000
+	MaxLifecycleRuleIDLength = 255
+)
+
+// bucketLifecycle returns the bucket's LifecycleConfiguration, or nil if it
+// has none.
+func (g *GoFakeS3) bucketLifecycle(bucket string) *LifecycleConfiguration {
+	config, ok := g.configs.get(resourceConfigKey{bucket: bucket, kind: bucketLifecycleConfig})
+	if !ok {
+		return nil
+	}
+	return config.(*LifecycleConfiguration)
+}
+
+func validateLifecycle(config *LifecycleConfiguration) error {
+	if len(config.Rules) == 0 {
+		return ErrMalformedXML
+	}
+	if len(config.Rules) > MaxLifecycleRules {
+		return ErrorMessage(ErrInvalidArgument, "Number of lifecycle rules should not exceed allowed limit of 1000 rules")
+	}
+
+	seen := make(map[string]bool, len(config.Rules))
+	for _, rule := range config.Rules {
+		if !rule.Status.Valid() {
+			return ErrMalformedXML
+		}
+		if len(rule.ID) > MaxLifecycleRuleIDLength {
+			return ErrorInvalidArgument("ID", rule.ID, "ID length should not exceed allowed limit of 255")
+		}
+		if rule.ID != "" && seen[rule.ID] {
+			return ErrorInvalidArgument("ID", rule.ID, "Rule ID must be unique. Found same ID for more than one rule")
+		}
+		seen[rule.ID] = true
+
+		if rule.Filter != nil {
+			if rule.Prefix != "" {
+				return ErrMalformedXML
+			}
+			if err := validateLifecycleFilter(rule.Filter); err != nil {
+				return err
+			}
+		}
+
+		if rule.Expiration == nil && len(rule.Transitions) == 0 &&
+			rule.NoncurrentVersionExpiration == nil && len(rule.NoncurrentVersionTransitions) == 0 &&
+			rule.AbortIncompleteMultipartUpload == nil {
+			return ErrorMessage(ErrInvalidArgument, "At least one action needs to be specified in a rule")
+		}
+
+		if exp := rule.Expiration; exp != nil {
+			set := 0
+			if !exp.Date.IsZero() {
+				set++
+				if !exp.Date.Equal(exp.Date.UTC().Truncate(24 * time.Hour)) {
+					return ErrorInvalidArgument("Date", exp.Date.String(), "'Date' must be at midnight GMT")
+				}
+			}
+			if exp.Days != 0 {
+				set++
+				if exp.Days < 0 {
+					return ErrorInvalidArgument("Days", fmt.Sprint(exp.Days), "'Days' for Expiration action must be a positive integer")
+				}
+			}
+			if exp.ExpiredObjectDeleteMarker {
+				set++
+			}
+			if set != 1 {
+				return ErrMalformedXML
+			}
+		}
+	}
+	return nil
+}
+
+// validateLifecycleFilter checks that a LifecycleRuleFilter uses at most one
+// condition, as several must be combined with And.
+func validateLifecycleFilter(filter *LifecycleRuleFilter) error {
+	set := 0
+	for _, ok := range []bool{
+		filter.Prefix != "",
+		filter.Tag != nil,
+		filter.ObjectSizeGreaterThan != 0,
+		filter.ObjectSizeLessThan != 0,
+		filter.And != nil,
+	} {
+		if ok {
+			set++
+		}
+	}
+	if set > 1 {
+		return ErrMalformedXML
+	}
+	return nil
+}
+
+// lifecycleRuleMatches reports whether the rule applies to the object. The
+// tags are only fetched if the rule filters on them.
+func lifecycleRuleMatches(rule *LifecycleRule, obj *Object, tags func() (*Tagging, error)) (bool, error) {
+	prefix := rule.Prefix
+	var want []Tag
+	var greaterThan, lessThan int64
+
+	if filter := rule.Filter; filter != nil {
+		prefix = filter.Prefix
+		if filter.Tag != nil {
+			want = append(want, *filter.Tag)
+		}
+		greaterThan, lessThan = filter.ObjectSizeGreaterThan, filter.ObjectSizeLessThan
+		if and := filter.And; and != nil {
+			prefix = and.Prefix
+			want = append(want, and.Tags...)
+			greaterThan, lessThan = and.ObjectSizeGreaterThan, and.ObjectSizeLessThan
+		}
+	}
+
+	if !strings.HasPrefix(obj.Name, prefix) {
+		return false, nil
+	}
+	if greaterThan != 0 && obj.Size <= greaterThan {
+		return false, nil
+	}
+	if lessThan != 0 && obj.Size >= lessThan {
+		return false, nil
+	}
+	if len(want) == 0 {
+		return true, nil
+	}
+
+	tagging, err := tags()
+	if err != nil {
+		return false, err
+	}
+	have := make(map[string]string, len(tagging.TagSet))
+	for _, tag := range tagging.TagSet {
+		have[tag.Key] = tag.Value
+	}
+	for _, tag := range want {
+		if v, ok := have[tag.Key]; !ok || v != tag.Value {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// lifecycleExpiryDate returns the time at which an Expiration action expires
+// an object created at the given time. Like S3, expiry after a number of Days
+// is rounded up to the following midnight UTC.
+func lifecycleExpiryDate(exp *LifecycleExpiration, created time.Time) (expires time.Time, ok bool) {
+	if !exp.Date.IsZero() {
+		return exp.Date.UTC(), true
+	}
+	if exp.Days <= 0 || created.IsZero() {
+		return time.Time{}, false
+	}
+	expires = created.UTC().AddDate(0, 0, exp.Days)
+	if midnight := expires.Truncate(24 * time.Hour); midnight.Before(expires) {
+		expires = midnight.Add(24 * time.Hour)
+	}
+	return expires, true
+}
+
+// lifecycleExpiration returns the earliest time at which one of the bucket's
+// enabled lifecycle rules expires the object, and the id of that rule. ok is
+// false if no rule expires the object.
+func (g *GoFakeS3) lifecycleExpiration(bucket string, obj *Object) (expires time.Time, ruleID string, ok bool, err error) {
+	config := g.bucketLifecycle(bucket)
+	if config == nil {
+		return expires, "", false, nil
+	}
+
+	var created time.Time
+	if modified := obj.Metadata["Last-Modified"]; modified != "" {
+		created, _ = http.ParseTime(modified)
+	}
+
+	var tagging *Tagging
+	var tagsErr error
+	tags := func() (*Tagging, error) {
+		if tagging == nil && tagsErr == nil {
+			tagging, tagsErr = g.objectTagging(bucket, obj.Name, obj)
+		}
+		return tagging, tagsErr
+	}
+
+	for i := range config.Rules {
+		rule := &config.Rules[i]
+		if rule.Status != LifecycleRuleEnabled || rule.Expiration == nil {
+			continue
+		}
+		at, found := lifecycleExpiryDate(rule.Expiration, created)
+		if !found || (ok && !at.Before(expires)) {
+			continue
+		}
+		matches, err := lifecycleRuleMatches(rule, obj, tags)
+		if err != nil {
+			return expires, "", false, err
+		}
+		if matches {
+			expires, ruleID, ok = at, rule.ID, true
+		}
+	}
+	return expires, ruleID, ok, nil
+}
+
+// writeExpirationHeader sets the 'x-amz-expiration' header if the bucket's
+// lifecycle configuration will expire the object.
+func (g *GoFakeS3) writeExpirationHeader(bucket string, obj *Object, w http.ResponseWriter) error {
+	expires, ruleID, ok, err := g.lifecycleExpiration(bucket, obj)
+	if err != nil || !ok {
+		return err
+	}
+	w.Header().Set("x-amz-expiration", fmt.Sprintf(`expiry-date="%s", rule-id="%s"`, formatHeaderTime(expires), ruleID))
+	return nil
+}
+
+func (g *GoFakeS3) getBucketLifecycle(bucket string, w http.ResponseWriter, r *http.Request) error {
+	g.log.Print(LogInfo, "GET BUCKET LIFECYCLE:", bucket)
+
+	if err := g.ensureBucketExists(bucket); err != nil {
+		return err
+	}
+
+	config := g.bucketLifecycle(bucket)
+	if config == nil {
+		return ResourceError(ErrNoSuchLifecycleConfiguration, bucket)
+	}
+	out := *config
+	out.Xmlns = "http://s3.amazonaws.com/doc/2006-03-01/"
+	return g.xmlEncoder(w).Encode(&out)
+}
+
+func (g *GoFakeS3) putBucketLifecycle(bucket string, w http.ResponseWriter, r *http.Request) error {
+	g.log.Print(LogInfo, "PUT BUCKET LIFECYCLE:", bucket)
+
+	if err := g.ensureBucketExists(bucket); err != nil {
+		return err
+	}
+
+	var in LifecycleConfiguration
+	if err := g.xmlDecodeBody(r.Body, &in); err != nil {
+		return err
+	}
+	if err := validateLifecycle(&in); err != nil {
+		return err
+	}
+
+	g.configs.put(resourceConfigKey{bucket: bucket, kind: bucketLifecycleConfig}, &in)
+	return nil
+}
+
+func (g *GoFakeS3) deleteBucketLifecycle(bucket string, w http.ResponseWriter, r *http.Request) error {
+	g.log.Print(LogInfo, "DELETE BUCKET LIFECYCLE:", bucket)
+
+	if err := g.ensureBucketExists(bucket); err != nil {
+		return err
+	}
+
+	g.configs.delete(resourceConfigKey{bucket: bucket, kind: bucketLifecycleConfig})
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// SweepLifecycleExpirations deletes every object that an enabled Expiration
+// action in its bucket's lifecycle configuration has expired, according to
+// the configured TimeSource. It is called periodically if
+// WithLifecycleSweepInterval is used, but can also be called directly to make
+// tests deterministic.
+//
+// GoFakeS3 does not support event notifications, so unlike S3, no
+// 's3:ObjectRemoved:Expiration' event is sent for the deleted objects.
+func (g *GoFakeS3) SweepLifecycleExpirations() error {
+	buckets, err := g.storage.ListBuckets()
+	if err != nil {
+		return err
+	}
+
+	for _, bucket := range buckets {
+		if g.bucketLifecycle(bucket.Name) == nil {
+			continue
+		}
+
+		objects, err := g.storage.ListBucket(bucket.Name, nil, ListBucketPage{})
+		if err != nil {
+			return err
+		}
+
+		for _, item := range objects.Contents {
+			obj, err := g.storage.HeadObject(bucket.Name, item.Key)
+			if HasErrorCode(err, ErrNoSuchKey) {
+				continue
+			} else if err != nil {
+				return err
+			}
+			obj.Contents.Close()
+
+			expires, ruleID, ok, err := g.lifecycleExpiration(bucket.Name, obj)
+			if err != nil {
+				return err
+			}
+			if !ok || g.timeSource.Now().Before(expires) {
+				continue
+			}
+
+			g.log.Print(LogInfo, "LIFECYCLE EXPIRED:", bucket.Name, item.Key, ruleID)
+			result, err := g.storage.DeleteObject(bucket.Name, item.Key)
+			if err != nil {
+				return err
+			}
+			if result.VersionID == "" {
+				g.configs.deleteObject(bucket.Name, item.Key, "")
+			}
+		}
+	}
+
+	return nil
+}
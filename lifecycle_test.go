@@ -0,0 +1,213 @@
+package gofakes3_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/johannesboyne/gofakes3"
+)
+
+func TestBucketLifecycleConfiguration(t *testing.T) {
+	ts := newTestServer(t)
+	defer ts.Close()
+	svc := ts.s3Client()
+
+	_, err := svc.GetBucketLifecycleConfiguration(&s3.GetBucketLifecycleConfigurationInput{
+		Bucket: aws.String(defaultBucket),
+	})
+	if !hasErrorCode(err, gofakes3.ErrNoSuchLifecycleConfiguration) {
+		t.Fatal("expected ErrNoSuchLifecycleConfiguration, found", err)
+	}
+
+	ts.OKAll(svc.PutBucketLifecycleConfiguration(&s3.PutBucketLifecycleConfigurationInput{
+		Bucket: aws.String(defaultBucket),
+		LifecycleConfiguration: &s3.BucketLifecycleConfiguration{
+			Rules: []*s3.LifecycleRule{
+				{
+					ID:         aws.String("logs"),
+					Status:     aws.String("Enabled"),
+					Filter:     &s3.LifecycleRuleFilter{Prefix: aws.String("logs/")},
+					Expiration: &s3.LifecycleExpiration{Days: aws.Int64(3)},
+				},
+			},
+		},
+	}))
+
+	out, err := svc.GetBucketLifecycleConfiguration(&s3.GetBucketLifecycleConfigurationInput{
+		Bucket: aws.String(defaultBucket),
+	})
+	ts.OK(err)
+	if len(out.Rules) != 1 || aws.StringValue(out.Rules[0].ID) != "logs" || aws.Int64Value(out.Rules[0].Expiration.Days) != 3 {
+		t.Fatal("unexpected configuration", out)
+	}
+
+	_, err = svc.PutBucketLifecycleConfiguration(&s3.PutBucketLifecycleConfigurationInput{
+		Bucket: aws.String(defaultBucket),
+		LifecycleConfiguration: &s3.BucketLifecycleConfiguration{
+			Rules: []*s3.LifecycleRule{
+				{
+					ID:         aws.String("bad"),
+					Status:     aws.String("Enabled"),
+					Filter:     &s3.LifecycleRuleFilter{Prefix: aws.String("")},
+					Expiration: &s3.LifecycleExpiration{Date: aws.Time(defaultDate)},
+				},
+			},
+		},
+	})
+	if !hasErrorCode(err, gofakes3.ErrInvalidArgument) {
+		t.Fatal("expected ErrInvalidArgument for a date that is not midnight, found", err)
+	}
+
+	ts.OKAll(svc.DeleteBucketLifecycle(&s3.DeleteBucketLifecycleInput{
+		Bucket: aws.String(defaultBucket),
+	}))
+	_, err = svc.GetBucketLifecycleConfiguration(&s3.GetBucketLifecycleConfigurationInput{
+		Bucket: aws.String(defaultBucket),
+	})
+	if !hasErrorCode(err, gofakes3.ErrNoSuchLifecycleConfiguration) {
+		t.Fatal("expected ErrNoSuchLifecycleConfiguration, found", err)
+	}
+}
+
+func TestLifecycleExpirationHeader(t *testing.T) {
+	ts := newTestServer(t)
+	defer ts.Close()
+	svc := ts.s3Client()
+
+	midnight := time.Date(2018, 6, 1, 0, 0, 0, 0, time.UTC)
+	ts.OKAll(svc.PutBucketLifecycleConfiguration(&s3.PutBucketLifecycleConfigurationInput{
+		Bucket: aws.String(defaultBucket),
+		LifecycleConfiguration: &s3.BucketLifecycleConfiguration{
+			Rules: []*s3.LifecycleRule{
+				{
+					ID:         aws.String("logs"),
+					Status:     aws.String("Enabled"),
+					Filter:     &s3.LifecycleRuleFilter{Prefix: aws.String("logs/")},
+					Expiration: &s3.LifecycleExpiration{Days: aws.Int64(3)},
+				},
+				{
+					ID:     aws.String("tagged"),
+					Status: aws.String("Enabled"),
+					Filter: &s3.LifecycleRuleFilter{
+						Tag: &s3.Tag{Key: aws.String("temp"), Value: aws.String("yes")},
+					},
+					Expiration: &s3.LifecycleExpiration{Date: aws.Time(midnight)},
+				},
+				{
+					ID:         aws.String("disabled"),
+					Status:     aws.String("Disabled"),
+					Filter:     &s3.LifecycleRuleFilter{Prefix: aws.String("")},
+					Expiration: &s3.LifecycleExpiration{Days: aws.Int64(1)},
+				},
+			},
+		},
+	}))
+
+	put := func(key, tagging string) {
+		t.Helper()
+		in := &s3.PutObjectInput{
+			Bucket: aws.String(defaultBucket),
+			Key:    aws.String(key),
+			Body:   strings.NewReader("hello"),
+		}
+		if tagging != "" {
+			in.Tagging = aws.String(tagging)
+		}
+		ts.OKAll(svc.PutObject(in))
+	}
+	put("logs/today", "")
+	put("tagged", "temp=yes")
+	put("kept", "temp=no")
+
+	for _, tc := range []struct {
+		key    string
+		expect string
+	}{
+		// Created at 2018-01-01 12:00, plus 3 days, rounded up to midnight:
+		{"logs/today", `expiry-date="Fri, 05 Jan 2018 00:00:00 GMT", rule-id="logs"`},
+		{"tagged", `expiry-date="Fri, 01 Jun 2018 00:00:00 GMT", rule-id="tagged"`},
+		{"kept", ""},
+	} {
+		t.Run(tc.key, func(t *testing.T) {
+			head, err := svc.HeadObject(&s3.HeadObjectInput{
+				Bucket: aws.String(defaultBucket),
+				Key:    aws.String(tc.key),
+			})
+			ts.OK(err)
+			if aws.StringValue(head.Expiration) != tc.expect {
+				t.Fatalf("unexpected HEAD expiration %q, expected %q", aws.StringValue(head.Expiration), tc.expect)
+			}
+
+			get, err := svc.GetObject(&s3.GetObjectInput{
+				Bucket: aws.String(defaultBucket),
+				Key:    aws.String(tc.key),
+			})
+			ts.OK(err)
+			get.Body.Close()
+			if aws.StringValue(get.Expiration) != tc.expect {
+				t.Fatalf("unexpected GET expiration %q, expected %q", aws.StringValue(get.Expiration), tc.expect)
+			}
+		})
+	}
+}
+
+func TestSweepLifecycleExpirations(t *testing.T) {
+	ts := newTestServer(t)
+	defer ts.Close()
+	svc := ts.s3Client()
+
+	ts.OKAll(svc.PutBucketLifecycleConfiguration(&s3.PutBucketLifecycleConfigurationInput{
+		Bucket: aws.String(defaultBucket),
+		LifecycleConfiguration: &s3.BucketLifecycleConfiguration{
+			Rules: []*s3.LifecycleRule{
+				{
+					ID:         aws.String("logs"),
+					Status:     aws.String("Enabled"),
+					Filter:     &s3.LifecycleRuleFilter{Prefix: aws.String("logs/")},
+					Expiration: &s3.LifecycleExpiration{Days: aws.Int64(1)},
+				},
+				{
+					ID:     aws.String("tagged"),
+					Status: aws.String("Enabled"),
+					Filter: &s3.LifecycleRuleFilter{
+						Tag: &s3.Tag{Key: aws.String("temp"), Value: aws.String("yes")},
+					},
+					Expiration: &s3.LifecycleExpiration{Days: aws.Int64(3)},
+				},
+			},
+		},
+	}))
+
+	for key, tagging := range map[string]string{
+		"logs/old": "",
+		"tagged":   "temp=yes",
+		"kept":     "temp=no",
+	} {
+		in := &s3.PutObjectInput{
+			Bucket: aws.String(defaultBucket),
+			Key:    aws.String(key),
+			Body:   strings.NewReader("hello"),
+		}
+		if tagging != "" {
+			in.Tagging = aws.String(tagging)
+		}
+		ts.OKAll(svc.PutObject(in))
+	}
+
+	// Nothing has expired yet:
+	ts.OK(ts.SweepLifecycleExpirations())
+	ts.assertLs(defaultBucket, "", []string{"logs/"}, []string{"kept", "tagged"})
+
+	// Created at 2018-01-01 12:00, so 'logs' expires them at midnight on the
+	// 3rd and 'tagged' at midnight on the 5th:
+	ts.Advance(48 * time.Hour)
+	ts.OK(ts.SweepLifecycleExpirations())
+	ts.assertLs(defaultBucket, "", nil, []string{"kept", "tagged"})
+
+	ts.Advance(48 * time.Hour)
+	ts.OK(ts.SweepLifecycleExpirations())
+	ts.assertLs(defaultBucket, "", nil, []string{"kept"})
+}
@@ -0,0 +1,46 @@
+package gofakes3
+
+import (
+	"net/url"
+	"strings"
+)
+
+// EncodingTypeURL is the only value S3 accepts for the 'encoding-type'
+// parameter of the list operations. Keys may contain characters that XML 1.0
+// cannot represent, so clients can ask for every key in the response,
+// including CommonPrefixes, markers and the echoed prefix and delimiter, to
+// be URL encoded.
+const EncodingTypeURL = "url"
+
+// listEncodingFromQuery returns the 'encoding-type' the request asked for,
+// and the function that applies it to a key. Without one, keys are left as
+// they are.
+func listEncodingFromQuery(q url.Values) (encodingType string, encode func(string) string, err error) {
+	switch encodingType = q.Get("encoding-type"); encodingType {
+	case "":
+		return "", func(s string) string { return s }, nil
+	case EncodingTypeURL:
+		return encodingType, urlEncodeKey, nil
+	default:
+		return "", nil, ErrorInvalidArgument("encoding-type", encodingType, "Invalid Encoding Method specified in Request")
+	}
+}
+
+// urlEncodeKey encodes a key like S3 does, with '+' for spaces but with the
+// '/' separators left alone.
+func urlEncodeKey(key string) string {
+	return strings.Replace(url.QueryEscape(key), "%2F", "/", -1)
+}
+
+// encodeCommonPrefixes returns a copy of prefixes with encode applied, so
+// that a slice returned by a Backend is not modified.
+func encodeCommonPrefixes(prefixes []CommonPrefix, encode func(string) string) []CommonPrefix {
+	if prefixes == nil {
+		return nil
+	}
+	out := make([]CommonPrefix, len(prefixes))
+	for i, prefix := range prefixes {
+		out[i] = CommonPrefix{Prefix: encode(prefix.Prefix)}
+	}
+	return out
+}
@@ -0,0 +1,18 @@
+package gofakes3
+
+// omitRecentWrites implements WithListingLag, removing the objects written
+// less than the lag ago from a listing returned by the Backend.
+func (g *GoFakeS3) omitRecentWrites(contents []*Content) []*Content {
+	if g.listingLag <= 0 {
+		return contents
+	}
+
+	now := g.timeSource.Now()
+	kept := make([]*Content, 0, len(contents))
+	for _, item := range contents {
+		if now.Sub(item.LastModified.Time) >= g.listingLag {
+			kept = append(kept, item)
+		}
+	}
+	return kept
+}
@@ -0,0 +1,118 @@
+package gofakes3
+
+import (
+	"io"
+	"sort"
+	"strings"
+)
+
+// listBucketIter pages a listing for an IteratingBackend whose ListBucket
+// does not support paging. It yields the same results as a Backend that does,
+// but only reads as much of the bucket as it needs to fill the page, plus one
+// more entry to tell whether the listing is truncated.
+func listBucketIter(ib IteratingBackend, bucket string, prefix *Prefix, page ListBucketPage) (result *ObjectList, err error) {
+	var scanPrefix string
+	if prefix.HasPrefix {
+		scanPrefix = prefix.Prefix
+	}
+
+	iter, err := ib.ListObjectsIter(bucket, scanPrefix, page.Marker)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if cerr := iter.Close(); err == nil {
+			err = cerr
+		}
+	}()
+
+	result = NewObjectList()
+
+	// If the previous page ended on a common prefix, the Marker is that
+	// prefix, and the keys it rolled up must not be listed again:
+	lastPrefix := page.Marker
+
+	var match PrefixMatch
+	var last string
+	var cnt int64
+	for {
+		item, err := iter.Next()
+		if err == io.EOF {
+			return result, nil
+		} else if err != nil {
+			return nil, err
+		}
+
+		if !prefix.Match(item.Key, &match) {
+			continue
+		} else if match.CommonPrefix && match.MatchedPart == lastPrefix {
+			continue // Should not count towards keys
+		}
+
+		if page.MaxKeys > 0 && cnt >= page.MaxKeys {
+			// The next page resumes after the last entry in this one, which
+			// is the common prefix itself if the page ended on one:
+			result.IsTruncated = true
+			result.NextMarker = last
+			return result, nil
+		}
+
+		if match.CommonPrefix {
+			result.AddPrefix(match.MatchedPart)
+			lastPrefix = match.MatchedPart
+			last = match.MatchedPart
+		} else {
+			result.Add(item)
+			last = item.Key
+		}
+		cnt++
+	}
+}
+
+// listBucketAfterMarker answers a paged listing for a Backend whose
+// ListBucket supports neither paging nor IteratingBackend. The whole listing
+// is returned, as the page size is ignored, but like S3 it starts strictly
+// after the marker. As the keys a common prefix rolls up may lie on either
+// side of the marker, the keys are listed without the delimiter, and rolled
+// up as listBucketIter would.
+func listBucketAfterMarker(backend Backend, bucket string, prefix *Prefix, marker string) (*ObjectList, error) {
+	if marker == "" {
+		return backend.ListBucket(bucket, prefix, ListBucketPage{})
+	}
+
+	flat := Prefix{HasPrefix: prefix.HasPrefix, Prefix: prefix.Prefix}
+	objects, err := backend.ListBucket(bucket, &flat, ListBucketPage{})
+	if err != nil {
+		return nil, err
+	}
+	return listBucketIter(listedObjects(objects.Contents), bucket, prefix, ListBucketPage{Marker: marker})
+}
+
+// listedObjects is an IteratingBackend over the objects in a listing.
+type listedObjects []*Content
+
+func (l listedObjects) ListObjectsIter(bucketName, prefix, after string) (ObjectIterator, error) {
+	sorted := make([]*Content, 0, len(l))
+	for _, item := range l {
+		if item.Key > after && strings.HasPrefix(item.Key, prefix) {
+			sorted = append(sorted, item)
+		}
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Key < sorted[j].Key })
+	return &listedObjectsIterator{objects: sorted}, nil
+}
+
+type listedObjectsIterator struct {
+	objects []*Content
+}
+
+func (iter *listedObjectsIterator) Next() (*Content, error) {
+	if len(iter.objects) == 0 {
+		return nil, io.EOF
+	}
+	item := iter.objects[0]
+	iter.objects = iter.objects[1:]
+	return item, nil
+}
+
+func (iter *listedObjectsIterator) Close() error { return nil }
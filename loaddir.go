@@ -0,0 +1,61 @@
+package gofakes3
+
+import (
+	"fmt"
+	"mime"
+	"os"
+	"path"
+	"path/filepath"
+)
+
+// LoadDir stores every file under dir as an object in bucket, creating the
+// bucket if it does not exist, so that a directory of fixtures can be served
+// straight away. Each object's key is the file's path relative to dir, with
+// nested directories separated by slashes, and its Content-Type is chosen by
+// the file's extension, or is DefaultContentType if the extension is not
+// known. Objects are stored with the Backend's PutObject, so they get the
+// same ETags as if they had been uploaded.
+//
+// Only regular files are loaded: symbolic links and other special files are
+// skipped, as are empty directories, which S3 has no way to represent.
+func LoadDir(backend Backend, bucket, dir string) error {
+	exists, err := backend.BucketExists(bucket)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		if err := backend.CreateBucket(bucket); err != nil {
+			return err
+		}
+	}
+
+	return filepath.Walk(dir, func(file string, info os.FileInfo, err error) error {
+		if err != nil || !info.Mode().IsRegular() {
+			return err
+		}
+		rel, err := filepath.Rel(dir, file)
+		if err != nil {
+			return err
+		}
+		key := filepath.ToSlash(rel)
+
+		contentType := mime.TypeByExtension(path.Ext(key))
+		if contentType == "" {
+			contentType = DefaultContentType
+		}
+		meta := map[string]string{
+			"Content-Type":  contentType,
+			"Last-Modified": formatHeaderTime(info.ModTime()),
+		}
+
+		f, err := os.Open(file)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		if _, err := backend.PutObject(bucket, key, meta, f, info.Size()); err != nil {
+			return fmt.Errorf("gofakes3: loading %q: %w", file, err)
+		}
+		return nil
+	})
+}
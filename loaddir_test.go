@@ -0,0 +1,55 @@
+package gofakes3_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/johannesboyne/gofakes3"
+)
+
+func TestLoadDir(t *testing.T) {
+	ts := newTestServer(t)
+	defer ts.Close()
+	svc := ts.s3Client()
+
+	dir := t.TempDir()
+	files := map[string]string{
+		"index.html":       "<html></html>",
+		"css/site.css":     "body {}",
+		"data/nested/blob": "\x00\x01",
+	}
+	for name, contents := range files {
+		file := filepath.Join(dir, filepath.FromSlash(name))
+		ts.OK(os.MkdirAll(filepath.Dir(file), 0700))
+		ts.OK(ioutil.WriteFile(file, []byte(contents), 0600))
+	}
+	ts.OK(os.Mkdir(filepath.Join(dir, "empty"), 0700))
+
+	ts.OK(gofakes3.LoadDir(ts.backend, "fixtures", dir))
+
+	list, err := svc.ListObjectsV2(&s3.ListObjectsV2Input{Bucket: aws.String("fixtures")})
+	ts.OK(err)
+	if len(list.Contents) != len(files) {
+		t.Fatal("expected", len(files), "objects, found", list.Contents)
+	}
+
+	for name, contentType := range map[string]string{
+		"index.html":       "text/html; charset=utf-8",
+		"css/site.css":     "text/css; charset=utf-8",
+		"data/nested/blob": gofakes3.DefaultContentType,
+	} {
+		head, err := svc.HeadObject(&s3.HeadObjectInput{Bucket: aws.String("fixtures"), Key: aws.String(name)})
+		ts.OK(err)
+		if v := aws.StringValue(head.ContentType); v != contentType {
+			t.Fatal("unexpected Content-Type for", name, v)
+		}
+		if v, expected := aws.StringValue(head.ETag), `"`+hashMD5Bytes([]byte(files[name])).Hex()+`"`; v != expected {
+			t.Fatal("unexpected ETag for", name, v, "expected", expected)
+		}
+		ts.assertObject("fixtures", name, nil, files[name])
+	}
+}
@@ -13,6 +13,11 @@ type Storage struct {
 	Xmlns   string    `xml:"xmlns,attr"`
 	Owner   *UserInfo `xml:"Owner,omitempty"`
 	Buckets Buckets   `xml:"Buckets>Bucket"`
+
+	// ContinuationToken is returned if the listing was paged with
+	// 'max-buckets' and there are more buckets to list.
+	ContinuationToken string `xml:"ContinuationToken,omitempty"`
+	Prefix            string `xml:"Prefix,omitempty"`
 }
 
 type UserInfo struct {
@@ -20,6 +25,89 @@ type UserInfo struct {
 	DisplayName string `xml:"DisplayName"`
 }
 
+// AccessControlPolicy is the body of the PutObjectAcl request and the
+// GetObjectAcl response.
+type AccessControlPolicy struct {
+	XMLName xml.Name `xml:"AccessControlPolicy"`
+	Xmlns   string   `xml:"xmlns,attr,omitempty"`
+
+	Owner             *UserInfo         `xml:"Owner,omitempty"`
+	AccessControlList AccessControlList `xml:"AccessControlList"`
+}
+
+type AccessControlList struct {
+	Grants []Grant `xml:"Grant"`
+}
+
+type Grant struct {
+	Grantee    *Grantee   `xml:"Grantee"`
+	Permission Permission `xml:"Permission"`
+}
+
+// Permission is used by Grant.
+type Permission string
+
+const (
+	PermissionFullControl Permission = "FULL_CONTROL"
+	PermissionRead        Permission = "READ"
+	PermissionReadACP     Permission = "READ_ACP"
+	PermissionWrite       Permission = "WRITE"
+	PermissionWriteACP    Permission = "WRITE_ACP"
+)
+
+func (p Permission) Valid() bool {
+	switch p {
+	case PermissionFullControl, PermissionRead, PermissionReadACP, PermissionWrite, PermissionWriteACP:
+		return true
+	}
+	return false
+}
+
+// GranteeType is used by Grantee.
+type GranteeType string
+
+const (
+	GranteeCanonicalUser         GranteeType = "CanonicalUser"
+	GranteeAmazonCustomerByEmail GranteeType = "AmazonCustomerByEmail"
+	GranteeGroup                 GranteeType = "Group"
+)
+
+const xmlSchemaInstanceNS = "http://www.w3.org/2001/XMLSchema-instance"
+
+// Grantee identifies who a Grant applies to. The type is carried in an
+// 'xsi:type' attribute, which encoding/xml can't express with struct tags,
+// so Grantee has custom XML marshalling.
+type Grantee struct {
+	Type         GranteeType `xml:"-"`
+	ID           string      `xml:"ID,omitempty"`
+	DisplayName  string      `xml:"DisplayName,omitempty"`
+	EmailAddress string      `xml:"EmailAddress,omitempty"`
+	URI          string      `xml:"URI,omitempty"`
+}
+
+type granteeFields Grantee
+
+func (g Grantee) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	start.Attr = append(start.Attr,
+		xml.Attr{Name: xml.Name{Local: "xmlns:xsi"}, Value: xmlSchemaInstanceNS},
+		xml.Attr{Name: xml.Name{Local: "xsi:type"}, Value: string(g.Type)})
+	return e.EncodeElement(granteeFields(g), start)
+}
+
+func (g *Grantee) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	var fields granteeFields
+	if err := d.DecodeElement(&fields, &start); err != nil {
+		return err
+	}
+	*g = Grantee(fields)
+	for _, attr := range start.Attr {
+		if attr.Name.Local == "type" {
+			g.Type = GranteeType(attr.Value)
+		}
+	}
+	return nil
+}
+
 type Buckets []BucketInfo
 
 // Names is a deterministic convenience function returning a sorted list of bucket names.
@@ -94,9 +182,11 @@ func NewContentTime(t time.Time) ContentTime {
 }
 
 func (c ContentTime) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
-	// This is the format expected by the aws xml code, not the default.
+	// This is the format expected by the aws xml code, not the default. Like
+	// S3, it always has millisecond precision, even though the
+	// 'Last-Modified' header is truncated to seconds.
 	if !c.IsZero() {
-		var s = c.Format("2006-01-02T15:04:05.999Z")
+		var s = c.UTC().Format("2006-01-02T15:04:05.000Z")
 		return e.EncodeElement(s, start)
 	}
 	return nil
@@ -137,6 +227,7 @@ func (d MultiDeleteResult) AsError() error {
 type ErrorResult struct {
 	XMLName   xml.Name  `xml:"Error"`
 	Key       string    `xml:"Key,omitempty"`
+	VersionID string    `xml:"VersionId,omitempty"`
 	Code      ErrorCode `xml:"Code,omitempty"`
 	Message   string    `xml:"Message,omitempty"`
 	Resource  string    `xml:"Resource,omitempty"`
@@ -201,10 +292,22 @@ type ListBucketResultBase struct {
 
 	MaxKeys int64 `xml:"MaxKeys,omitempty"`
 
+	// EncodingType is EncodingTypeURL if the keys in the response are URL
+	// encoded.
+	EncodingType string `xml:"EncodingType,omitempty"`
+
+	// Like S3, a listing with no results has neither element, rather than
+	// empty ones, which SDKs would read as an object with no key:
 	CommonPrefixes []CommonPrefix `xml:"CommonPrefixes,omitempty"`
 	Contents       []*Content     `xml:"Contents"`
 }
 
+// CreateBucketConfiguration is the optional body of a CreateBucket request.
+type CreateBucketConfiguration struct {
+	XMLName            xml.Name `xml:"CreateBucketConfiguration"`
+	LocationConstraint string   `xml:"LocationConstraint,omitempty"`
+}
+
 type GetBucketLocation struct {
 	XMLName            xml.Name `xml:"LocationConstraint"`
 	Xmlns              string   `xml:"xmlns,attr"`
@@ -238,8 +341,9 @@ type ListBucketResultV2 struct {
 	ContinuationToken string `xml:"ContinuationToken,omitempty"`
 
 	// Returns the number of keys included in the response. The value is always
-	// less than or equal to the MaxKeys value.
-	KeyCount int64 `xml:"KeyCount,omitempty"`
+	// less than or equal to the MaxKeys value. Like S3, it is included even if
+	// it is 0.
+	KeyCount int64 `xml:"KeyCount"`
 
 	// If the response is truncated, Amazon S3 returns this parameter with a
 	// continuation token. You can specify the token as the continuation-token
@@ -298,6 +402,7 @@ type ListBucketVersionsResult struct {
 	CommonPrefixes []CommonPrefix `xml:"CommonPrefixes,omitempty"`
 	IsTruncated    bool           `xml:"IsTruncated"`
 	MaxKeys        int64          `xml:"MaxKeys"`
+	EncodingType   string         `xml:"EncodingType,omitempty"`
 
 	// Marks the last Key returned in a truncated response.
 	KeyMarker string `xml:"KeyMarker,omitempty"`
@@ -437,6 +542,13 @@ type CopyObjectResult struct {
 	LastModified ContentTime `xml:"LastModified,omitempty"`
 }
 
+// CopyPartResult contains the response from an UploadPartCopy operation.
+type CopyPartResult struct {
+	XMLName      xml.Name    `xml:"CopyPartResult"`
+	ETag         string      `xml:"ETag,omitempty"`
+	LastModified ContentTime `xml:"LastModified,omitempty"`
+}
+
 // MFADeleteStatus is used by VersioningConfiguration.
 type MFADeleteStatus string
 
@@ -472,6 +584,419 @@ type ObjectID struct {
 	VersionID string `xml:"VersionId,omitempty" json:"VersionId,omitempty"`
 }
 
+// ObjectLockMode is used by ObjectRetention.
+type ObjectLockMode string
+
+const (
+	ObjectLockModeGovernance ObjectLockMode = "GOVERNANCE"
+	ObjectLockModeCompliance ObjectLockMode = "COMPLIANCE"
+)
+
+func (m ObjectLockMode) Valid() bool {
+	return m == ObjectLockModeGovernance || m == ObjectLockModeCompliance
+}
+
+// ObjectRetention is the body of the PutObjectRetention request and the
+// GetObjectRetention response.
+type ObjectRetention struct {
+	XMLName xml.Name `xml:"Retention"`
+
+	Mode            ObjectLockMode `xml:"Mode"`
+	RetainUntilDate ContentTime    `xml:"RetainUntilDate"`
+}
+
+// ObjectLockEnabled is used by ObjectLockConfiguration.
+type ObjectLockEnabled string
+
+const ObjectLockEnabledEnabled ObjectLockEnabled = "Enabled"
+
+// ObjectLockConfiguration is the body of the PutObjectLockConfiguration
+// request and the GetObjectLockConfiguration response.
+type ObjectLockConfiguration struct {
+	XMLName xml.Name `xml:"ObjectLockConfiguration"`
+
+	ObjectLockEnabled ObjectLockEnabled `xml:"ObjectLockEnabled,omitempty"`
+	Rule              *ObjectLockRule   `xml:"Rule,omitempty"`
+}
+
+// ObjectLockRule is used by ObjectLockConfiguration.
+type ObjectLockRule struct {
+	DefaultRetention *DefaultRetention `xml:"DefaultRetention"`
+}
+
+// DefaultRetention is the retention given to new objects in a bucket with an
+// ObjectLockConfiguration, for a period of either Days or Years.
+type DefaultRetention struct {
+	Mode  ObjectLockMode `xml:"Mode"`
+	Days  int            `xml:"Days,omitempty"`
+	Years int            `xml:"Years,omitempty"`
+}
+
+// ObjectLegalHoldStatus is used by ObjectLegalHold.
+type ObjectLegalHoldStatus string
+
+const (
+	ObjectLegalHoldOn  ObjectLegalHoldStatus = "ON"
+	ObjectLegalHoldOff ObjectLegalHoldStatus = "OFF"
+)
+
+func (s ObjectLegalHoldStatus) Valid() bool {
+	return s == ObjectLegalHoldOn || s == ObjectLegalHoldOff
+}
+
+// ObjectLegalHold is the body of the PutObjectLegalHold request and the
+// GetObjectLegalHold response.
+type ObjectLegalHold struct {
+	XMLName xml.Name `xml:"LegalHold"`
+
+	Status ObjectLegalHoldStatus `xml:"Status"`
+}
+
+// ObjectOwnership is used by OwnershipControlsRule.
+type ObjectOwnership string
+
+const (
+	ObjectOwnershipBucketOwnerPreferred ObjectOwnership = "BucketOwnerPreferred"
+	ObjectOwnershipObjectWriter         ObjectOwnership = "ObjectWriter"
+	ObjectOwnershipBucketOwnerEnforced  ObjectOwnership = "BucketOwnerEnforced"
+)
+
+func (o ObjectOwnership) Valid() bool {
+	return o == ObjectOwnershipBucketOwnerPreferred ||
+		o == ObjectOwnershipObjectWriter ||
+		o == ObjectOwnershipBucketOwnerEnforced
+}
+
+// OwnershipControls is the body of the PutBucketOwnershipControls request and
+// the GetBucketOwnershipControls response.
+type OwnershipControls struct {
+	XMLName xml.Name `xml:"OwnershipControls"`
+
+	Rules []OwnershipControlsRule `xml:"Rule"`
+}
+
+type OwnershipControlsRule struct {
+	ObjectOwnership ObjectOwnership `xml:"ObjectOwnership"`
+}
+
+// PublicAccessBlockConfiguration is the body of the PutPublicAccessBlock
+// request and the GetPublicAccessBlock response.
+type PublicAccessBlockConfiguration struct {
+	XMLName xml.Name `xml:"PublicAccessBlockConfiguration"`
+
+	BlockPublicAcls       bool `xml:"BlockPublicAcls"`
+	IgnorePublicAcls      bool `xml:"IgnorePublicAcls"`
+	BlockPublicPolicy     bool `xml:"BlockPublicPolicy"`
+	RestrictPublicBuckets bool `xml:"RestrictPublicBuckets"`
+}
+
+// AccelerateStatus is used by AccelerateConfiguration.
+type AccelerateStatus string
+
+const (
+	AccelerateEnabled   AccelerateStatus = "Enabled"
+	AccelerateSuspended AccelerateStatus = "Suspended"
+)
+
+func (s AccelerateStatus) Valid() bool {
+	return s == AccelerateEnabled || s == AccelerateSuspended
+}
+
+// AccelerateConfiguration is the body of the PutBucketAccelerateConfiguration
+// request and the GetBucketAccelerateConfiguration response. Status is empty
+// if transfer acceleration has never been configured for the bucket.
+type AccelerateConfiguration struct {
+	XMLName xml.Name `xml:"AccelerateConfiguration"`
+	Xmlns   string   `xml:"xmlns,attr,omitempty"`
+
+	Status AccelerateStatus `xml:"Status,omitempty"`
+}
+
+// Tagging is the body of the PutObjectTagging request and the
+// GetObjectTagging response.
+type Tagging struct {
+	XMLName xml.Name `xml:"Tagging"`
+	Xmlns   string   `xml:"xmlns,attr,omitempty"`
+
+	TagSet []Tag `xml:"TagSet>Tag"`
+}
+
+type Tag struct {
+	Key   string `xml:"Key"`
+	Value string `xml:"Value"`
+}
+
+// AnalyticsConfiguration is the body of the PutBucketAnalyticsConfiguration
+// request and the GetBucketAnalyticsConfiguration response.
+type AnalyticsConfiguration struct {
+	XMLName xml.Name `xml:"AnalyticsConfiguration"`
+	Xmlns   string   `xml:"xmlns,attr,omitempty"`
+
+	ID                   string                `xml:"Id"`
+	Filter               *ConfigurationFilter  `xml:"Filter,omitempty"`
+	StorageClassAnalysis *StorageClassAnalysis `xml:"StorageClassAnalysis"`
+}
+
+// ConfigurationFilter limits an AnalyticsConfiguration, or similar, to the
+// objects matching a prefix, a tag, or all of several conditions if And is
+// used. AccessPointArn is only used by MetricsConfiguration.
+type ConfigurationFilter struct {
+	Prefix         string                  `xml:"Prefix,omitempty"`
+	Tag            *Tag                    `xml:"Tag,omitempty"`
+	AccessPointArn string                  `xml:"AccessPointArn,omitempty"`
+	And            *ConfigurationFilterAnd `xml:"And,omitempty"`
+}
+
+type ConfigurationFilterAnd struct {
+	Prefix         string `xml:"Prefix,omitempty"`
+	Tags           []Tag  `xml:"Tag"`
+	AccessPointArn string `xml:"AccessPointArn,omitempty"`
+}
+
+type StorageClassAnalysis struct {
+	DataExport *StorageClassAnalysisDataExport `xml:"DataExport,omitempty"`
+}
+
+type StorageClassAnalysisDataExport struct {
+	OutputSchemaVersion string                     `xml:"OutputSchemaVersion"`
+	Destination         AnalyticsExportDestination `xml:"Destination"`
+}
+
+type AnalyticsExportDestination struct {
+	S3BucketDestination AnalyticsS3BucketDestination `xml:"S3BucketDestination"`
+}
+
+type AnalyticsS3BucketDestination struct {
+	Format          string `xml:"Format"`
+	BucketAccountID string `xml:"BucketAccountId,omitempty"`
+	Bucket          string `xml:"Bucket"`
+	Prefix          string `xml:"Prefix,omitempty"`
+}
+
+// ListBucketAnalyticsConfigurationsResult is the response to the
+// ListBucketAnalyticsConfigurations request.
+type ListBucketAnalyticsConfigurationsResult struct {
+	XMLName xml.Name `xml:"ListBucketAnalyticsConfigurationResult"`
+	Xmlns   string   `xml:"xmlns,attr"`
+
+	IsTruncated             bool                     `xml:"IsTruncated"`
+	ContinuationToken       string                   `xml:"ContinuationToken,omitempty"`
+	NextContinuationToken   string                   `xml:"NextContinuationToken,omitempty"`
+	AnalyticsConfigurations []AnalyticsConfiguration `xml:"AnalyticsConfiguration"`
+}
+
+// MetricsConfiguration is the body of the PutBucketMetricsConfiguration
+// request and the GetBucketMetricsConfiguration response.
+type MetricsConfiguration struct {
+	XMLName xml.Name `xml:"MetricsConfiguration"`
+	Xmlns   string   `xml:"xmlns,attr,omitempty"`
+
+	ID     string               `xml:"Id"`
+	Filter *ConfigurationFilter `xml:"Filter,omitempty"`
+}
+
+// ListBucketMetricsConfigurationsResult is the response to the
+// ListBucketMetricsConfigurations request.
+type ListBucketMetricsConfigurationsResult struct {
+	XMLName xml.Name `xml:"ListMetricsConfigurationsResult"`
+	Xmlns   string   `xml:"xmlns,attr"`
+
+	IsTruncated           bool                   `xml:"IsTruncated"`
+	ContinuationToken     string                 `xml:"ContinuationToken,omitempty"`
+	NextContinuationToken string                 `xml:"NextContinuationToken,omitempty"`
+	MetricsConfigurations []MetricsConfiguration `xml:"MetricsConfiguration"`
+}
+
+// IntelligentTieringConfiguration is the body of the
+// PutBucketIntelligentTieringConfiguration request and the
+// GetBucketIntelligentTieringConfiguration response.
+type IntelligentTieringConfiguration struct {
+	XMLName xml.Name `xml:"IntelligentTieringConfiguration"`
+	Xmlns   string   `xml:"xmlns,attr,omitempty"`
+
+	ID       string                   `xml:"Id"`
+	Filter   *ConfigurationFilter     `xml:"Filter,omitempty"`
+	Status   IntelligentTieringStatus `xml:"Status"`
+	Tierings []Tiering                `xml:"Tiering"`
+}
+
+type IntelligentTieringStatus string
+
+const (
+	IntelligentTieringEnabled  IntelligentTieringStatus = "Enabled"
+	IntelligentTieringDisabled IntelligentTieringStatus = "Disabled"
+)
+
+func (s IntelligentTieringStatus) Valid() bool {
+	return s == IntelligentTieringEnabled || s == IntelligentTieringDisabled
+}
+
+// Tiering moves objects to AccessTier once they have not been accessed for
+// Days.
+type Tiering struct {
+	AccessTier IntelligentTieringAccessTier `xml:"AccessTier"`
+	Days       int                          `xml:"Days"`
+}
+
+type IntelligentTieringAccessTier string
+
+const (
+	IntelligentTieringArchiveAccess     IntelligentTieringAccessTier = "ARCHIVE_ACCESS"
+	IntelligentTieringDeepArchiveAccess IntelligentTieringAccessTier = "DEEP_ARCHIVE_ACCESS"
+)
+
+// ListBucketIntelligentTieringConfigurationsResult is the response to the
+// ListBucketIntelligentTieringConfigurations request.
+type ListBucketIntelligentTieringConfigurationsResult struct {
+	XMLName xml.Name `xml:"ListBucketIntelligentTieringConfigurationsOutput"`
+	Xmlns   string   `xml:"xmlns,attr"`
+
+	IsTruncated                      bool                              `xml:"IsTruncated"`
+	ContinuationToken                string                            `xml:"ContinuationToken,omitempty"`
+	NextContinuationToken            string                            `xml:"NextContinuationToken,omitempty"`
+	IntelligentTieringConfigurations []IntelligentTieringConfiguration `xml:"IntelligentTieringConfiguration"`
+}
+
+// CORSConfiguration is the body of the PutBucketCors request and the
+// GetBucketCors response.
+type CORSConfiguration struct {
+	XMLName xml.Name `xml:"CORSConfiguration"`
+	Xmlns   string   `xml:"xmlns,attr,omitempty"`
+
+	CORSRules []CORSRule `xml:"CORSRule"`
+}
+
+type CORSRule struct {
+	ID             string   `xml:"ID,omitempty"`
+	AllowedHeaders []string `xml:"AllowedHeader"`
+	AllowedMethods []string `xml:"AllowedMethod"`
+	AllowedOrigins []string `xml:"AllowedOrigin"`
+	ExposeHeaders  []string `xml:"ExposeHeader"`
+	MaxAgeSeconds  int      `xml:"MaxAgeSeconds,omitempty"`
+}
+
+// LifecycleConfiguration is the body of the PutBucketLifecycleConfiguration
+// request and the GetBucketLifecycleConfiguration response.
+type LifecycleConfiguration struct {
+	XMLName xml.Name `xml:"LifecycleConfiguration"`
+	Xmlns   string   `xml:"xmlns,attr,omitempty"`
+
+	Rules []LifecycleRule `xml:"Rule"`
+}
+
+// LifecycleRule applies its actions to the objects matching Filter. Prefix is
+// the deprecated alternative to Filter, and may not be used with it.
+type LifecycleRule struct {
+	ID     string               `xml:"ID,omitempty"`
+	Filter *LifecycleRuleFilter `xml:"Filter,omitempty"`
+	Prefix string               `xml:"Prefix,omitempty"`
+	Status LifecycleRuleStatus  `xml:"Status"`
+
+	Expiration                     *LifecycleExpiration            `xml:"Expiration,omitempty"`
+	Transitions                    []LifecycleTransition           `xml:"Transition"`
+	NoncurrentVersionExpiration    *NoncurrentVersionExpiration    `xml:"NoncurrentVersionExpiration,omitempty"`
+	NoncurrentVersionTransitions   []NoncurrentVersionTransition   `xml:"NoncurrentVersionTransition"`
+	AbortIncompleteMultipartUpload *AbortIncompleteMultipartUpload `xml:"AbortIncompleteMultipartUpload,omitempty"`
+}
+
+type LifecycleRuleStatus string
+
+const (
+	LifecycleRuleEnabled  LifecycleRuleStatus = "Enabled"
+	LifecycleRuleDisabled LifecycleRuleStatus = "Disabled"
+)
+
+func (s LifecycleRuleStatus) Valid() bool {
+	return s == LifecycleRuleEnabled || s == LifecycleRuleDisabled
+}
+
+// LifecycleRuleFilter limits a LifecycleRule to the objects matching a
+// prefix, a tag, a size range, or all of several conditions if And is used.
+type LifecycleRuleFilter struct {
+	Prefix                string                    `xml:"Prefix,omitempty"`
+	Tag                   *Tag                      `xml:"Tag,omitempty"`
+	ObjectSizeGreaterThan int64                     `xml:"ObjectSizeGreaterThan,omitempty"`
+	ObjectSizeLessThan    int64                     `xml:"ObjectSizeLessThan,omitempty"`
+	And                   *LifecycleRuleAndOperator `xml:"And,omitempty"`
+}
+
+type LifecycleRuleAndOperator struct {
+	Prefix                string `xml:"Prefix,omitempty"`
+	Tags                  []Tag  `xml:"Tag"`
+	ObjectSizeGreaterThan int64  `xml:"ObjectSizeGreaterThan,omitempty"`
+	ObjectSizeLessThan    int64  `xml:"ObjectSizeLessThan,omitempty"`
+}
+
+// LifecycleExpiration expires current object versions on Date, or Days after
+// they were created. ExpiredObjectDeleteMarker removes delete markers that
+// no longer have any noncurrent versions behind them instead.
+type LifecycleExpiration struct {
+	Date                      ContentTime `xml:"Date"`
+	Days                      int         `xml:"Days,omitempty"`
+	ExpiredObjectDeleteMarker bool        `xml:"ExpiredObjectDeleteMarker,omitempty"`
+}
+
+type LifecycleTransition struct {
+	Date         ContentTime  `xml:"Date"`
+	Days         int          `xml:"Days,omitempty"`
+	StorageClass StorageClass `xml:"StorageClass"`
+}
+
+type NoncurrentVersionExpiration struct {
+	NoncurrentDays          int `xml:"NoncurrentDays,omitempty"`
+	NewerNoncurrentVersions int `xml:"NewerNoncurrentVersions,omitempty"`
+}
+
+type NoncurrentVersionTransition struct {
+	NoncurrentDays          int          `xml:"NoncurrentDays,omitempty"`
+	NewerNoncurrentVersions int          `xml:"NewerNoncurrentVersions,omitempty"`
+	StorageClass            StorageClass `xml:"StorageClass"`
+}
+
+type AbortIncompleteMultipartUpload struct {
+	DaysAfterInitiation int `xml:"DaysAfterInitiation"`
+}
+
+// GetObjectAttributesResult is returned by GetObjectAttributes. Only the
+// attributes that were asked for are set.
+type GetObjectAttributesResult struct {
+	XMLName      xml.Name                  `xml:"GetObjectAttributesResponse"`
+	Xmlns        string                    `xml:"xmlns,attr"`
+	ETag         string                    `xml:"ETag,omitempty"`
+	Checksum     *Checksum                 `xml:"Checksum,omitempty"`
+	ObjectParts  *GetObjectAttributesParts `xml:"ObjectParts,omitempty"`
+	StorageClass StorageClass              `xml:"StorageClass,omitempty"`
+	ObjectSize   *int64                    `xml:"ObjectSize,omitempty"`
+}
+
+// GetObjectAttributesParts lists the parts of an object created by a
+// multipart upload.
+type GetObjectAttributesParts struct {
+	PartsCount           int          `xml:"PartsCount"`
+	PartNumberMarker     int          `xml:"PartNumberMarker"`
+	NextPartNumberMarker int          `xml:"NextPartNumberMarker"`
+	MaxParts             int64        `xml:"MaxParts"`
+	IsTruncated          bool         `xml:"IsTruncated"`
+	Parts                []ObjectPart `xml:"Part"`
+}
+
+// ObjectPart is a part of an object listed by GetObjectAttributes. S3 does not
+// return the part's ETag; GoFakeS3 does, so that clients can check it.
+type ObjectPart struct {
+	PartNumber int    `xml:"PartNumber"`
+	Size       int64  `xml:"Size"`
+	ETag       string `xml:"ETag,omitempty"`
+}
+
+// Checksum holds an object's checksum; only the field for the algorithm it
+// was computed with is set.
+type Checksum struct {
+	ChecksumCRC32  string `xml:"ChecksumCRC32,omitempty"`
+	ChecksumCRC32C string `xml:"ChecksumCRC32C,omitempty"`
+	ChecksumSHA1   string `xml:"ChecksumSHA1,omitempty"`
+	ChecksumSHA256 string `xml:"ChecksumSHA256,omitempty"`
+}
+
 type StorageClass string
 
 func (s StorageClass) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
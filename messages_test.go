@@ -35,7 +35,7 @@ func TestContentTime(t *testing.T) {
 	const expected = "" +
 		"<testMsg>" +
 		"<Foo>bar</Foo>" +
-		"<Time>2019-01-01T12:00:00Z</Time>" +
+		"<Time>2019-01-01T12:00:00.000Z</Time>" +
 		"</testMsg>"
 
 	var v = testMsg{
@@ -143,7 +143,7 @@ func TestCopyObjectResult(t *testing.T) {
 	const expected = "" +
 		"<CopyObjectResult>" +
 		"<ETag>&#34;etag0&#34;</ETag>" +
-		"<LastModified>2019-01-01T12:00:00Z</LastModified>" +
+		"<LastModified>2019-01-01T12:00:00.000Z</LastModified>" +
 		"</CopyObjectResult>"
 
 	out, err := xml.Marshal(&res)
@@ -0,0 +1,59 @@
+package gofakes3
+
+import "net/http"
+
+const bucketMetricsConfig = "metrics"
+
+// MaxMetricsConfigurations is the number of metrics configurations a bucket
+// may have, documented here:
+// https://docs.aws.amazon.com/AmazonS3/latest/API/API_PutBucketMetricsConfiguration.html
+const MaxMetricsConfigurations = 1000
+
+func (g *GoFakeS3) getBucketMetricsConfiguration(bucket string, w http.ResponseWriter, r *http.Request) error {
+	g.log.Print(LogInfo, "GET BUCKET METRICS CONFIGURATION:", bucket, r.URL.Query().Get("id"))
+
+	config, err := g.getIDConfig(bucket, bucketMetricsConfig, r)
+	if err != nil {
+		return err
+	}
+	out := *config.(*MetricsConfiguration)
+	out.Xmlns = "http://s3.amazonaws.com/doc/2006-03-01/"
+	return g.xmlEncoder(w).Encode(&out)
+}
+
+func (g *GoFakeS3) listBucketMetricsConfigurations(bucket string, w http.ResponseWriter, r *http.Request) error {
+	g.log.Print(LogInfo, "LIST BUCKET METRICS CONFIGURATIONS:", bucket)
+
+	configs, token, next, err := g.listIDConfigs(bucket, bucketMetricsConfig, r)
+	if err != nil {
+		return err
+	}
+
+	out := ListBucketMetricsConfigurationsResult{
+		Xmlns:                 "http://s3.amazonaws.com/doc/2006-03-01/",
+		IsTruncated:           next != "",
+		ContinuationToken:     token,
+		NextContinuationToken: next,
+	}
+	for _, config := range configs {
+		out.MetricsConfigurations = append(out.MetricsConfigurations, *config.(*MetricsConfiguration))
+	}
+	return g.xmlEncoder(w).Encode(&out)
+}
+
+func (g *GoFakeS3) putBucketMetricsConfiguration(bucket string, w http.ResponseWriter, r *http.Request) error {
+	g.log.Print(LogInfo, "PUT BUCKET METRICS CONFIGURATION:", bucket, r.URL.Query().Get("id"))
+
+	var in MetricsConfiguration
+	return g.putIDConfig(bucket, bucketMetricsConfig, MaxMetricsConfigurations, r, &in,
+		func() string { return in.ID },
+		func() error {
+			in.Xmlns = ""
+			return validateConfigurationFilter(in.Filter)
+		})
+}
+
+func (g *GoFakeS3) deleteBucketMetricsConfiguration(bucket string, w http.ResponseWriter, r *http.Request) error {
+	g.log.Print(LogInfo, "DELETE BUCKET METRICS CONFIGURATION:", bucket, r.URL.Query().Get("id"))
+	return g.deleteIDConfig(bucket, bucketMetricsConfig, w, r)
+}
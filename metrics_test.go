@@ -0,0 +1,85 @@
+package gofakes3_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/johannesboyne/gofakes3"
+)
+
+func TestBucketMetricsConfiguration(t *testing.T) {
+	ts := newTestServer(t)
+	defer ts.Close()
+	svc := ts.s3Client()
+
+	config := func(id string) *s3.MetricsConfiguration {
+		return &s3.MetricsConfiguration{
+			Id: aws.String(id),
+			Filter: &s3.MetricsFilter{
+				Tag: &s3.Tag{Key: aws.String("team"), Value: aws.String("storage")},
+			},
+		}
+	}
+
+	_, err := svc.GetBucketMetricsConfiguration(&s3.GetBucketMetricsConfigurationInput{
+		Bucket: aws.String(defaultBucket),
+		Id:     aws.String("missing"),
+	})
+	if !hasErrorCode(err, gofakes3.ErrNoSuchConfiguration) {
+		t.Fatal("expected ErrNoSuchConfiguration, found", err)
+	}
+
+	for i := 0; i < 101; i++ {
+		id := fmt.Sprintf("metrics-%03d", i)
+		ts.OKAll(svc.PutBucketMetricsConfiguration(&s3.PutBucketMetricsConfigurationInput{
+			Bucket:               aws.String(defaultBucket),
+			Id:                   aws.String(id),
+			MetricsConfiguration: config(id),
+		}))
+	}
+
+	rs, err := svc.GetBucketMetricsConfiguration(&s3.GetBucketMetricsConfigurationInput{
+		Bucket: aws.String(defaultBucket),
+		Id:     aws.String("metrics-042"),
+	})
+	ts.OK(err)
+	if rs.MetricsConfiguration.String() != config("metrics-042").String() {
+		t.Fatal("configuration mismatch:", rs.MetricsConfiguration)
+	}
+
+	t.Run("list-pages", func(t *testing.T) {
+		first, err := svc.ListBucketMetricsConfigurations(&s3.ListBucketMetricsConfigurationsInput{
+			Bucket: aws.String(defaultBucket),
+		})
+		ts.OK(err)
+		if !aws.BoolValue(first.IsTruncated) || len(first.MetricsConfigurationList) != 100 {
+			t.Fatal("unexpected first page", len(first.MetricsConfigurationList), aws.BoolValue(first.IsTruncated))
+		}
+
+		second, err := svc.ListBucketMetricsConfigurations(&s3.ListBucketMetricsConfigurationsInput{
+			Bucket:            aws.String(defaultBucket),
+			ContinuationToken: first.NextContinuationToken,
+		})
+		ts.OK(err)
+		if aws.BoolValue(second.IsTruncated) || len(second.MetricsConfigurationList) != 1 {
+			t.Fatal("unexpected second page", len(second.MetricsConfigurationList), aws.BoolValue(second.IsTruncated))
+		}
+		if id := aws.StringValue(second.MetricsConfigurationList[0].Id); id != "metrics-100" {
+			t.Fatal("unexpected id", id)
+		}
+	})
+
+	ts.OKAll(svc.DeleteBucketMetricsConfiguration(&s3.DeleteBucketMetricsConfigurationInput{
+		Bucket: aws.String(defaultBucket),
+		Id:     aws.String("metrics-042"),
+	}))
+	_, err = svc.GetBucketMetricsConfiguration(&s3.GetBucketMetricsConfigurationInput{
+		Bucket: aws.String(defaultBucket),
+		Id:     aws.String("metrics-042"),
+	})
+	if !hasErrorCode(err, gofakes3.ErrNoSuchConfiguration) {
+		t.Fatal("expected ErrNoSuchConfiguration, found", err)
+	}
+}
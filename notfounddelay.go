@@ -0,0 +1,34 @@
+package gofakes3
+
+import (
+	"net/http"
+	"path"
+	"time"
+)
+
+// notFoundDelayRule is added by WithNotFoundDelay.
+type notFoundDelayRule struct {
+	pattern string
+	delay   time.Duration
+}
+
+// delayNotFound implements WithNotFoundDelay, waiting before a GET or HEAD
+// object request fails with ErrNoSuchKey. It returns an error if the client
+// goes away first, in which case there is nobody left to respond to.
+func (g *GoFakeS3) delayNotFound(bucket, object string, r *http.Request, err error) error {
+	if len(g.notFoundDelays) == 0 || object == "" || !HasErrorCode(err, ErrNoSuchKey) {
+		return nil
+	}
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return nil
+	}
+
+	for _, rule := range g.notFoundDelays {
+		if matched, _ := path.Match(rule.pattern, bucket+"/"+object); !matched {
+			continue
+		}
+		g.log.Print(LogInfo, "DELAYING NOT FOUND:", bucket, object, rule.delay)
+		return g.sleep(r.Context(), rule.delay)
+	}
+	return nil
+}
@@ -0,0 +1,302 @@
+package gofakes3
+
+import (
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	objectRetentionConfig  = "retention"
+	objectLegalHoldConfig  = "legal-hold"
+	bucketObjectLockConfig = "object-lock"
+)
+
+const (
+	// ObjectLockEnabledHeader may be sent with a CreateBucket request, set
+	// to 'true', to create a bucket that supports an
+	// ObjectLockConfiguration. Object Lock cannot be enabled on a bucket
+	// after it has been created.
+	ObjectLockEnabledHeader = "X-Amz-Bucket-Object-Lock-Enabled"
+
+	// ObjectLockModeHeader and ObjectLockRetainUntilDateHeader may be sent
+	// together with a PutObject or CopyObject request to set the retention
+	// of the new object, instead of the bucket's DefaultRetention. They are
+	// returned by GET and HEAD object requests for objects with retention.
+	ObjectLockModeHeader            = "X-Amz-Object-Lock-Mode"
+	ObjectLockRetainUntilDateHeader = "X-Amz-Object-Lock-Retain-Until-Date"
+
+	// BypassGovernanceRetentionHeader may be sent, set to 'true', with a
+	// DeleteObjects request to delete object versions whose retention is in
+	// GOVERNANCE mode.
+	BypassGovernanceRetentionHeader = "X-Amz-Bypass-Governance-Retention"
+)
+
+// bucketObjectLock returns the bucket's ObjectLockConfiguration, if it was
+// created with Object Lock enabled.
+func (g *GoFakeS3) bucketObjectLock(bucket string) (*ObjectLockConfiguration, bool) {
+	config, ok := g.configs.get(resourceConfigKey{bucket: bucket, kind: bucketObjectLockConfig})
+	if !ok {
+		return nil, false
+	}
+	return config.(*ObjectLockConfiguration), true
+}
+
+// enableObjectLock is used by CreateBucket requests that send
+// ObjectLockEnabledHeader. Like S3, this also enables versioning, if the
+// Backend supports it.
+func (g *GoFakeS3) enableObjectLock(bucket string) error {
+	g.configs.put(resourceConfigKey{bucket: bucket, kind: bucketObjectLockConfig}, &ObjectLockConfiguration{
+		ObjectLockEnabled: ObjectLockEnabledEnabled,
+	})
+	if g.versioned == nil {
+		return nil
+	}
+	return g.versioned.SetVersioningConfiguration(bucket, VersioningConfiguration{Status: VersioningEnabled})
+}
+
+func (g *GoFakeS3) getObjectLockConfiguration(bucket string, w http.ResponseWriter, r *http.Request) error {
+	g.log.Print(LogInfo, "GET OBJECT LOCK CONFIGURATION:", bucket)
+
+	if err := g.ensureBucketExists(bucket); err != nil {
+		return err
+	}
+
+	config, ok := g.bucketObjectLock(bucket)
+	if !ok {
+		return ResourceError(ErrObjectLockConfigurationNotFound, bucket)
+	}
+	return g.xmlEncoder(w).Encode(config)
+}
+
+func (g *GoFakeS3) putObjectLockConfiguration(bucket string, w http.ResponseWriter, r *http.Request) error {
+	g.log.Print(LogInfo, "PUT OBJECT LOCK CONFIGURATION:", bucket)
+
+	if err := g.ensureBucketExists(bucket); err != nil {
+		return err
+	}
+
+	var in ObjectLockConfiguration
+	if err := g.xmlDecodeBody(r.Body, &in); err != nil {
+		return err
+	}
+	if in.ObjectLockEnabled != ObjectLockEnabledEnabled {
+		return ErrMalformedXML
+	}
+	if in.Rule != nil {
+		retention := in.Rule.DefaultRetention
+		if retention == nil || !retention.Mode.Valid() {
+			return ErrMalformedXML
+		}
+		if (retention.Days > 0) == (retention.Years > 0) || retention.Days < 0 || retention.Years < 0 {
+			return ErrorMessage(ErrMalformedXML, "The DefaultRetention must specify a positive number of either Days or Years")
+		}
+	}
+
+	if _, ok := g.bucketObjectLock(bucket); !ok {
+		return ErrorMessage(ErrInvalidBucketState, "Object Lock configuration cannot be enabled on existing buckets")
+	}
+	g.configs.put(resourceConfigKey{bucket: bucket, kind: bucketObjectLockConfig}, &in)
+	return nil
+}
+
+// requestRetention returns the retention set by a PutObject or CopyObject
+// request's ObjectLockModeHeader and ObjectLockRetainUntilDateHeader, and
+// removes the headers from the object's metadata; the retention is stored
+// with setObjectRetention instead, like retention set with
+// PutObjectRetention. nil is returned if the headers were not sent.
+func (g *GoFakeS3) requestRetention(bucket string, meta map[string]string) (*ObjectRetention, error) {
+	mode, hasMode := meta[ObjectLockModeHeader]
+	until, hasUntil := meta[ObjectLockRetainUntilDateHeader]
+	delete(meta, ObjectLockModeHeader)
+	delete(meta, ObjectLockRetainUntilDateHeader)
+	if !hasMode && !hasUntil {
+		return nil, nil
+	} else if !hasMode || !hasUntil {
+		return nil, ErrorMessage(ErrInvalidArgument, "x-amz-object-lock-retain-until-date and x-amz-object-lock-mode must both be supplied")
+	}
+
+	retention := &ObjectRetention{Mode: ObjectLockMode(strings.ToUpper(mode))}
+	if !retention.Mode.Valid() {
+		return nil, ErrorInvalidArgument(ObjectLockModeHeader, mode, "Unknown wormMode directive.")
+	}
+	at, err := time.Parse(time.RFC3339, until)
+	if err != nil {
+		return nil, ErrorInvalidArgument(ObjectLockRetainUntilDateHeader, until, "The retain until date must be provided in ISO 8601 format")
+	}
+	if !at.After(g.timeSource.Now()) {
+		return nil, ErrorInvalidArgument(ObjectLockRetainUntilDateHeader, until, "The retain until date must be in the future!")
+	}
+	retention.RetainUntilDate = NewContentTime(at)
+
+	if _, ok := g.bucketObjectLock(bucket); !ok {
+		return nil, ErrorMessage(ErrInvalidRequest, "Bucket is missing Object Lock Configuration")
+	}
+	return retention, nil
+}
+
+// setObjectRetention stores the retention of a new object version, which is
+// either the retention the request asked for, or failing that, the bucket's
+// DefaultRetention, if it has one.
+func (g *GoFakeS3) setObjectRetention(bucket, object string, version VersionID, retention *ObjectRetention) {
+	if retention == nil {
+		config, ok := g.bucketObjectLock(bucket)
+		if !ok || config.Rule == nil || config.Rule.DefaultRetention == nil {
+			return
+		}
+		def := config.Rule.DefaultRetention
+		retention = &ObjectRetention{
+			Mode:            def.Mode,
+			RetainUntilDate: NewContentTime(g.timeSource.Now().AddDate(def.Years, 0, def.Days)),
+		}
+	}
+	g.configs.put(resourceConfigKey{bucket: bucket, object: object, version: version, kind: objectRetentionConfig}, retention)
+}
+
+// writeRetentionHeaders returns the retention of an object version in the
+// response to a GET or HEAD object request.
+func (g *GoFakeS3) writeRetentionHeaders(bucket, object string, version VersionID, w http.ResponseWriter) {
+	config, ok := g.configs.get(resourceConfigKey{bucket: bucket, object: object, version: version, kind: objectRetentionConfig})
+	if !ok {
+		return
+	}
+	retention := config.(*ObjectRetention)
+	w.Header().Set(ObjectLockModeHeader, string(retention.Mode))
+	w.Header().Set(ObjectLockRetainUntilDateHeader, retention.RetainUntilDate.UTC().Format(time.RFC3339))
+}
+
+// objectConfigKey resolves the object version that a subresource request
+// refers to, so that configuration is attached to a version that exists.
+func (g *GoFakeS3) objectConfigKey(bucket, object string, versionID VersionID, kind string) (key resourceConfigKey, err error) {
+	obj, err := g.headObjectVersion(bucket, object, versionID)
+	if err != nil {
+		return key, err
+	}
+	return resourceConfigKey{bucket: bucket, object: object, version: obj.VersionID, kind: kind}, nil
+}
+
+func (g *GoFakeS3) getObjectRetention(bucket, object string, versionID VersionID, w http.ResponseWriter, r *http.Request) error {
+	g.log.Print(LogInfo, "GET OBJECT RETENTION:", bucket, object, versionID)
+
+	key, err := g.objectConfigKey(bucket, object, versionID, objectRetentionConfig)
+	if err != nil {
+		return err
+	}
+
+	config, ok := g.configs.get(key)
+	if !ok {
+		return ResourceError(ErrNoSuchObjectLockConfiguration, object)
+	}
+	return g.xmlEncoder(w).Encode(config)
+}
+
+func (g *GoFakeS3) putObjectRetention(bucket, object string, versionID VersionID, w http.ResponseWriter, r *http.Request) error {
+	g.log.Print(LogInfo, "PUT OBJECT RETENTION:", bucket, object, versionID)
+
+	key, err := g.objectConfigKey(bucket, object, versionID, objectRetentionConfig)
+	if err != nil {
+		return err
+	}
+
+	var in ObjectRetention
+	if err := g.xmlDecodeBody(r.Body, &in); err != nil {
+		return err
+	}
+	if !in.Mode.Valid() || in.RetainUntilDate.IsZero() {
+		return ErrMalformedXML
+	}
+	if !in.RetainUntilDate.After(g.timeSource.Now()) {
+		return ErrorInvalidArgument("RetainUntilDate", in.RetainUntilDate.String(), "The retain until date must be in the future!")
+	}
+
+	g.configs.put(key, &in)
+	return nil
+}
+
+func (g *GoFakeS3) getObjectLegalHold(bucket, object string, versionID VersionID, w http.ResponseWriter, r *http.Request) error {
+	g.log.Print(LogInfo, "GET OBJECT LEGAL HOLD:", bucket, object, versionID)
+
+	key, err := g.objectConfigKey(bucket, object, versionID, objectLegalHoldConfig)
+	if err != nil {
+		return err
+	}
+
+	config, ok := g.configs.get(key)
+	if !ok {
+		return ResourceError(ErrNoSuchObjectLockConfiguration, object)
+	}
+	return g.xmlEncoder(w).Encode(config)
+}
+
+func (g *GoFakeS3) putObjectLegalHold(bucket, object string, versionID VersionID, w http.ResponseWriter, r *http.Request) error {
+	g.log.Print(LogInfo, "PUT OBJECT LEGAL HOLD:", bucket, object, versionID)
+
+	key, err := g.objectConfigKey(bucket, object, versionID, objectLegalHoldConfig)
+	if err != nil {
+		return err
+	}
+
+	var in ObjectLegalHold
+	if err := g.xmlDecodeBody(r.Body, &in); err != nil {
+		return err
+	}
+	if !in.Status.Valid() {
+		return ErrMalformedXML
+	}
+
+	g.configs.put(key, &in)
+	return nil
+}
+
+// checkObjectLock returns ErrAccessDenied if an object version may not be
+// deleted, because it is under a legal hold or its retention period has not
+// ended. Retention in GOVERNANCE mode can be bypassed; COMPLIANCE mode cannot.
+func (g *GoFakeS3) checkObjectLock(bucket, object string, version VersionID, bypassGovernance bool) error {
+	key := resourceConfigKey{bucket: bucket, object: object, version: version}
+
+	key.kind = objectLegalHoldConfig
+	if config, ok := g.configs.get(key); ok && config.(*ObjectLegalHold).Status == ObjectLegalHoldOn {
+		return ErrAccessDenied
+	}
+
+	key.kind = objectRetentionConfig
+	config, ok := g.configs.get(key)
+	if !ok {
+		return nil
+	}
+	retention := config.(*ObjectRetention)
+	if !retention.RetainUntilDate.After(g.timeSource.Now()) {
+		return nil
+	}
+	if retention.Mode == ObjectLockModeCompliance || !bypassGovernance {
+		return ErrAccessDenied
+	}
+	return nil
+}
+
+// checkDeleteLock applies checkObjectLock to an object named in a
+// DeleteObjects request. Without a version, the current version is only
+// checked if the bucket does not have versioning enabled; otherwise it is
+// left in place behind a delete marker.
+func (g *GoFakeS3) checkDeleteLock(bucket string, id ObjectID, bypassGovernance bool) error {
+	version := VersionID(id.VersionID)
+	if version == "" {
+		if g.versioned != nil {
+			versioning, err := g.versioned.VersioningConfiguration(bucket)
+			if err != nil {
+				return err
+			}
+			if versioning.Enabled() {
+				return nil
+			}
+		}
+		obj, err := g.storage.HeadObject(bucket, id.Key)
+		if HasErrorCode(err, ErrNoSuchKey) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		version = obj.VersionID
+	}
+	return g.checkObjectLock(bucket, id.Key, version, bypassGovernance)
+}
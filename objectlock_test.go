@@ -0,0 +1,318 @@
+package gofakes3_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/johannesboyne/gofakes3"
+)
+
+func TestObjectRetention(t *testing.T) {
+	ts := newTestServer(t)
+	defer ts.Close()
+	svc := ts.s3Client()
+
+	ts.backendPutString(defaultBucket, "object", nil, "hello")
+
+	_, err := svc.GetObjectRetention(&s3.GetObjectRetentionInput{
+		Bucket: aws.String(defaultBucket),
+		Key:    aws.String("object"),
+	})
+	if !hasErrorCode(err, gofakes3.ErrNoSuchObjectLockConfiguration) {
+		t.Fatal("expected ErrNoSuchObjectLockConfiguration, found", err)
+	}
+
+	until := defaultDate.Add(24 * time.Hour)
+	ts.OKAll(svc.PutObjectRetention(&s3.PutObjectRetentionInput{
+		Bucket: aws.String(defaultBucket),
+		Key:    aws.String("object"),
+		Retention: &s3.ObjectLockRetention{
+			Mode:            aws.String(s3.ObjectLockRetentionModeGovernance),
+			RetainUntilDate: aws.Time(until),
+		},
+	}))
+
+	rs, err := svc.GetObjectRetention(&s3.GetObjectRetentionInput{
+		Bucket: aws.String(defaultBucket),
+		Key:    aws.String("object"),
+	})
+	ts.OK(err)
+	if aws.StringValue(rs.Retention.Mode) != s3.ObjectLockRetentionModeGovernance {
+		t.Fatal("unexpected mode", aws.StringValue(rs.Retention.Mode))
+	}
+	if !aws.TimeValue(rs.Retention.RetainUntilDate).Equal(until) {
+		t.Fatal("unexpected retain until date", aws.TimeValue(rs.Retention.RetainUntilDate))
+	}
+
+	t.Run("past-date", func(t *testing.T) {
+		_, err := svc.PutObjectRetention(&s3.PutObjectRetentionInput{
+			Bucket: aws.String(defaultBucket),
+			Key:    aws.String("object"),
+			Retention: &s3.ObjectLockRetention{
+				Mode:            aws.String(s3.ObjectLockRetentionModeCompliance),
+				RetainUntilDate: aws.Time(defaultDate.Add(-time.Hour)),
+			},
+		})
+		if !hasErrorCode(err, gofakes3.ErrInvalidArgument) {
+			t.Fatal("expected ErrInvalidArgument, found", err)
+		}
+	})
+
+	t.Run("missing-object", func(t *testing.T) {
+		_, err := svc.GetObjectRetention(&s3.GetObjectRetentionInput{
+			Bucket: aws.String(defaultBucket),
+			Key:    aws.String("nope"),
+		})
+		if !hasErrorCode(err, gofakes3.ErrNoSuchKey) {
+			t.Fatal("expected ErrNoSuchKey, found", err)
+		}
+	})
+
+	t.Run("cleared-on-delete", func(t *testing.T) {
+		ts.OKAll(svc.DeleteObject(&s3.DeleteObjectInput{
+			Bucket: aws.String(defaultBucket),
+			Key:    aws.String("object"),
+		}))
+		ts.backendPutString(defaultBucket, "object", nil, "hello")
+
+		_, err := svc.GetObjectRetention(&s3.GetObjectRetentionInput{
+			Bucket: aws.String(defaultBucket),
+			Key:    aws.String("object"),
+		})
+		if !hasErrorCode(err, gofakes3.ErrNoSuchObjectLockConfiguration) {
+			t.Fatal("expected ErrNoSuchObjectLockConfiguration, found", err)
+		}
+	})
+}
+
+func TestObjectLegalHold(t *testing.T) {
+	ts := newTestServer(t)
+	defer ts.Close()
+	svc := ts.s3Client()
+
+	ts.backendPutString(defaultBucket, "object", nil, "hello")
+
+	_, err := svc.GetObjectLegalHold(&s3.GetObjectLegalHoldInput{
+		Bucket: aws.String(defaultBucket),
+		Key:    aws.String("object"),
+	})
+	if !hasErrorCode(err, gofakes3.ErrNoSuchObjectLockConfiguration) {
+		t.Fatal("expected ErrNoSuchObjectLockConfiguration, found", err)
+	}
+
+	ts.OKAll(svc.PutObjectLegalHold(&s3.PutObjectLegalHoldInput{
+		Bucket:    aws.String(defaultBucket),
+		Key:       aws.String("object"),
+		LegalHold: &s3.ObjectLockLegalHold{Status: aws.String(s3.ObjectLockLegalHoldStatusOn)},
+	}))
+
+	rs, err := svc.GetObjectLegalHold(&s3.GetObjectLegalHoldInput{
+		Bucket: aws.String(defaultBucket),
+		Key:    aws.String("object"),
+	})
+	ts.OK(err)
+	if aws.StringValue(rs.LegalHold.Status) != s3.ObjectLockLegalHoldStatusOn {
+		t.Fatal("unexpected status", aws.StringValue(rs.LegalHold.Status))
+	}
+}
+
+func TestObjectLockConfiguration(t *testing.T) {
+	ts := newTestServer(t)
+	defer ts.Close()
+	svc := ts.s3Client()
+
+	// Object Lock can only be configured on buckets created with it:
+	_, err := svc.GetObjectLockConfiguration(&s3.GetObjectLockConfigurationInput{Bucket: aws.String(defaultBucket)})
+	if !hasErrorCode(err, gofakes3.ErrObjectLockConfigurationNotFound) {
+		t.Fatal("expected ErrObjectLockConfigurationNotFound, found", err)
+	}
+	_, err = svc.PutObjectLockConfiguration(&s3.PutObjectLockConfigurationInput{
+		Bucket:                  aws.String(defaultBucket),
+		ObjectLockConfiguration: &s3.ObjectLockConfiguration{ObjectLockEnabled: aws.String(s3.ObjectLockEnabledEnabled)},
+	})
+	if !hasErrorCode(err, gofakes3.ErrInvalidBucketState) {
+		t.Fatal("expected ErrInvalidBucketState, found", err)
+	}
+
+	ts.OKAll(svc.CreateBucket(&s3.CreateBucketInput{
+		Bucket:                     aws.String("locked"),
+		ObjectLockEnabledForBucket: aws.Bool(true),
+	}))
+	config, err := svc.GetObjectLockConfiguration(&s3.GetObjectLockConfigurationInput{Bucket: aws.String("locked")})
+	ts.OK(err)
+	if aws.StringValue(config.ObjectLockConfiguration.ObjectLockEnabled) != s3.ObjectLockEnabledEnabled || config.ObjectLockConfiguration.Rule != nil {
+		t.Fatal("unexpected configuration", config)
+	}
+
+	_, err = svc.PutObjectLockConfiguration(&s3.PutObjectLockConfigurationInput{
+		Bucket: aws.String("locked"),
+		ObjectLockConfiguration: &s3.ObjectLockConfiguration{
+			ObjectLockEnabled: aws.String(s3.ObjectLockEnabledEnabled),
+			Rule: &s3.ObjectLockRule{DefaultRetention: &s3.DefaultRetention{
+				Mode: aws.String(s3.ObjectLockRetentionModeGovernance), Days: aws.Int64(1), Years: aws.Int64(1),
+			}},
+		},
+	})
+	if !hasErrorCode(err, gofakes3.ErrMalformedXML) {
+		t.Fatal("expected ErrMalformedXML, found", err)
+	}
+
+	ts.OKAll(svc.PutObjectLockConfiguration(&s3.PutObjectLockConfigurationInput{
+		Bucket: aws.String("locked"),
+		ObjectLockConfiguration: &s3.ObjectLockConfiguration{
+			ObjectLockEnabled: aws.String(s3.ObjectLockEnabledEnabled),
+			Rule: &s3.ObjectLockRule{DefaultRetention: &s3.DefaultRetention{
+				Mode: aws.String(s3.ObjectLockRetentionModeGovernance), Days: aws.Int64(1),
+			}},
+		},
+	}))
+
+	assertRetention := func(key, mode string, until time.Time) {
+		t.Helper()
+		rs, err := svc.GetObjectRetention(&s3.GetObjectRetentionInput{Bucket: aws.String("locked"), Key: aws.String(key)})
+		ts.OK(err)
+		if aws.StringValue(rs.Retention.Mode) != mode || !aws.TimeValue(rs.Retention.RetainUntilDate).Equal(until) {
+			t.Fatal("unexpected retention", rs.Retention)
+		}
+		head, err := svc.HeadObject(&s3.HeadObjectInput{Bucket: aws.String("locked"), Key: aws.String(key)})
+		ts.OK(err)
+		if aws.StringValue(head.ObjectLockMode) != mode || !aws.TimeValue(head.ObjectLockRetainUntilDate).Equal(until) {
+			t.Fatal("unexpected retention headers", head)
+		}
+	}
+
+	// New objects inherit the default retention, unless they ask for their
+	// own:
+	ts.OKAll(svc.PutObject(&s3.PutObjectInput{
+		Bucket: aws.String("locked"),
+		Key:    aws.String("default"),
+		Body:   strings.NewReader("hello"),
+	}))
+	assertRetention("default", s3.ObjectLockRetentionModeGovernance, defaultDate.AddDate(0, 0, 1))
+
+	until := defaultDate.Add(time.Hour)
+	ts.OKAll(svc.PutObject(&s3.PutObjectInput{
+		Bucket:                    aws.String("locked"),
+		Key:                       aws.String("explicit"),
+		Body:                      strings.NewReader("hello"),
+		ObjectLockMode:            aws.String(s3.ObjectLockModeCompliance),
+		ObjectLockRetainUntilDate: aws.Time(until),
+	}))
+	assertRetention("explicit", s3.ObjectLockRetentionModeCompliance, until)
+
+	// Versioning was enabled with Object Lock, and cannot be suspended:
+	rs, err := svc.GetBucketVersioning(&s3.GetBucketVersioningInput{Bucket: aws.String("locked")})
+	ts.OK(err)
+	if aws.StringValue(rs.Status) != s3.BucketVersioningStatusEnabled {
+		t.Fatal("unexpected versioning status", aws.StringValue(rs.Status))
+	}
+	_, err = svc.PutBucketVersioning(&s3.PutBucketVersioningInput{
+		Bucket:                  aws.String("locked"),
+		VersioningConfiguration: &s3.VersioningConfiguration{Status: aws.String(s3.BucketVersioningStatusSuspended)},
+	})
+	if !hasErrorCode(err, gofakes3.ErrInvalidBucketState) {
+		t.Fatal("expected ErrInvalidBucketState, found", err)
+	}
+
+	// Retention can't be requested in buckets without Object Lock:
+	_, err = svc.PutObject(&s3.PutObjectInput{
+		Bucket:                    aws.String(defaultBucket),
+		Key:                       aws.String("explicit"),
+		Body:                      strings.NewReader("hello"),
+		ObjectLockMode:            aws.String(s3.ObjectLockModeCompliance),
+		ObjectLockRetainUntilDate: aws.Time(until),
+	})
+	if !hasErrorCode(err, gofakes3.ErrInvalidRequest) {
+		t.Fatal("expected ErrInvalidRequest, found", err)
+	}
+}
+
+func TestDeleteObjectsObjectLock(t *testing.T) {
+	ts := newTestServer(t)
+	defer ts.Close()
+	svc := ts.s3Client()
+
+	ts.OKAll(svc.CreateBucket(&s3.CreateBucketInput{
+		Bucket:                     aws.String("locked"),
+		ObjectLockEnabledForBucket: aws.Bool(true),
+	}))
+
+	put := func(key, mode string) *s3.ObjectIdentifier {
+		t.Helper()
+		in := &s3.PutObjectInput{
+			Bucket: aws.String("locked"),
+			Key:    aws.String(key),
+			Body:   strings.NewReader("hello"),
+		}
+		if mode != "" {
+			in.ObjectLockMode = aws.String(mode)
+			in.ObjectLockRetainUntilDate = aws.Time(defaultDate.Add(time.Hour))
+		}
+		out, err := svc.PutObject(in)
+		ts.OK(err)
+		return &s3.ObjectIdentifier{Key: aws.String(key), VersionId: out.VersionId}
+	}
+	plain := put("plain", "")
+	governance := put("governance", s3.ObjectLockModeGovernance)
+	compliance := put("compliance", s3.ObjectLockModeCompliance)
+	held := put("held", "")
+	ts.OKAll(svc.PutObjectLegalHold(&s3.PutObjectLegalHoldInput{
+		Bucket:    aws.String("locked"),
+		Key:       aws.String("held"),
+		LegalHold: &s3.ObjectLockLegalHold{Status: aws.String(s3.ObjectLockLegalHoldStatusOn)},
+	}))
+
+	deleteObjects := func(bypass bool, objects ...*s3.ObjectIdentifier) (deleted, denied []string) {
+		t.Helper()
+		out, err := svc.DeleteObjects(&s3.DeleteObjectsInput{
+			Bucket:                    aws.String("locked"),
+			Delete:                    &s3.Delete{Objects: objects},
+			BypassGovernanceRetention: aws.Bool(bypass),
+		})
+		ts.OK(err)
+		for _, d := range out.Deleted {
+			deleted = append(deleted, aws.StringValue(d.Key))
+		}
+		for _, e := range out.Errors {
+			if aws.StringValue(e.Code) != string(gofakes3.ErrAccessDenied) {
+				t.Fatal("unexpected error", e)
+			}
+			denied = append(denied, aws.StringValue(e.Key))
+		}
+		return deleted, denied
+	}
+
+	deleted, denied := deleteObjects(false, plain, governance, compliance, held)
+	if !reflect.DeepEqual(deleted, []string{"plain"}) || !reflect.DeepEqual(denied, []string{"governance", "compliance", "held"}) {
+		t.Fatal("unexpected result", deleted, denied)
+	}
+
+	deleted, denied = deleteObjects(true, governance, compliance, held)
+	if !reflect.DeepEqual(deleted, []string{"governance"}) || !reflect.DeepEqual(denied, []string{"compliance", "held"}) {
+		t.Fatal("unexpected result", deleted, denied)
+	}
+
+	// Without a version, a delete marker is added and the locked version is
+	// left as it was:
+	deleted, denied = deleteObjects(false, &s3.ObjectIdentifier{Key: aws.String("compliance")})
+	if !reflect.DeepEqual(deleted, []string{"compliance"}) || len(denied) != 0 {
+		t.Fatal("unexpected result", deleted, denied)
+	}
+	ts.OKAll(svc.HeadObject(&s3.HeadObjectInput{
+		Bucket:    aws.String("locked"),
+		Key:       aws.String("compliance"),
+		VersionId: compliance.VersionId,
+	}))
+
+	// Once the retention period is over, nothing stops the version being
+	// deleted:
+	ts.Advance(2 * time.Hour)
+	deleted, denied = deleteObjects(false, compliance)
+	if !reflect.DeepEqual(deleted, []string{"compliance"}) || len(denied) != 0 {
+		t.Fatal("unexpected result", deleted, denied)
+	}
+}
@@ -0,0 +1,131 @@
+package gofakes3
+
+import (
+	"net/http"
+	"net/url"
+)
+
+// subresourceOperations maps the subresources in subresourceRoutes, and the
+// method of a request for one, to the operation it invokes. Configurations
+// that are addressed by 'id' are listed by a GET without one, under "LIST".
+// '?acl' names the object operations; bucketACLOperations has the bucket's.
+var subresourceOperations = map[string]map[string]string{
+	"accelerate":          {"GET": "GetBucketAccelerateConfiguration", "PUT": "PutBucketAccelerateConfiguration"},
+	"acl":                 {"GET": "GetObjectAcl", "PUT": "PutObjectAcl"},
+	"analytics":           {"GET": "GetBucketAnalyticsConfiguration", "LIST": "ListBucketAnalyticsConfigurations", "PUT": "PutBucketAnalyticsConfiguration", "DELETE": "DeleteBucketAnalyticsConfiguration"},
+	"attributes":          {"GET": "GetObjectAttributes"},
+	"cors":                {"GET": "GetBucketCors", "PUT": "PutBucketCors", "DELETE": "DeleteBucketCors"},
+	"intelligent-tiering": {"GET": "GetBucketIntelligentTieringConfiguration", "LIST": "ListBucketIntelligentTieringConfigurations", "PUT": "PutBucketIntelligentTieringConfiguration", "DELETE": "DeleteBucketIntelligentTieringConfiguration"},
+	"legal-hold":          {"GET": "GetObjectLegalHold", "PUT": "PutObjectLegalHold"},
+	"lifecycle":           {"GET": "GetBucketLifecycleConfiguration", "PUT": "PutBucketLifecycleConfiguration", "DELETE": "DeleteBucketLifecycle"},
+	"location":            {"GET": "GetBucketLocation"},
+	"metrics":             {"GET": "GetBucketMetricsConfiguration", "LIST": "ListBucketMetricsConfigurations", "PUT": "PutBucketMetricsConfiguration", "DELETE": "DeleteBucketMetricsConfiguration"},
+	"object-lock":         {"GET": "GetObjectLockConfiguration", "PUT": "PutObjectLockConfiguration"},
+	"ownershipControls":   {"GET": "GetBucketOwnershipControls", "PUT": "PutBucketOwnershipControls", "DELETE": "DeleteBucketOwnershipControls"},
+	"publicAccessBlock":   {"GET": "GetPublicAccessBlock", "PUT": "PutPublicAccessBlock", "DELETE": "DeletePublicAccessBlock"},
+	"retention":           {"GET": "GetObjectRetention", "PUT": "PutObjectRetention"},
+	"tagging":             {"GET": "GetObjectTagging", "PUT": "PutObjectTagging", "DELETE": "DeleteObjectTagging"},
+	"versioning":          {"GET": "GetBucketVersioning", "PUT": "PutBucketVersioning"},
+}
+
+var bucketACLOperations = map[string]string{"GET": "GetBucketAcl", "PUT": "PutBucketAcl"}
+
+// requestOperation returns the S3 API action name of the operation a request
+// invokes, as WithOperationLatency and Authorizer expect it. An empty string
+// is returned for requests that don't match an operation, such as those for
+// unsupported subresources.
+func requestOperation(bucket, object string, query url.Values, r *http.Request) string {
+	has := func(name string) bool {
+		_, ok := query[name]
+		return ok
+	}
+
+	switch {
+	case bucket == "":
+		if r.Method == "GET" {
+			return "ListBuckets"
+		}
+
+	case has("uploadId"):
+		switch r.Method {
+		case "GET":
+			return "ListParts"
+		case "PUT":
+			if r.Header.Get("X-Amz-Copy-Source") != "" {
+				return "UploadPartCopy"
+			}
+			return "UploadPart"
+		case "POST":
+			return "CompleteMultipartUpload"
+		case "DELETE":
+			return "AbortMultipartUpload"
+		}
+
+	case has("uploads"):
+		switch r.Method {
+		case "GET":
+			return "ListMultipartUploads"
+		case "POST":
+			return "CreateMultipartUpload"
+		}
+
+	case has("versions") && object == "" && r.Method == "GET":
+		return "ListObjectVersions"
+
+	case has("delete") && object == "" && r.Method == "POST":
+		return "DeleteObjects"
+
+	case hasSubresource(query):
+		name, _ := findSubresource(query)
+		route := subresourceRoutes[name]
+		if (object == "" && route.bucket == nil) || (object != "" && route.object == nil) {
+			return ""
+		}
+		operations := subresourceOperations[name]
+		if object == "" && name == "acl" {
+			operations = bucketACLOperations
+		}
+		if _, ok := operations["LIST"]; ok && r.Method == "GET" && query.Get("id") == "" {
+			return operations["LIST"]
+		}
+		return operations[r.Method]
+
+	case object != "":
+		switch r.Method {
+		case "GET":
+			return "GetObject"
+		case "HEAD":
+			return "HeadObject"
+		case "PUT":
+			if r.Header.Get("X-Amz-Copy-Source") != "" {
+				return "CopyObject"
+			}
+			return "PutObject"
+		case "DELETE":
+			return "DeleteObject"
+		}
+
+	default:
+		switch r.Method {
+		case "GET":
+			if query.Get("list-type") == "2" {
+				return "ListObjectsV2"
+			}
+			return "ListObjects"
+		case "HEAD":
+			return "HeadBucket"
+		case "PUT":
+			return "CreateBucket"
+		case "DELETE":
+			return "DeleteBucket"
+		case "POST":
+			return "PostObject"
+		}
+	}
+	return ""
+}
+
+func hasSubresource(query url.Values) bool {
+	_, ok := findSubresource(query)
+	return ok
+}
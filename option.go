@@ -1,12 +1,18 @@
 package gofakes3
 
-import "time"
+import (
+	"math/rand"
+	"net/http"
+	"strings"
+	"time"
+)
 
 type Option func(g *GoFakeS3)
 
 // WithTimeSource allows you to substitute the behaviour of time.Now() and
 // time.Since() within GoFakeS3. This can be used to trigger time skew errors,
-// or to ensure the output of the commands is deterministic.
+// or to ensure the output of the commands is deterministic. A TimeSource that
+// implements TimeSourceSleeper is also used to wait out simulated delays.
 //
 // See gofakes3.FixedTimeSource(), gofakes3.LocalTimeSource(tz).
 func WithTimeSource(timeSource TimeSource) Option {
@@ -32,6 +38,15 @@ func WithMetadataSizeLimit(size int) Option {
 	return func(g *GoFakeS3) { g.metadataSizeLimit = size }
 }
 
+// WithMetadataCountLimit allows you to cap the number of user-defined
+// metadata entries ('x-amz-meta-*' headers) that may be attached to an
+// object. Exceeding the limit results in ErrMetadataTooLarge.
+//
+// The default is '0', which disables the limit.
+func WithMetadataCountLimit(count int) Option {
+	return func(g *GoFakeS3) { g.metadataCountLimit = count }
+}
+
 // WithIntegrityCheck enables or disables Content-MD5 validation when
 // putting an Object.
 func WithIntegrityCheck(check bool) Option {
@@ -66,6 +81,17 @@ func WithHostBucket(enabled bool) Option {
 	return func(g *GoFakeS3) { g.hostBucket = enabled }
 }
 
+// WithPreserveTrailingSlash keeps a trailing slash in the request path as
+// part of the object's key, so that folder marker objects like 'folder/' can
+// be created and retrieved. A request for '/mybucket/' still addresses the
+// bucket.
+//
+// By default, GoFakeS3 strips the trailing slash, so '/mybucket/folder/'
+// addresses the key 'folder'.
+func WithPreserveTrailingSlash() Option {
+	return func(g *GoFakeS3) { g.preserveTrailingSlash = true }
+}
+
 // WithoutVersioning disables versioning on the passed backend, if it supported it.
 func WithoutVersioning() Option {
 	return func(g *GoFakeS3) { g.versioned = nil }
@@ -86,3 +112,590 @@ func WithUnimplementedPageError() Option {
 func WithAutoBucket(enabled bool) Option {
 	return func(g *GoFakeS3) { g.autoBucket = enabled }
 }
+
+// WithRequireContentLength makes PUT requests for objects and multipart upload
+// parts fail with ErrMissingContentLength if they have no Content-Length
+// header, as S3 does. Streaming 'aws-chunked' uploads of both are exempt, as
+// they provide the decoded length in 'x-amz-decoded-content-length'.
+//
+// By default, GoFakeS3 tolerates a missing Content-Length by buffering the
+// body to find its size.
+func WithRequireContentLength() Option {
+	return func(g *GoFakeS3) { g.requireContentLength = true }
+}
+
+// WithContentTypeSniffing chooses a Content-Type for objects created without
+// one from the key's file extension, or failing that from the first 512 bytes
+// of the object, using http.DetectContentType. This is convenient for serving
+// static fixtures.
+//
+// By default, GoFakeS3 stores DefaultContentType, as S3 does.
+func WithContentTypeSniffing() Option {
+	return func(g *GoFakeS3) { g.contentTypeSniffing = true }
+}
+
+// WithMaxConcurrentRequests caps the number of requests GoFakeS3 will handle
+// at once. Requests beyond the limit are not queued; they fail immediately
+// with ErrSlowDown and a 'Retry-After' header, like a throttled S3 endpoint.
+// The limit can be changed later with GoFakeS3.SetMaxConcurrentRequests().
+//
+// The default is '0', which disables the limit.
+func WithMaxConcurrentRequests(n int) Option {
+	return func(g *GoFakeS3) { g.maxConcurrentRequests = int64(n) }
+}
+
+// WithTTLSweepInterval starts a background sweeper that deletes objects whose
+// TTL has lapsed (see TTLHeader) at the given interval. Expiry is checked
+// against the configured TimeSource, but the interval itself uses the real
+// clock. Call GoFakeS3.Close() to stop the sweeper.
+//
+// The sweeper is disabled by default; expired objects are still hidden from
+// GET and HEAD requests without it.
+func WithTTLSweepInterval(interval time.Duration) Option {
+	return func(g *GoFakeS3) { g.ttlSweepInterval = interval }
+}
+
+// WithBandwidthLimit throttles both request and response bodies to
+// bytesPerSec, which is useful for exercising upload and download progress
+// reporting and timeouts. Each request is throttled independently. A
+// throttled transfer is abandoned if the request's context is cancelled.
+//
+// Use WithUploadBandwidthLimit and WithDownloadBandwidthLimit to throttle
+// only one direction. The default is '0', which disables throttling.
+func WithBandwidthLimit(bytesPerSec int) Option {
+	return func(g *GoFakeS3) {
+		g.uploadBandwidth = bytesPerSec
+		g.downloadBandwidth = bytesPerSec
+	}
+}
+
+// WithUploadBandwidthLimit throttles request bodies, like the body of a PUT,
+// to bytesPerSec. See WithBandwidthLimit.
+func WithUploadBandwidthLimit(bytesPerSec int) Option {
+	return func(g *GoFakeS3) { g.uploadBandwidth = bytesPerSec }
+}
+
+// WithDownloadBandwidthLimit throttles response bodies, like the body of a
+// GET, to bytesPerSec. See WithBandwidthLimit.
+func WithDownloadBandwidthLimit(bytesPerSec int) Option {
+	return func(g *GoFakeS3) { g.downloadBandwidth = bytesPerSec }
+}
+
+// WithRedirect makes requests whose path starts with pathPrefix, like
+// '/mybucket/' or '/mybucket/some/prefix', respond with an empty redirect
+// instead of being handled. This can be used to test how clients follow
+// S3's region and transfer acceleration redirects.
+//
+// The 'Location' header is target, which should be a base URL like
+// 'http://other-endpoint:9000', followed by the request's path and query
+// string. code may be http.StatusPermanentRedirect (308); any other value
+// sends http.StatusTemporaryRedirect (307), as S3 does.
+//
+// WithRedirect may be passed more than once; the first matching prefix wins.
+func WithRedirect(pathPrefix, target string, code int) Option {
+	if code != http.StatusPermanentRedirect {
+		code = http.StatusTemporaryRedirect
+	}
+	return func(g *GoFakeS3) {
+		g.redirects = append(g.redirects, redirectRule{pathPrefix: pathPrefix, target: target, code: code})
+	}
+}
+
+// WithTruncatedBodies simulates downloads that are cut short: GET object
+// responses for keys matching pattern, a path.Match pattern such as
+// 'corrupt/*', stop after the given fraction of the body, between 0 and 1,
+// while the Content-Length and ETag headers still describe all of it. This is
+// intended for testing that clients detect the length or ETag mismatch.
+//
+// The stored objects are not changed, so this only affects GET object; HEAD
+// object, CopyObject and listings report the objects as they are. The same
+// number of bytes is always sent for the same key and range.
+//
+// WithTruncatedBodies may be passed more than once, and along with
+// WithStalledBodies; the first matching pattern wins.
+func WithTruncatedBodies(pattern string, fraction float64) Option {
+	if fraction < 0 {
+		fraction = 0
+	} else if fraction > 1 {
+		fraction = 1
+	}
+	return func(g *GoFakeS3) {
+		g.truncateRules = append(g.truncateRules, truncateRule{pattern: pattern, fraction: fraction})
+	}
+}
+
+// WithListingLag simulates the list-after-write lag of S3's old eventually
+// consistent listings: ListObjects and ListObjectsV2 leave out objects whose
+// last modified time is less than lag before the TimeSource's current time,
+// while GET and HEAD object find them straight away. Overwriting an object
+// hides it again. Other listings, such as ListObjectVersions, are not
+// affected.
+//
+// Pages are filtered after the Backend has filled them, so a page may hold
+// fewer than max-keys objects, or none, while still being truncated.
+func WithListingLag(lag time.Duration) Option {
+	return func(g *GoFakeS3) { g.listingLag = lag }
+}
+
+// WithNotFoundDelay simulates a slow lookup of keys that don't exist: GET and
+// HEAD object requests for a key whose 'bucket/key' path matches pattern, a
+// path.Match pattern such as 'mybucket/missing/*', wait for delay before
+// failing with ErrNoSuchKey. Requests for keys that exist are not delayed.
+//
+// WithNotFoundDelay may be passed more than once; the first matching pattern
+// wins.
+func WithNotFoundDelay(pattern string, delay time.Duration) Option {
+	return func(g *GoFakeS3) {
+		g.notFoundDelays = append(g.notFoundDelays, notFoundDelayRule{pattern: pattern, delay: delay})
+	}
+}
+
+// WithBucketBackend stores the named bucket in backend rather than in the
+// Backend passed to New, so that buckets with different characteristics can
+// be served side by side; buckets that are not mapped stay in the default
+// Backend. The bucket is created in backend like any other, for example with
+// CreateBucket. WithBucketBackend may be passed more than once, and several
+// buckets may share a Backend.
+//
+// Versioning is only available if the default Backend supports it. Versioned
+// operations on a bucket whose own Backend does not support them fail with
+// ErrNotImplemented.
+func WithBucketBackend(name string, backend Backend) Option {
+	return func(g *GoFakeS3) {
+		if g.bucketBackends == nil {
+			g.bucketBackends = make(map[string]Backend)
+		}
+		g.bucketBackends[name] = backend
+	}
+}
+
+// WithAdminUI serves GoFakeS3's AdminHandler from Server, under pathPrefix,
+// e.g. '/_admin'. The prefix should not be a valid bucket name, or the bucket
+// will be unreachable; the underscore in '/_admin' ensures this.
+func WithAdminUI(pathPrefix string) Option {
+	return func(g *GoFakeS3) { g.adminPrefix = "/" + strings.Trim(pathPrefix, "/") }
+}
+
+// WithRequestCapture calls capture with the details of every request once it
+// has been handled, including the hash of its body, so that tests can assert
+// exactly what a client sent. capture may be called concurrently.
+//
+// Requests turned away before they are routed, by WithRedirect or by
+// WithTimeSkewLimit, are not passed to capture.
+func WithRequestCapture(capture func(CapturedRequest)) Option {
+	return func(g *GoFakeS3) { g.requestCapture = capture }
+}
+
+// WithSubresourceHandler routes every request whose query string contains
+// name, such as '?x-custom', to handler, for buckets and objects alike. This
+// can be used to simulate the extra endpoints of other S3 implementations,
+// like MinIO or Ceph. handler takes precedence over GoFakeS3's own handling
+// of the request, even if name is a subresource GoFakeS3 implements.
+//
+// WithSubresourceHandler may be passed more than once; if a request contains
+// several registered names, the first one registered wins.
+func WithSubresourceHandler(name string, handler SubresourceHandler) Option {
+	return func(g *GoFakeS3) {
+		g.subresourceHandlers = append(g.subresourceHandlers, subresourceHandler{name: name, handler: handler})
+	}
+}
+
+// WithDefaultOwner sets the owner reported for buckets, and for objects
+// created by requests that WithOwners does not attribute to another owner.
+func WithDefaultOwner(owner UserInfo) Option {
+	return func(g *GoFakeS3) { g.defaultOwner = owner }
+}
+
+// WithOwners attributes objects to the owner registered for the access key ID
+// the creating request was signed with. The owner is reported in object
+// listings and in the object's ACL. Requests signed with other access keys,
+// and anonymous requests, create objects owned by the default owner.
+//
+// Unless WithSignatureVerification is passed, signatures are not verified, so
+// the access key only identifies the principal; this is intended to simulate
+// several users in tests.
+func WithOwners(owners map[string]UserInfo) Option {
+	return func(g *GoFakeS3) { g.owners = owners }
+}
+
+// WithAnonymousAccessControl denies anonymous requests, those that carry no
+// credentials, unless they read an object whose ACL grants READ to the
+// AllUsers group, such as one created with the 'public-read' canned ACL.
+// Public ACLs are not honoured in buckets whose PublicAccessBlockConfiguration
+// sets IgnorePublicAcls.
+//
+// As with WithOwners, signatures are not verified unless
+// WithSignatureVerification is passed: any request with an access key is
+// treated as authenticated and allowed. Bucket policies are not
+// supported, so anonymous requests can never list or write to a bucket.
+func WithAnonymousAccessControl() Option {
+	return func(g *GoFakeS3) { g.anonymousAccessControl = true }
+}
+
+// WithAuthorizer consults authorizer before carrying out every request,
+// including those WithAnonymousAccessControl has let through, and fails the
+// request with ErrAccessDenied if it returns an error.
+//
+// As with WithOwners, signatures are not verified unless
+// WithSignatureVerification is passed, so the principal is otherwise only the
+// access key ID the request claims to be signed with.
+func WithAuthorizer(authorizer Authorizer) Option {
+	return func(g *GoFakeS3) { g.authorizer = authorizer }
+}
+
+// WithSyntheticTree populates the bucket, which is created if it does not
+// exist, with a hierarchy of fanout^depth objects when the GoFakeS3 is
+// created. This is a fixture for exercising prefix and delimiter listings at
+// scale, such as in benchmarks, and is not part of the S3 API.
+//
+// The keys are deterministic: each is depth segments separated by '/', and
+// each segment is a zero-padded index from 0 to fanout-1, for example
+// '0/3/12' in a tree with depth 3 and a fanout of 20. Each object contains its
+// own key. New panics if the Backend cannot be populated.
+func WithSyntheticTree(bucket string, depth, fanout int) Option {
+	return func(g *GoFakeS3) {
+		g.syntheticTrees = append(g.syntheticTrees, syntheticTree{bucket: bucket, depth: depth, fanout: fanout})
+	}
+}
+
+// WithBucketDefaultMetadata adds user-defined metadata to every object written
+// to bucket that does not already have it, as if the client had sent it. The
+// keys of md may be given with or without the 'x-amz-meta-' prefix. Metadata
+// sent by the client takes precedence, as does metadata CopyObject keeps from
+// the source object. This can be used to simulate buckets whose policies
+// require certain metadata.
+//
+// The defaults are not counted against WithMetadataSizeLimit or
+// WithMetadataCountLimit. WithBucketDefaultMetadata may be passed more than
+// once for the same bucket; later values win.
+func WithBucketDefaultMetadata(bucket string, md map[string]string) Option {
+	return func(g *GoFakeS3) {
+		if g.bucketDefaultMetadata == nil {
+			g.bucketDefaultMetadata = make(map[string]map[string]string)
+		}
+		defaults := g.bucketDefaultMetadata[bucket]
+		if defaults == nil {
+			defaults = make(map[string]string)
+			g.bucketDefaultMetadata[bucket] = defaults
+		}
+		for k, v := range md {
+			if !isMetadataKey(k) {
+				k = metadataKeyPrefix + k
+			}
+			defaults[canonicalMetadataKey(k)] = v
+		}
+	}
+}
+
+// WithWriteByteLimit simulates a Backend that runs out of space: once more
+// than n bytes in total have been written by PutObject, POST uploads,
+// CopyObject and UploadPart, the write under way and every later one fails
+// with ErrInternal. The failing write stores nothing, and objects stored
+// before it are left as they were. Completing a multipart upload does not
+// count its parts again.
+//
+// Deleting objects does not free any space; the count only ever grows.
+func WithWriteByteLimit(n int64) Option {
+	return func(g *GoFakeS3) { g.writeByteLimit = n }
+}
+
+// WithMinPartSize sets the size every part of a multipart upload but the
+// last must be for CompleteMultipartUpload to succeed, which is
+// DefaultUploadPartSize if this is not passed. Tests can lower it to
+// assemble uploads from tiny parts; 0 disables the check.
+func WithMinPartSize(bytes int64) Option {
+	return func(g *GoFakeS3) { g.minPartSize = bytes }
+}
+
+// WithOperationLatency delays every request for the operation, named by its
+// S3 API action such as 'GetObject', 'PutObject' or 'ListObjectsV2', by the
+// duration latency returns, for example UniformLatency(10*time.Millisecond,
+// 50*time.Millisecond). AnyOperation sets the latency of the operations that
+// are not given their own.
+//
+// Requests for subresources that GoFakeS3 does not support only use the
+// AnyOperation latency.
+func WithOperationLatency(operation string, latency LatencyFunc) Option {
+	return func(g *GoFakeS3) {
+		if g.latencies.funcs == nil {
+			g.latencies.funcs = make(map[string]LatencyFunc)
+		}
+		g.latencies.funcs[operation] = latency
+	}
+}
+
+// WithLatencySeed seeds the random source passed to the LatencyFuncs given to
+// WithOperationLatency, so that the same requests, made in the same order,
+// are delayed by the same durations.
+func WithLatencySeed(seed int64) Option {
+	return func(g *GoFakeS3) { g.latencies.rnd = rand.New(rand.NewSource(seed)) }
+}
+
+// WithGzipErrors gzip-encodes the body of error responses when the request's
+// Accept-Encoding header allows it, and sets Content-Encoding and
+// Content-Length to match. Successful responses are never compressed, as S3
+// only returns objects with the Content-Encoding they were stored with.
+func WithGzipErrors() Option {
+	return func(g *GoFakeS3) { g.gzipErrors = true }
+}
+
+// WithWeakETags makes GET and HEAD object requests return weak ETags, like
+// W/"<md5>", for objects larger than size bytes, as a proxy that cannot
+// promise byte-for-byte identical responses would. If-None-Match compares
+// these ETags weakly, while If-Match and If-Range, which need a strong
+// validator, never match them; If-Range then sends the whole object.
+//
+// Other responses, such as listings and PutObject, still return strong
+// ETags.
+func WithWeakETags(size int64) Option {
+	return func(g *GoFakeS3) { g.weakETagSize = size }
+}
+
+// WithEchoHeaders copies every header of every request onto its response,
+// prefixed with EchoHeaderPrefix, e.g. 'X-Gofakes3-Echo-Content-Type', so
+// that tests can confirm which headers a client sends, such as those used for
+// signing or server-side encryption. It is a debugging aid; S3 sends nothing
+// like it.
+func WithEchoHeaders() Option {
+	return func(g *GoFakeS3) { g.echoHeaders = true }
+}
+
+// WithBucketRegion places the named bucket in region, such as 'eu-west-1', so
+// that clients' endpoint resolution can be tested. Requests for the bucket
+// that arrive through another region's endpoint, with a host like
+// 's3.us-west-2.localhost' or 'mybucket.s3-us-west-2.localhost', are answered
+// with a 301 PermanentRedirect error naming the bucket's endpoint. Requests
+// signed with Signature Version 4 for another region fail with
+// ErrAuthorizationHeaderMalformed, or ErrAuthorizationQueryParametersError
+// for presigned URLs, naming the bucket's region in the 'Region' element.
+//
+// Hosts that don't name a region, like 's3.localhost', and unsigned requests
+// are accepted. The bucket's region is returned by GetBucketLocation and in
+// the BucketRegionHeader. WithBucketRegion may be
+// passed more than once; buckets that are not given a region may be used from
+// any region.
+func WithBucketRegion(bucket, region string) Option {
+	return func(g *GoFakeS3) {
+		if g.bucketRegions == nil {
+			g.bucketRegions = make(map[string]string)
+		}
+		g.bucketRegions[bucket] = region
+	}
+}
+
+// WithCreationTimes makes GET and HEAD object requests return the time each
+// key was first written in the CreatedHeader, so that tests can tell an
+// object that was created from one that was overwritten. Overwriting or
+// copying over an object keeps its creation time, while 'Last-Modified' is
+// updated as usual; once the object is deleted, the next write creates it
+// again.
+//
+// Only writes made through GoFakeS3 are tracked, so objects put straight into
+// the Backend have no creation time. The header is left out for them.
+func WithCreationTimes() Option {
+	return func(g *GoFakeS3) { g.creationTimes = true }
+}
+
+// WithHealthCheck answers GET and HEAD requests for path, such as
+// DefaultHealthCheckPath, with a plain '200 OK', so that GoFakeS3 can be put
+// behind a load balancer that probes its targets. Health checks bypass the S3
+// API entirely; only the exact path is intercepted, so other requests are
+// routed as usual. Once Shutdown is called, health checks fail with '503
+// Service Unavailable'.
+//
+// If path is empty, DefaultHealthCheckPath is used. It should not be a valid
+// bucket name, or the bucket's root will be unreachable.
+func WithHealthCheck(path string) Option {
+	if path == "" {
+		path = DefaultHealthCheckPath
+	}
+	return func(g *GoFakeS3) { g.healthCheckPath = path }
+}
+
+// WithStrictQueryParameters rejects requests with a query parameter that
+// their operation does not honour with ErrInvalidArgument, naming the
+// parameter, rather than ignoring it. This catches clients relying on
+// parameters that GoFakeS3, and perhaps S3, would not act on, such as
+// 'versionId' on a PUT object. The parameters of presigned URLs are accepted
+// with any operation.
+//
+// Requests for subresources without a route still fail with
+// ErrNotImplemented, and subresources added with WithSubresourceHandler are
+// not checked.
+func WithStrictQueryParameters() Option {
+	return func(g *GoFakeS3) { g.strictQueryParameters = true }
+}
+
+// WithStoreTransform passes the contents of every object GoFakeS3 writes to
+// the Backend through transform, to simulate storage that changes data at
+// rest, for example by compressing, encrypting or corrupting it. Use
+// WithLoadTransform to undo the change when objects are read. The ETag is
+// still the MD5 hash of the untransformed contents, so conditional requests
+// work as usual.
+//
+// Objects put straight into the Backend, or assembled from a multipart upload
+// by a Backend that implements MultipartBackend, are not transformed, and are
+// returned as they are. Listings report the size and ETag of the stored
+// contents.
+func WithStoreTransform(transform TransformFunc) Option {
+	return func(g *GoFakeS3) { g.storeTransform = transform }
+}
+
+// WithLoadTransform passes the contents of every object written with
+// WithStoreTransform in effect through transform when it is read, by GET
+// object or as the source of a copy. Ranges are taken from the transformed
+// contents, and the 'Content-Length' is their size.
+func WithLoadTransform(transform TransformFunc) Option {
+	return func(g *GoFakeS3) { g.loadTransform = transform }
+}
+
+// WithSignatureVerification verifies the signature of every signed request,
+// using the secret access keys in secrets, indexed by access key ID. Requests
+// signed with Signature Version 4 or 2, in either the 'Authorization' header
+// or the query string of a presigned URL, fail with ErrSignatureDoesNotMatch
+// if the signature is wrong, and with ErrInvalidAccessKeyId if the access key
+// isn't in secrets. Presigned URLs also fail once they expire.
+//
+// Anonymous requests are not affected; see WithAnonymousAccessControl. The
+// bodies of Version 4 requests are not checked against the payload hash they
+// were signed with, and neither are the signatures of the chunks of a
+// streaming upload.
+func WithSignatureVerification(secrets map[string]string) Option {
+	return func(g *GoFakeS3) { g.signatureSecrets = secrets }
+}
+
+// WithSignatureDebugging helps to find out why WithSignatureVerification
+// rejects a request. When a signature does not match, the canonical request
+// and string to sign GoFakeS3 calculated it from are logged at LogWarn, and
+// returned in the 'CanonicalRequest' and 'StringToSign' elements of the error,
+// along with the 'SignatureProvided', as S3 does. Secret keys are never
+// included, but these do reveal the request's headers, so this should only be
+// used while debugging a client.
+func WithSignatureDebugging() Option {
+	return func(g *GoFakeS3) { g.signatureDebugging = true }
+}
+
+// WithAllowedRegions makes CreateBucket fail with ErrInvalidLocationConstraint
+// unless the LocationConstraint in its CreateBucketConfiguration is one of
+// regions. Requests without a CreateBucketConfiguration, or with an empty
+// LocationConstraint, create the bucket in us-east-1, which must be allowed
+// for them to succeed. WithAllowedRegions may be passed more than once.
+//
+// By default, any LocationConstraint is accepted, and none is recorded.
+func WithAllowedRegions(regions ...string) Option {
+	return func(g *GoFakeS3) {
+		if g.allowedRegions == nil {
+			g.allowedRegions = make(map[string]bool)
+		}
+		for _, region := range regions {
+			g.allowedRegions[region] = true
+		}
+	}
+}
+
+// WithBucketThroughputLimit throttles the bodies of requests for the objects
+// in bucket, such as GET and PUT object and UploadPart, to simulate buckets
+// with different performance. Unlike WithBandwidthLimit, the limits are
+// shared by all of the bucket's requests: readBytesPerSec is the combined
+// rate of their response bodies, and writeBytesPerSec that of their request
+// bodies. A limit of 0 is not enforced.
+//
+// Each bucket's limits are independent of other buckets', and of the limits
+// set by WithBandwidthLimit, which also apply. A request whose context is
+// cancelled stops waiting. WithBucketThroughputLimit may be passed more than
+// once, for different buckets.
+func WithBucketThroughputLimit(bucket string, readBytesPerSec, writeBytesPerSec int) Option {
+	return func(g *GoFakeS3) {
+		if g.bucketThroughput == nil {
+			g.bucketThroughput = make(map[string]*bucketThroughput)
+		}
+		limits := &bucketThroughput{}
+		if readBytesPerSec > 0 {
+			limits.read = newSharedTokenBucket(readBytesPerSec)
+		}
+		if writeBytesPerSec > 0 {
+			limits.write = newSharedTokenBucket(writeBytesPerSec)
+		}
+		g.bucketThroughput[bucket] = limits
+	}
+}
+
+// WithStalledBodies is like WithTruncatedBodies, but rather than closing the
+// connection as soon as the given fraction of the body has been sent, GoFakeS3
+// waits for stall first, to simulate a connection that hangs part way through
+// a download before it drops. This is intended for testing clients' read
+// timeouts and retries.
+//
+// The bytes sent before the stall are flushed to the client. Rules added by
+// WithStalledBodies and WithTruncatedBodies are checked together; the first
+// matching pattern wins.
+func WithStalledBodies(pattern string, fraction float64, stall time.Duration) Option {
+	if fraction < 0 {
+		fraction = 0
+	} else if fraction > 1 {
+		fraction = 1
+	}
+	return func(g *GoFakeS3) {
+		g.truncateRules = append(g.truncateRules, truncateRule{pattern: pattern, fraction: fraction, stall: stall})
+	}
+}
+
+// WithUploadIDGenerator replaces the IDs GoFakeS3 gives new multipart
+// uploads, which by default count up from 1, so that tests can assert on
+// them, or make them look more like S3's. The generator is called once for
+// each CreateMultipartUpload request, and must not return an ID that is in
+// use by another upload: if it does, the request fails.
+//
+// Only uploads GoFakeS3 keeps itself are affected; a Backend that implements
+// MultipartBackend chooses its own IDs.
+func WithUploadIDGenerator(generate UploadIDGenerator) Option {
+	return func(g *GoFakeS3) { g.uploadIDGenerator = generate }
+}
+
+// WithMaxUploadParts sets the highest part number UploadPart accepts, and so
+// the most parts a CompleteMultipartUpload request may list, which is
+// MaxUploadPartNumber if this is not passed. Tests can lower it to exercise
+// the limit without uploading 10,000 parts; it cannot be raised, as the
+// uploader always rejects part numbers above MaxUploadPartNumber.
+func WithMaxUploadParts(n int) Option {
+	return func(g *GoFakeS3) { g.maxUploadParts = n }
+}
+
+// WithFirstByteDelay simulates a slow time to first byte: GET object responses
+// for a key whose 'bucket/key' path matches pattern, a path.Match pattern such
+// as 'mybucket/large/*', send their headers straight away, then wait for delay
+// before sending the body. Unlike WithOperationLatency, which delays the whole
+// response, this lets tests exercise a client's first-byte timeout.
+//
+// WithFirstByteDelay may be passed more than once; the first matching pattern
+// wins.
+func WithFirstByteDelay(pattern string, delay time.Duration) Option {
+	return func(g *GoFakeS3) {
+		g.firstByteDelays = append(g.firstByteDelays, firstByteDelayRule{pattern: pattern, delay: delay})
+	}
+}
+
+// WithLifecycleSweepInterval starts a background sweeper that deletes objects
+// expired by the Expiration actions of their bucket's lifecycle configuration,
+// honouring each rule's prefix, tag and size filters. Expiry is checked
+// against the configured TimeSource, but the interval itself uses the real
+// clock. Call GoFakeS3.Close() to stop the sweeper.
+//
+// The sweeper is disabled by default, in which case lifecycle rules are only
+// reported by the 'x-amz-expiration' header. See
+// GoFakeS3.SweepLifecycleExpirations to sweep on demand.
+func WithLifecycleSweepInterval(interval time.Duration) Option {
+	return func(g *GoFakeS3) { g.lifecycleSweepInterval = interval }
+}
+
+// WithDecodedContentLengthCheck makes streaming 'aws-chunked' uploads of
+// objects and parts fail with ErrIncompleteBody if the payload left once the
+// chunk framing is removed is not the size given by
+// 'x-amz-decoded-content-length', which catches client framing bugs whatever
+// the Backend.
+//
+// Streaming uploads are always stored with the decoded size; by default,
+// GoFakeS3 leaves any mismatch for the Backend to notice.
+func WithDecodedContentLengthCheck() Option {
+	return func(g *GoFakeS3) { g.decodedLengthCheck = true }
+}
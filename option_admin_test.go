@@ -0,0 +1,56 @@
+package gofakes3_test
+
+import (
+	"io/ioutil"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/johannesboyne/gofakes3"
+)
+
+func TestAdminUI(t *testing.T) {
+	ts := newTestServer(t, withFakerOptions(gofakes3.WithAdminUI("/_admin/")))
+	defer ts.Close()
+	ts.backendPutString(defaultBucket, "dir/a&b.txt", nil, "hello")
+
+	client := httpClient()
+	get := func(path string, code int) string {
+		t.Helper()
+		rs, err := client.Get(ts.url(path))
+		ts.OK(err)
+		defer rs.Body.Close()
+		body, err := ioutil.ReadAll(rs.Body)
+		ts.OK(err)
+		if rs.StatusCode != code {
+			t.Fatal("unexpected status", rs.StatusCode, string(body))
+		}
+		return string(body)
+	}
+
+	if body := get("/_admin", 200); !strings.Contains(body, "bucket?bucket="+defaultBucket) {
+		t.Fatal("bucket not listed", body)
+	}
+
+	objectQuery := "bucket=" + defaultBucket + "&key=" + url.QueryEscape("dir/a&b.txt")
+	body := get("/_admin/bucket?bucket="+defaultBucket, 200)
+	if !strings.Contains(body, "dir/a&amp;b.txt") || !strings.Contains(body, "5d41402abc4b2a76b9719d911017c592") {
+		t.Fatal("object not listed", body)
+	}
+	if body := get("/_admin/object?"+objectQuery, 200); body != "hello" {
+		t.Fatal("unexpected download", body)
+	}
+	get("/_admin/bucket?bucket=nope", 404)
+
+	rs, err := client.Post(ts.url("/_admin/object?"+objectQuery), "", nil)
+	ts.OK(err)
+	rs.Body.Close()
+	if rs.StatusCode != 200 || rs.Request.URL.Path != "/_admin/bucket" {
+		t.Fatal("unexpected response", rs.StatusCode, rs.Request.URL)
+	}
+	ts.assertLs(defaultBucket, "dir/", nil, nil)
+
+	// The S3 API is still served outside the prefix:
+	ts.assertLs(defaultBucket, "", nil, nil)
+	get("/_admin/nope", 404)
+}
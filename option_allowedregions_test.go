@@ -0,0 +1,64 @@
+package gofakes3_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/johannesboyne/gofakes3"
+)
+
+func TestAllowedRegions(t *testing.T) {
+	ts := newTestServer(t, withFakerOptions(
+		gofakes3.WithAllowedRegions("us-east-1"),
+		gofakes3.WithAllowedRegions("eu-west-1"),
+	))
+	defer ts.Close()
+	svc := ts.s3Client()
+
+	for bucket, region := range map[string]string{
+		"ireland":  "eu-west-1",
+		"virginia": "us-east-1",
+	} {
+		ts.OKAll(svc.CreateBucket(&s3.CreateBucketInput{
+			Bucket:                    aws.String(bucket),
+			CreateBucketConfiguration: &s3.CreateBucketConfiguration{LocationConstraint: aws.String(region)},
+		}))
+	}
+
+	// The SDK sends the client's region if none is given, so this is sent
+	// without it; no body means us-east-1:
+	rs, body := ts.sendRaw("PUT", "/nowhere", nil, nil)
+	if rs.StatusCode != http.StatusOK {
+		t.Fatal("unexpected status", rs.StatusCode, string(body))
+	}
+
+	_, err := svc.CreateBucket(&s3.CreateBucketInput{
+		Bucket:                    aws.String("bogus"),
+		CreateBucketConfiguration: &s3.CreateBucketConfiguration{LocationConstraint: aws.String("moon-north-1")},
+	})
+	if !hasErrorCode(err, gofakes3.ErrInvalidLocationConstraint) {
+		t.Fatal("expected InvalidLocationConstraint, found", err)
+	}
+	if reqErr, ok := err.(awserr.RequestFailure); !ok || reqErr.StatusCode() != http.StatusBadRequest {
+		t.Fatal("unexpected error", err)
+	}
+	if _, err := ts.backend.HeadObject("bogus", "object"); !hasErrorCode(err, gofakes3.ErrNoSuchBucket) {
+		t.Fatal("bucket was created", err)
+	}
+
+	rs, body = ts.sendRaw("PUT", "/malformed", []byte("<CreateBucketConfiguration>"), nil)
+	ts.assertRawErrorCode(rs, body, gofakes3.ErrMalformedXML)
+}
+
+func TestAllowedRegionsDisabled(t *testing.T) {
+	ts := newTestServer(t)
+	defer ts.Close()
+
+	ts.OKAll(ts.s3Client().CreateBucket(&s3.CreateBucketInput{
+		Bucket:                    aws.String("bogus"),
+		CreateBucketConfiguration: &s3.CreateBucketConfiguration{LocationConstraint: aws.String("moon-north-1")},
+	}))
+}
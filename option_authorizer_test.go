@@ -0,0 +1,72 @@
+package gofakes3_test
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/johannesboyne/gofakes3"
+)
+
+func TestAuthorizer(t *testing.T) {
+	var mu sync.Mutex
+	var calls []string
+	authorizer := gofakes3.AuthorizerFunc(func(principal, action, bucket, key string) error {
+		mu.Lock()
+		calls = append(calls, strings.Join([]string{principal, action, bucket, key}, " "))
+		mu.Unlock()
+
+		switch {
+		case principal == gofakes3.AnonymousPrincipal && action != "GetObject":
+			return fmt.Errorf("anonymous users may only read")
+		case strings.HasPrefix(key, "secret/"):
+			return fmt.Errorf("secret")
+		}
+		return nil
+	})
+
+	ts := newTestServer(t, withFakerOptions(gofakes3.WithAuthorizer(authorizer)))
+	defer ts.Close()
+	svc := ts.s3Client()
+
+	ts.OKAll(svc.PutObject(&s3.PutObjectInput{
+		Bucket: aws.String(defaultBucket),
+		Key:    aws.String("public"),
+		Body:   strings.NewReader("hello"),
+	}))
+	_, err := svc.PutObject(&s3.PutObjectInput{
+		Bucket: aws.String(defaultBucket),
+		Key:    aws.String("secret/object"),
+		Body:   strings.NewReader("hello"),
+	})
+	if !hasErrorCode(err, gofakes3.ErrAccessDenied) {
+		t.Fatal("expected AccessDenied, found", err)
+	}
+	ts.OKAll(svc.GetBucketVersioning(&s3.GetBucketVersioningInput{Bucket: aws.String(defaultBucket)}))
+
+	rs, body := ts.sendRaw("GET", defaultBucket+"/public", nil, nil)
+	if rs.StatusCode != http.StatusOK || string(body) != "hello" {
+		t.Fatal("unexpected response", rs.StatusCode, string(body))
+	}
+	rs, body = ts.sendRaw("DELETE", defaultBucket+"/public", nil, nil)
+	ts.assertRawErrorCode(rs, body, gofakes3.ErrAccessDenied)
+	ts.assertObject(defaultBucket, "public", nil, "hello")
+
+	mu.Lock()
+	defer mu.Unlock()
+	expected := []string{
+		"dummy-access PutObject " + defaultBucket + " public",
+		"dummy-access PutObject " + defaultBucket + " secret/object",
+		"dummy-access GetBucketVersioning " + defaultBucket + " ",
+		"anonymous GetObject " + defaultBucket + " public",
+		"anonymous DeleteObject " + defaultBucket + " public",
+	}
+	if len(calls) < len(expected) || !reflect.DeepEqual(calls[:len(expected)], expected) {
+		t.Fatalf("unexpected calls:\nexp: %q\ngot: %q", expected, calls)
+	}
+}
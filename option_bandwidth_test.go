@@ -0,0 +1,77 @@
+package gofakes3_test
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/johannesboyne/gofakes3"
+)
+
+func TestBandwidthLimit(t *testing.T) {
+	const rate = 4000
+	body := bytes.Repeat([]byte("x"), rate/2)
+
+	// Transfers of rate/2 bytes should take about half a second; anything
+	// over a quarter of a second shows the limit is in effect.
+	const expected = 250 * time.Millisecond
+
+	t.Run("upload", func(t *testing.T) {
+		ts := newTestServer(t, withFakerOptions(gofakes3.WithUploadBandwidthLimit(rate)))
+		defer ts.Close()
+
+		start := time.Now()
+		rs, out := ts.sendRaw("PUT", defaultBucket+"/object", body, nil)
+		if rs.StatusCode != http.StatusOK {
+			t.Fatal("unexpected status", rs.StatusCode, string(out))
+		}
+		if elapsed := time.Since(start); elapsed < expected {
+			t.Fatal("upload was not throttled; took", elapsed)
+		}
+
+		// Downloads are unaffected:
+		start = time.Now()
+		ts.assertObject(defaultBucket, "object", nil, body)
+		if elapsed := time.Since(start); elapsed >= expected {
+			t.Fatal("download was throttled; took", elapsed)
+		}
+	})
+
+	t.Run("download", func(t *testing.T) {
+		ts := newTestServer(t, withFakerOptions(gofakes3.WithDownloadBandwidthLimit(rate)))
+		defer ts.Close()
+		ts.backendPutBytes(defaultBucket, "object", nil, body)
+
+		start := time.Now()
+		rs, out := ts.sendRaw("GET", defaultBucket+"/object", nil, nil)
+		if rs.StatusCode != http.StatusOK || !bytes.Equal(out, body) {
+			t.Fatal("unexpected response", rs.StatusCode)
+		}
+		if elapsed := time.Since(start); elapsed < expected {
+			t.Fatal("download was not throttled; took", elapsed)
+		}
+	})
+
+	t.Run("cancelled", func(t *testing.T) {
+		ts := newTestServer(t, withFakerOptions(gofakes3.WithBandwidthLimit(rate)))
+		defer ts.Close()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+		rq, err := http.NewRequestWithContext(ctx, "PUT", ts.url(defaultBucket+"/object"), bytes.NewReader(body))
+		ts.OK(err)
+		rs, err := httpClient().Do(rq)
+		if err == nil {
+			ioutil.ReadAll(rs.Body)
+			rs.Body.Close()
+			t.Fatal("expected request to be cancelled")
+		}
+
+		if ts.backendObjectExists(defaultBucket, "object") {
+			t.Fatal("cancelled upload should not create an object")
+		}
+	})
+}
@@ -0,0 +1,93 @@
+package gofakes3_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+	"github.com/johannesboyne/gofakes3"
+	"github.com/johannesboyne/gofakes3/backend/s3mem"
+)
+
+func TestBucketBackend(t *testing.T) {
+	other := s3mem.New()
+	ts := newTestServer(t, withFakerOptions(gofakes3.WithBucketBackend("other", other)))
+	defer ts.Close()
+	svc := ts.s3Client()
+
+	ts.OKAll(svc.CreateBucket(&s3.CreateBucketInput{Bucket: aws.String("other")}))
+	for _, bucket := range []string{defaultBucket, "other"} {
+		ts.OKAll(svc.PutObject(&s3.PutObjectInput{
+			Bucket: aws.String(bucket),
+			Key:    aws.String("object"),
+			Body:   strings.NewReader("hello " + bucket),
+		}))
+		rs, err := svc.GetObject(&s3.GetObjectInput{Bucket: aws.String(bucket), Key: aws.String("object")})
+		ts.OK(err)
+		body, err := ioutil.ReadAll(rs.Body)
+		rs.Body.Close()
+		ts.OK(err)
+		if string(body) != "hello "+bucket {
+			t.Fatal("unexpected body", bucket, string(body))
+		}
+	}
+
+	// The mapped bucket only exists in its own Backend:
+	if exists, _ := ts.backend.BucketExists("other"); exists {
+		t.Fatal("mapped bucket created in the default backend")
+	}
+	obj, err := other.GetObject("other", "object", nil)
+	ts.OK(err)
+	defer obj.Contents.Close()
+	if body, _ := ioutil.ReadAll(obj.Contents); string(body) != "hello other" {
+		t.Fatal("unexpected body in mapped backend", string(body))
+	}
+
+	rs, err := svc.ListBuckets(&s3.ListBucketsInput{})
+	ts.OK(err)
+	var buckets []string
+	for _, bucket := range rs.Buckets {
+		buckets = append(buckets, aws.StringValue(bucket.Name))
+	}
+	expected := []string{defaultBucket, "other"}
+	sort.Strings(expected)
+	if !reflect.DeepEqual(buckets, expected) {
+		t.Fatal("unexpected buckets", buckets)
+	}
+
+	list, err := svc.ListObjectsV2(&s3.ListObjectsV2Input{Bucket: aws.String("other")})
+	ts.OK(err)
+	if len(list.Contents) != 1 || aws.StringValue(list.Contents[0].Key) != "object" {
+		t.Fatal("unexpected listing", list.Contents)
+	}
+
+	// Multipart uploads are assembled in the mapped Backend:
+	body := randomFileBody(defaultUploadPartSize + 1)
+	uploader := s3manager.NewUploaderWithClient(svc)
+	ts.OKAll(uploader.Upload(&s3manager.UploadInput{
+		Bucket: aws.String("other"),
+		Key:    aws.String("multi"),
+		Body:   bytes.NewReader(body),
+	}))
+	multi, err := other.GetObject("other", "multi", nil)
+	ts.OK(err)
+	defer multi.Contents.Close()
+	if data, _ := ioutil.ReadAll(multi.Contents); !bytes.Equal(data, body) {
+		t.Fatal("upload not stored in the mapped backend")
+	}
+
+	ts.OKAll(svc.DeleteObjects(&s3.DeleteObjectsInput{
+		Bucket: aws.String("other"),
+		Delete: &s3.Delete{Objects: []*s3.ObjectIdentifier{{Key: aws.String("object")}, {Key: aws.String("multi")}}},
+	}))
+	ts.OKAll(svc.DeleteBucket(&s3.DeleteBucketInput{Bucket: aws.String("other")}))
+	if exists, _ := other.BucketExists("other"); exists {
+		t.Fatal("mapped bucket not deleted")
+	}
+}
@@ -0,0 +1,78 @@
+package gofakes3_test
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/johannesboyne/gofakes3"
+)
+
+func TestBucketThroughputLimit(t *testing.T) {
+	const rate = 4000
+	body := bytes.Repeat([]byte("x"), rate/4)
+
+	ts := newTestServer(t,
+		withFakerOptions(gofakes3.WithBucketThroughputLimit("slow", rate, rate)),
+		withInitialBuckets(defaultBucket, "slow"))
+	defer ts.Close()
+
+	put := func(bucket, key string) {
+		t.Helper()
+		rs, out := ts.sendRaw("PUT", bucket+"/"+key, body, nil)
+		if rs.StatusCode != http.StatusOK {
+			t.Error("unexpected status", rs.StatusCode, string(out))
+		}
+	}
+
+	// Two uploads of rate/4 bytes each would take a quarter of a second if
+	// they were throttled independently; sharing the limit, they take half
+	// a second between them:
+	start := time.Now()
+	var wg sync.WaitGroup
+	for _, key := range []string{"one", "two"} {
+		wg.Add(1)
+		go func(key string) {
+			defer wg.Done()
+			put("slow", key)
+		}(key)
+	}
+	wg.Wait()
+	if elapsed := time.Since(start); elapsed < 375*time.Millisecond {
+		t.Fatal("uploads did not share the limit; took", elapsed)
+	}
+
+	start = time.Now()
+	rs, out := ts.sendRaw("GET", "slow/one", nil, nil)
+	if rs.StatusCode != http.StatusOK || !bytes.Equal(out, body) {
+		t.Fatal("unexpected response", rs.StatusCode)
+	}
+	if elapsed := time.Since(start); elapsed < 200*time.Millisecond {
+		t.Fatal("download was not throttled; took", elapsed)
+	}
+
+	// Other buckets are unaffected:
+	start = time.Now()
+	put(defaultBucket, "object")
+	ts.assertObject(defaultBucket, "object", nil, body)
+	if elapsed := time.Since(start); elapsed >= 200*time.Millisecond {
+		t.Fatal("unlimited bucket was throttled; took", elapsed)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	rq, err := http.NewRequestWithContext(ctx, "PUT", ts.url("slow/cancelled"), bytes.NewReader(bytes.Repeat(body, 4)))
+	ts.OK(err)
+	if rs, err := httpClient().Do(rq); err == nil {
+		ioutil.ReadAll(rs.Body)
+		rs.Body.Close()
+		t.Fatal("expected request to be cancelled")
+	}
+	if ts.backendObjectExists("slow", "cancelled") {
+		t.Fatal("cancelled upload should not create an object")
+	}
+}
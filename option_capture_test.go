@@ -0,0 +1,84 @@
+package gofakes3_test
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/johannesboyne/gofakes3"
+)
+
+func TestRequestCapture(t *testing.T) {
+	var mu sync.Mutex
+	var captured []gofakes3.CapturedRequest
+	ts := newTestServer(t, withFakerOptions(gofakes3.WithRequestCapture(func(rq gofakes3.CapturedRequest) {
+		mu.Lock()
+		defer mu.Unlock()
+		captured = append(captured, rq)
+	})))
+	defer ts.Close()
+	svc := ts.s3Client()
+
+	last := func() gofakes3.CapturedRequest {
+		mu.Lock()
+		defer mu.Unlock()
+		if len(captured) == 0 {
+			t.Fatal("no request captured")
+		}
+		return captured[len(captured)-1]
+	}
+
+	body := "hello capture"
+	ts.OKAll(svc.PutObject(&s3.PutObjectInput{
+		Bucket:      aws.String(defaultBucket),
+		Key:         aws.String("dir/object"),
+		ContentType: aws.String("text/plain"),
+		Body:        strings.NewReader(body),
+	}))
+
+	rq := last()
+	sum := sha256.Sum256([]byte(body))
+	if rq.Method != "PUT" || rq.Bucket != defaultBucket || rq.Object != "dir/object" || rq.Subresource != "" {
+		t.Fatal("unexpected request", rq.Method, rq.Bucket, rq.Object, rq.Subresource)
+	}
+	if ct := rq.Header.Get("Content-Type"); ct != "text/plain" {
+		t.Fatal("unexpected content type", ct)
+	}
+	if rq.BodySize != int64(len(body)) || rq.BodySHA256 != hex.EncodeToString(sum[:]) {
+		t.Fatal("unexpected body", rq.BodySize, rq.BodySHA256)
+	}
+	if rq.StatusCode != 200 {
+		t.Fatal("unexpected status", rq.StatusCode)
+	}
+
+	t.Run("query", func(t *testing.T) {
+		ts.OKAll(svc.ListObjectsV2(&s3.ListObjectsV2Input{
+			Bucket:    aws.String(defaultBucket),
+			Prefix:    aws.String("dir/"),
+			Delimiter: aws.String("/"),
+		}))
+		rq := last()
+		if rq.Method != "GET" || rq.Object != "" ||
+			rq.Query.Get("list-type") != "2" || rq.Query.Get("prefix") != "dir/" || rq.Query.Get("delimiter") != "/" {
+			t.Fatal("unexpected request", rq.Method, rq.Object, rq.RawQuery)
+		}
+	})
+
+	t.Run("subresource-error", func(t *testing.T) {
+		_, err := svc.GetObjectTagging(&s3.GetObjectTaggingInput{
+			Bucket: aws.String(defaultBucket),
+			Key:    aws.String("missing"),
+		})
+		if !hasErrorCode(err, gofakes3.ErrNoSuchKey) {
+			t.Fatal("expected ErrNoSuchKey, found", err)
+		}
+		rq := last()
+		if rq.Subresource != "tagging" || rq.StatusCode != 404 {
+			t.Fatal("unexpected request", rq.Subresource, rq.StatusCode)
+		}
+	})
+}
@@ -0,0 +1,81 @@
+package gofakes3_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/johannesboyne/gofakes3"
+)
+
+func TestContentTypeSniffing(t *testing.T) {
+	putAndHead := func(ts *testServer, key, contentType, body string) string {
+		ts.Helper()
+		svc := ts.s3Client()
+		in := &s3.PutObjectInput{
+			Bucket: aws.String(defaultBucket),
+			Key:    aws.String(key),
+			Body:   strings.NewReader(body),
+		}
+		if contentType != "" {
+			in.ContentType = aws.String(contentType)
+		}
+		ts.OKAll(svc.PutObject(in))
+
+		out, err := svc.HeadObject(&s3.HeadObjectInput{
+			Bucket: aws.String(defaultBucket),
+			Key:    aws.String(key),
+		})
+		ts.OK(err)
+		return aws.StringValue(out.ContentType)
+	}
+
+	t.Run("default", func(t *testing.T) {
+		ts := newTestServer(t)
+		defer ts.Close()
+
+		if ct := putAndHead(ts, "page.html", "", "<html></html>"); ct != gofakes3.DefaultContentType {
+			t.Fatal("expected", gofakes3.DefaultContentType, "found", ct)
+		}
+	})
+
+	t.Run("sniffing", func(t *testing.T) {
+		ts := newTestServer(t, withFakerOptions(gofakes3.WithContentTypeSniffing()))
+		defer ts.Close()
+
+		for _, tc := range []struct {
+			key, contentType, body string
+			expected               string
+		}{
+			{"style.css", "", "body {}", "text/css; charset=utf-8"},
+			{"page", "", "<html><body></body></html>", "text/html; charset=utf-8"},
+			{"blob", "", "\x00\x01\x02", "application/octet-stream"},
+			{"empty", "", "", gofakes3.DefaultContentType},
+			{"explicit.css", "text/plain", "body {}", "text/plain"},
+		} {
+			t.Run(tc.key, func(t *testing.T) {
+				if ct := putAndHead(ts, tc.key, tc.contentType, tc.body); ct != tc.expected {
+					t.Fatal("expected", tc.expected, "found", ct)
+				}
+				ts.assertObject(defaultBucket, tc.key, nil, tc.body)
+			})
+		}
+
+		t.Run("multipart", func(t *testing.T) {
+			body := []byte(`{"hello": "world"}`)
+			id := ts.createMultipartUpload(defaultBucket, "upload.json", nil)
+			part := ts.uploadPart(defaultBucket, "upload.json", id, 1, body)
+			ts.assertCompleteUpload(defaultBucket, "upload.json", id, []*s3.CompletedPart{part}, body)
+
+			out, err := ts.s3Client().HeadObject(&s3.HeadObjectInput{
+				Bucket: aws.String(defaultBucket),
+				Key:    aws.String("upload.json"),
+			})
+			ts.OK(err)
+			if ct := aws.StringValue(out.ContentType); ct != "application/json" {
+				t.Fatal("expected application/json, found", ct)
+			}
+		})
+	})
+}
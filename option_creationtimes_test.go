@@ -0,0 +1,81 @@
+package gofakes3_test
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/johannesboyne/gofakes3"
+)
+
+func TestCreationTimes(t *testing.T) {
+	ts := newTestServer(t, withFakerOptions(gofakes3.WithCreationTimes()))
+	defer ts.Close()
+
+	head := func(key string) (created, modified string) {
+		t.Helper()
+		rs, body := ts.sendRaw("HEAD", defaultBucket+"/"+key, nil, nil)
+		if rs.StatusCode != http.StatusOK {
+			t.Fatal("unexpected status", rs.StatusCode, string(body))
+		}
+		return rs.Header.Get(gofakes3.CreatedHeader), rs.Header.Get("Last-Modified")
+	}
+	put := func(key string, header http.Header) {
+		t.Helper()
+		rs, body := ts.sendRaw("PUT", defaultBucket+"/"+key, []byte("hello"), header)
+		if rs.StatusCode != http.StatusOK {
+			t.Fatal("unexpected status", rs.StatusCode, string(body))
+		}
+	}
+
+	createdAt := ts.Now().UTC().Format(http.TimeFormat)
+	put("object", nil)
+	if created, modified := head("object"); created != createdAt || modified != createdAt {
+		t.Fatal("unexpected times", created, modified)
+	}
+
+	// Overwriting, or copying over, the object keeps its creation time:
+	ts.Advance(time.Hour)
+	put("object", nil)
+	if created, modified := head("object"); created != createdAt || modified != ts.Now().UTC().Format(http.TimeFormat) {
+		t.Fatal("unexpected times after overwrite", created, modified)
+	}
+	ts.Advance(time.Hour)
+	put("source", nil)
+	put("object", http.Header{"X-Amz-Copy-Source": {defaultBucket + "/source"}})
+	if created, _ := head("object"); created != createdAt {
+		t.Fatal("unexpected creation time after copy", created)
+	}
+	if created, _ := head("source"); created != ts.Now().UTC().Format(http.TimeFormat) {
+		t.Fatal("unexpected creation time of copy source", created)
+	}
+
+	// Once deleted, the object is created again:
+	rs, body := ts.sendRaw("DELETE", defaultBucket+"/object", nil, nil)
+	if rs.StatusCode != http.StatusNoContent {
+		t.Fatal("unexpected status", rs.StatusCode, string(body))
+	}
+	ts.Advance(time.Hour)
+	put("object", nil)
+	if created, _ := head("object"); created != ts.Now().UTC().Format(http.TimeFormat) {
+		t.Fatal("unexpected creation time after delete", created)
+	}
+
+	// Objects put straight into the Backend have none:
+	ts.backendPutString(defaultBucket, "untracked", nil, "hello")
+	put("untracked", nil)
+	if created, _ := head("untracked"); created != "" {
+		t.Fatal("unexpected creation time", created)
+	}
+}
+
+func TestCreationTimesDisabled(t *testing.T) {
+	ts := newTestServer(t)
+	defer ts.Close()
+
+	ts.sendRaw("PUT", defaultBucket+"/object", []byte("hello"), nil)
+	rs, _ := ts.sendRaw("GET", defaultBucket+"/object", nil, nil)
+	if rs.Header.Get(gofakes3.CreatedHeader) != "" {
+		t.Fatal("unexpected creation time", rs.Header.Get(gofakes3.CreatedHeader))
+	}
+}
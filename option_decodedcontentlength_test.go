@@ -0,0 +1,87 @@
+package gofakes3_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/johannesboyne/gofakes3"
+)
+
+func TestDecodedContentLengthCheck(t *testing.T) {
+	const signature = ";chunk-signature=0000000000000000000000000000000000000000000000000000000000000000"
+
+	for _, tc := range []struct {
+		streaming string
+		body      string
+	}{
+		{"STREAMING-AWS4-HMAC-SHA256-PAYLOAD", "5" + signature + "\r\nhello\r\n6" + signature + "\r\n world\r\n0" + signature + "\r\n\r\n"},
+		{"STREAMING-UNSIGNED-PAYLOAD-TRAILER", "5\r\nhello\r\n6\r\n world\r\n0\r\n\r\n"},
+	} {
+		t.Run(tc.streaming, func(t *testing.T) {
+			ts := newTestServer(t, withFakerOptions(
+				gofakes3.WithDecodedContentLengthCheck(),
+				gofakes3.WithMinPartSize(0),
+			))
+			defer ts.Close()
+
+			send := func(path, query string, decodedLength int) (*http.Response, []byte) {
+				t.Helper()
+				client := ts.rawClient()
+				rq := client.Request("PUT", path, []byte(tc.body))
+				rq.URL.RawQuery = query
+				rq.Header.Del("Content-Md5")
+				rq.Header.Set("Content-Encoding", "aws-chunked")
+				rq.Header.Set("X-Amz-Content-Sha256", tc.streaming)
+				rq.Header.Set("X-Amz-Decoded-Content-Length", fmt.Sprint(decodedLength))
+				rs, err := client.Do(rq)
+				ts.OK(err)
+				defer rs.Body.Close()
+				body, err := ioutil.ReadAll(rs.Body)
+				ts.OK(err)
+				return rs, body
+			}
+
+			for _, decodedLength := range []int{5, 20} {
+				rs, body := send("/"+defaultBucket+"/object", "", decodedLength)
+				ts.assertRawErrorCode(rs, body, gofakes3.ErrIncompleteBody)
+			}
+			if ts.backendObjectExists(defaultBucket, "object") {
+				t.Fatal("object was stored despite its length mismatch")
+			}
+
+			if rs, body := send("/"+defaultBucket+"/object", "", 11); rs.StatusCode != http.StatusOK {
+				t.Fatal("unexpected status", rs.StatusCode, string(body))
+			}
+			ts.assertObject(defaultBucket, "object", nil, "hello world")
+
+			// A part is sized by its decoded length too, rather than its
+			// framed Content-Length:
+			uploadID := ts.createMultipartUpload(defaultBucket, "upload", nil)
+			partQuery := "partNumber=1&uploadId=" + uploadID
+			rs, body := send("/"+defaultBucket+"/upload", partQuery, 20)
+			ts.assertRawErrorCode(rs, body, gofakes3.ErrIncompleteBody)
+			rs, body = send("/"+defaultBucket+"/upload", partQuery, 11)
+			if rs.StatusCode != http.StatusOK {
+				t.Fatal("unexpected status", rs.StatusCode, string(body))
+			}
+			ts.assertCompleteUpload(defaultBucket, "upload", uploadID, []*s3.CompletedPart{
+				{ETag: aws.String(rs.Header.Get("ETag")), PartNumber: aws.Int64(1)},
+			}, "hello world")
+		})
+	}
+
+	t.Run("invalid", func(t *testing.T) {
+		ts := newTestServer(t, withFakerOptions(gofakes3.WithDecodedContentLengthCheck()))
+		defer ts.Close()
+
+		rs, body := ts.sendRaw("PUT", defaultBucket+"/object", []byte("0\r\n\r\n"), http.Header{
+			"X-Amz-Content-Sha256":         []string{"STREAMING-UNSIGNED-PAYLOAD-TRAILER"},
+			"X-Amz-Decoded-Content-Length": []string{"-1"},
+		})
+		ts.assertRawErrorCode(rs, body, gofakes3.ErrInvalidRequest)
+	})
+}
@@ -0,0 +1,57 @@
+package gofakes3_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/johannesboyne/gofakes3"
+)
+
+func TestBucketDefaultMetadata(t *testing.T) {
+	ts := newTestServer(t, withFakerOptions(
+		gofakes3.WithBucketDefaultMetadata(defaultBucket, map[string]string{
+			"Team":              "storage",
+			"x-amz-meta-region": "eu",
+		}),
+	))
+	defer ts.Close()
+	ts.backendCreateBucket("other")
+
+	assertMeta := func(path, team, region string) {
+		t.Helper()
+		rs, body := ts.sendRaw("HEAD", path, nil, nil)
+		if rs.StatusCode != http.StatusOK {
+			t.Fatal("unexpected status", rs.StatusCode, string(body))
+		}
+		if rs.Header.Get("X-Amz-Meta-Team") != team || rs.Header.Get("X-Amz-Meta-Region") != region {
+			t.Fatal("unexpected metadata", rs.Header)
+		}
+	}
+	put := func(path string, header http.Header) {
+		t.Helper()
+		rs, body := ts.sendRaw("PUT", path, []byte("hello"), header)
+		if rs.StatusCode != http.StatusOK {
+			t.Fatal("unexpected status", rs.StatusCode, string(body))
+		}
+	}
+
+	put(defaultBucket+"/plain", nil)
+	assertMeta(defaultBucket+"/plain", "storage", "eu")
+
+	put(defaultBucket+"/override", http.Header{"X-Amz-Meta-Team": {"compute"}})
+	assertMeta(defaultBucket+"/override", "compute", "eu")
+
+	put("other/plain", nil)
+	assertMeta("other/plain", "", "")
+
+	// The source's metadata is copied in preference to the defaults:
+	put("other/source", http.Header{"X-Amz-Meta-Team": {"network"}})
+	put(defaultBucket+"/copy", http.Header{"X-Amz-Copy-Source": {"/other/source"}})
+	assertMeta(defaultBucket+"/copy", "network", "eu")
+
+	uploadID := ts.createMultipartUpload(defaultBucket, "upload", nil)
+	part := ts.uploadPart(defaultBucket, "upload", uploadID, 1, []byte("part"))
+	ts.assertCompleteUpload(defaultBucket, "upload", uploadID, []*s3.CompletedPart{part}, []byte("part"))
+	assertMeta(defaultBucket+"/upload", "storage", "eu")
+}
@@ -0,0 +1,53 @@
+package gofakes3_test
+
+import (
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/johannesboyne/gofakes3"
+)
+
+func TestEchoHeaders(t *testing.T) {
+	ts := newTestServer(t, withFakerOptions(
+		gofakes3.WithEchoHeaders(),
+	))
+	defer ts.Close()
+
+	rs, _ := ts.sendRaw("PUT", defaultBucket+"/object", []byte("hello"), http.Header{
+		"X-Amz-Server-Side-Encryption": {"AES256"},
+		"X-Amz-Meta-Multi":             {"a", "b"},
+	})
+	if rs.StatusCode != http.StatusOK {
+		t.Fatal("unexpected status", rs.StatusCode)
+	}
+	if v := rs.Header.Get(gofakes3.EchoHeaderPrefix + "X-Amz-Server-Side-Encryption"); v != "AES256" {
+		t.Fatal("unexpected echoed header", v)
+	}
+	if v := rs.Header.Values(gofakes3.EchoHeaderPrefix + "X-Amz-Meta-Multi"); !reflect.DeepEqual(v, []string{"a", "b"}) {
+		t.Fatal("unexpected echoed header", v)
+	}
+	if v := rs.Header.Get(gofakes3.EchoHeaderPrefix + "Host"); v == "" {
+		t.Fatal("host not echoed")
+	}
+
+	// Errors echo the headers too:
+	rs, body := ts.sendRaw("GET", defaultBucket+"/missing", nil, http.Header{"X-Custom": {"yes"}})
+	ts.assertRawErrorCode(rs, body, gofakes3.ErrNoSuchKey)
+	if v := rs.Header.Get(gofakes3.EchoHeaderPrefix + "X-Custom"); v != "yes" {
+		t.Fatal("unexpected echoed header", v)
+	}
+}
+
+func TestEchoHeadersDisabled(t *testing.T) {
+	ts := newTestServer(t)
+	defer ts.Close()
+
+	rs, _ := ts.sendRaw("GET", defaultBucket+"/missing", nil, http.Header{"X-Custom": {"yes"}})
+	for k := range rs.Header {
+		if strings.HasPrefix(k, gofakes3.EchoHeaderPrefix) {
+			t.Fatal("unexpected echoed header", k)
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package gofakes3_test
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/johannesboyne/gofakes3"
+)
+
+func TestFirstByteDelay(t *testing.T) {
+	ts := newTestServer(t, withFakerOptions(
+		gofakes3.WithFirstByteDelay(defaultBucket+"/slow/*", time.Minute),
+	))
+	defer ts.Close()
+	ts.backendPutString(defaultBucket, "slow/obj", nil, "hello")
+	ts.backendPutString(defaultBucket, "fast", nil, "hello")
+
+	// The FixedTimeSource is advanced instead of waiting:
+	delay := func(method, path string) time.Duration {
+		t.Helper()
+		start := ts.Now()
+		rs, body := ts.sendRaw(method, path, nil, nil)
+		if rs.StatusCode != http.StatusOK {
+			t.Fatal("unexpected status", method, path, rs.StatusCode, string(body))
+		}
+		if method == "GET" && string(body) != "hello" {
+			t.Fatal("unexpected body", string(body))
+		}
+		return ts.Since(start)
+	}
+
+	if d := delay("GET", defaultBucket+"/slow/obj"); d != time.Minute {
+		t.Fatal("unexpected GET delay", d)
+	}
+	if d := delay("HEAD", defaultBucket+"/slow/obj"); d != 0 {
+		t.Fatal("unexpected delay for HEAD", d)
+	}
+	if d := delay("GET", defaultBucket+"/fast"); d != 0 {
+		t.Fatal("unexpected delay for unmatched key", d)
+	}
+}
+
+// blockingSleeper waits in Sleep until it is released, so tests can observe
+// what a client receives while a request is delayed.
+type blockingSleeper struct {
+	gofakes3.TimeSource
+	sleeping chan struct{}
+	release  chan struct{}
+}
+
+func (b *blockingSleeper) Sleep(ctx context.Context, d time.Duration) error {
+	b.sleeping <- struct{}{}
+	select {
+	case <-b.release:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func TestFirstByteDelayAfterHeaders(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		options []gofakes3.Option
+	}{
+		{"plain", nil},
+
+		// The throttled writer must still let the headers be flushed:
+		{"throttled", []gofakes3.Option{gofakes3.WithDownloadBandwidthLimit(1 << 20)}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			sleeper := &blockingSleeper{
+				TimeSource: gofakes3.FixedTimeSource(defaultDate),
+				sleeping:   make(chan struct{}, 1),
+				release:    make(chan struct{}),
+			}
+			ts := newTestServer(t, withFakerOptions(append([]gofakes3.Option{
+				gofakes3.WithTimeSource(sleeper),
+				gofakes3.WithFirstByteDelay(defaultBucket+"/*", time.Minute),
+			}, tc.options...)...))
+			defer ts.Close()
+			ts.backendPutString(defaultBucket, "obj", nil, "hello")
+
+			// The headers arrive while the body is still delayed:
+			rs, err := httpClient().Get(ts.url(defaultBucket + "/obj"))
+			ts.OK(err)
+			defer rs.Body.Close()
+			<-sleeper.sleeping
+			if rs.StatusCode != http.StatusOK || rs.ContentLength != 5 {
+				t.Fatal("unexpected response", rs.StatusCode, rs.ContentLength)
+			}
+
+			close(sleeper.release)
+			body, err := ioutil.ReadAll(rs.Body)
+			ts.OK(err)
+			if string(body) != "hello" {
+				t.Fatal("unexpected body", string(body))
+			}
+		})
+	}
+}
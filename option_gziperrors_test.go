@@ -0,0 +1,42 @@
+package gofakes3_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"testing"
+
+	"github.com/johannesboyne/gofakes3"
+)
+
+func TestGzipErrors(t *testing.T) {
+	ts := newTestServer(t, withFakerOptions(
+		gofakes3.WithGzipErrors(),
+	))
+	defer ts.Close()
+
+	rs, body := ts.sendRaw("GET", defaultBucket+"/missing", nil, http.Header{"Accept-Encoding": {"deflate, gzip"}})
+	if rs.StatusCode != http.StatusNotFound || rs.Header.Get("Content-Encoding") != "gzip" {
+		t.Fatal("unexpected response", rs.StatusCode, rs.Header)
+	}
+	if rs.Header.Get("Content-Length") != strconv.Itoa(len(body)) {
+		t.Fatal("unexpected content length", rs.Header.Get("Content-Length"), len(body))
+	}
+	gz, err := gzip.NewReader(bytes.NewReader(body))
+	ts.OK(err)
+	decoded, err := ioutil.ReadAll(gz)
+	ts.OK(err)
+	ts.assertRawErrorCode(rs, decoded, gofakes3.ErrNoSuchKey)
+
+	// Without gzip in Accept-Encoding, or with it refused, the body is sent
+	// as it is:
+	for _, accept := range []string{"", "identity", "gzip;q=0"} {
+		rs, body = ts.sendRaw("GET", defaultBucket+"/missing", nil, http.Header{"Accept-Encoding": {accept}})
+		if rs.Header.Get("Content-Encoding") != "" {
+			t.Fatal("unexpected content encoding for", accept, rs.Header.Get("Content-Encoding"))
+		}
+		ts.assertRawErrorCode(rs, body, gofakes3.ErrNoSuchKey)
+	}
+}
@@ -0,0 +1,44 @@
+package gofakes3_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/johannesboyne/gofakes3"
+)
+
+func TestHealthCheck(t *testing.T) {
+	ts := newTestServer(t, withFakerOptions(gofakes3.WithHealthCheck("")))
+	defer ts.Close()
+
+	for _, method := range []string{"GET", "HEAD"} {
+		rs, body := ts.sendRaw(method, gofakes3.DefaultHealthCheckPath, nil, nil)
+		if rs.StatusCode != http.StatusOK {
+			t.Fatal("unexpected status", method, rs.StatusCode, string(body))
+		}
+		if method == "GET" && string(body) != "OK\n" {
+			t.Fatal("unexpected body", string(body))
+		}
+	}
+
+	rs, _ := ts.sendRaw("POST", gofakes3.DefaultHealthCheckPath, nil, nil)
+	if rs.StatusCode != http.StatusMethodNotAllowed {
+		t.Fatal("unexpected status", rs.StatusCode)
+	}
+
+	// Anything else is still an S3 request:
+	rs, body := ts.sendRaw("GET", "/_gofakes3/other", nil, nil)
+	ts.assertRawErrorCode(rs, body, gofakes3.ErrNoSuchBucket)
+	ts.backendPutString(defaultBucket, "object", nil, "hello")
+	rs, body = ts.sendRaw("GET", defaultBucket+"/object", nil, nil)
+	if rs.StatusCode != http.StatusOK || string(body) != "hello" {
+		t.Fatal("unexpected response", rs.StatusCode, string(body))
+	}
+
+	ts.OK(ts.Shutdown(context.Background()))
+	rs, _ = ts.sendRaw("GET", gofakes3.DefaultHealthCheckPath, nil, nil)
+	if rs.StatusCode != http.StatusServiceUnavailable {
+		t.Fatal("unexpected status after shutdown", rs.StatusCode)
+	}
+}
@@ -0,0 +1,55 @@
+package gofakes3_test
+
+import (
+	"math/rand"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/johannesboyne/gofakes3"
+)
+
+func TestOperationLatency(t *testing.T) {
+	delays := func(seed int64) []time.Duration {
+		ts := newTestServer(t, withFakerOptions(
+			gofakes3.WithOperationLatency("GetObject", gofakes3.UniformLatency(time.Second, 2*time.Second)),
+			gofakes3.WithOperationLatency("ListObjects", func(*rand.Rand) time.Duration { return time.Minute }),
+			gofakes3.WithOperationLatency(gofakes3.AnyOperation, func(*rand.Rand) time.Duration { return time.Hour }),
+			gofakes3.WithOperationLatency("PutObject", gofakes3.UniformLatency(0, 0)),
+			gofakes3.WithLatencySeed(seed),
+		))
+		defer ts.Close()
+
+		// The FixedTimeSource is advanced instead of waiting:
+		var out []time.Duration
+		request := func(method, path string) {
+			t.Helper()
+			start := ts.Now()
+			rs, body := ts.sendRaw(method, path, []byte("hello"), nil)
+			if rs.StatusCode != http.StatusOK {
+				t.Fatal("unexpected status", rs.StatusCode, string(body))
+			}
+			out = append(out, ts.Since(start))
+		}
+		request("PUT", defaultBucket+"/obj")
+		request("GET", defaultBucket+"/obj")
+		request("GET", defaultBucket+"/obj")
+		request("GET", defaultBucket)
+		request("GET", defaultBucket+"/obj?acl")
+		return out
+	}
+
+	first := delays(1)
+	if first[0] != 0 || first[3] != time.Minute || first[4] != time.Hour {
+		t.Fatal("unexpected delays", first)
+	}
+	for _, d := range first[1:3] {
+		if d < time.Second || d > 2*time.Second {
+			t.Fatal("unexpected GetObject delay", d)
+		}
+	}
+
+	if second := delays(1); second[1] != first[1] || second[2] != first[2] {
+		t.Fatal("delays differ with the same seed", first, second)
+	}
+}
@@ -0,0 +1,58 @@
+package gofakes3_test
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/johannesboyne/gofakes3"
+)
+
+func TestListingLag(t *testing.T) {
+	ts := newTestServer(t, withFakerOptions(gofakes3.WithListingLag(time.Minute)))
+	defer ts.Close()
+	svc := ts.s3Client()
+
+	listed := func() (v1, v2 []string) {
+		t.Helper()
+		rs1, err := svc.ListObjects(&s3.ListObjectsInput{Bucket: aws.String(defaultBucket)})
+		ts.OK(err)
+		for _, item := range rs1.Contents {
+			v1 = append(v1, aws.StringValue(item.Key))
+		}
+		rs2, err := svc.ListObjectsV2(&s3.ListObjectsV2Input{Bucket: aws.String(defaultBucket)})
+		ts.OK(err)
+		for _, item := range rs2.Contents {
+			v2 = append(v2, aws.StringValue(item.Key))
+		}
+		if aws.Int64Value(rs2.KeyCount) != int64(len(v2)) {
+			t.Fatal("unexpected key count", aws.Int64Value(rs2.KeyCount), len(v2))
+		}
+		return v1, v2
+	}
+	assertListed := func(expected ...string) {
+		t.Helper()
+		v1, v2 := listed()
+		if !reflect.DeepEqual(v1, expected) || !reflect.DeepEqual(v2, expected) {
+			t.Fatal("unexpected listing", expected, v1, v2)
+		}
+	}
+
+	ts.backendPutString(defaultBucket, "old", nil, "hello")
+	ts.Advance(time.Minute)
+	ts.backendPutString(defaultBucket, "new", nil, "hello")
+
+	// The new object can be read, but is not listed until the lag is over:
+	ts.assertObject(defaultBucket, "new", nil, "hello")
+	assertListed("old")
+	ts.Advance(59 * time.Second)
+	assertListed("old")
+	ts.Advance(time.Second)
+	assertListed("new", "old")
+
+	// Overwriting an object hides it again:
+	ts.backendPutString(defaultBucket, "old", nil, "changed")
+	assertListed("new")
+}
@@ -0,0 +1,82 @@
+package gofakes3_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/johannesboyne/gofakes3"
+	"github.com/johannesboyne/gofakes3/backend/s3mem"
+)
+
+// blockingBackend holds ListBuckets requests in flight until release is
+// closed, so that the concurrency limit can be reached deterministically.
+type blockingBackend struct {
+	gofakes3.Backend
+	entered chan struct{}
+	release chan struct{}
+}
+
+func (b *blockingBackend) ListBuckets() ([]gofakes3.BucketInfo, error) {
+	b.entered <- struct{}{}
+	<-b.release
+	return b.Backend.ListBuckets()
+}
+
+func TestMaxConcurrentRequests(t *testing.T) {
+	backend := &blockingBackend{
+		Backend: s3mem.New(),
+		entered: make(chan struct{}),
+		release: make(chan struct{}),
+	}
+	ts := newTestServer(t,
+		withBackend(backend),
+		withFakerOptions(gofakes3.WithMaxConcurrentRequests(2)))
+	defer ts.Close()
+
+	done := make(chan int, 2)
+	for i := 0; i < 2; i++ {
+		go func() {
+			rs, err := httpClient().Get(ts.url("/"))
+			if err != nil {
+				done <- 0
+				return
+			}
+			rs.Body.Close()
+			done <- rs.StatusCode
+		}()
+		<-backend.entered
+	}
+
+	rs, body := ts.sendRaw("HEAD", defaultBucket, nil, nil)
+	if rs.StatusCode != http.StatusServiceUnavailable {
+		t.Fatal("expected status", http.StatusServiceUnavailable, "found", rs.StatusCode, string(body))
+	}
+	if rs.Header.Get("Retry-After") == "" {
+		t.Fatal("missing Retry-After header")
+	}
+
+	rs, body = ts.sendRaw("GET", defaultBucket, nil, nil)
+	ts.assertRawErrorCode(rs, body, gofakes3.ErrSlowDown)
+
+	// Raising the limit allows further requests while the others are still
+	// in flight:
+	ts.SetMaxConcurrentRequests(3)
+	rs, body = ts.sendRaw("HEAD", defaultBucket, nil, nil)
+	if rs.StatusCode != http.StatusOK {
+		t.Fatal("expected status", http.StatusOK, "found", rs.StatusCode, string(body))
+	}
+
+	close(backend.release)
+	for i := 0; i < 2; i++ {
+		if code := <-done; code != http.StatusOK {
+			t.Fatal("expected status", http.StatusOK, "found", code)
+		}
+	}
+
+	// Slots are released once requests finish:
+	ts.SetMaxConcurrentRequests(1)
+	rs, body = ts.sendRaw("HEAD", defaultBucket, nil, nil)
+	if rs.StatusCode != http.StatusOK {
+		t.Fatal("expected status", http.StatusOK, "found", rs.StatusCode, string(body))
+	}
+}
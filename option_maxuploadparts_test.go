@@ -0,0 +1,65 @@
+package gofakes3_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/johannesboyne/gofakes3"
+)
+
+func TestMaxUploadParts(t *testing.T) {
+	uploadPart := func(ts *testServer, uploadID string, num int64) error {
+		_, err := ts.s3Client().UploadPart(&s3.UploadPartInput{
+			Bucket:     aws.String(defaultBucket),
+			Key:        aws.String("object"),
+			Body:       bytes.NewReader([]byte("part")),
+			UploadId:   aws.String(uploadID),
+			PartNumber: aws.Int64(num),
+		})
+		return err
+	}
+
+	t.Run("default", func(t *testing.T) {
+		ts := newTestServer(t)
+		defer ts.Close()
+
+		uploadID := ts.createMultipartUpload(defaultBucket, "object", nil)
+		ts.OK(uploadPart(ts, uploadID, gofakes3.MaxUploadPartNumber))
+		if err := uploadPart(ts, uploadID, gofakes3.MaxUploadPartNumber+1); !hasErrorCode(err, gofakes3.ErrInvalidArgument) {
+			t.Fatal("expected InvalidArgument, found", err)
+		}
+	})
+
+	t.Run("lowered", func(t *testing.T) {
+		ts := newTestServer(t, withFakerOptions(
+			gofakes3.WithMaxUploadParts(2),
+			gofakes3.WithMinPartSize(0),
+		))
+		defer ts.Close()
+
+		uploadID := ts.createMultipartUpload(defaultBucket, "object", nil)
+		parts := []*s3.CompletedPart{
+			ts.uploadPart(defaultBucket, "object", uploadID, 1, []byte("one")),
+			ts.uploadPart(defaultBucket, "object", uploadID, 2, []byte("two")),
+		}
+		if err := uploadPart(ts, uploadID, 3); !hasErrorCode(err, gofakes3.ErrInvalidArgument) {
+			t.Fatal("expected InvalidArgument, found", err)
+		}
+
+		_, err := ts.s3Client().CompleteMultipartUpload(&s3.CompleteMultipartUploadInput{
+			Bucket:   aws.String(defaultBucket),
+			Key:      aws.String("object"),
+			UploadId: aws.String(uploadID),
+			MultipartUpload: &s3.CompletedMultipartUpload{
+				Parts: append(parts, &s3.CompletedPart{ETag: parts[1].ETag, PartNumber: aws.Int64(3)}),
+			},
+		})
+		if !hasErrorCode(err, gofakes3.ErrInvalidArgument) {
+			t.Fatal("expected InvalidArgument, found", err)
+		}
+
+		ts.assertCompleteUpload(defaultBucket, "object", uploadID, parts, "onetwo")
+	})
+}
@@ -0,0 +1,57 @@
+package gofakes3_test
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/johannesboyne/gofakes3"
+)
+
+func TestMinPartSize(t *testing.T) {
+	upload := func(ts *testServer, sizes ...int64) (uploadID string, parts []*s3.CompletedPart) {
+		uploadID = ts.createMultipartUpload(defaultBucket, "object", nil)
+		for i, size := range sizes {
+			parts = append(parts, ts.uploadPart(defaultBucket, "object", uploadID, int64(i+1), randomFileBody(size)))
+		}
+		return uploadID, parts
+	}
+	complete := func(ts *testServer, uploadID string, parts []*s3.CompletedPart) error {
+		_, err := ts.s3Client().CompleteMultipartUpload(&s3.CompleteMultipartUploadInput{
+			Bucket:          aws.String(defaultBucket),
+			Key:             aws.String("object"),
+			UploadId:        aws.String(uploadID),
+			MultipartUpload: &s3.CompletedMultipartUpload{Parts: parts},
+		})
+		return err
+	}
+
+	t.Run("default", func(t *testing.T) {
+		ts := newTestServer(t)
+		defer ts.Close()
+
+		uploadID, parts := upload(ts, 10, 10)
+		if err := complete(ts, uploadID, parts); !hasErrorCode(err, gofakes3.ErrEntityTooSmall) {
+			t.Fatal("expected EntityTooSmall, found", err)
+		}
+
+		// The last part may be smaller, so a single small part is fine:
+		uploadID, parts = upload(ts, gofakes3.DefaultUploadPartSize, 10)
+		ts.OK(complete(ts, uploadID, parts))
+		uploadID, parts = upload(ts, 10)
+		ts.OK(complete(ts, uploadID, parts))
+	})
+
+	t.Run("lowered", func(t *testing.T) {
+		ts := newTestServer(t, withFakerOptions(gofakes3.WithMinPartSize(10)))
+		defer ts.Close()
+
+		uploadID, parts := upload(ts, 10, 10, 1)
+		ts.OK(complete(ts, uploadID, parts))
+
+		uploadID, parts = upload(ts, 10, 9, 10)
+		if err := complete(ts, uploadID, parts); !hasErrorCode(err, gofakes3.ErrEntityTooSmall) {
+			t.Fatal("expected EntityTooSmall, found", err)
+		}
+	})
+}
@@ -0,0 +1,44 @@
+package gofakes3_test
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/johannesboyne/gofakes3"
+)
+
+func TestNotFoundDelay(t *testing.T) {
+	ts := newTestServer(t, withFakerOptions(
+		gofakes3.WithNotFoundDelay(defaultBucket+"/slow/*", time.Minute),
+	))
+	defer ts.Close()
+	ts.backendPutString(defaultBucket, "slow/exists", nil, "hello")
+
+	// The FixedTimeSource is advanced instead of waiting:
+	delay := func(method, path string, status int) time.Duration {
+		t.Helper()
+		start := ts.Now()
+		rs, body := ts.sendRaw(method, path, nil, nil)
+		if rs.StatusCode != status {
+			t.Fatal("unexpected status", method, path, rs.StatusCode, string(body))
+		}
+		return ts.Since(start)
+	}
+
+	if d := delay("GET", defaultBucket+"/slow/missing", http.StatusNotFound); d != time.Minute {
+		t.Fatal("unexpected GET delay", d)
+	}
+	if d := delay("HEAD", defaultBucket+"/slow/missing", http.StatusNotFound); d != time.Minute {
+		t.Fatal("unexpected HEAD delay", d)
+	}
+	if d := delay("GET", defaultBucket+"/slow/exists", http.StatusOK); d != 0 {
+		t.Fatal("unexpected delay for existing key", d)
+	}
+	if d := delay("GET", defaultBucket+"/fast", http.StatusNotFound); d != 0 {
+		t.Fatal("unexpected delay for unmatched key", d)
+	}
+	if d := delay("DELETE", defaultBucket+"/slow/missing", http.StatusNoContent); d != 0 {
+		t.Fatal("unexpected delay for DELETE", d)
+	}
+}
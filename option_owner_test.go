@@ -0,0 +1,99 @@
+package gofakes3_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/johannesboyne/gofakes3"
+)
+
+func TestOwners(t *testing.T) {
+	bucketOwner := gofakes3.UserInfo{ID: "owner-id", DisplayName: "owner"}
+	alice := gofakes3.UserInfo{ID: "alice-id", DisplayName: "alice"}
+
+	ts := newTestServer(t, withFakerOptions(
+		gofakes3.WithDefaultOwner(bucketOwner),
+		gofakes3.WithOwners(map[string]gofakes3.UserInfo{"alice-access": alice}),
+	))
+	defer ts.Close()
+	svc := ts.s3Client()
+	aliceSvc := s3.New(session.New(), svc.Client.Config.Copy().
+		WithCredentials(credentials.NewStaticCredentials("alice-access", "alice-secret", "")))
+
+	for _, put := range []struct {
+		svc *s3.S3
+		key string
+	}{
+		{svc, "default"},
+		{aliceSvc, "alice"},
+	} {
+		ts.OKAll(put.svc.PutObject(&s3.PutObjectInput{
+			Bucket: aws.String(defaultBucket),
+			Key:    aws.String(put.key),
+			Body:   strings.NewReader("hello"),
+		}))
+	}
+
+	owners := func(contents []*s3.Object) map[string]string {
+		found := map[string]string{}
+		for _, item := range contents {
+			if item.Owner != nil {
+				found[aws.StringValue(item.Key)] = aws.StringValue(item.Owner.ID)
+			}
+		}
+		return found
+	}
+
+	v1, err := svc.ListObjects(&s3.ListObjectsInput{Bucket: aws.String(defaultBucket)})
+	ts.OK(err)
+	if found := owners(v1.Contents); found["default"] != "owner-id" || found["alice"] != "alice-id" {
+		t.Fatal("unexpected owners", found)
+	}
+
+	v2, err := svc.ListObjectsV2(&s3.ListObjectsV2Input{Bucket: aws.String(defaultBucket)})
+	ts.OK(err)
+	if found := owners(v2.Contents); len(found) != 0 {
+		t.Fatal("unexpected owners without fetch-owner", found)
+	}
+
+	v2, err = svc.ListObjectsV2(&s3.ListObjectsV2Input{Bucket: aws.String(defaultBucket), FetchOwner: aws.Bool(true)})
+	ts.OK(err)
+	if found := owners(v2.Contents); found["default"] != "owner-id" || found["alice"] != "alice-id" {
+		t.Fatal("unexpected owners", found)
+	}
+
+	acl, err := svc.GetObjectAcl(&s3.GetObjectAclInput{
+		Bucket: aws.String(defaultBucket),
+		Key:    aws.String("alice"),
+	})
+	ts.OK(err)
+	if aws.StringValue(acl.Owner.ID) != "alice-id" || len(acl.Grants) != 1 || aws.StringValue(acl.Grants[0].Grantee.ID) != "alice-id" {
+		t.Fatal("unexpected ACL", acl)
+	}
+
+	// The bucket owner is granted access separately, as it no longer owns
+	// the object:
+	ts.OKAll(svc.PutObjectAcl(&s3.PutObjectAclInput{
+		Bucket: aws.String(defaultBucket),
+		Key:    aws.String("alice"),
+		ACL:    aws.String("bucket-owner-full-control"),
+	}))
+	acl, err = svc.GetObjectAcl(&s3.GetObjectAclInput{
+		Bucket: aws.String(defaultBucket),
+		Key:    aws.String("alice"),
+	})
+	ts.OK(err)
+	if aws.StringValue(acl.Owner.ID) != "alice-id" || len(acl.Grants) != 2 || aws.StringValue(acl.Grants[1].Grantee.ID) != "owner-id" {
+		t.Fatal("unexpected ACL", acl)
+	}
+
+	buckets, err := svc.ListBuckets(&s3.ListBucketsInput{})
+	ts.OK(err)
+	if aws.StringValue(buckets.Owner.ID) != "owner-id" {
+		t.Fatal("unexpected bucket owner", buckets.Owner)
+	}
+}
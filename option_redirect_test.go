@@ -0,0 +1,49 @@
+package gofakes3_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/johannesboyne/gofakes3"
+)
+
+func TestRedirect(t *testing.T) {
+	ts := newTestServer(t, withFakerOptions(
+		gofakes3.WithRedirect("/"+defaultBucket+"/moved/", "http://elsewhere.example/", http.StatusPermanentRedirect),
+		gofakes3.WithRedirect("/"+defaultBucket+"/", "http://elsewhere.example", 0),
+		gofakes3.WithRedirect("/"+defaultBucket+"/unreachable", "http://unreachable.example", 0),
+	), withInitialBuckets(defaultBucket, "other"))
+	defer ts.Close()
+	ts.backendPutString("other", "object", nil, "hello")
+
+	client := httpClient()
+	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+
+	for _, tc := range []struct {
+		path     string
+		code     int
+		location string
+	}{
+		{"/" + defaultBucket + "/moved/obj?versionId=1", http.StatusPermanentRedirect, "http://elsewhere.example/" + defaultBucket + "/moved/obj?versionId=1"},
+		{"/" + defaultBucket + "/unreachable", http.StatusTemporaryRedirect, "http://elsewhere.example/" + defaultBucket + "/unreachable"},
+		{"/other/object", http.StatusOK, ""},
+	} {
+		t.Run(tc.path, func(t *testing.T) {
+			rs, err := client.Get(ts.url(tc.path))
+			ts.OK(err)
+			defer rs.Body.Close()
+
+			if rs.StatusCode != tc.code {
+				t.Fatal("expected status", tc.code, "found", rs.StatusCode)
+			}
+			if loc := rs.Header.Get("Location"); loc != tc.location {
+				t.Fatal("expected location", tc.location, "found", loc)
+			}
+			if tc.location != "" && rs.ContentLength > 0 {
+				t.Fatal("expected empty body, found", rs.ContentLength, "bytes")
+			}
+		})
+	}
+}
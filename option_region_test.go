@@ -0,0 +1,115 @@
+package gofakes3_test
+
+import (
+	"encoding/xml"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/johannesboyne/gofakes3"
+)
+
+func TestBucketRegion(t *testing.T) {
+	ts := newTestServer(t, withFakerOptions(
+		// The test client signs requests for 'region':
+		gofakes3.WithBucketRegion(defaultBucket, "region"),
+		gofakes3.WithBucketRegion("elsewhere", "eu-west-1"),
+	), withInitialBuckets(defaultBucket, "elsewhere", "anywhere"))
+	defer ts.Close()
+	svc := ts.s3Client()
+
+	for _, bucket := range []string{defaultBucket, "anywhere"} {
+		ts.OKAll(svc.PutObject(&s3.PutObjectInput{
+			Bucket: aws.String(bucket),
+			Key:    aws.String("object"),
+			Body:   strings.NewReader("hello"),
+		}))
+	}
+
+	location, err := svc.GetBucketLocation(&s3.GetBucketLocationInput{Bucket: aws.String(defaultBucket)})
+	ts.OK(err)
+	if aws.StringValue(location.LocationConstraint) != "region" {
+		t.Fatal("unexpected location", location)
+	}
+
+	_, err = svc.PutObject(&s3.PutObjectInput{
+		Bucket: aws.String("elsewhere"),
+		Key:    aws.String("object"),
+		Body:   strings.NewReader("hello"),
+	})
+	if !hasErrorCode(err, gofakes3.ErrAuthorizationHeaderMalformed) {
+		t.Fatal("expected AuthorizationHeaderMalformed, found", err)
+	}
+	if reqErr, ok := err.(awserr.RequestFailure); !ok || reqErr.StatusCode() != http.StatusBadRequest {
+		t.Fatal("unexpected error", err)
+	}
+
+	rq, _ := svc.GetObjectRequest(&s3.GetObjectInput{Bucket: aws.String("elsewhere"), Key: aws.String("object")})
+	presigned, err := rq.Presign(time.Minute)
+	ts.OK(err)
+	rs, err := httpClient().Get(presigned)
+	ts.OK(err)
+	body, err := ioutil.ReadAll(rs.Body)
+	rs.Body.Close()
+	ts.OK(err)
+	ts.assertRawErrorCode(rs, body, gofakes3.ErrAuthorizationQueryParametersError)
+	if rs.Header.Get(gofakes3.BucketRegionHeader) != "eu-west-1" {
+		t.Fatal("unexpected region header", rs.Header)
+	}
+	var regionErr struct{ Region string }
+	ts.OK(xml.Unmarshal(body, &regionErr))
+	if regionErr.Region != "eu-west-1" {
+		t.Fatal("unexpected region", string(body))
+	}
+
+	// Unsigned requests may use any endpoint that doesn't name a region:
+	rs, body = ts.sendRaw("GET", "/elsewhere/object", nil, nil)
+	ts.assertRawErrorCode(rs, body, gofakes3.ErrNoSuchKey)
+
+	for _, tc := range []struct {
+		host     string
+		path     string
+		code     int
+		endpoint string
+	}{
+		{"s3.eu-west-1.localhost", "/elsewhere", http.StatusOK, ""},
+		{"s3.us-west-2.localhost", "/elsewhere", http.StatusMovedPermanently, "s3.eu-west-1.localhost"},
+		{"s3-us-west-2.localhost:9000", "/elsewhere", http.StatusMovedPermanently, "s3-eu-west-1.localhost:9000"},
+		{"s3.us-west-2.localhost", "/anywhere", http.StatusOK, ""},
+		{"s3.dualstack.us-west-2.amazonaws.com", "/elsewhere", http.StatusMovedPermanently, "s3.dualstack.eu-west-1.amazonaws.com"},
+		{"s3-website-us-west-2.amazonaws.com", "/elsewhere", http.StatusMovedPermanently, "s3-website-eu-west-1.amazonaws.com"},
+
+		// Endpoints that don't name a region are accepted:
+		{"s3.localhost", "/elsewhere", http.StatusOK, ""},
+		{"mybucket.s3.localhost", "/elsewhere", http.StatusOK, ""},
+		{"s3.amazonaws.com", "/elsewhere", http.StatusOK, ""},
+		{"s3.dualstack.localhost", "/elsewhere", http.StatusOK, ""},
+	} {
+		t.Run(tc.host+tc.path, func(t *testing.T) {
+			rq, err := http.NewRequest("GET", ts.url(tc.path), nil)
+			ts.OK(err)
+			rq.Host = tc.host
+			rs, err := httpClient().Do(rq)
+			ts.OK(err)
+			defer rs.Body.Close()
+			body, err := ioutil.ReadAll(rs.Body)
+			ts.OK(err)
+
+			if rs.StatusCode != tc.code {
+				t.Fatal("expected status", tc.code, "found", rs.StatusCode, string(body))
+			}
+			if tc.endpoint != "" {
+				var redirect struct{ Code, Endpoint string }
+				ts.OK(xml.Unmarshal(body, &redirect))
+				if redirect.Code != string(gofakes3.ErrPermanentRedirect) || redirect.Endpoint != tc.endpoint {
+					t.Fatal("unexpected redirect", string(body))
+				}
+			}
+		})
+	}
+}
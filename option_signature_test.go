@@ -0,0 +1,201 @@
+package gofakes3_test
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/xml"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/johannesboyne/gofakes3"
+)
+
+var signatureSecrets = map[string]string{"dummy-access": "dummy-secret"}
+
+func TestSignatureVerificationV4(t *testing.T) {
+	ts := newTestServer(t, withFakerOptions(gofakes3.WithSignatureVerification(signatureSecrets)))
+	defer ts.Close()
+	svc := ts.s3Client()
+
+	ts.OKAll(svc.PutObject(&s3.PutObjectInput{
+		Bucket: aws.String(defaultBucket),
+		Key:    aws.String("some dir/object"),
+		Body:   strings.NewReader("hello"),
+	}))
+	ts.OKAll(svc.ListObjectsV2(&s3.ListObjectsV2Input{
+		Bucket:    aws.String(defaultBucket),
+		Prefix:    aws.String("some dir/"),
+		Delimiter: aws.String("/"),
+	}))
+
+	for _, tc := range []struct {
+		access, secret string
+		code           gofakes3.ErrorCode
+	}{
+		{"dummy-access", "wrong-secret", gofakes3.ErrSignatureDoesNotMatch},
+		{"unknown-access", "dummy-secret", gofakes3.ErrInvalidAccessKeyId},
+	} {
+		other := s3.New(session.New(), svc.Client.Config.Copy().
+			WithCredentials(credentials.NewStaticCredentials(tc.access, tc.secret, "")))
+		_, err := other.GetObject(&s3.GetObjectInput{Bucket: aws.String(defaultBucket), Key: aws.String("some dir/object")})
+		if !hasErrorCode(err, tc.code) {
+			t.Fatal("expected", tc.code, "found", err)
+		}
+	}
+
+	rq, _ := svc.GetObjectRequest(&s3.GetObjectInput{Bucket: aws.String(defaultBucket), Key: aws.String("some dir/object")})
+	presigned, err := rq.Presign(time.Minute)
+	ts.OK(err)
+
+	get := func(u string) (*http.Response, []byte) {
+		t.Helper()
+		rs, err := httpClient().Get(u)
+		ts.OK(err)
+		defer rs.Body.Close()
+		body, err := ioutil.ReadAll(rs.Body)
+		ts.OK(err)
+		return rs, body
+	}
+	if rs, body := get(presigned); rs.StatusCode != http.StatusOK || string(body) != "hello" {
+		t.Fatal("unexpected response", rs.StatusCode, string(body))
+	}
+	rs, body := get(strings.Replace(presigned, "object", "other", 1))
+	ts.assertRawErrorCode(rs, body, gofakes3.ErrSignatureDoesNotMatch)
+	if strings.Contains(string(body), "StringToSign") {
+		t.Fatal("unexpected debugging output", string(body))
+	}
+
+	// The SDK presigns with the wall clock rather than the TimeSource:
+	ts.Advance(time.Since(ts.Now()) + 2*time.Minute)
+	rs, body = get(presigned)
+	ts.assertRawErrorCode(rs, body, gofakes3.ErrAccessDenied)
+
+	// Anonymous requests are not signed:
+	rs, body = ts.sendRaw("GET", defaultBucket+"/some%20dir/object", nil, nil)
+	if rs.StatusCode != http.StatusOK {
+		t.Fatal("unexpected status", rs.StatusCode, string(body))
+	}
+}
+
+func TestSignatureVerificationV2(t *testing.T) {
+	ts := newTestServer(t, withFakerOptions(gofakes3.WithSignatureVerification(signatureSecrets)))
+	defer ts.Close()
+	ts.backendPutString(defaultBucket, "object", nil, "hello")
+
+	sign := func(secret, stringToSign string) string {
+		mac := hmac.New(sha1.New, []byte(secret))
+		mac.Write([]byte(stringToSign))
+		return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+	}
+	date := ts.Now().UTC().Format(http.TimeFormat)
+	stringToSign := "PUT\n\ntext/plain\n" + date + "\nx-amz-meta-colour:blue\n/" + defaultBucket + "/object?tagging"
+	send := func(secret string) (*http.Response, []byte) {
+		t.Helper()
+		body := `<Tagging><TagSet><Tag><Key>k</Key><Value>v</Value></Tag></TagSet></Tagging>`
+		rq, err := http.NewRequest("PUT", ts.url(defaultBucket+"/object?tagging&ignored=1"), strings.NewReader(body))
+		ts.OK(err)
+		rq.Header.Set("Content-Type", "text/plain")
+		rq.Header.Set("Date", date)
+		rq.Header.Set("X-Amz-Meta-Colour", " blue ")
+		rq.Header.Set("Authorization", "AWS dummy-access:"+sign(secret, stringToSign))
+		rs, err := httpClient().Do(rq)
+		ts.OK(err)
+		defer rs.Body.Close()
+		rsBody, err := ioutil.ReadAll(rs.Body)
+		ts.OK(err)
+		return rs, rsBody
+	}
+
+	if rs, body := send("dummy-secret"); rs.StatusCode != http.StatusOK {
+		t.Fatal("unexpected status", rs.StatusCode, string(body))
+	}
+	rs, body := send("wrong-secret")
+	ts.assertRawErrorCode(rs, body, gofakes3.ErrSignatureDoesNotMatch)
+
+	// Presigned URLs sign their expiry time in place of the date:
+	expires := strconv.FormatInt(ts.Now().Add(time.Minute).Unix(), 10)
+	signature := sign("dummy-secret", "GET\n\n\n"+expires+"\n/"+defaultBucket+"/object")
+	presigned := ts.url(defaultBucket+"/object") + "?AWSAccessKeyId=dummy-access&Expires=" + expires + "&Signature=" + url.QueryEscape(signature)
+	rs, err := httpClient().Get(presigned)
+	ts.OK(err)
+	body, err = ioutil.ReadAll(rs.Body)
+	rs.Body.Close()
+	ts.OK(err)
+	if rs.StatusCode != http.StatusOK || string(body) != "hello" {
+		t.Fatal("unexpected response", rs.StatusCode, string(body))
+	}
+}
+
+func TestSignatureDebugging(t *testing.T) {
+	ts := newTestServer(t, withFakerOptions(
+		gofakes3.WithSignatureVerification(signatureSecrets),
+		gofakes3.WithSignatureDebugging(),
+	))
+	defer ts.Close()
+	svc := ts.s3Client()
+
+	type mismatch struct {
+		Code              string
+		AWSAccessKeyId    string
+		SignatureProvided string
+		StringToSign      string
+		CanonicalRequest  string
+	}
+	send := func(rq *http.Request) (m mismatch) {
+		t.Helper()
+		rs, err := httpClient().Do(rq)
+		ts.OK(err)
+		defer rs.Body.Close()
+		body, err := ioutil.ReadAll(rs.Body)
+		ts.OK(err)
+		ts.assertRawErrorCode(rs, body, gofakes3.ErrSignatureDoesNotMatch)
+		ts.OK(xml.Unmarshal(body, &m))
+		return m
+	}
+
+	rq, _ := svc.GetObjectRequest(&s3.GetObjectInput{Bucket: aws.String(defaultBucket), Key: aws.String("object")})
+	presigned, err := rq.Presign(time.Minute)
+	ts.OK(err)
+	u, err := url.Parse(presigned)
+	ts.OK(err)
+	signature := u.Query().Get("X-Amz-Signature")
+	u.Path += "-tampered"
+	get, err := http.NewRequest("GET", u.String(), nil)
+	ts.OK(err)
+
+	m := send(get)
+	if m.AWSAccessKeyId != "dummy-access" || m.SignatureProvided != signature {
+		t.Fatal("unexpected mismatch", m)
+	}
+	if !strings.HasPrefix(m.CanonicalRequest, "GET\n/"+defaultBucket+"/object-tampered\nX-Amz-Algorithm=AWS4-HMAC-SHA256&") {
+		t.Fatal("unexpected canonical request", m.CanonicalRequest)
+	}
+	if !strings.HasPrefix(m.StringToSign, "AWS4-HMAC-SHA256\n"+u.Query().Get("X-Amz-Date")+"\n") {
+		t.Fatal("unexpected string to sign", m.StringToSign)
+	}
+
+	// Signature Version 2 has no canonical request:
+	date := ts.Now().UTC().Format(http.TimeFormat)
+	put, err := http.NewRequest("PUT", ts.url(defaultBucket+"/object?acl"), nil)
+	ts.OK(err)
+	put.Header.Set("Date", date)
+	put.Header.Set("X-Amz-Acl", "private")
+	put.Header.Set("Authorization", "AWS dummy-access:bm90IGEgc2lnbmF0dXJl")
+	m = send(put)
+	if expected := "PUT\n\n\n" + date + "\nx-amz-acl:private\n/" + defaultBucket + "/object?acl"; m.StringToSign != expected {
+		t.Fatalf("unexpected string to sign %q", m.StringToSign)
+	}
+	if m.CanonicalRequest != "" || m.SignatureProvided != "bm90IGEgc2lnbmF0dXJl" {
+		t.Fatal("unexpected mismatch", m)
+	}
+}
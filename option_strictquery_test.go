@@ -0,0 +1,71 @@
+package gofakes3_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/johannesboyne/gofakes3"
+)
+
+func TestStrictQueryParameters(t *testing.T) {
+	ts := newTestServer(t, withFakerOptions(gofakes3.WithStrictQueryParameters()))
+	defer ts.Close()
+	svc := ts.s3Client()
+	ts.backendPutString(defaultBucket, "object", nil, "hello")
+	ts.createMultipartUpload(defaultBucket, "upload", nil)
+
+	for _, path := range []string{
+		defaultBucket + "?prefix=o&max-keys=1&encoding-type=url",
+		defaultBucket + "?list-type=2&fetch-owner=true&start-after=a",
+		defaultBucket + "?uploads&max-uploads=1",
+		defaultBucket + "/object?partNumber=1",
+		defaultBucket + "/object?response-content-type=text/plain&x-id=GetObject",
+		defaultBucket + "/object?tagging",
+	} {
+		rs, body := ts.sendRaw("GET", path, nil, nil)
+		if rs.StatusCode != http.StatusOK && rs.StatusCode != http.StatusPartialContent {
+			t.Fatal("unexpected status for", path, rs.StatusCode, string(body))
+		}
+	}
+
+	for _, tc := range []struct {
+		method, path string
+	}{
+		{"GET", defaultBucket + "?max-keys=1&bogus=1"},
+		{"GET", defaultBucket + "?start-after=a"},
+		{"GET", defaultBucket + "/object?max-keys=1"},
+		{"GET", defaultBucket + "/object?tagging&id=1"},
+		{"PUT", defaultBucket + "/object?versionId=1"},
+	} {
+		rs, body := ts.sendRaw(tc.method, tc.path, nil, nil)
+		ts.assertRawErrorCode(rs, body, gofakes3.ErrInvalidArgument)
+	}
+
+	// The SDK's requests, including presigned URLs, only send what S3 honours:
+	ts.OKAll(svc.ListObjectsV2(&s3.ListObjectsV2Input{Bucket: aws.String(defaultBucket), MaxKeys: aws.Int64(1)}))
+	ts.OKAll(svc.ListObjectVersions(&s3.ListObjectVersionsInput{Bucket: aws.String(defaultBucket)}))
+	rq, _ := svc.GetObjectRequest(&s3.GetObjectInput{Bucket: aws.String(defaultBucket), Key: aws.String("object")})
+	presigned, err := rq.Presign(time.Minute)
+	ts.OK(err)
+	rs, err := httpClient().Get(presigned)
+	ts.OK(err)
+	body, _ := ioutil.ReadAll(rs.Body)
+	rs.Body.Close()
+	if rs.StatusCode != http.StatusOK || string(body) != "hello" {
+		t.Fatal("unexpected response", rs.StatusCode, string(body))
+	}
+}
+
+func TestStrictQueryParametersDisabled(t *testing.T) {
+	ts := newTestServer(t)
+	defer ts.Close()
+
+	rs, body := ts.sendRaw("GET", defaultBucket+"?bogus=1", nil, nil)
+	if rs.StatusCode != http.StatusOK {
+		t.Fatal("unexpected status", rs.StatusCode, string(body))
+	}
+}
@@ -0,0 +1,31 @@
+package gofakes3_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/johannesboyne/gofakes3"
+)
+
+func TestSyntheticTree(t *testing.T) {
+	ts := newTestServer(t, withFakerOptions(gofakes3.WithSyntheticTree("tree", 2, 11)))
+	defer ts.Close()
+	svc := ts.s3Client()
+
+	var prefixes, keys []string
+	for i := 0; i < 11; i++ {
+		prefixes = append(prefixes, fmt.Sprintf("%02d/", i))
+		keys = append(keys, fmt.Sprintf("10/%02d", i))
+	}
+	ts.assertLs("tree", "", prefixes, nil)
+	ts.assertLs("tree", "10/", nil, keys)
+	ts.assertObject("tree", "03/07", nil, "03/07")
+
+	rs, err := svc.ListObjectsV2(&s3.ListObjectsV2Input{Bucket: aws.String("tree")})
+	ts.OK(err)
+	if aws.Int64Value(rs.KeyCount) != 121 {
+		t.Fatal("unexpected key count", aws.Int64Value(rs.KeyCount))
+	}
+}
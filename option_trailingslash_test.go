@@ -0,0 +1,68 @@
+package gofakes3_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/johannesboyne/gofakes3"
+)
+
+func TestPreserveTrailingSlash(t *testing.T) {
+	put := func(ts *testServer, key, body string) {
+		ts.Helper()
+		ts.OKAll(ts.s3Client().PutObject(&s3.PutObjectInput{
+			Bucket: aws.String(defaultBucket),
+			Key:    aws.String(key),
+			Body:   strings.NewReader(body),
+		}))
+	}
+
+	t.Run("default", func(t *testing.T) {
+		ts := newTestServer(t)
+		defer ts.Close()
+
+		put(ts, "folder/", "")
+		if !ts.backendObjectExists(defaultBucket, "folder") || ts.backendObjectExists(defaultBucket, "folder/") {
+			t.Fatal("expected the trailing slash to be stripped")
+		}
+	})
+
+	t.Run("preserved", func(t *testing.T) {
+		ts := newTestServer(t, withFakerOptions(gofakes3.WithPreserveTrailingSlash()))
+		defer ts.Close()
+		svc := ts.s3Client()
+
+		put(ts, "folder/", "")
+		put(ts, "folder", "file")
+		ts.assertObject(defaultBucket, "folder/", nil, "")
+		ts.assertObject(defaultBucket, "folder", nil, "file")
+
+		_, err := svc.HeadObject(&s3.HeadObjectInput{
+			Bucket: aws.String(defaultBucket),
+			Key:    aws.String("folder/"),
+		})
+		ts.OK(err)
+
+		rs, err := svc.ListObjectsV2(&s3.ListObjectsV2Input{Bucket: aws.String(defaultBucket)})
+		ts.OK(err)
+		if len(rs.Contents) != 2 || aws.StringValue(rs.Contents[0].Key) != "folder" || aws.StringValue(rs.Contents[1].Key) != "folder/" {
+			t.Fatal("unexpected listing", rs.Contents)
+		}
+
+		ts.OKAll(svc.DeleteObject(&s3.DeleteObjectInput{
+			Bucket: aws.String(defaultBucket),
+			Key:    aws.String("folder/"),
+		}))
+		if ts.backendObjectExists(defaultBucket, "folder/") || !ts.backendObjectExists(defaultBucket, "folder") {
+			t.Fatal("expected only the folder marker to be deleted")
+		}
+
+		// The bucket itself is still reachable with a trailing slash:
+		lrs, body := ts.sendRaw("GET", defaultBucket+"/", nil, nil)
+		if lrs.StatusCode != 200 || !strings.Contains(string(body), "<Key>folder</Key>") {
+			t.Fatal("unexpected bucket listing", lrs.StatusCode, string(body))
+		}
+	})
+}
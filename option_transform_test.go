@@ -0,0 +1,124 @@
+package gofakes3_test
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/hex"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/johannesboyne/gofakes3"
+)
+
+func reverseBytes(data []byte) []byte {
+	out := make([]byte, len(data))
+	for i, b := range data {
+		out[len(data)-1-i] = b
+	}
+	return out
+}
+
+func TestStoreAndLoadTransform(t *testing.T) {
+	ts := newTestServer(t, withFakerOptions(
+		gofakes3.WithStoreTransform(reverseBytes),
+		gofakes3.WithLoadTransform(reverseBytes),
+	))
+	defer ts.Close()
+	svc := ts.s3Client()
+
+	sum := md5.Sum([]byte("hello"))
+	etag := `"` + hex.EncodeToString(sum[:]) + `"`
+
+	out, err := svc.PutObject(&s3.PutObjectInput{
+		Bucket: aws.String(defaultBucket),
+		Key:    aws.String("object"),
+		Body:   strings.NewReader("hello"),
+	})
+	ts.OK(err)
+	if aws.StringValue(out.ETag) != etag {
+		t.Fatal("unexpected etag", aws.StringValue(out.ETag))
+	}
+
+	// The Backend holds the transformed contents:
+	if body := ts.backendGetString(defaultBucket, "object", nil); body != "olleh" {
+		t.Fatal("unexpected stored contents", body)
+	}
+
+	rs, body := ts.sendRaw("GET", defaultBucket+"/object", nil, nil)
+	if rs.StatusCode != http.StatusOK || string(body) != "hello" || rs.Header.Get("ETag") != etag {
+		t.Fatal("unexpected response", rs.StatusCode, string(body), rs.Header.Get("ETag"))
+	}
+	for k := range rs.Header {
+		if strings.HasPrefix(k, "X-Gofakes3-") {
+			t.Fatal("unexpected header", k)
+		}
+	}
+	rs, _ = ts.sendRaw("HEAD", defaultBucket+"/object", nil, nil)
+	if rs.Header.Get("Content-Length") != "5" || rs.Header.Get("ETag") != etag {
+		t.Fatal("unexpected head", rs.Header)
+	}
+	rs, body = ts.sendRaw("GET", defaultBucket+"/object", nil, http.Header{"Range": {"bytes=1-2"}})
+	if rs.StatusCode != http.StatusPartialContent || string(body) != "el" || rs.Header.Get("Content-Range") != "bytes 1-2/5" {
+		t.Fatal("unexpected range", rs.StatusCode, string(body), rs.Header.Get("Content-Range"))
+	}
+
+	// Conditional requests use the ETag of the untransformed contents:
+	rs, _ = ts.sendRaw("GET", defaultBucket+"/object", nil, http.Header{"If-None-Match": {etag}})
+	if rs.StatusCode != http.StatusNotModified {
+		t.Fatal("unexpected status", rs.StatusCode)
+	}
+	rs, body = ts.sendRaw("PUT", defaultBucket+"/object", []byte("world"), http.Header{"If-Match": {etag}})
+	if rs.StatusCode != http.StatusOK {
+		t.Fatal("unexpected status", rs.StatusCode, string(body))
+	}
+
+	// Copies are loaded from the source and stored again:
+	ts.OKAll(svc.CopyObject(&s3.CopyObjectInput{
+		Bucket:     aws.String(defaultBucket),
+		Key:        aws.String("copy"),
+		CopySource: aws.String(defaultBucket + "/object"),
+	}))
+	if body := ts.backendGetString(defaultBucket, "copy", nil); body != "dlrow" {
+		t.Fatal("unexpected stored copy", body)
+	}
+
+	// Objects put straight into the Backend are left alone:
+	ts.backendPutString(defaultBucket, "plain", nil, "plain")
+	rs, body = ts.sendRaw("GET", defaultBucket+"/plain", nil, http.Header{"Range": {"bytes=0-1"}})
+	if string(body) != "pl" {
+		t.Fatal("unexpected contents", rs.StatusCode, string(body))
+	}
+}
+
+func TestStoreTransformCorruption(t *testing.T) {
+	corrupt := func(data []byte) []byte {
+		out := append([]byte(nil), data...)
+		out[0] ^= 0xff
+		return out
+	}
+	ts := newTestServer(t, withFakerOptions(gofakes3.WithStoreTransform(corrupt)))
+	defer ts.Close()
+
+	// A multipart upload assembled by GoFakeS3 is transformed as a whole:
+	id := ts.createMultipartUpload(defaultBucket, "object", nil)
+	part := ts.uploadPart(defaultBucket, "object", id, 1, []byte("hello"))
+	ts.OKAll(ts.s3Client().CompleteMultipartUpload(&s3.CompleteMultipartUploadInput{
+		Bucket:          aws.String(defaultBucket),
+		Key:             aws.String("object"),
+		UploadId:        aws.String(id),
+		MultipartUpload: &s3.CompletedMultipartUpload{Parts: []*s3.CompletedPart{part}},
+	}))
+
+	rs, err := ts.s3Client().GetObject(&s3.GetObjectInput{Bucket: aws.String(defaultBucket), Key: aws.String("object")})
+	ts.OK(err)
+	defer rs.Body.Close()
+	body, err := ioutil.ReadAll(rs.Body)
+	ts.OK(err)
+	if bytes.Equal(body, []byte("hello")) || len(body) != 5 {
+		t.Fatal("contents not corrupted", string(body))
+	}
+}
@@ -0,0 +1,78 @@
+package gofakes3_test
+
+import (
+	"io"
+	"io/ioutil"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/johannesboyne/gofakes3"
+)
+
+func TestTruncatedBodies(t *testing.T) {
+	ts := newTestServer(t, withFakerOptions(
+		gofakes3.WithTruncatedBodies("corrupt/*", 0.5),
+	))
+	defer ts.Close()
+	ts.backendPutString(defaultBucket, "corrupt/obj", nil, "0123456789")
+	ts.backendPutString(defaultBucket, "intact/obj", nil, "0123456789")
+
+	get := func(key string, header http.Header) (*http.Response, []byte, error) {
+		t.Helper()
+		rq, err := http.NewRequest("GET", ts.url(defaultBucket+"/"+key), nil)
+		ts.OK(err)
+		for k, v := range header {
+			rq.Header[k] = v
+		}
+		rs, err := httpClient().Do(rq)
+		ts.OK(err)
+		defer rs.Body.Close()
+		body, err := ioutil.ReadAll(rs.Body)
+		return rs, body, err
+	}
+
+	rs, body, err := get("corrupt/obj", nil)
+	if err != io.ErrUnexpectedEOF {
+		t.Fatal("expected unexpected EOF, found", err)
+	}
+	if rs.ContentLength != 10 || string(body) != "01234" {
+		t.Fatal("unexpected response", rs.ContentLength, string(body))
+	}
+
+	rs, body, err = get("corrupt/obj", http.Header{"Range": {"bytes=2-5"}})
+	if err != io.ErrUnexpectedEOF || rs.ContentLength != 4 || string(body) != "23" {
+		t.Fatal("unexpected response", err, rs.ContentLength, string(body))
+	}
+
+	_, body, err = get("intact/obj", nil)
+	ts.OK(err)
+	if string(body) != "0123456789" {
+		t.Fatal("unexpected body", string(body))
+	}
+}
+
+func TestStalledBodies(t *testing.T) {
+	ts := newTestServer(t, withFakerOptions(
+		gofakes3.WithStalledBodies("slow/*", 0.3, time.Minute),
+	))
+	defer ts.Close()
+	ts.backendPutString(defaultBucket, "slow/obj", nil, "0123456789")
+
+	start := ts.Now()
+	rs, err := httpClient().Get(ts.url(defaultBucket + "/slow/obj"))
+	ts.OK(err)
+	defer rs.Body.Close()
+	body, err := ioutil.ReadAll(rs.Body)
+	if err != io.ErrUnexpectedEOF {
+		t.Fatal("expected unexpected EOF, found", err)
+	}
+	if rs.StatusCode != http.StatusOK || rs.ContentLength != 10 || string(body) != "012" {
+		t.Fatal("unexpected response", rs.StatusCode, rs.ContentLength, string(body))
+	}
+
+	// The fixed TimeSource advances rather than waiting:
+	if stalled := ts.Now().Sub(start); stalled != time.Minute {
+		t.Fatal("unexpected stall", stalled)
+	}
+}
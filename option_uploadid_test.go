@@ -0,0 +1,54 @@
+package gofakes3_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/johannesboyne/gofakes3"
+)
+
+func TestUploadIDGenerator(t *testing.T) {
+	var n int
+	ts := newTestServer(t, withFakerOptions(gofakes3.WithUploadIDGenerator(func() gofakes3.UploadID {
+		n++
+		return gofakes3.UploadID(fmt.Sprintf("upload-%d", n))
+	})))
+	defer ts.Close()
+	svc := ts.s3Client()
+
+	create := func() (string, error) {
+		rs, err := svc.CreateMultipartUpload(&s3.CreateMultipartUploadInput{
+			Bucket: aws.String(defaultBucket),
+			Key:    aws.String("object"),
+		})
+		if err != nil {
+			return "", err
+		}
+		return aws.StringValue(rs.UploadId), nil
+	}
+
+	for _, expected := range []string{"upload-1", "upload-2"} {
+		id, err := create()
+		ts.OK(err)
+		if id != expected {
+			t.Fatal("expected upload ID", expected, "found", id)
+		}
+	}
+	ts.OKAll(svc.AbortMultipartUpload(&s3.AbortMultipartUploadInput{
+		Bucket:   aws.String(defaultBucket),
+		Key:      aws.String("object"),
+		UploadId: aws.String("upload-1"),
+	}))
+
+	// IDs that are in use can't be handed out again, but those of finished
+	// uploads can. The SDK would retry the InternalError:
+	n = 1
+	rs, body := ts.sendRaw("POST", defaultBucket+"/object?uploads", nil, nil)
+	ts.assertRawErrorCode(rs, body, gofakes3.ErrInternal)
+	n = 0
+	if id, err := create(); err != nil || id != "upload-1" {
+		t.Fatal("unexpected upload", id, err)
+	}
+}
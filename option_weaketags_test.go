@@ -0,0 +1,50 @@
+package gofakes3_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/johannesboyne/gofakes3"
+)
+
+func TestWeakETags(t *testing.T) {
+	ts := newTestServer(t, withFakerOptions(
+		gofakes3.WithWeakETags(5),
+	))
+	defer ts.Close()
+
+	ts.backendPutString(defaultBucket, "small", nil, "hello")
+	ts.backendPutString(defaultBucket, "large", nil, "hello world")
+	const smallETag = `"5d41402abc4b2a76b9719d911017c592"`
+	const largeETag = `W/"5eb63bbbe01eeed093cb22bb8f5acdc3"`
+
+	for _, tc := range []struct{ key, etag string }{{"small", smallETag}, {"large", largeETag}} {
+		for _, method := range []string{"GET", "HEAD"} {
+			rs, _ := ts.sendRaw(method, defaultBucket+"/"+tc.key, nil, nil)
+			if etag := rs.Header.Get("ETag"); etag != tc.etag {
+				t.Fatal("unexpected etag", method, tc.key, etag)
+			}
+		}
+	}
+
+	// If-None-Match compares weakly, so the tag matches with or without W/:
+	for _, inm := range []string{largeETag, largeETag[2:]} {
+		rs, _ := ts.sendRaw("GET", defaultBucket+"/large", nil, http.Header{"If-None-Match": {inm}})
+		if rs.StatusCode != http.StatusNotModified {
+			t.Fatal("unexpected status for", inm, rs.StatusCode)
+		}
+	}
+
+	// If-Range needs a strong ETag, so a weak one never matches and the
+	// whole object is sent:
+	for _, ifRange := range []string{largeETag, largeETag[2:]} {
+		rs, body := ts.sendRaw("GET", defaultBucket+"/large", nil, http.Header{"Range": {"bytes=0-1"}, "If-Range": {ifRange}})
+		if rs.StatusCode != http.StatusOK || string(body) != "hello world" {
+			t.Fatal("unexpected response for", ifRange, rs.StatusCode, string(body))
+		}
+	}
+	rs, body := ts.sendRaw("GET", defaultBucket+"/small", nil, http.Header{"Range": {"bytes=0-1"}, "If-Range": {smallETag}})
+	if rs.StatusCode != http.StatusPartialContent || string(body) != "he" {
+		t.Fatal("unexpected response", rs.StatusCode, string(body))
+	}
+}
@@ -0,0 +1,36 @@
+package gofakes3_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/johannesboyne/gofakes3"
+)
+
+func TestWriteByteLimit(t *testing.T) {
+	ts := newTestServer(t, withFakerOptions(
+		gofakes3.WithWriteByteLimit(10),
+	))
+	defer ts.Close()
+
+	rs, body := ts.sendRaw("PUT", defaultBucket+"/first", []byte("hello"), nil)
+	if rs.StatusCode != http.StatusOK {
+		t.Fatal("unexpected status", rs.StatusCode, string(body))
+	}
+
+	// The write that takes the total over the limit fails, whether it
+	// creates an object or replaces one:
+	rs, body = ts.sendRaw("PUT", defaultBucket+"/second", []byte("0123456789"), nil)
+	ts.assertRawErrorCode(rs, body, gofakes3.ErrInternal)
+	rs, body = ts.sendRaw("PUT", defaultBucket+"/first", []byte("0123456789"), nil)
+	ts.assertRawErrorCode(rs, body, gofakes3.ErrInternal)
+
+	ts.assertObject(defaultBucket, "first", nil, "hello")
+	if ts.backendObjectExists(defaultBucket, "second") {
+		t.Fatal("partial object stored")
+	}
+
+	// The space is not freed, so even an empty write fails from now on:
+	rs, body = ts.sendRaw("PUT", defaultBucket+"/empty", nil, nil)
+	ts.assertRawErrorCode(rs, body, gofakes3.ErrInternal)
+}
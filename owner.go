@@ -0,0 +1,84 @@
+package gofakes3
+
+import (
+	"net/http"
+	"strings"
+)
+
+const objectOwnerConfig = "owner"
+
+// requestAccessKey returns the access key ID a request was signed with, from
+// either the 'Authorization' header or the query string of a presigned URL,
+// for both Signature Version 4 and 2. It returns an empty string for
+// anonymous requests.
+func requestAccessKey(r *http.Request) string {
+	auth := r.Header.Get("Authorization")
+	switch {
+	case strings.HasPrefix(auth, "AWS4-HMAC-SHA256 "):
+		for _, field := range strings.Split(strings.TrimPrefix(auth, "AWS4-HMAC-SHA256 "), ",") {
+			field = strings.TrimSpace(field)
+			if strings.HasPrefix(field, "Credential=") {
+				return strings.SplitN(strings.TrimPrefix(field, "Credential="), "/", 2)[0]
+			}
+		}
+		return ""
+	case strings.HasPrefix(auth, "AWS "):
+		return strings.SplitN(strings.TrimPrefix(auth, "AWS "), ":", 2)[0]
+	}
+
+	q := r.URL.Query()
+	if credential := q.Get("X-Amz-Credential"); credential != "" {
+		return strings.SplitN(credential, "/", 2)[0]
+	}
+	return q.Get("AWSAccessKeyId")
+}
+
+// requestOwner returns the owner of objects created by the request: the owner
+// registered with WithOwners for the request's access key, if there is one,
+// or else the default owner.
+func (g *GoFakeS3) requestOwner(r *http.Request) UserInfo {
+	if owner, ok := g.owners[requestAccessKey(r)]; ok {
+		return owner
+	}
+	return g.defaultOwner
+}
+
+// setObjectOwner records the owner of a newly created object version. Objects
+// owned by the default owner are not recorded, so that buckets used by a
+// single principal don't pay for owner lookups when they are listed.
+func (g *GoFakeS3) setObjectOwner(bucket, object string, version VersionID, r *http.Request) {
+	if owner := g.requestOwner(r); owner != g.defaultOwner {
+		g.configs.put(resourceConfigKey{bucket: bucket, object: object, version: version, kind: objectOwnerConfig}, owner)
+	}
+}
+
+// objectOwner returns the owner of an object version.
+func (g *GoFakeS3) objectOwner(bucket, object string, version VersionID) UserInfo {
+	if config, ok := g.configs.get(resourceConfigKey{bucket: bucket, object: object, version: version, kind: objectOwnerConfig}); ok {
+		return config.(UserInfo)
+	}
+	return g.defaultOwner
+}
+
+// fillListOwners sets the Owner of every item in a listing. The current
+// version of each object is only looked up if the bucket has objects with an
+// owner other than the default one; otherwise the owner the Backend returned
+// is kept, or the default owner is used if it did not return one.
+func (g *GoFakeS3) fillListOwners(bucket string, contents []*Content) error {
+	tracked := g.configs.hasKind(bucket, objectOwnerConfig)
+	for _, item := range contents {
+		if tracked {
+			obj, err := g.storage.HeadObject(bucket, item.Key)
+			if HasErrorCode(err, ErrNoSuchKey) {
+				continue
+			} else if err != nil {
+				return err
+			}
+			owner := g.objectOwner(bucket, item.Key, obj.VersionID)
+			item.Owner = &owner
+		} else if item.Owner == nil {
+			item.Owner = &UserInfo{ID: g.defaultOwner.ID, DisplayName: g.defaultOwner.DisplayName}
+		}
+	}
+	return nil
+}
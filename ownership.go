@@ -0,0 +1,68 @@
+package gofakes3
+
+import "net/http"
+
+const bucketOwnershipControlsConfig = "ownershipControls"
+
+// ObjectOwnershipHeader may be sent with a CreateBucket request to set the
+// bucket's OwnershipControls at creation time.
+const ObjectOwnershipHeader = "X-Amz-Object-Ownership"
+
+// bucketObjectOwnership returns the bucket's ObjectOwnership setting, or an
+// empty string if it has no OwnershipControls.
+func (g *GoFakeS3) bucketObjectOwnership(bucket string) ObjectOwnership {
+	config, ok := g.configs.get(resourceConfigKey{bucket: bucket, kind: bucketOwnershipControlsConfig})
+	if !ok {
+		return ""
+	}
+	controls := config.(*OwnershipControls)
+	if len(controls.Rules) == 0 {
+		return ""
+	}
+	return controls.Rules[0].ObjectOwnership
+}
+
+func (g *GoFakeS3) getBucketOwnershipControls(bucket string, w http.ResponseWriter, r *http.Request) error {
+	g.log.Print(LogInfo, "GET BUCKET OWNERSHIP CONTROLS:", bucket)
+
+	if err := g.ensureBucketExists(bucket); err != nil {
+		return err
+	}
+
+	config, ok := g.configs.get(resourceConfigKey{bucket: bucket, kind: bucketOwnershipControlsConfig})
+	if !ok {
+		return ResourceError(ErrOwnershipControlsNotFound, bucket)
+	}
+	return g.xmlEncoder(w).Encode(config)
+}
+
+func (g *GoFakeS3) putBucketOwnershipControls(bucket string, w http.ResponseWriter, r *http.Request) error {
+	g.log.Print(LogInfo, "PUT BUCKET OWNERSHIP CONTROLS:", bucket)
+
+	if err := g.ensureBucketExists(bucket); err != nil {
+		return err
+	}
+
+	var in OwnershipControls
+	if err := g.xmlDecodeBody(r.Body, &in); err != nil {
+		return err
+	}
+	if len(in.Rules) != 1 || !in.Rules[0].ObjectOwnership.Valid() {
+		return ErrMalformedXML
+	}
+
+	g.configs.put(resourceConfigKey{bucket: bucket, kind: bucketOwnershipControlsConfig}, &in)
+	return nil
+}
+
+func (g *GoFakeS3) deleteBucketOwnershipControls(bucket string, w http.ResponseWriter, r *http.Request) error {
+	g.log.Print(LogInfo, "DELETE BUCKET OWNERSHIP CONTROLS:", bucket)
+
+	if err := g.ensureBucketExists(bucket); err != nil {
+		return err
+	}
+
+	g.configs.delete(resourceConfigKey{bucket: bucket, kind: bucketOwnershipControlsConfig})
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
@@ -0,0 +1,97 @@
+package gofakes3_test
+
+import (
+	"encoding/xml"
+	"net/http"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/johannesboyne/gofakes3"
+)
+
+func TestBucketOwnershipControls(t *testing.T) {
+	ts := newTestServer(t)
+	defer ts.Close()
+
+	const path = defaultBucket + "?ownershipControls"
+
+	rs, body := ts.sendRaw("GET", path, nil, nil)
+	ts.assertRawErrorCode(rs, body, gofakes3.ErrOwnershipControlsNotFound)
+
+	rs, body = ts.sendRaw("PUT", path, []byte(`<OwnershipControls xmlns="http://s3.amazonaws.com/doc/2006-03-01/">`+
+		`<Rule><ObjectOwnership>BucketOwnerEnforced</ObjectOwnership></Rule>`+
+		`</OwnershipControls>`), nil)
+	if rs.StatusCode != http.StatusOK {
+		t.Fatal("unexpected status", rs.StatusCode, string(body))
+	}
+
+	rs, body = ts.sendRaw("GET", path, nil, nil)
+	if rs.StatusCode != http.StatusOK {
+		t.Fatal("unexpected status", rs.StatusCode, string(body))
+	}
+	var controls gofakes3.OwnershipControls
+	ts.OK(xml.Unmarshal(body, &controls))
+	if len(controls.Rules) != 1 || controls.Rules[0].ObjectOwnership != gofakes3.ObjectOwnershipBucketOwnerEnforced {
+		t.Fatal("unexpected controls", controls)
+	}
+
+	t.Run("acls-rejected", func(t *testing.T) {
+		svc := ts.s3Client()
+		_, err := svc.PutObject(&s3.PutObjectInput{
+			Bucket: aws.String(defaultBucket),
+			Key:    aws.String("object"),
+			ACL:    aws.String(s3.ObjectCannedACLPublicRead),
+		})
+		if !hasErrorCode(err, gofakes3.ErrAccessControlListNotSupported) {
+			t.Fatal("expected ErrAccessControlListNotSupported, found", err)
+		}
+
+		// bucket-owner-full-control is still accepted when ACLs are disabled:
+		ts.OKAll(svc.PutObject(&s3.PutObjectInput{
+			Bucket: aws.String(defaultBucket),
+			Key:    aws.String("object"),
+			ACL:    aws.String(s3.ObjectCannedACLBucketOwnerFullControl),
+		}))
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		rs, body := ts.sendRaw("PUT", path, []byte(`<OwnershipControls><Rule><ObjectOwnership>Nope</ObjectOwnership></Rule></OwnershipControls>`), nil)
+		ts.assertRawErrorCode(rs, body, gofakes3.ErrMalformedXML)
+	})
+
+	rs, body = ts.sendRaw("DELETE", path, nil, nil)
+	if rs.StatusCode != http.StatusNoContent {
+		t.Fatal("unexpected status", rs.StatusCode, string(body))
+	}
+	rs, body = ts.sendRaw("GET", path, nil, nil)
+	ts.assertRawErrorCode(rs, body, gofakes3.ErrOwnershipControlsNotFound)
+}
+
+func TestCreateBucketWithObjectOwnership(t *testing.T) {
+	ts := newTestServer(t)
+	defer ts.Close()
+
+	rs, body := ts.sendRaw("PUT", "enforced", nil, http.Header{
+		gofakes3.ObjectOwnershipHeader: {string(gofakes3.ObjectOwnershipBucketOwnerEnforced)},
+	})
+	if rs.StatusCode != http.StatusOK {
+		t.Fatal("unexpected status", rs.StatusCode, string(body))
+	}
+
+	rs, body = ts.sendRaw("GET", "enforced?ownershipControls", nil, nil)
+	if rs.StatusCode != http.StatusOK {
+		t.Fatal("unexpected status", rs.StatusCode, string(body))
+	}
+	var controls gofakes3.OwnershipControls
+	ts.OK(xml.Unmarshal(body, &controls))
+	if len(controls.Rules) != 1 || controls.Rules[0].ObjectOwnership != gofakes3.ObjectOwnershipBucketOwnerEnforced {
+		t.Fatal("unexpected controls", controls)
+	}
+
+	rs, body = ts.sendRaw("PUT", "acl", nil, http.Header{
+		gofakes3.ObjectOwnershipHeader: {string(gofakes3.ObjectOwnershipBucketOwnerEnforced)},
+		"X-Amz-Acl":                    {"public-read"},
+	})
+	ts.assertRawErrorCode(rs, body, gofakes3.ErrInvalidBucketAclWithObjectOwnership)
+}
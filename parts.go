@@ -0,0 +1,158 @@
+package gofakes3
+
+import (
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// PartsCountHeader is returned by GET and HEAD object requests that name a
+// part with the 'partNumber' parameter, and gives how many parts the object
+// has.
+const PartsCountHeader = "X-Amz-Mp-Parts-Count"
+
+const objectPartsConfig = "parts"
+
+// objectPart records where a part of a completed multipart upload lies in
+// the object it was assembled into.
+type objectPart struct {
+	number int
+	etag   string
+	offset int64
+	size   int64
+}
+
+// uploadedParts collects the parts a CompleteMultipartUpload request
+// assembles, in the order they appear in the object. This must be done
+// before the upload is completed, which discards its parts. If a part was
+// never uploaded, nil is returned, as completing the upload will fail.
+func (g *GoFakeS3) uploadedParts(bucket, object string, uploadID UploadID, in *CompleteMultipartUploadRequest) ([]objectPart, error) {
+	uploaded := make(map[int]ListMultipartUploadPartItem)
+	for marker := 0; ; {
+		list, err := g.multipart.ListParts(bucket, object, uploadID, marker, MaxUploadPartsLimit)
+		if err != nil {
+			return nil, err
+		}
+		for _, part := range list.Parts {
+			uploaded[part.PartNumber] = part
+		}
+		if !list.IsTruncated {
+			break
+		}
+		marker = list.NextPartNumberMarker
+	}
+
+	parts := make([]objectPart, 0, len(in.Parts))
+	var offset int64
+	for _, in := range in.Parts {
+		part, ok := uploaded[in.PartNumber]
+		if !ok {
+			return nil, nil
+		}
+		parts = append(parts, objectPart{
+			number: in.PartNumber,
+			etag:   strings.Trim(part.ETag, `"`),
+			offset: offset,
+			size:   part.Size,
+		})
+		offset += part.Size
+	}
+	return parts, nil
+}
+
+// objectParts returns the parts an object version was assembled from, if it
+// was created by completing a multipart upload.
+func (g *GoFakeS3) objectParts(bucket, object string, obj *Object) ([]objectPart, bool) {
+	config, ok := g.configs.get(resourceConfigKey{bucket: bucket, object: object, version: obj.VersionID, kind: objectPartsConfig})
+	if !ok {
+		return nil, false
+	}
+	return config.([]objectPart), true
+}
+
+// requestedPart returns the part named by the 'partNumber' parameter of a GET
+// or HEAD object request, and how many parts the object has, or nil if the
+// parameter was not sent. Like S3, an object that was not uploaded in parts
+// has a single part, number 1.
+func (g *GoFakeS3) requestedPart(bucket, object string, obj *Object, r *http.Request) (part *objectPart, count int, err error) {
+	query := r.URL.Query()
+	if _, ok := query["partNumber"]; !ok {
+		return nil, 0, nil
+	}
+	if r.Header.Get("Range") != "" {
+		return nil, 0, ErrorMessage(ErrInvalidRequest, "Cannot specify both Range header and partNumber query parameter")
+	}
+
+	number, err := strconv.Atoi(query.Get("partNumber"))
+	if err != nil || number < 1 || number > MaxUploadPartNumber {
+		return nil, 0, ErrorInvalidArgument("partNumber", query.Get("partNumber"), fmt.Sprintf("Part number must be an integer between 1 and %d, inclusive", MaxUploadPartNumber))
+	}
+
+	parts, ok := g.objectParts(bucket, object, obj)
+	if !ok {
+		parts = []objectPart{{number: 1, etag: hex.EncodeToString(obj.Hash), size: obj.Size}}
+	}
+	for idx := range parts {
+		if parts[idx].number == number {
+			if len(parts) > 1 && !g.supportsRanges(bucket) {
+				// Only the whole object can be read:
+				return nil, 0, ErrNotImplemented
+			}
+			return &parts[idx], len(parts), nil
+		}
+	}
+	return nil, 0, ErrInvalidPartNumber
+}
+
+// rangeRequest returns the range of the object the part covers.
+func (p *objectPart) rangeRequest() *ObjectRangeRequest {
+	if p.size == 0 {
+		return nil
+	}
+	return &ObjectRangeRequest{Start: p.offset, End: p.offset + p.size - 1}
+}
+
+// writeHeaders returns the part's own ETag for a HEAD request, rather than
+// the object's, so that clients can check each part against the ETag they
+// computed when uploading it. S3 itself only returns the object's, which GET
+// requests still get.
+func (p *objectPart) writeHeaders(count int, w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodHead {
+		w.Header().Set("ETag", quoteETag(p.etag))
+	}
+	w.Header().Set(PartsCountHeader, strconv.Itoa(count))
+}
+
+// getObjectAttributesParts returns the ObjectParts attribute for
+// GetObjectAttributes, paged with the 'x-amz-max-parts' and
+// 'x-amz-part-number-marker' headers.
+func getObjectAttributesParts(parts []objectPart, h http.Header) (*GetObjectAttributesParts, error) {
+	maxParts, err := parseClampedInt(h.Get("X-Amz-Max-Parts"), DefaultMaxUploadParts, 0, MaxUploadPartsLimit)
+	if err != nil {
+		return nil, ErrorInvalidArgument("x-amz-max-parts", h.Get("X-Amz-Max-Parts"), "Argument max-parts must be an integer between 0 and 2147483647")
+	}
+	marker, err := parseClampedInt(h.Get("X-Amz-Part-Number-Marker"), 0, 0, MaxUploadPartNumber)
+	if err != nil {
+		return nil, ErrorInvalidArgument("x-amz-part-number-marker", h.Get("X-Amz-Part-Number-Marker"), "Argument part-number-marker must be an integer between 0 and 10000")
+	}
+
+	out := &GetObjectAttributesParts{
+		PartsCount:       len(parts),
+		PartNumberMarker: int(marker),
+		MaxParts:         maxParts,
+	}
+	for _, part := range parts {
+		if part.number <= int(marker) {
+			continue
+		}
+		if int64(len(out.Parts)) >= maxParts {
+			out.IsTruncated = true
+			break
+		}
+		out.Parts = append(out.Parts, ObjectPart{PartNumber: part.number, Size: part.size, ETag: quoteETag(part.etag)})
+		out.NextPartNumberMarker = part.number
+	}
+	return out, nil
+}
@@ -0,0 +1,112 @@
+package gofakes3
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"strconv"
+	"strings"
+)
+
+// postPolicy is the policy document of a browser-based upload, sent
+// base64-encoded in the 'policy' form field:
+// https://docs.aws.amazon.com/AmazonS3/latest/API/sigv4-HTTPPOSTConstructPolicy.html
+//
+// Signatures are not verified, and of the conditions only
+// 'content-length-range' is enforced.
+type postPolicy struct {
+	Expiration string        `json:"expiration"`
+	Conditions []interface{} `json:"conditions"`
+}
+
+// postFormValue returns the value of a form field of a browser-based upload.
+// Like S3, field names are matched case-insensitively.
+func postFormValue(values map[string][]string, name string) (string, bool) {
+	for k, v := range values {
+		if strings.EqualFold(k, name) && len(v) > 0 {
+			return v[0], true
+		}
+	}
+	return "", false
+}
+
+func parsePostPolicy(encoded string) (*postPolicy, error) {
+	raw, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, ErrorMessage(ErrInvalidPolicyDocument, "Invalid Policy: Invalid Base64 Encoding")
+	}
+
+	var policy postPolicy
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	if err := dec.Decode(&policy); err != nil {
+		return nil, ErrorMessage(ErrInvalidPolicyDocument, "Invalid Policy: Invalid JSON.")
+	}
+	return &policy, nil
+}
+
+// contentLengthRange returns the bounds of the policy's
+// 'content-length-range' condition, if it has one.
+func (p *postPolicy) contentLengthRange() (min, max int64, ok bool, err error) {
+	for _, condition := range p.Conditions {
+		items, isList := condition.([]interface{})
+		if !isList || len(items) == 0 {
+			continue
+		}
+		if name, _ := items[0].(string); !strings.EqualFold(name, "content-length-range") {
+			continue
+		}
+		if len(items) != 3 {
+			return 0, 0, false, ErrorMessage(ErrInvalidPolicyDocument, "Invalid Policy: Invalid content-length-range condition.")
+		}
+		if min, err = policyInt(items[1]); err != nil {
+			return 0, 0, false, err
+		}
+		if max, err = policyInt(items[2]); err != nil {
+			return 0, 0, false, err
+		}
+		return min, max, true, nil
+	}
+	return 0, 0, false, nil
+}
+
+// policyInt converts a bound of 'content-length-range', which may be given
+// as a number or as a string.
+func policyInt(v interface{}) (int64, error) {
+	var s string
+	switch v := v.(type) {
+	case json.Number:
+		s = v.String()
+	case string:
+		s = v
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || n < 0 {
+		return 0, ErrorMessage(ErrInvalidPolicyDocument, "Invalid Policy: Invalid content-length-range condition.")
+	}
+	return n, nil
+}
+
+// checkPostPolicy enforces the policy sent with a browser-based upload, if
+// there is one, on a file of the given size.
+func checkPostPolicy(values map[string][]string, size int64) error {
+	encoded, ok := postFormValue(values, "policy")
+	if !ok {
+		return nil
+	}
+	policy, err := parsePostPolicy(encoded)
+	if err != nil {
+		return err
+	}
+
+	min, max, ok, err := policy.contentLengthRange()
+	if err != nil || !ok {
+		return err
+	}
+	if size < min {
+		return ErrEntityTooSmall
+	} else if size > max {
+		return ErrEntityTooLarge
+	}
+	return nil
+}
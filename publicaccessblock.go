@@ -0,0 +1,57 @@
+package gofakes3
+
+import "net/http"
+
+const bucketPublicAccessBlockConfig = "publicAccessBlock"
+
+// bucketPublicAccessBlock returns the bucket's PublicAccessBlockConfiguration.
+// If none is set, nothing is blocked.
+func (g *GoFakeS3) bucketPublicAccessBlock(bucket string) PublicAccessBlockConfiguration {
+	config, ok := g.configs.get(resourceConfigKey{bucket: bucket, kind: bucketPublicAccessBlockConfig})
+	if !ok {
+		return PublicAccessBlockConfiguration{}
+	}
+	return *config.(*PublicAccessBlockConfiguration)
+}
+
+func (g *GoFakeS3) getPublicAccessBlock(bucket string, w http.ResponseWriter, r *http.Request) error {
+	g.log.Print(LogInfo, "GET PUBLIC ACCESS BLOCK:", bucket)
+
+	if err := g.ensureBucketExists(bucket); err != nil {
+		return err
+	}
+
+	config, ok := g.configs.get(resourceConfigKey{bucket: bucket, kind: bucketPublicAccessBlockConfig})
+	if !ok {
+		return ResourceError(ErrNoSuchPublicAccessBlockConfiguration, bucket)
+	}
+	return g.xmlEncoder(w).Encode(config)
+}
+
+func (g *GoFakeS3) putPublicAccessBlock(bucket string, w http.ResponseWriter, r *http.Request) error {
+	g.log.Print(LogInfo, "PUT PUBLIC ACCESS BLOCK:", bucket)
+
+	if err := g.ensureBucketExists(bucket); err != nil {
+		return err
+	}
+
+	var in PublicAccessBlockConfiguration
+	if err := g.xmlDecodeBody(r.Body, &in); err != nil {
+		return err
+	}
+
+	g.configs.put(resourceConfigKey{bucket: bucket, kind: bucketPublicAccessBlockConfig}, &in)
+	return nil
+}
+
+func (g *GoFakeS3) deletePublicAccessBlock(bucket string, w http.ResponseWriter, r *http.Request) error {
+	g.log.Print(LogInfo, "DELETE PUBLIC ACCESS BLOCK:", bucket)
+
+	if err := g.ensureBucketExists(bucket); err != nil {
+		return err
+	}
+
+	g.configs.delete(resourceConfigKey{bucket: bucket, kind: bucketPublicAccessBlockConfig})
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
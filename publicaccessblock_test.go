@@ -0,0 +1,98 @@
+package gofakes3_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/johannesboyne/gofakes3"
+)
+
+func TestPublicAccessBlock(t *testing.T) {
+	ts := newTestServer(t)
+	defer ts.Close()
+	svc := ts.s3Client()
+
+	_, err := svc.GetPublicAccessBlock(&s3.GetPublicAccessBlockInput{
+		Bucket: aws.String(defaultBucket),
+	})
+	if !hasErrorCode(err, gofakes3.ErrNoSuchPublicAccessBlockConfiguration) {
+		t.Fatal("expected ErrNoSuchPublicAccessBlockConfiguration, found", err)
+	}
+
+	// Public ACLs are permitted before the block is configured:
+	ts.OKAll(svc.PutObject(&s3.PutObjectInput{
+		Bucket: aws.String(defaultBucket),
+		Key:    aws.String("public"),
+		ACL:    aws.String(s3.ObjectCannedACLPublicRead),
+	}))
+
+	ts.OKAll(svc.PutPublicAccessBlock(&s3.PutPublicAccessBlockInput{
+		Bucket: aws.String(defaultBucket),
+		PublicAccessBlockConfiguration: &s3.PublicAccessBlockConfiguration{
+			BlockPublicAcls:   aws.Bool(true),
+			BlockPublicPolicy: aws.Bool(true),
+		},
+	}))
+
+	rs, err := svc.GetPublicAccessBlock(&s3.GetPublicAccessBlockInput{
+		Bucket: aws.String(defaultBucket),
+	})
+	ts.OK(err)
+	config := rs.PublicAccessBlockConfiguration
+	if !aws.BoolValue(config.BlockPublicAcls) || !aws.BoolValue(config.BlockPublicPolicy) ||
+		aws.BoolValue(config.IgnorePublicAcls) || aws.BoolValue(config.RestrictPublicBuckets) {
+		t.Fatal("unexpected configuration", config)
+	}
+
+	for _, acl := range []string{
+		s3.ObjectCannedACLPublicRead,
+		s3.ObjectCannedACLPublicReadWrite,
+		s3.ObjectCannedACLAuthenticatedRead,
+	} {
+		_, err := svc.PutObject(&s3.PutObjectInput{
+			Bucket: aws.String(defaultBucket),
+			Key:    aws.String("public"),
+			ACL:    aws.String(acl),
+		})
+		if !hasErrorCode(err, gofakes3.ErrAccessDenied) {
+			t.Fatal("expected ErrAccessDenied for", acl, "found", err)
+		}
+	}
+
+	_, err = svc.PutObject(&s3.PutObjectInput{
+		Bucket:    aws.String(defaultBucket),
+		Key:       aws.String("public"),
+		GrantRead: aws.String(`uri="http://acs.amazonaws.com/groups/global/AllUsers"`),
+	})
+	if !hasErrorCode(err, gofakes3.ErrAccessDenied) {
+		t.Fatal("expected ErrAccessDenied for grant, found", err)
+	}
+
+	ts.OKAll(svc.PutObject(&s3.PutObjectInput{
+		Bucket: aws.String(defaultBucket),
+		Key:    aws.String("private"),
+		ACL:    aws.String(s3.ObjectCannedACLPrivate),
+	}))
+
+	ts.OKAll(svc.DeletePublicAccessBlock(&s3.DeletePublicAccessBlockInput{
+		Bucket: aws.String(defaultBucket),
+	}))
+	_, err = svc.GetPublicAccessBlock(&s3.GetPublicAccessBlockInput{
+		Bucket: aws.String(defaultBucket),
+	})
+	if !hasErrorCode(err, gofakes3.ErrNoSuchPublicAccessBlockConfiguration) {
+		t.Fatal("expected ErrNoSuchPublicAccessBlockConfiguration, found", err)
+	}
+}
+
+func TestPublicAccessBlockMissingBucket(t *testing.T) {
+	ts := newTestServer(t)
+	defer ts.Close()
+
+	rs, body := ts.sendRaw("GET", "nope?publicAccessBlock", nil, nil)
+	if rs.StatusCode != http.StatusNotFound {
+		t.Fatal("unexpected status", rs.StatusCode, string(body))
+	}
+}
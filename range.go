@@ -64,10 +64,33 @@ func (o *ObjectRangeRequest) Range(size int64) (*ObjectRange, error) {
 	return &ObjectRange{Start: start, Length: length}, nil
 }
 
+type invalidRangeResponse struct {
+	ErrorResponse
+	RangeRequested   string
+	ActualObjectSize int64
+}
+
+var _ errorResponse = &invalidRangeResponse{}
+
+// invalidRange returns the error S3 gives when a range starts beyond the end
+// of an object, as all ranges of an empty object do. Clients may use it to
+// learn the object's size.
+func invalidRange(requested string, size int64) error {
+	code := ErrInvalidRange
+	return &invalidRangeResponse{
+		ErrorResponse{Code: code, Message: code.Message()},
+		requested, size,
+	}
+}
+
 // parseRangeHeader parses a single byte range from the Range header.
 //
 // Amazon S3 doesn't support retrieving multiple ranges of data per GET request:
 // https://docs.aws.amazon.com/AmazonS3/latest/API/RESTObjectGET.html
+//
+// Rather than failing, S3 ignores a header that asks for more than one, and
+// sends the whole object, as RFC 7233 allows; a nil range is returned for
+// them here so that the same happens.
 func parseRangeHeader(s string) (*ObjectRangeRequest, error) {
 	if s == "" {
 		return nil, nil
@@ -80,7 +103,7 @@ func parseRangeHeader(s string) (*ObjectRangeRequest, error) {
 
 	ranges := strings.Split(s[len(b):], ",")
 	if len(ranges) > 1 {
-		return nil, ErrorMessage(ErrNotImplemented, "multiple ranges not supported")
+		return nil, nil
 	}
 
 	rnge := strings.TrimSpace(ranges[0])
@@ -124,3 +147,20 @@ func parseRangeHeader(s string) (*ObjectRangeRequest, error) {
 
 	return &o, nil
 }
+
+// ifRangeMatches reports whether the If-Range header still describes obj,
+// whose ETag is etag, in which case the Range header applies; otherwise the
+// whole object is sent. Weak ETags never match, whether sent by the client or
+// returned for obj, and a date only matches if it is the object's
+// Last-Modified time:
+// https://www.rfc-editor.org/rfc/rfc7233#section-3.2
+func ifRangeMatches(ifRange string, obj *Object, etag string) bool {
+	if at, err := http.ParseTime(ifRange); err == nil {
+		modified, err := http.ParseTime(obj.Metadata["Last-Modified"])
+		return err == nil && at.Equal(modified)
+	}
+	if isWeakETag(ifRange) || isWeakETag(etag) {
+		return false
+	}
+	return quoteETag(ifRange) == etag
+}
@@ -0,0 +1,30 @@
+package gofakes3
+
+import (
+	"net/http"
+	"strings"
+)
+
+// redirectRule is added by WithRedirect.
+type redirectRule struct {
+	pathPrefix string
+	target     string
+	code       int
+}
+
+// redirectMiddleware responds to requests matching a redirectRule with a
+// redirect, without passing them on to handler. Rules are checked in the
+// order they were added.
+func (g *GoFakeS3) redirectMiddleware(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, rq *http.Request) {
+		for _, rule := range g.redirects {
+			if strings.HasPrefix(rq.URL.Path, rule.pathPrefix) {
+				g.log.Print(LogInfo, "REDIRECT:", rq.URL.Path, "TO", rule.target)
+				w.Header().Set("Location", strings.TrimRight(rule.target, "/")+rq.URL.RequestURI())
+				w.WriteHeader(rule.code)
+				return
+			}
+		}
+		handler.ServeHTTP(w, rq)
+	})
+}
@@ -0,0 +1,129 @@
+package gofakes3
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+// BucketRegionHeader is returned with every response to a request for a
+// bucket given a region with WithBucketRegion, as S3 does for HEAD bucket
+// requests and for errors caused by using the wrong region.
+const BucketRegionHeader = "X-Amz-Bucket-Region"
+
+type authorizationRegionResponse struct {
+	ErrorResponse
+	Region string
+}
+
+var _ errorResponse = &authorizationRegionResponse{}
+
+type permanentRedirectResponse struct {
+	ErrorResponse
+	Bucket   string
+	Endpoint string
+}
+
+var _ errorResponse = &permanentRedirectResponse{}
+
+// requestSigningRegion returns the region in the credential scope of a
+// Signature Version 4 request, from either the 'Authorization' header or the
+// query string of a presigned URL, and whether it came from the query string.
+// It returns an empty string for other requests.
+func requestSigningRegion(r *http.Request) (region string, presigned bool) {
+	var credential string
+	if auth := r.Header.Get("Authorization"); strings.HasPrefix(auth, "AWS4-HMAC-SHA256 ") {
+		for _, field := range strings.Split(strings.TrimPrefix(auth, "AWS4-HMAC-SHA256 "), ",") {
+			field = strings.TrimSpace(field)
+			if strings.HasPrefix(field, "Credential=") {
+				credential = strings.TrimPrefix(field, "Credential=")
+			}
+		}
+	} else if credential = r.URL.Query().Get("X-Amz-Credential"); credential != "" {
+		presigned = true
+	}
+
+	// The credential scope is 'access-key/date/region/service/aws4_request':
+	scope := strings.Split(credential, "/")
+	if len(scope) != 5 {
+		return "", false
+	}
+	return scope[2], presigned
+}
+
+// regionLabel matches the names of AWS regions, like 'eu-west-1' or
+// 'us-gov-east-1'.
+var regionLabel = regexp.MustCompile(`^[a-z]{2}(-gov)?-[a-z]+-\d+$`)
+
+// hostRegion returns the region named by a regional endpoint in the labels of
+// a host, like 's3.eu-west-1.amazonaws.com', 's3.dualstack.eu-west-1.amazonaws.com'
+// or 'mybucket.s3-eu-west-1.localhost', and the index of the label that names
+// it. It returns -1 if the host does not name a region, as with 's3.localhost'.
+func hostRegion(labels []string) (region string, idx int) {
+	for idx, label := range labels {
+		if label != "s3" && !strings.HasPrefix(label, "s3-") {
+			continue
+		}
+		// The region is either part of the label, as in 's3-eu-west-1' or
+		// 's3-website-eu-west-1', or one of the labels after it:
+		if rest := strings.TrimPrefix(strings.TrimPrefix(label, "s3-"), "website-"); regionLabel.MatchString(rest) {
+			return rest, idx
+		}
+		for next := idx + 1; next < len(labels); next++ {
+			if labels[next] == "dualstack" || labels[next] == "website" {
+				continue
+			}
+			if regionLabel.MatchString(labels[next]) {
+				return labels[next], next
+			}
+			break
+		}
+	}
+	return "", -1
+}
+
+// checkBucketRegion rejects requests for a bucket given a region with
+// WithBucketRegion that were sent to a different region's endpoint, or signed
+// for a different region. Like S3, the former is answered with a redirect and
+// the latter with an error naming the bucket's region.
+func (g *GoFakeS3) checkBucketRegion(bucket string, w http.ResponseWriter, r *http.Request) error {
+	region, ok := g.bucketRegions[bucket]
+	if !ok {
+		return nil
+	}
+	w.Header().Set(BucketRegionHeader, region)
+
+	host, port, err := net.SplitHostPort(r.Host)
+	if err != nil {
+		host = r.Host
+	}
+	labels := strings.Split(host, ".")
+	if requested, idx := hostRegion(labels); idx >= 0 && requested != region {
+		labels[idx] = strings.TrimSuffix(labels[idx], requested) + region
+		endpoint := strings.Join(labels, ".")
+		if port != "" {
+			endpoint = net.JoinHostPort(endpoint, port)
+		}
+
+		code := ErrPermanentRedirect
+		return &permanentRedirectResponse{
+			ErrorResponse: ErrorResponse{Code: code, Message: code.Message()},
+			Bucket:        bucket,
+			Endpoint:      endpoint,
+		}
+	}
+
+	if signed, presigned := requestSigningRegion(r); signed != "" && signed != region {
+		code, message := ErrAuthorizationHeaderMalformed, "The authorization header is malformed;"
+		if presigned {
+			code, message = ErrAuthorizationQueryParametersError, "Error parsing the X-Amz-Credential parameter;"
+		}
+		return &authorizationRegionResponse{
+			ErrorResponse: ErrorResponse{Code: code, Message: fmt.Sprintf("%s the region '%s' is wrong; expecting '%s'", message, signed, region)},
+			Region:        region,
+		}
+	}
+	return nil
+}
@@ -1,9 +1,8 @@
 package gofakes3
 
 import (
-	"encoding/base64"
-	"fmt"
 	"net/http"
+	"net/url"
 	"strings"
 )
 
@@ -18,11 +17,16 @@ import (
 // verbs, but outside the core functionality, the clean separation starts
 // to degrade, especially around multipart uploads.
 //
+// Subresources are detected by the presence of their name in the query
+// string, whatever its value, so '?acl' and '?acl=' are equivalent. If a
+// query parameter is repeated, the first value is used, as S3 does; this is
+// also what url.Values.Get returns.
+//
 func (g *GoFakeS3) routeBase(w http.ResponseWriter, r *http.Request) {
 	var (
-		path   = strings.Trim(r.URL.Path, "/")
+		path   = g.routePath(r.URL.EscapedPath())
 		parts  = strings.SplitN(path, "/", 2)
-		bucket = parts[0]
+		bucket = unescapePath(parts[0])
 		query  = r.URL.Query()
 		object = ""
 		err    error
@@ -30,46 +34,123 @@ func (g *GoFakeS3) routeBase(w http.ResponseWriter, r *http.Request) {
 
 	hdr := w.Header()
 
-	id := fmt.Sprintf("%016X", g.nextRequestID())
-	hdr.Set("x-amz-id-2", base64.StdEncoding.EncodeToString([]byte(id+id+id+id))) // x-amz-id-2 is 48 bytes of random stuff
-	hdr.Set("x-amz-request-id", id)
-	hdr.Set("Server", "AmazonS3")
-
 	if len(parts) == 2 {
-		object = parts[1]
+		object = unescapePath(parts[1])
+	}
+
+	if g.requestCapture != nil {
+		var finish func(bucket, object string)
+		w, finish = g.captureRequest(w, r)
+		defer finish(bucket, object)
+	}
+
+	release, err := g.acquireRequestSlot()
+	if err != nil {
+		hdr.Set("Retry-After", "1")
+		g.httpError(w, r, err)
+		return
+	}
+	defer release()
+
+	if err := g.simulateLatency(bucket, object, query, r); err != nil {
+		g.log.Print(LogInfo, "request abandoned during simulated latency:", err)
+		return
+	}
+
+	if err := g.checkBucketRegion(bucket, w, r); err != nil {
+		g.httpError(w, r, err)
+		return
 	}
+	if err := g.authorizeAnonymous(bucket, object, query, r); err != nil {
+		g.httpError(w, r, err)
+		return
+	}
+	if err := g.authorize(bucket, object, query, r); err != nil {
+		g.httpError(w, r, err)
+		return
+	}
+	if err := g.checkQueryParameters(bucket, object, query, r); err != nil {
+		g.httpError(w, r, err)
+		return
+	}
+	w, r = g.throttleBucket(bucket, object, w, r)
+
+	if bucket == "" {
+		err = g.routeRoot(w, r)
 
-	if uploadID := UploadID(query.Get("uploadId")); uploadID != "" {
+	} else if handler, ok := g.findSubresourceHandler(query); ok {
+		err = handler(bucket, object, query, w, r)
+
+	} else if uploadID := UploadID(query.Get("uploadId")); uploadID != "" {
 		err = g.routeMultipartUpload(bucket, object, uploadID, w, r)
 
 	} else if _, ok := query["uploads"]; ok {
 		err = g.routeMultipartUploadBase(bucket, object, w, r)
 
-	} else if _, ok := query["versioning"]; ok {
-		err = g.routeVersioning(bucket, w, r)
-
-	} else if _, ok := query["versions"]; ok {
-		err = g.routeVersions(bucket, w, r)
+	} else if name, ok := findSubresource(query); ok {
+		err = g.routeSubresource(name, bucket, object, w, r)
 
 	} else if versionID := versionFromQuery(query["versionId"]); versionID != "" {
 		err = g.routeVersion(bucket, object, VersionID(versionID), w, r)
 
-	} else if bucket != "" && object != "" {
+	} else if object != "" {
 		err = g.routeObject(bucket, object, w, r)
 
-	} else if bucket != "" {
+	} else {
 		err = g.routeBucket(bucket, w, r)
+	}
 
-	} else if r.Method == "GET" {
-		err = g.listBuckets(w, r)
+	if err != nil {
+		if err := g.delayNotFound(bucket, object, r, err); err != nil {
+			g.log.Print(LogInfo, "request abandoned during not found delay:", err)
+			return
+		}
+		g.httpError(w, r, err)
+	}
+}
 
-	} else {
-		http.NotFound(w, r)
-		return
+// methodNotAllowed sets the Allow header of a '405 Method Not Allowed'
+// response to the methods the route supports, as HTTP requires.
+func methodNotAllowed(w http.ResponseWriter, allowed ...string) error {
+	w.Header().Set("Allow", strings.Join(allowed, ", "))
+	return ErrMethodNotAllowed
+}
+
+// routePath strips the slashes around a request path before it is split into
+// the bucket and the object. With WithPreserveTrailingSlash, a trailing slash
+// is kept as part of the object's key.
+func (g *GoFakeS3) routePath(path string) string {
+	if g.preserveTrailingSlash {
+		return strings.TrimLeft(path, "/")
 	}
+	return strings.Trim(path, "/")
+}
 
+// unescapePath decodes part of the escaped request path. The path is split
+// into the bucket and the object while it is still escaped, so that an
+// encoded '%2F' is kept as a slash in the object's key rather than being
+// taken for a separator, or trimmed by routePath.
+func unescapePath(s string) string {
+	unescaped, err := url.PathUnescape(s)
 	if err != nil {
-		g.httpError(w, r, err)
+		// EscapedPath only returns valid encodings, so this is not expected:
+		return s
+	}
+	return unescaped
+}
+
+// routeRoot handles URLs that contain neither a bucket nor an object path
+// segment. Any query string parameters other than the capabilities query are
+// ignored; S3 simply lists the buckets.
+func (g *GoFakeS3) routeRoot(w http.ResponseWriter, r *http.Request) error {
+	switch r.Method {
+	case "GET":
+		if _, ok := r.URL.Query()[CapabilitiesQuery]; ok {
+			return g.getCapabilities(w, r)
+		}
+		return g.listBuckets(w, r)
+	default:
+		return methodNotAllowed(w, "GET")
 	}
 }
 
@@ -86,7 +167,7 @@ func (g *GoFakeS3) routeObject(bucket, object string, w http.ResponseWriter, r *
 	case "DELETE":
 		return g.deleteObject(bucket, object, w, r)
 	default:
-		return ErrMethodNotAllowed
+		return methodNotAllowed(w, "GET", "HEAD", "PUT", "DELETE")
 	}
 }
 
@@ -95,11 +176,7 @@ func (g *GoFakeS3) routeObject(bucket, object string, w http.ResponseWriter, r *
 func (g *GoFakeS3) routeBucket(bucket string, w http.ResponseWriter, r *http.Request) (err error) {
 	switch r.Method {
 	case "GET":
-		if _, ok := r.URL.Query()["location"]; ok {
-			return g.getBucketLocation(bucket, w, r)
-		} else {
-			return g.listBucket(bucket, w, r)
-		}
+		return g.listBucket(bucket, w, r)
 	case "PUT":
 		return g.createBucket(bucket, w, r)
 	case "DELETE":
@@ -107,13 +184,9 @@ func (g *GoFakeS3) routeBucket(bucket string, w http.ResponseWriter, r *http.Req
 	case "HEAD":
 		return g.headBucket(bucket, w, r)
 	case "POST":
-		if _, ok := r.URL.Query()["delete"]; ok {
-			return g.deleteMulti(bucket, w, r)
-		} else {
-			return g.createObjectBrowserUpload(bucket, w, r)
-		}
+		return g.createObjectBrowserUpload(bucket, w, r)
 	default:
-		return ErrMethodNotAllowed
+		return methodNotAllowed(w, "GET", "PUT", "DELETE", "HEAD", "POST")
 	}
 }
 
@@ -127,7 +200,60 @@ func (g *GoFakeS3) routeMultipartUploadBase(bucket, object string, w http.Respon
 	case "POST":
 		return g.initiateMultipartUpload(bucket, object, w, r)
 	default:
-		return ErrMethodNotAllowed
+		return methodNotAllowed(w, "GET", "POST")
+	}
+}
+
+// routeIntelligentTiering operates on routes that contain
+// '?intelligent-tiering' in the query string.
+func (g *GoFakeS3) routeIntelligentTiering(bucket string, w http.ResponseWriter, r *http.Request) error {
+	switch r.Method {
+	case "GET":
+		if _, ok := r.URL.Query()["id"]; ok {
+			return g.getBucketIntelligentTieringConfiguration(bucket, w, r)
+		}
+		return g.listBucketIntelligentTieringConfigurations(bucket, w, r)
+	case "PUT":
+		return g.putBucketIntelligentTieringConfiguration(bucket, w, r)
+	case "DELETE":
+		return g.deleteBucketIntelligentTieringConfiguration(bucket, w, r)
+	default:
+		return methodNotAllowed(w, "GET", "PUT", "DELETE")
+	}
+}
+
+// routeLocation operates on routes that contain '?location' in the query
+// string.
+func (g *GoFakeS3) routeLocation(bucket string, w http.ResponseWriter, r *http.Request) error {
+	switch r.Method {
+	case "GET":
+		return g.getBucketLocation(bucket, w, r)
+	default:
+		return methodNotAllowed(w, "GET")
+	}
+}
+
+// routeAccelerate operates on routes that contain '?accelerate' in the query
+// string.
+func (g *GoFakeS3) routeAccelerate(bucket string, w http.ResponseWriter, r *http.Request) error {
+	switch r.Method {
+	case "GET":
+		return g.getBucketAccelerateConfiguration(bucket, w, r)
+	case "PUT":
+		return g.putBucketAccelerateConfiguration(bucket, w, r)
+	default:
+		return methodNotAllowed(w, "GET", "PUT")
+	}
+}
+
+// routeDeleteMulti operates on routes that contain '?delete' in the query
+// string.
+func (g *GoFakeS3) routeDeleteMulti(bucket string, w http.ResponseWriter, r *http.Request) error {
+	switch r.Method {
+	case "POST":
+		return g.deleteMulti(bucket, w, r)
+	default:
+		return methodNotAllowed(w, "POST")
 	}
 }
 
@@ -141,7 +267,115 @@ func (g *GoFakeS3) routeVersioning(bucket string, w http.ResponseWriter, r *http
 	case "PUT":
 		return g.putBucketVersioning(bucket, w, r)
 	default:
-		return ErrMethodNotAllowed
+		return methodNotAllowed(w, "GET", "PUT")
+	}
+}
+
+// routeCORS operates on routes that contain '?cors' in the query string.
+func (g *GoFakeS3) routeCORS(bucket string, w http.ResponseWriter, r *http.Request) error {
+	switch r.Method {
+	case "GET":
+		return g.getBucketCORS(bucket, w, r)
+	case "PUT":
+		return g.putBucketCORS(bucket, w, r)
+	case "DELETE":
+		return g.deleteBucketCORS(bucket, w, r)
+	default:
+		return methodNotAllowed(w, "GET", "PUT", "DELETE")
+	}
+}
+
+// routeLifecycle operates on routes that contain '?lifecycle' in the query
+// string.
+func (g *GoFakeS3) routeLifecycle(bucket string, w http.ResponseWriter, r *http.Request) error {
+	switch r.Method {
+	case "GET":
+		return g.getBucketLifecycle(bucket, w, r)
+	case "PUT":
+		return g.putBucketLifecycle(bucket, w, r)
+	case "DELETE":
+		return g.deleteBucketLifecycle(bucket, w, r)
+	default:
+		return methodNotAllowed(w, "GET", "PUT", "DELETE")
+	}
+}
+
+// routeOwnershipControls operates on routes that contain '?ownershipControls'
+// in the query string.
+func (g *GoFakeS3) routeOwnershipControls(bucket string, w http.ResponseWriter, r *http.Request) error {
+	switch r.Method {
+	case "GET":
+		return g.getBucketOwnershipControls(bucket, w, r)
+	case "PUT":
+		return g.putBucketOwnershipControls(bucket, w, r)
+	case "DELETE":
+		return g.deleteBucketOwnershipControls(bucket, w, r)
+	default:
+		return methodNotAllowed(w, "GET", "PUT", "DELETE")
+	}
+}
+
+// routeObjectLock operates on routes that contain '?object-lock' in the
+// query string.
+func (g *GoFakeS3) routeObjectLock(bucket string, w http.ResponseWriter, r *http.Request) error {
+	switch r.Method {
+	case "GET":
+		return g.getObjectLockConfiguration(bucket, w, r)
+	case "PUT":
+		return g.putObjectLockConfiguration(bucket, w, r)
+	default:
+		return methodNotAllowed(w, "GET", "PUT")
+	}
+}
+
+// routeAnalytics operates on routes that contain '?analytics' in the query
+// string.
+func (g *GoFakeS3) routeAnalytics(bucket string, w http.ResponseWriter, r *http.Request) error {
+	switch r.Method {
+	case "GET":
+		if _, ok := r.URL.Query()["id"]; ok {
+			return g.getBucketAnalyticsConfiguration(bucket, w, r)
+		}
+		return g.listBucketAnalyticsConfigurations(bucket, w, r)
+	case "PUT":
+		return g.putBucketAnalyticsConfiguration(bucket, w, r)
+	case "DELETE":
+		return g.deleteBucketAnalyticsConfiguration(bucket, w, r)
+	default:
+		return methodNotAllowed(w, "GET", "PUT", "DELETE")
+	}
+}
+
+// routeMetrics operates on routes that contain '?metrics' in the query
+// string.
+func (g *GoFakeS3) routeMetrics(bucket string, w http.ResponseWriter, r *http.Request) error {
+	switch r.Method {
+	case "GET":
+		if _, ok := r.URL.Query()["id"]; ok {
+			return g.getBucketMetricsConfiguration(bucket, w, r)
+		}
+		return g.listBucketMetricsConfigurations(bucket, w, r)
+	case "PUT":
+		return g.putBucketMetricsConfiguration(bucket, w, r)
+	case "DELETE":
+		return g.deleteBucketMetricsConfiguration(bucket, w, r)
+	default:
+		return methodNotAllowed(w, "GET", "PUT", "DELETE")
+	}
+}
+
+// routePublicAccessBlock operates on routes that contain '?publicAccessBlock'
+// in the query string.
+func (g *GoFakeS3) routePublicAccessBlock(bucket string, w http.ResponseWriter, r *http.Request) error {
+	switch r.Method {
+	case "GET":
+		return g.getPublicAccessBlock(bucket, w, r)
+	case "PUT":
+		return g.putPublicAccessBlock(bucket, w, r)
+	case "DELETE":
+		return g.deletePublicAccessBlock(bucket, w, r)
+	default:
+		return methodNotAllowed(w, "GET", "PUT", "DELETE")
 	}
 }
 
@@ -151,7 +385,7 @@ func (g *GoFakeS3) routeVersions(bucket string, w http.ResponseWriter, r *http.R
 	case "GET":
 		return g.listBucketVersions(bucket, w, r)
 	default:
-		return ErrMethodNotAllowed
+		return methodNotAllowed(w, "GET")
 	}
 }
 
@@ -166,7 +400,91 @@ func (g *GoFakeS3) routeVersion(bucket, object string, versionID VersionID, w ht
 	case "DELETE":
 		return g.deleteObjectVersion(bucket, object, versionID, w, r)
 	default:
-		return ErrMethodNotAllowed
+		return methodNotAllowed(w, "GET", "HEAD", "DELETE")
+	}
+}
+
+// routeBucketACL operates on routes that contain '?acl' in the query string,
+// without an object path segment. A bucket's ACL can only be set when it is
+// created.
+func (g *GoFakeS3) routeBucketACL(bucket string, w http.ResponseWriter, r *http.Request) error {
+	switch r.Method {
+	case "GET":
+		return g.getBucketACL(bucket, w, r)
+	case "PUT":
+		return ErrorMessage(ErrNotImplemented, "PutBucketAcl is not implemented")
+	default:
+		return methodNotAllowed(w, "GET", "PUT")
+	}
+}
+
+// routeObjectACL operates on routes that contain '?acl' in the query string,
+// along with an object path segment. The versionId may be empty, which
+// refers to the current version.
+func (g *GoFakeS3) routeObjectACL(bucket, object string, versionID VersionID, w http.ResponseWriter, r *http.Request) error {
+	switch r.Method {
+	case "GET":
+		return g.getObjectACL(bucket, object, versionID, w, r)
+	case "PUT":
+		return g.putObjectACL(bucket, object, versionID, w, r)
+	default:
+		return methodNotAllowed(w, "GET", "PUT")
+	}
+}
+
+// routeObjectTagging operates on routes that contain '?tagging' in the query
+// string, along with an object path segment. The versionId may be empty,
+// which refers to the current version.
+func (g *GoFakeS3) routeObjectTagging(bucket, object string, versionID VersionID, w http.ResponseWriter, r *http.Request) error {
+	switch r.Method {
+	case "GET":
+		return g.getObjectTagging(bucket, object, versionID, w, r)
+	case "PUT":
+		return g.putObjectTagging(bucket, object, versionID, w, r)
+	case "DELETE":
+		return g.deleteObjectTagging(bucket, object, versionID, w, r)
+	default:
+		return methodNotAllowed(w, "GET", "PUT", "DELETE")
+	}
+}
+
+// routeObjectAttributes operates on routes that contain '?attributes' in the
+// query string. The versionId may be empty, which refers to the current
+// version.
+func (g *GoFakeS3) routeObjectAttributes(bucket, object string, versionID VersionID, w http.ResponseWriter, r *http.Request) error {
+	switch r.Method {
+	case "GET":
+		return g.getObjectAttributes(bucket, object, versionID, w, r)
+	default:
+		return methodNotAllowed(w, "GET")
+	}
+}
+
+// routeObjectRetention operates on routes that contain '?retention' in the
+// query string. The versionId may be empty, which refers to the current
+// version.
+func (g *GoFakeS3) routeObjectRetention(bucket, object string, versionID VersionID, w http.ResponseWriter, r *http.Request) error {
+	switch r.Method {
+	case "GET":
+		return g.getObjectRetention(bucket, object, versionID, w, r)
+	case "PUT":
+		return g.putObjectRetention(bucket, object, versionID, w, r)
+	default:
+		return methodNotAllowed(w, "GET", "PUT")
+	}
+}
+
+// routeObjectLegalHold operates on routes that contain '?legal-hold' in the
+// query string. The versionId may be empty, which refers to the current
+// version.
+func (g *GoFakeS3) routeObjectLegalHold(bucket, object string, versionID VersionID, w http.ResponseWriter, r *http.Request) error {
+	switch r.Method {
+	case "GET":
+		return g.getObjectLegalHold(bucket, object, versionID, w, r)
+	case "PUT":
+		return g.putObjectLegalHold(bucket, object, versionID, w, r)
+	default:
+		return methodNotAllowed(w, "GET", "PUT")
 	}
 }
 
@@ -183,7 +501,7 @@ func (g *GoFakeS3) routeMultipartUpload(bucket, object string, uploadID UploadID
 	case "POST":
 		return g.completeMultipartUpload(bucket, object, uploadID, w, r)
 	default:
-		return ErrMethodNotAllowed
+		return methodNotAllowed(w, "GET", "PUT", "DELETE", "POST")
 	}
 }
 
@@ -198,3 +516,124 @@ func versionFromQuery(qv []string) string {
 	}
 	return ""
 }
+
+// subresourceRoute dispatches requests for a subresource: a query string key
+// like '?versioning' that selects an operation other than the default one for
+// the bucket or object. Either function may be nil if the subresource does not
+// apply at that level, or GoFakeS3 does not implement it there.
+type subresourceRoute struct {
+	bucket func(g *GoFakeS3, bucket string, w http.ResponseWriter, r *http.Request) error
+	object func(g *GoFakeS3, bucket, object string, versionID VersionID, w http.ResponseWriter, r *http.Request) error
+}
+
+// subresources contains every subresource S3 supports, in the order they take
+// precedence if a request contains more than one. The multipart upload and
+// versionId subresources are handled separately in routeBase, as they can be
+// combined with these.
+//
+// To implement a subresource, add a route for it in subresourceRoutes;
+// requests for a subresource without a route receive ErrNotImplemented, so
+// that clients can reliably detect features GoFakeS3 doesn't have.
+var subresources = []string{
+	"accelerate",
+	"acl",
+	"analytics",
+	"attributes",
+	"cors",
+	"delete",
+	"encryption",
+	"intelligent-tiering",
+	"inventory",
+	"legal-hold",
+	"lifecycle",
+	"location",
+	"logging",
+	"metrics",
+	"notification",
+	"object-lock",
+	"ownershipControls",
+	"policy",
+	"policyStatus",
+	"publicAccessBlock",
+	"replication",
+	"requestPayment",
+	"restore",
+	"retention",
+	"select",
+	"tagging",
+	"torrent",
+	"versioning",
+	"versions",
+	"website",
+}
+
+var subresourceRoutes = map[string]subresourceRoute{
+	"accelerate":          {bucket: (*GoFakeS3).routeAccelerate},
+	"acl":                 {bucket: (*GoFakeS3).routeBucketACL, object: (*GoFakeS3).routeObjectACL},
+	"analytics":           {bucket: (*GoFakeS3).routeAnalytics},
+	"attributes":          {object: (*GoFakeS3).routeObjectAttributes},
+	"cors":                {bucket: (*GoFakeS3).routeCORS},
+	"delete":              {bucket: (*GoFakeS3).routeDeleteMulti},
+	"intelligent-tiering": {bucket: (*GoFakeS3).routeIntelligentTiering},
+	"legal-hold":          {object: (*GoFakeS3).routeObjectLegalHold},
+	"lifecycle":           {bucket: (*GoFakeS3).routeLifecycle},
+	"location":            {bucket: (*GoFakeS3).routeLocation},
+	"metrics":             {bucket: (*GoFakeS3).routeMetrics},
+	"object-lock":         {bucket: (*GoFakeS3).routeObjectLock},
+	"ownershipControls":   {bucket: (*GoFakeS3).routeOwnershipControls},
+	"publicAccessBlock":   {bucket: (*GoFakeS3).routePublicAccessBlock},
+	"retention":           {object: (*GoFakeS3).routeObjectRetention},
+	"tagging":             {object: (*GoFakeS3).routeObjectTagging},
+	"versioning":          {bucket: (*GoFakeS3).routeVersioning},
+	"versions":            {bucket: (*GoFakeS3).routeVersions},
+}
+
+// findSubresource returns the name of the subresource requested in the query
+// string, if there is one.
+func findSubresource(query url.Values) (name string, ok bool) {
+	for _, name := range subresources {
+		if _, ok := query[name]; ok {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// SubresourceHandler handles requests for a subresource registered with
+// WithSubresourceHandler. object is empty for requests to the bucket itself.
+// If an error is returned, it is sent as an S3 error response, as it would
+// be for any other request.
+type SubresourceHandler func(bucket, object string, query url.Values, w http.ResponseWriter, r *http.Request) error
+
+type subresourceHandler struct {
+	name    string
+	handler SubresourceHandler
+}
+
+// findSubresourceHandler returns the handler registered for a subresource
+// requested in the query string, if there is one. Handlers take precedence in
+// the order they were registered.
+func (g *GoFakeS3) findSubresourceHandler(query url.Values) (handler SubresourceHandler, ok bool) {
+	for _, sh := range g.subresourceHandlers {
+		if _, ok := query[sh.name]; ok {
+			return sh.handler, true
+		}
+	}
+	return nil, false
+}
+
+// routeSubresource operates on routes that contain one of the subresources
+// in the query string.
+func (g *GoFakeS3) routeSubresource(name string, bucket, object string, w http.ResponseWriter, r *http.Request) error {
+	route := subresourceRoutes[name]
+
+	if object == "" && route.bucket != nil {
+		return route.bucket(g, bucket, w, r)
+
+	} else if object != "" && route.object != nil {
+		versionID := VersionID(versionFromQuery(r.URL.Query()["versionId"]))
+		return route.object(g, bucket, object, versionID, w, r)
+	}
+
+	return ErrorMessagef(ErrNotImplemented, "The '%s' subresource is not implemented", name)
+}
@@ -1,7 +1,15 @@
 package gofakes3_test
 
 import (
+	"encoding/xml"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strings"
 	"testing"
+
+	"github.com/johannesboyne/gofakes3"
 )
 
 func TestRoutingSlashes(t *testing.T) {
@@ -30,3 +38,250 @@ func TestRoutingSlashes(t *testing.T) {
 	assertStatus("test/obj/", 200)
 	assertStatus("test/obj//", 200)
 }
+
+func TestRoutingEncodedKeys(t *testing.T) {
+	ts := newTestServer(t)
+	defer ts.Close()
+
+	for _, tc := range []struct {
+		path string
+		key  string
+	}{
+		{"with%20space", "with space"},
+		{"plus+sign", "plus+sign"},
+		{"encoded%2Bplus", "encoded+plus"},
+		{"dir%2Ffile", "dir/file"},
+		{"folder%2F", "folder/"},
+		{"%2Fleading", "/leading"},
+		{"mixed/a%20b%2Fc%3F", "mixed/a b/c?"},
+	} {
+		t.Run(tc.key, func(t *testing.T) {
+			rs, body := ts.sendRaw("PUT", defaultBucket+"/"+tc.path, []byte(tc.key), nil)
+			if rs.StatusCode != http.StatusOK {
+				t.Fatal("put failed", rs.StatusCode, string(body))
+			}
+			ts.assertObject(defaultBucket, tc.key, nil, tc.key)
+
+			rs, body = ts.sendRaw("GET", defaultBucket+"/"+tc.path, nil, nil)
+			if rs.StatusCode != http.StatusOK || string(body) != tc.key {
+				t.Fatal("get failed", rs.StatusCode, string(body))
+			}
+		})
+	}
+
+	t.Run("host-bucket", func(t *testing.T) {
+		ts := newTestServer(t, withFakerOptions(gofakes3.WithHostBucket(true)))
+		defer ts.Close()
+
+		rq, err := http.NewRequest("PUT", ts.url("folder%2F"), strings.NewReader("marker"))
+		ts.OK(err)
+		rq.Host = defaultBucket + ".localhost"
+		rs, err := httpClient().Do(rq)
+		ts.OK(err)
+		rs.Body.Close()
+		if rs.StatusCode != http.StatusOK {
+			t.Fatal("put failed", rs.StatusCode)
+		}
+		ts.assertObject(defaultBucket, "folder/", nil, "marker")
+	})
+}
+
+func TestRoutingTorrentNotImplemented(t *testing.T) {
+	ts := newTestServer(t)
+	defer ts.Close()
+	ts.backendPutString(defaultBucket, "obj", nil, "yep")
+
+	rs, err := httpClient().Get(ts.url(defaultBucket + "/obj?torrent"))
+	ts.OK(err)
+	defer rs.Body.Close()
+
+	if rs.StatusCode != http.StatusNotImplemented {
+		t.Fatal("expected status", http.StatusNotImplemented, "found", rs.StatusCode)
+	}
+
+	body, err := ioutil.ReadAll(rs.Body)
+	ts.OK(err)
+
+	var errResp gofakes3.ErrorResponse
+	ts.OK(xml.Unmarshal(body, &errResp))
+	if errResp.Code != gofakes3.ErrNotImplemented {
+		t.Fatal("unexpected code", errResp.Code)
+	}
+}
+
+func TestRoutingRoot(t *testing.T) {
+	ts := newTestServer(t)
+	defer ts.Close()
+
+	client := httpClient()
+
+	assertStatus := func(method, url string, code int) {
+		t.Helper()
+		rq, err := http.NewRequest(method, ts.url(url), nil)
+		ts.OK(err)
+		rs, err := client.Do(rq)
+		ts.OK(err)
+		rs.Body.Close()
+		if rs.StatusCode != code {
+			t.Fatal("expected status", code, "found", rs.StatusCode)
+		}
+	}
+
+	assertStatus("GET", "/", 200)
+	assertStatus("GET", "/?health", 200)
+	assertStatus("GET", "/?versioning", 200)
+	assertStatus("GET", "/?uploads", 200)
+	assertStatus("PUT", "/", gofakes3.ErrMethodNotAllowed.Status())
+	assertStatus("DELETE", "/?versioning", gofakes3.ErrMethodNotAllowed.Status())
+}
+
+func TestRoutingMethodNotAllowed(t *testing.T) {
+	ts := newTestServer(t)
+	defer ts.Close()
+	ts.backendPutString(defaultBucket, "object", nil, "hello")
+
+	for _, tc := range []struct {
+		method, path string
+		allow        string
+	}{
+		{"PATCH", defaultBucket + "/object", "GET, HEAD, PUT, DELETE"},
+		{"PATCH", defaultBucket, "GET, PUT, DELETE, HEAD, POST"},
+		{"POST", defaultBucket + "?versioning", "GET, PUT"},
+		{"PUT", defaultBucket + "/object?attributes", "GET"},
+	} {
+		t.Run(tc.method+" "+tc.path, func(t *testing.T) {
+			rs, body := ts.sendRaw(tc.method, tc.path, nil, nil)
+			ts.assertRawErrorCode(rs, body, gofakes3.ErrMethodNotAllowed)
+			if allow := rs.Header.Get("Allow"); allow != tc.allow {
+				t.Fatalf("unexpected Allow header %q, expected %q", allow, tc.allow)
+			}
+		})
+	}
+}
+
+func TestRoutingUnimplementedSubresource(t *testing.T) {
+	ts := newTestServer(t)
+	defer ts.Close()
+	ts.backendPutString(defaultBucket, "obj", nil, "yep")
+
+	for _, tc := range []struct {
+		method, url string
+	}{
+		{"GET", defaultBucket + "?logging"},
+		{"PUT", defaultBucket + "?replication"},
+		{"DELETE", defaultBucket + "?website"},
+		{"PUT", defaultBucket + "?acl"},
+		{"POST", defaultBucket + "/obj?select&select-type=2"},
+		{"GET", defaultBucket + "/obj?select"},
+		{"POST", defaultBucket + "/obj?restore"},
+		{"GET", defaultBucket + "/obj?versioning"},
+	} {
+		t.Run(tc.method+" "+tc.url, func(t *testing.T) {
+			rs, body := ts.sendRaw(tc.method, tc.url, nil, nil)
+			ts.assertRawErrorCode(rs, body, gofakes3.ErrNotImplemented)
+		})
+	}
+
+	// Implemented subresources are routed to their handlers:
+	rs, body := ts.sendRaw("GET", defaultBucket+"?versioning", nil, nil)
+	if rs.StatusCode != http.StatusOK {
+		t.Fatal("unexpected status", rs.StatusCode, string(body))
+	}
+}
+
+func TestRoutingSubresourceHandler(t *testing.T) {
+	type call struct {
+		bucket, object, value string
+	}
+	var calls []call
+
+	ts := newTestServer(t, withFakerOptions(
+		gofakes3.WithSubresourceHandler("x-custom", func(bucket, object string, query url.Values, w http.ResponseWriter, r *http.Request) error {
+			calls = append(calls, call{bucket, object, query.Get("x-custom")})
+			if bucket != defaultBucket {
+				return gofakes3.ErrNotImplemented
+			}
+			w.Header().Set("Content-Type", "application/xml")
+			w.WriteHeader(http.StatusAccepted)
+			_, err := w.Write([]byte("<Custom/>"))
+			return err
+		}),
+		// Registered subresources can also replace GoFakeS3's own:
+		gofakes3.WithSubresourceHandler("versioning", func(bucket, object string, query url.Values, w http.ResponseWriter, r *http.Request) error {
+			return gofakes3.ErrAccessDenied
+		}),
+	))
+	defer ts.Close()
+
+	rs, body := ts.sendRaw("GET", defaultBucket+"/dir/obj?x-custom=1", nil, nil)
+	if rs.StatusCode != http.StatusAccepted || string(body) != "<Custom/>" {
+		t.Fatal("unexpected response", rs.StatusCode, string(body))
+	}
+	rs, body = ts.sendRaw("PUT", defaultBucket+"?x-custom", nil, nil)
+	if rs.StatusCode != http.StatusAccepted {
+		t.Fatal("unexpected response", rs.StatusCode, string(body))
+	}
+	rs, body = ts.sendRaw("GET", "other?x-custom", nil, nil)
+	ts.assertRawErrorCode(rs, body, gofakes3.ErrNotImplemented)
+
+	expected := []call{{defaultBucket, "dir/obj", "1"}, {defaultBucket, "", ""}, {"other", "", ""}}
+	if !reflect.DeepEqual(calls, expected) {
+		t.Fatal("unexpected calls", calls)
+	}
+
+	rs, body = ts.sendRaw("GET", defaultBucket+"?versioning", nil, nil)
+	ts.assertRawErrorCode(rs, body, gofakes3.ErrAccessDenied)
+}
+
+func TestRoutingSelectHandler(t *testing.T) {
+	ts := newTestServer(t, withFakerOptions(
+		gofakes3.WithSubresourceHandler("select", func(bucket, object string, query url.Values, w http.ResponseWriter, r *http.Request) error {
+			if r.Method != "POST" || query.Get("select-type") != "2" {
+				return gofakes3.ErrMethodNotAllowed
+			}
+			_, err := w.Write([]byte("selected " + object))
+			return err
+		}),
+	))
+	defer ts.Close()
+
+	// A Select implementation takes over from the NotImplemented response:
+	rs, body := ts.sendRaw("POST", defaultBucket+"/obj?select&select-type=2", nil, nil)
+	if rs.StatusCode != http.StatusOK || string(body) != "selected obj" {
+		t.Fatal("unexpected response", rs.StatusCode, string(body))
+	}
+}
+
+func TestRoutingQueryParameters(t *testing.T) {
+	ts := newTestServer(t)
+	defer ts.Close()
+	ts.backendPutString(defaultBucket, "a/obj", nil, "a")
+	ts.backendPutString(defaultBucket, "b/obj", nil, "b")
+
+	// Subresources with empty or repeated values are still routed to their
+	// handlers:
+	for _, path := range []string{
+		defaultBucket + "/a/obj?acl=",
+		defaultBucket + "/a/obj?acl&acl",
+		defaultBucket + "/a/obj?acl=&acl=x",
+	} {
+		rs, body := ts.sendRaw("GET", path, nil, nil)
+		if rs.StatusCode != http.StatusOK || !strings.Contains(string(body), "<AccessControlPolicy") {
+			t.Fatal("unexpected response for", path, rs.StatusCode, string(body))
+		}
+	}
+	rs, body := ts.sendRaw("GET", defaultBucket+"?versioning=&versioning", nil, nil)
+	if rs.StatusCode != http.StatusOK || !strings.Contains(string(body), "<VersioningConfiguration") {
+		t.Fatal("unexpected response", rs.StatusCode, string(body))
+	}
+
+	// The first of a repeated parameter is used:
+	rs, body = ts.sendRaw("GET", defaultBucket+"?prefix=a/&prefix=b/", nil, nil)
+	if rs.StatusCode != http.StatusOK || !strings.Contains(string(body), "<Key>a/obj</Key>") || strings.Contains(string(body), "b/obj") {
+		t.Fatal("unexpected response", rs.StatusCode, string(body))
+	}
+	rs, body = ts.sendRaw("GET", defaultBucket+"?max-keys=1&max-keys=1000", nil, nil)
+	if rs.StatusCode != http.StatusOK || strings.Contains(string(body), "b/obj") {
+		t.Fatal("unexpected response", rs.StatusCode, string(body))
+	}
+}
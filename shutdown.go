@@ -0,0 +1,37 @@
+package gofakes3
+
+import "context"
+
+// Shutdown gracefully stops GoFakeS3. It works like http.Server.Shutdown:
+// new requests are refused with ErrServiceUnavailable, then Shutdown waits
+// for in-flight requests, such as multipart part uploads, to finish before
+// flushing the Backend if it implements FlushingBackend.
+//
+// If ctx expires before the in-flight requests finish, Shutdown returns the
+// context's error without flushing the Backend. Shutdown also stops any
+// background work, as Close does. It does not stop the server returned by
+// Server(); the caller should close that once Shutdown returns.
+func (g *GoFakeS3) Shutdown(ctx context.Context) error {
+	g.shutdownMu.Lock()
+	g.shuttingDown = true
+	g.shutdownMu.Unlock()
+
+	g.Close()
+
+	done := make(chan struct{})
+	go func() {
+		g.handlers.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	if flusher, ok := g.storage.(FlushingBackend); ok {
+		return flusher.Flush()
+	}
+	return nil
+}
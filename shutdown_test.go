@@ -0,0 +1,68 @@
+package gofakes3_test
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/johannesboyne/gofakes3"
+	"github.com/johannesboyne/gofakes3/backend/s3mem"
+)
+
+type flushingBackend struct {
+	*blockingBackend
+	flushes int32
+}
+
+func (b *flushingBackend) Flush() error {
+	atomic.AddInt32(&b.flushes, 1)
+	return nil
+}
+
+func TestShutdown(t *testing.T) {
+	backend := &flushingBackend{blockingBackend: &blockingBackend{
+		Backend: s3mem.New(),
+		entered: make(chan struct{}),
+		release: make(chan struct{}),
+	}}
+	ts := newTestServer(t, withBackend(backend))
+	defer ts.Close()
+
+	done := make(chan int, 1)
+	go func() {
+		rs, err := httpClient().Get(ts.url("/"))
+		if err != nil {
+			done <- 0
+			return
+		}
+		rs.Body.Close()
+		done <- rs.StatusCode
+	}()
+	<-backend.entered
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := ts.Shutdown(ctx); err != context.DeadlineExceeded {
+		t.Fatal("expected context.DeadlineExceeded, found", err)
+	}
+	if n := atomic.LoadInt32(&backend.flushes); n != 0 {
+		t.Fatal("backend flushed before in-flight requests finished")
+	}
+
+	rs, body := ts.sendRaw("GET", defaultBucket, nil, nil)
+	ts.assertRawErrorCode(rs, body, gofakes3.ErrServiceUnavailable)
+
+	shutdown := make(chan error, 1)
+	go func() { shutdown <- ts.Shutdown(context.Background()) }()
+
+	close(backend.release)
+	if code := <-done; code != http.StatusOK {
+		t.Fatal("expected in-flight request to finish with status", http.StatusOK, "found", code)
+	}
+	ts.OK(<-shutdown)
+	if n := atomic.LoadInt32(&backend.flushes); n != 1 {
+		t.Fatal("expected backend to be flushed once, found", n)
+	}
+}
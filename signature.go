@@ -0,0 +1,383 @@
+package gofakes3
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	signatureV4Algorithm = "AWS4-HMAC-SHA256"
+	unsignedPayload      = "UNSIGNED-PAYLOAD"
+)
+
+// signatureV2Subresources are the query parameters that are part of the
+// resource signed by Signature Version 2; the rest of the query string is not
+// signed.
+var signatureV2Subresources = map[string]bool{
+	"accelerate": true, "acl": true, "analytics": true, "cors": true,
+	"delete": true, "inventory": true, "legal-hold": true, "lifecycle": true,
+	"location": true, "logging": true, "metrics": true, "notification": true,
+	"object-lock": true, "partNumber": true, "policy": true,
+	"replication": true, "requestPayment": true, "restore": true,
+	"retention": true, "select": true, "select-type": true, "tagging": true,
+	"torrent": true, "uploadId": true, "uploads": true, "versionId": true,
+	"versioning": true, "versions": true, "website": true,
+
+	"response-cache-control":       true,
+	"response-content-disposition": true,
+	"response-content-encoding":    true,
+	"response-content-language":    true,
+	"response-content-type":        true,
+	"response-expires":             true,
+}
+
+// signatureMismatchResponse is returned for ErrSignatureDoesNotMatch if
+// WithSignatureDebugging is passed. Like S3's, it holds what the signature was
+// calculated from, so it can be compared with what the client signed; the
+// secret key is never included.
+type signatureMismatchResponse struct {
+	ErrorResponse
+	AWSAccessKeyId    string
+	SignatureProvided string
+	StringToSign      string
+	CanonicalRequest  string `xml:",omitempty"`
+}
+
+var _ errorResponse = &signatureMismatchResponse{}
+
+// signatureMismatch returns the error for a request whose signature was not
+// the one calculated from stringToSign. canonicalRequest is empty for
+// Signature Version 2, which has none.
+func (g *GoFakeS3) signatureMismatch(accessKey, signature, stringToSign, canonicalRequest string) error {
+	if !g.signatureDebugging {
+		return ErrSignatureDoesNotMatch
+	}
+	g.log.Print(LogWarn, "signature mismatch for access key", accessKey,
+		"\ncanonical request:\n"+canonicalRequest,
+		"\nstring to sign:\n"+stringToSign)
+
+	code := ErrSignatureDoesNotMatch
+	return &signatureMismatchResponse{
+		ErrorResponse:     ErrorResponse{Code: code, Message: code.Message()},
+		AWSAccessKeyId:    accessKey,
+		SignatureProvided: signature,
+		StringToSign:      stringToSign,
+		CanonicalRequest:  canonicalRequest,
+	}
+}
+
+// signatureMiddleware implements WithSignatureVerification.
+func (g *GoFakeS3) signatureMiddleware(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, rq *http.Request) {
+		if err := g.verifySignature(rq); err != nil {
+			g.httpError(w, rq, err)
+			return
+		}
+		handler.ServeHTTP(w, rq)
+	})
+}
+
+// verifySignature checks the signature of a request signed with either
+// Signature Version 4 or 2, in the 'Authorization' header or the query string
+// of a presigned URL. Anonymous requests are left for authorizeAnonymous.
+//
+// The payload hash a Version 4 request declares is signed, but is not checked
+// against the body.
+func (g *GoFakeS3) verifySignature(r *http.Request) error {
+	auth := r.Header.Get("Authorization")
+	query := r.URL.Query()
+	switch {
+	case strings.HasPrefix(auth, signatureV4Algorithm+" "):
+		return g.verifySignatureV4(r, auth)
+	case strings.HasPrefix(auth, "AWS "):
+		return g.verifySignatureV2(r, auth)
+	case query.Get("X-Amz-Algorithm") != "":
+		return g.verifyPresignedV4(r, query)
+	case query.Get("AWSAccessKeyId") != "":
+		return g.verifyPresignedV2(r, query)
+	}
+	return nil
+}
+
+func (g *GoFakeS3) verifySignatureV4(r *http.Request, auth string) error {
+	fields := map[string]string{}
+	for _, field := range strings.Split(strings.TrimPrefix(auth, signatureV4Algorithm+" "), ",") {
+		parts := strings.SplitN(strings.TrimSpace(field), "=", 2)
+		if len(parts) == 2 {
+			fields[parts[0]] = parts[1]
+		}
+	}
+	credential, signedHeaders, signature := fields["Credential"], fields["SignedHeaders"], fields["Signature"]
+	if credential == "" || signedHeaders == "" || signature == "" {
+		return ErrorMessage(ErrAuthorizationHeaderMalformed, "The authorization header is malformed; the authorization header requires three components: Credential, SignedHeaders, and Signature.")
+	}
+
+	payloadHash := r.Header.Get("X-Amz-Content-Sha256")
+	if payloadHash == "" {
+		return ErrorMessage(ErrInvalidRequest, "Missing required header for this request: x-amz-content-sha256")
+	}
+	amzDate := r.Header.Get("X-Amz-Date")
+	if amzDate == "" {
+		amzDate = r.Header.Get("Date")
+	}
+	return g.checkSignatureV4(r, ErrAuthorizationHeaderMalformed, credential, amzDate, signedHeaders, payloadHash, signature)
+}
+
+func (g *GoFakeS3) verifyPresignedV4(r *http.Request, query url.Values) error {
+	if query.Get("X-Amz-Algorithm") != signatureV4Algorithm {
+		return ErrorMessage(ErrAuthorizationQueryParametersError, "X-Amz-Algorithm only supports \"AWS4-HMAC-SHA256\"")
+	}
+	amzDate := query.Get("X-Amz-Date")
+	issued, err := time.Parse("20060102T150405Z", amzDate)
+	if err != nil {
+		return ErrorMessage(ErrAuthorizationQueryParametersError, "X-Amz-Date must be in the ISO8601 Long Format \"yyyyMMdd'T'HHmmss'Z'\"")
+	}
+	expires, err := strconv.ParseInt(query.Get("X-Amz-Expires"), 10, 64)
+	if err != nil || expires < 0 {
+		return ErrorMessage(ErrAuthorizationQueryParametersError, "X-Amz-Expires should be a number")
+	}
+	if g.timeSource.Now().After(issued.Add(time.Duration(expires) * time.Second)) {
+		return ErrorMessage(ErrAccessDenied, "Request has expired")
+	}
+
+	payloadHash := query.Get("X-Amz-Content-Sha256")
+	if payloadHash == "" {
+		payloadHash = unsignedPayload
+	}
+	return g.checkSignatureV4(r, ErrAuthorizationQueryParametersError, query.Get("X-Amz-Credential"), amzDate, query.Get("X-Amz-SignedHeaders"), payloadHash, query.Get("X-Amz-Signature"))
+}
+
+// checkSignatureV4 calculates the Signature Version 4 signature of a request,
+// and compares it with the one the request carries. malformed is the error
+// code for a credential that can't be parsed, which depends on where it was
+// found.
+func (g *GoFakeS3) checkSignatureV4(r *http.Request, malformed ErrorCode, credential, amzDate, signedHeaders, payloadHash, signature string) error {
+	// The credential is 'access-key/date/region/service/aws4_request':
+	scope := strings.Split(credential, "/")
+	if len(scope) != 5 || scope[4] != "aws4_request" {
+		return ErrorMessage(malformed, "Error parsing the X-Amz-Credential parameter; the Credential is mal-formed; expecting \"<YOUR-AKID>/YYYYMMDD/REGION/SERVICE/aws4_request\".")
+	}
+	secret, ok := g.signatureSecrets[scope[0]]
+	if !ok {
+		return ErrInvalidAccessKeyId
+	}
+
+	canonicalRequest := strings.Join([]string{
+		r.Method,
+		requestRawPath(r),
+		canonicalQueryV4(r.URL.RawQuery),
+		canonicalHeadersV4(r, signedHeaders),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := strings.Join([]string{
+		signatureV4Algorithm,
+		amzDate,
+		strings.Join(scope[1:], "/"),
+		hex.EncodeToString(requestHash[:]),
+	}, "\n")
+
+	key := []byte("AWS4" + secret)
+	for _, part := range scope[1:] {
+		key = hmacSHA256(key, part)
+	}
+	expected := hex.EncodeToString(hmacSHA256(key, stringToSign))
+	if !hmac.Equal([]byte(expected), []byte(signature)) {
+		return g.signatureMismatch(scope[0], signature, stringToSign, canonicalRequest)
+	}
+	return nil
+}
+
+func (g *GoFakeS3) verifySignatureV2(r *http.Request, auth string) error {
+	parts := strings.SplitN(strings.TrimPrefix(auth, "AWS "), ":", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return ErrorMessage(ErrInvalidArgument, "AWS authorization header is invalid.  Expected AwsAccessKeyId:signature")
+	}
+
+	date := r.Header.Get("Date")
+	if r.Header.Get("X-Amz-Date") != "" {
+		// The 'x-amz-date' header is signed with the other 'x-amz-' headers;
+		// the client leaves the 'Date' line empty:
+		date = ""
+	}
+	return g.checkSignatureV2(r, parts[0], date, parts[1])
+}
+
+func (g *GoFakeS3) verifyPresignedV2(r *http.Request, query url.Values) error {
+	expires, err := strconv.ParseInt(query.Get("Expires"), 10, 64)
+	if err != nil {
+		return ErrorMessage(ErrAccessDenied, "Query-string authentication requires the Signature, Expires and AWSAccessKeyId parameters")
+	}
+	if g.timeSource.Now().After(time.Unix(expires, 0)) {
+		return ErrorMessage(ErrAccessDenied, "Request has expired")
+	}
+	return g.checkSignatureV2(r, query.Get("AWSAccessKeyId"), query.Get("Expires"), query.Get("Signature"))
+}
+
+// checkSignatureV2 calculates the Signature Version 2 signature of a request,
+// and compares it with the one the request carries. date is the 'Date' line
+// of the string to sign, which presigned URLs replace with their expiry time.
+func (g *GoFakeS3) checkSignatureV2(r *http.Request, accessKey, date, signature string) error {
+	secret, ok := g.signatureSecrets[accessKey]
+	if !ok {
+		return ErrInvalidAccessKeyId
+	}
+
+	stringToSign := strings.Join([]string{
+		r.Method,
+		r.Header.Get("Content-MD5"),
+		r.Header.Get("Content-Type"),
+		date,
+		canonicalAmzHeadersV2(r.Header) + g.canonicalResourceV2(r),
+	}, "\n")
+	mac := hmac.New(sha1.New, []byte(secret))
+	mac.Write([]byte(stringToSign))
+	expected := base64.StdEncoding.EncodeToString(mac.Sum(nil))
+	if !hmac.Equal([]byte(expected), []byte(signature)) {
+		return g.signatureMismatch(accessKey, signature, stringToSign, "")
+	}
+	return nil
+}
+
+// requestRawPath returns the path of a request as the client sent it, before
+// it was unescaped or rewritten by hostBucketMiddleware.
+func requestRawPath(r *http.Request) string {
+	path := r.RequestURI
+	if !strings.HasPrefix(path, "/") {
+		// An absolute URI, as sent to proxies:
+		if u, err := url.Parse(path); err == nil {
+			path = u.EscapedPath()
+		}
+	}
+	if idx := strings.IndexByte(path, '?'); idx >= 0 {
+		path = path[:idx]
+	}
+	if path == "" {
+		path = "/"
+	}
+	return path
+}
+
+func canonicalQueryV4(rawQuery string) string {
+	var params []string
+	for _, param := range strings.Split(rawQuery, "&") {
+		if param == "" {
+			continue
+		}
+		kv := strings.SplitN(param, "=", 2)
+		name, _ := url.QueryUnescape(kv[0])
+		if name == "X-Amz-Signature" {
+			continue
+		}
+		var value string
+		if len(kv) == 2 {
+			value, _ = url.QueryUnescape(kv[1])
+		}
+		params = append(params, uriEncodeV4(name)+"="+uriEncodeV4(value))
+	}
+	sort.Strings(params)
+	return strings.Join(params, "&")
+}
+
+// canonicalHeadersV4 returns the canonical headers of a request, including
+// the blank line that ends them.
+func canonicalHeadersV4(r *http.Request, signedHeaders string) string {
+	var b strings.Builder
+	for _, name := range strings.Split(signedHeaders, ";") {
+		values := r.Header[http.CanonicalHeaderKey(name)]
+		if name == "host" {
+			values = []string{r.Host}
+		}
+		trimmed := make([]string, len(values))
+		for i, value := range values {
+			trimmed[i] = strings.Join(strings.Fields(value), " ")
+		}
+		b.WriteString(name + ":" + strings.Join(trimmed, ",") + "\n")
+	}
+	return b.String()
+}
+
+// uriEncodeV4 percent-encodes everything but the characters Signature
+// Version 4 leaves unreserved.
+func uriEncodeV4(s string) string {
+	const hexDigits = "0123456789ABCDEF"
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' || c == '-' || c == '_' || c == '.' || c == '~' {
+			b.WriteByte(c)
+		} else {
+			b.WriteByte('%')
+			b.WriteByte(hexDigits[c>>4])
+			b.WriteByte(hexDigits[c&0xf])
+		}
+	}
+	return b.String()
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+func canonicalAmzHeadersV2(header http.Header) string {
+	var names []string
+	for name := range header {
+		if lower := strings.ToLower(name); strings.HasPrefix(lower, "x-amz-") {
+			names = append(names, lower)
+		}
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	for _, name := range names {
+		values := header[http.CanonicalHeaderKey(name)]
+		trimmed := make([]string, len(values))
+		for i, value := range values {
+			trimmed[i] = strings.TrimSpace(value)
+		}
+		b.WriteString(name + ":" + strings.Join(trimmed, ",") + "\n")
+	}
+	return b.String()
+}
+
+// canonicalResourceV2 returns the resource signed by Signature Version 2: the
+// path, prefixed with the bucket if it was taken from the host, and the
+// subresources in the query string.
+func (g *GoFakeS3) canonicalResourceV2(r *http.Request) string {
+	resource := requestRawPath(r)
+	if g.hostBucket {
+		resource = "/" + strings.SplitN(r.Host, ".", 2)[0] + resource
+	}
+
+	query := r.URL.Query()
+	var names []string
+	for name := range query {
+		if signatureV2Subresources[name] {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for i, name := range names {
+		sep := "&"
+		if i == 0 {
+			sep = "?"
+		}
+		resource += sep + name
+		if value := query.Get(name); value != "" {
+			resource += "=" + value
+		}
+	}
+	return resource
+}
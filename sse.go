@@ -0,0 +1,69 @@
+package gofakes3
+
+import (
+	"net/http"
+	"strings"
+)
+
+// ServerSideEncryptionHeader, SSEKMSKeyIDHeader and SSEBucketKeyEnabledHeader
+// request server-side encryption with S3-managed (SSE-S3) or KMS-managed
+// (SSE-KMS) keys when writing an object.
+//
+// As with SSE-C, GoFakeS3 does not encrypt anything, and there is no KMS: the
+// headers are validated and stored with the object, and returned by the write
+// and by GET and HEAD requests.
+const (
+	ServerSideEncryptionHeader = "X-Amz-Server-Side-Encryption"
+	SSEKMSKeyIDHeader          = "X-Amz-Server-Side-Encryption-Aws-Kms-Key-Id"
+	SSEBucketKeyEnabledHeader  = "X-Amz-Server-Side-Encryption-Bucket-Key-Enabled"
+)
+
+// The values of ServerSideEncryptionHeader S3 supports.
+const (
+	ServerSideEncryptionAES256  = "AES256"
+	ServerSideEncryptionKMS     = "aws:kms"
+	ServerSideEncryptionKMSDSSE = "aws:kms:dsse"
+)
+
+// serverSideEncryptionMeta validates the SSE-S3 and SSE-KMS headers that
+// metadataHeaders put in meta along with the other 'x-amz-' headers. Like
+// S3, a bucket key that is not enabled is not stored.
+func serverSideEncryptionMeta(meta map[string]string) error {
+	sse, sseOK := meta[ServerSideEncryptionHeader]
+	if sseOK {
+		switch sse {
+		case ServerSideEncryptionAES256, ServerSideEncryptionKMS, ServerSideEncryptionKMSDSSE:
+		default:
+			return ErrorInvalidArgument(ServerSideEncryptionHeader, sse, "The encryption method specified is not supported")
+		}
+	}
+	kms := strings.HasPrefix(sse, ServerSideEncryptionKMS)
+
+	if keyID, ok := meta[SSEKMSKeyIDHeader]; ok && !kms {
+		return ErrorInvalidArgument(SSEKMSKeyIDHeader, keyID, "Server Side Encryption with AWS KMS managed key requires HTTP header x-amz-server-side-encryption : aws:kms")
+	}
+
+	if enabled, ok := meta[SSEBucketKeyEnabledHeader]; ok {
+		switch {
+		case strings.EqualFold(enabled, "false"):
+			delete(meta, SSEBucketKeyEnabledHeader)
+		case !strings.EqualFold(enabled, "true"):
+			return ErrorInvalidArgument(SSEBucketKeyEnabledHeader, enabled, "Bucket key enabled must be true or false")
+		case !kms:
+			return ErrorInvalidArgument(SSEBucketKeyEnabledHeader, enabled, "Bucket key is only supported with Server Side Encryption with AWS KMS managed keys")
+		default:
+			meta[SSEBucketKeyEnabledHeader] = "true"
+		}
+	}
+	return nil
+}
+
+// writeServerSideEncryptionHeaders confirms the SSE-S3 or SSE-KMS settings an
+// object was written with, as S3 does in the response to the write.
+func writeServerSideEncryptionHeaders(meta map[string]string, w http.ResponseWriter) {
+	for _, hdr := range []string{ServerSideEncryptionHeader, SSEKMSKeyIDHeader, SSEBucketKeyEnabledHeader} {
+		if v, ok := meta[hdr]; ok {
+			w.Header().Set(hdr, v)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package gofakes3_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/johannesboyne/gofakes3"
+)
+
+func TestServerSideEncryptionKMS(t *testing.T) {
+	ts := newTestServer(t)
+	defer ts.Close()
+	svc := ts.s3Client()
+
+	const keyID = "arn:aws:kms:region:123456789012:key/some-key"
+	sse := http.Header{
+		gofakes3.ServerSideEncryptionHeader: {"aws:kms"},
+		gofakes3.SSEKMSKeyIDHeader:          {keyID},
+		gofakes3.SSEBucketKeyEnabledHeader:  {"TRUE"},
+	}
+	assertHeaders := func(rs *http.Response, body []byte) {
+		t.Helper()
+		if rs.StatusCode != http.StatusOK {
+			t.Fatal("unexpected status", rs.StatusCode, string(body))
+		}
+		if rs.Header.Get(gofakes3.ServerSideEncryptionHeader) != "aws:kms" ||
+			rs.Header.Get(gofakes3.SSEKMSKeyIDHeader) != keyID ||
+			rs.Header.Get(gofakes3.SSEBucketKeyEnabledHeader) != "true" {
+			t.Fatal("unexpected headers", rs.Header)
+		}
+	}
+	assertHeaders(ts.sendRaw("PUT", defaultBucket+"/object", []byte("hello"), sse))
+	assertHeaders(ts.sendRaw("GET", defaultBucket+"/object", nil, nil))
+	assertHeaders(ts.sendRaw("HEAD", defaultBucket+"/object", nil, nil))
+
+	head, err := svc.HeadObject(&s3.HeadObjectInput{Bucket: aws.String(defaultBucket), Key: aws.String("object")})
+	ts.OK(err)
+	if aws.StringValue(head.ServerSideEncryption) != "aws:kms" || aws.StringValue(head.SSEKMSKeyId) != keyID {
+		t.Fatal("unexpected head output", head)
+	}
+
+	upload, err := svc.CreateMultipartUpload(&s3.CreateMultipartUploadInput{
+		Bucket:               aws.String(defaultBucket),
+		Key:                  aws.String("multipart"),
+		ServerSideEncryption: aws.String(gofakes3.ServerSideEncryptionAES256),
+	})
+	ts.OK(err)
+	if aws.StringValue(upload.ServerSideEncryption) != "AES256" {
+		t.Fatal("unexpected upload output", upload)
+	}
+
+	// A disabled bucket key is not returned:
+	rs, body := ts.sendRaw("PUT", defaultBucket+"/disabled", []byte("hello"), http.Header{
+		gofakes3.ServerSideEncryptionHeader: {"aws:kms"},
+		gofakes3.SSEBucketKeyEnabledHeader:  {"false"},
+	})
+	if rs.StatusCode != http.StatusOK || rs.Header.Get(gofakes3.SSEBucketKeyEnabledHeader) != "" {
+		t.Fatal("unexpected response", rs.StatusCode, rs.Header, string(body))
+	}
+}
+
+func TestServerSideEncryptionInvalid(t *testing.T) {
+	ts := newTestServer(t)
+	defer ts.Close()
+
+	for _, header := range []http.Header{
+		{gofakes3.ServerSideEncryptionHeader: {"rot13"}},
+		{gofakes3.SSEKMSKeyIDHeader: {"some-key"}},
+		{gofakes3.ServerSideEncryptionHeader: {"AES256"}, gofakes3.SSEKMSKeyIDHeader: {"some-key"}},
+		{gofakes3.ServerSideEncryptionHeader: {"aws:kms"}, gofakes3.SSEBucketKeyEnabledHeader: {"yes"}},
+		{gofakes3.ServerSideEncryptionHeader: {"AES256"}, gofakes3.SSEBucketKeyEnabledHeader: {"true"}},
+	} {
+		rs, body := ts.sendRaw("PUT", defaultBucket+"/object", []byte("hello"), header)
+		ts.assertRawErrorCode(rs, body, gofakes3.ErrInvalidArgument)
+	}
+}
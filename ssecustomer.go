@@ -0,0 +1,116 @@
+package gofakes3
+
+import (
+	"crypto/md5"
+	"encoding/base64"
+	"net/http"
+)
+
+// SSECustomerAlgorithmHeader, SSECustomerKeyHeader and SSECustomerKeyMD5Header
+// request server-side encryption with a customer-provided key (SSE-C) when
+// writing an object, and must be sent again with the same key to read it.
+//
+// GoFakeS3 does not encrypt anything: the object is stored as it was sent,
+// along with the algorithm and the key's MD5, so that later requests can be
+// checked for the same key. The key itself is never stored.
+const (
+	SSECustomerAlgorithmHeader = "X-Amz-Server-Side-Encryption-Customer-Algorithm"
+	SSECustomerKeyHeader       = "X-Amz-Server-Side-Encryption-Customer-Key"
+	SSECustomerKeyMD5Header    = "X-Amz-Server-Side-Encryption-Customer-Key-Md5"
+)
+
+// CopySourceSSECustomerPrefix is prepended to the names of the SSE-C headers to
+// give the headers that CopyObject takes the copy source's key from, e.g.
+// 'X-Amz-Copy-Source-Server-Side-Encryption-Customer-Key'.
+const CopySourceSSECustomerPrefix = "X-Amz-Copy-Source-"
+
+// SSECustomerAlgorithm is the only algorithm S3 supports for SSE-C.
+const SSECustomerAlgorithm = "AES256"
+
+type sseCustomerKey struct {
+	algorithm string
+	keyMD5    string
+}
+
+// parseSSECustomerKey validates the SSE-C headers of a request, with prefix
+// prepended to their names if it is not empty. It returns nil if none of the
+// headers were sent.
+func parseSSECustomerKey(h http.Header, prefix string) (*sseCustomerKey, error) {
+	var (
+		algorithmHeader = ssecHeader(prefix, SSECustomerAlgorithmHeader)
+		keyHeader       = ssecHeader(prefix, SSECustomerKeyHeader)
+		keyMD5Header    = ssecHeader(prefix, SSECustomerKeyMD5Header)
+		algorithm       = h.Get(algorithmHeader)
+		key             = h.Get(keyHeader)
+		keyMD5          = h.Get(keyMD5Header)
+	)
+	if algorithm == "" && key == "" && keyMD5 == "" {
+		return nil, nil
+	}
+
+	if algorithm == "" {
+		return nil, ErrorInvalidArgument(algorithmHeader, "", "Requests specifying Server Side Encryption with Customer provided keys must provide a valid encryption algorithm.")
+	} else if algorithm != SSECustomerAlgorithm {
+		return nil, ErrorInvalidArgument(algorithmHeader, algorithm, "The encryption method specified is not supported")
+	} else if key == "" {
+		return nil, ErrorInvalidArgument(keyHeader, "", "Requests specifying Server Side Encryption with Customer provided keys must provide an appropriate secret key.")
+	} else if keyMD5 == "" {
+		return nil, ErrorInvalidArgument(keyMD5Header, "", "Requests specifying Server Side Encryption with Customer provided keys must provide the client calculated MD5 of the secret key.")
+	}
+
+	raw, err := base64.StdEncoding.DecodeString(key)
+	if err != nil || len(raw) != 32 {
+		return nil, ErrorInvalidArgument(keyHeader, "", "The secret key was invalid for the specified algorithm.")
+	}
+	sum := md5.Sum(raw)
+	if base64.StdEncoding.EncodeToString(sum[:]) != keyMD5 {
+		return nil, ErrorMessage(ErrInvalidRequest, "The calculated MD5 hash of the key did not match the hash that was provided.")
+	}
+
+	return &sseCustomerKey{algorithm: algorithm, keyMD5: keyMD5}, nil
+}
+
+func ssecHeader(prefix, header string) string {
+	if prefix == "" {
+		return header
+	}
+	return prefix + header[len("X-Amz-"):]
+}
+
+// sseCustomerMeta validates the SSE-C headers of a request that writes an
+// object, and removes the key from meta, where metadataHeaders put it along
+// with the other 'x-amz-' headers. The algorithm and the key MD5 are kept.
+func sseCustomerMeta(meta map[string]string, h http.Header) (*sseCustomerKey, error) {
+	key, err := parseSSECustomerKey(h, "")
+	if err != nil {
+		return nil, err
+	}
+	delete(meta, SSECustomerKeyHeader)
+	return key, nil
+}
+
+// checkSSECustomerKey checks that a request reading an object supplied the
+// key the object was stored with, if it was stored with SSE-C. Like S3, a
+// wrong key is reported as ErrAccessDenied.
+func checkSSECustomerKey(meta map[string]string, key *sseCustomerKey) error {
+	storedMD5 := meta[SSECustomerKeyMD5Header]
+	switch {
+	case storedMD5 == "" && key != nil:
+		return ErrorMessage(ErrInvalidRequest, "The encryption parameters are not applicable to this object.")
+	case storedMD5 != "" && key == nil:
+		return ErrorMessage(ErrInvalidRequest, "The object was stored using a form of Server Side Encryption. The correct parameters must be provided to retrieve the object.")
+	case storedMD5 != "" && key.keyMD5 != storedMD5:
+		return ErrAccessDenied
+	}
+	return nil
+}
+
+// writeHeaders confirms the algorithm and key MD5 in the response, as S3 does
+// for every request that supplies an SSE-C key.
+func (k *sseCustomerKey) writeHeaders(w http.ResponseWriter) {
+	if k == nil {
+		return
+	}
+	w.Header().Set(SSECustomerAlgorithmHeader, k.algorithm)
+	w.Header().Set(SSECustomerKeyMD5Header, k.keyMD5)
+}
@@ -0,0 +1,101 @@
+package gofakes3_test
+
+import (
+	"crypto/md5"
+	"encoding/base64"
+	"net/http"
+	"testing"
+
+	"github.com/johannesboyne/gofakes3"
+)
+
+func ssecHeaders(h http.Header, prefix, key string) http.Header {
+	if h == nil {
+		h = http.Header{}
+	}
+	sum := md5.Sum([]byte(key))
+	h.Set(prefix+"Server-Side-Encryption-Customer-Algorithm", gofakes3.SSECustomerAlgorithm)
+	h.Set(prefix+"Server-Side-Encryption-Customer-Key", base64.StdEncoding.EncodeToString([]byte(key)))
+	h.Set(prefix+"Server-Side-Encryption-Customer-Key-Md5", base64.StdEncoding.EncodeToString(sum[:]))
+	return h
+}
+
+func TestSSECustomerKey(t *testing.T) {
+	ts := newTestServer(t)
+	defer ts.Close()
+
+	const key1 = "0123456789abcdef0123456789abcdef"
+	const key2 = "fedcba9876543210fedcba9876543210"
+	key1MD5 := ssecHeaders(nil, "X-Amz-", key1).Get(gofakes3.SSECustomerKeyMD5Header)
+
+	assertGet := func(key string, header http.Header, code int) {
+		t.Helper()
+		rs, body := ts.sendRaw("GET", defaultBucket+"/"+key, nil, header)
+		if rs.StatusCode != code {
+			t.Fatal("unexpected status", rs.StatusCode, string(body))
+		}
+		if rs.Header.Get(gofakes3.SSECustomerKeyHeader) != "" {
+			t.Fatal("unexpected key in response")
+		}
+	}
+
+	rs, body := ts.sendRaw("PUT", defaultBucket+"/obj", []byte("hello"), ssecHeaders(nil, "X-Amz-", key1))
+	if rs.StatusCode != 200 || rs.Header.Get(gofakes3.SSECustomerKeyMD5Header) != key1MD5 {
+		t.Fatal("unexpected response", rs.StatusCode, rs.Header, string(body))
+	}
+
+	t.Run("get", func(t *testing.T) {
+		rs, body := ts.sendRaw("GET", defaultBucket+"/obj", nil, nil)
+		ts.assertRawErrorCode(rs, body, gofakes3.ErrInvalidRequest)
+		rs, body = ts.sendRaw("GET", defaultBucket+"/obj", nil, ssecHeaders(nil, "X-Amz-", key2))
+		ts.assertRawErrorCode(rs, body, gofakes3.ErrAccessDenied)
+		assertGet("obj", ssecHeaders(nil, "X-Amz-", key1), 200)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		h := ssecHeaders(nil, "X-Amz-", key1)
+		h.Set(gofakes3.SSECustomerKeyMD5Header, key1MD5[1:])
+		rs, body := ts.sendRaw("PUT", defaultBucket+"/bad", []byte("hello"), h)
+		ts.assertRawErrorCode(rs, body, gofakes3.ErrInvalidRequest)
+
+		h = ssecHeaders(nil, "X-Amz-", key1)
+		h.Del(gofakes3.SSECustomerKeyHeader)
+		rs, body = ts.sendRaw("PUT", defaultBucket+"/bad", []byte("hello"), h)
+		ts.assertRawErrorCode(rs, body, gofakes3.ErrInvalidArgument)
+	})
+
+	t.Run("copy", func(t *testing.T) {
+		copySource := http.Header{"X-Amz-Copy-Source": {"/" + defaultBucket + "/obj"}}
+		rs, body := ts.sendRaw("PUT", defaultBucket+"/copy", nil, copySource)
+		ts.assertRawErrorCode(rs, body, gofakes3.ErrInvalidRequest)
+
+		h := ssecHeaders(ssecHeaders(copySource.Clone(), gofakes3.CopySourceSSECustomerPrefix, key1), "X-Amz-", key1)
+		h.Set(gofakes3.SSECustomerKeyMD5Header, key1MD5[1:])
+		rs, body = ts.sendRaw("PUT", defaultBucket+"/copy", nil, h)
+		ts.assertRawErrorCode(rs, body, gofakes3.ErrInvalidRequest)
+
+		// The source's key decrypts it, and the copy is encrypted with the
+		// destination's:
+		h = ssecHeaders(ssecHeaders(copySource.Clone(), gofakes3.CopySourceSSECustomerPrefix, key1), "X-Amz-", key2)
+		rs, body = ts.sendRaw("PUT", defaultBucket+"/copy", nil, h)
+		if rs.StatusCode != 200 {
+			t.Fatal("unexpected status", rs.StatusCode, string(body))
+		}
+		assertGet("copy", ssecHeaders(nil, "X-Amz-", key2), 200)
+		assertGet("copy", ssecHeaders(nil, "X-Amz-", key1), 403)
+
+		// Without a destination key, the copy is not encrypted:
+		rs, body = ts.sendRaw("PUT", defaultBucket+"/plain", nil, ssecHeaders(copySource.Clone(), gofakes3.CopySourceSSECustomerPrefix, key1))
+		if rs.StatusCode != 200 {
+			t.Fatal("unexpected status", rs.StatusCode, string(body))
+		}
+		assertGet("plain", nil, 200)
+
+		// Changing the key is enough to copy an object onto itself:
+		rs, body = ts.sendRaw("PUT", defaultBucket+"/obj", nil, h)
+		if rs.StatusCode != 200 {
+			t.Fatal("unexpected status", rs.StatusCode, string(body))
+		}
+		assertGet("obj", ssecHeaders(nil, "X-Amz-", key2), 200)
+	})
+}
@@ -0,0 +1,137 @@
+package gofakes3
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+)
+
+// commonQueryParameters may be sent with any request: the parameters of
+// presigned URLs for both Signature Version 4 and 2, and the 'x-id' some SDKs
+// add to name the operation.
+var commonQueryParameters = []string{
+	"AWSAccessKeyId",
+	"Expires",
+	"Signature",
+	"X-Amz-Algorithm",
+	"X-Amz-Credential",
+	"X-Amz-Date",
+	"X-Amz-Expires",
+	"X-Amz-Security-Token",
+	"X-Amz-Signature",
+	"X-Amz-SignedHeaders",
+	"x-id",
+}
+
+// operationQueryParameters lists the query parameters each operation
+// honours, by the names requestOperation returns. Operations on the
+// subresources in subresourceOperations also accept the subresource's own
+// parameter, along with 'versionId' for objects, and 'id' and, for listings,
+// 'continuation-token' for configurations addressed by one; see
+// queryParameterAllowed.
+var operationQueryParameters = map[string][]string{
+	"ListBuckets":             {CapabilitiesQuery, "continuation-token", "max-buckets", "prefix"},
+	"ListObjects":             {"delimiter", "encoding-type", "marker", "max-keys", "prefix"},
+	"ListObjectsV2":           {"continuation-token", "delimiter", "encoding-type", "fetch-owner", "list-type", "max-keys", "prefix", "start-after"},
+	"ListObjectVersions":      {"delimiter", "encoding-type", "key-marker", "max-keys", "prefix", "version-id-marker", "versions"},
+	"ListMultipartUploads":    {"delimiter", "key-marker", "max-uploads", "prefix", "upload-id-marker", "uploads"},
+	"CreateMultipartUpload":   {"uploads"},
+	"ListParts":               {"max-parts", "part-number-marker", "uploadId"},
+	"UploadPart":              {"partNumber", "uploadId"},
+	"UploadPartCopy":          {"partNumber", "uploadId"},
+	"CompleteMultipartUpload": {"uploadId"},
+	"AbortMultipartUpload":    {"uploadId"},
+	"DeleteObjects":           {"delete"},
+	"GetObject":               {"partNumber", "versionId"},
+	"HeadObject":              {"partNumber", "versionId"},
+	"DeleteObject":            {"versionId"},
+}
+
+// queryParameterAllowed reports whether the operation honours the query
+// parameter.
+func queryParameterAllowed(operation, object, name string) bool {
+	for _, allowed := range commonQueryParameters {
+		if name == allowed {
+			return true
+		}
+	}
+	for _, allowed := range operationQueryParameters[operation] {
+		if name == allowed {
+			return true
+		}
+	}
+
+	if operation == "GetObject" {
+		for _, override := range responseHeaderOverrides {
+			if name == override[0] {
+				return true
+			}
+		}
+	}
+
+	subresource, ok := operationSubresource(operation)
+	if !ok {
+		return false
+	}
+	switch name {
+	case subresource:
+		return true
+	case "versionId":
+		return object != ""
+	case "id":
+		_, ok := subresourceOperations[subresource]["LIST"]
+		return ok
+	case "continuation-token":
+		return operation == subresourceOperations[subresource]["LIST"]
+	}
+	return false
+}
+
+// operationSubresource returns the subresource in subresourceOperations that
+// the operation is invoked through, if there is one.
+func operationSubresource(operation string) (name string, ok bool) {
+	for _, op := range bucketACLOperations {
+		if op == operation {
+			return "acl", true
+		}
+	}
+	for name, operations := range subresourceOperations {
+		for _, op := range operations {
+			if op == operation {
+				return name, true
+			}
+		}
+	}
+	return "", false
+}
+
+// checkQueryParameters implements WithStrictQueryParameters: it rejects a
+// request with a query parameter its operation does not honour. Requests
+// that don't match an operation GoFakeS3 implements are left for routing to
+// reject, as are those for subresources added with WithSubresourceHandler,
+// whose parameters GoFakeS3 knows nothing about.
+func (g *GoFakeS3) checkQueryParameters(bucket, object string, query url.Values, r *http.Request) error {
+	if !g.strictQueryParameters {
+		return nil
+	}
+	if _, ok := g.findSubresourceHandler(query); ok {
+		return nil
+	}
+	operation := requestOperation(bucket, object, query, r)
+	if operation == "" {
+		return nil
+	}
+
+	names := make([]string, 0, len(query))
+	for name := range query {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if !queryParameterAllowed(operation, object, name) {
+			return ErrorInvalidArgument(name, query.Get(name), fmt.Sprintf("%s does not support the query parameter '%s'", operation, name))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+package gofakes3
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// syntheticTree is a key hierarchy to generate, added by WithSyntheticTree.
+type syntheticTree struct {
+	bucket string
+	depth  int
+	fanout int
+}
+
+// keys returns the keys of the tree in lexical order. Each key is made of
+// depth path segments, each of which is the index of the segment among its
+// siblings, zero-padded so that the keys sort in the order they are
+// generated:
+//
+//	depth 2, fanout 3: 0/0, 0/1, 0/2, 1/0, ..., 2/2
+func (t syntheticTree) keys() []string {
+	if t.depth <= 0 || t.fanout <= 0 {
+		return nil
+	}
+	width := len(strconv.Itoa(t.fanout - 1))
+
+	var keys []string
+	segments := make([]string, t.depth)
+	var walk func(level int)
+	walk = func(level int) {
+		for i := 0; i < t.fanout; i++ {
+			segments[level] = fmt.Sprintf("%0*d", width, i)
+			if level == t.depth-1 {
+				keys = append(keys, strings.Join(segments, "/"))
+			} else {
+				walk(level + 1)
+			}
+		}
+	}
+	walk(0)
+	return keys
+}
+
+// populateSyntheticTree writes the tree to the Backend, creating the bucket if
+// it does not already exist. The content of each object is its key.
+func (g *GoFakeS3) populateSyntheticTree(tree syntheticTree) error {
+	exists, err := g.storage.BucketExists(tree.bucket)
+	if err != nil {
+		return err
+	} else if !exists {
+		if err := g.storage.CreateBucket(tree.bucket); err != nil {
+			return err
+		}
+	}
+
+	for _, key := range tree.keys() {
+		if _, err := g.storage.PutObject(tree.bucket, key, map[string]string{}, strings.NewReader(key), int64(len(key))); err != nil {
+			return err
+		}
+	}
+	return nil
+}
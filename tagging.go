@@ -0,0 +1,165 @@
+package gofakes3
+
+import (
+	"net/http"
+	"net/url"
+	"sort"
+)
+
+const objectTaggingConfig = "tagging"
+
+// TaggingHeader may be sent when creating an object to set its tags, as a
+// URL-encoded query string, e.g. 'key1=value1&key2=value2'.
+const TaggingHeader = "X-Amz-Tagging"
+
+// TaggingCountHeader is returned by GET and HEAD object requests with the
+// number of tags the object has, if it has any. The tags themselves are only
+// returned by GetObjectTagging.
+const TaggingCountHeader = "X-Amz-Tagging-Count"
+
+// TaggingDirectiveHeader may be sent with CopyObject to choose whether the
+// destination keeps the source's tags ('COPY', the default) or takes the
+// tags in the TaggingHeader instead ('REPLACE').
+const TaggingDirectiveHeader = "X-Amz-Tagging-Directive"
+
+const (
+	TaggingDirectiveCopy    = "COPY"
+	TaggingDirectiveReplace = "REPLACE"
+)
+
+// Limits on object tags, documented here:
+// https://docs.aws.amazon.com/AmazonS3/latest/userguide/object-tagging.html
+const (
+	MaxObjectTags     = 10
+	MaxTagKeyLength   = 128
+	MaxTagValueLength = 256
+)
+
+// parseTaggingHeader converts the value of a TaggingHeader into a Tagging.
+func parseTaggingHeader(hdr string) (*Tagging, error) {
+	values, err := url.ParseQuery(hdr)
+	if err != nil {
+		return nil, ErrorMessage(ErrInvalidArgument, "The header 'x-amz-tagging' shall be encoded as UTF-8 then URLEncoded URL query parameters without tag name duplicates.")
+	}
+
+	tagging := &Tagging{}
+	for key, vs := range values {
+		if len(vs) > 1 {
+			return nil, ErrorMessage(ErrInvalidTag, "Cannot provide multiple Tags with the same key")
+		}
+		tagging.TagSet = append(tagging.TagSet, Tag{Key: key, Value: vs[0]})
+	}
+	sort.Slice(tagging.TagSet, func(i, j int) bool { return tagging.TagSet[i].Key < tagging.TagSet[j].Key })
+
+	return tagging, validateTagging(tagging)
+}
+
+// formatTaggingHeader converts a Tagging into the value of a TaggingHeader.
+func formatTaggingHeader(tagging *Tagging) string {
+	values := url.Values{}
+	for _, tag := range tagging.TagSet {
+		values.Set(tag.Key, tag.Value)
+	}
+	return values.Encode()
+}
+
+func validateTagging(tagging *Tagging) error {
+	if len(tagging.TagSet) > MaxObjectTags {
+		return ErrorMessage(ErrInvalidTag, "Object tags cannot be greater than 10")
+	}
+
+	seen := make(map[string]bool, len(tagging.TagSet))
+	for _, tag := range tagging.TagSet {
+		if tag.Key == "" || len(tag.Key) > MaxTagKeyLength {
+			return ErrorMessage(ErrInvalidTag, "The TagKey you have provided is invalid")
+		}
+		if len(tag.Value) > MaxTagValueLength {
+			return ErrorMessage(ErrInvalidTag, "The TagValue you have provided is invalid")
+		}
+		if seen[tag.Key] {
+			return ErrorMessage(ErrInvalidTag, "Cannot provide multiple Tags with the same key")
+		}
+		seen[tag.Key] = true
+	}
+	return nil
+}
+
+// objectTagging returns the tags for an object version. Tags set with
+// PutObjectTagging take precedence over the TaggingHeader the object was
+// created with, which metadataHeaders stores along with the other 'x-amz-'
+// headers.
+func (g *GoFakeS3) objectTagging(bucket, object string, obj *Object) (*Tagging, error) {
+	key := resourceConfigKey{bucket: bucket, object: object, version: obj.VersionID, kind: objectTaggingConfig}
+	if config, ok := g.configs.get(key); ok {
+		return config.(*Tagging), nil
+	}
+	if hdr := obj.Metadata[TaggingHeader]; hdr != "" {
+		return parseTaggingHeader(hdr)
+	}
+	return &Tagging{}, nil
+}
+
+func (g *GoFakeS3) getObjectTagging(bucket, object string, versionID VersionID, w http.ResponseWriter, r *http.Request) error {
+	g.log.Print(LogInfo, "GET OBJECT TAGGING:", bucket, object, versionID)
+
+	obj, err := g.headObjectVersion(bucket, object, versionID)
+	if err != nil {
+		return err
+	}
+
+	tagging, err := g.objectTagging(bucket, object, obj)
+	if err != nil {
+		return err
+	}
+
+	if obj.VersionID != "" {
+		w.Header().Set("x-amz-version-id", string(obj.VersionID))
+	}
+	out := *tagging
+	out.Xmlns = "http://s3.amazonaws.com/doc/2006-03-01/"
+	if out.TagSet == nil {
+		out.TagSet = []Tag{}
+	}
+	return g.xmlEncoder(w).Encode(&out)
+}
+
+func (g *GoFakeS3) putObjectTagging(bucket, object string, versionID VersionID, w http.ResponseWriter, r *http.Request) error {
+	g.log.Print(LogInfo, "PUT OBJECT TAGGING:", bucket, object, versionID)
+
+	key, err := g.objectConfigKey(bucket, object, versionID, objectTaggingConfig)
+	if err != nil {
+		return err
+	}
+
+	var in Tagging
+	if err := g.xmlDecodeBody(r.Body, &in); err != nil {
+		return err
+	}
+	if err := validateTagging(&in); err != nil {
+		return err
+	}
+
+	g.configs.put(key, &in)
+	if key.version != "" {
+		w.Header().Set("x-amz-version-id", string(key.version))
+	}
+	return nil
+}
+
+func (g *GoFakeS3) deleteObjectTagging(bucket, object string, versionID VersionID, w http.ResponseWriter, r *http.Request) error {
+	g.log.Print(LogInfo, "DELETE OBJECT TAGGING:", bucket, object, versionID)
+
+	key, err := g.objectConfigKey(bucket, object, versionID, objectTaggingConfig)
+	if err != nil {
+		return err
+	}
+
+	// An empty tag set takes precedence over any tags the object was created
+	// with:
+	g.configs.put(key, &Tagging{})
+	if key.version != "" {
+		w.Header().Set("x-amz-version-id", string(key.version))
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
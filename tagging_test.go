@@ -0,0 +1,187 @@
+package gofakes3_test
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/johannesboyne/gofakes3"
+)
+
+func TestObjectTagging(t *testing.T) {
+	ts := newTestServer(t)
+	defer ts.Close()
+	svc := ts.s3Client()
+
+	assertTags := func(key string, expected map[string]string) {
+		t.Helper()
+		rs, err := svc.GetObjectTagging(&s3.GetObjectTaggingInput{
+			Bucket: aws.String(defaultBucket),
+			Key:    aws.String(key),
+		})
+		ts.OK(err)
+		found := map[string]string{}
+		for _, tag := range rs.TagSet {
+			found[aws.StringValue(tag.Key)] = aws.StringValue(tag.Value)
+		}
+		if !reflect.DeepEqual(found, expected) {
+			t.Fatal("tag mismatch:", expected, "!=", found)
+		}
+	}
+
+	ts.OKAll(svc.PutObject(&s3.PutObjectInput{
+		Bucket:  aws.String(defaultBucket),
+		Key:     aws.String("object"),
+		Body:    bytes.NewReader([]byte("hello")),
+		Tagging: aws.String("a=1&b=two%20words"),
+	}))
+	assertTags("object", map[string]string{"a": "1", "b": "two words"})
+
+	ts.OKAll(svc.PutObjectTagging(&s3.PutObjectTaggingInput{
+		Bucket: aws.String(defaultBucket),
+		Key:    aws.String("object"),
+		Tagging: &s3.Tagging{TagSet: []*s3.Tag{
+			{Key: aws.String("c"), Value: aws.String("3")},
+		}},
+	}))
+	assertTags("object", map[string]string{"c": "3"})
+	ts.assertObject(defaultBucket, "object", nil, "hello")
+
+	ts.OKAll(svc.DeleteObjectTagging(&s3.DeleteObjectTaggingInput{
+		Bucket: aws.String(defaultBucket),
+		Key:    aws.String("object"),
+	}))
+	assertTags("object", map[string]string{})
+
+	t.Run("invalid-header", func(t *testing.T) {
+		_, err := svc.PutObject(&s3.PutObjectInput{
+			Bucket:  aws.String(defaultBucket),
+			Key:     aws.String("invalid"),
+			Body:    bytes.NewReader([]byte("hello")),
+			Tagging: aws.String("a=1&a=2"),
+		})
+		if !hasErrorCode(err, gofakes3.ErrInvalidTag) {
+			t.Fatal("expected ErrInvalidTag, found", err)
+		}
+	})
+
+	t.Run("too-many", func(t *testing.T) {
+		var tags []*s3.Tag
+		for i := 0; i <= gofakes3.MaxObjectTags; i++ {
+			tags = append(tags, &s3.Tag{Key: aws.String(string(rune('a' + i))), Value: aws.String("v")})
+		}
+		_, err := svc.PutObjectTagging(&s3.PutObjectTaggingInput{
+			Bucket:  aws.String(defaultBucket),
+			Key:     aws.String("object"),
+			Tagging: &s3.Tagging{TagSet: tags},
+		})
+		if !hasErrorCode(err, gofakes3.ErrInvalidTag) {
+			t.Fatal("expected ErrInvalidTag, found", err)
+		}
+	})
+}
+
+func TestObjectTaggingCount(t *testing.T) {
+	ts := newTestServer(t)
+	defer ts.Close()
+	svc := ts.s3Client()
+
+	assertCount := func(expected *int64) {
+		t.Helper()
+		rs, err := svc.GetObject(&s3.GetObjectInput{
+			Bucket: aws.String(defaultBucket),
+			Key:    aws.String("object"),
+		})
+		ts.OK(err)
+		rs.Body.Close()
+		if !reflect.DeepEqual(rs.TagCount, expected) {
+			t.Fatal("unexpected tag count", aws.Int64Value(rs.TagCount))
+		}
+	}
+
+	ts.OKAll(svc.PutObject(&s3.PutObjectInput{
+		Bucket:  aws.String(defaultBucket),
+		Key:     aws.String("object"),
+		Body:    bytes.NewReader([]byte("hello")),
+		Tagging: aws.String("a=1&b=2"),
+	}))
+	assertCount(aws.Int64(2))
+
+	// The count is returned by HEAD too, but the tags themselves are not:
+	rs, _ := ts.sendRaw("HEAD", defaultBucket+"/object", nil, nil)
+	if rs.Header.Get(gofakes3.TaggingCountHeader) != "2" || rs.Header.Get(gofakes3.TaggingHeader) != "" {
+		t.Fatal("unexpected headers", rs.Header)
+	}
+
+	ts.OKAll(svc.DeleteObjectTagging(&s3.DeleteObjectTaggingInput{
+		Bucket: aws.String(defaultBucket),
+		Key:    aws.String("object"),
+	}))
+	assertCount(nil)
+}
+
+func TestCopyObjectTaggingDirective(t *testing.T) {
+	ts := newTestServer(t)
+	defer ts.Close()
+	svc := ts.s3Client()
+
+	assertTags := func(key string, expected map[string]string) {
+		t.Helper()
+		rs, err := svc.GetObjectTagging(&s3.GetObjectTaggingInput{
+			Bucket: aws.String(defaultBucket),
+			Key:    aws.String(key),
+		})
+		ts.OK(err)
+		found := map[string]string{}
+		for _, tag := range rs.TagSet {
+			found[aws.StringValue(tag.Key)] = aws.StringValue(tag.Value)
+		}
+		if !reflect.DeepEqual(found, expected) {
+			t.Fatal("tag mismatch:", expected, "!=", found)
+		}
+	}
+
+	ts.OKAll(svc.PutObject(&s3.PutObjectInput{
+		Bucket:  aws.String(defaultBucket),
+		Key:     aws.String("src"),
+		Body:    bytes.NewReader([]byte("hello")),
+		Tagging: aws.String("created=yes"),
+	}))
+	// Tags set after creation must be copied too:
+	ts.OKAll(svc.PutObjectTagging(&s3.PutObjectTaggingInput{
+		Bucket: aws.String(defaultBucket),
+		Key:    aws.String("src"),
+		Tagging: &s3.Tagging{TagSet: []*s3.Tag{
+			{Key: aws.String("class"), Value: aws.String("secret")},
+		}},
+	}))
+
+	copyObject := func(dst string, directive, tagging *string) error {
+		_, err := svc.CopyObject(&s3.CopyObjectInput{
+			Bucket:           aws.String(defaultBucket),
+			Key:              aws.String(dst),
+			CopySource:       aws.String(defaultBucket + "/src"),
+			TaggingDirective: directive,
+			Tagging:          tagging,
+		})
+		return err
+	}
+
+	ts.OK(copyObject("default", nil, aws.String("ignored=yes")))
+	assertTags("default", map[string]string{"class": "secret"})
+
+	ts.OK(copyObject("copy", aws.String("COPY"), nil))
+	assertTags("copy", map[string]string{"class": "secret"})
+
+	ts.OK(copyObject("replace", aws.String("REPLACE"), aws.String("new=tag")))
+	assertTags("replace", map[string]string{"new": "tag"})
+
+	ts.OK(copyObject("replace-empty", aws.String("REPLACE"), nil))
+	assertTags("replace-empty", map[string]string{})
+
+	if err := copyObject("bad", aws.String("MERGE"), nil); !hasErrorCode(err, gofakes3.ErrInvalidArgument) {
+		t.Fatal("expected ErrInvalidArgument, found", err)
+	}
+}
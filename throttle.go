@@ -0,0 +1,163 @@
+package gofakes3
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// tokenBucket limits a stream to a number of bytes per second. It starts
+// empty, so that a transfer of n bytes takes roughly n/rate seconds no matter
+// how small it is.
+//
+// A tokenBucket may be shared by concurrent streams, as those limited by
+// WithBucketThroughputLimit are: each wait reserves its bytes before
+// sleeping, so the streams take turns and their total stays within the rate.
+type tokenBucket struct {
+	mu     sync.Mutex
+	rate   int
+	burst  float64 // The most tokens that accumulate while idle.
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(bytesPerSec int) *tokenBucket {
+	return &tokenBucket{rate: bytesPerSec, burst: float64(bytesPerSec), last: time.Now()}
+}
+
+// newSharedTokenBucket returns a tokenBucket for streams that share a limit.
+// It never accumulates tokens, so that a bucket that has been idle for a while
+// is no faster than a busy one.
+func newSharedTokenBucket(bytesPerSec int) *tokenBucket {
+	return &tokenBucket{rate: bytesPerSec, last: time.Now()}
+}
+
+// wait blocks until n bytes may be transferred, or until ctx is done.
+func (tb *tokenBucket) wait(ctx context.Context, n int) error {
+	tb.mu.Lock()
+	now := time.Now()
+	tb.tokens += now.Sub(tb.last).Seconds() * float64(tb.rate)
+	if tb.tokens > tb.burst {
+		tb.tokens = tb.burst
+	}
+	tb.last = now
+
+	tb.tokens -= float64(n)
+	tokens := tb.tokens
+	tb.mu.Unlock()
+	if tokens >= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(time.Duration(-tokens / float64(tb.rate) * float64(time.Second)))
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// chunk caps the size of a single read or write, so that no more than a
+// second's worth of bytes is transferred at once.
+func (tb *tokenBucket) chunk(n int) int {
+	if n > tb.rate {
+		return tb.rate
+	}
+	return n
+}
+
+type throttledReader struct {
+	ctx context.Context
+	r   io.ReadCloser
+	tb  *tokenBucket
+}
+
+func (t *throttledReader) Read(p []byte) (n int, err error) {
+	if len(p) == 0 {
+		return t.r.Read(p)
+	}
+	n, err = t.r.Read(p[:t.tb.chunk(len(p))])
+	if n > 0 {
+		if werr := t.tb.wait(t.ctx, n); werr != nil {
+			return n, werr
+		}
+	}
+	return n, err
+}
+
+func (t *throttledReader) Close() error {
+	return t.r.Close()
+}
+
+type throttledResponseWriter struct {
+	http.ResponseWriter
+	ctx context.Context
+	tb  *tokenBucket
+}
+
+func (t *throttledResponseWriter) Write(p []byte) (n int, err error) {
+	for len(p) > 0 {
+		sz := t.tb.chunk(len(p))
+		if err := t.tb.wait(t.ctx, sz); err != nil {
+			return n, err
+		}
+		written, err := t.ResponseWriter.Write(p[:sz])
+		n += written
+		if err != nil {
+			return n, err
+		}
+		p = p[sz:]
+	}
+	return n, nil
+}
+
+// Flush sends any buffered data to the client straight away; flushing is not
+// throttled.
+func (t *throttledResponseWriter) Flush() {
+	if f, ok := t.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// bandwidthMiddleware throttles request and response bodies to the limits
+// set by WithUploadBandwidthLimit and WithDownloadBandwidthLimit. Each request
+// is throttled independently.
+func (g *GoFakeS3) bandwidthMiddleware(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, rq *http.Request) {
+		ctx := rq.Context()
+		if g.uploadBandwidth > 0 && rq.Body != nil {
+			rq.Body = &throttledReader{ctx: ctx, r: rq.Body, tb: newTokenBucket(g.uploadBandwidth)}
+		}
+		if g.downloadBandwidth > 0 {
+			w = &throttledResponseWriter{ResponseWriter: w, ctx: ctx, tb: newTokenBucket(g.downloadBandwidth)}
+		}
+		handler.ServeHTTP(w, rq)
+	})
+}
+
+// bucketThroughput holds the limits set by WithBucketThroughputLimit, which
+// are shared by every request for the bucket's objects. Either may be nil.
+type bucketThroughput struct {
+	read, write *tokenBucket
+}
+
+// throttleBucket throttles the bodies of a request for an object to the
+// limits set for its bucket by WithBucketThroughputLimit.
+func (g *GoFakeS3) throttleBucket(bucket, object string, w http.ResponseWriter, r *http.Request) (http.ResponseWriter, *http.Request) {
+	limits, ok := g.bucketThroughput[bucket]
+	if !ok || object == "" {
+		return w, r
+	}
+	ctx := r.Context()
+	if limits.write != nil && r.Body != nil {
+		r.Body = &throttledReader{ctx: ctx, r: r.Body, tb: limits.write}
+	}
+	if limits.read != nil {
+		w = &throttledResponseWriter{ResponseWriter: w, ctx: ctx, tb: limits.read}
+	}
+	return w, r
+}
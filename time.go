@@ -1,6 +1,10 @@
 package gofakes3
 
-import "time"
+import (
+	"context"
+	"sync"
+	"time"
+)
 
 type TimeSource interface {
 	Now() time.Time
@@ -42,17 +46,33 @@ func (l *locatedTimeSource) Since(t time.Time) time.Duration {
 }
 
 type fixedTimeSource struct {
+	mu   sync.Mutex
 	time time.Time
 }
 
+var _ TimeSourceSleeper = &fixedTimeSource{}
+
 func (l *fixedTimeSource) Now() time.Time {
+	l.mu.Lock()
+	defer l.mu.Unlock()
 	return l.time
 }
 
 func (l *fixedTimeSource) Since(t time.Time) time.Duration {
-	return l.time.Sub(t)
+	return l.Now().Sub(t)
 }
 
 func (l *fixedTimeSource) Advance(by time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
 	l.time = l.time.Add(by)
 }
+
+// Sleep advances the time by d instead of waiting for it to pass.
+func (l *fixedTimeSource) Sleep(ctx context.Context, d time.Duration) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	l.Advance(d)
+	return nil
+}
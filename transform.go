@@ -0,0 +1,244 @@
+package gofakes3
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/hex"
+	"io"
+
+	"github.com/johannesboyne/gofakes3/internal/s3io"
+)
+
+// transformedHashMeta is stored with objects written through a
+// transformingBackend, and holds the MD5 hash of the bytes before they were
+// transformed. It is never returned to clients.
+const transformedHashMeta = "X-Gofakes3-Transformed-Md5"
+
+// TransformFunc transforms the contents of an object; see WithStoreTransform
+// and WithLoadTransform. It must not modify its argument.
+type TransformFunc func(data []byte) []byte
+
+// transformingBackend implements WithStoreTransform and WithLoadTransform. It
+// is a Backend that passes the contents of every object written through it to
+// store, and those of every object it wrote to load when they are read back.
+//
+// Objects it did not write, such as those put straight into the Backend, are
+// returned as they are. As the transformed size may differ, ranges are taken
+// from the loaded bytes rather than by the Backend.
+type transformingBackend struct {
+	Backend
+	store, load TransformFunc
+}
+
+var (
+	_ Backend                 = &transformingBackend{}
+	_ VersionedBackend        = &transformingBackend{}
+	_ FlushingBackend         = &transformingBackend{}
+	_ MetadataUpdatingBackend = &transformingBackend{}
+	_ RangeReportingBackend   = &transformingBackend{}
+)
+
+func newTransformingBackend(backend Backend, store, load TransformFunc) *transformingBackend {
+	identity := func(data []byte) []byte { return data }
+	if store == nil {
+		store = identity
+	}
+	if load == nil {
+		load = identity
+	}
+	return &transformingBackend{Backend: backend, store: store, load: load}
+}
+
+// PutObject stores the transformed contents, along with the hash of the
+// untransformed ones, which is returned as the object's ETag so that
+// conditional requests still work.
+func (b *transformingBackend) PutObject(bucketName, key string, meta map[string]string, input io.Reader, size int64) (PutObjectResult, error) {
+	data, err := ReadAll(input, size)
+	if err != nil {
+		return PutObjectResult{}, err
+	}
+	hash := md5.Sum(data)
+	stored := b.store(data)
+
+	withHash := make(map[string]string, len(meta)+1)
+	for k, v := range meta {
+		withHash[k] = v
+	}
+	withHash[transformedHashMeta] = hex.EncodeToString(hash[:])
+	return b.Backend.PutObject(bucketName, key, withHash, bytes.NewReader(stored), int64(len(stored)))
+}
+
+func (b *transformingBackend) GetObject(bucketName, objectName string, rangeRequest *ObjectRangeRequest) (*Object, error) {
+	obj, err := b.Backend.GetObject(bucketName, objectName, nil)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := obj.Metadata[transformedHashMeta]; !ok && rangeRequest != nil {
+		obj.Contents.Close()
+		return b.Backend.GetObject(bucketName, objectName, rangeRequest)
+	}
+	return b.loadObject(obj, rangeRequest)
+}
+
+func (b *transformingBackend) HeadObject(bucketName, objectName string) (*Object, error) {
+	obj, err := b.Backend.HeadObject(bucketName, objectName)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := obj.Metadata[transformedHashMeta]; !ok {
+		return obj, nil
+	}
+	// The size of the loaded contents is only known once they are loaded:
+	return b.headObject(b.Backend.GetObject(bucketName, objectName, nil))
+}
+
+func (b *transformingBackend) GetObjectVersion(bucketName, objectName string, versionID VersionID, rangeRequest *ObjectRangeRequest) (*Object, error) {
+	versioned, ok := b.Backend.(VersionedBackend)
+	if !ok {
+		return nil, ErrNotImplemented
+	}
+	obj, err := versioned.GetObjectVersion(bucketName, objectName, versionID, nil)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := obj.Metadata[transformedHashMeta]; !ok && rangeRequest != nil {
+		obj.Contents.Close()
+		return versioned.GetObjectVersion(bucketName, objectName, versionID, rangeRequest)
+	}
+	return b.loadObject(obj, rangeRequest)
+}
+
+func (b *transformingBackend) HeadObjectVersion(bucketName, objectName string, versionID VersionID) (*Object, error) {
+	versioned, ok := b.Backend.(VersionedBackend)
+	if !ok {
+		return nil, ErrNotImplemented
+	}
+	obj, err := versioned.HeadObjectVersion(bucketName, objectName, versionID)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := obj.Metadata[transformedHashMeta]; !ok {
+		return obj, nil
+	}
+	return b.headObject(versioned.GetObjectVersion(bucketName, objectName, versionID, nil))
+}
+
+// loadObject replaces the contents of an object written by PutObject with
+// the loaded ones, and applies rangeRequest to them.
+func (b *transformingBackend) loadObject(obj *Object, rangeRequest *ObjectRangeRequest) (*Object, error) {
+	hash, ok := obj.Metadata[transformedHashMeta]
+	if !ok {
+		return obj, nil
+	}
+	defer obj.Contents.Close()
+
+	stored, err := ReadAll(obj.Contents, obj.Size)
+	if err != nil {
+		return nil, err
+	}
+	loaded := b.load(stored)
+
+	rnge, err := rangeRequest.Range(int64(len(loaded)))
+	if err != nil {
+		return nil, err
+	}
+	contents := loaded
+	if rnge != nil {
+		contents = loaded[rnge.Start : rnge.Start+rnge.Length]
+	}
+
+	meta := make(map[string]string, len(obj.Metadata))
+	for k, v := range obj.Metadata {
+		if k != transformedHashMeta {
+			meta[k] = v
+		}
+	}
+	out := *obj
+	out.Metadata = meta
+	out.Size = int64(len(loaded))
+	out.Contents = s3io.ReaderWithDummyCloser{Reader: bytes.NewReader(contents)}
+	out.Hash, _ = hex.DecodeString(hash)
+	out.Range = rnge
+	return &out, nil
+}
+
+func (b *transformingBackend) headObject(obj *Object, err error) (*Object, error) {
+	if err != nil {
+		return nil, err
+	}
+	if obj, err = b.loadObject(obj, nil); err != nil {
+		return nil, err
+	}
+	obj.Contents = s3io.NoOpReadCloser{}
+	return obj, nil
+}
+
+// ListBucket pages the listing itself if the Backend is an IteratingBackend
+// that can't, as GoFakeS3 would. The sizes and ETags listed are those of the
+// stored, transformed, contents.
+func (b *transformingBackend) ListBucket(name string, prefix *Prefix, page ListBucketPage) (*ObjectList, error) {
+	objects, err := b.Backend.ListBucket(name, prefix, page)
+	if ib, ok := b.Backend.(IteratingBackend); ok && err == ErrInternalPageNotImplemented {
+		return listBucketIter(ib, name, prefix, page)
+	}
+	return objects, err
+}
+
+func (b *transformingBackend) VersioningConfiguration(bucket string) (VersioningConfiguration, error) {
+	versioned, ok := b.Backend.(VersionedBackend)
+	if !ok {
+		return VersioningConfiguration{}, ErrNotImplemented
+	}
+	return versioned.VersioningConfiguration(bucket)
+}
+
+func (b *transformingBackend) SetVersioningConfiguration(bucket string, v VersioningConfiguration) error {
+	versioned, ok := b.Backend.(VersionedBackend)
+	if !ok {
+		return ErrNotImplemented
+	}
+	return versioned.SetVersioningConfiguration(bucket, v)
+}
+
+func (b *transformingBackend) DeleteObjectVersion(bucketName, objectName string, versionID VersionID) (ObjectDeleteResult, error) {
+	versioned, ok := b.Backend.(VersionedBackend)
+	if !ok {
+		return ObjectDeleteResult{}, ErrNotImplemented
+	}
+	return versioned.DeleteObjectVersion(bucketName, objectName, versionID)
+}
+
+func (b *transformingBackend) DeleteMultiVersions(bucketName string, objects ...ObjectID) (MultiDeleteResult, error) {
+	versioned, ok := b.Backend.(VersionedBackend)
+	if !ok {
+		return MultiDeleteResult{}, ErrNotImplemented
+	}
+	return versioned.DeleteMultiVersions(bucketName, objects...)
+}
+
+func (b *transformingBackend) ListBucketVersions(bucketName string, prefix *Prefix, page *ListBucketVersionsPage) (*ListBucketVersionsResult, error) {
+	versioned, ok := b.Backend.(VersionedBackend)
+	if !ok {
+		return nil, ErrNotImplemented
+	}
+	return versioned.ListBucketVersions(bucketName, prefix, page)
+}
+
+func (b *transformingBackend) Flush() error {
+	if flusher, ok := b.Backend.(FlushingBackend); ok {
+		return flusher.Flush()
+	}
+	return nil
+}
+
+// UpdateObjectMetadata always fails with ErrNotImplemented, so that GoFakeS3
+// writes the object again through PutObject rather than losing the hash of
+// its untransformed contents.
+func (b *transformingBackend) UpdateObjectMetadata(bucketName, objectName string, meta map[string]string) error {
+	return ErrNotImplemented
+}
+
+func (b *transformingBackend) SupportsRanges() bool {
+	rb, ok := b.Backend.(RangeReportingBackend)
+	return !ok || rb.SupportsRanges()
+}
@@ -0,0 +1,26 @@
+package gofakes3
+
+import (
+	"path"
+	"time"
+)
+
+// truncateRule is added by WithTruncatedBodies and WithStalledBodies.
+type truncateRule struct {
+	pattern  string
+	fraction float64
+	stall    time.Duration
+}
+
+// truncatedLength returns how many bytes of a GET object response body of
+// the given length to send, and how long to stall for after sending them, if
+// the key matches a truncateRule. Rules are checked in the order they were
+// added.
+func (g *GoFakeS3) truncatedLength(object string, length int64) (truncated int64, stall time.Duration, ok bool) {
+	for _, rule := range g.truncateRules {
+		if matched, _ := path.Match(rule.pattern, object); matched {
+			return int64(float64(length) * rule.fraction), rule.stall, true
+		}
+	}
+	return length, 0, false
+}
@@ -0,0 +1,121 @@
+package gofakes3
+
+import (
+	"strconv"
+	"time"
+)
+
+const (
+	// TTLHeader is a non-standard header that can be sent when creating an
+	// object to have it expire after a period of time. The value is either a
+	// number of seconds or a Go duration string, like '90s' or '1h'.
+	//
+	// As it uses the 'x-amz-meta-' prefix, S3 clients treat it as ordinary
+	// user metadata, so it can be passed through any SDK.
+	TTLHeader = "x-amz-meta-gofakes3-ttl"
+
+	// TTLExpiresHeader is the metadata key GoFakeS3 stores the computed
+	// expiry time under, in RFC3339 format. It is returned with the object's
+	// other metadata.
+	TTLExpiresHeader = "x-amz-meta-gofakes3-expires"
+)
+
+// applyObjectTTL converts a TTLHeader found in meta into an absolute expiry
+// time, relative to the configured TimeSource.
+func (g *GoFakeS3) applyObjectTTL(meta map[string]string) error {
+	ttlStr, ok := meta[TTLHeader]
+	if !ok {
+		return nil
+	}
+
+	var ttl time.Duration
+	if secs, err := strconv.ParseInt(ttlStr, 10, 64); err == nil {
+		ttl = time.Duration(secs) * time.Second
+	} else if ttl, err = time.ParseDuration(ttlStr); err != nil {
+		return ErrorInvalidArgument(TTLHeader, ttlStr, "TTL must be a number of seconds or a duration")
+	}
+	if ttl <= 0 {
+		return ErrorInvalidArgument(TTLHeader, ttlStr, "TTL must be greater than zero")
+	}
+
+	meta[TTLExpiresHeader] = g.timeSource.Now().Add(ttl).UTC().Format(time.RFC3339Nano)
+	return nil
+}
+
+// objectExpired reports whether the object has a TTL that has lapsed. Objects
+// are hidden as soon as they expire, even if the sweeper has not yet deleted
+// them.
+func (g *GoFakeS3) objectExpired(obj *Object) bool {
+	expiresStr, ok := obj.Metadata[TTLExpiresHeader]
+	if !ok {
+		return false
+	}
+	expires, err := time.Parse(time.RFC3339Nano, expiresStr)
+	if err != nil {
+		g.log.Print(LogWarn, "invalid object expiry", obj.Name, expiresStr)
+		return false
+	}
+	return !g.timeSource.Now().Before(expires)
+}
+
+// SweepExpiredObjects deletes every object whose TTL has lapsed, according to
+// the configured TimeSource. It is called periodically if
+// WithTTLSweepInterval is used, but can also be called directly to make
+// tests deterministic.
+func (g *GoFakeS3) SweepExpiredObjects() error {
+	buckets, err := g.storage.ListBuckets()
+	if err != nil {
+		return err
+	}
+
+	for _, bucket := range buckets {
+		objects, err := g.storage.ListBucket(bucket.Name, nil, ListBucketPage{})
+		if err != nil {
+			return err
+		}
+
+		for _, item := range objects.Contents {
+			obj, err := g.storage.HeadObject(bucket.Name, item.Key)
+			if HasErrorCode(err, ErrNoSuchKey) {
+				continue
+			} else if err != nil {
+				return err
+			}
+			obj.Contents.Close()
+
+			if g.objectExpired(obj) {
+				g.log.Print(LogInfo, "TTL EXPIRED:", bucket.Name, item.Key)
+				if _, err := g.storage.DeleteObject(bucket.Name, item.Key); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// runSweeper calls sweep at the given interval until Close is called. what
+// names the sweeper in log messages.
+func (g *GoFakeS3) runSweeper(interval time.Duration, what string, sweep func() error) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := sweep(); err != nil {
+				g.log.Print(LogErr, what, "sweep failed:", err)
+			}
+		case <-g.stop:
+			return
+		}
+	}
+}
+
+// Close stops any background work started by GoFakeS3, such as the TTL and
+// lifecycle sweepers. It does not stop the server returned by Server().
+func (g *GoFakeS3) Close() error {
+	g.stopOnce.Do(func() { close(g.stop) })
+	return nil
+}
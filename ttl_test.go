@@ -0,0 +1,71 @@
+package gofakes3_test
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/johannesboyne/gofakes3"
+)
+
+func TestObjectTTL(t *testing.T) {
+	ts := newTestServer(t)
+	defer ts.Close()
+	svc := ts.s3Client()
+
+	ts.OKAll(svc.PutObject(&s3.PutObjectInput{
+		Bucket:   aws.String(defaultBucket),
+		Key:      aws.String("expiring"),
+		Body:     bytes.NewReader([]byte("hello")),
+		Metadata: map[string]*string{"Gofakes3-Ttl": aws.String("60")},
+	}))
+	ts.backendPutString(defaultBucket, "forever", nil, "hello")
+
+	get := func(key string) error {
+		_, err := svc.GetObject(&s3.GetObjectInput{
+			Bucket: aws.String(defaultBucket),
+			Key:    aws.String(key),
+		})
+		return err
+	}
+
+	ts.OK(get("expiring"))
+
+	ts.Advance(59 * time.Second)
+	ts.OK(get("expiring"))
+	ts.OK(ts.SweepExpiredObjects())
+	if !ts.backendObjectExists(defaultBucket, "expiring") {
+		t.Fatal("object swept before expiry")
+	}
+
+	ts.Advance(1 * time.Second)
+	if err := get("expiring"); !hasErrorCode(err, gofakes3.ErrNoSuchKey) {
+		t.Fatal("expected NoSuchKey, found", err)
+	}
+
+	ts.OK(ts.SweepExpiredObjects())
+	if ts.backendObjectExists(defaultBucket, "expiring") {
+		t.Fatal("expired object was not swept")
+	}
+	if !ts.backendObjectExists(defaultBucket, "forever") {
+		t.Fatal("object without TTL was swept")
+	}
+}
+
+func TestObjectTTLInvalid(t *testing.T) {
+	ts := newTestServer(t)
+	defer ts.Close()
+	svc := ts.s3Client()
+
+	_, err := svc.PutObject(&s3.PutObjectInput{
+		Bucket:   aws.String(defaultBucket),
+		Key:      aws.String("expiring"),
+		Body:     bytes.NewReader([]byte("hello")),
+		Metadata: map[string]*string{"Gofakes3-Ttl": aws.String("soon")},
+	})
+	if !hasErrorCode(err, gofakes3.ErrInvalidArgument) {
+		t.Fatal("expected InvalidArgument, found", err)
+	}
+}
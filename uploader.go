@@ -128,6 +128,10 @@ func (bu *bucketUploads) remove(uploadID UploadID) {
 	}
 }
 
+// UploadIDGenerator returns the ID of a new multipart upload; see
+// WithUploadIDGenerator.
+type UploadIDGenerator func() UploadID
+
 // uploader manages multipart uploads.
 //
 // Multipart upload support has the following rather severe limitations (which
@@ -154,6 +158,10 @@ type uploader struct {
 	// expected to ever generate 4.2 billion of these but who are we to judge?)
 	uploadID *big.Int
 
+	// generateID, if set by WithUploadIDGenerator, is used in place of
+	// uploadID.
+	generateID UploadIDGenerator
+
 	buckets map[string]*bucketUploads
 	mu      sync.Mutex
 }
@@ -165,14 +173,25 @@ func newUploader() *uploader {
 	}
 }
 
-func (u *uploader) Begin(bucket, object string, meta map[string]string, initiated time.Time) *multipartUpload {
+func (u *uploader) Begin(bucket, object string, meta map[string]string, initiated time.Time) (*multipartUpload, error) {
 	u.mu.Lock()
 	defer u.mu.Unlock()
 
-	u.uploadID.Add(u.uploadID, add1)
+	var id UploadID
+	if u.generateID != nil {
+		id = u.generateID()
+		for _, bucketUploads := range u.buckets {
+			if _, ok := bucketUploads.uploads[id]; ok {
+				return nil, fmt.Errorf("gofakes3: upload ID %q from the UploadIDGenerator is already in use", id)
+			}
+		}
+	} else {
+		u.uploadID.Add(u.uploadID, add1)
+		id = UploadID(u.uploadID.String())
+	}
 
 	mpu := &multipartUpload{
-		ID:        UploadID(u.uploadID.String()),
+		ID:        id,
 		Bucket:    bucket,
 		Object:    object,
 		Meta:      meta,
@@ -188,7 +207,7 @@ func (u *uploader) Begin(bucket, object string, meta map[string]string, initiate
 
 	bucketUploads.add(mpu)
 
-	return mpu
+	return mpu, nil
 }
 
 func (u *uploader) ListParts(bucket, object string, uploadID UploadID, marker int, limit int64) (*ListMultipartUploadPartsResult, error) {
@@ -209,15 +228,22 @@ func (u *uploader) ListParts(bucket, object string, uploadID UploadID, marker in
 		StorageClass:     "STANDARD", // FIXME
 	}
 
+	mpu.mu.Lock()
+	defer mpu.mu.Unlock()
+
+	// mpu.parts is indexed by part number, so the marker (the last part number
+	// the client has already seen) is used directly as the starting offset.
+	// NextPartNumberMarker is the last part number returned in this page, as
+	// S3 does.
 	var cnt int64
-	for partNumber, part := range mpu.parts[marker:] {
+	for partNumber := marker + 1; partNumber < len(mpu.parts); partNumber++ {
+		part := mpu.parts[partNumber]
 		if part == nil {
 			continue
 		}
 
 		if cnt >= limit {
 			result.IsTruncated = true
-			result.NextPartNumberMarker = partNumber
 			break
 		}
 
@@ -227,6 +253,7 @@ func (u *uploader) ListParts(bucket, object string, uploadID UploadID, marker in
 			PartNumber:   partNumber,
 			LastModified: part.LastModified,
 		})
+		result.NextPartNumberMarker = partNumber
 
 		cnt++
 	}
@@ -296,9 +323,16 @@ func (u *uploader) List(bucket string, marker *UploadListMarker, prefix Prefix,
 
 		} else {
 			if match.CommonPrefix {
+				// Like S3, each common prefix counts towards the limit, as
+				// an upload does:
 				if !seenPrefixes[match.MatchedPart] {
 					result.CommonPrefixes = append(result.CommonPrefixes, match.AsCommonPrefix())
 					seenPrefixes[match.MatchedPart] = true
+
+					cnt++
+					if cnt >= limit {
+						goto done
+					}
 				}
 
 			} else {
@@ -330,7 +364,22 @@ done:
 	if !truncated {
 		for iter.Next() {
 			object := iter.Key().(string)
-			if matched := prefix.Match(object, &match); matched && !match.CommonPrefix {
+			if matched := prefix.Match(object, &match); !matched {
+				continue
+
+			} else if match.CommonPrefix {
+				// Keys under a common prefix that was returned in this page
+				// don't need another one:
+				if !seenPrefixes[match.MatchedPart] {
+					truncated = true
+
+					// Seeking to the prefix starts the next page with the
+					// first key under it:
+					result.NextKeyMarker = match.MatchedPart
+					break
+				}
+
+			} else {
 				truncated = true
 
 				// This is not especially defensive; it assumes the rest of the code works
@@ -361,6 +410,21 @@ func (u *uploader) Complete(bucket, object string, id UploadID) (*multipartUploa
 	return up, nil
 }
 
+// Abort removes an upload and releases its parts straight away, rather than
+// once the last request using the upload has finished with it.
+func (u *uploader) Abort(bucket, object string, id UploadID) error {
+	up, err := u.Complete(bucket, object, id)
+	if err != nil {
+		return err
+	}
+
+	up.mu.Lock()
+	defer up.mu.Unlock()
+	up.parts = nil
+	up.aborted = true
+	return nil
+}
+
 func (u *uploader) Get(bucket, object string, id UploadID) (mu *multipartUpload, err error) {
 	u.mu.Lock()
 	defer u.mu.Unlock()
@@ -447,6 +511,10 @@ type multipartUpload struct {
 	// Do not attempt to access parts without locking mu.
 	parts []*multipartUploadPart
 
+	// aborted is set, under mu, once the upload has been aborted and its
+	// parts released; see uploader.Abort.
+	aborted bool
+
 	mu sync.Mutex
 }
 
@@ -458,6 +526,11 @@ func (mpu *multipartUpload) AddPart(partNumber int, at time.Time, body []byte) (
 	mpu.mu.Lock()
 	defer mpu.mu.Unlock()
 
+	// A part upload that raced with an abort must not bring the parts back:
+	if mpu.aborted {
+		return "", ErrNoSuchUpload
+	}
+
 	// What the ETag actually is is not specified, so let's just invent any old thing
 	// from guaranteed unique input:
 	hash := md5.New()
@@ -481,6 +554,10 @@ func (mpu *multipartUpload) Reassemble(input *CompleteMultipartUploadRequest) (b
 	mpu.mu.Lock()
 	defer mpu.mu.Unlock()
 
+	if mpu.aborted {
+		return nil, "", ErrNoSuchUpload
+	}
+
 	mpuPartsLen := len(mpu.parts)
 
 	// FIXME: what does AWS do when mpu.Parts > input.Parts? Presumably you may
@@ -525,20 +602,24 @@ type multipartBackend struct {
 }
 
 func (b *multipartBackend) AbortMultipartUpload(bucketName, key string, id UploadID) error {
-	_, err := b.uploader.Complete(bucketName, key, id)
-	return err
+	return b.uploader.Abort(bucketName, key, id)
 }
 
 func (b *multipartBackend) CompleteMultipartUpload(bucketName, key string, id UploadID, req *CompleteMultipartUploadRequest) (*PutObjectResult, string, error) {
-	upload, err := b.uploader.Complete(bucketName, key, id)
+	upload, err := b.uploader.Get(bucketName, key, id)
 	if err != nil {
 		return nil, "", err
 	}
 
+	// Like S3, an invalid request leaves the upload in place so that it can
+	// be retried; only a successful completion or an abort removes it:
 	fileBody, etag, err := upload.Reassemble(req)
 	if err != nil {
 		return nil, "", err
 	}
+	if _, err := b.uploader.Complete(bucketName, key, id); err != nil {
+		return nil, "", err
+	}
 
 	result, err := b.storage.PutObject(bucketName, key, upload.Meta, bytes.NewReader(fileBody), int64(len(fileBody)))
 	if err != nil {
@@ -548,10 +629,28 @@ func (b *multipartBackend) CompleteMultipartUpload(bucketName, key string, id Up
 }
 
 func (b *multipartBackend) CreateMultipartUpload(bucketName, key string, meta map[string]string, initiated time.Time) (UploadID, error) {
-	upload := b.uploader.Begin(bucketName, key, meta, initiated)
+	upload, err := b.uploader.Begin(bucketName, key, meta, initiated)
+	if err != nil {
+		return "", err
+	}
 	return upload.ID, nil
 }
 
+// uploaders returns the uploaders GoFakeS3 keeps uploads in, for each
+// Backend that is not a MultipartBackend.
+func uploaders(multipart MultipartBackend) (all []*uploader) {
+	switch mb := multipart.(type) {
+	case *multipartBackend:
+		all = append(all, mb.uploader)
+	case *bucketRouter:
+		all = append(all, uploaders(mb.fallbackMultipart)...)
+		for _, bucketMultipart := range mb.multipart {
+			all = append(all, uploaders(bucketMultipart)...)
+		}
+	}
+	return all
+}
+
 func (b *multipartBackend) ListParts(bucketName, key string, id UploadID, marker int, limit int64) (*ListMultipartUploadPartsResult, error) {
 	return b.uploader.ListParts(bucketName, key, id, marker, limit)
 }
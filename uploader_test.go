@@ -1,10 +1,21 @@
 package gofakes3_test
 
 import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"reflect"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/s3"
 	"github.com/johannesboyne/gofakes3"
+	"github.com/johannesboyne/gofakes3/backend/s3mem"
 )
 
 func TestMultipartUpload(t *testing.T) {
@@ -41,6 +52,220 @@ func TestMultipartUpload(t *testing.T) {
 	}
 }
 
+func TestMultipartUploadEmptyPart(t *testing.T) {
+	ts := newTestServer(t)
+	defer ts.Close()
+	svc := ts.s3Client()
+
+	// The only part of an upload is also the last, so it is exempt from the
+	// minimum part size, even if it is empty. The object still gets the
+	// metadata given at initiation:
+	uploadID := ts.createMultipartUpload(defaultBucket, "empty", map[string]string{"Kind": "placeholder"})
+	part := ts.uploadPart(defaultBucket, "empty", uploadID, 1, []byte{})
+	rs, err := svc.CompleteMultipartUpload(&s3.CompleteMultipartUploadInput{
+		Bucket:          aws.String(defaultBucket),
+		Key:             aws.String("empty"),
+		UploadId:        aws.String(uploadID),
+		MultipartUpload: &s3.CompletedMultipartUpload{Parts: []*s3.CompletedPart{part}},
+	})
+	ts.OK(err)
+
+	const emptyETag = `"d41d8cd98f00b204e9800998ecf8427e"`
+	if aws.StringValue(rs.ETag) != emptyETag {
+		t.Fatal("unexpected etag", aws.StringValue(rs.ETag))
+	}
+	ts.assertObject(defaultBucket, "empty", nil, "")
+
+	head, err := svc.HeadObject(&s3.HeadObjectInput{Bucket: aws.String(defaultBucket), Key: aws.String("empty")})
+	ts.OK(err)
+	if aws.StringValue(head.ETag) != emptyETag || aws.Int64Value(head.ContentLength) != 0 {
+		t.Fatal("unexpected object", aws.StringValue(head.ETag), aws.Int64Value(head.ContentLength))
+	}
+	if v := aws.StringValue(head.Metadata["Kind"]); v != "placeholder" {
+		t.Fatal("unexpected metadata", head.Metadata)
+	}
+}
+
+func TestMultipartUploadContentLength(t *testing.T) {
+	ts := newTestServer(t, withFakerOptions(gofakes3.WithMinPartSize(0)))
+	defer ts.Close()
+	svc := ts.s3Client()
+	ts.backendPutString(defaultBucket, "source", nil, "0123456789")
+
+	uploadID := ts.createMultipartUpload(defaultBucket, "object", nil)
+	parts := []*s3.CompletedPart{
+		ts.uploadPart(defaultBucket, "object", uploadID, 1, []byte("hello, ")),
+		nil,
+		ts.uploadPart(defaultBucket, "object", uploadID, 3, []byte("!")),
+	}
+
+	// The second part is copied from a range of another object:
+	copied, err := svc.UploadPartCopy(&s3.UploadPartCopyInput{
+		Bucket:          aws.String(defaultBucket),
+		Key:             aws.String("object"),
+		UploadId:        aws.String(uploadID),
+		PartNumber:      aws.Int64(2),
+		CopySource:      aws.String(defaultBucket + "/source"),
+		CopySourceRange: aws.String("bytes=2-5"),
+	})
+	ts.OK(err)
+	if expected := `"` + hashMD5Bytes([]byte("2345")).Hex() + `"`; aws.StringValue(copied.CopyPartResult.ETag) != expected {
+		t.Fatal("unexpected part etag", aws.StringValue(copied.CopyPartResult.ETag), "expected", expected)
+	}
+	parts[1] = &s3.CompletedPart{ETag: copied.CopyPartResult.ETag, PartNumber: aws.Int64(2)}
+
+	ts.assertCompleteUpload(defaultBucket, "object", uploadID, parts, "hello, 2345!")
+
+	head, err := svc.HeadObject(&s3.HeadObjectInput{Bucket: aws.String(defaultBucket), Key: aws.String("object")})
+	ts.OK(err)
+	if aws.Int64Value(head.ContentLength) != 12 {
+		t.Fatal("unexpected HEAD content length", aws.Int64Value(head.ContentLength))
+	}
+	obj, err := svc.GetObject(&s3.GetObjectInput{Bucket: aws.String(defaultBucket), Key: aws.String("object")})
+	ts.OK(err)
+	defer obj.Body.Close()
+	if aws.Int64Value(obj.ContentLength) != 12 {
+		t.Fatal("unexpected GET content length", aws.Int64Value(obj.ContentLength))
+	}
+}
+
+func TestMultipartUploadConcurrentParts(t *testing.T) {
+	ts := newTestServer(t, withFakerOptions(gofakes3.WithMinPartSize(0)))
+	defer ts.Close()
+	svc := ts.s3Client()
+
+	const partCount = 50
+	uploadID := ts.createMultipartUpload(defaultBucket, "object", nil)
+
+	var expected bytes.Buffer
+	bodies := make([][]byte, partCount)
+	for i := range bodies {
+		bodies[i] = []byte(fmt.Sprintf("part %d;", i+1))
+		expected.Write(bodies[i])
+	}
+
+	parts := make([]*s3.CompletedPart, partCount)
+	errs := make(chan error, partCount)
+	var wg sync.WaitGroup
+	for i := range bodies {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			rs, err := svc.UploadPart(&s3.UploadPartInput{
+				Bucket:     aws.String(defaultBucket),
+				Key:        aws.String("object"),
+				Body:       bytes.NewReader(bodies[i]),
+				UploadId:   aws.String(uploadID),
+				PartNumber: aws.Int64(int64(i + 1)),
+			})
+			if err != nil {
+				errs <- err
+				return
+			}
+			parts[i] = &s3.CompletedPart{ETag: rs.ETag, PartNumber: aws.Int64(int64(i + 1))}
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		ts.OK(err)
+	}
+
+	listed, err := svc.ListParts(&s3.ListPartsInput{
+		Bucket:   aws.String(defaultBucket),
+		Key:      aws.String("object"),
+		UploadId: aws.String(uploadID),
+	})
+	ts.OK(err)
+	if len(listed.Parts) != partCount {
+		t.Fatal("expected", partCount, "parts, found", len(listed.Parts))
+	}
+
+	ts.assertCompleteUpload(defaultBucket, "object", uploadID, parts, expected.Bytes())
+
+	// A part written after completion does not succeed silently:
+	_, err = svc.UploadPart(&s3.UploadPartInput{
+		Bucket:     aws.String(defaultBucket),
+		Key:        aws.String("object"),
+		Body:       bytes.NewReader([]byte("late")),
+		UploadId:   aws.String(uploadID),
+		PartNumber: aws.Int64(partCount + 1),
+	})
+	if !hasErrorCode(err, gofakes3.ErrNoSuchUpload) {
+		t.Fatal("expected NoSuchUpload, found", err)
+	}
+}
+
+func TestMultipartUploadInitiationMetadata(t *testing.T) {
+	ts := newTestServer(t)
+	defer ts.Close()
+	svc := ts.s3Client()
+
+	expires := time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+	mpu, err := svc.CreateMultipartUpload(&s3.CreateMultipartUploadInput{
+		Bucket:             aws.String(defaultBucket),
+		Key:                aws.String("object"),
+		ContentType:        aws.String("text/csv"),
+		ContentDisposition: aws.String(`attachment; filename="export.csv"`),
+		CacheControl:       aws.String("max-age=3600"),
+		ContentEncoding:    aws.String("identity"),
+		Expires:            aws.Time(expires),
+		Metadata:           aws.StringMap(map[string]string{"Source": "export"}),
+		StorageClass:       aws.String("STANDARD_IA"),
+		Tagging:            aws.String("stage=raw"),
+	})
+	ts.OK(err)
+	uploadID := aws.StringValue(mpu.UploadId)
+
+	// Nothing the part upload or completion sends may replace what was set
+	// at initiation:
+	part := ts.uploadPart(defaultBucket, "object", uploadID, 1, []byte("a,b,c"))
+	ts.assertCompleteUpload(defaultBucket, "object", uploadID, []*s3.CompletedPart{part}, []byte("a,b,c"))
+
+	head, err := svc.HeadObject(&s3.HeadObjectInput{
+		Bucket: aws.String(defaultBucket),
+		Key:    aws.String("object"),
+	})
+	ts.OK(err)
+	if v := aws.StringValue(head.ContentType); v != "text/csv" {
+		t.Fatal("unexpected Content-Type", v)
+	}
+	if v := aws.StringValue(head.Metadata["Source"]); v != "export" {
+		t.Fatal("unexpected metadata", head.Metadata)
+	}
+	if v := aws.StringValue(head.StorageClass); v != "STANDARD_IA" {
+		t.Fatal("unexpected storage class", v)
+	}
+
+	obj, err := svc.GetObject(&s3.GetObjectInput{
+		Bucket: aws.String(defaultBucket),
+		Key:    aws.String("object"),
+	})
+	ts.OK(err)
+	obj.Body.Close()
+	for _, tc := range []struct{ name, found, expected string }{
+		{"Content-Type", aws.StringValue(obj.ContentType), "text/csv"},
+		{"Content-Disposition", aws.StringValue(obj.ContentDisposition), `attachment; filename="export.csv"`},
+		{"Cache-Control", aws.StringValue(obj.CacheControl), "max-age=3600"},
+		{"Content-Encoding", aws.StringValue(obj.ContentEncoding), "identity"},
+		{"Expires", aws.StringValue(obj.Expires), "Wed, 02 Jan 2030 03:04:05 GMT"},
+		{"X-Amz-Meta-Source", aws.StringValue(obj.Metadata["Source"]), "export"},
+	} {
+		if tc.found != tc.expected {
+			t.Fatalf("unexpected %s %q, expected %q", tc.name, tc.found, tc.expected)
+		}
+	}
+
+	tagging, err := svc.GetObjectTagging(&s3.GetObjectTaggingInput{
+		Bucket: aws.String(defaultBucket),
+		Key:    aws.String("object"),
+	})
+	ts.OK(err)
+	if len(tagging.TagSet) != 1 || aws.StringValue(tagging.TagSet[0].Key) != "stage" || aws.StringValue(tagging.TagSet[0].Value) != "raw" {
+		t.Fatal("unexpected tags", tagging.TagSet)
+	}
+}
+
 func TestAbortMultipartUpload(t *testing.T) {
 	ts := newTestServer(t)
 	defer ts.Close()
@@ -50,6 +275,19 @@ func TestAbortMultipartUpload(t *testing.T) {
 		Uploads: strs("obj/1")})
 
 	ts.assertAbortMultipartUpload(defaultBucket, "obj", "1")
+
+	svc := ts.s3Client()
+	_, err := svc.ListParts(&s3.ListPartsInput{
+		Bucket:   aws.String(defaultBucket),
+		Key:      aws.String("obj"),
+		UploadId: aws.String("1"),
+	})
+	if !hasErrorCode(err, gofakes3.ErrNoSuchUpload) {
+		t.Fatal("expected ErrNoSuchUpload, found", err)
+	}
+	if reqErr, ok := err.(interface{ StatusCode() int }); !ok || reqErr.StatusCode() != http.StatusNotFound {
+		t.Fatal("expected 404, found", err)
+	}
 }
 
 func TestListMultipartUploadsWithTheSameObjectKey(t *testing.T) {
@@ -130,18 +368,102 @@ func TestListMultipartUploadsPrefix(t *testing.T) {
 		Prefixes: strs("foo/nested/"),
 		Uploads:  strs("foo/bar/1", "foo/bar/2", "foo/baz/3")})
 
-	// FIXME: This requires further investigation. How does AWS treat the combination
-	// of commonprefixes and a limit?
-	// ts.assertListMultipartUploads(defaultBucket, listUploadsOpts{Prefix: prefixFile("foo/"),
-	//     Limit:    2,
-	//     Prefixes: strs("foo/nested/"),
-	//     Uploads:  strs("foo/bar/1", "foo/bar/2")})
+	// Common prefixes count towards the limit:
+	ts.assertListMultipartUploads(defaultBucket, listUploadsOpts{Prefix: prefixFile("foo/"),
+		Limit:   2,
+		Uploads: strs("foo/bar/1", "foo/bar/2")})
+
+	ts.assertListMultipartUploads(defaultBucket, listUploadsOpts{Prefix: prefixFile("/"),
+		Limit:    2,
+		Prefixes: strs("foo/", "food/")})
 }
 
-func TestListMultipartUploadParts(t *testing.T) {
+func TestListMultipartUploadsDelimiterPaging(t *testing.T) {
 	ts := newTestServer(t)
 	defer ts.Close()
 
+	ts.createMultipartUpload(defaultBucket, "a/1", nil)
+	ts.createMultipartUpload(defaultBucket, "a/2", nil)
+	ts.createMultipartUpload(defaultBucket, "b", nil)
+	ts.createMultipartUpload(defaultBucket, "c/1", nil)
+	ts.createMultipartUpload(defaultBucket, "c/2/x", nil)
+	ts.createMultipartUpload(defaultBucket, "d", nil)
+
+	svc := ts.s3Client()
+	rq := &s3.ListMultipartUploadsInput{
+		Bucket:     aws.String(defaultBucket),
+		Delimiter:  aws.String("/"),
+		MaxUploads: aws.Int64(1),
+	}
+
+	var found []string
+	for pages := 0; ; pages++ {
+		if pages > 10 {
+			t.Fatal("too many pages")
+		}
+		rs, err := svc.ListMultipartUploads(rq)
+		ts.OK(err)
+		if len(rs.CommonPrefixes)+len(rs.Uploads) != 1 {
+			t.Fatal("unexpected page size", rs)
+		}
+		for _, cp := range rs.CommonPrefixes {
+			found = append(found, aws.StringValue(cp.Prefix))
+		}
+		for _, up := range rs.Uploads {
+			found = append(found, aws.StringValue(up.Key))
+		}
+		if !aws.BoolValue(rs.IsTruncated) {
+			break
+		}
+		rq.KeyMarker, rq.UploadIdMarker = rs.NextKeyMarker, rs.NextUploadIdMarker
+	}
+
+	if !reflect.DeepEqual(found, strs("a/", "b", "c/", "d")) {
+		t.Fatal("unexpected listing", found)
+	}
+}
+
+// backendFailingPuts fails every PutObject, which the fallback
+// MultipartBackend uses to store a completed upload.
+type backendFailingPuts struct {
+	*s3mem.Backend
+}
+
+func (b *backendFailingPuts) PutObject(bucketName, key string, meta map[string]string, input io.Reader, size int64) (gofakes3.PutObjectResult, error) {
+	return gofakes3.PutObjectResult{}, fmt.Errorf("disk full")
+}
+
+func TestCompleteMultipartUploadErrorAfterHeaders(t *testing.T) {
+	ts := newTestServer(t, withBackend(&backendFailingPuts{Backend: s3mem.New()}))
+	defer ts.Close()
+
+	uploadID := ts.createMultipartUpload(defaultBucket, "object", nil)
+	part := ts.uploadPart(defaultBucket, "object", uploadID, 1, []byte("hello"))
+	body, err := xml.Marshal(&gofakes3.CompleteMultipartUploadRequest{
+		Parts: []gofakes3.CompletedPart{{PartNumber: 1, ETag: aws.StringValue(part.ETag)}},
+	})
+	ts.OK(err)
+
+	// A failure while the parts are assembled is sent with '200 OK':
+	rs, rsBody := ts.sendRaw("POST", defaultBucket+"/object?uploadId="+url.QueryEscape(uploadID), body, nil)
+	if rs.StatusCode != http.StatusOK {
+		t.Fatal("unexpected status", rs.StatusCode, string(rsBody))
+	}
+	var errResp gofakes3.ErrorResponse
+	ts.OK(xml.Unmarshal(rsBody, &errResp))
+	if errResp.Code != gofakes3.ErrInternal || errResp.RequestID == "" {
+		t.Fatalf("unexpected error %+v", errResp)
+	}
+
+	// Errors in the request itself still get their own status:
+	rs, rsBody = ts.sendRaw("POST", defaultBucket+"/object?uploadId=nope", body, nil)
+	ts.assertRawErrorCode(rs, rsBody, gofakes3.ErrNoSuchUpload)
+}
+
+func TestListMultipartUploadParts(t *testing.T) {
+	ts := newTestServer(t, withFakerOptions(gofakes3.WithMinPartSize(0)))
+	defer ts.Close()
+
 	id := ts.createMultipartUpload(defaultBucket, "foo", nil)
 
 	parts := []*s3.CompletedPart{
@@ -158,3 +480,295 @@ func TestListMultipartUploadParts(t *testing.T) {
 	// No parts should be returned after the upload is completed:
 	ts.assertListUploadPartsFails(gofakes3.ErrNoSuchUpload, defaultBucket, "foo", id, listUploadPartsOpts{})
 }
+
+func TestListMultipartUploadPartsPaging(t *testing.T) {
+	ts := newTestServer(t)
+	defer ts.Close()
+
+	id := ts.createMultipartUpload(defaultBucket, "foo", nil)
+
+	// Parts are uploaded out of order, with a gap at 3, to ensure they are
+	// listed in ascending order and the marker works with sparse parts:
+	p4 := ts.uploadPart(defaultBucket, "foo", id, 4, []byte("jklm"))
+	p1 := ts.uploadPart(defaultBucket, "foo", id, 1, []byte("a"))
+	p2 := ts.uploadPart(defaultBucket, "foo", id, 2, []byte("bc"))
+	p5 := ts.uploadPart(defaultBucket, "foo", id, 5, []byte("nopqr"))
+
+	ts.assertListUploadParts(defaultBucket, "foo", id,
+		listUploadPartsOpts{Limit: 2}.withCompletedParts(p1, p2))
+	ts.assertListUploadParts(defaultBucket, "foo", id,
+		listUploadPartsOpts{Marker: 2, Limit: 2}.withCompletedParts(p4, p5))
+	ts.assertListUploadParts(defaultBucket, "foo", id,
+		listUploadPartsOpts{Marker: 3}.withCompletedParts(p4, p5))
+	ts.assertListUploadParts(defaultBucket, "foo", id,
+		listUploadPartsOpts{Marker: 5})
+
+	svc := ts.s3Client()
+	var found []*s3.Part
+	var pages int
+	ts.OK(svc.ListPartsPages(listUploadPartsOpts{Limit: 1}.input(defaultBucket, "foo", id),
+		func(rs *s3.ListPartsOutput, last bool) bool {
+			pages++
+			if last == aws.BoolValue(rs.IsTruncated) {
+				ts.Fatal("unexpected truncation on page", pages)
+			}
+			found = append(found, rs.Parts...)
+			return true
+		}))
+
+	if pages != 4 {
+		ts.Fatal("unexpected page count", pages)
+	}
+	for idx, expected := range []*s3.CompletedPart{p1, p2, p4, p5} {
+		part := found[idx]
+		if *part.PartNumber != *expected.PartNumber {
+			ts.Fatal("part", idx, "PartNumber mismatch:", *part.PartNumber, "!=", *expected.PartNumber)
+		}
+		if *part.ETag != *expected.ETag {
+			ts.Fatal("part", idx, "ETag mismatch:", *part.ETag, "!=", *expected.ETag)
+		}
+		if *part.Size != *part.PartNumber {
+			ts.Fatal("part", idx, "Size mismatch:", *part.Size, "!=", *part.PartNumber)
+		}
+		if part.LastModified == nil || part.LastModified.IsZero() {
+			ts.Fatal("part", idx, "missing LastModified")
+		}
+	}
+}
+
+func TestListMultipartUploadPartsInvalidParams(t *testing.T) {
+	ts := newTestServer(t)
+	defer ts.Close()
+
+	id := ts.createMultipartUpload(defaultBucket, "foo", nil)
+	ts.uploadPart(defaultBucket, "foo", id, 1, []byte("abc"))
+
+	for _, query := range []string{"max-parts=-5", "max-parts=abc", "part-number-marker=-1", "part-number-marker=abc"} {
+		rs, body := ts.sendRaw("GET", fmt.Sprintf("%s/foo?uploadId=%s&%s", defaultBucket, url.QueryEscape(id), query), nil, nil)
+		ts.assertRawErrorCode(rs, body, gofakes3.ErrInvalidArgument)
+	}
+	for _, query := range []string{"max-uploads=-5", "max-uploads=abc"} {
+		rs, body := ts.sendRaw("GET", defaultBucket+"?uploads&"+query, nil, nil)
+		ts.assertRawErrorCode(rs, body, gofakes3.ErrInvalidArgument)
+	}
+}
+
+func TestUploadPartWithMissingContentLength(t *testing.T) {
+	ts := newTestServer(t, withFakerOptions(gofakes3.WithRequireContentLength()))
+	defer ts.Close()
+
+	id := ts.createMultipartUpload(defaultBucket, "foo", nil)
+
+	client := ts.rawClient()
+	body := []byte("abc")
+	u := client.URL(fmt.Sprintf("/%s/foo", defaultBucket))
+	u.RawQuery = url.Values{"partNumber": {"1"}, "uploadId": {id}}.Encode()
+	rq, err := http.NewRequest("PUT", u.String(), maskReader(bytes.NewReader(body)))
+	ts.OK(err)
+	client.SetHeaders(rq, body)
+
+	rs, err := client.Do(rq)
+	ts.OK(err)
+	defer rs.Body.Close()
+	if rs.StatusCode != http.StatusLengthRequired {
+		t.Fatal("unexpected status", rs.StatusCode)
+	}
+
+	// Like objects, streaming parts are exempt, as they carry their size in
+	// x-amz-decoded-content-length:
+	chunked := []byte("3\r\nabc\r\n0\r\n\r\n")
+	rq, err = http.NewRequest("PUT", u.String(), maskReader(bytes.NewReader(chunked)))
+	ts.OK(err)
+	client.SetHeaders(rq, chunked)
+	rq.Header.Del("Content-Md5")
+	rq.Header.Set("Content-Encoding", "aws-chunked")
+	rq.Header.Set("X-Amz-Content-Sha256", "STREAMING-UNSIGNED-PAYLOAD-TRAILER")
+	rq.Header.Set("X-Amz-Decoded-Content-Length", fmt.Sprint(len(body)))
+
+	rs, err = client.Do(rq)
+	ts.OK(err)
+	defer rs.Body.Close()
+	if rs.StatusCode != http.StatusOK {
+		t.Fatal("unexpected status for streaming part", rs.StatusCode)
+	}
+	ts.assertCompleteUpload(defaultBucket, "foo", id, []*s3.CompletedPart{
+		{ETag: aws.String(rs.Header.Get("ETag")), PartNumber: aws.Int64(1)},
+	}, body)
+}
+
+func TestCompleteMultipartUploadMalformedXML(t *testing.T) {
+	ts := newTestServer(t)
+	defer ts.Close()
+
+	id := ts.createMultipartUpload(defaultBucket, "obj", nil)
+	part := ts.uploadPart(defaultBucket, "obj", id, 1, []byte("hello"))
+
+	for _, body := range []string{
+		"",
+		"garbage",
+		"<CompleteMultipartUpload><Part><PartNumber>1</PartNumber>",
+		"<CompleteMultipartUpload></CompleteMultipartUpload>",
+	} {
+		rs, out := ts.sendRaw("POST", defaultBucket+"/obj?uploadId="+id, []byte(body), nil)
+		ts.assertRawErrorCode(rs, out, gofakes3.ErrMalformedXML)
+	}
+
+	// The upload is left intact by the failed attempts:
+	ts.assertCompleteUpload(defaultBucket, "obj", id, []*s3.CompletedPart{part}, []byte("hello"))
+}
+
+func TestCompleteMultipartUploadInvalidPart(t *testing.T) {
+	ts := newTestServer(t)
+	defer ts.Close()
+	svc := ts.s3Client()
+
+	id := ts.createMultipartUpload(defaultBucket, "obj", nil)
+	part := ts.uploadPart(defaultBucket, "obj", id, 1, []byte("hello"))
+
+	for _, parts := range [][]*s3.CompletedPart{
+		{{PartNumber: aws.Int64(2), ETag: part.ETag}},
+		{{PartNumber: aws.Int64(1), ETag: aws.String(`"0123456789abcdef0123456789abcdef"`)}},
+	} {
+		_, err := svc.CompleteMultipartUpload(&s3.CompleteMultipartUploadInput{
+			Bucket:          aws.String(defaultBucket),
+			Key:             aws.String("obj"),
+			UploadId:        aws.String(id),
+			MultipartUpload: &s3.CompletedMultipartUpload{Parts: parts},
+		})
+		if !hasErrorCode(err, gofakes3.ErrInvalidPart) {
+			t.Fatal("expected ErrInvalidPart, found", err)
+		}
+	}
+	if ts.backendObjectExists(defaultBucket, "obj") {
+		t.Fatal("object created by an invalid completion")
+	}
+
+	// The upload is left intact by the failed attempts:
+	ts.assertCompleteUpload(defaultBucket, "obj", id, []*s3.CompletedPart{part}, []byte("hello"))
+}
+
+func TestCompleteMultipartUploadIfNoneMatch(t *testing.T) {
+	ts := newTestServer(t)
+	defer ts.Close()
+	svc := ts.s3Client()
+
+	// The SDK in use predates conditional writes, so the header is added to
+	// the request by hand:
+	complete := func(id string, part *s3.CompletedPart) error {
+		rq, _ := svc.CompleteMultipartUploadRequest(&s3.CompleteMultipartUploadInput{
+			Bucket:          aws.String(defaultBucket),
+			Key:             aws.String("obj"),
+			UploadId:        aws.String(id),
+			MultipartUpload: &s3.CompletedMultipartUpload{Parts: []*s3.CompletedPart{part}},
+		})
+		rq.HTTPRequest.Header.Set("If-None-Match", "*")
+		return rq.Send()
+	}
+
+	id := ts.createMultipartUpload(defaultBucket, "obj", nil)
+	part := ts.uploadPart(defaultBucket, "obj", id, 1, []byte("hello"))
+	ts.OK(complete(id, part))
+	ts.assertObject(defaultBucket, "obj", nil, "hello")
+
+	id = ts.createMultipartUpload(defaultBucket, "obj", nil)
+	part = ts.uploadPart(defaultBucket, "obj", id, 1, []byte("world"))
+	if err := complete(id, part); !hasErrorCode(err, gofakes3.ErrPreconditionFailed) {
+		t.Fatal("expected ErrPreconditionFailed, found", err)
+	}
+	ts.assertObject(defaultBucket, "obj", nil, "hello")
+
+	// The staged parts are left for the client to abort:
+	ts.assertListUploadParts(defaultBucket, "obj", id, listUploadPartsOpts{}.withCompletedParts(part))
+	ts.assertAbortMultipartUpload(defaultBucket, "obj", gofakes3.UploadID(id))
+}
+
+func TestCompletedUploadParts(t *testing.T) {
+	ts := newTestServer(t)
+	defer ts.Close()
+
+	bodies := [][]byte{randomFileBody(5 * 1024 * 1024), randomFileBody(5 * 1024 * 1024), []byte("last")}
+	id := ts.createMultipartUpload(defaultBucket, "obj", nil)
+	var parts []*s3.CompletedPart
+	for idx, body := range bodies {
+		parts = append(parts, ts.uploadPart(defaultBucket, "obj", id, int64(idx+1), body))
+	}
+	ts.assertCompleteUpload(defaultBucket, "obj", id, parts, bytes.Join(bodies, nil))
+
+	head, _ := ts.sendRaw("HEAD", defaultBucket+"/obj", nil, nil)
+	objectETag := head.Header.Get("ETag")
+
+	for idx, part := range parts {
+		path := fmt.Sprintf("%s/obj?partNumber=%d", defaultBucket, idx+1)
+		rs, _ := ts.sendRaw("HEAD", path, nil, nil)
+		if rs.StatusCode != http.StatusPartialContent || rs.Header.Get("ETag") != aws.StringValue(part.ETag) ||
+			rs.ContentLength != int64(len(bodies[idx])) || rs.Header.Get(gofakes3.PartsCountHeader) != "3" {
+			t.Fatal("unexpected response for part", idx+1, rs.StatusCode, rs.Header)
+		}
+
+		// Like S3, a GET returns the whole object's ETag:
+		rs, body := ts.sendRaw("GET", path, nil, nil)
+		if rs.StatusCode != http.StatusPartialContent || rs.Header.Get("ETag") != objectETag || !bytes.Equal(body, bodies[idx]) {
+			t.Fatal("unexpected response for part", idx+1, rs.StatusCode, rs.Header)
+		}
+	}
+
+	rs, body := ts.sendRaw("GET", defaultBucket+"/obj?partNumber=4", nil, nil)
+	ts.assertRawErrorCode(rs, body, gofakes3.ErrInvalidPartNumber)
+
+	rs, body = ts.sendRaw("GET", defaultBucket+"/obj?attributes", nil, http.Header{
+		gofakes3.ObjectAttributesHeader: {gofakes3.ObjectAttributeObjectParts},
+		"X-Amz-Max-Parts":               {"2"},
+	})
+	if rs.StatusCode != http.StatusOK {
+		t.Fatal("unexpected status", rs.StatusCode, string(body))
+	}
+	var out gofakes3.GetObjectAttributesResult
+	ts.OK(xml.Unmarshal(body, &out))
+	if out.ObjectParts == nil || out.ObjectParts.PartsCount != 3 || !out.ObjectParts.IsTruncated || len(out.ObjectParts.Parts) != 2 {
+		t.Fatalf("unexpected parts %+v", out.ObjectParts)
+	}
+	for idx, part := range out.ObjectParts.Parts {
+		if part.PartNumber != idx+1 || part.ETag != aws.StringValue(parts[idx].ETag) || part.Size != int64(len(bodies[idx])) {
+			t.Fatalf("unexpected part %+v", part)
+		}
+	}
+
+	// An object that was not uploaded in parts has a single part:
+	ts.backendPutString(defaultBucket, "single", nil, "hello")
+	rs, body = ts.sendRaw("GET", defaultBucket+"/single?partNumber=1", nil, nil)
+	if rs.StatusCode != http.StatusPartialContent || rs.Header.Get("Content-Range") != "bytes 0-4/5" ||
+		rs.Header.Get(gofakes3.PartsCountHeader) != "1" || string(body) != "hello" {
+		t.Fatal("unexpected response", rs.StatusCode, rs.Header, string(body))
+	}
+	for _, partNumber := range []string{"2", "10000"} {
+		rs, body = ts.sendRaw("GET", defaultBucket+"/single?partNumber="+partNumber, nil, nil)
+		ts.assertRawErrorCode(rs, body, gofakes3.ErrInvalidPartNumber)
+	}
+}
+
+func TestListAllMultipartUploads(t *testing.T) {
+	ts := newTestServer(t)
+	defer ts.Close()
+	ts.backendCreateBucket("other")
+
+	ts.createMultipartUpload(defaultBucket, "b", nil)
+	ts.createMultipartUpload("other", "a", nil)
+	done := ts.createMultipartUpload(defaultBucket, "done", nil)
+	ts.createMultipartUpload(defaultBucket, "a", nil)
+	aborted := ts.createMultipartUpload(defaultBucket, "aborted", nil)
+
+	part := ts.uploadPart(defaultBucket, "done", done, 1, []byte("hello"))
+	ts.assertCompleteUpload(defaultBucket, "done", done, []*s3.CompletedPart{part}, "hello")
+	ts.assertAbortMultipartUpload(defaultBucket, "aborted", gofakes3.UploadID(aborted))
+
+	uploads, err := ts.ListAllMultipartUploads()
+	ts.OK(err)
+	expected := []gofakes3.MultipartUploadInfo{
+		{Bucket: defaultBucket, Key: "a", UploadID: "4", Initiated: ts.Now()},
+		{Bucket: defaultBucket, Key: "b", UploadID: "1", Initiated: ts.Now()},
+		{Bucket: "other", Key: "a", UploadID: "2", Initiated: ts.Now()},
+	}
+	if !reflect.DeepEqual(uploads, expected) {
+		t.Fatalf("unexpected uploads %+v", uploads)
+	}
+}
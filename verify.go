@@ -0,0 +1,236 @@
+package gofakes3
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// VerifyProblemKind identifies the kind of inconsistency described by a
+// VerifyProblem.
+type VerifyProblemKind string
+
+const (
+	// VerifyObjectMissing is reported when an object that was listed by the
+	// Backend could not be retrieved.
+	VerifyObjectMissing VerifyProblemKind = "ObjectMissing"
+
+	// VerifySizeMismatch is reported when the number of bytes stored for an
+	// object differs from the size the Backend reports for it.
+	VerifySizeMismatch VerifyProblemKind = "SizeMismatch"
+
+	// VerifyETagMismatch is reported when the MD5 of the bytes stored for an
+	// object or part differs from its ETag.
+	VerifyETagMismatch VerifyProblemKind = "ETagMismatch"
+
+	// VerifyUploadMissing is reported when an in-progress multipart upload was
+	// listed, but its parts could not be, for example because the Backend no
+	// longer has them.
+	VerifyUploadMissing VerifyProblemKind = "UploadMissing"
+)
+
+// VerifyProblem describes a single inconsistency found by GoFakeS3.Verify.
+type VerifyProblem struct {
+	Kind   VerifyProblemKind
+	Bucket string
+	Key    string
+
+	// UploadID and PartNumber are only set for problems with multipart
+	// uploads. PartNumber is zero if the problem is with the upload as a
+	// whole.
+	UploadID   UploadID
+	PartNumber int
+
+	// Expected and Found are set for mismatches, to the value recorded by the
+	// Backend and the value recomputed from the stored data respectively.
+	Expected string
+	Found    string
+
+	// Err is the error returned by the Backend, if the problem was detected
+	// by an operation failing.
+	Err error
+}
+
+func (p VerifyProblem) String() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%s: %s/%s", p.Kind, p.Bucket, p.Key)
+	if p.UploadID != "" {
+		fmt.Fprintf(&sb, " upload %s", p.UploadID)
+	}
+	if p.PartNumber != 0 {
+		fmt.Fprintf(&sb, " part %d", p.PartNumber)
+	}
+	if p.Expected != "" || p.Found != "" {
+		fmt.Fprintf(&sb, ": expected %q, found %q", p.Expected, p.Found)
+	}
+	if p.Err != nil {
+		fmt.Fprintf(&sb, ": %v", p.Err)
+	}
+	return sb.String()
+}
+
+// VerifyReport is returned by GoFakeS3.Verify.
+type VerifyReport struct {
+	// The number of objects and in-progress multipart uploads checked.
+	Objects int
+	Uploads int
+
+	Problems []VerifyProblem
+}
+
+// OK reports whether no problems were found.
+func (r *VerifyReport) OK() bool {
+	return len(r.Problems) == 0
+}
+
+func (r *VerifyReport) add(p VerifyProblem) {
+	r.Problems = append(r.Problems, p)
+}
+
+// Verify checks the integrity of everything stored in the Backend: each
+// object's data is read back and its MD5 recomputed and compared to its
+// ETag, and the parts of every in-progress multipart upload are checked to
+// still exist.
+//
+// Verify reads every object in full, so it is intended as a debugging aid for
+// Backend implementers rather than something to call while serving requests.
+// Inconsistencies are collected in the returned VerifyReport; the error is
+// only non-nil if the Backend could not be walked at all.
+func (g *GoFakeS3) Verify() (*VerifyReport, error) {
+	buckets, err := g.storage.ListBuckets()
+	if err != nil {
+		return nil, err
+	}
+
+	var report VerifyReport
+	for _, bucket := range buckets {
+		if err := g.verifyObjects(bucket.Name, &report); err != nil {
+			return nil, err
+		}
+		if err := g.verifyUploads(bucket.Name, &report); err != nil {
+			return nil, err
+		}
+	}
+	return &report, nil
+}
+
+func (g *GoFakeS3) verifyObjects(bucket string, report *VerifyReport) error {
+	// Pagination is not needed; a Backend must return everything when
+	// passed an empty ListBucketPage:
+	objects, err := g.storage.ListBucket(bucket, &Prefix{}, ListBucketPage{})
+	if err != nil {
+		return err
+	}
+
+	for _, item := range objects.Contents {
+		report.Objects++
+
+		obj, err := g.storage.GetObject(bucket, item.Key, nil)
+		if err != nil {
+			report.add(VerifyProblem{Kind: VerifyObjectMissing, Bucket: bucket, Key: item.Key, Err: err})
+			continue
+		}
+
+		hash := md5.New()
+		size, err := io.Copy(hash, obj.Contents)
+		obj.Contents.Close()
+		if err != nil {
+			return err
+		}
+
+		if size != obj.Size {
+			report.add(VerifyProblem{
+				Kind: VerifySizeMismatch, Bucket: bucket, Key: item.Key,
+				Expected: fmt.Sprint(obj.Size), Found: fmt.Sprint(size),
+			})
+		}
+
+		// Backends may not return the Hash from GetObject, in which case the
+		// ETag they listed the object with is all there is to check against:
+		expected := hex.EncodeToString(obj.Hash)
+		if expected == "" {
+			expected = strings.Trim(item.ETag, `"`)
+		}
+		if found := hex.EncodeToString(hash.Sum(nil)); expected != found {
+			report.add(VerifyProblem{
+				Kind: VerifyETagMismatch, Bucket: bucket, Key: item.Key,
+				Expected: expected, Found: found,
+			})
+		}
+	}
+	return nil
+}
+
+func (g *GoFakeS3) verifyUploads(bucket string, report *VerifyReport) error {
+	var marker *UploadListMarker
+	for {
+		uploads, err := g.multipart.ListMultipartUploads(bucket, marker, Prefix{}, DefaultMaxUploads)
+		if HasErrorCode(err, ErrNoSuchUpload) {
+			// The default MultipartBackend reports this if the bucket has
+			// never had any uploads.
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		for _, upload := range uploads.Uploads {
+			report.Uploads++
+			g.verifyUpload(bucket, upload, report)
+		}
+
+		if !uploads.IsTruncated {
+			return nil
+		}
+		marker = &UploadListMarker{Object: uploads.NextKeyMarker, UploadID: uploads.NextUploadIDMarker}
+	}
+}
+
+func (g *GoFakeS3) verifyUpload(bucket string, upload ListMultipartUploadItem, report *VerifyReport) {
+	// The parts of an upload held by the default MultipartBackend can be
+	// checked byte for byte:
+	if mb, ok := g.multipart.(*multipartBackend); ok {
+		mpu, err := mb.uploader.Get(bucket, upload.Key, upload.UploadID)
+		if err != nil {
+			report.add(VerifyProblem{Kind: VerifyUploadMissing, Bucket: bucket, Key: upload.Key, UploadID: upload.UploadID, Err: err})
+			return
+		}
+		mpu.verifyParts(report)
+		return
+	}
+
+	// Other MultipartBackends can only be asked whether they still know
+	// about the parts:
+	var marker int
+	for {
+		parts, err := g.multipart.ListParts(bucket, upload.Key, upload.UploadID, marker, DefaultMaxUploadParts)
+		if err != nil {
+			report.add(VerifyProblem{Kind: VerifyUploadMissing, Bucket: bucket, Key: upload.Key, UploadID: upload.UploadID, Err: err})
+			return
+		}
+		if !parts.IsTruncated {
+			return
+		}
+		marker = parts.NextPartNumberMarker
+	}
+}
+
+func (mpu *multipartUpload) verifyParts(report *VerifyReport) {
+	mpu.mu.Lock()
+	defer mpu.mu.Unlock()
+
+	for partNumber, part := range mpu.parts {
+		if part == nil {
+			continue
+		}
+		sum := md5.Sum(part.Body)
+		if expected, found := strings.Trim(part.ETag, `"`), hex.EncodeToString(sum[:]); expected != found {
+			report.add(VerifyProblem{
+				Kind: VerifyETagMismatch, Bucket: mpu.Bucket, Key: mpu.Object,
+				UploadID: mpu.ID, PartNumber: partNumber,
+				Expected: expected, Found: found,
+			})
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package gofakes3_test
+
+import (
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/johannesboyne/gofakes3"
+	"github.com/johannesboyne/gofakes3/backend/s3mem"
+)
+
+// backendWithCorruptObject returns the wrong data for a single key, as a
+// Backend with damaged storage might.
+type backendWithCorruptObject struct {
+	gofakes3.Backend
+	key string
+}
+
+func (b *backendWithCorruptObject) GetObject(bucketName, objectName string, rangeRequest *gofakes3.ObjectRangeRequest) (*gofakes3.Object, error) {
+	obj, err := b.Backend.GetObject(bucketName, objectName, rangeRequest)
+	if err != nil || objectName != b.key {
+		return obj, err
+	}
+	obj.Contents.Close()
+	obj.Contents = ioutil.NopCloser(strings.NewReader("corrupt"))
+	return obj, nil
+}
+
+func TestVerify(t *testing.T) {
+	ts := newTestServer(t, withBackend(&backendWithCorruptObject{Backend: s3mem.New(), key: "bad"}))
+	defer ts.Close()
+
+	ts.backendPutString(defaultBucket, "good", nil, "hello")
+	ts.backendPutString(defaultBucket, "bad", nil, "hello")
+	uploadID := ts.createMultipartUpload(defaultBucket, "upload", nil)
+	ts.uploadPart(defaultBucket, "upload", uploadID, 1, []byte("part"))
+
+	report, err := ts.Verify()
+	ts.OK(err)
+	if report.Objects != 2 || report.Uploads != 1 {
+		t.Fatal("unexpected counts", report.Objects, report.Uploads)
+	}
+	if report.OK() {
+		t.Fatal("expected problems")
+	}
+
+	var kinds []gofakes3.VerifyProblemKind
+	for _, problem := range report.Problems {
+		if problem.Key != "bad" {
+			t.Fatal("unexpected problem", problem)
+		}
+		kinds = append(kinds, problem.Kind)
+	}
+	if len(kinds) != 2 || kinds[0] != gofakes3.VerifySizeMismatch || kinds[1] != gofakes3.VerifyETagMismatch {
+		t.Fatal("unexpected problems", report.Problems)
+	}
+}
@@ -0,0 +1,31 @@
+package gofakes3
+
+import (
+	"io"
+	"sync/atomic"
+)
+
+// limitWrite wraps the body of an object or part write so that it fails once
+// the bytes written since the GoFakeS3 was created exceed the limit set by
+// WithWriteByteLimit. Backends read the whole body before storing anything,
+// so the failed write leaves no partial object behind.
+func (g *GoFakeS3) limitWrite(rdr io.Reader) io.Reader {
+	if g.writeByteLimit < 0 {
+		return rdr
+	}
+	return &writeLimitReader{g: g, inner: rdr}
+}
+
+type writeLimitReader struct {
+	g     *GoFakeS3
+	inner io.Reader
+}
+
+func (r *writeLimitReader) Read(p []byte) (n int, err error) {
+	n, err = r.inner.Read(p)
+	if total := atomic.AddInt64(&r.g.bytesWritten, int64(n)); total > r.g.writeByteLimit {
+		r.g.log.Print(LogWarn, "write byte limit exceeded:", total, ">", r.g.writeByteLimit)
+		return n, ErrInternal
+	}
+	return n, err
+}